@@ -0,0 +1,336 @@
+// Package audit writes and verifies the tamper-evident request log.
+//
+// Entries are NDJSON lines. Each carries the hash of the entry before it, so
+// editing, removing or reordering an entry breaks the chain at that point.
+// The active file is rotated into numbered files; the chain continues
+// across them.
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DirName is the audit directory under the state dir.
+	DirName = "audit"
+	// ActiveFileName is the file new entries are appended to.
+	ActiveFileName = "audit.ndjson"
+	// LockFileName is locked while an entry is appended, so processes
+	// sharing the directory extend one chain.
+	LockFileName = "audit.lock"
+
+	rotatedPrefix = "audit-"
+	rotatedSuffix = ".ndjson"
+	// maxEntryBytes bounds the tail read used to recover the chain head.
+	maxEntryBytes = 1 << 20
+)
+
+// Span locates a returned passage, mirroring the span objects of tool
+// results.
+type Span struct {
+	Kind      string `json:"kind"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Page      int    `json:"page,omitempty"`
+	StartMS   int    `json:"start_ms,omitempty"`
+	EndMS     int    `json:"end_ms,omitempty"`
+}
+
+// Ref is a file, and optionally a span of it, that a response exposed.
+type Ref struct {
+	RelPath string `json:"rel_path"`
+	Span    *Span  `json:"span,omitempty"`
+}
+
+// Entry is one audit record. Seq, Time, Prev and Hash are filled in by
+// Logger.Append.
+type Entry struct {
+	Seq        uint64 `json:"seq"`
+	Time       string `json:"ts"`
+	Prev       string `json:"prev"`
+	Session    string `json:"session,omitempty"`
+	Token      string `json:"token,omitempty"`
+	ClientIP   string `json:"client_ip,omitempty"`
+	Method     string `json:"method,omitempty"`
+	Tool       string `json:"tool,omitempty"`
+	ArgsDigest string `json:"args_digest,omitempty"`
+	Refs       []Ref  `json:"refs,omitempty"`
+	Status     string `json:"status"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Hash       string `json:"hash"`
+}
+
+// computeHash returns the hash of e with its Hash field cleared.
+func computeHash(e Entry) (string, error) {
+	e.Hash = ""
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Digest returns the hash used for argument digests.
+func Digest(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Logger appends entries to the log in one directory. It is safe for
+// concurrent use, and several processes may share a directory: each append
+// holds an exclusive lock on LockFileName and first catches up with entries
+// other processes wrote, so they all extend the same chain.
+type Logger struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	lock     *os.File
+	file     *os.File
+	size     int64
+	seq      uint64
+	prev     string
+}
+
+// Open opens the log in dir, creating it if needed, and resumes the chain
+// from its last entry. maxBytes is the rotation threshold; zero disables
+// rotation. A log whose last entry cannot be read is an error, so a damaged
+// chain is never silently extended.
+func Open(dir string, maxBytes int64) (*Logger, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create audit dir: %w", err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, LockFileName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit lock: %w", err)
+	}
+	l := &Logger{dir: dir, maxBytes: maxBytes, lock: lock}
+	if err := lockFile(lock); err != nil {
+		_ = lock.Close()
+		return nil, fmt.Errorf("lock audit log: %w", err)
+	}
+	err = l.resume()
+	_ = unlockFile(lock)
+	if err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// resume opens the active file and reads the chain head from the newest
+// entry on disk. Callers hold the file lock.
+func (l *Logger) resume() error {
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+	l.seq, l.prev = 0, ""
+	files, err := logFiles(l.dir)
+	if err != nil {
+		return err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		last, ok, err := lastEntry(files[i])
+		if err != nil {
+			return err
+		}
+		if ok {
+			l.seq, l.prev = last.Seq, last.Hash
+			break
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(l.dir, ActiveFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// catchUp resumes the chain when another process has appended to or
+// rotated the active file since this logger last wrote. Callers hold the
+// file lock and l.mu.
+func (l *Logger) catchUp() error {
+	current, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	onDisk, err := os.Stat(filepath.Join(l.dir, ActiveFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	if err == nil && os.SameFile(current, onDisk) && current.Size() == l.size {
+		return nil
+	}
+	return l.resume()
+}
+
+// Append chains e onto the log and writes it.
+func (l *Logger) Append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.New("audit log is closed")
+	}
+	if err := lockFile(l.lock); err != nil {
+		return fmt.Errorf("lock audit log: %w", err)
+	}
+	defer func() { _ = unlockFile(l.lock) }()
+	if err := l.catchUp(); err != nil {
+		return err
+	}
+
+	e.Seq = l.seq + 1
+	if e.Time == "" {
+		e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	e.Prev = l.prev
+	hash, err := computeHash(e)
+	if err != nil {
+		return fmt.Errorf("hash audit entry: %w", err)
+	}
+	e.Hash = hash
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if l.maxBytes > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	// one write per line keeps concurrent readers from seeing partial lines.
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	l.seq, l.prev = e.Seq, e.Hash
+	return nil
+}
+
+// rotate renames the active file after the last sequence number it holds
+// and starts a new one. Callers hold the file lock and l.mu.
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("close audit log: %w", err)
+	}
+	l.file = nil
+	active := filepath.Join(l.dir, ActiveFileName)
+	rotated := filepath.Join(l.dir, fmt.Sprintf("%s%020d%s", rotatedPrefix, l.seq, rotatedSuffix))
+	if err := os.Rename(active, rotated); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+	f, err := os.OpenFile(active, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	l.file, l.size = f, 0
+	return nil
+}
+
+// Close closes the active file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	if lockErr := l.lock.Close(); err == nil {
+		err = lockErr
+	}
+	return err
+}
+
+// logFiles lists the rotated files in order, followed by the active file if
+// it exists.
+func logFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read audit dir: %w", err)
+	}
+	var files []string
+	hasActive := false
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if name == ActiveFileName {
+			hasActive = true
+			continue
+		}
+		if strings.HasPrefix(name, rotatedPrefix) && strings.HasSuffix(name, rotatedSuffix) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	// zero-padded sequence numbers sort lexically.
+	sort.Strings(files)
+	if hasActive {
+		files = append(files, filepath.Join(dir, ActiveFileName))
+	}
+	return files, nil
+}
+
+// lastEntry reads the final entry of path. ok is false for an empty file.
+func lastEntry(path string) (Entry, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("open audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("stat audit log: %w", err)
+	}
+	offset := info.Size() - maxEntryBytes
+	if offset < 0 {
+		offset = 0
+	}
+	tail, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("read audit log: %w", err)
+	}
+	tail = bytes.TrimRight(tail, "\n")
+	if len(tail) == 0 {
+		return Entry{}, false, nil
+	}
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	} else if offset > 0 {
+		return Entry{}, false, fmt.Errorf("audit log %s: last entry is too large", path)
+	}
+	var e Entry
+	if err := json.Unmarshal(tail, &e); err != nil || e.Hash == "" {
+		return Entry{}, false, fmt.Errorf("audit log %s: last entry is damaged; run dir2mcp audit verify", path)
+	}
+	return e, true, nil
+}
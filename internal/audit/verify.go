@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Report summarizes a verified log.
+type Report struct {
+	Files    []string
+	Entries  uint64
+	FirstSeq uint64
+	LastSeq  uint64
+	LastHash string
+	// Truncated is set when the oldest entry is not the first of the chain,
+	// meaning older files were removed. The entries that remain are still
+	// verified against each other.
+	Truncated bool
+}
+
+// ChainError reports where the chain breaks.
+type ChainError struct {
+	File   string
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	if e.Seq > 0 {
+		return fmt.Sprintf("%s:%d (seq %d): %s", e.File, e.Line, e.Seq, e.Reason)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Reason)
+}
+
+// Verify walks every file of the log in dir and checks that each entry's
+// hash matches its content and that sequence numbers and prev hashes link
+// up. The first break is returned as a *ChainError.
+func Verify(dir string) (Report, error) {
+	files, err := logFiles(dir)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{}
+	for _, path := range files {
+		report.Files = append(report.Files, filepath.Base(path))
+		if err := verifyFile(path, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func verifyFile(path string, report *Report) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	name := filepath.Base(path)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxEntryBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return &ChainError{File: name, Line: line, Reason: "malformed entry"}
+		}
+		fail := func(reason string) error {
+			return &ChainError{File: name, Line: line, Seq: e.Seq, Reason: reason}
+		}
+		hash, err := computeHash(e)
+		if err != nil || hash != e.Hash {
+			return fail("hash does not match entry content")
+		}
+		if report.Entries == 0 {
+			report.FirstSeq = e.Seq
+			switch {
+			case e.Seq == 0:
+				return fail("sequence numbers start at 1")
+			case e.Seq == 1 && e.Prev != "":
+				return fail("first entry has a prev hash")
+			case e.Seq > 1:
+				report.Truncated = true
+			}
+		} else {
+			if e.Seq != report.LastSeq+1 {
+				return fail(fmt.Sprintf("expected seq %d", report.LastSeq+1))
+			}
+			if e.Prev != report.LastHash {
+				return fail("prev hash does not match the previous entry")
+			}
+		}
+		report.Entries++
+		report.LastSeq, report.LastHash = e.Seq, e.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return &ChainError{File: name, Line: line + 1, Reason: err.Error()}
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,15 +22,19 @@ import (
 	"time"
 
 	"dir2mcp/internal/appstate"
+	"dir2mcp/internal/audit"
 	"dir2mcp/internal/config"
 	"dir2mcp/internal/elevenlabs"
 	"dir2mcp/internal/index"
 	"dir2mcp/internal/ingest"
 	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/metrics"
 	"dir2mcp/internal/mistral"
 	"dir2mcp/internal/model"
 	"dir2mcp/internal/protocol"
 	"dir2mcp/internal/retrieval"
+	"dir2mcp/internal/sessions"
+	"dir2mcp/internal/snapshot"
 	"dir2mcp/internal/store"
 )
 
@@ -50,30 +55,46 @@ const (
 	x402FacilitatorTokenEnvVar = "DIR2MCP_X402_FACILITATOR_TOKEN"
 	connectionFileName         = "connection.json"
 	secretTokenName            = "secret.token"
+
+	transportHTTP  = "http"
+	transportStdio = "stdio"
 )
 
 var commands = map[string]struct{}{
-	"up":      {},
-	"status":  {},
-	"ask":     {},
-	"reindex": {},
-	"config":  {},
-	"version": {},
+	"up":       {},
+	"stdio":    {},
+	"status":   {},
+	"ask":      {},
+	"reindex":  {},
+	"config":   {},
+	"snapshot": {},
+	"token":    {},
+	"audit":    {},
+	"version":  {},
 }
 
 type App struct {
+	stdin  io.Reader
 	stdout io.Writer
 	stderr io.Writer
 
 	newIngestor  func(config.Config, model.Store) model.Ingestor
 	newStore     func(config.Config) model.Store
 	newRetriever func(config.Config, model.Store) model.Retriever
+
+	// providerObserver is attached to the provider clients runUp and the
+	// default ingestor create; it is set when metrics are enabled.
+	providerObserver model.ProviderObserver
 }
 
 type indexingStateAware interface {
 	SetIndexingState(state *appstate.IndexingState)
 }
 
+type documentChangeAware interface {
+	SetDocumentChangeHandler(fn func(model.DocumentChange))
+}
+
 type contentHashResetter interface {
 	ClearDocumentContentHashes(ctx context.Context) error
 }
@@ -94,6 +115,8 @@ type RuntimeHooks struct {
 	NewIngestor  func(config.Config, model.Store) model.Ingestor
 	NewStore     func(config.Config) model.Store
 	NewRetriever func(config.Config, model.Store) model.Retriever
+	// Stdin replaces os.Stdin for the stdio transport.
+	Stdin io.Reader
 }
 
 type globalOptions struct {
@@ -124,9 +147,12 @@ type upOptions struct {
 	x402ToolsCallEnabled          bool
 	x402ToolsCallEnabledIsSet     bool
 	auth                          string
-	listen                        string
-	mcpPath                       string
-	allowedOrigins                string
+	// transport is "http" (the default) or "stdio".
+	transport      string
+	listen         string
+	mcpPath        string
+	allowedOrigins string
+	metricsListen  string
 	// overrideable models, set via flags or env/config
 	embedModelText string
 	embedModelCode string
@@ -159,6 +185,19 @@ func (o *optionalBoolFlag) IsBoolFlag() bool {
 	return true
 }
 
+type statusOptions struct {
+	dryRun bool
+}
+
+type snapshotCreateOptions struct {
+	output string
+}
+
+type snapshotRestoreOptions struct {
+	force   bool
+	archive string
+}
+
 type askOptions struct {
 	question   string
 	k          int
@@ -167,6 +206,17 @@ type askOptions struct {
 	pathPrefix string
 	fileGlob   string
 	docTypes   []string
+	debug      bool
+	rerank     string
+	mmrLambda  float64
+	maxPerFile int
+	strict     string
+	filter     model.MetadataFilter
+}
+
+type grepOptions struct {
+	query   model.GrepQuery
+	timeout time.Duration
 }
 
 type authMaterial struct {
@@ -240,24 +290,27 @@ func NewApp() *App {
 }
 
 func NewAppWithIO(stdout, stderr io.Writer) *App {
-	return &App{
+	app := &App{
+		stdin:  os.Stdin,
 		stdout: stdout,
 		stderr: stderr,
-		newIngestor: func(cfg config.Config, st model.Store) model.Ingestor {
-			svc := ingest.NewService(cfg, st)
-			if strings.TrimSpace(cfg.MistralAPIKey) != "" {
-				client := mistral.NewClient(cfg.MistralBaseURL, cfg.MistralAPIKey)
-				svc.SetOCR(client)
-				svc.SetTranscriber(client)
-			}
-			return svc
-		},
 		// default store constructor uses sqlite in the configured state
 		// directory.  tests can override via RuntimeHooks.NewStore.
 		newStore: func(cfg config.Config) model.Store {
 			return store.NewSQLiteStore(filepath.Join(cfg.StateDir, "meta.sqlite"))
 		},
 	}
+	app.newIngestor = func(cfg config.Config, st model.Store) model.Ingestor {
+		svc := ingest.NewService(cfg, st)
+		if strings.TrimSpace(cfg.MistralAPIKey) != "" {
+			client := mistral.NewClient(cfg.MistralBaseURL, cfg.MistralAPIKey)
+			client.Observer = app.providerObserver
+			svc.SetOCR(client)
+			svc.SetTranscriber(client)
+		}
+		return svc
+	}
+	return app
 }
 
 func NewAppWithIOAndHooks(stdout, stderr io.Writer, hooks RuntimeHooks) *App {
@@ -271,6 +324,9 @@ func NewAppWithIOAndHooks(stdout, stderr io.Writer, hooks RuntimeHooks) *App {
 	if hooks.NewRetriever != nil {
 		app.newRetriever = hooks.NewRetriever
 	}
+	if hooks.Stdin != nil {
+		app.stdin = hooks.Stdin
+	}
 	return app
 }
 
@@ -319,14 +375,30 @@ func (a *App) RunWithContext(ctx context.Context, args []string) int {
 			return exitConfigInvalid
 		}
 		return a.runUp(ctx, upOpts)
+	case "stdio":
+		upOpts, parseErr := parseUpOptions(globalOpts, remaining[1:])
+		if parseErr != nil {
+			writef(a.stderr, "invalid stdio flags: %v\n", parseErr)
+			return exitConfigInvalid
+		}
+		upOpts.transport = transportStdio
+		return a.runUp(ctx, upOpts)
 	case "status":
 		return a.runStatus(ctx, globalOpts, remaining[1:])
 	case "ask":
 		return a.runAsk(ctx, globalOpts, remaining[1:])
+	case "grep":
+		return a.runGrep(ctx, globalOpts, remaining[1:])
 	case "reindex":
 		return a.runReindex(ctx)
 	case "config":
 		return a.runConfig(ctx, globalOpts, remaining[1:])
+	case "snapshot":
+		return a.runSnapshot(ctx, globalOpts, remaining[1:])
+	case "token":
+		return a.runToken(globalOpts, remaining[1:])
+	case "audit":
+		return a.runAudit(globalOpts, remaining[1:])
 	case "version":
 		writeln(a.stdout, "dir2mcp v0.0.0-dev")
 		return exitSuccess
@@ -340,8 +412,14 @@ func (a *App) RunWithContext(ctx context.Context, args []string) int {
 func (a *App) printUsage() {
 	writeln(a.stdout, "dir2mcp")
 	writeln(a.stdout, "usage: dir2mcp [--json] [--non-interactive] <command>")
-	writeln(a.stdout, "commands: up, status, ask, reindex, config, version")
-	writeln(a.stdout, "for 'up' the following flags are available: --listen, --mcp-path, --public, --read-only, --auth, --allowed-origins, --embed-model-text, --embed-model-code, --chat-model, --x402, --x402-facilitator-url, ...")
+	writeln(a.stdout, "commands: up, stdio, status, ask, grep, reindex, config, snapshot, token, audit, version")
+	writeln(a.stdout, "for 'status' use --dry-run to list pending schema migrations without applying them")
+	writeln(a.stdout, "for 'grep' use [--literal] [--ignore-case] [--path-prefix <p>] [--file-glob <g>] [--context <n>] [--max-matches <n>] [--timeout <d>] <pattern>")
+	writeln(a.stdout, "'stdio' is 'up --transport stdio': newline-delimited JSON-RPC on stdin/stdout, logs on stderr")
+	writeln(a.stdout, "for 'snapshot' use 'create [--output <file>]' or 'restore [--force] <file>'")
+	writeln(a.stdout, "for 'token' use 'create --name <n> [--tools <t,...>] [--path-prefix <p>] [--path-glob <g>] [--expires <d>] [--rate-limit <rps>] [--burst <n>]', 'list' or 'revoke <id|name>'")
+	writeln(a.stdout, "for 'audit' use 'verify' to check the hash chain of the audit log")
+	writeln(a.stdout, "for 'up' the following flags are available: --transport, --listen, --mcp-path, --public, --read-only, --auth, --allowed-origins, --embed-model-text, --embed-model-code, --chat-model, --x402, --x402-facilitator-url, ...")
 }
 
 func (a *App) runUp(ctx context.Context, opts upOptions) int {
@@ -429,6 +507,11 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 	if opts.x402ToolsCallEnabledIsSet {
 		cfg.X402.ToolsCallEnabled = opts.x402ToolsCallEnabled
 	}
+	stdio := opts.transport == transportStdio
+	if stdio && opts.public {
+		writeln(a.stderr, "CONFIG_INVALID: --public requires the HTTP transport")
+		return exitConfigInvalid
+	}
 	if opts.public {
 		cfg.Public = true
 
@@ -451,12 +534,35 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 		writeln(a.stderr, "CONFIG_INVALID: --mcp-path must start with '/'")
 		return exitConfigInvalid
 	}
+	if opts.metricsListen != "" {
+		cfg.MetricsListenAddr = opts.metricsListen
+	}
+	metricsEnabled := strings.TrimSpace(cfg.MetricsListenAddr) != ""
+	if metricsEnabled && strings.EqualFold(strings.TrimSpace(cfg.MetricsAuth), "none") &&
+		!isLoopbackListenAddr(cfg.MetricsListenAddr) && !opts.forceInsecure {
+		writeln(a.stderr, "ERROR: CONFIG_INVALID: metrics on a non-loopback address require auth. Use metrics_auth auto or --force-insecure to override (unsafe).")
+		return exitConfigInvalid
+	}
 
 	strictX402 := strings.EqualFold(strings.TrimSpace(cfg.X402.Mode), "required")
 	if err := cfg.ValidateX402(strictX402); err != nil {
 		writef(a.stderr, "CONFIG_INVALID: %v\n", err)
 		return exitConfigInvalid
 	}
+	if stdio && cfg.X402.Mode != "off" {
+		writeln(a.stderr, "CONFIG_INVALID: x402 payment gating requires the HTTP transport")
+		return exitConfigInvalid
+	}
+	if strings.EqualFold(strings.TrimSpace(cfg.AuthMode), "oauth") {
+		if stdio {
+			writeln(a.stderr, "CONFIG_INVALID: oauth auth requires the HTTP transport")
+			return exitConfigInvalid
+		}
+		if err := cfg.ValidateOAuth(); err != nil {
+			writef(a.stderr, "CONFIG_INVALID: %v\n", err)
+			return exitConfigInvalid
+		}
+	}
 
 	if err := ensureRootAccessible(cfg.RootDir); err != nil {
 		writef(a.stderr, "root inaccessible: %v\n", err)
@@ -477,6 +583,15 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 		writef(a.stderr, "create payments dir: %v\n", err)
 		return exitRootInaccessible
 	}
+	dirPrompts, err := config.LoadPromptDir(filepath.Join(cfg.StateDir, "prompts"))
+	if err == nil {
+		cfg.Prompts = append(append([]config.PromptTemplate(nil), cfg.Prompts...), dirPrompts...)
+		err = config.ValidatePrompts(cfg.Prompts)
+	}
+	if err != nil {
+		writef(a.stderr, "CONFIG_INVALID: %v\n", err)
+		return exitConfigInvalid
+	}
 
 	nonInteractiveMode := opts.nonInteractive || !isTerminal(os.Stdin) || !isTerminal(os.Stdout)
 	if strings.TrimSpace(cfg.MistralAPIKey) == "" {
@@ -491,10 +606,15 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 		return exitConfigInvalid
 	}
 
-	auth, err := prepareAuthMaterial(cfg)
-	if err != nil {
-		writef(a.stderr, "auth setup: %v\n", err)
-		return exitConfigInvalid
+	// the stdio peer is the process that started us, so there is no token
+	// to issue or check.
+	auth := authMaterial{mode: "none", tokenSource: "none"}
+	if !stdio {
+		auth, err = prepareAuthMaterial(cfg)
+		if err != nil {
+			writef(a.stderr, "auth setup: %v\n", err)
+			return exitConfigInvalid
+		}
 	}
 	cfg.AuthMode = auth.mode
 	cfg.ResolvedAuthToken = auth.token
@@ -531,7 +651,16 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 		return exitIndexLoadFailure
 	}
 
+	var serverMetrics *metrics.Metrics
+	if metricsEnabled {
+		serverMetrics = metrics.New()
+		textIx.Metrics = &index.HNSWIndexMetrics{}
+		codeIx.Metrics = &index.HNSWIndexMetrics{}
+		a.providerObserver = serverMetrics
+	}
+
 	client := mistral.NewClient(cfg.MistralBaseURL, cfg.MistralAPIKey)
+	client.Observer = a.providerObserver
 	if strings.TrimSpace(cfg.ChatModel) != "" {
 		client.DefaultChatModel = strings.TrimSpace(cfg.ChatModel)
 	}
@@ -540,11 +669,26 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 	ret.SetRootDir(cfg.RootDir)
 	ret.SetStateDir(cfg.StateDir)
 	ret.SetProtocolVersion(cfg.ProtocolVersion)
+	ret.SetChatModel(client.DefaultChatModel)
+	ret.SetContextTokenBudgets(cfg.RAGContextTokens)
+	ret.SetNeighborChunks(cfg.RAGNeighborChunks)
+	ret.SetGeneratorVerification(cfg.RAGVerifyWithGenerator)
+	ret.SetReranker(retrieval.RerankerLLM, retrieval.NewLLMReranker(client))
+	ret.SetPathExcludes(cfg.PathExcludes)
+	if err := ret.SetSecretPatterns(cfg.SecretPatterns); err != nil {
+		writef(a.stderr, "invalid secret pattern: %v\n", err)
+		return exitConfigInvalid
+	}
 
 	// events are emitted to stdout only after we create the emitter; moving
 	// creation before the preload call lets us report failures from that
 	// bootstrap step as structured events (see SPEC.md for NDJSON schema).
-	emitter := newNDJSONEmitter(a.stdout, opts.jsonOutput)
+	// stdout carries the protocol under stdio, so events go to stderr.
+	eventOut := a.stdout
+	if stdio {
+		eventOut = a.stderr
+	}
+	emitter := newNDJSONEmitter(eventOut, opts.jsonOutput)
 
 	preloadedChunks := 0
 	if metadataStore, ok := st.(embeddedChunkLister); ok {
@@ -576,29 +720,94 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 		if strings.TrimSpace(cfg.ElevenLabsBaseURL) != "" {
 			ttsClient.BaseURL = strings.TrimRight(strings.TrimSpace(cfg.ElevenLabsBaseURL), "/")
 		}
+		ttsClient.Observer = a.providerObserver
 		serverOptions = append(serverOptions, mcp.WithTTS(ttsClient))
 	}
+	if cfg.AuditLog {
+		auditLog, err := audit.Open(filepath.Join(cfg.StateDir, audit.DirName), cfg.AuditLogMaxBytes)
+		if err != nil {
+			writef(a.stderr, "open audit log: %v\n", err)
+			return exitRootInaccessible
+		}
+		defer func() { _ = auditLog.Close() }()
+		serverOptions = append(serverOptions, mcp.WithAuditLog(auditLog))
+	}
+	if cfg.SessionStore == config.SessionStoreSQLite && !stdio {
+		sessionStore, err := sessions.Open(filepath.Join(cfg.StateDir, sessions.FileName))
+		if err != nil {
+			writef(a.stderr, "open session store: %v\n", err)
+			return exitRootInaccessible
+		}
+		defer func() { _ = sessionStore.Close() }()
+		serverOptions = append(serverOptions, mcp.WithSessionStore(sessionStore))
+	}
+	if serverMetrics != nil {
+		serverOptions = append(serverOptions, mcp.WithMetrics(serverMetrics))
+		registerRuntimeMetrics(serverMetrics, indexingState, st, map[string]*index.HNSWIndex{"text": textIx, "code": codeIx})
+	}
 
 	mcpServer := mcp.NewServer(cfg, ret, serverOptions...)
 	ing := a.newIngestor(cfg, st)
 	if stateAware, ok := ing.(indexingStateAware); ok {
 		stateAware.SetIndexingState(indexingState)
 	}
+	if changeAware, ok := ing.(documentChangeAware); ok {
+		changeAware.SetDocumentChangeHandler(mcpServer.NotifyDocumentChange)
+	}
 
 	emitter.Emit("info", "index_loaded", map[string]interface{}{
 		"state_dir": cfg.StateDir,
 	})
 
-	ln, err := net.Listen("tcp", cfg.ListenAddr)
-	if err != nil {
-		writef(a.stderr, "bind server: %v\n", err)
-		return exitServerBindFailure
+	var ln net.Listener
+	if !stdio {
+		ln, err = net.Listen("tcp", cfg.ListenAddr)
+		if err != nil {
+			writef(a.stderr, "bind server: %v\n", err)
+			return exitServerBindFailure
+		}
+		defer func() {
+			_ = ln.Close()
+		}()
+	}
+	var metricsLn net.Listener
+	var metricsToken, metricsTokenFile string
+	if serverMetrics != nil {
+		metricsToken, metricsTokenFile, err = prepareMetricsToken(cfg)
+		if err != nil {
+			writef(a.stderr, "CONFIG_INVALID: metrics auth: %v\n", err)
+			return exitConfigInvalid
+		}
+		metricsLn, err = net.Listen("tcp", cfg.MetricsListenAddr)
+		if err != nil {
+			writef(a.stderr, "bind metrics server: %v\n", err)
+			return exitServerBindFailure
+		}
+		defer func() {
+			_ = metricsLn.Close()
+		}()
 	}
 	runCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	defer func() {
-		_ = ln.Close()
-	}()
+	if metricsLn != nil {
+		go func() {
+			if err := serveMetrics(runCtx, metricsLn, serverMetrics, metricsToken); err != nil {
+				writef(a.stderr, "metrics server: %v\n", err)
+			}
+		}()
+		metricsURL := "http://" + metricsLn.Addr().String() + metricsPath
+		metricsFields := map[string]interface{}{
+			"url":  metricsURL,
+			"auth": metricsToken != "",
+		}
+		if metricsTokenFile != "" {
+			metricsFields["token_file"] = metricsTokenFile
+		}
+		emitter.Emit("info", "metrics_started", metricsFields)
+		if !opts.jsonOutput {
+			writef(a.stderr, "metrics: %s\n", metricsURL)
+		}
+	}
 	persistence := index.NewPersistenceManager(
 		[]index.IndexedFile{
 			{Path: textIndexPath, Index: textIx},
@@ -629,33 +838,58 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 			} else {
 				embedLogger = log.New(a.stderr, "", log.LstdFlags)
 			}
-			startEmbeddingWorkers(runCtx, chunkSource, textIx, codeIx, client, ret, indexingState, embedErrCh, embedLogger, cfg.EmbedModelText, cfg.EmbedModelCode)
+			textTarget := index.NewShadowIndex(textIx)
+			codeTarget := index.NewShadowIndex(codeIx)
+			if settingsStore, ok := st.(index.ModelSettingsStore); ok {
+				a.reconcileEmbeddingModels(runCtx, settingsStore, chunkSource, emitter, embedErrCh, map[string]reembedTarget{
+					"text": {index: textTarget, model: cfg.EmbedModelText},
+					"code": {index: codeTarget, model: cfg.EmbedModelCode},
+				})
+			}
+			embedCache := index.NewFileEmbeddingCache(filepath.Join(cfg.StateDir, "cache", "embeddings"), cfg.EmbedCacheMaxBytes)
+			embedCache.Logger = embedLogger
+			startEmbeddingWorkers(runCtx, chunkSource, textTarget, codeTarget, client, embedCache, ret, indexingState, embedErrCh, embedLogger, cfg.EmbedModelText, cfg.EmbedModelCode)
 		}
 	}
-	mcpAddr := ln.Addr().String()
-	if cfg.Public {
-		mcpAddr = publicURLAddress(cfg.ListenAddr, mcpAddr)
-	}
-	mcpURL := buildMCPURL(mcpAddr, cfg.MCPPath)
-
 	serverErrCh := make(chan error, 1)
-	go func() {
-		serverErrCh <- mcpServer.RunOnListener(runCtx, ln)
-	}()
+	if stdio {
+		go func() {
+			serverErrCh <- mcpServer.ServeStdio(runCtx, a.stdin, a.stdout)
+		}()
+		emitter.Emit("info", "server_started", map[string]interface{}{
+			"transport": transportStdio,
+		})
+		if !opts.jsonOutput {
+			writeln(a.stderr, "dir2mcp serving MCP over stdio")
+		}
+	} else {
+		mcpAddr := ln.Addr().String()
+		if cfg.Public {
+			mcpAddr = publicURLAddress(cfg.ListenAddr, mcpAddr)
+		}
+		mcpURL := buildMCPURL(mcpAddr, cfg.MCPPath)
 
-	emitter.Emit("info", "server_started", map[string]interface{}{
-		"url":         mcpURL,
-		"listen_addr": ln.Addr().String(),
-		"public":      cfg.Public,
-	})
+		go func() {
+			serverErrCh <- mcpServer.RunOnListener(runCtx, ln)
+		}()
 
-	connection := buildConnectionPayload(cfg, mcpURL, auth)
-	if err := writeConnectionFile(filepath.Join(cfg.StateDir, connectionFileName), connection); err != nil {
-		writef(a.stderr, "write %s: %v\n", connectionFileName, err)
-		return exitGeneric
-	}
+		emitter.Emit("info", "server_started", map[string]interface{}{
+			"url":         mcpURL,
+			"listen_addr": ln.Addr().String(),
+			"public":      cfg.Public,
+		})
+
+		connection := buildConnectionPayload(cfg, mcpURL, auth)
+		if err := writeConnectionFile(filepath.Join(cfg.StateDir, connectionFileName), connection); err != nil {
+			writef(a.stderr, "write %s: %v\n", connectionFileName, err)
+			return exitGeneric
+		}
 
-	emitter.Emit("info", "connection", connection)
+		emitter.Emit("info", "connection", connection)
+		if !opts.jsonOutput {
+			a.printHumanConnection(cfg, connection, auth, opts.readOnly)
+		}
+	}
 	emitter.Emit("info", "scan_progress", map[string]interface{}{
 		"scanned": 0,
 		"indexed": 0,
@@ -671,10 +905,6 @@ func (a *App) runUp(ctx context.Context, opts upOptions) int {
 		"errors":   0,
 	})
 
-	if !opts.jsonOutput {
-		a.printHumanConnection(cfg, connection, auth, opts.readOnly)
-	}
-
 	ingestErrCh := make(chan error, 1)
 	go runCorpusWriter(runCtx, cfg.StateDir, st, indexingState, a.stderr, emitter)
 
@@ -777,12 +1007,68 @@ func preloadEmbeddedChunkMetadata(ctx context.Context, source embeddedChunkListe
 	return total, nil
 }
 
+type reembedTarget struct {
+	index *index.ShadowIndex
+	model string
+}
+
+// reconcileEmbeddingModels checks each index against its configured
+// embedding model. Indices built with a different model are rebuilt into a
+// shadow copy by the embedding workers while the original keeps serving
+// queries; a watcher swaps the rebuilt copy in once no chunks are pending.
+func (a *App) reconcileEmbeddingModels(ctx context.Context, st index.ModelSettingsStore, source index.ChunkSource, emitter *ndjsonEmitter, errCh chan<- error, targets map[string]reembedTarget) {
+	for _, kind := range []string{"text", "code"} {
+		target, ok := targets[kind]
+		if !ok || target.index == nil {
+			continue
+		}
+		rebuilding, err := index.ReconcileEmbeddingModel(ctx, st, kind, target.index, target.model)
+		if err != nil {
+			writef(a.stderr, "reconcile %s embedding model: %v\n", kind, err)
+			emitter.Emit("warning", "reembed_check_failed", map[string]interface{}{
+				"index":   kind,
+				"message": err.Error(),
+			})
+			continue
+		}
+		if !rebuilding {
+			continue
+		}
+		// until the swap the live index keeps the model it was built with.
+		emitter.Emit("info", "reembed_started", map[string]interface{}{
+			"index":      kind,
+			"from_model": target.index.Live().EmbeddingModel(),
+			"to_model":   target.model,
+		})
+
+		watchKind := kind
+		watchTarget := target
+		go func() {
+			err := index.CompleteRebuildWhenDrained(ctx, st, source, watchKind, watchTarget.index, time.Second)
+			if err != nil {
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return
+				}
+				errCh <- fmt.Errorf("%s reembed: %w", watchKind, err)
+				return
+			}
+			header := watchTarget.index.Live().Header()
+			emitter.Emit("info", "reembed_completed", map[string]interface{}{
+				"index": watchKind,
+				"model": header.Model,
+				"dim":   header.Dim,
+			})
+		}()
+	}
+}
+
 func startEmbeddingWorkers(
 	ctx context.Context,
 	st index.ChunkSource,
 	textIndex model.Index,
 	codeIndex model.Index,
 	embedder model.Embedder,
+	cache index.EmbeddingCache,
 	ret *retrieval.Service,
 	indexingState *appstate.IndexingState,
 	errCh chan<- error,
@@ -802,6 +1088,7 @@ func startEmbeddingWorkers(
 			Source:       st,
 			Index:        ix,
 			Embedder:     embedder,
+			Cache:        cache,
 			ModelForText: textModel,
 			ModelForCode: codeModel,
 			BatchSize:    32,
@@ -837,8 +1124,9 @@ func startEmbeddingWorkers(
 }
 
 func (a *App) runStatus(ctx context.Context, global globalOptions, args []string) int {
-	if len(args) > 0 {
-		writef(a.stderr, "status command does not accept arguments: %s\n", strings.Join(args, " "))
+	opts, err := parseStatusOptions(args)
+	if err != nil {
+		writef(a.stderr, "invalid status flags: %v\n", err)
 		return exitGeneric
 	}
 
@@ -850,12 +1138,16 @@ func (a *App) runStatus(ctx context.Context, global globalOptions, args []string
 	if strings.TrimSpace(cfg.StateDir) == "" {
 		cfg.StateDir = filepath.Join(".", ".dir2mcp")
 	}
+	metaPath := filepath.Join(cfg.StateDir, "meta.sqlite")
+
+	if opts.dryRun {
+		return a.printPendingMigrations(ctx, global, cfg.StateDir, metaPath)
+	}
 
 	snapshotPath := filepath.Join(cfg.StateDir, "corpus.json")
 	snapshot, err := readCorpusSnapshot(snapshotPath)
 	source := "corpus_json"
 	if err != nil {
-		metaPath := filepath.Join(cfg.StateDir, "meta.sqlite")
 		if _, statErr := os.Stat(metaPath); statErr != nil {
 			if errors.Is(statErr, os.ErrNotExist) {
 				writeln(a.stderr, "no state found in .dir2mcp; run: dir2mcp up")
@@ -880,12 +1172,24 @@ func (a *App) runStatus(ctx context.Context, global globalOptions, args []string
 		source = "computed"
 	}
 
+	// the snapshot may come from corpus.json alone, so schema details are
+	// best-effort unless the database is too new to be served safely.
+	schema, schemaErr := store.InspectSchema(ctx, metaPath)
+	if errors.Is(schemaErr, store.ErrSchemaTooNew) {
+		writef(a.stderr, "inspect schema: %v\n", schemaErr)
+		return exitIndexLoadFailure
+	}
+	haveSchema := schemaErr == nil
+
 	if global.jsonOutput {
 		payload := map[string]interface{}{
 			"source":    source,
 			"state_dir": cfg.StateDir,
 			"snapshot":  snapshot,
 		}
+		if haveSchema {
+			payload["schema"] = schema
+		}
 		if err := emitJSON(a.stdout, payload); err != nil {
 			writef(a.stderr, "encode status json: %v\n", err)
 			return exitGeneric
@@ -896,6 +1200,9 @@ func (a *App) runStatus(ctx context.Context, global globalOptions, args []string
 	writeln(a.stdout, "State:", cfg.StateDir)
 	writef(a.stdout, "Source: %s\n", source)
 	writef(a.stdout, "Timestamp: %s\n", snapshot.Timestamp)
+	if haveSchema {
+		writef(a.stdout, "Schema: version=%d supported=%d pending=%d\n", schema.Version, schema.Supported, len(schema.Pending))
+	}
 	writeln(a.stdout)
 	writeln(a.stdout, "Indexing:")
 	writef(a.stdout, "  mode=%s running=%t scanned=%d indexed=%d skipped=%d deleted=%d reps=%d chunks=%d embedded=%d errors=%d unknown=%d\n",
@@ -926,6 +1233,45 @@ func (a *App) runStatus(ctx context.Context, global globalOptions, args []string
 	return exitSuccess
 }
 
+// printPendingMigrations lists the schema migrations that the next store open
+// would apply, without touching the database.
+func (a *App) printPendingMigrations(ctx context.Context, global globalOptions, stateDir, metaPath string) int {
+	schema, err := store.InspectSchema(ctx, metaPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeln(a.stderr, "no state found in .dir2mcp; run: dir2mcp up")
+			return exitGeneric
+		}
+		writef(a.stderr, "inspect schema: %v\n", err)
+		return exitIndexLoadFailure
+	}
+
+	if global.jsonOutput {
+		payload := map[string]interface{}{
+			"state_dir": stateDir,
+			"dry_run":   true,
+			"schema":    schema,
+		}
+		if err := emitJSON(a.stdout, payload); err != nil {
+			writef(a.stderr, "encode status json: %v\n", err)
+			return exitGeneric
+		}
+		return exitSuccess
+	}
+
+	writeln(a.stdout, "State:", stateDir)
+	writef(a.stdout, "Schema: version=%d supported=%d\n", schema.Version, schema.Supported)
+	if len(schema.Pending) == 0 {
+		writeln(a.stdout, "No pending migrations.")
+		return exitSuccess
+	}
+	writeln(a.stdout, "Pending migrations:")
+	for _, m := range schema.Pending {
+		writef(a.stdout, "  %d %s\n", m.Version, m.Name)
+	}
+	return exitSuccess
+}
+
 func (a *App) runAsk(ctx context.Context, global globalOptions, args []string) int {
 	opts, err := parseAskOptions(args)
 	if err != nil {
@@ -972,20 +1318,31 @@ func (a *App) runAsk(ctx context.Context, global globalOptions, args []string) i
 	}
 
 	query := model.SearchQuery{
-		Query:      opts.question,
-		K:          opts.k,
-		Index:      opts.index,
-		PathPrefix: opts.pathPrefix,
-		FileGlob:   opts.fileGlob,
-		DocTypes:   opts.docTypes,
+		Query:          opts.question,
+		K:              opts.k,
+		Index:          opts.index,
+		PathPrefix:     opts.pathPrefix,
+		FileGlob:       opts.fileGlob,
+		DocTypes:       opts.docTypes,
+		Rerank:         opts.rerank,
+		MMRLambda:      opts.mmrLambda,
+		MaxHitsPerFile: opts.maxPerFile,
+		MetadataFilter: opts.filter,
 	}
 
 	if opts.mode == "search_only" {
-		hits, searchErr := retriever.Search(ctx, query)
+		var searchResult model.SearchResult
+		var searchErr error
+		if withMeta, ok := retriever.(searchWithMetadataRetriever); ok {
+			searchResult, searchErr = withMeta.SearchWithMetadata(ctx, query)
+		} else {
+			searchResult.Hits, searchErr = retriever.Search(ctx, query)
+		}
 		if searchErr != nil {
 			writef(a.stderr, "ask failed: %v\n", searchErr)
 			return exitGeneric
 		}
+		hits := searchResult.Hits
 		if global.jsonOutput {
 			// JSON output now uses a dedicated accessor instead of running Ask
 			// again.  this avoids the extra search/generation work while still
@@ -1002,6 +1359,9 @@ func (a *App) runAsk(ctx context.Context, global globalOptions, args []string) i
 				"hits":              serializeHits(hits),
 				"indexing_complete": indexingComplete,
 			}
+			if searchResult.Rerank != nil {
+				payload["rerank"] = serializeRerankInfo(*searchResult.Rerank)
+			}
 			if err := emitJSON(a.stdout, payload); err != nil {
 				writef(a.stderr, "encode ask json: %v\n", err)
 				return exitGeneric
@@ -1026,6 +1386,11 @@ func (a *App) runAsk(ctx context.Context, global globalOptions, args []string) i
 		writef(a.stderr, "ask failed: %v\n", askErr)
 		return exitGeneric
 	}
+	if askResult.Verification != nil {
+		var verification model.AnswerVerification
+		askResult.Answer, verification = askResult.Verification.Apply(askResult.Answer, opts.strict)
+		askResult.Verification = &verification
+	}
 
 	if global.jsonOutput {
 		payload := map[string]interface{}{
@@ -1035,6 +1400,15 @@ func (a *App) runAsk(ctx context.Context, global globalOptions, args []string) i
 			"hits":              serializeHits(askResult.Hits),
 			"indexing_complete": askResult.IndexingComplete,
 		}
+		if askResult.Rerank != nil {
+			payload["rerank"] = serializeRerankInfo(*askResult.Rerank)
+		}
+		if askResult.Verification != nil {
+			payload["verification"] = serializeVerification(*askResult.Verification)
+		}
+		if opts.debug && askResult.Context != nil {
+			payload["context"] = serializeAskContext(*askResult.Context)
+		}
 		if err := emitJSON(a.stdout, payload); err != nil {
 			writef(a.stderr, "encode ask json: %v\n", err)
 			return exitGeneric
@@ -1050,6 +1424,199 @@ func (a *App) runAsk(ctx context.Context, global globalOptions, args []string) i
 			writef(a.stdout, "- chunk=%d path=%s span=%s\n", citation.ChunkID, citation.RelPath, formatSpan(citation.Span))
 		}
 	}
+	if v := askResult.Verification; v != nil {
+		writeln(a.stdout)
+		writef(a.stdout, "Grounding: score=%.2f sentences=%d unsupported=%d\n", v.GroundingScore, len(v.Sentences), v.Unsupported())
+	}
+	if opts.debug {
+		writeAskContext(a.stdout, askResult.Context)
+	}
+	return exitSuccess
+}
+
+// writeAskContext prints the prompt assembled for the generator so operators
+// can audit what the model saw.
+func writeAskContext(out io.Writer, c *model.AskContext) {
+	writeln(out)
+	if c == nil {
+		writeln(out, "Context: (generation not attempted)")
+		return
+	}
+	writef(out, "Context: model=%s budget=%d used=%d blocks=%d dropped_hits=%d truncated=%t\n",
+		c.ChatModel, c.TokenBudget, c.TokensUsed, len(c.Blocks), c.Dropped, c.Truncated)
+	writeln(out, "--- system ---")
+	writeln(out, c.SystemPrompt)
+	writeln(out, "--- user ---")
+	writeln(out, c.UserPrompt)
+}
+
+// searchWithMetadataRetriever is implemented by retrievers that report
+// rerank metadata alongside search hits.
+type searchWithMetadataRetriever interface {
+	SearchWithMetadata(ctx context.Context, query model.SearchQuery) (model.SearchResult, error)
+}
+
+func serializeRerankInfo(info model.RerankInfo) map[string]interface{} {
+	out := map[string]interface{}{
+		"reranker":   info.Reranker,
+		"candidates": info.Candidates,
+		"latency_ms": info.LatencyMS,
+	}
+	if info.Error != "" {
+		out["error"] = info.Error
+	}
+	return out
+}
+
+func serializeVerification(v model.AnswerVerification) map[string]interface{} {
+	sentences := make([]map[string]interface{}, 0, len(v.Sentences))
+	for _, sentence := range v.Sentences {
+		entry := map[string]interface{}{
+			"text":    sentence.Text,
+			"start":   sentence.Start,
+			"end":     sentence.End,
+			"sources": append([]string{}, sentence.Sources...),
+			"status":  sentence.Status,
+			"score":   sentence.Score,
+			"method":  sentence.Method,
+		}
+		if sentence.Removed {
+			entry["removed"] = true
+		}
+		sentences = append(sentences, entry)
+	}
+	strict := v.Strict
+	if strict == "" {
+		strict = model.StrictOff
+	}
+	return map[string]interface{}{
+		"grounding_score": v.GroundingScore,
+		"unsupported":     v.Unsupported(),
+		"strict":          strict,
+		"sentences":       sentences,
+	}
+}
+
+func serializeAskContext(c model.AskContext) map[string]interface{} {
+	blocks := make([]map[string]interface{}, 0, len(c.Blocks))
+	for _, block := range c.Blocks {
+		blocks = append(blocks, map[string]interface{}{
+			"rel_path":  block.RelPath,
+			"chunk_ids": append([]uint64{}, block.ChunkIDs...),
+			"span":      serializeSpan(block.Span),
+			"tokens":    block.Tokens,
+			"text":      block.Text,
+		})
+	}
+	return map[string]interface{}{
+		"chat_model":    c.ChatModel,
+		"token_budget":  c.TokenBudget,
+		"tokens_used":   c.TokensUsed,
+		"system_prompt": c.SystemPrompt,
+		"user_prompt":   c.UserPrompt,
+		"blocks":        blocks,
+		"dropped_hits":  c.Dropped,
+		"truncated":     c.Truncated,
+	}
+}
+
+// retrieverGrep is implemented by retrievers that can pattern-match the raw
+// text of indexed files.
+type retrieverGrep interface {
+	Grep(ctx context.Context, query model.GrepQuery, emit func(model.GrepMatch)) (model.GrepResult, error)
+}
+
+func (a *App) runGrep(ctx context.Context, global globalOptions, args []string) int {
+	opts, err := parseGrepOptions(args)
+	if err != nil {
+		writef(a.stderr, "invalid grep flags: %v\n", err)
+		return exitGeneric
+	}
+
+	cfg, err := config.Load(".dir2mcp.yaml")
+	if err != nil {
+		writef(a.stderr, "load config: %v\n", err)
+		return exitConfigInvalid
+	}
+	if strings.TrimSpace(cfg.StateDir) == "" {
+		cfg.StateDir = filepath.Join(".", ".dir2mcp")
+	}
+
+	st := a.storeForConfig(cfg)
+	defer func() { _ = st.Close() }()
+	if err := st.Init(ctx); err != nil && !errors.Is(err, model.ErrNotImplemented) {
+		writef(a.stderr, "initialize metadata store: %v\n", err)
+		return exitIndexLoadFailure
+	}
+
+	retriever, err := a.buildRetrieverForGrep(cfg, st)
+	if err != nil {
+		writef(a.stderr, "initialize retriever: %v\n", err)
+		return exitConfigInvalid
+	}
+	grepper, ok := retriever.(retrieverGrep)
+	if !ok {
+		writeln(a.stderr, "grep failed: retriever does not support grep")
+		return exitGeneric
+	}
+
+	grepCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	// matches are written as they are found so large scans show progress;
+	// with --json each match is one NDJSON event.
+	emitter := newNDJSONEmitter(a.stdout, global.jsonOutput)
+	printed := 0
+	result, err := grepper.Grep(grepCtx, opts.query, func(match model.GrepMatch) {
+		if global.jsonOutput {
+			emitter.Emit("info", "grep_match", map[string]interface{}{
+				"rel_path": match.RelPath,
+				"span":     serializeSpan(match.Span),
+				"line":     match.Line,
+				"before":   append([]string{}, match.Before...),
+				"after":    append([]string{}, match.After...),
+			})
+			return
+		}
+		if opts.query.ContextLines > 0 && printed > 0 {
+			writeln(a.stdout, "--")
+		}
+		start := match.Span.StartLine - len(match.Before)
+		for i, line := range match.Before {
+			writef(a.stdout, "%s-%d-%s\n", match.RelPath, start+i, line)
+		}
+		writef(a.stdout, "%s:%d:%s\n", match.RelPath, match.Span.StartLine, match.Line)
+		for i, line := range match.After {
+			writef(a.stdout, "%s-%d-%s\n", match.RelPath, match.Span.StartLine+1+i, line)
+		}
+		printed++
+	})
+	if err != nil {
+		if errors.Is(err, model.ErrNotImplemented) {
+			writeln(a.stderr, "grep failed: store does not support grep")
+		} else {
+			writef(a.stderr, "grep failed: %v\n", err)
+		}
+		return exitGeneric
+	}
+
+	if global.jsonOutput {
+		emitter.Emit("info", "grep_summary", map[string]interface{}{
+			"pattern":       opts.query.Pattern,
+			"matches":       len(result.Matches),
+			"files_scanned": result.FilesScanned,
+			"files_skipped": result.FilesSkipped,
+			"truncated":     result.Truncated,
+			"timed_out":     result.TimedOut,
+		})
+		return exitSuccess
+	}
+	if result.Truncated {
+		writef(a.stderr, "stopped after %d match(es); raise --max-matches to see more\n", len(result.Matches))
+	}
+	if result.TimedOut {
+		writef(a.stderr, "timed out after %s; results are partial\n", opts.timeout)
+	}
 	return exitSuccess
 }
 
@@ -1147,6 +1714,94 @@ func (a *App) runConfig(ctx context.Context, global globalOptions, args []string
 	return exitSuccess
 }
 
+func (a *App) runSnapshot(ctx context.Context, global globalOptions, args []string) int {
+	if len(args) == 0 {
+		writeln(a.stdout, "snapshot command: supported subcommands are create and restore")
+		return exitSuccess
+	}
+
+	cfg, err := config.Load(".dir2mcp.yaml")
+	if err != nil {
+		writef(a.stderr, "load config: %v\n", err)
+		return exitConfigInvalid
+	}
+	if strings.TrimSpace(cfg.StateDir) == "" {
+		cfg.StateDir = filepath.Join(".", ".dir2mcp")
+	}
+
+	var (
+		manifest snapshot.Manifest
+		archive  string
+		action   string
+	)
+	switch args[0] {
+	case "create":
+		opts, err := parseSnapshotCreateOptions(args[1:])
+		if err != nil {
+			writef(a.stderr, "invalid snapshot create flags: %v\n", err)
+			return exitGeneric
+		}
+		archive = opts.output
+		action = "created"
+		manifest, err = snapshot.Create(ctx, cfg.StateDir, archive)
+		if err != nil {
+			writef(a.stderr, "snapshot create: %v\n", err)
+			if errors.Is(err, os.ErrNotExist) || errors.Is(err, store.ErrSchemaTooNew) {
+				return exitIndexLoadFailure
+			}
+			return exitGeneric
+		}
+	case "restore":
+		opts, err := parseSnapshotRestoreOptions(args[1:])
+		if err != nil {
+			writef(a.stderr, "invalid snapshot restore flags: %v\n", err)
+			return exitGeneric
+		}
+		archive = opts.archive
+		action = "restored"
+		manifest, err = snapshot.Restore(ctx, archive, cfg.StateDir, opts.force)
+		if err != nil {
+			writef(a.stderr, "snapshot restore: %v\n", err)
+			if errors.Is(err, snapshot.ErrChecksumMismatch) || errors.Is(err, snapshot.ErrInvalidArchive) || errors.Is(err, store.ErrSchemaTooNew) {
+				return exitIndexLoadFailure
+			}
+			return exitGeneric
+		}
+	default:
+		writef(a.stderr, "unknown snapshot subcommand: %s\n", args[0])
+		return exitGeneric
+	}
+
+	if global.jsonOutput {
+		if err := emitJSON(a.stdout, map[string]interface{}{
+			"action":    action,
+			"archive":   archive,
+			"state_dir": cfg.StateDir,
+			"manifest":  manifest,
+		}); err != nil {
+			writef(a.stderr, "encode snapshot output: %v\n", err)
+			return exitGeneric
+		}
+		return exitSuccess
+	}
+	writef(a.stdout, "Snapshot %s: %s\n", action, archive)
+	writef(a.stdout, "State dir: %s\n", cfg.StateDir)
+	writef(a.stdout, "Documents: %d files=%d schema_version=%d\n", manifest.Documents, len(manifest.Files), manifest.SchemaVersion)
+	writef(a.stdout, "Root fingerprint: %s\n", manifest.RootFingerprint)
+	for _, kind := range []string{"text", "code"} {
+		if m, ok := manifest.Models[kind]; ok && m.Model != "" {
+			writef(a.stdout, "Embed model (%s): %s dim=%d\n", kind, m.Model, m.Dim)
+		}
+	}
+	if manifest.PendingChunks > 0 {
+		writef(a.stdout, "Chunks without a vector: %d (dir2mcp up without --read-only embeds them)\n", manifest.PendingChunks)
+	}
+	if action == "restored" {
+		writeln(a.stdout, "Serve it with: dir2mcp up --read-only")
+	}
+	return exitSuccess
+}
+
 func (a *App) runConfigInit(global globalOptions, args []string) int {
 	if len(args) > 0 {
 		writef(a.stderr, "config init does not accept arguments: %s\n", strings.Join(args, " "))
@@ -1209,6 +1864,23 @@ func (a *App) runConfigInit(global globalOptions, args []string) int {
 	return exitSuccess
 }
 
+// buildRetrieverForGrep returns a retriever for the grep command. Grep only
+// reads the store and files on disk, so no indices or API client are loaded.
+func (a *App) buildRetrieverForGrep(cfg config.Config, st model.Store) (model.Retriever, error) {
+	if a != nil && a.newRetriever != nil {
+		return a.newRetriever(cfg, st), nil
+	}
+
+	ret := retrieval.NewService(st, nil, nil, nil)
+	ret.SetRootDir(cfg.RootDir)
+	ret.SetStateDir(cfg.StateDir)
+	ret.SetPathExcludes(cfg.PathExcludes)
+	if err := ret.SetSecretPatterns(cfg.SecretPatterns); err != nil {
+		return nil, fmt.Errorf("invalid secret pattern: %w", err)
+	}
+	return ret, nil
+}
+
 func (a *App) buildRetrieverForAsk(ctx context.Context, cfg config.Config, st model.Store) (model.Retriever, func(), error) {
 	if a != nil && a.newRetriever != nil {
 		return a.newRetriever(cfg, st), nil, nil
@@ -1235,11 +1907,25 @@ func (a *App) buildRetrieverForAsk(ctx context.Context, cfg config.Config, st mo
 	}
 
 	client := mistral.NewClient(cfg.MistralBaseURL, cfg.MistralAPIKey)
+	if strings.TrimSpace(cfg.ChatModel) != "" {
+		client.DefaultChatModel = strings.TrimSpace(cfg.ChatModel)
+	}
 	ret := retrieval.NewService(st, textIx, client, client)
 	ret.SetCodeIndex(codeIx)
 	ret.SetRootDir(cfg.RootDir)
 	ret.SetStateDir(cfg.StateDir)
 	ret.SetProtocolVersion(cfg.ProtocolVersion)
+	ret.SetChatModel(client.DefaultChatModel)
+	ret.SetContextTokenBudgets(cfg.RAGContextTokens)
+	ret.SetNeighborChunks(cfg.RAGNeighborChunks)
+	ret.SetGeneratorVerification(cfg.RAGVerifyWithGenerator)
+	ret.SetReranker(retrieval.RerankerLLM, retrieval.NewLLMReranker(client))
+	ret.SetPathExcludes(cfg.PathExcludes)
+	if err := ret.SetSecretPatterns(cfg.SecretPatterns); err != nil {
+		_ = textIx.Close()
+		_ = codeIx.Close()
+		return nil, nil, fmt.Errorf("invalid secret pattern: %w", err)
+	}
 
 	if metadataStore, ok := st.(embeddedChunkLister); ok {
 		if _, err := preloadEmbeddedChunkMetadata(ctx, metadataStore, ret); err != nil && !errors.Is(err, model.ErrNotImplemented) {
@@ -1256,13 +1942,112 @@ func (a *App) buildRetrieverForAsk(ctx context.Context, cfg config.Config, st mo
 	return ret, cleanup, nil
 }
 
+func parseStatusOptions(args []string) (statusOptions, error) {
+	opts := statusOptions{}
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "list pending schema migrations without applying them")
+	if err := fs.Parse(args); err != nil {
+		return statusOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		return statusOptions{}, fmt.Errorf("status command does not accept arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return opts, nil
+}
+
+func parseSnapshotCreateOptions(args []string) (snapshotCreateOptions, error) {
+	opts := snapshotCreateOptions{}
+	fs := flag.NewFlagSet("snapshot create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.output, "output", "", "archive path (default dir2mcp-snapshot-<timestamp>.tar.gz)")
+	if err := fs.Parse(args); err != nil {
+		return snapshotCreateOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		return snapshotCreateOptions{}, fmt.Errorf("snapshot create does not accept arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(opts.output) == "" {
+		opts.output = fmt.Sprintf("dir2mcp-snapshot-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	return opts, nil
+}
+
+func parseSnapshotRestoreOptions(args []string) (snapshotRestoreOptions, error) {
+	opts := snapshotRestoreOptions{}
+	fs := flag.NewFlagSet("snapshot restore", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.force, "force", false, "overwrite an existing index in the state directory")
+	if err := fs.Parse(args); err != nil {
+		return snapshotRestoreOptions{}, err
+	}
+	if fs.NArg() != 1 {
+		return snapshotRestoreOptions{}, errors.New("snapshot restore requires exactly one archive path")
+	}
+	opts.archive = fs.Arg(0)
+	return opts, nil
+}
+
+func parseGrepOptions(args []string) (grepOptions, error) {
+	opts := grepOptions{
+		query:   model.GrepQuery{MaxMatches: mcp.DefaultGrepMaxMatches},
+		timeout: mcp.GrepTimeout,
+	}
+
+	fs := flag.NewFlagSet("grep", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.query.Literal, "literal", false, "match the pattern as a literal string")
+	fs.BoolVar(&opts.query.IgnoreCase, "ignore-case", false, "match case-insensitively")
+	fs.BoolVar(&opts.query.IgnoreCase, "i", false, "shorthand for --ignore-case")
+	fs.StringVar(&opts.query.PathPrefix, "path-prefix", "", "optional path prefix filter")
+	fs.StringVar(&opts.query.FileGlob, "file-glob", "", "optional file glob filter")
+	fs.IntVar(&opts.query.ContextLines, "context", 0, fmt.Sprintf("lines of context around each match (max %d)", mcp.MaxGrepContextLines))
+	fs.IntVar(&opts.query.MaxMatches, "max-matches", opts.query.MaxMatches, fmt.Sprintf("stop after this many matches (max %d)", mcp.MaxGrepMatches))
+	fs.DurationVar(&opts.timeout, "timeout", opts.timeout, "stop scanning after this long and report partial results")
+	if err := fs.Parse(args); err != nil {
+		return grepOptions{}, err
+	}
+
+	if fs.NArg() != 1 {
+		return grepOptions{}, errors.New("grep command requires exactly one pattern argument")
+	}
+	opts.query.Pattern = fs.Arg(0)
+	if opts.query.Pattern == "" {
+		return grepOptions{}, errors.New("pattern must not be empty")
+	}
+	if len(opts.query.Pattern) > mcp.MaxGrepPatternLength {
+		return grepOptions{}, fmt.Errorf("pattern must be at most %d bytes", mcp.MaxGrepPatternLength)
+	}
+	if opts.query.ContextLines < 0 || opts.query.ContextLines > mcp.MaxGrepContextLines {
+		return grepOptions{}, fmt.Errorf("context must be between 0 and %d", mcp.MaxGrepContextLines)
+	}
+	if opts.query.MaxMatches < 1 || opts.query.MaxMatches > mcp.MaxGrepMatches {
+		return grepOptions{}, fmt.Errorf("max-matches must be between 1 and %d", mcp.MaxGrepMatches)
+	}
+	if opts.timeout <= 0 {
+		return grepOptions{}, errors.New("timeout must be > 0")
+	}
+	if _, err := opts.query.Regexp(); err != nil {
+		return grepOptions{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return opts, nil
+}
+
 func parseAskOptions(args []string) (askOptions, error) {
 	opts := askOptions{
 		k:     mcp.DefaultSearchK,
 		mode:  "answer",
 		index: "auto",
 	}
-	var rawDocTypes string
+	var (
+		rawDocTypes       string
+		rawModifiedAfter  string
+		rawModifiedBefore string
+		rawStatuses       string
+		rawSourceTypes    string
+		rawRepTypes       string
+		rawLanguages      string
+	)
 
 	fs := flag.NewFlagSet("ask", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
@@ -1273,10 +2058,23 @@ func parseAskOptions(args []string) (askOptions, error) {
 		fmt.Sprintf("number of results (<=0 defaults to %d, max %d)", mcp.DefaultSearchK, mcp.MaxSearchK),
 	)
 	fs.StringVar(&opts.mode, "mode", opts.mode, "answer|search_only")
-	fs.StringVar(&opts.index, "index", opts.index, "auto|text|code|both")
+	fs.StringVar(&opts.index, "index", opts.index, "auto|text|code|both|hybrid")
 	fs.StringVar(&opts.pathPrefix, "path-prefix", "", "optional path prefix filter")
 	fs.StringVar(&opts.fileGlob, "file-glob", "", "optional file glob filter")
 	fs.StringVar(&rawDocTypes, "doc-types", "", "comma-separated doc type filter")
+	fs.BoolVar(&opts.debug, "debug", false, "include the context assembled for the generator in output")
+	fs.StringVar(&opts.rerank, "rerank", "none", "none|lexical|llm second-stage reranker")
+	fs.Float64Var(&opts.mmrLambda, "mmr-lambda", 0, "diversify results with MMR (0 < lambda <= 1, lower is more diverse)")
+	fs.IntVar(&opts.maxPerFile, "max-hits-per-file", 0, "cap hits contributed by a single file (0 = unlimited)")
+	fs.StringVar(&opts.strict, "strict", model.StrictOff, "off|flag|strip sentences citation verification finds unsupported")
+	fs.StringVar(&rawModifiedAfter, "modified-after", "", "only files modified at or after this RFC 3339 time or YYYY-MM-DD date")
+	fs.StringVar(&rawModifiedBefore, "modified-before", "", "only files modified before this RFC 3339 time or YYYY-MM-DD date")
+	fs.Int64Var(&opts.filter.MinSizeBytes, "min-size", 0, "only files of at least this many bytes")
+	fs.Int64Var(&opts.filter.MaxSizeBytes, "max-size", 0, "only files of at most this many bytes (0 = unlimited)")
+	fs.StringVar(&rawStatuses, "statuses", "", "comma-separated document status filter (ok,skipped,error)")
+	fs.StringVar(&rawSourceTypes, "source-types", "", "comma-separated source type filter (filesystem,archive_member)")
+	fs.StringVar(&rawRepTypes, "rep-types", "", "comma-separated representation type filter (raw_text,ocr_markdown,transcript,annotation_text,annotation_json)")
+	fs.StringVar(&rawLanguages, "languages", "", "comma-separated code language filter (go,python,typescript,...)")
 	if err := fs.Parse(args); err != nil {
 		return askOptions{}, err
 	}
@@ -1301,24 +2099,119 @@ func parseAskOptions(args []string) (askOptions, error) {
 
 	opts.index = strings.ToLower(strings.TrimSpace(opts.index))
 	switch opts.index {
-	case "auto", "text", "code", "both":
+	case "auto", "text", "code", "both", "hybrid":
 	default:
-		return askOptions{}, errors.New("index must be one of auto,text,code,both")
+		return askOptions{}, errors.New("index must be one of auto,text,code,both,hybrid")
 	}
 
-	if trimmed := strings.TrimSpace(rawDocTypes); trimmed != "" {
-		parts := strings.Split(trimmed, ",")
-		opts.docTypes = make([]string, 0, len(parts))
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if part == "" {
-				continue
+	opts.rerank = strings.ToLower(strings.TrimSpace(opts.rerank))
+	switch opts.rerank {
+	case "none":
+		opts.rerank = ""
+	case "", "lexical", "llm":
+	default:
+		return askOptions{}, errors.New("rerank must be one of none,lexical,llm")
+	}
+	if opts.mmrLambda < 0 || opts.mmrLambda > 1 {
+		return askOptions{}, errors.New("mmr-lambda must be between 0 and 1")
+	}
+	if opts.maxPerFile < 0 {
+		return askOptions{}, errors.New("max-hits-per-file must be >= 0")
+	}
+	opts.strict = strings.ToLower(strings.TrimSpace(opts.strict))
+	switch opts.strict {
+	case model.StrictOff, model.StrictFlag, model.StrictStrip:
+	default:
+		return askOptions{}, errors.New("strict must be one of off,flag,strip")
+	}
+
+	opts.docTypes = splitCommaList(rawDocTypes)
+	if err := parseAskMetadataFilter(&opts.filter, rawModifiedAfter, rawModifiedBefore, rawStatuses, rawSourceTypes, rawRepTypes, rawLanguages); err != nil {
+		return askOptions{}, err
+	}
+
+	return opts, nil
+}
+
+// parseAskMetadataFilter validates the metadata filter flags of ask and
+// stores them in filter.
+func parseAskMetadataFilter(filter *model.MetadataFilter, modifiedAfter, modifiedBefore, statuses, sourceTypes, repTypes, languages string) error {
+	for _, bound := range []struct {
+		flag string
+		raw  string
+		dst  *int64
+	}{
+		{"modified-after", modifiedAfter, &filter.ModifiedAfter},
+		{"modified-before", modifiedBefore, &filter.ModifiedBefore},
+	} {
+		raw := strings.TrimSpace(bound.raw)
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			ts, err = time.Parse("2006-01-02", raw)
+		}
+		if err != nil {
+			return fmt.Errorf("%s must be an RFC 3339 time or YYYY-MM-DD date", bound.flag)
+		}
+		*bound.dst = ts.Unix()
+	}
+	if filter.ModifiedAfter > 0 && filter.ModifiedBefore > 0 && filter.ModifiedAfter >= filter.ModifiedBefore {
+		return errors.New("modified-after must be earlier than modified-before")
+	}
+	if filter.MinSizeBytes < 0 || filter.MaxSizeBytes < 0 {
+		return errors.New("min-size and max-size must be >= 0")
+	}
+	if filter.MaxSizeBytes > 0 && filter.MinSizeBytes > filter.MaxSizeBytes {
+		return errors.New("min-size must not exceed max-size")
+	}
+
+	for _, list := range []struct {
+		flag    string
+		raw     string
+		allowed []string
+		dst     *[]string
+	}{
+		{"statuses", statuses, []string{"ok", "skipped", "error"}, &filter.Statuses},
+		{"source-types", sourceTypes, []string{"filesystem", "archive_member"}, &filter.SourceTypes},
+		{"rep-types", repTypes, []string{ingest.RepTypeRawText, ingest.RepTypeOCRMarkdown, ingest.RepTypeTranscript, ingest.RepTypeAnnotationText, ingest.RepTypeAnnotationJSON}, &filter.RepTypes},
+	} {
+		for _, value := range splitCommaList(list.raw) {
+			value = strings.ToLower(value)
+			if !slices.Contains(list.allowed, value) {
+				return fmt.Errorf("%s entries must be one of %s", list.flag, strings.Join(list.allowed, ","))
 			}
-			opts.docTypes = append(opts.docTypes, part)
+			*list.dst = append(*list.dst, value)
+		}
+	}
+	for _, language := range splitCommaList(languages) {
+		canonical := ingest.NormalizeCodeLanguage(language)
+		if canonical == "" {
+			return fmt.Errorf("unknown language %q", language)
 		}
+		filter.Languages = append(filter.Languages, canonical)
 	}
+	return nil
+}
 
-	return opts, nil
+// splitCommaList splits a comma-separated flag value, dropping blank
+// entries. It returns nil for an empty value.
+func splitCommaList(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		out = append(out, part)
+	}
+	return out
 }
 
 func readCorpusSnapshot(path string) (corpusSnapshot, error) {
@@ -1351,6 +2244,9 @@ func serializeHits(hits []model.SearchHit) []map[string]interface{} {
 			"snippet":  hit.Snippet,
 			"span":     serializeSpan(hit.Span),
 		})
+		if hit.Reranked {
+			out[len(out)-1]["rerank_score"] = hit.RerankScore
+		}
 	}
 	return out
 }
@@ -1449,8 +2345,10 @@ func parseUpOptions(global globalOptions, args []string) (upOptions, error) {
 	fs.StringVar(&opts.x402PayTo, "x402-pay-to", "", "x402 pay-to address")
 	toolsCallEnabledFlag := &optionalBoolFlag{}
 	fs.Var(toolsCallEnabledFlag, "x402-tools-call-enabled", "enable x402 gating for tools/call")
-	fs.StringVar(&opts.auth, "auth", "", "auth mode: auto|none|file:<path>")
+	fs.StringVar(&opts.auth, "auth", "", "auth mode: auto|none|file:<path>|oauth")
+	fs.StringVar(&opts.transport, "transport", transportHTTP, "MCP transport: http|stdio")
 	fs.StringVar(&opts.listen, "listen", "", "listen address")
+	fs.StringVar(&opts.metricsListen, "metrics-listen", "", "serve Prometheus metrics on this address")
 	fs.StringVar(&opts.mcpPath, "mcp-path", "", "MCP route path")
 	fs.StringVar(&opts.allowedOrigins, "allowed-origins", "", "comma-separated origins to append to the allowlist")
 	fs.StringVar(&opts.embedModelText, "embed-model-text", "", "override embedding model used for text chunks")
@@ -1478,6 +2376,10 @@ func parseUpOptions(global globalOptions, args []string) (upOptions, error) {
 	if fs.NArg() > 0 {
 		return upOptions{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
 	}
+	opts.transport = strings.ToLower(strings.TrimSpace(opts.transport))
+	if opts.transport != transportHTTP && opts.transport != transportStdio {
+		return upOptions{}, fmt.Errorf("invalid --transport %q (accepted: http, stdio)", opts.transport)
+	}
 	return opts, nil
 }
 
@@ -1505,6 +2407,16 @@ func prepareAuthMaterial(cfg config.Config) (authMaterial, error) {
 		}, nil
 	}
 
+	// access tokens come from the configured issuer; there is no local
+	// secret to issue.
+	if strings.EqualFold(mode, "oauth") {
+		return authMaterial{
+			mode:              "oauth",
+			tokenSource:       "oauth",
+			authorizationHint: "Bearer <access-token-from-issuer>",
+		}, nil
+	}
+
 	if strings.EqualFold(mode, "auto") {
 		if token := strings.TrimSpace(os.Getenv(authTokenEnvVar)); token != "" {
 			return authMaterial{
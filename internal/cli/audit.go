@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"dir2mcp/internal/audit"
+	"dir2mcp/internal/config"
+)
+
+func (a *App) runAudit(global globalOptions, args []string) int {
+	if len(args) == 0 {
+		writeln(a.stdout, "audit command: supported subcommand is verify")
+		return exitSuccess
+	}
+	switch args[0] {
+	case "verify":
+		if len(args) > 1 {
+			writef(a.stderr, "audit verify does not accept arguments: %s\n", strings.Join(args[1:], " "))
+			return exitGeneric
+		}
+		return a.runAuditVerify(global)
+	default:
+		writef(a.stderr, "unknown audit subcommand: %s\n", args[0])
+		return exitGeneric
+	}
+}
+
+func (a *App) runAuditVerify(global globalOptions) int {
+	cfg, err := config.Load(".dir2mcp.yaml")
+	if err != nil {
+		writef(a.stderr, "load config: %v\n", err)
+		return exitConfigInvalid
+	}
+	if strings.TrimSpace(cfg.StateDir) == "" {
+		cfg.StateDir = filepath.Join(".", ".dir2mcp")
+	}
+	dir := filepath.Join(cfg.StateDir, audit.DirName)
+
+	report, err := audit.Verify(dir)
+	var chainErr *audit.ChainError
+	if err != nil && !errors.As(err, &chainErr) {
+		writef(a.stderr, "audit verify: %v\n", err)
+		return exitGeneric
+	}
+
+	if global.jsonOutput {
+		payload := map[string]interface{}{
+			"ok":        chainErr == nil && !report.Truncated,
+			"files":     report.Files,
+			"entries":   report.Entries,
+			"first_seq": report.FirstSeq,
+			"last_seq":  report.LastSeq,
+			"last_hash": report.LastHash,
+			"truncated": report.Truncated,
+		}
+		if chainErr != nil {
+			payload["error"] = map[string]interface{}{
+				"file":   chainErr.File,
+				"line":   chainErr.Line,
+				"seq":    chainErr.Seq,
+				"reason": chainErr.Reason,
+			}
+		}
+		if err := emitJSON(a.stdout, payload); err != nil {
+			writef(a.stderr, "encode audit output: %v\n", err)
+			return exitGeneric
+		}
+	} else if chainErr != nil {
+		writef(a.stderr, "audit log chain is broken at %v\n", chainErr)
+		writef(a.stderr, "%d entries verified before the break\n", report.Entries)
+	} else if len(report.Files) == 0 {
+		writef(a.stdout, "no audit log in %s\n", dir)
+	} else {
+		writef(a.stdout, "audit log OK: %d entries in %d files (seq %d-%d)\n", report.Entries, len(report.Files), report.FirstSeq, report.LastSeq)
+		writef(a.stdout, "last hash: %s\n", report.LastHash)
+		if report.Truncated {
+			writef(a.stderr, "warning: the log starts at seq %d; earlier files were removed and cannot be verified\n", report.FirstSeq)
+		}
+	}
+	// a log missing its start cannot vouch for what came before it.
+	if chainErr != nil || report.Truncated {
+		return exitGeneric
+	}
+	return exitSuccess
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dir2mcp/internal/appstate"
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/metrics"
+	"dir2mcp/internal/model"
+)
+
+const (
+	metricsTokenName = "metrics.token"
+	metricsPath      = "/metrics"
+	// metricsStoreTimeout bounds the backlog query run at each scrape.
+	metricsStoreTimeout = 2 * time.Second
+)
+
+type pendingChunkCounter interface {
+	PendingChunkCounts(ctx context.Context) (map[string]int64, error)
+}
+
+// prepareMetricsToken resolves the bearer token that guards /metrics. An
+// empty token means the endpoint is open.
+func prepareMetricsToken(cfg config.Config) (token, tokenFile string, err error) {
+	mode := strings.TrimSpace(cfg.MetricsAuth)
+	switch {
+	case mode == "" || strings.EqualFold(mode, "auto"):
+		tokenPath := filepath.Join(cfg.StateDir, metricsTokenName)
+		token, err = readToken(tokenPath, true)
+		if err != nil {
+			return "", "", err
+		}
+		if token == "" {
+			if token, err = generateTokenHex(); err != nil {
+				return "", "", err
+			}
+			if err := writeSecretToken(tokenPath, token); err != nil {
+				return "", "", err
+			}
+		}
+		return token, tokenPath, nil
+	case strings.EqualFold(mode, "none"):
+		return "", "", nil
+	case len(mode) >= len("file:") && strings.EqualFold(mode[:len("file:")], "file:"):
+		tokenPath := strings.TrimSpace(mode[len("file:"):])
+		token, err = readToken(tokenPath, false)
+		if err != nil {
+			return "", "", err
+		}
+		if token == "" {
+			return "", "", errors.New("metrics auth file token is empty")
+		}
+		return token, tokenPath, nil
+	}
+	return "", "", fmt.Errorf("unsupported metrics auth mode: %s", mode)
+}
+
+// isLoopbackListenAddr reports whether addr only binds loopback interfaces.
+func isLoopbackListenAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// registerRuntimeMetrics exposes the ingestion counters, embedding backlog
+// and index sizes that other components already keep.
+func registerRuntimeMetrics(m *metrics.Metrics, state *appstate.IndexingState, st model.Store, indexes map[string]*index.HNSWIndex) {
+	reg := m.Registry
+	ingest := func(read func(appstate.IndexingSnapshot) int64) func(func(float64, ...string)) {
+		return func(emit func(float64, ...string)) {
+			emit(float64(read(state.Snapshot())))
+		}
+	}
+	reg.CounterFunc("dir2mcp_ingest_scanned_total", "Files scanned by ingestion.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.Scanned }))
+	reg.CounterFunc("dir2mcp_ingest_indexed_total", "Files indexed by ingestion.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.Indexed }))
+	reg.CounterFunc("dir2mcp_ingest_skipped_total", "Files skipped by ingestion.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.Skipped }))
+	reg.CounterFunc("dir2mcp_ingest_deleted_total", "Files found deleted by ingestion.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.Deleted }))
+	reg.CounterFunc("dir2mcp_ingest_representations_total", "Representations produced by ingestion.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.Representations }))
+	reg.CounterFunc("dir2mcp_ingest_chunks_total", "Chunks produced by ingestion.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.ChunksTotal }))
+	reg.CounterFunc("dir2mcp_ingest_embedded_total", "Chunks embedded successfully.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.EmbeddedOK }))
+	reg.CounterFunc("dir2mcp_ingest_errors_total", "Ingestion and embedding errors.", nil,
+		ingest(func(s appstate.IndexingSnapshot) int64 { return s.Errors }))
+	reg.GaugeFunc("dir2mcp_ingest_running", "1 while an ingestion pass is running.", nil,
+		func(emit func(float64, ...string)) {
+			if state.Snapshot().Running {
+				emit(1)
+			} else {
+				emit(0)
+			}
+		})
+
+	kinds := sortedIndexKinds(indexes)
+	if counter, ok := st.(pendingChunkCounter); ok {
+		reg.GaugeFunc("dir2mcp_embedding_pending_chunks", "Chunks waiting for an embedding, by index.", []string{"index"},
+			func(emit func(float64, ...string)) {
+				ctx, cancel := context.WithTimeout(context.Background(), metricsStoreTimeout)
+				defer cancel()
+				counts, err := counter.PendingChunkCounts(ctx)
+				if err != nil {
+					return
+				}
+				for _, kind := range kinds {
+					emit(float64(counts[kind]), kind)
+				}
+			})
+	}
+
+	reg.GaugeFunc("dir2mcp_index_vectors", "Vectors held by each index.", []string{"index"},
+		func(emit func(float64, ...string)) {
+			for _, kind := range kinds {
+				emit(float64(indexes[kind].Len()), kind)
+			}
+		})
+	reg.CounterFunc("dir2mcp_index_dimension_mismatch_total", "Searches that met a stored vector of a different dimension.", []string{"index"},
+		func(emit func(float64, ...string)) {
+			for _, kind := range kinds {
+				if ix := indexes[kind]; ix.Metrics != nil {
+					emit(float64(ix.Metrics.DimensionMismatch.Load()), kind)
+				}
+			}
+		})
+}
+
+func sortedIndexKinds(indexes map[string]*index.HNSWIndex) []string {
+	kinds := make([]string, 0, len(indexes))
+	for _, kind := range []string{"text", "code"} {
+		if _, ok := indexes[kind]; ok {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// serveMetrics serves m on ln until ctx is done.
+func serveMetrics(ctx context.Context, ln net.Listener, m *metrics.Metrics, token string) error {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, m.Registry.Handler(token))
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(ln)
+	}()
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
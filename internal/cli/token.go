@@ -0,0 +1,301 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/tokens"
+)
+
+type tokenCreateOptions struct {
+	name         string
+	tools        string
+	pathPrefixes stringListFlag
+	pathGlobs    stringListFlag
+	expires      time.Duration
+	rateLimit    float64
+	burst        int
+}
+
+// stringListFlag collects a flag given more than once.
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (a *App) runToken(global globalOptions, args []string) int {
+	if len(args) == 0 {
+		writeln(a.stdout, "token command: supported subcommands are create, list and revoke")
+		return exitSuccess
+	}
+
+	cfg, err := config.Load(".dir2mcp.yaml")
+	if err != nil {
+		writef(a.stderr, "load config: %v\n", err)
+		return exitConfigInvalid
+	}
+	if strings.TrimSpace(cfg.StateDir) == "" {
+		cfg.StateDir = filepath.Join(".", ".dir2mcp")
+	}
+	path := filepath.Join(cfg.StateDir, tokens.FileName)
+
+	switch args[0] {
+	case "create":
+		return a.runTokenCreate(global, path, args[1:])
+	case "list":
+		if len(args) > 1 {
+			writef(a.stderr, "token list does not accept arguments: %s\n", strings.Join(args[1:], " "))
+			return exitGeneric
+		}
+		return a.runTokenList(global, path)
+	case "revoke":
+		if len(args) != 2 {
+			writeln(a.stderr, "token revoke requires exactly one token id or name")
+			return exitGeneric
+		}
+		return a.runTokenRevoke(global, path, args[1])
+	default:
+		writef(a.stderr, "unknown token subcommand: %s\n", args[0])
+		return exitGeneric
+	}
+}
+
+func (a *App) runTokenCreate(global globalOptions, path string, args []string) int {
+	opts, err := parseTokenCreateOptions(args)
+	if err != nil {
+		writef(a.stderr, "invalid token create flags: %v\n", err)
+		return exitGeneric
+	}
+	tools, err := parseTokenTools(opts.tools)
+	if err != nil {
+		writef(a.stderr, "invalid token create flags: %v\n", err)
+		return exitGeneric
+	}
+
+	file, err := tokens.Load(path)
+	if err != nil {
+		writef(a.stderr, "token create: %v\n", err)
+		return exitGeneric
+	}
+	now := time.Now()
+	spec := tokens.Token{
+		Name:           opts.name,
+		Tools:          tools,
+		PathPrefixes:   opts.pathPrefixes,
+		PathGlobs:      opts.pathGlobs,
+		RateLimitRPS:   opts.rateLimit,
+		RateLimitBurst: opts.burst,
+	}
+	if opts.expires > 0 {
+		spec.ExpiresAt = now.Add(opts.expires)
+	}
+	token, secret, err := file.Create(spec, now)
+	if err != nil {
+		writef(a.stderr, "token create: %v\n", err)
+		return exitGeneric
+	}
+	if err := tokens.Save(path, file); err != nil {
+		writef(a.stderr, "token create: %v\n", err)
+		return exitGeneric
+	}
+
+	if global.jsonOutput {
+		payload := tokenPayload(token, now)
+		payload["secret"] = secret
+		if err := emitJSON(a.stdout, payload); err != nil {
+			writef(a.stderr, "encode token output: %v\n", err)
+			return exitGeneric
+		}
+		return exitSuccess
+	}
+	writef(a.stdout, "Created token %s (%s)\n", token.Name, token.ID)
+	writef(a.stdout, "Scopes: %s\n", describeTokenScopes(token))
+	writef(a.stdout, "Secret: %s\n", secret)
+	writeln(a.stdout, "Store the secret now; it is not shown again.")
+	return exitSuccess
+}
+
+func (a *App) runTokenList(global globalOptions, path string) int {
+	file, err := tokens.Load(path)
+	if err != nil {
+		writef(a.stderr, "token list: %v\n", err)
+		return exitGeneric
+	}
+	now := time.Now()
+
+	if global.jsonOutput {
+		listed := make([]map[string]interface{}, 0, len(file.Tokens))
+		for _, token := range file.Tokens {
+			listed = append(listed, tokenPayload(token, now))
+		}
+		if err := emitJSON(a.stdout, map[string]interface{}{"tokens": listed}); err != nil {
+			writef(a.stderr, "encode token output: %v\n", err)
+			return exitGeneric
+		}
+		return exitSuccess
+	}
+	if len(file.Tokens) == 0 {
+		writeln(a.stdout, "no tokens; create one with: dir2mcp token create --name <name>")
+		return exitSuccess
+	}
+	for _, token := range file.Tokens {
+		writef(a.stdout, "%s\t%s\t%s\t%s\n", token.ID, token.Name, tokenState(token, now), describeTokenScopes(token))
+	}
+	return exitSuccess
+}
+
+func (a *App) runTokenRevoke(global globalOptions, path, ref string) int {
+	file, err := tokens.Load(path)
+	if err != nil {
+		writef(a.stderr, "token revoke: %v\n", err)
+		return exitGeneric
+	}
+	now := time.Now()
+	token, err := file.Revoke(ref, now)
+	if err != nil {
+		writef(a.stderr, "token revoke: %v\n", err)
+		if errors.Is(err, tokens.ErrNotFound) {
+			return exitConfigInvalid
+		}
+		return exitGeneric
+	}
+	if err := tokens.Save(path, file); err != nil {
+		writef(a.stderr, "token revoke: %v\n", err)
+		return exitGeneric
+	}
+
+	if global.jsonOutput {
+		if err := emitJSON(a.stdout, tokenPayload(token, now)); err != nil {
+			writef(a.stderr, "encode token output: %v\n", err)
+			return exitGeneric
+		}
+		return exitSuccess
+	}
+	writef(a.stdout, "Revoked token %s (%s)\n", token.Name, token.ID)
+	return exitSuccess
+}
+
+func parseTokenCreateOptions(args []string) (tokenCreateOptions, error) {
+	opts := tokenCreateOptions{}
+	fs := flag.NewFlagSet("token create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.name, "name", "", "token name")
+	fs.StringVar(&opts.tools, "tools", "", "comma-separated tools the token may call (default all)")
+	fs.Var(&opts.pathPrefixes, "path-prefix", "path prefix the token may see (repeatable)")
+	fs.Var(&opts.pathGlobs, "path-glob", "path glob the token may see (repeatable)")
+	fs.DurationVar(&opts.expires, "expires", 0, "lifetime of the token, e.g. 720h (default never)")
+	fs.Float64Var(&opts.rateLimit, "rate-limit", 0, "requests per second (default unlimited)")
+	fs.IntVar(&opts.burst, "burst", 0, "rate limit burst (default rate-limit+1)")
+	if err := fs.Parse(args); err != nil {
+		return tokenCreateOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		return tokenCreateOptions{}, fmt.Errorf("token create does not accept arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if strings.TrimSpace(opts.name) == "" {
+		return tokenCreateOptions{}, errors.New("--name is required")
+	}
+	if opts.expires < 0 {
+		return tokenCreateOptions{}, errors.New("--expires must be positive")
+	}
+	if opts.rateLimit < 0 || opts.burst < 0 {
+		return tokenCreateOptions{}, errors.New("--rate-limit and --burst must not be negative")
+	}
+	return opts, nil
+}
+
+// parseTokenTools resolves a comma-separated tool list. Names may omit the
+// "dir2mcp." prefix.
+func parseTokenTools(raw string) ([]string, error) {
+	known := make(map[string]struct{})
+	for _, name := range mcp.ToolNames() {
+		known[name] = struct{}{}
+	}
+	var tools []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !strings.Contains(name, ".") {
+			name = "dir2mcp." + name
+		}
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown tool %q", name)
+		}
+		tools = append(tools, name)
+	}
+	return tools, nil
+}
+
+func tokenState(token tokens.Token, now time.Time) string {
+	switch {
+	case token.Revoked():
+		return "revoked"
+	case token.Expired(now):
+		return "expired"
+	}
+	return "active"
+}
+
+func describeTokenScopes(token tokens.Token) string {
+	parts := []string{}
+	if len(token.Tools) == 0 {
+		parts = append(parts, "tools=all")
+	} else {
+		parts = append(parts, "tools="+strings.Join(token.Tools, ","))
+	}
+	if len(token.PathPrefixes) > 0 {
+		parts = append(parts, "path_prefixes="+strings.Join(token.PathPrefixes, ","))
+	}
+	if len(token.PathGlobs) > 0 {
+		parts = append(parts, "path_globs="+strings.Join(token.PathGlobs, ","))
+	}
+	if !token.ExpiresAt.IsZero() {
+		parts = append(parts, "expires="+token.ExpiresAt.Format(time.RFC3339))
+	}
+	if token.RateLimitRPS > 0 {
+		parts = append(parts, fmt.Sprintf("rate_limit=%g/s burst=%d", token.RateLimitRPS, token.RateLimitBurst))
+	}
+	return strings.Join(parts, " ")
+}
+
+// tokenPayload is the JSON form of a token. It never includes the hash.
+func tokenPayload(token tokens.Token, now time.Time) map[string]interface{} {
+	payload := map[string]interface{}{
+		"id":            token.ID,
+		"name":          token.Name,
+		"state":         tokenState(token, now),
+		"tools":         token.Tools,
+		"path_prefixes": token.PathPrefixes,
+		"path_globs":    token.PathGlobs,
+		"created_at":    token.CreatedAt.Format(time.RFC3339),
+	}
+	if !token.ExpiresAt.IsZero() {
+		payload["expires_at"] = token.ExpiresAt.Format(time.RFC3339)
+	}
+	if !token.RevokedAt.IsZero() {
+		payload["revoked_at"] = token.RevokedAt.Format(time.RFC3339)
+	}
+	if token.RateLimitRPS > 0 {
+		payload["rate_limit_rps"] = token.RateLimitRPS
+		payload["rate_limit_burst"] = token.RateLimitBurst
+	}
+	return payload
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +19,17 @@ import (
 
 const DefaultProtocolVersion = "2025-11-25"
 
+const (
+	// DefaultRAGContextKey is the RAGContextTokens entry used for chat models
+	// without their own budget.
+	DefaultRAGContextKey = "default"
+	// MaxRAGNeighborChunks caps RAGNeighborChunks.
+	MaxRAGNeighborChunks = 8
+	// SessionStoreMemory and SessionStoreSQLite are the SessionStore values.
+	SessionStoreMemory = "memory"
+	SessionStoreSQLite = "sqlite"
+)
+
 type X402Config struct {
 	// Mode controls whether x402 payment gating is enabled.  Allowed values
 	// are "off", "on" and "required".  Validation will normalize the
@@ -41,6 +53,29 @@ type X402Config struct {
 	PayTo            string
 }
 
+// OAuthConfig configures auth mode "oauth", in which the server acts as an
+// OAuth 2.1 protected resource and accepts JWT access tokens signed by the
+// keys in a JWKS.
+type OAuthConfig struct {
+	// Issuer must equal the token's iss claim. It is also advertised as the
+	// authorization server in the protected resource metadata.
+	Issuer string
+	// Audience must appear in the token's aud claim. It defaults to
+	// ResourceURL.
+	Audience string
+	// JWKSFile and JWKSURL locate the signing keys; exactly one is set.
+	JWKSFile string
+	JWKSURL  string
+	// ClockSkew is the leeway applied to exp and nbf.
+	ClockSkew time.Duration
+	// ScopePrefix marks the scope values that grant tools and paths, as in
+	// "dir2mcp:tools:search" or "dir2mcp:paths:docs/".
+	ScopePrefix string
+	// ResourceURL is the canonical URL of the MCP endpoint. When empty it is
+	// derived from each request.
+	ResourceURL string
+}
+
 type Config struct {
 	RootDir         string
 	StateDir        string
@@ -82,11 +117,38 @@ type Config struct {
 	// defaults if the upstream API changes or custom models are desired.
 	EmbedModelText string
 	EmbedModelCode string
+	// EmbedCacheMaxBytes bounds the on-disk embedding cache under
+	// <state_dir>/cache/embeddings. Least recently used entries are pruned
+	// once the limit is exceeded; zero disables the limit.
+	EmbedCacheMaxBytes int64
 	// ChatModel specifies the Mistral chat/completion model used for
 	// RAG-style generation.  Operators can override the hardcoded default
 	// when upstream introduces a new alias or model.  Environment variable
 	// DIR2MCP_CHAT_MODEL also affects this value.
 	ChatModel string
+	// RAGContextTokens bounds how many tokens of retrieved context are packed
+	// into an ask prompt, keyed by chat model name. The "default" entry
+	// applies to chat models without their own entry. The file form is a
+	// list of "model=tokens" strings.
+	RAGContextTokens map[string]int
+	// RAGNeighborChunks is how many chunks before and after each retrieved
+	// chunk are added to ask context.
+	RAGNeighborChunks int
+	// RAGVerifyWithGenerator adds a generator pass to answer verification for
+	// sentences the lexical check does not find supported.
+	RAGVerifyWithGenerator bool
+
+	// AuditLog records every MCP request in a hash-chained log under
+	// <state_dir>/audit. AuditLogMaxBytes is the size at which the active
+	// file is rotated; zero disables rotation.
+	AuditLog         bool
+	AuditLogMaxBytes int64
+
+	// MetricsListenAddr is the bind address of the Prometheus /metrics
+	// endpoint; empty disables it. MetricsAuth is "auto" (a token in
+	// <state_dir>/metrics.token), "none", or "file:<path>".
+	MetricsListenAddr string
+	MetricsAuth       string
 
 	// SessionInactivityTimeout defines how long a session may be idle before it
 	// is considered expired.  Zero means the default hardcoded value (24h).
@@ -94,13 +156,24 @@ type Config struct {
 	// SessionMaxLifetime sets an optional absolute upper bound on a session's
 	// lifespan regardless of activity.  Zero disables this limit.
 	SessionMaxLifetime time.Duration
+	// SessionStore selects where HTTP sessions are kept: "memory" (the
+	// default, lost on restart) or "sqlite" (<state_dir>/sessions.sqlite,
+	// shared by every server using the state directory).
+	SessionStore string
 	// HealthCheckInterval controls how frequently the runtime polls connector
 	// health endpoints when checking for availability.  A zero value means the
 	// default (5s).  The interval is used as the base fixed delay; failures
 	// trigger bounded exponential backoff independent of this setting.
 	HealthCheckInterval time.Duration
 
+	// Prompts are user-defined MCP prompt templates from the config file's
+	// prompts block. Templates under <state_dir>/prompts are loaded
+	// separately with LoadPromptDir so they are never written back here.
+	Prompts []PromptTemplate
+
 	X402 X402Config
+
+	OAuth OAuthConfig
 }
 
 type fileConfig struct {
@@ -118,17 +191,26 @@ type fileConfig struct {
 	SecretPatterns  []string
 	MistralBaseURL  *string
 
-	ElevenLabsBaseURL    *string
-	ElevenLabsTTSVoiceID *string
-	AllowedOrigins       []string
-	EmbedModelText       *string
-	EmbedModelCode       *string
+	ElevenLabsBaseURL      *string
+	ElevenLabsTTSVoiceID   *string
+	AllowedOrigins         []string
+	EmbedModelText         *string
+	EmbedModelCode         *string
+	EmbedCacheMaxBytes     *int64
+	RAGContextTokens       []string
+	RAGNeighborChunks      *int
+	RAGVerifyWithGenerator *bool
+	AuditLog               *bool
+	AuditLogMaxBytes       *int64
+	MetricsListenAddr      *string
+	MetricsAuth            *string
 	// session timings expressed as YAML duration strings.  populated by
 	// parseConfigYAML's custom parser via setFileScalarValue rather than the
 	// standard yaml.Unmarshal machinery.  struct tags are therefore omitted
 	// elsewhere and would be purely documentation if added here.
 	SessionInactivityTimeout *time.Duration
 	SessionMaxLifetime       *time.Duration
+	SessionStore             *string
 	HealthCheckInterval      *time.Duration
 	X402Mode                 *string
 	X402FacilitatorURL       *string
@@ -140,6 +222,14 @@ type fileConfig struct {
 	X402Scheme               *string
 	X402Asset                *string
 	X402PayTo                *string
+	OAuthIssuer              *string
+	OAuthAudience            *string
+	OAuthJWKSFile            *string
+	OAuthJWKSURL             *string
+	OAuthClockSkew           *time.Duration
+	OAuthScopePrefix         *string
+	OAuthResourceURL         *string
+	Prompts                  []PromptTemplate
 }
 
 type persistedConfig struct {
@@ -159,13 +249,22 @@ type persistedConfig struct {
 	// optional session timeouts expressed as YAML duration strings
 	SessionInactivityTimeout time.Duration `yaml:"session_inactivity_timeout"`
 	SessionMaxLifetime       time.Duration `yaml:"session_max_lifetime"`
+	SessionStore             string        `yaml:"session_store"`
 	HealthCheckInterval      time.Duration `yaml:"health_check_interval"`
 
-	ElevenLabsBaseURL    string   `yaml:"elevenlabs_base_url"`
-	ElevenLabsTTSVoiceID string   `yaml:"elevenlabs_tts_voice_id"`
-	AllowedOrigins       []string `yaml:"allowed_origins"`
-	EmbedModelText       string   `yaml:"embed_model_text"`
-	EmbedModelCode       string   `yaml:"embed_model_code"`
+	ElevenLabsBaseURL      string   `yaml:"elevenlabs_base_url"`
+	ElevenLabsTTSVoiceID   string   `yaml:"elevenlabs_tts_voice_id"`
+	AllowedOrigins         []string `yaml:"allowed_origins"`
+	EmbedModelText         string   `yaml:"embed_model_text"`
+	EmbedModelCode         string   `yaml:"embed_model_code"`
+	EmbedCacheMaxBytes     int64    `yaml:"embed_cache_max_bytes"`
+	RAGContextTokens       []string `yaml:"rag_context_tokens"`
+	RAGNeighborChunks      int      `yaml:"rag_neighbor_chunks"`
+	RAGVerifyWithGenerator bool     `yaml:"rag_verify_with_generator"`
+	AuditLog               bool     `yaml:"audit_log"`
+	AuditLogMaxBytes       int64    `yaml:"audit_log_max_bytes"`
+	MetricsListenAddr      string   `yaml:"metrics_listen_addr"`
+	MetricsAuth            string   `yaml:"metrics_auth"`
 
 	// The following fields configure optional x402 payment gating.  The
 	// facilitator token itself is treated like any other sensitive API key:
@@ -185,6 +284,16 @@ type persistedConfig struct {
 	X402Scheme           string `yaml:"x402_scheme"`
 	X402Asset            string `yaml:"x402_asset"`
 	X402PayTo            string `yaml:"x402_pay_to"`
+
+	OAuthIssuer      string        `yaml:"oauth_issuer"`
+	OAuthAudience    string        `yaml:"oauth_audience"`
+	OAuthJWKSFile    string        `yaml:"oauth_jwks_file"`
+	OAuthJWKSURL     string        `yaml:"oauth_jwks_url"`
+	OAuthClockSkew   time.Duration `yaml:"oauth_clock_skew"`
+	OAuthScopePrefix string        `yaml:"oauth_scope_prefix"`
+	OAuthResourceURL string        `yaml:"oauth_resource_url"`
+
+	Prompts []PromptTemplate `yaml:"prompts"`
 }
 
 func Default() Config {
@@ -234,9 +343,15 @@ func Default() Config {
 			"http://127.0.0.1",
 		},
 		// default embedding models
-		EmbedModelText: "mistral-embed",
-		EmbedModelCode: "codestral-embed",
-		ChatModel:      mistral.DefaultChatModel,
+		EmbedModelText:     "mistral-embed",
+		EmbedModelCode:     "codestral-embed",
+		EmbedCacheMaxBytes: 512 << 20,
+		AuditLogMaxBytes:   64 << 20,
+		MetricsAuth:        "auto",
+		SessionStore:       SessionStoreMemory,
+		ChatModel:          mistral.DefaultChatModel,
+		RAGContextTokens:   map[string]int{DefaultRAGContextKey: 8000},
+		RAGNeighborChunks:  1,
 		X402: X402Config{
 			Mode:             "off",
 			FacilitatorURL:   "",
@@ -249,6 +364,10 @@ func Default() Config {
 			Asset:            "",
 			PayTo:            "",
 		},
+		OAuth: OAuthConfig{
+			ClockSkew:   time.Minute,
+			ScopePrefix: "dir2mcp:",
+		},
 	}
 }
 
@@ -275,26 +394,34 @@ func SaveFile(path string, cfg Config) error {
 	}
 
 	serializable := persistedConfig{
-		RootDir:              cfg.RootDir,
-		StateDir:             cfg.StateDir,
-		ListenAddr:           cfg.ListenAddr,
-		MCPPath:              cfg.MCPPath,
-		ProtocolVersion:      cfg.ProtocolVersion,
-		Public:               cfg.Public,
-		AuthMode:             cfg.AuthMode,
-		RateLimitRPS:         cfg.RateLimitRPS,
-		RateLimitBurst:       cfg.RateLimitBurst,
-		TrustedProxies:       append([]string(nil), cfg.TrustedProxies...),
-		PathExcludes:         append([]string(nil), cfg.PathExcludes...),
-		SecretPatterns:       append([]string(nil), cfg.SecretPatterns...),
-		MistralBaseURL:       cfg.MistralBaseURL,
-		ElevenLabsBaseURL:    cfg.ElevenLabsBaseURL,
-		ElevenLabsTTSVoiceID: cfg.ElevenLabsTTSVoiceID,
-		AllowedOrigins:       append([]string(nil), cfg.AllowedOrigins...),
-		EmbedModelText:       cfg.EmbedModelText,
-		EmbedModelCode:       cfg.EmbedModelCode,
-		X402Mode:             cfg.X402.Mode,
-		X402FacilitatorURL:   cfg.X402.FacilitatorURL,
+		RootDir:                cfg.RootDir,
+		StateDir:               cfg.StateDir,
+		ListenAddr:             cfg.ListenAddr,
+		MCPPath:                cfg.MCPPath,
+		ProtocolVersion:        cfg.ProtocolVersion,
+		Public:                 cfg.Public,
+		AuthMode:               cfg.AuthMode,
+		RateLimitRPS:           cfg.RateLimitRPS,
+		RateLimitBurst:         cfg.RateLimitBurst,
+		TrustedProxies:         append([]string(nil), cfg.TrustedProxies...),
+		PathExcludes:           append([]string(nil), cfg.PathExcludes...),
+		SecretPatterns:         append([]string(nil), cfg.SecretPatterns...),
+		MistralBaseURL:         cfg.MistralBaseURL,
+		ElevenLabsBaseURL:      cfg.ElevenLabsBaseURL,
+		ElevenLabsTTSVoiceID:   cfg.ElevenLabsTTSVoiceID,
+		AllowedOrigins:         append([]string(nil), cfg.AllowedOrigins...),
+		EmbedModelText:         cfg.EmbedModelText,
+		EmbedModelCode:         cfg.EmbedModelCode,
+		EmbedCacheMaxBytes:     cfg.EmbedCacheMaxBytes,
+		RAGContextTokens:       FormatRAGContextTokens(cfg.RAGContextTokens),
+		RAGNeighborChunks:      cfg.RAGNeighborChunks,
+		RAGVerifyWithGenerator: cfg.RAGVerifyWithGenerator,
+		AuditLog:               cfg.AuditLog,
+		AuditLogMaxBytes:       cfg.AuditLogMaxBytes,
+		MetricsListenAddr:      cfg.MetricsListenAddr,
+		MetricsAuth:            cfg.MetricsAuth,
+		X402Mode:               cfg.X402.Mode,
+		X402FacilitatorURL:     cfg.X402.FacilitatorURL,
 		// token intentionally omitted to avoid persisting secrets
 		// X402FacilitatorToken: cfg.X402.FacilitatorToken,
 		X402ResourceBaseURL:  cfg.X402.ResourceBaseURL,
@@ -304,10 +431,19 @@ func SaveFile(path string, cfg Config) error {
 		X402Scheme:           cfg.X402.Scheme,
 		X402Asset:            cfg.X402.Asset,
 		X402PayTo:            cfg.X402.PayTo,
+		OAuthIssuer:          cfg.OAuth.Issuer,
+		OAuthAudience:        cfg.OAuth.Audience,
+		OAuthJWKSFile:        cfg.OAuth.JWKSFile,
+		OAuthJWKSURL:         cfg.OAuth.JWKSURL,
+		OAuthClockSkew:       cfg.OAuth.ClockSkew,
+		OAuthScopePrefix:     cfg.OAuth.ScopePrefix,
+		OAuthResourceURL:     cfg.OAuth.ResourceURL,
 		// session settings
 		SessionInactivityTimeout: cfg.SessionInactivityTimeout,
 		SessionMaxLifetime:       cfg.SessionMaxLifetime,
+		SessionStore:             cfg.SessionStore,
 		HealthCheckInterval:      cfg.HealthCheckInterval,
+		Prompts:                  append([]PromptTemplate(nil), cfg.Prompts...),
 	}
 
 	raw, err := marshalConfigYAML(serializable)
@@ -451,6 +587,37 @@ func applyFileOverrides(cfg *Config, path string) error {
 	if fileCfg.EmbedModelCode != nil {
 		cfg.EmbedModelCode = *fileCfg.EmbedModelCode
 	}
+	if fileCfg.EmbedCacheMaxBytes != nil {
+		cfg.EmbedCacheMaxBytes = *fileCfg.EmbedCacheMaxBytes
+	}
+	if fileCfg.RAGContextTokens != nil {
+		budgets, err := ParseRAGContextTokens(fileCfg.RAGContextTokens)
+		if err != nil {
+			return fmt.Errorf("parse config file %s: rag_context_tokens: %w", path, err)
+		}
+		cfg.RAGContextTokens = budgets
+	}
+	if fileCfg.RAGNeighborChunks != nil {
+		cfg.RAGNeighborChunks = *fileCfg.RAGNeighborChunks
+	}
+	if fileCfg.RAGVerifyWithGenerator != nil {
+		cfg.RAGVerifyWithGenerator = *fileCfg.RAGVerifyWithGenerator
+	}
+	if fileCfg.AuditLog != nil {
+		cfg.AuditLog = *fileCfg.AuditLog
+	}
+	if fileCfg.AuditLogMaxBytes != nil {
+		cfg.AuditLogMaxBytes = *fileCfg.AuditLogMaxBytes
+	}
+	if fileCfg.MetricsListenAddr != nil {
+		cfg.MetricsListenAddr = *fileCfg.MetricsListenAddr
+	}
+	if fileCfg.MetricsAuth != nil {
+		cfg.MetricsAuth = *fileCfg.MetricsAuth
+	}
+	if fileCfg.SessionStore != nil {
+		cfg.SessionStore = *fileCfg.SessionStore
+	}
 	if fileCfg.X402Mode != nil {
 		cfg.X402.Mode = *fileCfg.X402Mode
 	}
@@ -480,6 +647,30 @@ func applyFileOverrides(cfg *Config, path string) error {
 	if fileCfg.X402PayTo != nil {
 		cfg.X402.PayTo = *fileCfg.X402PayTo
 	}
+	if fileCfg.OAuthIssuer != nil {
+		cfg.OAuth.Issuer = *fileCfg.OAuthIssuer
+	}
+	if fileCfg.OAuthAudience != nil {
+		cfg.OAuth.Audience = *fileCfg.OAuthAudience
+	}
+	if fileCfg.OAuthJWKSFile != nil {
+		cfg.OAuth.JWKSFile = *fileCfg.OAuthJWKSFile
+	}
+	if fileCfg.OAuthJWKSURL != nil {
+		cfg.OAuth.JWKSURL = *fileCfg.OAuthJWKSURL
+	}
+	if fileCfg.OAuthClockSkew != nil {
+		cfg.OAuth.ClockSkew = *fileCfg.OAuthClockSkew
+	}
+	if fileCfg.OAuthScopePrefix != nil {
+		cfg.OAuth.ScopePrefix = *fileCfg.OAuthScopePrefix
+	}
+	if fileCfg.OAuthResourceURL != nil {
+		cfg.OAuth.ResourceURL = *fileCfg.OAuthResourceURL
+	}
+	if fileCfg.Prompts != nil {
+		cfg.Prompts = fileCfg.Prompts
+	}
 
 	return nil
 }
@@ -505,10 +696,36 @@ func parseConfigYAML(raw []byte) (fileConfig, error) {
 	scanner := bufio.NewScanner(reader)
 	lineNo := 0
 	currentListKey := ""
+	// the prompts block is nested, so its lines are collected and handed to
+	// parsePromptsYAML once the next top-level key starts.
+	var promptLines []string
+	promptsLine := 0
+	flushPrompts := func() error {
+		if promptLines == nil {
+			return nil
+		}
+		prompts, err := parsePromptsYAML(promptLines, promptsLine)
+		if err != nil {
+			return err
+		}
+		cfg.Prompts = prompts
+		promptLines = nil
+		return nil
+	}
 
 	for scanner.Scan() {
 		lineNo++
-		line := strings.TrimSpace(scanner.Text())
+		raw := scanner.Text()
+		if promptLines != nil {
+			if isNestedBlockLine(raw) {
+				promptLines = append(promptLines, raw)
+				continue
+			}
+			if err := flushPrompts(); err != nil {
+				return fileConfig{}, err
+			}
+		}
+		line := strings.TrimSpace(raw)
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
@@ -534,6 +751,19 @@ func parseConfigYAML(raw []byte) (fileConfig, error) {
 			return fileConfig{}, fmt.Errorf("line %d: empty key", lineNo)
 		}
 
+		if key == "prompts" {
+			switch value {
+			case "":
+				promptLines = []string{}
+				promptsLine = lineNo
+			case "[]":
+				cfg.Prompts = []PromptTemplate{}
+			default:
+				return fileConfig{}, fmt.Errorf("line %d: prompts must be a list", lineNo)
+			}
+			continue
+		}
+
 		if value == "" {
 			if isListConfigKey(key) {
 				currentListKey = key
@@ -575,9 +805,20 @@ func parseConfigYAML(raw []byte) (fileConfig, error) {
 	if err := scanner.Err(); err != nil {
 		return fileConfig{}, err
 	}
+	if err := flushPrompts(); err != nil {
+		return fileConfig{}, err
+	}
 	return cfg, nil
 }
 
+// isNestedBlockLine reports whether raw continues a nested block: it is blank,
+// a comment, indented, or a sequence item.
+func isNestedBlockLine(raw string) bool {
+	trimmed := strings.TrimSpace(raw)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#") ||
+		strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t") || strings.HasPrefix(raw, "-")
+}
+
 func setFileScalarValue(cfg *fileConfig, key, value string) error {
 	switch key {
 	case "root_dir":
@@ -620,6 +861,42 @@ func setFileScalarValue(cfg *fileConfig, key, value string) error {
 		cfg.EmbedModelText = strPtr(value)
 	case "embed_model_code":
 		cfg.EmbedModelCode = strPtr(value)
+	case "embed_cache_max_bytes":
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer for %s", key)
+		}
+		cfg.EmbedCacheMaxBytes = &parsed
+	case "rag_neighbor_chunks":
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer for %s", key)
+		}
+		cfg.RAGNeighborChunks = intPtr(parsed)
+	case "rag_verify_with_generator":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean for %s", key)
+		}
+		cfg.RAGVerifyWithGenerator = boolPtr(parsed)
+	case "audit_log":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean for %s", key)
+		}
+		cfg.AuditLog = boolPtr(parsed)
+	case "audit_log_max_bytes":
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer for %s", key)
+		}
+		cfg.AuditLogMaxBytes = &parsed
+	case "metrics_listen_addr":
+		cfg.MetricsListenAddr = strPtr(value)
+	case "metrics_auth":
+		cfg.MetricsAuth = strPtr(value)
+	case "session_store":
+		cfg.SessionStore = strPtr(value)
 	case "session_inactivity_timeout":
 		if value == "" {
 			return nil
@@ -671,6 +948,27 @@ func setFileScalarValue(cfg *fileConfig, key, value string) error {
 		cfg.X402Asset = strPtr(value)
 	case "x402_pay_to":
 		cfg.X402PayTo = strPtr(value)
+	case "oauth_issuer":
+		cfg.OAuthIssuer = strPtr(value)
+	case "oauth_audience":
+		cfg.OAuthAudience = strPtr(value)
+	case "oauth_jwks_file":
+		cfg.OAuthJWKSFile = strPtr(value)
+	case "oauth_jwks_url":
+		cfg.OAuthJWKSURL = strPtr(value)
+	case "oauth_clock_skew":
+		if value == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration for %s", key)
+		}
+		cfg.OAuthClockSkew = &d
+	case "oauth_scope_prefix":
+		cfg.OAuthScopePrefix = strPtr(value)
+	case "oauth_resource_url":
+		cfg.OAuthResourceURL = strPtr(value)
 	default:
 		// unknown keys are intentionally ignored for forward compatibility
 	}
@@ -697,12 +995,14 @@ func setFileListValue(cfg *fileConfig, key, value string) {
 		appendValue(&cfg.SecretPatterns, value)
 	case "allowed_origins":
 		appendValue(&cfg.AllowedOrigins, value)
+	case "rag_context_tokens":
+		appendValue(&cfg.RAGContextTokens, value)
 	}
 }
 
 func isListConfigKey(key string) bool {
 	switch key {
-	case "trusted_proxies", "path_excludes", "secret_patterns", "allowed_origins":
+	case "trusted_proxies", "path_excludes", "secret_patterns", "allowed_origins", "rag_context_tokens":
 		return true
 	default:
 		return false
@@ -753,12 +1053,21 @@ func marshalConfigYAML(cfg persistedConfig) ([]byte, error) {
 	writeScalar("mistral_base_url", cfg.MistralBaseURL)
 	writeScalar("session_inactivity_timeout", cfg.SessionInactivityTimeout.String())
 	writeScalar("session_max_lifetime", cfg.SessionMaxLifetime.String())
+	writeScalar("session_store", cfg.SessionStore)
 	writeScalar("health_check_interval", cfg.HealthCheckInterval.String())
 	writeScalar("elevenlabs_base_url", cfg.ElevenLabsBaseURL)
 	writeScalar("elevenlabs_tts_voice_id", cfg.ElevenLabsTTSVoiceID)
 	writeList("allowed_origins", cfg.AllowedOrigins)
 	writeScalar("embed_model_text", cfg.EmbedModelText)
 	writeScalar("embed_model_code", cfg.EmbedModelCode)
+	writeScalar("embed_cache_max_bytes", strconv.FormatInt(cfg.EmbedCacheMaxBytes, 10))
+	writeList("rag_context_tokens", cfg.RAGContextTokens)
+	writeInt("rag_neighbor_chunks", cfg.RAGNeighborChunks)
+	writeBool("rag_verify_with_generator", cfg.RAGVerifyWithGenerator)
+	writeBool("audit_log", cfg.AuditLog)
+	writeScalar("audit_log_max_bytes", strconv.FormatInt(cfg.AuditLogMaxBytes, 10))
+	writeScalar("metrics_listen_addr", cfg.MetricsListenAddr)
+	writeScalar("metrics_auth", cfg.MetricsAuth)
 	writeScalar("x402_mode", cfg.X402Mode)
 	writeScalar("x402_facilitator_url", cfg.X402FacilitatorURL)
 	// token is never written to disk
@@ -770,6 +1079,16 @@ func marshalConfigYAML(cfg persistedConfig) ([]byte, error) {
 	writeScalar("x402_scheme", cfg.X402Scheme)
 	writeScalar("x402_asset", cfg.X402Asset)
 	writeScalar("x402_pay_to", cfg.X402PayTo)
+	writeScalar("oauth_issuer", cfg.OAuthIssuer)
+	writeScalar("oauth_audience", cfg.OAuthAudience)
+	writeScalar("oauth_jwks_file", cfg.OAuthJWKSFile)
+	writeScalar("oauth_jwks_url", cfg.OAuthJWKSURL)
+	writeScalar("oauth_clock_skew", cfg.OAuthClockSkew.String())
+	writeScalar("oauth_scope_prefix", cfg.OAuthScopePrefix)
+	writeScalar("oauth_resource_url", cfg.OAuthResourceURL)
+	if len(cfg.Prompts) > 0 {
+		marshalPromptsYAML(&b, cfg.Prompts)
+	}
 
 	return []byte(b.String()), nil
 }
@@ -809,9 +1128,57 @@ func applyEnvOverrides(cfg *Config, overrideEnv map[string]string) {
 	if m, ok := envLookup("DIR2MCP_EMBED_MODEL_CODE", overrideEnv); ok && strings.TrimSpace(m) != "" {
 		cfg.EmbedModelCode = strings.TrimSpace(m)
 	}
+	if raw, ok := envLookup("DIR2MCP_EMBED_CACHE_MAX_BYTES", overrideEnv); ok {
+		if maxBytes, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64); err == nil && maxBytes >= 0 {
+			cfg.EmbedCacheMaxBytes = maxBytes
+		}
+	}
 	if m, ok := envLookup("DIR2MCP_CHAT_MODEL", overrideEnv); ok && strings.TrimSpace(m) != "" {
 		cfg.ChatModel = strings.TrimSpace(m)
 	}
+	if raw, ok := envLookup("DIR2MCP_RAG_CONTEXT_TOKENS", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		budgets, err := ParseRAGContextTokens(strings.Split(raw, ","))
+		if err != nil {
+			cfg.Warnings = append(cfg.Warnings, fmt.Errorf("invalid DIR2MCP_RAG_CONTEXT_TOKENS: %v", err))
+		} else {
+			merged := make(map[string]int, len(cfg.RAGContextTokens)+len(budgets))
+			for name, tokens := range cfg.RAGContextTokens {
+				merged[name] = tokens
+			}
+			for name, tokens := range budgets {
+				merged[name] = tokens
+			}
+			cfg.RAGContextTokens = merged
+		}
+	}
+	if raw, ok := envLookup("DIR2MCP_RAG_NEIGHBOR_CHUNKS", overrideEnv); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && n >= 0 {
+			cfg.RAGNeighborChunks = n
+		}
+	}
+	if raw, ok := envLookup("DIR2MCP_RAG_VERIFY_WITH_GENERATOR", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		if enabled, err := strconv.ParseBool(strings.TrimSpace(raw)); err == nil {
+			cfg.RAGVerifyWithGenerator = enabled
+		} else {
+			cfg.Warnings = append(cfg.Warnings, fmt.Errorf("invalid DIR2MCP_RAG_VERIFY_WITH_GENERATOR: %v", err))
+		}
+	}
+	if raw, ok := envLookup("DIR2MCP_AUDIT_LOG", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		if enabled, err := strconv.ParseBool(strings.TrimSpace(raw)); err == nil {
+			cfg.AuditLog = enabled
+		} else {
+			cfg.Warnings = append(cfg.Warnings, fmt.Errorf("invalid DIR2MCP_AUDIT_LOG: %v", err))
+		}
+	}
+	if raw, ok := envLookup("DIR2MCP_METRICS_LISTEN_ADDR", overrideEnv); ok {
+		cfg.MetricsListenAddr = strings.TrimSpace(raw)
+	}
+	if raw, ok := envLookup("DIR2MCP_METRICS_AUTH", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		cfg.MetricsAuth = strings.TrimSpace(raw)
+	}
+	if raw, ok := envLookup("DIR2MCP_SESSION_STORE", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		cfg.SessionStore = strings.TrimSpace(raw)
+	}
 	if apiKey, ok := envLookup("ELEVENLABS_API_KEY", overrideEnv); ok && strings.TrimSpace(apiKey) != "" {
 		cfg.ElevenLabsAPIKey = apiKey
 	}
@@ -931,6 +1298,15 @@ func applyEnvOverrides(cfg *Config, overrideEnv map[string]string) {
 	if raw, ok := envLookup("DIR2MCP_X402_PAY_TO", overrideEnv); ok && strings.TrimSpace(raw) != "" {
 		cfg.X402.PayTo = strings.TrimSpace(raw)
 	}
+	if raw, ok := envLookup("DIR2MCP_OAUTH_ISSUER", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		cfg.OAuth.Issuer = strings.TrimSpace(raw)
+	}
+	if raw, ok := envLookup("DIR2MCP_OAUTH_AUDIENCE", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		cfg.OAuth.Audience = strings.TrimSpace(raw)
+	}
+	if raw, ok := envLookup("DIR2MCP_OAUTH_JWKS_URL", overrideEnv); ok && strings.TrimSpace(raw) != "" {
+		cfg.OAuth.JWKSURL = strings.TrimSpace(raw)
+	}
 }
 
 // Validate checks configuration consistency and applies normalization
@@ -955,6 +1331,33 @@ func (c *Config) Validate() error {
 	if c.HealthCheckInterval < 0 {
 		return fmt.Errorf("health_check_interval must be non-negative: %v", c.HealthCheckInterval)
 	}
+	if c.EmbedCacheMaxBytes < 0 {
+		return fmt.Errorf("embed_cache_max_bytes must be non-negative: %d", c.EmbedCacheMaxBytes)
+	}
+	if c.AuditLogMaxBytes < 0 {
+		return fmt.Errorf("audit_log_max_bytes must be non-negative: %d", c.AuditLogMaxBytes)
+	}
+	if auth := strings.TrimSpace(c.MetricsAuth); auth != "" && auth != "auto" && auth != "none" {
+		if path, ok := strings.CutPrefix(auth, "file:"); !ok || strings.TrimSpace(path) == "" {
+			return fmt.Errorf("metrics_auth must be auto, none or file:<path>: %q", c.MetricsAuth)
+		}
+	}
+	switch strings.TrimSpace(c.SessionStore) {
+	case "", SessionStoreMemory, SessionStoreSQLite:
+	default:
+		return fmt.Errorf("session_store must be %s or %s: %q", SessionStoreMemory, SessionStoreSQLite, c.SessionStore)
+	}
+	for name, tokens := range c.RAGContextTokens {
+		if tokens <= 0 {
+			return fmt.Errorf("rag_context_tokens for %s must be positive: %d", name, tokens)
+		}
+	}
+	if c.RAGNeighborChunks < 0 || c.RAGNeighborChunks > MaxRAGNeighborChunks {
+		return fmt.Errorf("rag_neighbor_chunks must be between 0 and %d: %d", MaxRAGNeighborChunks, c.RAGNeighborChunks)
+	}
+	if err := ValidatePrompts(c.Prompts); err != nil {
+		return err
+	}
 	if c.SessionInactivityTimeout == 0 {
 		// zero is shorthand for the default
 		c.SessionInactivityTimeout = Default().SessionInactivityTimeout
@@ -1102,6 +1505,48 @@ func (c *Config) ValidateX402(strict bool) error {
 	return nil
 }
 
+// ValidateOAuth checks the OAuth settings used when AuthMode is "oauth"
+// and fills in the audience from the resource URL when it is not set.
+func (c *Config) ValidateOAuth() error {
+	o := &c.OAuth
+	o.Issuer = strings.TrimSpace(o.Issuer)
+	o.Audience = strings.TrimSpace(o.Audience)
+	o.JWKSFile = strings.TrimSpace(o.JWKSFile)
+	o.JWKSURL = strings.TrimSpace(o.JWKSURL)
+	o.ResourceURL = strings.TrimSpace(o.ResourceURL)
+	if o.Issuer == "" {
+		return errors.New("oauth_issuer is required for auth mode oauth")
+	}
+	if (o.JWKSFile == "") == (o.JWKSURL == "") {
+		return errors.New("exactly one of oauth_jwks_file and oauth_jwks_url is required for auth mode oauth")
+	}
+	if o.JWKSURL != "" {
+		parsed, err := url.Parse(o.JWKSURL)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("oauth_jwks_url must be an http(s) URL: %q", o.JWKSURL)
+		}
+	}
+	if o.ResourceURL != "" {
+		parsed, err := url.Parse(o.ResourceURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("oauth_resource_url must be an absolute URL: %q", o.ResourceURL)
+		}
+	}
+	if o.Audience == "" {
+		o.Audience = o.ResourceURL
+	}
+	if o.Audience == "" {
+		return errors.New("oauth_audience or oauth_resource_url is required for auth mode oauth")
+	}
+	if o.ClockSkew < 0 {
+		return fmt.Errorf("oauth_clock_skew must be non-negative: %v", o.ClockSkew)
+	}
+	if strings.TrimSpace(o.ScopePrefix) == "" {
+		o.ScopePrefix = Default().OAuth.ScopePrefix
+	}
+	return nil
+}
+
 func isCAIP2Network(value string) bool {
 	parts := strings.Split(strings.TrimSpace(value), ":")
 	if len(parts) != 2 {
@@ -1323,3 +1768,37 @@ func unquoteEnvValue(v string) string {
 	}
 	return v
 }
+
+// ParseRAGContextTokens parses "model=tokens" entries into a budget map.
+// Blank entries are skipped.
+func ParseRAGContextTokens(entries []string) (map[string]int, error) {
+	out := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rawTokens, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected model=tokens, got %q", entry)
+		}
+		tokens, err := strconv.Atoi(strings.TrimSpace(rawTokens))
+		if err != nil || tokens <= 0 {
+			return nil, fmt.Errorf("invalid token budget for %s: %q", name, strings.TrimSpace(rawTokens))
+		}
+		out[name] = tokens
+	}
+	return out, nil
+}
+
+// FormatRAGContextTokens renders a budget map as sorted "model=tokens"
+// entries, the form used in the config file.
+func FormatRAGContextTokens(budgets map[string]int) []string {
+	out := make([]string, 0, len(budgets))
+	for name, tokens := range budgets {
+		out = append(out, name+"="+strconv.Itoa(tokens))
+	}
+	sort.Strings(out)
+	return out
+}
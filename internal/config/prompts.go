@@ -0,0 +1,558 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prompt argument types. Path arguments name a file under the root whose
+// content is embedded in the rendered prompt; symbol arguments embed the
+// definitions of a code symbol.
+const (
+	PromptArgumentString  = "string"
+	PromptArgumentPath    = "path"
+	PromptArgumentSymbol  = "symbol"
+	PromptArgumentInteger = "integer"
+	PromptArgumentBoolean = "boolean"
+)
+
+// PromptTemplate is a user-defined MCP prompt. Template is the text of the
+// rendered user message: {{arg}} is replaced by an argument's value and
+// {{#arg}}...{{/arg}} is kept only when arg is set.
+type PromptTemplate struct {
+	Name        string
+	Description string
+	Arguments   []PromptArgument
+	Template    string
+}
+
+type PromptArgument struct {
+	Name        string
+	Description string
+	// Type is one of the PromptArgument* constants. Validation fills an empty
+	// Type with path for rel_path and *_path arguments and string otherwise.
+	Type     string
+	Required bool
+}
+
+var (
+	promptNamePattern         = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	promptArgumentNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	promptPlaceholderPattern  = regexp.MustCompile(`\{\{\s*([#/]?)\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// Render substitutes values into the template. Missing values render as
+// empty strings and drop the sections guarded by them.
+func (p PromptTemplate) Render(values map[string]string) string {
+	var b strings.Builder
+	skipping := ""
+	last := 0
+	for _, m := range promptPlaceholderPattern.FindAllStringSubmatchIndex(p.Template, -1) {
+		marker, name := p.Template[m[2]:m[3]], p.Template[m[4]:m[5]]
+		if skipping == "" {
+			b.WriteString(p.Template[last:m[0]])
+		}
+		last = m[1]
+		switch marker {
+		case "#":
+			if skipping == "" && values[name] == "" {
+				skipping = name
+			}
+		case "/":
+			if skipping == name {
+				skipping = ""
+			}
+		default:
+			if skipping == "" {
+				b.WriteString(values[name])
+			}
+		}
+	}
+	if skipping == "" {
+		b.WriteString(p.Template[last:])
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// ValidatePrompts checks prompt names, argument declarations and template
+// placeholders. It normalizes the prompts in place: names and types are
+// trimmed and empty argument types get their default.
+func ValidatePrompts(prompts []PromptTemplate) error {
+	seen := make(map[string]struct{}, len(prompts))
+	for i := range prompts {
+		prompt := &prompts[i]
+		prompt.Name = strings.TrimSpace(prompt.Name)
+		if !promptNamePattern.MatchString(prompt.Name) {
+			return fmt.Errorf("prompts: invalid prompt name %q", prompt.Name)
+		}
+		if _, dup := seen[prompt.Name]; dup {
+			return fmt.Errorf("prompts: duplicate prompt %q", prompt.Name)
+		}
+		seen[prompt.Name] = struct{}{}
+		if strings.TrimSpace(prompt.Template) == "" {
+			return fmt.Errorf("prompts: %s: template is required", prompt.Name)
+		}
+
+		declared := make(map[string]struct{}, len(prompt.Arguments))
+		for j := range prompt.Arguments {
+			arg := &prompt.Arguments[j]
+			arg.Name = strings.TrimSpace(arg.Name)
+			if !promptArgumentNamePattern.MatchString(arg.Name) {
+				return fmt.Errorf("prompts: %s: invalid argument name %q", prompt.Name, arg.Name)
+			}
+			if _, dup := declared[arg.Name]; dup {
+				return fmt.Errorf("prompts: %s: duplicate argument %q", prompt.Name, arg.Name)
+			}
+			declared[arg.Name] = struct{}{}
+			arg.Type = strings.ToLower(strings.TrimSpace(arg.Type))
+			switch arg.Type {
+			case "":
+				arg.Type = PromptArgumentString
+				if arg.Name == "rel_path" || strings.HasSuffix(arg.Name, "_path") {
+					arg.Type = PromptArgumentPath
+				}
+			case PromptArgumentString, PromptArgumentPath, PromptArgumentSymbol, PromptArgumentInteger, PromptArgumentBoolean:
+			default:
+				return fmt.Errorf("prompts: %s: argument %s: unknown type %q (accepted: string, path, symbol, integer, boolean)", prompt.Name, arg.Name, arg.Type)
+			}
+		}
+
+		open := ""
+		for _, m := range promptPlaceholderPattern.FindAllStringSubmatch(prompt.Template, -1) {
+			marker, name := m[1], m[2]
+			if _, ok := declared[name]; !ok {
+				return fmt.Errorf("prompts: %s: template references undeclared argument %q", prompt.Name, name)
+			}
+			switch marker {
+			case "#":
+				if open != "" {
+					return fmt.Errorf("prompts: %s: section %q opened inside section %q", prompt.Name, name, open)
+				}
+				open = name
+			case "/":
+				if open != name {
+					return fmt.Errorf("prompts: %s: unexpected end of section %q", prompt.Name, name)
+				}
+				open = ""
+			}
+		}
+		if open != "" {
+			return fmt.Errorf("prompts: %s: section %q is not closed", prompt.Name, open)
+		}
+	}
+	return nil
+}
+
+// LoadPromptDir reads one prompt per .yaml or .yml file in dir, in file name
+// order. A prompt without a name is named after its file. A missing
+// directory yields no prompts.
+func LoadPromptDir(dir string) ([]PromptTemplate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read prompt directory %s: %w", dir, err)
+	}
+	var prompts []PromptTemplate
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read prompt file %s: %w", path, err)
+		}
+		node, err := parseYAMLSubset(strings.Split(string(raw), "\n"), 0)
+		if err != nil {
+			return nil, fmt.Errorf("parse prompt file %s: %w", path, err)
+		}
+		prompt, err := promptFromYAML(node)
+		if err != nil {
+			return nil, fmt.Errorf("parse prompt file %s: %w", path, err)
+		}
+		if strings.TrimSpace(prompt.Name) == "" {
+			prompt.Name = strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		}
+		prompts = append(prompts, prompt)
+	}
+	if err := ValidatePrompts(prompts); err != nil {
+		return nil, fmt.Errorf("prompt directory %s: %w", dir, err)
+	}
+	return prompts, nil
+}
+
+// parsePromptsYAML parses the block under the top-level prompts key, which
+// starts after line firstLine of the config file.
+func parsePromptsYAML(lines []string, firstLine int) ([]PromptTemplate, error) {
+	node, err := parseYAMLSubset(lines, firstLine)
+	if err != nil {
+		return nil, fmt.Errorf("prompts: %w", err)
+	}
+	if node == nil {
+		return []PromptTemplate{}, nil
+	}
+	items, ok := node.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("line %d: prompts must be a list", firstLine)
+	}
+	prompts := make([]PromptTemplate, 0, len(items))
+	for i, item := range items {
+		prompt, err := promptFromYAML(item)
+		if err != nil {
+			return nil, fmt.Errorf("prompts[%d]: %w", i, err)
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, nil
+}
+
+func promptFromYAML(node interface{}) (PromptTemplate, error) {
+	fields, ok := node.(map[string]interface{})
+	if !ok {
+		return PromptTemplate{}, errors.New("prompt must be a mapping")
+	}
+	var (
+		prompt PromptTemplate
+		err    error
+	)
+	for _, key := range sortedYAMLKeys(fields) {
+		value := fields[key]
+		switch key {
+		case "name":
+			prompt.Name, err = yamlString(key, value)
+		case "description":
+			prompt.Description, err = yamlString(key, value)
+		case "template":
+			prompt.Template, err = yamlString(key, value)
+		case "arguments":
+			prompt.Arguments, err = promptArgumentsFromYAML(value)
+		default:
+			err = fmt.Errorf("unknown prompt field %q", key)
+		}
+		if err != nil {
+			return PromptTemplate{}, err
+		}
+	}
+	return prompt, nil
+}
+
+func promptArgumentsFromYAML(node interface{}) ([]PromptArgument, error) {
+	if s, ok := node.(string); ok && s == "" {
+		return nil, nil
+	}
+	items, ok := node.([]interface{})
+	if !ok {
+		return nil, errors.New("arguments must be a list")
+	}
+	args := make([]PromptArgument, 0, len(items))
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("arguments[%d] must be a mapping", i)
+		}
+		var (
+			arg PromptArgument
+			err error
+		)
+		for _, key := range sortedYAMLKeys(fields) {
+			value := fields[key]
+			switch key {
+			case "name":
+				arg.Name, err = yamlString(key, value)
+			case "description":
+				arg.Description, err = yamlString(key, value)
+			case "type":
+				arg.Type, err = yamlString(key, value)
+			case "required":
+				var raw string
+				if raw, err = yamlString(key, value); err == nil {
+					if arg.Required, err = strconv.ParseBool(raw); err != nil {
+						err = fmt.Errorf("invalid boolean for required")
+					}
+				}
+			default:
+				err = fmt.Errorf("unknown argument field %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("arguments[%d]: %w", i, err)
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func yamlString(key string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+	return s, nil
+}
+
+func sortedYAMLKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// marshalPromptsYAML writes prompts in the form parsePromptsYAML reads.
+func marshalPromptsYAML(b *strings.Builder, prompts []PromptTemplate) {
+	b.WriteString("prompts:\n")
+	for _, prompt := range prompts {
+		b.WriteString("  - name: ")
+		b.WriteString(strconv.Quote(prompt.Name))
+		b.WriteByte('\n')
+		if prompt.Description != "" {
+			b.WriteString("    description: ")
+			b.WriteString(strconv.Quote(prompt.Description))
+			b.WriteByte('\n')
+		}
+		if len(prompt.Arguments) > 0 {
+			b.WriteString("    arguments:\n")
+			for _, arg := range prompt.Arguments {
+				b.WriteString("      - name: ")
+				b.WriteString(strconv.Quote(arg.Name))
+				b.WriteByte('\n')
+				if arg.Description != "" {
+					b.WriteString("        description: ")
+					b.WriteString(strconv.Quote(arg.Description))
+					b.WriteByte('\n')
+				}
+				if arg.Type != "" {
+					b.WriteString("        type: ")
+					b.WriteString(arg.Type)
+					b.WriteByte('\n')
+				}
+				if arg.Required {
+					b.WriteString("        required: true\n")
+				}
+			}
+		}
+		b.WriteString("    template: |-\n")
+		for _, line := range strings.Split(strings.TrimRight(prompt.Template, "\n"), "\n") {
+			if line != "" {
+				b.WriteString("      ")
+				b.WriteString(line)
+			}
+			b.WriteByte('\n')
+		}
+	}
+}
+
+// yamlSubsetParser reads the YAML subset used by prompt definitions: block
+// mappings and sequences, flow lists of scalars, quoted scalars and literal
+// block scalars (| and |-). Scalars are returned as strings.
+type yamlSubsetParser struct {
+	lines     []string
+	pos       int
+	firstLine int
+}
+
+func parseYAMLSubset(lines []string, firstLine int) (interface{}, error) {
+	p := &yamlSubsetParser{lines: append([]string(nil), lines...), firstLine: firstLine}
+	node, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok := p.peek(); ok {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return node, nil
+}
+
+func (p *yamlSubsetParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.firstLine+p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// peek returns the indentation and text of the next line that is neither
+// blank nor a comment.
+func (p *yamlSubsetParser) peek() (int, string, bool) {
+	for p.pos < len(p.lines) {
+		line := strings.TrimRight(p.lines[p.pos], " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" || strings.HasPrefix(text, "#") {
+			p.pos++
+			continue
+		}
+		return len(line) - len(text), text, true
+	}
+	return 0, "", false
+}
+
+func (p *yamlSubsetParser) parseNode(minIndent int) (interface{}, error) {
+	indent, text, ok := p.peek()
+	if !ok || indent < minIndent {
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "\t") {
+		return nil, p.errorf("tabs are not allowed in indentation")
+	}
+	if isYAMLSequenceItem(text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlSubsetParser) parseSequence(indent int) ([]interface{}, error) {
+	items := []interface{}{}
+	for {
+		lineIndent, text, ok := p.peek()
+		if !ok || lineIndent < indent {
+			return items, nil
+		}
+		if lineIndent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if !isYAMLSequenceItem(text) {
+			return items, nil
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(text, "-"))
+		switch {
+		case rest == "":
+			p.pos++
+			child, err := p.parseNode(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, child)
+		case isYAMLKeyLine(rest):
+			// a mapping that starts on the item line: re-read the line as if
+			// the mapping were indented to the column after "- ".
+			column := indent + len(text) - len(rest)
+			p.lines[p.pos] = strings.Repeat(" ", column) + rest
+			child, err := p.parseMapping(column)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, child)
+		default:
+			p.pos++
+			items = append(items, parseYAMLSubsetScalar(rest))
+		}
+	}
+}
+
+func (p *yamlSubsetParser) parseMapping(indent int) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	for {
+		lineIndent, text, ok := p.peek()
+		if !ok || lineIndent < indent {
+			return fields, nil
+		}
+		if lineIndent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isYAMLSequenceItem(text) {
+			return fields, nil
+		}
+		if !isYAMLKeyLine(text) {
+			return nil, p.errorf("expected key: value")
+		}
+		key, value, _ := strings.Cut(text, ":")
+		key = unquoteYAMLScalar(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if _, dup := fields[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+
+		switch {
+		case value == "|" || value == "|-":
+			fields[key] = p.parseBlockScalar(indent, value == "|-")
+		case value == "":
+			nextIndent, nextText, ok := p.peek()
+			switch {
+			case ok && nextIndent == indent && isYAMLSequenceItem(nextText):
+				child, err := p.parseSequence(indent)
+				if err != nil {
+					return nil, err
+				}
+				fields[key] = child
+			case ok && nextIndent > indent:
+				child, err := p.parseNode(nextIndent)
+				if err != nil {
+					return nil, err
+				}
+				fields[key] = child
+			default:
+				fields[key] = ""
+			}
+		default:
+			fields[key] = parseYAMLSubsetScalar(value)
+		}
+	}
+}
+
+// parseBlockScalar reads the lines of a literal block scalar whose key sits
+// at parentIndent. Trailing blank lines are dropped; | keeps one final
+// newline and |- none.
+func (p *yamlSubsetParser) parseBlockScalar(parentIndent int, strip bool) string {
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := strings.TrimRight(p.lines[p.pos], " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		indent := len(line) - len(text)
+		if indent <= parentIndent || (blockIndent >= 0 && indent < blockIndent) {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = indent
+		}
+		lines = append(lines, line[blockIndent:])
+		p.pos++
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	out := strings.Join(lines, "\n")
+	if !strip && out != "" {
+		out += "\n"
+	}
+	return out
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isYAMLKeyLine(text string) bool {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") || strings.HasPrefix(text, "[") {
+		return false
+	}
+	key, value, found := strings.Cut(text, ":")
+	return found && strings.TrimSpace(key) != "" && (value == "" || value[0] == ' ')
+}
+
+func parseYAMLSubsetScalar(value string) interface{} {
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		items := []interface{}{}
+		inner := strings.TrimSpace(value[1 : len(value)-1])
+		if inner == "" {
+			return items
+		}
+		for _, token := range strings.Split(inner, ",") {
+			items = append(items, unquoteYAMLScalar(token))
+		}
+		return items
+	}
+	return unquoteYAMLScalar(value)
+}
@@ -22,6 +22,10 @@ type Options struct {
 	Verbose     bool
 	JSON        bool
 	StartupHint string
+	// Conversations sends a conversation_id with ask calls so the server
+	// resolves follow-up questions against earlier turns. Run enables it
+	// when the server's ask tool accepts the argument.
+	Conversations bool
 }
 
 var requiredTools = []string{
@@ -42,6 +46,11 @@ var autoApprove = map[string]bool{
 	protocol.ToolNameTranscribe:       true,
 	protocol.ToolNameAnnotate:         true,
 	protocol.ToolNameTranscribeAndAsk: true,
+	protocol.ToolNameFindSimilar:      true,
+	protocol.ToolNameGrep:             true,
+	protocol.ToolNameSymbols:          true,
+	protocol.ToolNameDefinition:       true,
+	protocol.ToolNameReferences:       true,
 }
 
 func Run(ctx context.Context, opts Options) error {
@@ -66,6 +75,7 @@ func Run(ctx context.Context, opts Options) error {
 	if err := validateTools(tools); err != nil {
 		return err
 	}
+	opts.Conversations = toolAcceptsArgument(tools, protocol.ToolNameAsk, "conversation_id")
 	opts.StartupHint = startupStatsHint(ctx, client)
 	if opts.JSON {
 		return RunJSONLoopWithIO(ctx, client, opts, os.Stdin, os.Stdout)
@@ -102,6 +112,10 @@ type jsonEvent struct {
 
 func runJSONLoop(ctx context.Context, client *mcp.Client, opts Options, in io.Reader, out io.Writer) error {
 	planner := NewPlanner(opts.Model)
+	conversationID := ""
+	if opts.Conversations {
+		conversationID = newConversationID()
+	}
 	enc := json.NewEncoder(out)
 	write := func(kind string, data map[string]any) error {
 		return enc.Encode(jsonEvent{Version: "v1", Type: kind, Data: data})
@@ -142,6 +156,9 @@ func runJSONLoop(ctx context.Context, client *mcp.Client, opts Options, in io.Re
 			continue
 		}
 		if plan.Clear {
+			if conversationID != "" {
+				conversationID = newConversationID()
+			}
 			if err := write("cleared", map[string]any{"status": "ok"}); err != nil {
 				return err
 			}
@@ -150,6 +167,7 @@ func runJSONLoop(ctx context.Context, client *mcp.Client, opts Options, in io.Re
 		if len(plan.Steps) == 0 {
 			continue
 		}
+		plan = WithConversation(plan, conversationID)
 
 		var approvalTool string
 		for _, step := range plan.Steps {
@@ -24,6 +24,13 @@ type mcpResponseMsg struct {
 	clear  bool
 }
 
+// answerDeltaMsg carries answer text streamed during a turn; events delivers
+// the rest of the turn.
+type answerDeltaMsg struct {
+	text   string
+	events <-chan tea.Msg
+}
+
 type breezeModel struct {
 	client    *mcp.Client
 	ctx       context.Context
@@ -33,6 +40,8 @@ type breezeModel struct {
 	spinner   spinner.Model
 	messages  []string
 	banner    []string
+	// draft is the answer streamed so far in the running turn.
+	draft     string
 	isLoading bool
 	ready     bool
 	width     int
@@ -41,6 +50,10 @@ type breezeModel struct {
 
 	// For confirmation
 	confirmingPlan TurnPlan
+
+	// conversationID ties ask calls together for follow-up questions; empty
+	// when the server does not support conversations.
+	conversationID string
 }
 
 func initialModel(ctx context.Context, client *mcp.Client, opts Options) breezeModel {
@@ -55,6 +68,10 @@ func initialModel(ctx context.Context, client *mcp.Client, opts Options) breezeM
 	s.Style = lipgloss.NewStyle().Foreground(ui.ClrBrand)
 
 	msgs := connectedBanner(opts.MCPURL, opts.Transport, client.SessionID(), opts.Model, opts.StartupHint)
+	conversationID := ""
+	if opts.Conversations {
+		conversationID = newConversationID()
+	}
 
 	return breezeModel{
 		client:    client,
@@ -64,6 +81,8 @@ func initialModel(ctx context.Context, client *mcp.Client, opts Options) breezeM
 		spinner:   s,
 		messages:  msgs,
 		banner:    append([]string(nil), msgs...),
+
+		conversationID: conversationID,
 	}
 }
 
@@ -117,13 +136,13 @@ func (m breezeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.isLoading = true
 					plan := m.confirmingPlan
 					m.confirmingPlan = TurnPlan{}
-					m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+					m.viewport.SetContent(m.transcript())
 					m.viewport.GotoBottom()
 					return m, tea.Batch(m.runPlanCmd(plan), m.spinner.Tick)
 				}
 				m.messages = append(m.messages, ui.Dim("Cancelled "+approvalTool+"."))
 				m.confirmingPlan = TurnPlan{}
-				m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+				m.viewport.SetContent(m.transcript())
 				m.viewport.GotoBottom()
 				return m, spCmd
 			}
@@ -136,7 +155,7 @@ func (m breezeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.messages = append(m.messages, ui.Prompt("breeze")+input)
 
 			m.isLoading = true
-			m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+			m.viewport.SetContent(m.transcript())
 			m.viewport.GotoBottom()
 
 			return m, tea.Batch(m.processInputCmd(input), m.spinner.Tick)
@@ -145,14 +164,26 @@ func (m breezeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.applyWindowSize(msg.Width, msg.Height)
 
+	case answerDeltaMsg:
+		m.draft += msg.text
+		m.viewport.SetContent(m.transcript())
+		m.viewport.GotoBottom()
+		return m, tea.Batch(waitForTurnEvent(msg.events), spCmd)
+
 	case mcpResponseMsg:
 		m.isLoading = false
+		m.draft = ""
 		if msg.quit {
 			return m, tea.Quit
 		}
 		if msg.clear {
+			// a cleared chat starts a new conversation so earlier turns no
+			// longer shape follow-up questions.
+			if m.conversationID != "" {
+				m.conversationID = newConversationID()
+			}
 			m.messages = append([]string(nil), m.banner...)
-			m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+			m.viewport.SetContent(m.transcript())
 			m.viewport.GotoBottom()
 			return m, nil
 		}
@@ -165,7 +196,7 @@ func (m breezeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else if msg.output != "" {
 			m.messages = append(m.messages, msg.output)
 		}
-		m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+		m.viewport.SetContent(m.transcript())
 		m.viewport.GotoBottom()
 		return m, nil
 
@@ -177,7 +208,7 @@ func (m breezeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			approvalTool = "pending plan"
 		}
 		m.messages = append(m.messages, ui.Yellow.Render("Approval required for ")+ui.Brand.Render(approvalTool))
-		m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+		m.viewport.SetContent(m.transcript())
 		m.viewport.GotoBottom()
 		return m, nil
 	}
@@ -225,6 +256,15 @@ func (m breezeModel) View() string {
 	return b.String()
 }
 
+// transcript renders the chat, including the answer being streamed.
+func (m breezeModel) transcript() string {
+	content := strings.Join(m.messages, "\n\n")
+	if m.draft != "" {
+		content += "\n\n" + m.draft
+	}
+	return content
+}
+
 func (m *breezeModel) applyWindowSize(width, height int) {
 	if width <= 0 || height <= 0 {
 		return
@@ -245,7 +285,7 @@ func (m *breezeModel) applyWindowSize(width, height int) {
 
 	if !m.ready {
 		m.viewport = viewport.New(vpWidth, vpHeight)
-		m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+		m.viewport.SetContent(m.transcript())
 		m.ready = true
 		return
 	}
@@ -298,6 +338,7 @@ func formatHelpCompact() string {
 }
 
 func (m *breezeModel) processInputCmd(input string) tea.Cmd {
+	conversationID := m.conversationID
 	return func() tea.Msg {
 		plan, err := PlanTurn(input, m.modelName)
 		if err != nil {
@@ -315,7 +356,7 @@ func (m *breezeModel) processInputCmd(input string) tea.Cmd {
 		if len(plan.Steps) == 0 {
 			return mcpResponseMsg{}
 		}
-		return m.checkApprovalAndRunPlan(plan)
+		return m.checkApprovalAndRunPlan(WithConversation(plan, conversationID))
 	}
 }
 
@@ -325,20 +366,47 @@ func (m *breezeModel) checkApprovalAndRunPlan(plan TurnPlan) tea.Msg {
 			return approvalReqMsg{plan: plan}
 		}
 	}
-	execRes, err := ExecutePlan(m.ctx, m.client, plan)
-	if err != nil {
-		return mcpResponseMsg{err: err}
-	}
-	return mcpResponseMsg{output: execRes.Output}
+	return m.streamPlan(plan)
 }
 
 func (m *breezeModel) runPlanCmd(plan TurnPlan) tea.Cmd {
 	return func() tea.Msg {
-		execRes, err := ExecutePlan(m.ctx, m.client, plan)
+		return m.streamPlan(plan)
+	}
+}
+
+// streamPlan runs plan in the background and returns its first event: an
+// answerDeltaMsg while an answer streams, or the final mcpResponseMsg.
+func (m *breezeModel) streamPlan(plan TurnPlan) tea.Msg {
+	ctx := m.ctx
+	events := make(chan tea.Msg, 64)
+	send := func(msg tea.Msg) {
+		select {
+		case events <- msg:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(events)
+		execRes, err := ExecutePlanWithProgress(ctx, m.client, plan, func(text string) {
+			send(answerDeltaMsg{text: text, events: events})
+		})
 		if err != nil {
-			return mcpResponseMsg{err: err}
+			send(mcpResponseMsg{err: err})
+			return
+		}
+		send(mcpResponseMsg{output: execRes.Output})
+	}()
+	return waitForTurnEvent(events)()
+}
+
+func waitForTurnEvent(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-events
+		if !ok {
+			return nil
 		}
-		return mcpResponseMsg{output: execRes.Output}
+		return msg
 	}
 }
 
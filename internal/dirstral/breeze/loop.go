@@ -2,6 +2,8 @@ package breeze
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -165,13 +167,25 @@ func AskTopKForModel(model string) int {
 }
 
 func ExecuteParsed(ctx context.Context, client *mcp.Client, parsed ParsedInput) (*ToolExecution, error) {
+	return executeParsed(ctx, client, parsed, nil)
+}
+
+func executeParsed(ctx context.Context, client *mcp.Client, parsed ParsedInput, onAnswerDelta func(string)) (*ToolExecution, error) {
 	if parsed.Tool == "" {
 		return &ToolExecution{}, nil
 	}
 	if client == nil {
 		return nil, ErrNilMCPClient
 	}
-	res, err := client.CallTool(ctx, parsed.Tool, parsed.Args)
+	var (
+		res *mcp.ToolCallResult
+		err error
+	)
+	if onAnswerDelta != nil && streamsAnswer(parsed.Tool) {
+		res, err = client.CallToolStream(ctx, parsed.Tool, parsed.Args, onAnswerDelta)
+	} else {
+		res, err = client.CallTool(ctx, parsed.Tool, parsed.Args)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -188,6 +202,12 @@ func ExecuteParsed(ctx context.Context, client *mcp.Client, parsed ParsedInput)
 	}, nil
 }
 
+// streamsAnswer reports whether the server streams tool's answer text as
+// progress notifications.
+func streamsAnswer(tool string) bool {
+	return tool == protocol.ToolNameAsk || tool == protocol.ToolNameTranscribeAndAsk
+}
+
 func needsApproval(tool string) bool {
 	return !autoApprove[tool]
 }
@@ -200,7 +220,60 @@ func PlanTurn(input, model string) (TurnPlan, error) {
 	return NewPlanner(model).Plan(input)
 }
 
+// WithConversation tags every ask step in plan with conversationID so the
+// server can resolve follow-up questions against earlier turns. An empty ID
+// leaves the plan unchanged.
+func WithConversation(plan TurnPlan, conversationID string) TurnPlan {
+	if conversationID == "" {
+		return plan
+	}
+	steps := make([]PlanStep, len(plan.Steps))
+	for i, step := range plan.Steps {
+		if step.Tool == protocol.ToolNameAsk {
+			args := make(map[string]any, len(step.Args)+1)
+			for k, v := range step.Args {
+				args[k] = v
+			}
+			args["conversation_id"] = conversationID
+			step.Args = args
+		}
+		steps[i] = step
+	}
+	plan.Steps = steps
+	return plan
+}
+
+// newConversationID returns a random identifier for a breeze conversation.
+func newConversationID() string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// toolAcceptsArgument reports whether the named tool's input schema lists
+// arg, so newer arguments are only sent to servers that understand them.
+func toolAcceptsArgument(tools []mcp.Tool, name, arg string) bool {
+	for _, tool := range tools {
+		if tool.Name != name {
+			continue
+		}
+		props, _ := tool.InputSchema["properties"].(map[string]any)
+		_, ok := props[arg]
+		return ok
+	}
+	return false
+}
+
 func ExecutePlan(ctx context.Context, client *mcp.Client, plan TurnPlan) (*TurnExecution, error) {
+	return ExecutePlanWithProgress(ctx, client, plan, nil)
+}
+
+// ExecutePlanWithProgress is like ExecutePlan but streams answers: for ask
+// steps onAnswerDelta receives the answer text as the server generates it.
+// The streamed text is a draft; the turn output holds the final answer.
+func ExecutePlanWithProgress(ctx context.Context, client *mcp.Client, plan TurnPlan, onAnswerDelta func(string)) (*TurnExecution, error) {
 	if len(plan.Steps) == 0 {
 		return &TurnExecution{}, nil
 	}
@@ -209,7 +282,7 @@ func ExecutePlan(ctx context.Context, client *mcp.Client, plan TurnPlan) (*TurnE
 	seen := map[string]bool{}
 	allCitations := make([]string, 0)
 	for _, step := range plan.Steps {
-		execRes, err := ExecuteParsed(ctx, client, ParsedInput{Tool: step.Tool, Args: step.Args})
+		execRes, err := executeParsed(ctx, client, ParsedInput{Tool: step.Tool, Args: step.Args}, onAnswerDelta)
 		if err != nil {
 			return nil, err
 		}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	"os/exec"
@@ -227,12 +228,39 @@ func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
 }
 
 func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*ToolCallResult, error) {
+	return c.callTool(ctx, name, args, nil)
+}
+
+// CallToolStream is like CallTool but asks the server to stream progress for
+// the call: onProgress receives the message of each notifications/progress
+// in order (for dir2mcp.ask, the answer text as it is generated). Servers or
+// transports that do not stream simply return the result.
+func (c *Client) CallToolStream(ctx context.Context, name string, args map[string]any, onProgress func(message string)) (*ToolCallResult, error) {
+	return c.callTool(ctx, name, args, onProgress)
+}
+
+func (c *Client) callTool(ctx context.Context, name string, args map[string]any, onProgress func(message string)) (*ToolCallResult, error) {
 	params := map[string]any{
 		"name":      name,
 		"arguments": args,
 	}
+	var onNotification func(method string, params map[string]any)
+	if onProgress != nil && c.transport == "streamable-http" {
+		c.mu.Lock()
+		token := fmt.Sprintf("dirstral-%d", c.nextID)
+		c.mu.Unlock()
+		params["_meta"] = map[string]any{"progressToken": token}
+		onNotification = func(method string, notification map[string]any) {
+			if method != protocol.RPCMethodProgress || asString(notification["progressToken"]) != token {
+				return
+			}
+			if message := asString(notification["message"]); message != "" {
+				onProgress(message)
+			}
+		}
+	}
 	start := time.Now()
-	body, status, headers, err := c.call(ctx, protocol.RPCMethodToolsCall, params, true)
+	body, status, headers, err := c.callWithNotifications(ctx, protocol.RPCMethodToolsCall, params, true, onNotification)
 	if err != nil && c.transport == "streamable-http" && isSessionNotFoundError(err) {
 		if c.verbose {
 			fmt.Println("[mcp] SESSION_NOT_FOUND received; recovering session and retrying tools/call once")
@@ -246,7 +274,7 @@ func (c *Client) CallTool(ctx context.Context, name string, args map[string]any)
 		if c.verbose {
 			fmt.Println("[mcp] session recovery succeeded; retrying tools/call")
 		}
-		body, status, headers, err = c.call(ctx, protocol.RPCMethodToolsCall, params, true)
+		body, status, headers, err = c.callWithNotifications(ctx, protocol.RPCMethodToolsCall, params, true, onNotification)
 		if err != nil && c.verbose {
 			fmt.Printf("[mcp] tools/call retry failed: %v\n", err)
 		}
@@ -310,6 +338,13 @@ func isSessionNotFoundError(err error) bool {
 }
 
 func (c *Client) call(ctx context.Context, method string, params map[string]any, withID bool) (map[string]any, int, http.Header, error) {
+	return c.callWithNotifications(ctx, method, params, withID, nil)
+}
+
+// callWithNotifications is like call but hands every notification received
+// on a text/event-stream response to onNotification before the response
+// itself is returned.
+func (c *Client) callWithNotifications(ctx context.Context, method string, params map[string]any, withID bool, onNotification func(method string, params map[string]any)) (map[string]any, int, http.Header, error) {
 	if c.transport == "stdio" {
 		return c.callStdio(ctx, method, params, withID)
 	}
@@ -359,7 +394,12 @@ func (c *Client) call(ctx context.Context, method string, params map[string]any,
 	defer func() {
 		_ = resp.Body.Close()
 	}()
-	bodyBytes, err := io.ReadAll(resp.Body)
+	var bodyBytes []byte
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+		bodyBytes, err = readEventStreamResponse(resp.Body, onNotification)
+	} else {
+		bodyBytes, err = io.ReadAll(resp.Body)
+	}
 	if err != nil {
 		return nil, resp.StatusCode, resp.Header, err
 	}
@@ -540,6 +580,46 @@ func (c *Client) callStdio(ctx context.Context, method string, params map[string
 	return raw, http.StatusOK, http.Header{}, nil
 }
 
+// readEventStreamResponse reads server-sent events until the JSON-RPC
+// response arrives and returns its bytes. Notifications before it are passed
+// to onNotification.
+func readEventStreamResponse(r io.Reader, onNotification func(method string, params map[string]any)) ([]byte, error) {
+	reader := bufio.NewReader(r)
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+		// a blank line, or the end of the stream, completes an event.
+		if (line == "" || err != nil) && len(data) > 0 {
+			payload := []byte(strings.Join(data, "\n"))
+			data = data[:0]
+			var message struct {
+				ID     any            `json:"id"`
+				Method string         `json:"method"`
+				Params map[string]any `json:"params"`
+			}
+			if jsonErr := json.Unmarshal(payload, &message); jsonErr != nil {
+				return nil, fmt.Errorf("invalid event stream message: %w", jsonErr)
+			}
+			if message.Method == "" {
+				return payload, nil
+			}
+			if onNotification != nil && message.ID == nil {
+				onNotification(message.Method, message.Params)
+			}
+		}
+		if err == io.EOF {
+			return nil, fmt.Errorf("event stream ended without a response")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
 func writeStdioMessage(w io.Writer, payload []byte) error {
 	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(payload)); err != nil {
 		return err
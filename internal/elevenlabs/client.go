@@ -24,6 +24,8 @@ type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	VoiceID    string
+	// Observer, when set, is told about every synthesis call.
+	Observer model.ProviderObserver
 }
 
 type synthesizeRequest struct {
@@ -45,6 +47,15 @@ func (c *Client) Synthesize(ctx context.Context, text string) ([]byte, error) {
 }
 
 func (c *Client) SynthesizeWithVoice(ctx context.Context, text, voiceID string) ([]byte, error) {
+	start := time.Now()
+	audio, err := c.synthesize(ctx, text, voiceID)
+	if c.Observer != nil {
+		c.Observer.ObserveProviderCall("elevenlabs", "tts", time.Since(start), err)
+	}
+	return audio, err
+}
+
+func (c *Client) synthesize(ctx context.Context, text, voiceID string) ([]byte, error) {
 	apiKey := strings.TrimSpace(c.APIKey)
 	if apiKey == "" {
 		return nil, &model.ProviderError{
@@ -112,7 +123,7 @@ func (c *Client) SynthesizeWithVoice(ctx context.Context, text, voiceID string)
 		return nil, &model.ProviderError{
 			Code:      "ELEVENLABS_FAILED",
 			Message:   "tts request failed",
-			Retryable: true,
+			Retryable: ctx.Err() == nil,
 			Cause:     err,
 		}
 	}
@@ -0,0 +1,213 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmbeddingCache stores vectors keyed by embedding model and chunk text so
+// unchanged chunks can be re-indexed without calling the provider again.
+type EmbeddingCache interface {
+	Get(modelName, text string) ([]float32, bool)
+	Put(modelName, text string, vector []float32) error
+}
+
+const (
+	embeddingCacheExt      = ".f32"
+	defaultCachePruneEvery = 128
+)
+
+// FileEmbeddingCache is an EmbeddingCache backed by one file per entry in a
+// directory (normally <state_dir>/cache/embeddings). Entries are keyed by
+// (model, text_hash) where text_hash is the sha256 of the chunk text, matching
+// chunks.text_hash in the metadata store.
+//
+// Size is bounded with least-recently-used pruning: reads refresh an entry's
+// modification time and pruning removes the oldest entries first, mirroring
+// the OCR cache policy in the ingest service.
+type FileEmbeddingCache struct {
+	dir string
+
+	// Logger is optional; when nil the standard library's log package is
+	// used for pruning warnings.
+	Logger *log.Logger
+
+	mu         sync.Mutex
+	maxBytes   int64
+	pruneEvery int
+	writes     int
+}
+
+// NewFileEmbeddingCache returns a cache rooted at dir. A maxBytes value of
+// zero or less disables size pruning.
+func NewFileEmbeddingCache(dir string, maxBytes int64) *FileEmbeddingCache {
+	return &FileEmbeddingCache{
+		dir:        dir,
+		maxBytes:   maxBytes,
+		pruneEvery: defaultCachePruneEvery,
+	}
+}
+
+// SetPruneEvery configures how many writes happen between pruning scans. A
+// value of zero or less prunes after every write.
+func (c *FileEmbeddingCache) SetPruneEvery(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneEvery = n
+}
+
+// Get returns the cached vector for text embedded with modelName.
+func (c *FileEmbeddingCache) Get(modelName, text string) ([]float32, bool) {
+	path := c.entryPath(modelName, text)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	vector, err := decodeCachedVector(raw)
+	if err != nil {
+		// a corrupt entry is worse than a miss; drop it so it is rewritten.
+		_ = os.Remove(path)
+		return nil, false
+	}
+	// refresh the modification time so pruning evicts least recently used
+	// entries first.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return vector, true
+}
+
+// Put stores vector for text embedded with modelName.
+func (c *FileEmbeddingCache) Put(modelName, text string, vector []float32) error {
+	if len(vector) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("create embedding cache dir: %w", err)
+	}
+
+	path := c.entryPath(modelName, text)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, encodeCachedVector(vector), 0o644); err != nil {
+		return fmt.Errorf("write embedding cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("write embedding cache: %w", err)
+	}
+
+	if c.markWrite() {
+		if err := c.Prune(); err != nil {
+			c.logf("embedding cache prune failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// Prune removes least recently used entries until the cache fits within its
+// size limit.
+func (c *FileEmbeddingCache) Prune() error {
+	c.mu.Lock()
+	maxBytes := c.maxBytes
+	c.mu.Unlock()
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read embedding cache dir %s: %w", c.dir, err)
+	}
+
+	type fileInfo struct {
+		path string
+		info os.FileInfo
+	}
+	files := make([]fileInfo, 0, len(entries))
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), embeddingCacheExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo{path: filepath.Join(c.dir, e.Name()), info: info})
+		total += info.Size()
+	}
+	if total <= maxBytes {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("prune embedding cache: remove %s: %w", f.path, err)
+		}
+		total -= f.info.Size()
+	}
+	return nil
+}
+
+func (c *FileEmbeddingCache) markWrite() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes++
+	if c.pruneEvery <= 0 || c.writes >= c.pruneEvery {
+		c.writes = 0
+		return true
+	}
+	return false
+}
+
+func (c *FileEmbeddingCache) entryPath(modelName, text string) string {
+	textHash := sha256.Sum256([]byte(text))
+	key := sha256.Sum256([]byte(modelName + "\x00" + hex.EncodeToString(textHash[:])))
+	return filepath.Join(c.dir, hex.EncodeToString(key[:])+embeddingCacheExt)
+}
+
+func (c *FileEmbeddingCache) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func encodeCachedVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeCachedVector(raw []byte) ([]float32, error) {
+	if len(raw) == 0 || len(raw)%4 != 0 {
+		return nil, errors.New("invalid cached vector length")
+	}
+	vector := make([]float32, len(raw)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return vector, nil
+}
@@ -39,6 +39,10 @@ type EmbeddingWorker struct {
 	// return value. The channel is never closed by EmbeddingWorker.
 	ErrCh chan error
 
+	// Cache is optional; when set, vectors are looked up by model and chunk
+	// text before calling Embedder and newly computed vectors are stored.
+	Cache EmbeddingCache
+
 	// RunOnceFunc, if non‑nil, is invoked by Run instead of the receiver's
 	// own RunOnce method. This hook exists primarily for testing and
 	// allows callers that embed EmbeddingWorker to override the behaviour
@@ -93,30 +97,38 @@ func (w *EmbeddingWorker) RunOnce(ctx context.Context, indexKind string) (int, e
 		labels = append(labels, chunkID)
 	}
 
-	vectors, err := w.Embedder.Embed(ctx, modelName, inputs)
-	if err != nil {
-		// distinguish between transient errors (which we want to retry later)
-		// and permanent failures for which the chunks should be marked as
-		// irrecoverable.  A transient error could be a network timeout,
-		// rate‑limit response, or context cancellation.  We intentionally keep
-		// the interface simple; by returning the error without marking the
-		// chunks as failed they will remain in the pending state and be
-		// re‑fetched on the next cycle.  Permanent errors fall through to the
-		// existing MarkFailed behaviour.
-		if isTransientEmbedError(err) {
-			return 0, err
-		}
-		if mfErr := w.Source.MarkFailed(ctx, labels, err.Error()); mfErr != nil {
-			w.logf("mark failed update error: %v (source error: %v) labels=%v", mfErr, err, labels)
+	// consult the cache first so unchanged chunks (e.g. after a full
+	// reindex) are not sent to the embedding provider again.
+	vectors := make([][]float32, len(validTasks))
+	missing := make([]int, 0, len(validTasks))
+	for idx := range validTasks {
+		if w.Cache != nil {
+			if cached, ok := w.Cache.Get(modelName, inputs[idx]); ok {
+				vectors[idx] = cached
+				continue
+			}
 		}
-		return 0, err
+		missing = append(missing, idx)
 	}
-	if len(vectors) != len(validTasks) {
-		reason := "embedding vector count mismatch"
-		if mfErr := w.Source.MarkFailed(ctx, labels, reason); mfErr != nil {
-			w.logf("mark failed update error: %v (reason: %s) labels=%v", mfErr, reason, labels)
+	if len(missing) > 0 {
+		missingInputs := make([]string, 0, len(missing))
+		missingLabels := make([]uint64, 0, len(missing))
+		for _, idx := range missing {
+			missingInputs = append(missingInputs, inputs[idx])
+			missingLabels = append(missingLabels, labels[idx])
+		}
+		embedded, err := w.embedMissing(ctx, modelName, missingInputs, missingLabels)
+		if err != nil {
+			return 0, err
+		}
+		for i, idx := range missing {
+			vectors[idx] = embedded[i]
+			if w.Cache != nil {
+				if putErr := w.Cache.Put(modelName, inputs[idx], embedded[i]); putErr != nil {
+					w.logf("embedding cache write warning: %v", putErr)
+				}
+			}
 		}
-		return 0, errors.New(reason)
 	}
 
 	for idx := range validTasks {
@@ -168,6 +180,38 @@ func (w *EmbeddingWorker) RunOnce(ctx context.Context, indexKind string) (int, e
 	return len(labels), nil
 }
 
+// embedMissing calls the embedder for inputs that were not served from the
+// cache. On failure the affected labels are marked failed unless the error
+// is transient, in which case they stay pending for the next cycle.
+func (w *EmbeddingWorker) embedMissing(ctx context.Context, modelName string, inputs []string, labels []uint64) ([][]float32, error) {
+	vectors, err := w.Embedder.Embed(ctx, modelName, inputs)
+	if err != nil {
+		// distinguish between transient errors (which we want to retry later)
+		// and permanent failures for which the chunks should be marked as
+		// irrecoverable.  A transient error could be a network timeout,
+		// rate‑limit response, or context cancellation.  We intentionally keep
+		// the interface simple; by returning the error without marking the
+		// chunks as failed they will remain in the pending state and be
+		// re‑fetched on the next cycle.  Permanent errors fall through to the
+		// existing MarkFailed behaviour.
+		if isTransientEmbedError(err) {
+			return nil, err
+		}
+		if mfErr := w.Source.MarkFailed(ctx, labels, err.Error()); mfErr != nil {
+			w.logf("mark failed update error: %v (source error: %v) labels=%v", mfErr, err, labels)
+		}
+		return nil, err
+	}
+	if len(vectors) != len(inputs) {
+		reason := "embedding vector count mismatch"
+		if mfErr := w.Source.MarkFailed(ctx, labels, reason); mfErr != nil {
+			w.logf("mark failed update error: %v (reason: %s) labels=%v", mfErr, reason, labels)
+		}
+		return nil, errors.New(reason)
+	}
+	return vectors, nil
+}
+
 // Run starts a background loop that periodically calls RunOnce. A small
 // tick interval is used to check for context cancellation and to space
 // invocations; the caller may choose a large interval if they only want to
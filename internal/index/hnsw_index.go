@@ -1,6 +1,7 @@
 package index
 
 import (
+	"bytes"
 	"encoding/gob"
 	"errors"
 	"log"
@@ -11,10 +12,26 @@ import (
 	"sync/atomic"
 )
 
+// IndexHeader records how the vectors in an index were produced. It is
+// persisted alongside the vectors so a restart can detect that the configured
+// embedding model no longer matches the one the index was built with.
+type IndexHeader struct {
+	Model string
+	Dim   int
+}
+
+// persistedIndex is the on-disk layout written by Save. Files written by
+// older builds contain a bare vector map and are still accepted by Load.
+type persistedIndex struct {
+	Header  IndexHeader
+	Vectors map[uint64][]float32
+}
+
 type HNSWIndex struct {
 	path    string
 	mu      sync.RWMutex
 	vectors map[uint64][]float32
+	header  IndexHeader
 
 	// Logger is optional; if non-nil its Printf method will be used for
 	// informational messages. When nil the standard library's log package
@@ -61,10 +78,87 @@ func (i *HNSWIndex) Add(label uint64, vector []float32) error {
 	copied := make([]float32, len(vector))
 	copy(copied, vector)
 	i.vectors[label] = copied
+	if i.header.Dim == 0 {
+		i.header.Dim = len(vector)
+	}
 	return nil
 }
 
+// Header returns the model and dimension recorded for the index.
+func (i *HNSWIndex) Header() IndexHeader {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.header
+}
+
+// SetModel records the embedding model that produced (or will produce) the
+// vectors held by the index.
+func (i *HNSWIndex) SetModel(name string) {
+	i.mu.Lock()
+	i.header.Model = name
+	i.mu.Unlock()
+}
+
+// EmbeddingModel reports the recorded embedding model so query embeddings
+// can be produced with the same model as the stored vectors. It returns an
+// empty string when the model is unknown.
+func (i *HNSWIndex) EmbeddingModel() string {
+	return i.Header().Model
+}
+
+// Len returns the number of vectors currently stored.
+func (i *HNSWIndex) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.vectors)
+}
+
+// Contains reports whether a vector is stored under label.
+func (i *HNSWIndex) Contains(label uint64) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	_, ok := i.vectors[label]
+	return ok
+}
+
+// Vector returns a copy of the vector stored under label.
+func (i *HNSWIndex) Vector(label uint64) ([]float32, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	vec, ok := i.vectors[label]
+	if !ok {
+		return nil, false
+	}
+	return append([]float32(nil), vec...), true
+}
+
+// ReplaceWith atomically swaps the contents of i for those of other. Readers
+// observe either the old or the new vectors, never a mix. other must not be
+// written to after the call because the two indices share storage.
+func (i *HNSWIndex) ReplaceWith(other *HNSWIndex) {
+	if other == nil || other == i {
+		return
+	}
+	other.mu.RLock()
+	vectors := other.vectors
+	header := other.header
+	other.mu.RUnlock()
+
+	i.mu.Lock()
+	i.vectors = vectors
+	i.header = header
+	i.mu.Unlock()
+}
+
 func (i *HNSWIndex) Search(vector []float32, k int) ([]uint64, []float32, error) {
+	return i.SearchFiltered(vector, k, nil)
+}
+
+// SearchFiltered is Search restricted to labels for which allow returns
+// true; a nil allow admits every label. Labels are filtered before scoring,
+// so selective filters cost less than an unfiltered search and always fill
+// k when enough labels match.
+func (i *HNSWIndex) SearchFiltered(vector []float32, k int, allow func(label uint64) bool) ([]uint64, []float32, error) {
 	if len(vector) == 0 {
 		return nil, nil, errors.New("query vector cannot be empty")
 	}
@@ -98,6 +192,9 @@ func (i *HNSWIndex) Search(vector []float32, k int) ([]uint64, []float32, error)
 	i.mu.RLock()
 	// collect matching candidates while holding the lock
 	for label, cand := range i.vectors {
+		if allow != nil && !allow(label) {
+			continue
+		}
 		if len(cand) != len(vector) {
 			mismatches = append(mismatches, mismatch{label, len(cand), len(vector)})
 			if i.Metrics != nil {
@@ -189,12 +286,13 @@ func (i *HNSWIndex) Save(path string) error {
 		copy(copied, v)
 		snapshot[k] = copied
 	}
+	header := i.header
 	i.mu.RUnlock()
 
 	// perform encoding and all file I/O on the snapshot without holding
 	// any locks. preserve existing cleanup semantics.
 	enc := gob.NewEncoder(file)
-	err = enc.Encode(snapshot)
+	err = enc.Encode(persistedIndex{Header: header, Vectors: snapshot})
 	if err != nil {
 		closeErr := file.Close()
 		_ = os.Remove(tmpPath)
@@ -224,23 +322,36 @@ func (i *HNSWIndex) Load(path string) error {
 		return errors.New("path is required")
 	}
 
-	file, err := os.Open(path)
+	raw, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil
 		}
 		return err
 	}
-	defer func() { _ = file.Close() }()
 
-	loaded := make(map[uint64][]float32)
-	dec := gob.NewDecoder(file)
-	if err := dec.Decode(&loaded); err != nil {
-		return err
+	var loaded persistedIndex
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&loaded); err != nil {
+		// fall back to the legacy headerless format: a bare vector map.
+		legacy := make(map[uint64][]float32)
+		if legacyErr := gob.NewDecoder(bytes.NewReader(raw)).Decode(&legacy); legacyErr != nil {
+			return errors.Join(err, legacyErr)
+		}
+		loaded = persistedIndex{Vectors: legacy}
+	}
+	if loaded.Vectors == nil {
+		loaded.Vectors = make(map[uint64][]float32)
+	}
+	if loaded.Header.Dim == 0 {
+		for _, v := range loaded.Vectors {
+			loaded.Header.Dim = len(v)
+			break
+		}
 	}
 
 	i.mu.Lock()
-	i.vectors = loaded
+	i.vectors = loaded.Vectors
+	i.header = loaded.Header
 	i.mu.Unlock()
 	return nil
 }
@@ -0,0 +1,256 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelSettingsStore is the subset of the metadata store used to record which
+// embedding model built each index and to queue chunks for re-embedding.
+type ModelSettingsStore interface {
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+	ResetEmbeddingStatus(ctx context.Context, indexKind string) (int64, error)
+}
+
+// ModelSettingKey returns the settings key holding the embedding model name
+// recorded for indexKind.
+func ModelSettingKey(indexKind string) string {
+	return "embed_" + indexKind + "_model"
+}
+
+// ModelRecordedSettingKey returns the settings key marking that the model
+// under ModelSettingKey was written from an index header. The store seeds
+// the model key with a default, so without the marker its value says
+// nothing about the vectors on disk.
+func ModelRecordedSettingKey(indexKind string) string {
+	return "embed_" + indexKind + "_model_recorded"
+}
+
+// DimSettingKey returns the settings key holding the embedding dimension
+// recorded for indexKind.
+func DimSettingKey(indexKind string) string {
+	return "embed_" + indexKind + "_dim"
+}
+
+// ShadowIndex wraps a live HNSWIndex so it can be rebuilt without interrupting
+// queries. Outside a rebuild every call goes straight to the live index. While
+// a rebuild is in progress Add writes to a separate shadow index and Search
+// keeps answering from the live one; Swap then replaces the live contents with
+// the shadow in a single step.
+type ShadowIndex struct {
+	live *HNSWIndex
+
+	mu     sync.RWMutex
+	shadow *HNSWIndex
+}
+
+// NewShadowIndex returns a ShadowIndex serving from live.
+func NewShadowIndex(live *HNSWIndex) *ShadowIndex {
+	return &ShadowIndex{live: live}
+}
+
+// Live returns the index currently serving queries.
+func (s *ShadowIndex) Live() *HNSWIndex {
+	return s.live
+}
+
+// Rebuilding reports whether writes are currently redirected to a shadow.
+func (s *ShadowIndex) Rebuilding() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shadow != nil
+}
+
+// BeginRebuild starts redirecting writes to a fresh shadow index whose
+// vectors will be produced by modelName. Calling it during a rebuild discards
+// the previous shadow.
+func (s *ShadowIndex) BeginRebuild(modelName string) {
+	shadow := NewHNSWIndex("")
+	shadow.SetModel(modelName)
+	s.mu.Lock()
+	s.shadow = shadow
+	s.mu.Unlock()
+}
+
+// Swap replaces the live index contents with the shadow and ends the
+// rebuild. It returns the header of the new live index and false when no
+// rebuild was in progress.
+func (s *ShadowIndex) Swap() (IndexHeader, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shadow == nil {
+		return s.live.Header(), false
+	}
+	s.live.ReplaceWith(s.shadow)
+	s.shadow = nil
+	return s.live.Header(), true
+}
+
+func (s *ShadowIndex) Add(label uint64, vector []float32) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.shadow != nil {
+		return s.shadow.Add(label, vector)
+	}
+	return s.live.Add(label, vector)
+}
+
+func (s *ShadowIndex) Search(vector []float32, k int) ([]uint64, []float32, error) {
+	return s.live.Search(vector, k)
+}
+
+// EmbeddingModel reports the model of the live index, which is the model
+// queries must be embedded with until the swap.
+func (s *ShadowIndex) EmbeddingModel() string {
+	return s.live.EmbeddingModel()
+}
+
+func (s *ShadowIndex) Save(path string) error {
+	return s.live.Save(path)
+}
+
+func (s *ShadowIndex) Load(path string) error {
+	return s.live.Load(path)
+}
+
+func (s *ShadowIndex) Close() error {
+	return s.live.Close()
+}
+
+// ReconcileEmbeddingModel compares the model recorded for the index of
+// indexKind against configuredModel. When they agree (or the index is empty)
+// the configured model is recorded and nothing else happens. On mismatch the
+// live index keeps its original model so queries stay consistent with the
+// stored vectors, every chunk of indexKind is marked pending, and target
+// begins a rebuild into a shadow index. The returned bool reports whether a
+// rebuild was started.
+//
+// The recorded model is read from the index header, falling back to the store
+// settings for index files written before headers existed. Settings count
+// only when recordEmbeddingModel wrote them; a headerless index without that
+// record has an unknown model and adopts configuredModel. The shadow index is
+// held in memory only and the live header keeps the old model until the
+// swap, so restarting mid-rebuild detects the mismatch again and re-embeds
+// every chunk of indexKind from scratch; only the embedding cache saves the
+// provider calls made before the restart.
+func ReconcileEmbeddingModel(ctx context.Context, st ModelSettingsStore, indexKind string, target *ShadowIndex, configuredModel string) (bool, error) {
+	if st == nil || target == nil {
+		return false, errors.New("store and index are required")
+	}
+	configuredModel = strings.TrimSpace(configuredModel)
+	live := target.Live()
+	header := live.Header()
+
+	recorded := strings.TrimSpace(header.Model)
+	if recorded == "" && live.Len() > 0 {
+		value, err := recordedModelSetting(ctx, st, indexKind)
+		if err != nil {
+			return false, err
+		}
+		recorded = value
+	}
+
+	dimMismatch := false
+	if header.Dim > 0 {
+		value, err := st.GetSetting(ctx, DimSettingKey(indexKind))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+		if dim, convErr := strconv.Atoi(strings.TrimSpace(value)); convErr == nil && dim > 0 && dim != header.Dim {
+			dimMismatch = true
+		}
+	}
+
+	if live.Len() == 0 || recorded == "" || (recorded == configuredModel && !dimMismatch) {
+		live.SetModel(configuredModel)
+		return false, recordEmbeddingModel(ctx, st, indexKind, live.Header())
+	}
+
+	live.SetModel(recorded)
+	if _, err := st.ResetEmbeddingStatus(ctx, indexKind); err != nil {
+		return false, fmt.Errorf("reset %s chunks for re-embedding: %w", indexKind, err)
+	}
+	target.BeginRebuild(configuredModel)
+	return true, nil
+}
+
+// CompleteRebuildWhenDrained polls source every interval until no chunk of
+// indexKind is pending, then swaps the shadow index into place, persists it
+// and records the new model and dimension. It returns nil immediately when
+// target is not rebuilding.
+func CompleteRebuildWhenDrained(ctx context.Context, st ModelSettingsStore, source ChunkSource, indexKind string, target *ShadowIndex, interval time.Duration) error {
+	if st == nil || source == nil || target == nil {
+		return errors.New("store, source and index are required")
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for target.Rebuilding() {
+		pending, err := source.NextPending(ctx, 1, indexKind)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			header, swapped := target.Swap()
+			if !swapped {
+				return nil
+			}
+			if live := target.Live(); live.path != "" {
+				if err := live.Save(""); err != nil {
+					return fmt.Errorf("save rebuilt %s index: %w", indexKind, err)
+				}
+			}
+			return recordEmbeddingModel(ctx, st, indexKind, header)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// recordedModelSetting returns the model recordEmbeddingModel stored for
+// indexKind, or "" when the model setting only holds the seeded default.
+func recordedModelSetting(ctx context.Context, st ModelSettingsStore, indexKind string) (string, error) {
+	marker, err := st.GetSetting(ctx, ModelRecordedSettingKey(indexKind))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && strings.TrimSpace(marker) == "") {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	value, err := st.GetSetting(ctx, ModelSettingKey(indexKind))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func recordEmbeddingModel(ctx context.Context, st ModelSettingsStore, indexKind string, header IndexHeader) error {
+	if header.Model != "" {
+		if err := st.SetSetting(ctx, ModelSettingKey(indexKind), header.Model); err != nil {
+			return err
+		}
+		if err := st.SetSetting(ctx, ModelRecordedSettingKey(indexKind), "true"); err != nil {
+			return err
+		}
+	}
+	if header.Dim > 0 {
+		if err := st.SetSetting(ctx, DimSettingKey(indexKind), strconv.Itoa(header.Dim)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -53,3 +53,80 @@ func ClassifyDocType(relPath string) string {
 		return "binary_ignored"
 	}
 }
+
+// codeLanguageExtensions maps the code languages recognized by search
+// filters to the file extensions that identify them.
+var codeLanguageExtensions = map[string][]string{
+	"go":         {".go"},
+	"rust":       {".rs"},
+	"python":     {".py"},
+	"javascript": {".js", ".jsx", ".mjs", ".cjs"},
+	"typescript": {".ts", ".tsx"},
+	"java":       {".java"},
+	"c":          {".c", ".h"},
+	"cpp":        {".cc", ".cpp", ".cxx", ".hpp", ".hh"},
+	"csharp":     {".cs"},
+	"ruby":       {".rb"},
+	"php":        {".php"},
+	"swift":      {".swift"},
+	"kotlin":     {".kt", ".kts"},
+	"scala":      {".scala"},
+	"shell":      {".sh", ".bash", ".zsh"},
+	"sql":        {".sql"},
+}
+
+// codeLanguageAliases accepts common short names for languages.
+var codeLanguageAliases = map[string]string{
+	"golang": "go",
+	"rs":     "rust",
+	"py":     "python",
+	"js":     "javascript",
+	"ts":     "typescript",
+	"c++":    "cpp",
+	"cs":     "csharp",
+	"c#":     "csharp",
+	"rb":     "ruby",
+	"kt":     "kotlin",
+	"sh":     "shell",
+	"bash":   "shell",
+}
+
+// NormalizeCodeLanguage returns the canonical name of a language or alias,
+// or "" when the language is not recognized.
+func NormalizeCodeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if canonical, ok := codeLanguageAliases[language]; ok {
+		language = canonical
+	}
+	if _, ok := codeLanguageExtensions[language]; !ok {
+		return ""
+	}
+	return language
+}
+
+// CodeLanguage returns the language of a source file judged by its
+// extension, or "" for files that are not recognized as code.
+func CodeLanguage(relPath string) string {
+	ext := strings.ToLower(filepath.Ext(relPath))
+	if ext == "" {
+		return ""
+	}
+	for language, exts := range codeLanguageExtensions {
+		for _, candidate := range exts {
+			if candidate == ext {
+				return language
+			}
+		}
+	}
+	return ""
+}
+
+// CodeLanguageExtensions returns the extensions identifying language, which
+// may be an alias. It returns nil for unrecognized languages.
+func CodeLanguageExtensions(language string) []string {
+	exts := codeLanguageExtensions[NormalizeCodeLanguage(language)]
+	if exts == nil {
+		return nil
+	}
+	return append([]string(nil), exts...)
+}
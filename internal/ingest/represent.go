@@ -131,6 +131,13 @@ func (rg *RepresentationGenerator) GenerateRawTextFromContent(ctx context.Contex
 		if err := rg.upsertChunksForRepresentationWithStore(ctx, tx, repID, indexKindForDocType(doc.DocType), segments); err != nil {
 			return err
 		}
+		// symbols are replaced in the same transaction so they never point
+		// at lines of an older version of the file.
+		if symbolStore, ok := tx.(model.SymbolStore); ok && doc.DocType == "code" && doc.DocID > 0 {
+			if err := symbolStore.ReplaceSymbols(ctx, doc.DocID, ExtractSymbols(doc.RelPath, normalizedContent)); err != nil {
+				return fmt.Errorf("replace symbols: %w", err)
+			}
+		}
 		return nil
 	})
 }
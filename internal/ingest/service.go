@@ -83,6 +83,11 @@ type Service struct {
 	// convenient for tests.
 	ocrCacheWrites     int
 	ocrCachePruneEvery int
+
+	// optional callback told about documents added, re-processed or
+	// deleted by a scan. Set before Run; it is called synchronously from
+	// the scan goroutine.
+	onDocumentChange func(model.DocumentChange)
 }
 
 // ErrTranscriptProviderFailure marks failures originating from the transcript
@@ -147,6 +152,32 @@ func (s *Service) SetIndexingState(state *appstate.IndexingState) {
 	s.indexingState = state
 }
 
+// SetDocumentChangeHandler registers fn to be told about every document a
+// scan adds, re-processes or deletes.
+func (s *Service) SetDocumentChangeHandler(fn func(model.DocumentChange)) {
+	s.onDocumentChange = fn
+}
+
+func (s *Service) notifyDocumentChange(relPath, kind string) {
+	if s.onDocumentChange != nil && kind != "" {
+		s.onDocumentChange(model.DocumentChange{RelPath: relPath, Kind: kind})
+	}
+}
+
+// documentChangeKind classifies an upsert: a path that was unknown or
+// deleted is added, and a known path whose content is re-processed is
+// updated. Anything else is not a change.
+func documentChangeKind(existing model.Document, needsProcessing bool) string {
+	switch {
+	case existing.RelPath == "" || existing.Deleted:
+		return model.DocumentAdded
+	case needsProcessing:
+		return model.DocumentUpdated
+	default:
+		return ""
+	}
+}
+
 func (s *Service) SetOCR(ocr model.OCR) {
 	s.ocr = ocr
 }
@@ -311,6 +342,7 @@ func (s *Service) processDocument(ctx context.Context, f DiscoveredFile, secretP
 	if err := s.store.UpsertDocument(ctx, doc); err != nil {
 		return fmt.Errorf("upsert document: %w", err)
 	}
+	defer s.notifyDocumentChange(doc.RelPath, documentChangeKind(existingDoc, needsProcessing))
 
 	// after upsert we need the persisted DocID for downstream
 	// representation creation.  The store implementation assigns the ID,
@@ -464,6 +496,7 @@ func (s *Service) processDocumentFromContent(ctx context.Context, relPath string
 	if err := s.store.UpsertDocument(ctx, doc); err != nil {
 		return fmt.Errorf("upsert document: %w", err)
 	}
+	defer s.notifyDocumentChange(relPath, documentChangeKind(existingDoc, needsProcessing))
 	if updated, err := s.store.GetDocumentByPath(ctx, relPath); err == nil {
 		doc.DocID = updated.DocID
 	} else if !isNotFoundError(err) {
@@ -572,6 +605,7 @@ func (s *Service) markMissingAsDeleted(ctx context.Context, existing, seen map[s
 			continue
 		}
 		s.addDeleted(1)
+		s.notifyDocumentChange(relPath, model.DocumentDeleted)
 	}
 	return nil
 }
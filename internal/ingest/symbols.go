@@ -0,0 +1,644 @@
+package ingest
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dir2mcp/internal/model"
+)
+
+const (
+	// maxSymbolsPerFile bounds the symbols recorded for one file so minified
+	// or generated sources cannot flood the table.
+	maxSymbolsPerFile = 5000
+	// maxSymbolSignatureRunes truncates the stored declaration line.
+	maxSymbolSignatureRunes = 200
+	// maxSymbolHeaderLines is how far a declaration header may run before
+	// its opening brace, e.g. a multi-line parameter list.
+	maxSymbolHeaderLines = 8
+	// maxSymbolStatementLines bounds the search for the semicolon ending a
+	// SQL statement.
+	maxSymbolStatementLines = 500
+)
+
+// blockStyle says how the end of a heuristically tagged declaration is found.
+type blockStyle int
+
+const (
+	// blockBraces ends at the brace matching the header's opening brace, or
+	// at the header line when the declaration has no body.
+	blockBraces blockStyle = iota
+	// blockIndent ends before the next line indented no deeper than the
+	// declaration (Python).
+	blockIndent
+	// blockIndentEnd is blockIndent plus a closing "end" line (Ruby).
+	blockIndentEnd
+	// blockStatement ends at the first semicolon (SQL).
+	blockStatement
+)
+
+// symbolKindImpl marks Rust impl blocks. They only provide a container for
+// the functions inside them and are never stored.
+const symbolKindImpl = "impl"
+
+// tagRule is one heuristic declaration pattern. The pattern's "name" group
+// captures the symbol name.
+type tagRule struct {
+	re   *regexp.Regexp
+	kind string
+	// member rules only produce symbols inside a container, which keeps
+	// loose patterns such as method signatures from matching statements.
+	member bool
+	// requireBody skips matches without a brace body, e.g. C prototypes.
+	requireBody bool
+}
+
+type tagLanguage struct {
+	rules []tagRule
+	block blockStyle
+}
+
+func rule(pattern, kind string) tagRule {
+	return tagRule{re: regexp.MustCompile(pattern), kind: kind}
+}
+
+func memberRule(pattern, kind string) tagRule {
+	r := rule(pattern, kind)
+	r.member = true
+	return r
+}
+
+func bodyRule(pattern, kind string) tagRule {
+	r := rule(pattern, kind)
+	r.requireBody = true
+	return r
+}
+
+var (
+	jsRules = []tagRule{
+		rule(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[\w$]+)`, model.SymbolKindFunction),
+		rule(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`, model.SymbolKindClass),
+		rule(`^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[\w$]+\s*=>)`, model.SymbolKindFunction),
+		memberRule(`^\s+(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?(?P<name>[\w$]+)\s*(?:<[^>]*>)?\s*\([^;]*\)\s*(?::[^{;]*)?\{\s*$`, model.SymbolKindMethod),
+	}
+	tsRules = append([]tagRule{
+		rule(`^\s*(?:export\s+)?(?:declare\s+)?interface\s+(?P<name>[\w$]+)`, model.SymbolKindInterface),
+		rule(`^\s*(?:export\s+)?(?:declare\s+)?type\s+(?P<name>[\w$]+)\s*(?:<[^=]*>)?\s*=`, model.SymbolKindType),
+		rule(`^\s*(?:export\s+)?(?:declare\s+)?(?:const\s+)?enum\s+(?P<name>[\w$]+)`, model.SymbolKindEnum),
+		rule(`^\s*(?:export\s+)?(?:declare\s+)?namespace\s+(?P<name>[\w$.]+)`, model.SymbolKindModule),
+	}, jsRules...)
+
+	// jvmModifiers covers the declaration modifiers of Java and C#.
+	jvmModifiers = `(?:(?:public|private|protected|internal|static|final|abstract|sealed|partial|virtual|override|async|synchronized|native|extern|unsafe|new|default|readonly|strictfp)\s+)*`
+
+	tagLanguages = map[string]tagLanguage{
+		"python": {block: blockIndent, rules: []tagRule{
+			rule(`^\s*class\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*(?:async\s+)?def\s+(?P<name>\w+)`, model.SymbolKindFunction),
+		}},
+		"ruby": {block: blockIndentEnd, rules: []tagRule{
+			rule(`^\s*class\s+(?P<name>[A-Z][\w:]*)`, model.SymbolKindClass),
+			rule(`^\s*module\s+(?P<name>[A-Z][\w:]*)`, model.SymbolKindModule),
+			rule(`^\s*def\s+(?:self\.)?(?P<name>[\w]+[?!=]?)`, model.SymbolKindFunction),
+		}},
+		"javascript": {block: blockBraces, rules: jsRules},
+		"typescript": {block: blockBraces, rules: tsRules},
+		"java": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*`+jvmModifiers+`class\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*`+jvmModifiers+`(?:@)?interface\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*`+jvmModifiers+`enum\s+(?P<name>\w+)`, model.SymbolKindEnum),
+			rule(`^\s*`+jvmModifiers+`record\s+(?P<name>\w+)`, model.SymbolKindClass),
+			memberRule(`^\s*`+jvmModifiers+`(?:<[^>]*>\s*)?[\w<>\[\],.?]+\s+(?P<name>\w+)\s*\([^;]*$`, model.SymbolKindMethod),
+		}},
+		"csharp": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*`+jvmModifiers+`class\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*`+jvmModifiers+`interface\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*`+jvmModifiers+`struct\s+(?P<name>\w+)`, model.SymbolKindStruct),
+			rule(`^\s*`+jvmModifiers+`enum\s+(?P<name>\w+)`, model.SymbolKindEnum),
+			rule(`^\s*`+jvmModifiers+`record\s+(?:class\s+|struct\s+)?(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*namespace\s+(?P<name>[\w.]+)`, model.SymbolKindModule),
+			memberRule(`^\s*`+jvmModifiers+`[\w<>\[\],.?]+\s+(?P<name>\w+)\s*(?:<[^>]*>)?\s*\([^;]*$`, model.SymbolKindMethod),
+		}},
+		"kotlin": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*(?:(?:public|private|protected|internal|abstract|open|sealed|data|enum|annotation|inner|value)\s+)*class\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*(?:(?:public|private|protected|internal|sealed|fun)\s+)*interface\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*(?:(?:public|private|protected|internal|companion|data)\s+)*object\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*(?:(?:public|private|protected|internal|override|open|abstract|suspend|inline|operator|infix|tailrec|external)\s+)*fun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?(?P<name>\w+)`, model.SymbolKindFunction),
+		}},
+		"scala": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*(?:(?:abstract|final|sealed|case|private|protected|implicit)\s+)*class\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*(?:(?:sealed|private|protected)\s+)*trait\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*(?:(?:case|private|protected)\s+)*object\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*(?:(?:override|private|protected|final|implicit|inline)\s+)*def\s+(?P<name>\w+)`, model.SymbolKindFunction),
+		}},
+		"swift": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*(?:(?:public|private|fileprivate|internal|open|final)\s+)*class\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*(?:(?:public|private|fileprivate|internal)\s+)*struct\s+(?P<name>\w+)`, model.SymbolKindStruct),
+			rule(`^\s*(?:(?:public|private|fileprivate|internal)\s+)*protocol\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*(?:(?:public|private|fileprivate|internal|indirect)\s+)*enum\s+(?P<name>\w+)`, model.SymbolKindEnum),
+			rule(`^\s*(?:(?:public|private|fileprivate|internal|open|static|class|final|override|mutating)\s+)*func\s+(?P<name>\w+)`, model.SymbolKindFunction),
+		}},
+		"rust": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:(?:const|async|unsafe|extern\s+"[^"]*")\s+)*fn\s+(?P<name>\w+)`, model.SymbolKindFunction),
+			rule(`^\s*(?:pub(?:\([^)]*\))?\s+)?struct\s+(?P<name>\w+)`, model.SymbolKindStruct),
+			rule(`^\s*(?:pub(?:\([^)]*\))?\s+)?enum\s+(?P<name>\w+)`, model.SymbolKindEnum),
+			rule(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(?P<name>\w+)`, model.SymbolKindModule),
+			rule(`^\s*(?:pub(?:\([^)]*\))?\s+)?type\s+(?P<name>\w+)`, model.SymbolKindType),
+			rule(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+(?:mut\s+)?(?P<name>[A-Z_][A-Z0-9_]*)\s*:`, model.SymbolKindConst),
+			rule(`^\s*macro_rules!\s*(?P<name>\w+)`, model.SymbolKindFunction),
+			rule(`^\s*(?:unsafe\s+)?impl(?:\s*<[^>]*>)?\s+(?:[\w:<>, ]+\s+for\s+)?(?P<name>\w+)`, symbolKindImpl),
+		}},
+		"c": {block: blockBraces, rules: []tagRule{
+			bodyRule(`^\s*(?:typedef\s+)?struct\s+(?P<name>\w+)`, model.SymbolKindStruct),
+			bodyRule(`^\s*(?:typedef\s+)?union\s+(?P<name>\w+)`, model.SymbolKindStruct),
+			bodyRule(`^\s*(?:typedef\s+)?enum\s+(?P<name>\w+)`, model.SymbolKindEnum),
+			rule(`^\s*#\s*define\s+(?P<name>\w+)`, model.SymbolKindConst),
+			bodyRule(`^[A-Za-z_][\w\s\*]*?[\s\*](?P<name>[A-Za-z_]\w*)\s*\([^;]*$`, model.SymbolKindFunction),
+			// return type on the previous line (GNU style).
+			bodyRule(`^(?P<name>[A-Za-z_]\w*)\s*\([^;]*$`, model.SymbolKindFunction),
+		}},
+		"cpp": {block: blockBraces, rules: []tagRule{
+			bodyRule(`^\s*(?:template\s*<[^>]*>\s*)?(?:class|struct)\s+(?:\w+\s+)?(?P<name>\w+)\s*(?:final\s*)?(?::[^;]*)?(?:\{.*)?$`, model.SymbolKindClass),
+			bodyRule(`^\s*(?:typedef\s+)?union\s+(?P<name>\w+)`, model.SymbolKindStruct),
+			bodyRule(`^\s*enum\s+(?:class\s+|struct\s+)?(?P<name>\w+)`, model.SymbolKindEnum),
+			bodyRule(`^\s*namespace\s+(?P<name>\w+)`, model.SymbolKindModule),
+			rule(`^\s*#\s*define\s+(?P<name>\w+)`, model.SymbolKindConst),
+			bodyRule(`^(?:template\s*<[^>]*>\s*)?[A-Za-z_][\w\s\*&:<>,]*?[\s\*&](?P<name>[A-Za-z_~][\w:~]*)\s*\([^;]*$`, model.SymbolKindFunction),
+			bodyRule(`^(?P<name>[A-Za-z_~][\w:~]*)\s*\([^;]*$`, model.SymbolKindFunction),
+		}},
+		"php": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*(?:(?:abstract|final|readonly)\s+)*class\s+(?P<name>\w+)`, model.SymbolKindClass),
+			rule(`^\s*interface\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*trait\s+(?P<name>\w+)`, model.SymbolKindInterface),
+			rule(`^\s*enum\s+(?P<name>\w+)`, model.SymbolKindEnum),
+			rule(`^\s*(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+&?(?P<name>\w+)`, model.SymbolKindFunction),
+		}},
+		"shell": {block: blockBraces, rules: []tagRule{
+			rule(`^\s*function\s+(?P<name>[\w.:-]+)`, model.SymbolKindFunction),
+			rule(`^\s*(?P<name>[A-Za-z_][\w.:-]*)\s*\(\s*\)`, model.SymbolKindFunction),
+		}},
+		"sql": {block: blockStatement, rules: []tagRule{
+			rule(`(?i)^\s*create\s+(?:or\s+replace\s+)?(?:(?:temp|temporary|unlogged|virtual)\s+)?(?:table|view|materialized\s+view)\s+(?:if\s+not\s+exists\s+)?(?P<name>[\w."`+"`"+`\[\]]+)`, model.SymbolKindTable),
+			rule(`(?i)^\s*create\s+(?:or\s+replace\s+)?(?:function|procedure)\s+(?:if\s+not\s+exists\s+)?(?P<name>[\w."`+"`"+`\[\]]+)`, model.SymbolKindFunction),
+		}},
+	}
+
+	// tagContainerKinds are the kinds whose span makes enclosed functions
+	// methods.
+	tagContainerKinds = map[string]bool{
+		model.SymbolKindClass:     true,
+		model.SymbolKindStruct:    true,
+		model.SymbolKindInterface: true,
+		model.SymbolKindEnum:      true,
+		model.SymbolKindModule:    true,
+		symbolKindImpl:            true,
+	}
+
+	// tagKeywords are control-flow words that loose function and method
+	// patterns would otherwise pick up as names.
+	tagKeywords = map[string]bool{
+		"if": true, "else": true, "for": true, "foreach": true, "while": true, "do": true,
+		"switch": true, "case": true, "catch": true, "try": true, "return": true,
+		"function": true, "new": true, "throw": true, "sizeof": true, "using": true,
+		"lock": true, "fixed": true, "when": true, "with": true, "typeof": true,
+	}
+)
+
+// ExtractSymbols returns the declarations in a code file. Go sources are
+// parsed with go/parser, so their symbols are exact; other languages in the
+// code list of ClassifyDocType use per-language tag patterns whose spans are
+// found by brace matching or indentation. Files in unrecognised languages
+// yield no symbols.
+func ExtractSymbols(relPath string, content []byte) []model.Symbol {
+	language := CodeLanguage(relPath)
+	var symbols []model.Symbol
+	switch {
+	case language == "go":
+		symbols = extractGoSymbols(relPath, content)
+	case language != "":
+		if lang, ok := tagLanguages[language]; ok {
+			symbols = extractTaggedSymbols(string(content), lang)
+		}
+	}
+	if len(symbols) > maxSymbolsPerFile {
+		symbols = symbols[:maxSymbolsPerFile]
+	}
+	for i := range symbols {
+		symbols[i].RelPath = relPath
+		symbols[i].Language = language
+	}
+	return symbols
+}
+
+func extractGoSymbols(relPath string, content []byte) []model.Symbol {
+	fset := token.NewFileSet()
+	// a file with syntax errors still yields the declarations parsed before
+	// the error, which is better than nothing for a half-edited file.
+	file, _ := parser.ParseFile(fset, relPath, content, parser.SkipObjectResolution)
+	if file == nil {
+		return nil
+	}
+	lines := strings.Split(string(content), "\n")
+	newSymbol := func(name, kind, container string, node ast.Node) model.Symbol {
+		start := fset.Position(node.Pos()).Line
+		end := fset.Position(node.End()).Line
+		if end < start {
+			end = start
+		}
+		return model.Symbol{
+			Name:      name,
+			Kind:      kind,
+			Container: container,
+			Span:      model.Span{Kind: "lines", StartLine: start, EndLine: end},
+			Signature: symbolSignature(lines, start),
+			Exact:     true,
+		}
+	}
+
+	var symbols []model.Symbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				symbols = append(symbols, newSymbol(d.Name.Name, model.SymbolKindFunction, "", d))
+				continue
+			}
+			symbols = append(symbols, newSymbol(d.Name.Name, model.SymbolKindMethod, goReceiverName(d.Recv.List[0].Type), d))
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				// an unparenthesised declaration spans its keyword too.
+				var node ast.Node = spec
+				if !d.Lparen.IsValid() {
+					node = d
+				}
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					symbols = append(symbols, goTypeSymbols(sp, node, newSymbol)...)
+				case *ast.ValueSpec:
+					kind := model.SymbolKindVar
+					if d.Tok == token.CONST {
+						kind = model.SymbolKindConst
+					}
+					for _, name := range sp.Names {
+						symbols = append(symbols, newSymbol(name.Name, kind, "", node))
+					}
+				}
+			}
+		}
+	}
+	// blank identifiers declare nothing; the parser also uses "_" for names
+	// it could not recover.
+	out := symbols[:0]
+	for _, sym := range symbols {
+		if sym.Name != "_" {
+			out = append(out, sym)
+		}
+	}
+	return out
+}
+
+// goTypeSymbols returns a type declaration and, for structs and interfaces,
+// its fields and methods.
+func goTypeSymbols(spec *ast.TypeSpec, node ast.Node, newSymbol func(name, kind, container string, node ast.Node) model.Symbol) []model.Symbol {
+	typeName := spec.Name.Name
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		out := []model.Symbol{newSymbol(typeName, model.SymbolKindStruct, "", node)}
+		if t.Fields == nil {
+			return out
+		}
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 {
+				// embedded field: named after its type.
+				if name := goReceiverName(field.Type); name != "" {
+					out = append(out, newSymbol(name, model.SymbolKindField, typeName, field))
+				}
+				continue
+			}
+			for _, name := range field.Names {
+				out = append(out, newSymbol(name.Name, model.SymbolKindField, typeName, field))
+			}
+		}
+		return out
+	case *ast.InterfaceType:
+		out := []model.Symbol{newSymbol(typeName, model.SymbolKindInterface, "", node)}
+		if t.Methods == nil {
+			return out
+		}
+		for _, method := range t.Methods.List {
+			for _, name := range method.Names {
+				out = append(out, newSymbol(name.Name, model.SymbolKindMethod, typeName, method))
+			}
+		}
+		return out
+	default:
+		return []model.Symbol{newSymbol(typeName, model.SymbolKindType, "", node)}
+	}
+}
+
+// goReceiverName returns the base type name of a receiver or embedded field
+// expression, dropping pointers, packages and type parameters.
+func goReceiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return goReceiverName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.IndexExpr:
+		return goReceiverName(e.X)
+	case *ast.IndexListExpr:
+		return goReceiverName(e.X)
+	case *ast.ParenExpr:
+		return goReceiverName(e.X)
+	default:
+		return ""
+	}
+}
+
+func extractTaggedSymbols(content string, lang tagLanguage) []model.Symbol {
+	lines := strings.Split(content, "\n")
+	var symbols []model.Symbol
+	var members []model.Symbol
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		for _, r := range lang.rules {
+			m := r.re.FindStringSubmatchIndex(line)
+			if m == nil {
+				continue
+			}
+			nameIdx := r.re.SubexpIndex("name")
+			name := strings.Trim(line[m[2*nameIdx]:m[2*nameIdx+1]], "\"`[]")
+			if name == "" || tagKeywords[strings.ToLower(name)] {
+				continue
+			}
+			end, hasBody := tagBlockEnd(lines, i, m[2*nameIdx+1], lang.block)
+			if r.requireBody && !hasBody {
+				continue
+			}
+			sym := model.Symbol{
+				Name:      name,
+				Kind:      r.kind,
+				Span:      model.Span{Kind: "lines", StartLine: i + 1, EndLine: end + 1},
+				Signature: symbolSignature(lines, i+1),
+			}
+			// C++ out-of-line definitions name their class: Foo::bar.
+			if idx := strings.LastIndex(name, "::"); idx > 0 && r.kind == model.SymbolKindFunction {
+				sym.Container = name[:idx]
+				sym.Name = name[idx+2:]
+				sym.Kind = model.SymbolKindMethod
+			}
+			if r.member {
+				members = append(members, sym)
+			} else {
+				symbols = append(symbols, sym)
+			}
+			break
+		}
+	}
+
+	// innermost containers first so nested classes win over outer ones.
+	var containers []model.Symbol
+	for _, sym := range symbols {
+		if tagContainerKinds[sym.Kind] {
+			containers = append(containers, sym)
+		}
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].Span.EndLine-containers[i].Span.StartLine < containers[j].Span.EndLine-containers[j].Span.StartLine
+	})
+	enclosing := func(sym model.Symbol) (model.Symbol, bool) {
+		for _, c := range containers {
+			if c.Span.StartLine < sym.Span.StartLine && sym.Span.StartLine <= c.Span.EndLine {
+				return c, true
+			}
+		}
+		return model.Symbol{}, false
+	}
+
+	out := make([]model.Symbol, 0, len(symbols)+len(members))
+	for _, sym := range symbols {
+		if sym.Kind == model.SymbolKindFunction && sym.Container == "" {
+			if c, ok := enclosing(sym); ok {
+				sym.Kind = model.SymbolKindMethod
+				sym.Container = c.Name
+			}
+		}
+		if sym.Kind != symbolKindImpl {
+			out = append(out, sym)
+		}
+	}
+	for _, sym := range members {
+		c, ok := enclosing(sym)
+		if !ok || c.Kind == model.SymbolKindModule {
+			continue
+		}
+		sym.Container = c.Name
+		out = append(out, sym)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Span.StartLine < out[j].Span.StartLine })
+	return out
+}
+
+// tagBlockEnd returns the 0-based last line of the declaration starting on
+// line start, whose header continues after byte offset col, and whether the
+// declaration has a body.
+func tagBlockEnd(lines []string, start, col int, style blockStyle) (int, bool) {
+	switch style {
+	case blockStatement:
+		for i := start; i < len(lines) && i < start+maxSymbolStatementLines; i++ {
+			if strings.Contains(lines[i], ";") {
+				return i, true
+			}
+		}
+		return start, false
+	case blockIndent, blockIndentEnd:
+		return indentBlockEnd(lines, start, col, style == blockIndentEnd)
+	default:
+		return braceBlockEnd(lines, start, col)
+	}
+}
+
+// braceBlockEnd finds the body of a brace-delimited declaration. The header
+// may continue onto following lines while a parenthesis is open, or when the
+// next line opens the body ("K&R" style). A semicolon or a line end outside
+// parentheses before any brace means the declaration has no body.
+func braceBlockEnd(lines []string, start, col int) (int, bool) {
+	parens := 0
+	line, pos := start, col
+	for {
+		if line >= len(lines) || line > start+maxSymbolHeaderLines {
+			return start, false
+		}
+		text := lines[line]
+		opened := false
+		for ; pos < len(text); pos++ {
+			switch text[pos] {
+			case '(':
+				parens++
+			case ')':
+				if parens > 0 {
+					parens--
+				}
+			case ';':
+				if parens == 0 {
+					return line, false
+				}
+			case '{':
+				if parens == 0 {
+					opened = true
+				}
+			}
+			if opened {
+				break
+			}
+		}
+		if opened {
+			return matchBraces(lines, line, pos), true
+		}
+		if parens == 0 {
+			next := line + 1
+			if next < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[next]), "{") {
+				line, pos = next, 0
+				continue
+			}
+			return line, false
+		}
+		line, pos = line+1, 0
+	}
+}
+
+// matchBraces returns the line holding the brace that closes the one at
+// lines[line][pos]. Braces inside string literals and comments are ignored
+// on a best-effort basis.
+func matchBraces(lines []string, line, pos int) int {
+	depth := 0
+	inBlockComment := false
+	for i := line; i < len(lines); i++ {
+		text := lines[i]
+		j := 0
+		if i == line {
+			j = pos
+		}
+		var quote byte
+		for ; j < len(text); j++ {
+			c := text[j]
+			switch {
+			case inBlockComment:
+				if c == '*' && j+1 < len(text) && text[j+1] == '/' {
+					inBlockComment = false
+					j++
+				}
+			case quote != 0:
+				if c == '\\' {
+					j++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '`':
+				quote = c
+			case c == '\'' && strings.IndexByte(text[j+1:], '\'') >= 0:
+				// a lone quote is a Rust lifetime or an apostrophe, not a
+				// string.
+				quote = c
+			case c == '/' && j+1 < len(text) && text[j+1] == '/':
+				j = len(text)
+			case c == '/' && j+1 < len(text) && text[j+1] == '*':
+				inBlockComment = true
+				j++
+			case c == '{':
+				depth++
+			case c == '}':
+				depth--
+				if depth == 0 {
+					return i
+				}
+			}
+		}
+		// template literals are the only quotes that span lines.
+		if quote != '`' {
+			quote = 0
+		}
+	}
+	return len(lines) - 1
+}
+
+// indentBlockEnd finds the end of an indentation-delimited declaration: the
+// last line before the next non-blank line indented no deeper than the
+// declaration. A multi-line header is skipped first. With closingEnd a
+// following "end" line at the declaration's depth is included.
+func indentBlockEnd(lines []string, start, col int, closingEnd bool) (int, bool) {
+	indent := indentWidth(lines[start])
+	headerEnd := start
+	parens := 0
+	for i, pos := start, col; i < len(lines) && i <= start+maxSymbolHeaderLines; i, pos = i+1, 0 {
+		for _, c := range lines[i][pos:] {
+			switch c {
+			case '(', '[':
+				parens++
+			case ')', ']':
+				if parens > 0 {
+					parens--
+				}
+			}
+		}
+		headerEnd = i
+		if parens == 0 {
+			break
+		}
+	}
+
+	end := headerEnd
+	i := headerEnd + 1
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentWidth(lines[i]) <= indent {
+			break
+		}
+		end = i
+	}
+	if closingEnd && i < len(lines) && indentWidth(lines[i]) == indent {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "end" || strings.HasPrefix(trimmed, "end ") || strings.HasPrefix(trimmed, "end#") {
+			end = i
+		}
+	}
+	return end, end > headerEnd
+}
+
+func indentWidth(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// symbolSignature returns the trimmed 1-based line of a declaration.
+func symbolSignature(lines []string, line int) string {
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	signature := strings.TrimSpace(strings.TrimSuffix(lines[line-1], "\r"))
+	if runes := []rune(signature); len(runes) > maxSymbolSignatureRunes {
+		signature = string(runes[:maxSymbolSignatureRunes])
+	}
+	return signature
+}
@@ -0,0 +1,300 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"dir2mcp/internal/audit"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+// maxAuditRefs bounds the refs recorded for one response.
+const maxAuditRefs = 200
+
+// WithAuditLog records every JSON-RPC request in logger. The caller owns
+// the logger and closes it after the server stops.
+func WithAuditLog(logger *audit.Logger) ServerOption {
+	return func(s *Server) {
+		s.auditLog = logger
+	}
+}
+
+type auditRecordContextKey struct{}
+
+// auditRecord collects what the request path learns about one request
+// before it is written as an audit entry.
+type auditRecord struct {
+	// audit is set when the record feeds the audit log, which needs the
+	// argument digest and refs that metrics do not.
+	audit      bool
+	method     string
+	session    string
+	token      string
+	tool       string
+	argsDigest string
+	refs       []audit.Ref
+	errorCode  string
+}
+
+func auditRecordFromContext(ctx context.Context) *auditRecord {
+	rec, _ := ctx.Value(auditRecordContextKey{}).(*auditRecord)
+	return rec
+}
+
+// responseErrorCode is the canonical error code of the request: the one the
+// request path noted, else the one sniffed from the response body.
+func (rec *auditRecord) responseErrorCode(aw *auditResponseWriter) string {
+	if rec.errorCode != "" {
+		return rec.errorCode
+	}
+	return aw.errorCode
+}
+
+// beginRequestRecord starts recording r for the audit log and metrics. The
+// returned writer and request replace the originals, and finish writes the
+// entry and observes the request once the response is done.
+func (s *Server) beginRequestRecord(w http.ResponseWriter, r *http.Request, clientIP string) (http.ResponseWriter, *http.Request, func()) {
+	if s.auditLog == nil && s.metrics == nil {
+		return w, r, func() {}
+	}
+	start := time.Now()
+	rec := &auditRecord{audit: s.auditLog != nil}
+	aw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	r = r.WithContext(context.WithValue(r.Context(), auditRecordContextKey{}, rec))
+	return aw, r, func() {
+		latency := time.Since(start)
+		if s.auditLog != nil {
+			s.writeAuditEntry(aw, rec, clientIP, latency)
+		}
+		if s.metrics != nil {
+			s.observeRequestMetrics(aw, rec, latency)
+		}
+	}
+}
+
+func (s *Server) writeAuditEntry(aw *auditResponseWriter, rec *auditRecord, clientIP string, latency time.Duration) {
+	entry := audit.Entry{
+		Session:    rec.session,
+		Token:      rec.token,
+		ClientIP:   clientIP,
+		Method:     rec.method,
+		Tool:       rec.tool,
+		ArgsDigest: rec.argsDigest,
+		Refs:       rec.refs,
+		Status:     "ok",
+		HTTPStatus: aw.status,
+		ErrorCode:  rec.responseErrorCode(aw),
+		LatencyMS:  latency.Milliseconds(),
+	}
+	if entry.Session == "" {
+		// initialize only learns its session from the response.
+		entry.Session = aw.Header().Get(protocol.MCPSessionHeader)
+	}
+	if entry.ErrorCode != "" || aw.status >= http.StatusBadRequest {
+		entry.Status = "error"
+	}
+	if err := s.auditLog.Append(entry); err != nil && s.eventEmitter != nil {
+		s.eventEmitter("warn", "audit_log_write_failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// auditIdentity names the credential behind ctx for the audit log.
+func (s *Server) auditIdentity(ctx context.Context) string {
+	if token := accessTokenFromContext(ctx); token != nil {
+		return token.ID
+	}
+	if strings.EqualFold(s.cfg.AuthMode, "none") {
+		return ""
+	}
+	return "server"
+}
+
+// recordAuditRequest notes the method, claimed session and credential of a
+// parsed request.
+func (s *Server) recordAuditRequest(ctx context.Context, method, sessionID string) {
+	rec := auditRecordFromContext(ctx)
+	if rec == nil {
+		return
+	}
+	rec.method = method
+	rec.session = sessionID
+	rec.token = s.auditIdentity(ctx)
+}
+
+// recordAuditMethod notes the method of a request that may be rejected
+// before recordAuditRequest runs.
+func recordAuditMethod(ctx context.Context, method string) {
+	if rec := auditRecordFromContext(ctx); rec != nil {
+		rec.method = method
+	}
+}
+
+// recordAuditToolCall notes the tool, a digest of its arguments and the
+// files its result exposed.
+func recordAuditToolCall(ctx context.Context, params toolsCallParams, result toolCallResult, rpcErr *rpcError) {
+	rec := auditRecordFromContext(ctx)
+	if rec == nil {
+		return
+	}
+	rec.tool = params.Name
+	if rec.audit && params.Arguments != nil {
+		// encoding/json sorts map keys, so equal arguments share a digest.
+		if raw, err := json.Marshal(params.Arguments); err == nil {
+			rec.argsDigest = audit.Digest(raw)
+		}
+	}
+	if rpcErr != nil {
+		if rpcErr.Data != nil {
+			rec.errorCode = rpcErr.Data.Code
+		}
+		return
+	}
+	if !result.IsError && !rec.audit {
+		return
+	}
+	raw, err := json.Marshal(result.StructuredContent)
+	if err != nil {
+		return
+	}
+	var structured interface{}
+	if err := json.Unmarshal(raw, &structured); err != nil {
+		return
+	}
+	if result.IsError {
+		if m, ok := structured.(map[string]interface{}); ok {
+			if toolErr, ok := m["error"].(map[string]interface{}); ok {
+				rec.errorCode, _ = toolErr["code"].(string)
+			}
+		}
+		return
+	}
+	rec.refs = collectAuditRefs(structured, rec.refs)
+}
+
+// recordAuditRef notes a file, and the span of it, that resources/read or
+// prompts/get returned.
+func recordAuditRef(ctx context.Context, relPath string, span model.Span) {
+	rec := auditRecordFromContext(ctx)
+	if rec == nil || !rec.audit || len(rec.refs) >= maxAuditRefs {
+		return
+	}
+	ref := audit.Ref{RelPath: relPath}
+	if span.Kind != "" {
+		ref.Span = &audit.Span{
+			Kind:      span.Kind,
+			StartLine: span.StartLine,
+			EndLine:   span.EndLine,
+			Page:      span.Page,
+			StartMS:   span.StartMS,
+			EndMS:     span.EndMS,
+		}
+	}
+	rec.refs = append(rec.refs, ref)
+}
+
+// collectAuditRefs gathers every object with a rel_path from a decoded
+// tool result, with its span when it has one.
+func collectAuditRefs(value interface{}, refs []audit.Ref) []audit.Ref {
+	if len(refs) >= maxAuditRefs {
+		return refs
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if relPath, ok := v["rel_path"].(string); ok && relPath != "" {
+			ref := audit.Ref{RelPath: relPath}
+			if span, ok := v["span"].(map[string]interface{}); ok {
+				ref.Span = auditSpan(span)
+			}
+			refs = append(refs, ref)
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			if key != "span" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			refs = collectAuditRefs(v[key], refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			refs = collectAuditRefs(child, refs)
+		}
+	}
+	return refs
+}
+
+func auditSpan(span map[string]interface{}) *audit.Span {
+	number := func(key string) int {
+		n, _ := span[key].(float64)
+		return int(n)
+	}
+	kind, _ := span["kind"].(string)
+	return &audit.Span{
+		Kind:      kind,
+		StartLine: number("start_line"),
+		EndLine:   number("end_line"),
+		Page:      number("page"),
+		StartMS:   number("start_ms"),
+		EndMS:     number("end_ms"),
+	}
+}
+
+// auditResponseWriter captures the status and, for plain JSON responses,
+// the canonical error code of a response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	errorCode   string
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+	}
+	if w.errorCode == "" && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") && isRPCErrorBody(p) {
+		var response struct {
+			Error *rpcError `json:"error"`
+		}
+		if json.Unmarshal(p, &response) == nil && response.Error != nil {
+			w.errorCode = "JSONRPC_ERROR"
+			if response.Error.Data != nil && response.Error.Data.Code != "" {
+				w.errorCode = response.Error.Data.Code
+			}
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// isRPCErrorBody cheaply tells error responses from results, which may be
+// large, by looking at the keys that follow "id".
+func isRPCErrorBody(p []byte) bool {
+	head := p
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(head, []byte(`"error":`)) && !bytes.Contains(head, []byte(`"result":`))
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// progress streaming keeps working.
+func (w *auditResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
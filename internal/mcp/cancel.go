@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"dir2mcp/internal/protocol"
+)
+
+// rpcCodeRequestCancelled answers a request the client cancelled.
+const rpcCodeRequestCancelled = -32800
+
+// errRequestCancelled is the context cause of a request cancelled with
+// notifications/cancelled.
+var errRequestCancelled = errors.New("request cancelled by client")
+
+// sessionRequests holds the cancel functions of one session's requests in
+// flight, keyed by request id. It is owned by the session's sessionInfo.
+type sessionRequests struct {
+	mu   sync.Mutex
+	byID map[string]*inflightRequest
+}
+
+type inflightRequest struct {
+	cancel context.CancelCauseFunc
+}
+
+func newSessionRequests() *sessionRequests {
+	return &sessionRequests{byID: make(map[string]*inflightRequest)}
+}
+
+// track returns a context for request id that notifications/cancelled can
+// end. done must be called once the request has been answered.
+func (r *sessionRequests) track(ctx context.Context, id interface{}) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := requestIDKey(id)
+	request := &inflightRequest{cancel: cancel}
+	r.mu.Lock()
+	r.byID[key] = request
+	r.mu.Unlock()
+	return ctx, func() {
+		r.mu.Lock()
+		// a reused id may have replaced this entry.
+		if r.byID[key] == request {
+			delete(r.byID, key)
+		}
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel ends request id if it is still in flight.
+func (r *sessionRequests) cancel(id interface{}) bool {
+	r.mu.Lock()
+	request, ok := r.byID[requestIDKey(id)]
+	r.mu.Unlock()
+	if ok {
+		request.cancel(errRequestCancelled)
+	}
+	return ok
+}
+
+// requestIDKey keeps the string id "1" apart from the number 1.
+func requestIDKey(id interface{}) string {
+	return fmt.Sprintf("%T:%v", id, id)
+}
+
+func (s *Server) requestsForSession(sessionID string) *sessionRequests {
+	if sessionID == "" {
+		return nil
+	}
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	return s.sessions[sessionID].requests
+}
+
+// trackRequest makes a request of the session in ctx cancellable by id.
+func (s *Server) trackRequest(ctx context.Context, id interface{}) (context.Context, func()) {
+	requests := s.requestsForSession(sessionIDFromContext(ctx))
+	if requests == nil {
+		return ctx, func() {}
+	}
+	return requests.track(ctx, id)
+}
+
+// handleCancelled applies notifications/cancelled. Unknown or finished
+// requests are ignored, as the notification may race with the response.
+func (s *Server) handleCancelled(ctx context.Context, rawParams json.RawMessage) {
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+		Reason    string          `json:"reason"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return
+	}
+	id, ok, err := parseID(params.RequestID)
+	if err != nil || !ok || id == nil {
+		return
+	}
+	requests := s.requestsForSession(sessionIDFromContext(ctx))
+	if requests == nil || !requests.cancel(id) {
+		return
+	}
+	if s.eventEmitter != nil {
+		s.eventEmitter("info", "request_cancelled", map[string]interface{}{
+			"request_id": id,
+			"reason":     params.Reason,
+		})
+	}
+}
+
+// requestCancelled reports whether the client cancelled the request,
+// either with notifications/cancelled or by going away.
+func requestCancelled(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+func requestCancelledError() *rpcError {
+	return &rpcError{
+		Code:    rpcCodeRequestCancelled,
+		Message: "request cancelled",
+		Data:    &rpcErrorData{Code: protocol.ErrorCodeRequestCancelled, Retryable: false},
+	}
+}
@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"dir2mcp/internal/model"
+)
+
+const (
+	// maxConversationTurns bounds the history kept per conversation; older
+	// turns are dropped first.
+	maxConversationTurns = 8
+	// maxConversationsPerSession bounds how many conversations one session
+	// may hold; the least recently used conversation is evicted.
+	maxConversationsPerSession = 16
+	// maxConversationIDLength bounds client-supplied conversation IDs.
+	maxConversationIDLength = 128
+	// maxStoredAnswerRunes trims answers kept in history.
+	maxStoredAnswerRunes = 2000
+)
+
+// retrieverQuestionRewriter is implemented by retrievers that can turn a
+// follow-up question into a standalone query using earlier turns.
+type retrieverQuestionRewriter interface {
+	RewriteQuestion(ctx context.Context, question string, history []model.ConversationTurn) (string, error)
+}
+
+type sessionIDContextKey struct{}
+
+// withSessionID records the MCP session serving a request so tool handlers
+// can reach per-session state.
+func withSessionID(ctx context.Context, sessionID string) context.Context {
+	if sessionID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionIDContextKey{}, sessionID)
+}
+
+func sessionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDContextKey{}).(string)
+	return id
+}
+
+// sessionConversations holds the ask histories of one MCP session. It is
+// owned by the session's sessionInfo, so histories expire with the session.
+type sessionConversations struct {
+	mu   sync.Mutex
+	byID map[string]*conversation
+}
+
+type conversation struct {
+	turns    []model.ConversationTurn
+	lastUsed time.Time
+}
+
+func newSessionConversations() *sessionConversations {
+	return &sessionConversations{byID: make(map[string]*conversation)}
+}
+
+func (c *sessionConversations) history(id string) []model.ConversationTurn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conv, ok := c.byID[id]
+	if !ok {
+		return nil
+	}
+	conv.lastUsed = time.Now()
+	return append([]model.ConversationTurn(nil), conv.turns...)
+}
+
+func (c *sessionConversations) append(id string, turn model.ConversationTurn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conv, ok := c.byID[id]
+	if !ok {
+		if len(c.byID) >= maxConversationsPerSession {
+			c.evictOldestLocked()
+		}
+		conv = &conversation{}
+		c.byID[id] = conv
+	}
+	if runes := []rune(turn.Answer); len(runes) > maxStoredAnswerRunes {
+		turn.Answer = string(runes[:maxStoredAnswerRunes])
+	}
+	conv.turns = append(conv.turns, turn)
+	if len(conv.turns) > maxConversationTurns {
+		conv.turns = append([]model.ConversationTurn(nil), conv.turns[len(conv.turns)-maxConversationTurns:]...)
+	}
+	conv.lastUsed = time.Now()
+}
+
+func (c *sessionConversations) evictOldestLocked() {
+	var (
+		oldestID string
+		oldest   time.Time
+	)
+	for id, conv := range c.byID {
+		if oldestID == "" || conv.lastUsed.Before(oldest) {
+			oldestID, oldest = id, conv.lastUsed
+		}
+	}
+	delete(c.byID, oldestID)
+}
+
+// conversationsForSession returns the conversation store of an active
+// session, or nil when the session is unknown.
+func (s *Server) conversationsForSession(sessionID string) *sessionConversations {
+	if sessionID == "" {
+		return nil
+	}
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	return s.sessions[sessionID].conversations
+}
+
+// parseConversationID reads the optional conversation_id argument of
+// dir2mcp.ask.
+func parseConversationID(args map[string]interface{}) (string, *toolExecutionError) {
+	id, err := parseOptionalString(args, "conversation_id")
+	if err != nil {
+		return "", &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	id = strings.TrimSpace(id)
+	if len(id) > maxConversationIDLength {
+		return "", &toolExecutionError{Code: "INVALID_FIELD", Message: "conversation_id must be at most 128 characters", Retryable: false}
+	}
+	return id, nil
+}
+
+// resolveFollowUp rewrites question into a standalone query using the
+// conversation's history. It returns the conversation store (nil when no
+// conversation applies) and the query to retrieve with. Rewrite failures
+// fall back to the question as asked.
+func (s *Server) resolveFollowUp(ctx context.Context, conversationID, question string) (*sessionConversations, string) {
+	if conversationID == "" {
+		return nil, question
+	}
+	conversations := s.conversationsForSession(sessionIDFromContext(ctx))
+	if conversations == nil {
+		return nil, question
+	}
+	history := conversations.history(conversationID)
+	rewriter, ok := s.retriever.(retrieverQuestionRewriter)
+	if len(history) == 0 || !ok {
+		return conversations, question
+	}
+	standalone, err := rewriter.RewriteQuestion(ctx, question, history)
+	if err != nil {
+		if s.eventEmitter != nil {
+			s.eventEmitter("warning", "conversation_rewrite_failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		return conversations, question
+	}
+	if strings.TrimSpace(standalone) == "" {
+		return conversations, question
+	}
+	return conversations, standalone
+}
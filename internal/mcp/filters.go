@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+var (
+	filterStatuses    = []string{"ok", "skipped", "error"}
+	filterSourceTypes = []string{"filesystem", "archive_member"}
+	filterRepTypes    = []string{
+		ingest.RepTypeRawText,
+		ingest.RepTypeOCRMarkdown,
+		ingest.RepTypeTranscript,
+		ingest.RepTypeAnnotationText,
+		ingest.RepTypeAnnotationJSON,
+	}
+)
+
+// parseMetadataFilterArguments reads the metadata filter arguments shared by
+// dir2mcp.search and dir2mcp.ask.
+func parseMetadataFilterArguments(args map[string]interface{}) (model.MetadataFilter, *toolExecutionError) {
+	var filter model.MetadataFilter
+	invalid := func(message string) (model.MetadataFilter, *toolExecutionError) {
+		return model.MetadataFilter{}, &toolExecutionError{Code: "INVALID_FIELD", Message: message, Retryable: false}
+	}
+	outOfRange := func(message string) (model.MetadataFilter, *toolExecutionError) {
+		return model.MetadataFilter{}, &toolExecutionError{Code: "INVALID_RANGE", Message: message, Retryable: false}
+	}
+
+	for _, bound := range []struct {
+		key string
+		dst *int64
+	}{
+		{"modified_after", &filter.ModifiedAfter},
+		{"modified_before", &filter.ModifiedBefore},
+	} {
+		raw, err := parseOptionalString(args, bound.key)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		if raw == "" {
+			continue
+		}
+		ts, err := parseFilterTime(raw)
+		if err != nil {
+			return invalid(fmt.Sprintf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", bound.key))
+		}
+		*bound.dst = ts.Unix()
+	}
+	if filter.ModifiedAfter > 0 && filter.ModifiedBefore > 0 && filter.ModifiedAfter >= filter.ModifiedBefore {
+		return outOfRange("modified_after must be earlier than modified_before")
+	}
+
+	for _, bound := range []struct {
+		key string
+		dst *int64
+	}{
+		{"min_size_bytes", &filter.MinSizeBytes},
+		{"max_size_bytes", &filter.MaxSizeBytes},
+	} {
+		value, present, err := parseOptionalIntegerWithPresence(args, bound.key)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		if !present {
+			continue
+		}
+		if value < 0 {
+			return outOfRange(bound.key + " must be >= 0")
+		}
+		*bound.dst = int64(value)
+	}
+	if filter.MaxSizeBytes > 0 && filter.MinSizeBytes > filter.MaxSizeBytes {
+		return outOfRange("min_size_bytes must not exceed max_size_bytes")
+	}
+
+	var toolErr *toolExecutionError
+	if filter.Statuses, toolErr = parseEnumSlice(args, "statuses", filterStatuses); toolErr != nil {
+		return model.MetadataFilter{}, toolErr
+	}
+	if filter.SourceTypes, toolErr = parseEnumSlice(args, "source_types", filterSourceTypes); toolErr != nil {
+		return model.MetadataFilter{}, toolErr
+	}
+	if filter.RepTypes, toolErr = parseEnumSlice(args, "rep_types", filterRepTypes); toolErr != nil {
+		return model.MetadataFilter{}, toolErr
+	}
+
+	languages, err := parseOptionalStringSlice(args, "languages")
+	if err != nil {
+		return invalid(err.Error())
+	}
+	for _, language := range languages {
+		canonical := ingest.NormalizeCodeLanguage(language)
+		if canonical == "" {
+			return invalid(fmt.Sprintf("languages contains unknown language %q", strings.TrimSpace(language)))
+		}
+		filter.Languages = append(filter.Languages, canonical)
+	}
+	return filter, nil
+}
+
+// parseFilterTime accepts RFC 3339 timestamps and bare dates, which are
+// taken as midnight UTC.
+func parseFilterTime(raw string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, raw); err == nil {
+		return ts, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// parseEnumSlice reads an optional string array whose entries must be drawn
+// from allowed; entries are lowercased.
+func parseEnumSlice(args map[string]interface{}, key string, allowed []string) ([]string, *toolExecutionError) {
+	values, err := parseOptionalStringSlice(args, key)
+	if err != nil {
+		return nil, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		known := false
+		for _, candidate := range allowed {
+			if value == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, &toolExecutionError{
+				Code:      "INVALID_FIELD",
+				Message:   fmt.Sprintf("%s entries must be one of %s", key, strings.Join(allowed, ",")),
+				Retryable: false,
+			}
+		}
+		out = append(out, value)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// addMetadataFilterProperties adds the metadata filter arguments to an input
+// schema's properties.
+func addMetadataFilterProperties(properties map[string]interface{}) {
+	properties["modified_after"] = map[string]interface{}{"type": "string", "description": "RFC 3339 timestamp or YYYY-MM-DD; inclusive"}
+	properties["modified_before"] = map[string]interface{}{"type": "string", "description": "RFC 3339 timestamp or YYYY-MM-DD; exclusive"}
+	properties["min_size_bytes"] = map[string]interface{}{"type": "integer", "minimum": 0}
+	properties["max_size_bytes"] = map[string]interface{}{"type": "integer", "minimum": 0}
+	properties["statuses"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": filterStatuses}}
+	properties["source_types"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": filterSourceTypes}}
+	properties["rep_types"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": filterRepTypes}}
+	properties["languages"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+}
@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+// retrieverGrep is implemented by retrievers that can pattern-match the raw
+// text of indexed files.
+type retrieverGrep interface {
+	Grep(ctx context.Context, query model.GrepQuery, emit func(model.GrepMatch)) (model.GrepResult, error)
+}
+
+func (s *Server) handleGrepTool(ctx context.Context, args map[string]interface{}) (toolCallResult, *toolExecutionError) {
+	if err := assertNoUnknownArguments(args, map[string]struct{}{
+		"pattern":       {},
+		"literal":       {},
+		"ignore_case":   {},
+		"path_prefix":   {},
+		"file_glob":     {},
+		"context_lines": {},
+		"max_matches":   {},
+	}); err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+
+	pattern, ok, err := parseRequiredString(args, "pattern")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if !ok {
+		return toolCallResult{}, &toolExecutionError{Code: "MISSING_FIELD", Message: "pattern is required", Retryable: false}
+	}
+	if len(pattern) > MaxGrepPatternLength {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("pattern must be at most %d bytes", MaxGrepPatternLength), Retryable: false}
+	}
+	literal, err := parseOptionalBool(args, "literal", false)
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	ignoreCase, err := parseOptionalBool(args, "ignore_case", false)
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	pathPrefix, err := parseOptionalString(args, "path_prefix")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	fileGlob, err := parseOptionalString(args, "file_glob")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+
+	contextLines, _, err := parseOptionalIntegerWithPresence(args, "context_lines")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if contextLines < 0 || contextLines > MaxGrepContextLines {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("context_lines must be between 0 and %d", MaxGrepContextLines), Retryable: false}
+	}
+	maxMatches, hasMaxMatches, err := parseOptionalIntegerWithPresence(args, "max_matches")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if !hasMaxMatches {
+		maxMatches = DefaultGrepMaxMatches
+	}
+	if maxMatches < 1 || maxMatches > MaxGrepMatches {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("max_matches must be between 1 and %d", MaxGrepMatches), Retryable: false}
+	}
+
+	query := model.GrepQuery{
+		Pattern:      pattern,
+		Literal:      literal,
+		IgnoreCase:   ignoreCase,
+		PathPrefix:   pathPrefix,
+		FileGlob:     fileGlob,
+		ContextLines: contextLines,
+		MaxMatches:   maxMatches,
+	}
+	if _, err := query.Regexp(); err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "pattern is not a valid RE2 expression: " + err.Error(), Retryable: false}
+	}
+
+	if s.retriever == nil {
+		return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever not configured", Retryable: false}
+	}
+	grepper, ok := s.retriever.(retrieverGrep)
+	if !ok {
+		return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever does not support grep", Retryable: false}
+	}
+
+	grepCtx, cancel := context.WithTimeout(ctx, GrepTimeout)
+	defer cancel()
+	result, grepErr := grepper.Grep(grepCtx, query, grepProgress(ctx))
+	if grepErr != nil {
+		if errors.Is(grepErr, model.ErrNotImplemented) {
+			return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "grep not supported by store", Retryable: false}
+		}
+		return toolCallResult{}, &toolExecutionError{Code: "INTERNAL_ERROR", Message: "internal server error", Retryable: true}
+	}
+
+	matches := make([]map[string]interface{}, 0, len(result.Matches))
+	var text strings.Builder
+	fmt.Fprintf(&text, "found %d match(es) in %d file(s)", len(result.Matches), result.FilesScanned)
+	for _, match := range result.Matches {
+		matches = append(matches, map[string]interface{}{
+			"rel_path": match.RelPath,
+			"span":     buildOpenFileSpan(match.Span),
+			"line":     match.Line,
+			"before":   append([]string{}, match.Before...),
+			"after":    append([]string{}, match.After...),
+		})
+		fmt.Fprintf(&text, "\n%s:%d: %s", match.RelPath, match.Span.StartLine, match.Line)
+	}
+	if result.Truncated {
+		text.WriteString("\n(stopped at max_matches)")
+	}
+	if result.TimedOut {
+		text.WriteString("\n(timed out; results are partial)")
+	}
+
+	return toolCallResult{
+		Content: []toolContentItem{
+			{Type: "text", Text: text.String()},
+		},
+		StructuredContent: map[string]interface{}{
+			"pattern":       pattern,
+			"matches":       matches,
+			"files_scanned": result.FilesScanned,
+			"files_skipped": result.FilesSkipped,
+			"truncated":     result.Truncated,
+			"timed_out":     result.TimedOut,
+		},
+	}, nil
+}
+
+func grepInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"pattern":       map[string]interface{}{"type": "string", "minLength": 1, "maxLength": MaxGrepPatternLength},
+			"literal":       map[string]interface{}{"type": "boolean", "default": false},
+			"ignore_case":   map[string]interface{}{"type": "boolean", "default": false},
+			"path_prefix":   map[string]interface{}{"type": "string"},
+			"file_glob":     map[string]interface{}{"type": "string"},
+			"context_lines": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": MaxGrepContextLines, "default": 0},
+			"max_matches":   map[string]interface{}{"type": "integer", "minimum": 1, "maximum": MaxGrepMatches, "default": DefaultGrepMaxMatches},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func grepOutputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"pattern": map[string]interface{}{"type": "string"},
+			"matches": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"rel_path": map[string]interface{}{"type": "string"},
+						"span":     map[string]interface{}{"$ref": "#/definitions/Span"},
+						"line":     map[string]interface{}{"type": "string"},
+						"before":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"after":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+					},
+					"required": []string{"rel_path", "span", "line"},
+				},
+			},
+			"files_scanned": map[string]interface{}{"type": "integer"},
+			"files_skipped": map[string]interface{}{"type": "integer"},
+			"truncated":     map[string]interface{}{"type": "boolean"},
+			"timed_out":     map[string]interface{}{"type": "boolean"},
+		},
+		"required":    []string{"pattern", "matches", "truncated", "timed_out"},
+		"definitions": map[string]interface{}{"Span": spanDefinitionSchema()},
+	}
+}
+
+// grepProgress returns a callback that sends each match as a progress
+// notification when the client asked for progress, so matches in a large
+// corpus arrive before the result. Progress counts the matches found so far.
+func grepProgress(ctx context.Context) func(model.GrepMatch) {
+	reporter := progressReporterFromContext(ctx)
+	if reporter == nil {
+		return nil
+	}
+	return func(match model.GrepMatch) {
+		reporter.report(1, fmt.Sprintf("%s:%d: %s", match.RelPath, match.Span.StartLine, match.Line))
+	}
+}
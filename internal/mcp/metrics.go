@@ -0,0 +1,101 @@
+package mcp
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"dir2mcp/internal/metrics"
+	"dir2mcp/internal/protocol"
+)
+
+// WithMetrics records request, rate-limit and payment metrics in m and adds
+// an active-session gauge to its registry.
+func WithMetrics(m *metrics.Metrics) ServerOption {
+	return func(s *Server) {
+		s.metrics = m
+		if m == nil {
+			return
+		}
+		m.Registry.GaugeFunc("dir2mcp_mcp_active_sessions", "MCP sessions that have not expired.", nil,
+			func(emit func(float64, ...string)) {
+				emit(float64(s.activeSessionCount(time.Now())))
+			})
+	}
+}
+
+// metricMethods bounds the method label; anything else is counted as
+// "other" so clients cannot grow the series set.
+var metricMethods = map[string]bool{
+	"initialize":                true,
+	"notifications/initialized": true,
+	protocol.RPCMethodCancelled: true,
+	"tools/list":                true,
+	"tools/call":                true,
+	"resources/list":            true,
+	"resources/templates/list":  true,
+	"resources/read":            true,
+	"resources/subscribe":       true,
+	"resources/unsubscribe":     true,
+	"prompts/list":              true,
+	"prompts/get":               true,
+	eventStreamMethod:           true,
+}
+
+func (s *Server) observeRequestMetrics(aw *auditResponseWriter, rec *auditRecord, latency time.Duration) {
+	method := rec.method
+	switch {
+	case method == "":
+		// rejected before the body was parsed, e.g. by auth.
+		method = "none"
+	case !metricMethods[method]:
+		method = "other"
+	}
+	tool := ""
+	if method == "tools/call" {
+		tool = rec.tool
+		if _, known := s.tools[tool]; !known {
+			tool = "unknown"
+		}
+	}
+	code := rec.responseErrorCode(aw)
+	if code == "" && aw.status >= 400 {
+		code = "HTTP_" + strconv.Itoa(aw.status)
+	}
+	s.metrics.ObserveRequest(method, tool, code, latency)
+}
+
+func (s *Server) observeRateLimited(limiter string) {
+	if s.metrics != nil {
+		s.metrics.ObserveRateLimited(limiter)
+	}
+}
+
+func (s *Server) observeX402(stage, outcome string) {
+	if s.metrics != nil {
+		s.metrics.ObserveX402(stage, outcome)
+	}
+}
+
+// activeSessionCount counts sessions that are within both timeouts at now.
+// With a session store it counts every process's sessions.
+func (s *Server) activeSessionCount(now time.Time) int {
+	inactivity, maxLife := s.resolveSessionTimeouts()
+	if s.sessionStore != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+		defer cancel()
+		if n, err := s.sessionStore.CountActive(ctx, now, inactivity, maxLife); err == nil {
+			return n
+		}
+	}
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	n := 0
+	for _, si := range s.sessions {
+		if now.Sub(si.lastSeen) > inactivity || (maxLife > 0 && now.Sub(si.created) > maxLife) {
+			continue
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,336 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+const (
+	// resourceEventBacklog bounds how many resource notifications are kept
+	// for streams resuming with Last-Event-ID.
+	resourceEventBacklog = 1024
+	// eventStreamKeepAlive is how often an idle GET stream writes a comment
+	// and re-checks that its session is still alive.
+	eventStreamKeepAlive = 25 * time.Second
+	// eventStreamMethod is the method recorded in the audit log and metrics
+	// for a GET event stream, which carries no JSON-RPC method of its own.
+	eventStreamMethod = "GET"
+)
+
+type resourceEvent struct {
+	id     uint64
+	method string
+	// relPath names the document for resources/updated; it is empty for
+	// list_changed.
+	relPath string
+}
+
+// resourceEventLog is a bounded, ordered log of resource notifications.
+// Event ids increase by one, so a stream can resume from the last id it saw.
+type resourceEventLog struct {
+	mu      sync.Mutex
+	events  []resourceEvent
+	nextID  uint64
+	changed chan struct{}
+}
+
+func newResourceEventLog() *resourceEventLog {
+	return &resourceEventLog{nextID: 1, changed: make(chan struct{})}
+}
+
+// append records a notification and wakes every waiting stream. A
+// list_changed directly after another replaces it, so a scan that adds or
+// deletes many documents leaves one event in the backlog; the replacement
+// gets a new id, so clients that saw the old one are still told.
+func (l *resourceEventLog) append(method, relPath string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if method == protocol.RPCMethodResourcesListChanged && len(l.events) > 0 &&
+		l.events[len(l.events)-1].method == protocol.RPCMethodResourcesListChanged {
+		l.events = l.events[:len(l.events)-1]
+	}
+	l.events = append(l.events, resourceEvent{id: l.nextID, method: method, relPath: relPath})
+	l.nextID++
+	if len(l.events) > resourceEventBacklog {
+		l.events = append([]resourceEvent(nil), l.events[len(l.events)-resourceEventBacklog:]...)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// lastID returns the id of the most recent event, or 0 when there is none.
+func (l *resourceEventLog) lastID() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.nextID - 1
+}
+
+// since returns the retained events after id and a channel closed by the
+// next append. missed reports that events after id are no longer retained
+// (or that id was issued by an earlier server run), in which case the
+// returned events start from the oldest retained one.
+func (l *resourceEventLog) since(id uint64) (events []resourceEvent, changed <-chan struct{}, missed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if id >= l.nextID {
+		id, missed = 0, true
+	}
+	for i, ev := range l.events {
+		if ev.id > id {
+			if ev.id > id+1 {
+				missed = true
+			}
+			events = append(events, l.events[i:]...)
+			break
+		}
+	}
+	return events, l.changed, missed
+}
+
+// sessionResources holds the resource subscriptions of one MCP session and
+// the session's GET stream. It is owned by the session's sessionInfo.
+type sessionResources struct {
+	mu sync.Mutex
+	// subscriptions maps rel_path to the URIs subscribed for it; span URIs
+	// of one file share its updates.
+	subscriptions map[string]map[string]struct{}
+	stream        *sessionStream
+}
+
+type sessionStream struct {
+	cancel context.CancelFunc
+}
+
+func newSessionResources() *sessionResources {
+	return &sessionResources{subscriptions: make(map[string]map[string]struct{})}
+}
+
+func (r *sessionResources) subscribe(relPath, uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	uris, ok := r.subscriptions[relPath]
+	if !ok {
+		uris = make(map[string]struct{})
+		r.subscriptions[relPath] = uris
+	}
+	uris[uri] = struct{}{}
+}
+
+func (r *sessionResources) unsubscribe(relPath, uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.subscriptions[relPath], uri)
+	if len(r.subscriptions[relPath]) == 0 {
+		delete(r.subscriptions, relPath)
+	}
+}
+
+func (r *sessionResources) subscribedURIs(relPath string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	uris := make([]string, 0, len(r.subscriptions[relPath]))
+	for uri := range r.subscriptions[relPath] {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// attachStream makes stream the session's only GET stream, ending the
+// previous one so each notification is delivered once.
+func (r *sessionResources) attachStream(stream *sessionStream) {
+	r.mu.Lock()
+	previous := r.stream
+	r.stream = stream
+	r.mu.Unlock()
+	if previous != nil {
+		previous.cancel()
+	}
+}
+
+func (r *sessionResources) detachStream(stream *sessionStream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stream == stream {
+		r.stream = nil
+	}
+}
+
+// resourcesForSession returns the resource state of an active session, or
+// nil when the session is unknown.
+func (s *Server) resourcesForSession(sessionID string) *sessionResources {
+	if sessionID == "" {
+		return nil
+	}
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	return s.sessions[sessionID].resources
+}
+
+// NotifyDocumentChange turns an ingestion change into resource
+// notifications: re-processed documents produce resources/updated for
+// their subscribers and added or deleted documents produce
+// resources/list_changed.
+func (s *Server) NotifyDocumentChange(change model.DocumentChange) {
+	if !s.resourcesEnabled() {
+		return
+	}
+	switch change.Kind {
+	case model.DocumentUpdated:
+		s.resourceEvents.append(protocol.RPCMethodResourcesUpdated, change.RelPath)
+	case model.DocumentAdded, model.DocumentDeleted:
+		s.resourceEvents.append(protocol.RPCMethodResourcesListChanged, "")
+	}
+}
+
+func (s *Server) handleResourceSubscription(ctx context.Context, w http.ResponseWriter, rawParams json.RawMessage, id interface{}, subscribe bool) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "invalid params", "INVALID_FIELD", false)
+		return
+	}
+	uri := strings.TrimSpace(params.URI)
+	if uri == "" {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "uri is required", "MISSING_FIELD", false)
+		return
+	}
+	relPath, _, err := parseResourceURI(uri)
+	if err != nil {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, err.Error(), "INVALID_FIELD", false)
+		return
+	}
+	resources := s.resourcesForSession(sessionIDFromContext(ctx))
+	if resources == nil {
+		writeError(w, http.StatusNotFound, id, -32001, "session not found", protocol.ErrorCodeSessionNotFound, false)
+		return
+	}
+	if subscribe {
+		resources.subscribe(relPath, uri)
+	} else {
+		resources.unsubscribe(relPath, uri)
+	}
+	writeResult(w, http.StatusOK, id, map[string]interface{}{})
+}
+
+// handleEventStream serves the standalone GET stream of a session. It
+// carries resource notifications only; responses always travel on the POST
+// that asked for them.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	recordAuditMethod(r.Context(), eventStreamMethod)
+	r, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	s.recordAuditRequest(r.Context(), eventStreamMethod, strings.TrimSpace(r.Header.Get(protocol.MCPSessionHeader)))
+	if !s.allowOrigin(w, r) {
+		return
+	}
+	if !acceptsEventStream(r) {
+		writeError(w, http.StatusNotAcceptable, nil, -32600, "Accept must include text/event-stream", "INVALID_FIELD", false)
+		return
+	}
+	sessionID := strings.TrimSpace(r.Header.Get(protocol.MCPSessionHeader))
+	if sessionID == "" {
+		writeError(w, http.StatusNotFound, nil, -32001, "session not found", protocol.ErrorCodeSessionNotFound, false)
+		return
+	}
+	if ok, reason := s.hasActiveSession(sessionID, time.Now()); !ok {
+		if reason != "" {
+			w.Header().Set(protocol.MCPSessionExpiredHeader, reason)
+		}
+		writeError(w, http.StatusNotFound, nil, -32001, "session not found", protocol.ErrorCodeSessionNotFound, false)
+		return
+	}
+	resources := s.resourcesForSession(sessionID)
+	if resources == nil {
+		writeError(w, http.StatusNotFound, nil, -32001, "session not found", protocol.ErrorCodeSessionNotFound, false)
+		return
+	}
+
+	// without Last-Event-ID the stream starts at the present; history is
+	// only replayed to clients resuming a stream.
+	lastID := s.resourceEvents.lastID()
+	if raw := strings.TrimSpace(r.Header.Get("Last-Event-ID")); raw != "" {
+		if parsed, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			lastID = parsed
+		}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stream := &sessionStream{cancel: cancel}
+	resources.attachStream(stream)
+	defer resources.detachStream(stream)
+
+	out := newEventStream(w)
+	if err := out.open(); err != nil {
+		return
+	}
+	s.pumpResourceEvents(ctx, out, resources, sessionID, lastID)
+}
+
+// pumpResourceEvents writes the resource notifications after lastID to out
+// until ctx ends, the server shuts its streams or the session goes away.
+func (s *Server) pumpResourceEvents(ctx context.Context, out *eventStream, resources *sessionResources, sessionID string, lastID uint64) {
+	ticker := time.NewTicker(eventStreamKeepAlive)
+	defer ticker.Stop()
+	for {
+		events, changed, missed := s.resourceEvents.since(lastID)
+		if missed {
+			// some notifications are gone; a list_changed tells the client
+			// to re-list, and carries no id so it is not resumed past.
+			if err := out.send(rpcNotification{JSONRPC: "2.0", Method: protocol.RPCMethodResourcesListChanged}); err != nil {
+				return
+			}
+		}
+		for _, ev := range events {
+			if err := s.sendResourceEvent(out, resources, ev); err != nil {
+				return
+			}
+			lastID = ev.id
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-changed:
+		case <-ticker.C:
+			if s.resourcesForSession(sessionID) == nil {
+				return
+			}
+			if err := out.keepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) sendResourceEvent(out *eventStream, resources *sessionResources, ev resourceEvent) error {
+	eventID := strconv.FormatUint(ev.id, 10)
+	if ev.method != protocol.RPCMethodResourcesUpdated {
+		return out.sendEvent(eventID, rpcNotification{JSONRPC: "2.0", Method: ev.method})
+	}
+	for _, uri := range resources.subscribedURIs(ev.relPath) {
+		if err := out.sendEvent(eventID, rpcNotification{
+			JSONRPC: "2.0",
+			Method:  ev.method,
+			Params:  map[string]interface{}{"uri": uri},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"dir2mcp/internal/oauth"
+	"dir2mcp/internal/protocol"
+)
+
+// protectedResourceMetadataPath is where RFC 9728 metadata is served. The
+// metadata for the MCP endpoint is also served with the endpoint's path
+// appended, as RFC 9728 §3.1 describes.
+const protectedResourceMetadataPath = "/.well-known/oauth-protected-resource"
+
+// oauthEnabled reports whether the server runs as an OAuth protected
+// resource.
+func (s *Server) oauthEnabled() bool {
+	return s.oauth != nil
+}
+
+// authorizeOAuth validates the request's JWT access token. Failures carry a
+// WWW-Authenticate challenge pointing at the resource metadata, so clients
+// can discover the authorization server.
+func (s *Server) authorizeOAuth(w http.ResponseWriter, r *http.Request, bearer string) (*http.Request, bool) {
+	metadataURL := s.resourceMetadataURL(r)
+	if bearer == "" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata=%q`, metadataURL))
+		writeError(w, http.StatusUnauthorized, nil, -32000, "missing or invalid bearer token", protocol.ErrorCodeUnauthorized, false)
+		return r, false
+	}
+
+	token, err := s.oauth.Authenticate(r.Context(), bearer, time.Now())
+	switch {
+	case errors.Is(err, oauth.ErrInsufficientScope):
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q, resource_metadata=%q`, s.oauth.ScopePrefix()+"tools:*", metadataURL))
+		writeError(w, http.StatusForbidden, nil, -32000, "token grants no dir2mcp tools", protocol.ErrorCodePermissionDenied, false)
+		return r, false
+	case err != nil:
+		if s.eventEmitter != nil {
+			s.eventEmitter("warn", "token_rejected", map[string]interface{}{
+				"reason": err.Error(),
+			})
+		}
+		description := "the access token is invalid"
+		if !errors.Is(err, oauth.ErrInvalidToken) {
+			// the key set could not be loaded; the token may be fine.
+			description = "the access token could not be verified"
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q, resource_metadata=%q`, description, metadataURL))
+		writeError(w, http.StatusUnauthorized, nil, -32000, "missing or invalid bearer token", protocol.ErrorCodeUnauthorized, false)
+		return r, false
+	}
+	return r.WithContext(withAccessToken(r.Context(), &token)), true
+}
+
+// handleProtectedResourceMetadata serves the RFC 9728 metadata document.
+func (s *Server) handleProtectedResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	prefix := s.oauth.ScopePrefix()
+	scopes := []string{prefix + "tools:*"}
+	for _, name := range toolOrder {
+		scopes = append(scopes, prefix+"tools:"+strings.TrimPrefix(name, "dir2mcp."))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"resource":                 s.resourceURL(r),
+		"authorization_servers":    []string{s.cfg.OAuth.Issuer},
+		"bearer_methods_supported": []string{"header"},
+		"scopes_supported":         scopes,
+		"resource_name":            "dir2mcp",
+	})
+}
+
+// resourceURL is the canonical URL of the MCP endpoint: the configured one,
+// or else the one the request was addressed to.
+func (s *Server) resourceURL(r *http.Request) string {
+	if resource := strings.TrimSpace(s.cfg.OAuth.ResourceURL); resource != "" {
+		return resource
+	}
+	return requestBaseURL(r) + s.cfg.MCPPath
+}
+
+func (s *Server) resourceMetadataURL(r *http.Request) string {
+	base := requestBaseURL(r)
+	if resource := strings.TrimSpace(s.cfg.OAuth.ResourceURL); resource != "" {
+		if scheme, rest, ok := strings.Cut(resource, "://"); ok {
+			host, _, _ := strings.Cut(rest, "/")
+			base = scheme + "://" + host
+		}
+	}
+	return base + protectedResourceMetadataPath + s.cfg.MCPPath
+}
+
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
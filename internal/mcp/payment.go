@@ -82,7 +82,7 @@ func buildPaymentResourceURL(baseURL, mcpPath string) string {
 
 func (s *Server) handleToolsCallRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, rawParams json.RawMessage, id interface{}) {
 	if !s.x402Enabled {
-		s.handleToolsCall(ctx, w, rawParams, id)
+		s.handleToolsCall(ctx, w, r, rawParams, id)
 		return
 	}
 
@@ -110,6 +110,7 @@ func (s *Server) handleToolsCallRequest(ctx context.Context, w http.ResponseWrit
 		s.handlePaymentFailure(w, id, "verify", err, executionKey)
 		return
 	}
+	s.observeX402("verify", "ok")
 	s.emitPaymentEvent("info", "payment_verified", map[string]interface{}{
 		"response": json.RawMessage(verifyResponse),
 	})
@@ -118,6 +119,22 @@ func (s *Server) handleToolsCallRequest(ctx context.Context, w http.ResponseWrit
 	})
 
 	result, statusCode, rpcErr := s.processToolsCall(ctx, rawParams)
+	if requestCancelled(ctx) {
+		// a cancelled call is never settled, and no outcome is cached so the
+		// same payment can pay for a retry.
+		s.emitPaymentEvent("info", "payment_not_settled", map[string]interface{}{
+			"reason": "request_cancelled",
+		})
+		s.appendPaymentLog("payment_not_settled", map[string]interface{}{
+			"reason": "request_cancelled",
+		})
+		writeResponse(w, http.StatusOK, rpcResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   requestCancelledError(),
+		})
+		return
+	}
 	outcome := paymentExecutionOutcome{
 		StatusCode: statusCode,
 		UpdatedAt:  time.Now().UTC(),
@@ -148,6 +165,7 @@ func (s *Server) handleToolsCallRequest(ctx context.Context, w http.ResponseWrit
 		s.handlePaymentFailure(w, id, "settle", err, executionKey)
 		return
 	}
+	s.observeX402("settle", "ok")
 
 	// update the cached outcome; if the entry was pruned we need to
 	// reconstruct and persist the successful state before replaying it.
@@ -187,6 +205,7 @@ func (s *Server) handlePaymentFailure(w http.ResponseWriter, id interface{}, ope
 			Cause:      err,
 		}
 	}
+	s.observeX402(operation, facErr.Code)
 	if operation == "settle" {
 		if outcome, ok := s.getPaymentExecutionOutcome(executionKey); ok {
 			if !outcome.RequiresSettle || outcome.Settled {
@@ -256,6 +275,7 @@ func (s *Server) replayCachedPaymentOutcomeIfAny(ctx context.Context, w http.Res
 		s.handlePaymentFailure(w, id, "settle", settleErr, executionKey)
 		return true
 	}
+	s.observeX402("settle", "ok")
 	// original outcome loaded above; keep a copy in case the cache entry
 	// is gone by the time we call markPaymentExecutionSettled.
 	orig := outcome
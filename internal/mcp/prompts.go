@@ -0,0 +1,384 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+const (
+	// promptMaxDefinitions caps the definitions embedded for a symbol
+	// argument.
+	promptMaxDefinitions = 3
+	// promptOverviewFiles caps the file list embedded by onboarding_tour.
+	promptOverviewFiles = 200
+)
+
+// promptDefinition is a prompt served by prompts/list and prompts/get.
+type promptDefinition struct {
+	config.PromptTemplate
+	// corpusOverview embeds a list of indexed files ahead of the
+	// instruction.
+	corpusOverview bool
+}
+
+// builtinPrompts returns the prompts shipped with the server.
+func builtinPrompts() []promptDefinition {
+	return []promptDefinition{
+		{PromptTemplate: config.PromptTemplate{
+			Name:        "summarize_file",
+			Description: "Summarize one file with line citations.",
+			Arguments: []config.PromptArgument{
+				{Name: "rel_path", Description: "File to summarize, relative to the root.", Type: config.PromptArgumentPath, Required: true},
+				{Name: "focus", Description: "Optional aspect to concentrate on.", Type: config.PromptArgumentString},
+			},
+			Template: "Summarize {{rel_path}} for someone new to this codebase: its purpose, its main parts and how it connects to the rest of the directory." +
+				"{{#focus}} Pay particular attention to: {{focus}}.{{/focus}}" +
+				" Cite every claim with the lines it comes from, as [{{rel_path}}:L<start>-<end>].",
+		}},
+		{PromptTemplate: config.PromptTemplate{
+			Name:        "explain_symbol",
+			Description: "Explain a function, type or other code symbol from its definition.",
+			Arguments: []config.PromptArgument{
+				{Name: "name", Description: "Symbol name; its definitions are embedded.", Type: config.PromptArgumentSymbol, Required: true},
+				{Name: "rel_path", Description: "Optional file to include alongside the definition.", Type: config.PromptArgumentPath},
+			},
+			Template: "Explain the code symbol {{name}}: what it does, its inputs and outputs, and its side effects and error cases." +
+				" Treat the embedded definition{{#rel_path}} and {{rel_path}}{{/rel_path}} as the source of truth," +
+				" call " + protocol.ToolNameReferences + " to see how it is used, and cite lines as [path:L<start>-<end>].",
+		}},
+		{PromptTemplate: config.PromptTemplate{
+			Name:        "compare_files",
+			Description: "Compare two files and explain the differences that matter.",
+			Arguments: []config.PromptArgument{
+				{Name: "rel_path_a", Description: "First file, relative to the root.", Type: config.PromptArgumentPath, Required: true},
+				{Name: "rel_path_b", Description: "Second file, relative to the root.", Type: config.PromptArgumentPath, Required: true},
+				{Name: "focus", Description: "Optional aspect to concentrate on.", Type: config.PromptArgumentString},
+			},
+			Template: "Compare {{rel_path_a}} with {{rel_path_b}}: what they have in common, where their behaviour and structure differ, and which differences matter." +
+				"{{#focus}} Focus on: {{focus}}.{{/focus}}" +
+				" Cite lines from both files as [path:L<start>-<end>].",
+		}},
+		{
+			PromptTemplate: config.PromptTemplate{
+				Name:        "onboarding_tour",
+				Description: "Tour of the indexed directory for a newcomer.",
+				Arguments: []config.PromptArgument{
+					{Name: "focus", Description: "Optional area the newcomer cares about most.", Type: config.PromptArgumentString},
+				},
+				Template: "Give me an onboarding tour of this directory. Using the file list above, group the files into areas, explain what each area is for and suggest an order in which to read them." +
+					"{{#focus}} I am mainly interested in: {{focus}}.{{/focus}}" +
+					" Use " + protocol.ToolNameSearch + " and " + protocol.ToolNameOpenFile + " to check your claims and cite the files you rely on.",
+			},
+			corpusOverview: true,
+		},
+	}
+}
+
+// buildPromptRegistry returns the built-in prompts followed by the
+// configured ones. A configured prompt named like a built-in replaces it.
+// Configured prompts are expected to have passed config.ValidatePrompts,
+// which fills in their argument types.
+func buildPromptRegistry(configured []config.PromptTemplate) []promptDefinition {
+	prompts := builtinPrompts()
+	for _, tmpl := range configured {
+		replaced := false
+		for i := range prompts {
+			if prompts[i].Name == tmpl.Name {
+				prompts[i] = promptDefinition{PromptTemplate: tmpl}
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			prompts = append(prompts, promptDefinition{PromptTemplate: tmpl})
+		}
+	}
+	return prompts
+}
+
+// promptsEnabled reports whether prompts are served. Like resources they are
+// disabled while x402 gating is on, since prompts/get embeds file content.
+func (s *Server) promptsEnabled() bool {
+	return s.resourcesEnabled()
+}
+
+func (s *Server) handlePromptsList(w http.ResponseWriter, id interface{}) {
+	prompts := make([]map[string]interface{}, 0, len(s.prompts))
+	for _, prompt := range s.prompts {
+		args := make([]map[string]interface{}, 0, len(prompt.Arguments))
+		for _, arg := range prompt.Arguments {
+			args = append(args, map[string]interface{}{
+				"name":        arg.Name,
+				"description": arg.Description,
+				"required":    arg.Required,
+			})
+		}
+		prompts = append(prompts, map[string]interface{}{
+			"name":        prompt.Name,
+			"description": prompt.Description,
+			"arguments":   args,
+		})
+	}
+	writeResult(w, http.StatusOK, id, map[string]interface{}{"prompts": prompts})
+}
+
+func (s *Server) handlePromptsGet(ctx context.Context, w http.ResponseWriter, rawParams json.RawMessage, id interface{}) {
+	var params struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "invalid params", "INVALID_FIELD", false)
+		return
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "name is required", "MISSING_FIELD", false)
+		return
+	}
+	var prompt *promptDefinition
+	for i := range s.prompts {
+		if s.prompts[i].Name == name {
+			prompt = &s.prompts[i]
+			break
+		}
+	}
+	if prompt == nil {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, fmt.Sprintf("unknown prompt: %s", name), "INVALID_FIELD", false)
+		return
+	}
+	values, canonicalCode, err := parsePromptArguments(prompt.Arguments, params.Arguments)
+	if err != nil {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, err.Error(), canonicalCode, false)
+		return
+	}
+
+	messages, err := s.renderPrompt(ctx, *prompt, values)
+	if err != nil {
+		if errors.Is(err, errPromptRetrieverMissing) {
+			writeError(w, http.StatusOK, id, rpcCodeInternalError, "retriever not configured", protocol.ErrorCodeIndexNotReady, false)
+			return
+		}
+		writeResourceError(w, id, err)
+		return
+	}
+	writeResult(w, http.StatusOK, id, map[string]interface{}{
+		"description": prompt.Description,
+		"messages":    messages,
+	})
+}
+
+var errPromptRetrieverMissing = errors.New("retriever not configured")
+
+// parsePromptArguments checks raw against the declared arguments and returns
+// the values to render, normalized by type. Unset and empty arguments are
+// left out. The returned code is the canonical error code for err.
+func parsePromptArguments(declared []config.PromptArgument, raw map[string]interface{}) (map[string]string, string, error) {
+	known := make(map[string]config.PromptArgument, len(declared))
+	for _, arg := range declared {
+		known[arg.Name] = arg
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		arg, ok := known[key]
+		if !ok {
+			return nil, "INVALID_FIELD", fmt.Errorf("unknown argument: %s", key)
+		}
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = strings.TrimSpace(v)
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			text = strconv.FormatBool(v)
+		case nil:
+		default:
+			return nil, "INVALID_FIELD", fmt.Errorf("argument %s must be a string", key)
+		}
+		if text == "" {
+			continue
+		}
+		switch arg.Type {
+		case config.PromptArgumentInteger:
+			n, err := strconv.Atoi(text)
+			if err != nil {
+				return nil, "INVALID_FIELD", fmt.Errorf("argument %s must be an integer", key)
+			}
+			text = strconv.Itoa(n)
+		case config.PromptArgumentBoolean:
+			b, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, "INVALID_FIELD", fmt.Errorf("argument %s must be a boolean", key)
+			}
+			text = strconv.FormatBool(b)
+		}
+		values[key] = text
+	}
+	for _, arg := range declared {
+		if _, ok := values[arg.Name]; arg.Required && !ok {
+			return nil, "MISSING_FIELD", fmt.Errorf("argument %s is required", arg.Name)
+		}
+	}
+	return values, "", nil
+}
+
+// renderPrompt builds the prompt messages: the content embedded for path and
+// symbol arguments in declaration order, the corpus overview when the prompt
+// asks for one, and finally the rendered instruction.
+func (s *Server) renderPrompt(ctx context.Context, prompt promptDefinition, values map[string]string) ([]map[string]interface{}, error) {
+	messages := make([]map[string]interface{}, 0, len(prompt.Arguments)+2)
+	var notes []string
+	for _, arg := range prompt.Arguments {
+		value, ok := values[arg.Name]
+		if !ok {
+			continue
+		}
+		switch arg.Type {
+		case config.PromptArgumentPath:
+			content, truncated, err := s.promptResource(ctx, value, model.Span{})
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, promptResourceMessage(content))
+			if truncated {
+				notes = append(notes, fmt.Sprintf("%s is truncated to %d characters; use %s to read the rest.", value, resourceMaxChars, protocol.ToolNameOpenFile))
+			}
+		case config.PromptArgumentSymbol:
+			definitions, err := s.promptDefinitions(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			if len(definitions) == 0 {
+				notes = append(notes, fmt.Sprintf("No indexed definition of %s was found.", value))
+			}
+			for _, def := range definitions {
+				content, _, err := s.promptResource(ctx, def.RelPath, def.Span)
+				if err != nil {
+					return nil, err
+				}
+				messages = append(messages, promptResourceMessage(content))
+			}
+		}
+	}
+	if prompt.corpusOverview {
+		if overview, err := s.promptCorpusOverview(ctx); err != nil {
+			return nil, err
+		} else if overview != "" {
+			messages = append(messages, promptTextMessage(overview))
+		}
+	}
+
+	text := prompt.Render(values)
+	if len(notes) > 0 {
+		text += "\n\n" + strings.Join(notes, "\n")
+	}
+	return append(messages, promptTextMessage(text)), nil
+}
+
+// promptResource reads relPath for embedding. Text is capped at
+// resourceMaxChars rather than rejected, so large files still make a usable
+// prompt; whole non-text files are embedded as blobs like resources/read.
+func (s *Server) promptResource(ctx context.Context, relPath string, span model.Span) (map[string]interface{}, bool, error) {
+	if s.retriever == nil {
+		return nil, false, errPromptRetrieverMissing
+	}
+	uri := resourceURI(relPath)
+	if span.Kind == "lines" {
+		uri += fmt.Sprintf("#L%d-%d", span.StartLine, span.EndLine)
+	}
+	mimeType := resourceMIMEType(relPath)
+	if span.Kind == "" && mimeType != "" && !isTextMIMEType(mimeType) {
+		content, err := s.readResource(ctx, uri, relPath, span)
+		if err != nil {
+			return nil, false, err
+		}
+		recordAuditRef(ctx, relPath, span)
+		return content, false, nil
+	}
+
+	var (
+		text      string
+		truncated bool
+		err       error
+	)
+	if withMeta, ok := s.retriever.(retrieverOpenFileWithMeta); ok {
+		text, truncated, err = withMeta.OpenFileWithMeta(ctx, relPath, span, resourceMaxChars)
+	} else {
+		text, err = s.retriever.OpenFile(ctx, relPath, span, resourceMaxChars)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	recordAuditRef(ctx, relPath, span)
+	if mimeType == "" || !isTextMIMEType(mimeType) {
+		mimeType = "text/plain"
+	}
+	return map[string]interface{}{"uri": uri, "mimeType": mimeType, "text": text}, truncated, nil
+}
+
+// promptDefinitions looks up the definitions of a symbol argument. Without a
+// symbol index there is nothing to embed, which is not an error.
+func (s *Server) promptDefinitions(ctx context.Context, name string) ([]model.Symbol, error) {
+	symbols, ok := s.retriever.(retrieverSymbols)
+	if !ok {
+		return nil, nil
+	}
+	definitions, err := symbols.Definitions(ctx, model.SymbolQuery{Name: name, Exact: true, Limit: promptMaxDefinitions})
+	if errors.Is(err, model.ErrNotImplemented) {
+		return nil, nil
+	}
+	return definitions, err
+}
+
+// promptCorpusOverview lists the first indexed files, or returns "" when no
+// store is available.
+func (s *Server) promptCorpusOverview(ctx context.Context) (string, error) {
+	if s.store == nil {
+		return "", nil
+	}
+	docs, total, err := s.store.ListFiles(ctx, "", "", promptOverviewFiles, 0)
+	if err != nil {
+		if errors.Is(err, model.ErrNotImplemented) {
+			return "", nil
+		}
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Indexed files (%d total", total)
+	if int64(len(docs)) < total {
+		fmt.Fprintf(&b, ", first %d shown", len(docs))
+	}
+	b.WriteString("):")
+	for _, doc := range docs {
+		if doc.Deleted {
+			continue
+		}
+		b.WriteString("\n- ")
+		b.WriteString(doc.RelPath)
+	}
+	return b.String(), nil
+}
+
+func promptResourceMessage(content map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"role":    "user",
+		"content": map[string]interface{}{"type": "resource", "resource": content},
+	}
+}
+
+func promptTextMessage(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"role":    "user",
+		"content": map[string]interface{}{"type": "text", "text": text},
+	}
+}
@@ -47,25 +47,35 @@ func (l *ipRateLimiter) allow(ip string) bool {
 
 	bucket, exists := l.buckets[clientIP]
 	if !exists {
-		l.buckets[clientIP] = &tokenBucket{
-			tokens:   float64(l.burst - 1),
-			lastTime: now,
-		}
+		l.buckets[clientIP] = newTokenBucket(l.burst, now)
 		return true
 	}
+	return bucket.take(now, l.rps, l.burst)
+}
+
+// newTokenBucket returns a full bucket with one token already taken.
+func newTokenBucket(burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		tokens:   float64(burst - 1),
+		lastTime: now,
+	}
+}
 
-	elapsedSeconds := now.Sub(bucket.lastTime).Seconds()
+// take refills the bucket for the time since its last use and takes one
+// token if there is one.
+func (b *tokenBucket) take(now time.Time, rps float64, burst int) bool {
+	elapsedSeconds := now.Sub(b.lastTime).Seconds()
 	if elapsedSeconds > 0 {
-		bucket.tokens += elapsedSeconds * l.rps
-		maxTokens := float64(l.burst)
-		if bucket.tokens > maxTokens {
-			bucket.tokens = maxTokens
+		b.tokens += elapsedSeconds * rps
+		maxTokens := float64(burst)
+		if b.tokens > maxTokens {
+			b.tokens = maxTokens
 		}
 	}
 
-	bucket.lastTime = now
-	if bucket.tokens >= 1 {
-		bucket.tokens -= 1
+	b.lastTime = now
+	if b.tokens >= 1 {
+		b.tokens -= 1
 		return true
 	}
 
@@ -0,0 +1,379 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+const (
+	// resourcePageSize is the number of resources returned per
+	// resources/list page.
+	resourcePageSize = 200
+	// resourceMaxBytes caps whole-file resources/read responses.
+	resourceMaxBytes = 8 << 20
+	// resourceMaxChars caps span reads, matching open_file's upper bound.
+	resourceMaxChars = 50000
+
+	rpcCodeInvalidParams    = -32602
+	rpcCodeResourceNotFound = -32002
+	rpcCodeInternalError    = -32603
+)
+
+// retrieverOpenFileBytes is implemented by retrievers that can return a file's
+// raw bytes under the same checks as OpenFile, so binary resources can be
+// served as blobs.
+type retrieverOpenFileBytes interface {
+	OpenFileBytes(ctx context.Context, relPath string, maxBytes int) ([]byte, bool, error)
+}
+
+var errResourceTooLarge = errors.New("resource too large")
+
+// resourcesEnabled reports whether resources are served. They are disabled
+// while x402 gating is on because resources/read would otherwise hand out
+// file content that tools/call only returns after payment.
+func (s *Server) resourcesEnabled() bool {
+	return !s.x402Enabled
+}
+
+func (s *Server) handleResourcesList(ctx context.Context, w http.ResponseWriter, rawParams json.RawMessage, id interface{}) {
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(rawParams) > 0 && string(rawParams) != "null" {
+		if err := json.Unmarshal(rawParams, &params); err != nil {
+			writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "invalid params", "INVALID_FIELD", false)
+			return
+		}
+	}
+	offset := 0
+	if cursor := strings.TrimSpace(params.Cursor); cursor != "" {
+		parsed, err := strconv.Atoi(cursor)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "invalid cursor", "INVALID_FIELD", false)
+			return
+		}
+		offset = parsed
+	}
+
+	resources := make([]map[string]interface{}, 0)
+	result := map[string]interface{}{"resources": resources}
+	if s.store == nil {
+		writeResult(w, http.StatusOK, id, result)
+		return
+	}
+	docs, total, err := s.store.ListFiles(ctx, "", "", resourcePageSize, offset)
+	if err != nil {
+		if errors.Is(err, model.ErrNotImplemented) {
+			writeResult(w, http.StatusOK, id, result)
+			return
+		}
+		writeError(w, http.StatusOK, id, rpcCodeInternalError, err.Error(), "STORE_CORRUPT", false)
+		return
+	}
+	for _, doc := range docs {
+		if doc.Deleted {
+			continue
+		}
+		resources = append(resources, serializeResource(doc))
+	}
+	result["resources"] = resources
+	if next := offset + len(docs); len(docs) > 0 && int64(next) < total {
+		result["nextCursor"] = strconv.Itoa(next)
+	}
+	writeResult(w, http.StatusOK, id, result)
+}
+
+func serializeResource(doc model.Document) map[string]interface{} {
+	resource := map[string]interface{}{
+		"uri":  resourceURI(doc.RelPath),
+		"name": doc.RelPath,
+		"size": doc.SizeBytes,
+	}
+	if mimeType := resourceMIMEType(doc.RelPath); mimeType != "" {
+		resource["mimeType"] = mimeType
+	}
+	if doc.MTimeUnix > 0 {
+		resource["annotations"] = map[string]interface{}{
+			"lastModified": time.Unix(doc.MTimeUnix, 0).UTC().Format(time.RFC3339),
+		}
+	}
+	return resource
+}
+
+func (s *Server) handleResourceTemplatesList(w http.ResponseWriter, id interface{}) {
+	base := protocol.ResourceURIPrefix + "{+rel_path}"
+	writeResult(w, http.StatusOK, id, map[string]interface{}{
+		"resourceTemplates": []map[string]interface{}{
+			{
+				"uriTemplate": base,
+				"name":        "file",
+				"description": "Whole file under the indexed root.",
+			},
+			{
+				"uriTemplate": base + "#L{start_line}-{end_line}",
+				"name":        "file_lines",
+				"description": "Inclusive 1-based line range of a text file.",
+			},
+			{
+				"uriTemplate": base + "#page={page}",
+				"name":        "file_page",
+				"description": "Extracted text of one page of a PDF or OCR'd document.",
+				"mimeType":    "text/plain",
+			},
+			{
+				"uriTemplate": base + "#t={start_ms}-{end_ms}",
+				"name":        "file_time",
+				"description": "Transcript text between two offsets of an audio file, in milliseconds.",
+				"mimeType":    "text/plain",
+			},
+		},
+	})
+}
+
+func (s *Server) handleResourcesRead(ctx context.Context, w http.ResponseWriter, rawParams json.RawMessage, id interface{}) {
+	var params struct {
+		URI *string `json:"uri"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "invalid params", "INVALID_FIELD", false)
+		return
+	}
+	if params.URI == nil || strings.TrimSpace(*params.URI) == "" {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "uri is required", "MISSING_FIELD", false)
+		return
+	}
+	uri := strings.TrimSpace(*params.URI)
+	relPath, span, err := parseResourceURI(uri)
+	if err != nil {
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, err.Error(), "INVALID_FIELD", false)
+		return
+	}
+	if s.retriever == nil {
+		writeError(w, http.StatusOK, id, rpcCodeInternalError, "retriever not configured", protocol.ErrorCodeIndexNotReady, false)
+		return
+	}
+
+	content, err := s.readResource(ctx, uri, relPath, span)
+	if err != nil {
+		writeResourceError(w, id, err)
+		return
+	}
+	recordAuditRef(ctx, relPath, span)
+	writeResult(w, http.StatusOK, id, map[string]interface{}{
+		"contents": []map[string]interface{}{content},
+	})
+}
+
+// writeResourceError maps a readResource failure to its JSON-RPC error.
+func writeResourceError(w http.ResponseWriter, id interface{}, err error) {
+	switch {
+	case errors.Is(err, model.ErrForbidden):
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "forbidden", protocol.ErrorCodePermissionDenied, false)
+	case errors.Is(err, model.ErrPathOutsideRoot):
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "path outside root", "PATH_OUTSIDE_ROOT", false)
+	case errors.Is(err, model.ErrDocTypeUnsupported):
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, "doc type unsupported", "DOC_TYPE_UNSUPPORTED", false)
+	case errors.Is(err, errResourceTooLarge):
+		writeError(w, http.StatusOK, id, rpcCodeInvalidParams, fmt.Sprintf("resource exceeds %d bytes; read a span instead", resourceMaxBytes), "FILE_TOO_LARGE", false)
+	case errors.Is(err, os.ErrNotExist):
+		writeError(w, http.StatusOK, id, rpcCodeResourceNotFound, "resource not found", protocol.ErrorCodeFileNotFound, false)
+	default:
+		writeError(w, http.StatusOK, id, rpcCodeInternalError, "internal server error", "INTERNAL_ERROR", true)
+	}
+}
+
+// readResource returns the resources/read contents entry for relPath. Spans
+// go through OpenFile and always yield text; whole files are returned as text
+// when they are valid UTF-8 of a textual type and as a base64 blob otherwise.
+func (s *Server) readResource(ctx context.Context, uri, relPath string, span model.Span) (map[string]interface{}, error) {
+	mimeType := resourceMIMEType(relPath)
+	bytesReader, canReadBytes := s.retriever.(retrieverOpenFileBytes)
+	if span.Kind != "" || !canReadBytes {
+		var (
+			text string
+			err  error
+		)
+		if withMeta, ok := s.retriever.(retrieverOpenFileWithMeta); ok {
+			text, _, err = withMeta.OpenFileWithMeta(ctx, relPath, span, resourceMaxChars)
+		} else {
+			text, err = s.retriever.OpenFile(ctx, relPath, span, resourceMaxChars)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if span.Kind == "page" || span.Kind == "time" || !isTextMIMEType(mimeType) {
+			mimeType = "text/plain"
+		}
+		return map[string]interface{}{"uri": uri, "mimeType": mimeType, "text": text}, nil
+	}
+
+	// read one byte past the cap so a file of exactly resourceMaxBytes is not
+	// mistaken for a truncated one.
+	data, truncated, err := bytesReader.OpenFileBytes(ctx, relPath, resourceMaxBytes+1)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, errResourceTooLarge
+	}
+	if (mimeType == "" || isTextMIMEType(mimeType)) && utf8.Valid(data) {
+		if mimeType == "" {
+			mimeType = "text/plain"
+		}
+		return map[string]interface{}{"uri": uri, "mimeType": mimeType, "text": string(data)}, nil
+	}
+	if mimeType == "" || isTextMIMEType(mimeType) {
+		mimeType = "application/octet-stream"
+	}
+	return map[string]interface{}{"uri": uri, "mimeType": mimeType, "blob": base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+// resourceURI returns the dir2mcp://file/ URI for relPath, escaping each path
+// segment.
+func resourceURI(relPath string) string {
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return protocol.ResourceURIPrefix + strings.Join(segments, "/")
+}
+
+// parseResourceURI splits a dir2mcp://file/ URI into its rel_path and the span
+// named by the fragment: L<start>-<end> (or L<line>), page=<n> or
+// t=<start_ms>-<end_ms>.
+func parseResourceURI(uri string) (string, model.Span, error) {
+	if !strings.HasPrefix(uri, protocol.ResourceURIPrefix) {
+		return "", model.Span{}, fmt.Errorf("uri must start with %s", protocol.ResourceURIPrefix)
+	}
+	rest := strings.TrimPrefix(uri, protocol.ResourceURIPrefix)
+	rawPath, fragment, _ := strings.Cut(rest, "#")
+	relPath, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return "", model.Span{}, fmt.Errorf("invalid uri path: %v", err)
+	}
+	if strings.TrimSpace(relPath) == "" {
+		return "", model.Span{}, errors.New("uri must name a file")
+	}
+	span, err := parseResourceFragment(fragment)
+	if err != nil {
+		return "", model.Span{}, err
+	}
+	return relPath, span, nil
+}
+
+func parseResourceFragment(fragment string) (model.Span, error) {
+	switch {
+	case fragment == "":
+		return model.Span{}, nil
+	case strings.HasPrefix(fragment, "L"):
+		start, end, err := parseResourceRange(strings.TrimPrefix(fragment, "L"), "L", true)
+		if err != nil {
+			return model.Span{}, err
+		}
+		if start <= 0 {
+			return model.Span{}, errors.New("line numbers must be > 0")
+		}
+		return model.Span{Kind: "lines", StartLine: start, EndLine: end}, nil
+	case strings.HasPrefix(fragment, "page="):
+		page, err := strconv.Atoi(strings.TrimPrefix(fragment, "page="))
+		if err != nil || page <= 0 {
+			return model.Span{}, errors.New("page must be a positive integer")
+		}
+		return model.Span{Kind: "page", Page: page}, nil
+	case strings.HasPrefix(fragment, "t="):
+		start, end, err := parseResourceRange(strings.TrimPrefix(fragment, "t="), "t=", false)
+		if err != nil {
+			return model.Span{}, err
+		}
+		return model.Span{Kind: "time", StartMS: start, EndMS: end}, nil
+	default:
+		return model.Span{}, fmt.Errorf("unsupported uri fragment %q", fragment)
+	}
+}
+
+// parseResourceRange parses "<start>-<end>", or a lone "<start>" when
+// allowSingle is set, as a non-negative ascending range.
+func parseResourceRange(value, prefix string, allowSingle bool) (int, int, error) {
+	rawStart, rawEnd, hasEnd := strings.Cut(value, "-")
+	if !hasEnd && allowSingle {
+		rawEnd = rawStart
+	}
+	start, startErr := strconv.Atoi(rawStart)
+	end, endErr := strconv.Atoi(rawEnd)
+	if startErr != nil || endErr != nil || start < 0 || end < 0 {
+		return 0, 0, fmt.Errorf("fragment %s must be <start>-<end> with non-negative integers", prefix)
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("fragment %s start must be <= end", prefix)
+	}
+	return start, end, nil
+}
+
+// resourceMIMEType maps a file extension to the MIME type reported for its
+// resource. Source code is served as text/plain; unknown extensions return
+// "" and are classified by content when read.
+func resourceMIMEType(relPath string) string {
+	ext := strings.ToLower(filepath.Ext(relPath))
+	switch ext {
+	case ".md":
+		return "text/markdown"
+	case ".txt", ".rst":
+		return "text/plain"
+	case ".html", ".htm":
+		return "text/html"
+	case ".css":
+		return "text/css"
+	case ".csv":
+		return "text/csv"
+	case ".json":
+		return "application/json"
+	case ".yaml", ".yml":
+		return "application/yaml"
+	case ".xml":
+		return "application/xml"
+	case ".pdf":
+		return "application/pdf"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".wav":
+		return "audio/wav"
+	case ".m4a":
+		return "audio/mp4"
+	case ".flac":
+		return "audio/flac"
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	}
+	if inferDocType(relPath) == "code" {
+		return "text/plain"
+	}
+	return ""
+}
+
+func isTextMIMEType(mimeType string) bool {
+	switch mimeType {
+	case "application/json", "application/yaml", "application/xml":
+		return true
+	}
+	return strings.HasPrefix(mimeType, "text/")
+}
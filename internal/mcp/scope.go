@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+	"dir2mcp/internal/tokens"
+)
+
+const (
+	// scopedSearchOversample widens a path-scoped search so that enough hits
+	// survive the scope filter.
+	scopedSearchOversample = 4
+	scopedSearchMaxK       = 200
+	// scopedListPageSize is the page size used to walk the store when
+	// list_files results have to be filtered by path scope.
+	scopedListPageSize = 1000
+)
+
+// pathScopedTools are the tools that enforce a token's path scope. A token
+// limited to some paths can call only these; the others read across the
+// whole corpus.
+var pathScopedTools = map[string]struct{}{
+	protocol.ToolNameSearch:    {},
+	protocol.ToolNameOpenFile:  {},
+	protocol.ToolNameListFiles: {},
+}
+
+type accessTokenContextKey struct{}
+
+// withAccessToken records the scoped token that authorized a request. A
+// request authorized by the server token, or with auth disabled, carries
+// none and has full access.
+func withAccessToken(ctx context.Context, token *tokens.Token) context.Context {
+	if token == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, accessTokenContextKey{}, token)
+}
+
+func accessTokenFromContext(ctx context.Context) *tokens.Token {
+	token, _ := ctx.Value(accessTokenContextKey{}).(*tokens.Token)
+	return token
+}
+
+// toolAllowed reports whether the request's token may call tool.
+func toolAllowed(ctx context.Context, tool string) bool {
+	token := accessTokenFromContext(ctx)
+	if token == nil {
+		return true
+	}
+	if !token.AllowsTool(tool) {
+		return false
+	}
+	if token.PathScoped() {
+		_, ok := pathScopedTools[tool]
+		return ok
+	}
+	return true
+}
+
+// pathAllowed reports whether the request's token may see relPath.
+func pathAllowed(ctx context.Context, relPath string) bool {
+	token := accessTokenFromContext(ctx)
+	return token == nil || token.AllowsPath(relPath)
+}
+
+// resourcesAllowed reports whether the request's token may use resources
+// and prompts. Both read files directly, so they need open_file and a token
+// that is not limited to some paths.
+func resourcesAllowed(ctx context.Context) bool {
+	token := accessTokenFromContext(ctx)
+	return token == nil || (token.AllowsTool(protocol.ToolNameOpenFile) && !token.PathScoped())
+}
+
+func toolForbiddenError(tool string) *toolExecutionError {
+	return &toolExecutionError{
+		Code:      protocol.ErrorCodePermissionDenied,
+		Message:   "token scope does not allow " + tool,
+		Retryable: false,
+	}
+}
+
+// scopedSearchK returns the K to ask the retriever for so that k hits are
+// likely to remain after filterHitsByScope.
+func scopedSearchK(ctx context.Context, k int) int {
+	token := accessTokenFromContext(ctx)
+	if token == nil || !token.PathScoped() {
+		return k
+	}
+	return min(k*scopedSearchOversample, scopedSearchMaxK)
+}
+
+// filterHitsByScope drops hits outside the token's paths and keeps at most k.
+func filterHitsByScope(ctx context.Context, hits []model.SearchHit, k int) []model.SearchHit {
+	token := accessTokenFromContext(ctx)
+	if token == nil || !token.PathScoped() {
+		return hits
+	}
+	filtered := make([]model.SearchHit, 0, min(len(hits), k))
+	for _, hit := range hits {
+		if len(filtered) == k {
+			break
+		}
+		if token.AllowsPath(hit.RelPath) {
+			filtered = append(filtered, hit)
+		}
+	}
+	return filtered
+}
+
+// listFilesInScope pages through the store and returns the window
+// [offset, offset+limit) of the files the token may see, with their total.
+func (s *Server) listFilesInScope(ctx context.Context, token *tokens.Token, prefix, glob string, limit, offset int) ([]model.Document, int64, error) {
+	docs := make([]model.Document, 0, limit)
+	var total int64
+	for page := 0; ; page += scopedListPageSize {
+		listed, _, err := s.store.ListFiles(ctx, prefix, glob, scopedListPageSize, page)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, doc := range listed {
+			if !token.AllowsPath(doc.RelPath) {
+				continue
+			}
+			if total >= int64(offset) && len(docs) < limit {
+				docs = append(docs, doc)
+			}
+			total++
+		}
+		if len(listed) < scopedListPageSize {
+			return docs, total, nil
+		}
+	}
+}
+
+// tokenRateLimiter enforces the per-token rate limits of scoped tokens.
+type tokenRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newTokenRateLimiter() *tokenRateLimiter {
+	return &tokenRateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+func (l *tokenRateLimiter) allow(token *tokens.Token, now time.Time) bool {
+	if token.RateLimitRPS <= 0 || token.RateLimitBurst <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, exists := l.buckets[token.ID]
+	if !exists {
+		l.buckets[token.ID] = newTokenBucket(token.RateLimitBurst, now)
+		return true
+	}
+	return bucket.take(now, token.RateLimitRPS, token.RateLimitBurst)
+}
+
+func (l *tokenRateLimiter) cleanup(maxAge time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, bucket := range l.buckets {
+		if now.Sub(bucket.lastTime) > maxAge {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// authenticateScopedToken checks a bearer token against the token store
+// and its rate limit. It writes the error response itself when it fails.
+func (s *Server) authenticateScopedToken(w http.ResponseWriter, secret string) (*tokens.Token, bool) {
+	now := time.Now()
+	token, err := s.tokens.Authenticate(secret, now)
+	if err != nil {
+		if !errors.Is(err, tokens.ErrUnknownToken) && s.eventEmitter != nil {
+			s.eventEmitter("warn", "token_rejected", map[string]interface{}{
+				"reason": err.Error(),
+			})
+		}
+		writeError(w, http.StatusUnauthorized, nil, -32000, "missing or invalid bearer token", protocol.ErrorCodeUnauthorized, false)
+		return nil, false
+	}
+	if !s.tokenLimiter.allow(&token, now) {
+		s.observeRateLimited("token")
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, nil, -32000, "rate limit exceeded", protocol.ErrorCodeRateLimitExceeded, true)
+		return nil, false
+	}
+	return &token, true
+}
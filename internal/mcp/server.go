@@ -23,9 +23,14 @@ import (
 	"time"
 
 	"dir2mcp/internal/appstate"
+	"dir2mcp/internal/audit"
 	"dir2mcp/internal/config"
+	"dir2mcp/internal/metrics"
 	"dir2mcp/internal/model"
+	"dir2mcp/internal/oauth"
 	"dir2mcp/internal/protocol"
+	"dir2mcp/internal/sessions"
+	"dir2mcp/internal/tokens"
 	"dir2mcp/internal/x402"
 )
 
@@ -50,13 +55,55 @@ const DefaultSearchK = 10
 // MaxSearchK is the highest allowed k value for search/ask requests.
 const MaxSearchK = 50
 
+// Limits for dir2mcp.grep. They bound the work a single call can cause, which
+// matters most on public servers.
+const (
+	DefaultGrepMaxMatches = 100
+	MaxGrepMatches        = 1000
+	MaxGrepContextLines   = 10
+	MaxGrepPatternLength  = 1024
+	// GrepTimeout caps how long one grep call may scan; matches found
+	// before it expires are returned with timed_out set.
+	GrepTimeout = 10 * time.Second
+)
+
+// Limits for dir2mcp.symbols and dir2mcp.definition results.
+const (
+	DefaultSymbolLimit  = 50
+	MaxSymbolLimit      = 200
+	MaxSymbolNameLength = 256
+)
+
 // sessionInfo holds metadata tracked for each active session.  `created` is the
 // time the session was started; `lastSeen` is updated on each successful
 // request.  The server uses both values to enforce inactivity timeouts and
-// optional absolute lifetimes.
+// optional absolute lifetimes.  conversations holds the session's ask
+// histories; it is dropped together with the session entry so histories
+// expire with the session.  requests tracks the requests in flight so
+// notifications/cancelled can end them.  protocolVersion and the client
+// fields record what initialize negotiated.
 type sessionInfo struct {
-	created  time.Time
-	lastSeen time.Time
+	created         time.Time
+	lastSeen        time.Time
+	protocolVersion string
+	clientName      string
+	clientVersion   string
+	conversations   *sessionConversations
+	resources       *sessionResources
+	requests        *sessionRequests
+}
+
+func newSessionInfo(created time.Time, protocolVersion, clientName, clientVersion string) sessionInfo {
+	return sessionInfo{
+		created:         created,
+		lastSeen:        created,
+		protocolVersion: protocolVersion,
+		clientName:      clientName,
+		clientVersion:   clientVersion,
+		conversations:   newSessionConversations(),
+		resources:       newSessionResources(),
+		requests:        newSessionRequests(),
+	}
 }
 
 type Server struct {
@@ -67,6 +114,7 @@ type Server struct {
 	indexing  *appstate.IndexingState
 	tts       TTSSynthesizer
 	tools     map[string]toolDefinition
+	prompts   []promptDefinition
 
 	sessionMu sync.RWMutex
 	// sessions maps session IDs to metadata.  lastSeen is updated on each
@@ -74,9 +122,22 @@ type Server struct {
 	// initialized.  We use both values to enforce inactivity timeouts and
 	// optional absolute lifetimes.
 	sessions map[string]sessionInfo
+	// sessionStore, when set, is the authoritative record of sessions;
+	// the map above then caches per-process state.
+	sessionStore *sessions.Store
 
 	rateLimiter *ipRateLimiter
 
+	// tokens holds the scoped API tokens accepted next to authToken.
+	tokens       *tokens.Store
+	tokenLimiter *tokenRateLimiter
+	// oauth validates JWT access tokens when AuthMode is "oauth".
+	oauth *oauth.Validator
+
+	// auditLog, when set, receives one entry per JSON-RPC request.
+	auditLog *audit.Logger
+	metrics  *metrics.Metrics
+
 	x402Client      *x402.HTTPClient
 	x402Requirement x402.Requirement
 	x402Enabled     bool
@@ -102,6 +163,12 @@ type Server struct {
 	execKeyMu map[string]*keyMutex
 
 	eventEmitter func(level, event string, data interface{})
+
+	// resourceEvents feeds the GET streams; streamsDone is closed on
+	// shutdown so open streams end instead of holding the server open.
+	resourceEvents  *resourceEventLog
+	streamsDone     chan struct{}
+	closeStreamOnce sync.Once
 }
 
 type rpcRequest struct {
@@ -187,12 +254,16 @@ func NewServer(cfg config.Config, retriever model.Retriever, opts ...ServerOptio
 	s := &Server{
 		cfg:             cfg,
 		authToken:       loadAuthToken(cfg),
+		tokens:          tokens.NewStore(filepath.Join(cfg.StateDir, tokens.FileName)),
+		tokenLimiter:    newTokenRateLimiter(),
 		retriever:       retriever,
 		sessions:        make(map[string]sessionInfo),
 		paymentOutcomes: make(map[string]paymentExecutionOutcome),
 		paymentTTL:      paymentOutcomeTTL,
 		paymentMaxItems: paymentOutcomeMaxEntries,
 		execKeyMu:       make(map[string]*keyMutex),
+		resourceEvents:  newResourceEventLog(),
+		streamsDone:     make(chan struct{}),
 	}
 	// cond must be set after the zero-value mutex has been created above
 	s.execCond = sync.NewCond(&s.execMu)
@@ -207,14 +278,24 @@ func NewServer(cfg config.Config, retriever model.Retriever, opts ...ServerOptio
 	if cfg.Public && cfg.RateLimitRPS > 0 && cfg.RateLimitBurst > 0 {
 		s.rateLimiter = newIPRateLimiter(float64(cfg.RateLimitRPS), cfg.RateLimitBurst, cfg.TrustedProxies)
 	}
+	if strings.EqualFold(cfg.AuthMode, "oauth") {
+		// callers validate the settings up front; this only fills defaults.
+		_ = s.cfg.ValidateOAuth()
+		s.oauth = oauth.NewValidator(s.cfg.OAuth, nil)
+	}
 	s.initPaymentConfig()
 	s.tools = s.buildToolRegistry()
+	s.prompts = buildPromptRegistry(cfg.Prompts)
 	return s
 }
 
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc(s.cfg.MCPPath, s.handleMCP)
+	if s.oauthEnabled() {
+		mux.HandleFunc(protectedResourceMetadataPath, s.handleProtectedResourceMetadata)
+		mux.HandleFunc(protectedResourceMetadataPath+s.cfg.MCPPath, s.handleProtectedResourceMetadata)
+	}
 	return s.corsMiddleware(mux)
 }
 
@@ -226,9 +307,9 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		origin := strings.TrimSpace(r.Header.Get("Origin"))
 		if origin != "" && isOriginAllowed(origin, s.cfg.AllowedOrigins) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
-			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", fmt.Sprintf("Content-Type, Authorization, %s, %s, PAYMENT-SIGNATURE", protocol.MCPProtocolVersionHeader, protocol.MCPSessionHeader))
-			w.Header().Set("Access-Control-Expose-Headers", protocol.MCPSessionHeader+", PAYMENT-REQUIRED, PAYMENT-RESPONSE, "+protocol.MCPSessionExpiredHeader)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", fmt.Sprintf("Content-Type, Authorization, %s, %s, Last-Event-ID, PAYMENT-SIGNATURE", protocol.MCPProtocolVersionHeader, protocol.MCPSessionHeader))
+			w.Header().Set("Access-Control-Expose-Headers", protocol.MCPSessionHeader+", PAYMENT-REQUIRED, PAYMENT-RESPONSE, WWW-Authenticate, "+protocol.MCPSessionExpiredHeader)
 			w.Header().Set("Access-Control-Max-Age", "86400")
 			w.Header().Set("Vary", "Origin")
 		}
@@ -272,9 +353,7 @@ func (s *Server) RunOnListener(ctx context.Context, ln net.Listener) error {
 	defer cancel()
 
 	go s.runSessionCleanup(runCtx)
-	if s.rateLimiter != nil {
-		go s.runRateLimitCleanup(runCtx)
-	}
+	go s.runRateLimitCleanup(runCtx)
 	if s.x402Enabled {
 		go s.runPaymentOutcomeCleanup(runCtx)
 	}
@@ -286,6 +365,7 @@ func (s *Server) RunOnListener(ctx context.Context, ln net.Listener) error {
 		WriteTimeout:      30 * time.Second,
 		IdleTimeout:       2 * time.Minute,
 	}
+	server.RegisterOnShutdown(s.closeEventStreams)
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -310,14 +390,27 @@ func (s *Server) RunOnListener(ctx context.Context, ln net.Listener) error {
 func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 	if s.rateLimiter != nil {
 		if !s.rateLimiter.allow(realIP(r, s.rateLimiter)) {
+			s.observeRateLimited("ip")
 			w.Header().Set("Retry-After", "1")
 			writeError(w, http.StatusTooManyRequests, nil, -32000, "rate limit exceeded", protocol.ErrorCodeRateLimitExceeded, true)
 			return
 		}
 	}
 
+	// event streams are audited too; their entry is written when they close.
+	w, r, finishRecord := s.beginRequestRecord(w, r, realIP(r, s.rateLimiter))
+	defer finishRecord()
+
+	if r.Method == http.MethodGet && s.resourcesEnabled() {
+		s.handleEventStream(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
-		w.Header().Set("Allow", http.MethodPost)
+		if s.resourcesEnabled() {
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		} else {
+			w.Header().Set("Allow", http.MethodPost)
+		}
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
@@ -328,7 +421,8 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !s.authorize(w, r) {
+	r, ok := s.authorize(w, r)
+	if !ok {
 		return
 	}
 
@@ -336,6 +430,12 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.handleRPC(w, r)
+}
+
+// handleRPC parses and dispatches one JSON-RPC message. Transport checks
+// (rate limits, auth, origin) are the caller's job.
+func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
 	req, parseErr := parseRequest(r.Body)
 	if parseErr != nil {
 		canonicalCode := "INVALID_FIELD"
@@ -363,6 +463,8 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx := r.Context()
+	s.recordAuditRequest(ctx, req.Method, strings.TrimSpace(r.Header.Get(protocol.MCPSessionHeader)))
 	if req.Method != "initialize" {
 		sessionID := strings.TrimSpace(r.Header.Get(protocol.MCPSessionHeader))
 		if sessionID == "" {
@@ -377,29 +479,85 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, id, -32001, "session not found", protocol.ErrorCodeSessionNotFound, false)
 			return
 		}
+		ctx = withSessionID(ctx, sessionID)
+		if hasID && id != nil {
+			var done func()
+			ctx, done = s.trackRequest(ctx, id)
+			defer done()
+		}
 	}
 
 	switch req.Method {
 	case "initialize":
-		s.handleInitialize(w, id, hasID)
+		s.handleInitialize(w, id, hasID, req.Params, strings.TrimSpace(r.Header.Get(protocol.MCPSessionHeader)))
 	case "notifications/initialized":
 		if !hasID {
 			w.WriteHeader(http.StatusAccepted)
 			return
 		}
 		writeResult(w, http.StatusOK, id, map[string]interface{}{})
+	case protocol.RPCMethodCancelled:
+		if hasID {
+			writeError(w, http.StatusOK, id, -32600, "notifications/cancelled must not have an id", "INVALID_FIELD", false)
+			return
+		}
+		s.handleCancelled(ctx, req.Params)
+		w.WriteHeader(http.StatusAccepted)
 	case "tools/list":
 		if !hasID {
 			w.WriteHeader(http.StatusAccepted)
 			return
 		}
-		s.handleToolsList(w, id)
+		s.handleToolsList(ctx, w, id)
 	case "tools/call":
 		if !hasID {
 			w.WriteHeader(http.StatusAccepted)
 			return
 		}
-		s.handleToolsCallRequest(r.Context(), w, r, req.Params, id)
+		s.handleToolsCallRequest(ctx, w, r, req.Params, id)
+	case "resources/list", "resources/templates/list", "resources/read", "resources/subscribe", "resources/unsubscribe":
+		if !hasID {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if !s.resourcesEnabled() {
+			writeError(w, http.StatusOK, id, -32601, "method not found", "METHOD_NOT_FOUND", false)
+			return
+		}
+		if !resourcesAllowed(ctx) {
+			writeError(w, http.StatusForbidden, id, -32000, "token scope does not allow resources", protocol.ErrorCodePermissionDenied, false)
+			return
+		}
+		switch req.Method {
+		case "resources/list":
+			s.handleResourcesList(ctx, w, req.Params, id)
+		case "resources/templates/list":
+			s.handleResourceTemplatesList(w, id)
+		case "resources/subscribe":
+			s.handleResourceSubscription(ctx, w, req.Params, id, true)
+		case "resources/unsubscribe":
+			s.handleResourceSubscription(ctx, w, req.Params, id, false)
+		default:
+			s.handleResourcesRead(ctx, w, req.Params, id)
+		}
+	case "prompts/list", "prompts/get":
+		if !hasID {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if !s.promptsEnabled() {
+			writeError(w, http.StatusOK, id, -32601, "method not found", "METHOD_NOT_FOUND", false)
+			return
+		}
+		if !resourcesAllowed(ctx) {
+			writeError(w, http.StatusForbidden, id, -32000, "token scope does not allow prompts", protocol.ErrorCodePermissionDenied, false)
+			return
+		}
+		if req.Method == "prompts/list" {
+			s.handlePromptsList(w, id)
+		} else {
+			s.handlePromptsGet(ctx, w, req.Params, id)
+		}
 	default:
 		if !hasID {
 			w.WriteHeader(http.StatusAccepted)
@@ -409,7 +567,9 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) handleInitialize(w http.ResponseWriter, id interface{}, hasID bool) {
+// handleInitialize starts a new session. A client that initializes again
+// while presenting previousSessionID replaces that session, which ends.
+func (s *Server) handleInitialize(w http.ResponseWriter, id interface{}, hasID bool, rawParams json.RawMessage, previousSessionID string) {
 	if !hasID {
 		writeError(w, http.StatusBadRequest, nil, -32600, "initialize requires id", "MISSING_FIELD", false)
 		return
@@ -420,16 +580,32 @@ func (s *Server) handleInitialize(w http.ResponseWriter, id interface{}, hasID b
 		writeError(w, http.StatusInternalServerError, id, -32603, "failed to initialize session", "", false)
 		return
 	}
-	s.storeSession(sessionID)
+	if previousSessionID != "" {
+		s.endSession(previousSessionID)
+	}
+	s.createSession(sessionID, parseInitializeParams(rawParams))
+
+	capabilities := map[string]interface{}{
+		"tools": map[string]interface{}{
+			"listChanged": false,
+		},
+	}
+	if s.resourcesEnabled() {
+		capabilities["resources"] = map[string]interface{}{
+			"subscribe":   true,
+			"listChanged": true,
+		}
+	}
+	if s.promptsEnabled() {
+		capabilities["prompts"] = map[string]interface{}{
+			"listChanged": false,
+		}
+	}
 
 	w.Header().Set(protocol.MCPSessionHeader, sessionID)
 	writeResult(w, http.StatusOK, id, map[string]interface{}{
 		"protocolVersion": s.cfg.ProtocolVersion,
-		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{
-				"listChanged": false,
-			},
-		},
+		"capabilities":    capabilities,
 		"serverInfo": map[string]interface{}{
 			"name":    "dir2mcp",
 			"title":   "dir2mcp: Directory RAG MCP Server",
@@ -439,9 +615,12 @@ func (s *Server) handleInitialize(w http.ResponseWriter, id interface{}, hasID b
 	})
 }
 
-func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+// authorize checks the bearer token of r. The server token grants full
+// access; a scoped token from the token store is attached to the returned
+// request's context so handlers can enforce its scopes.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
 	if strings.EqualFold(s.cfg.AuthMode, "none") {
-		return true
+		return r, true
 	}
 
 	expectedToken := s.authToken
@@ -449,22 +628,31 @@ func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
 	const bearerPrefix = "bearer "
 
 	if len(authHeader) < len(bearerPrefix) || strings.ToLower(authHeader[:len(bearerPrefix)]) != bearerPrefix {
+		if s.oauthEnabled() {
+			return s.authorizeOAuth(w, r, "")
+		}
 		writeError(w, http.StatusUnauthorized, nil, -32000, "missing or invalid bearer token", protocol.ErrorCodeUnauthorized, false)
-		return false
+		return r, false
 	}
 
 	providedToken := strings.TrimSpace(authHeader[len(bearerPrefix):])
-	if expectedToken == "" || providedToken == "" {
+	if s.oauthEnabled() {
+		return s.authorizeOAuth(w, r, providedToken)
+	}
+	if providedToken == "" {
 		writeError(w, http.StatusUnauthorized, nil, -32000, "missing or invalid bearer token", protocol.ErrorCodeUnauthorized, false)
-		return false
+		return r, false
 	}
 
-	if subtle.ConstantTimeCompare([]byte(providedToken), []byte(expectedToken)) != 1 {
-		writeError(w, http.StatusUnauthorized, nil, -32000, "missing or invalid bearer token", protocol.ErrorCodeUnauthorized, false)
-		return false
+	if expectedToken != "" && subtle.ConstantTimeCompare([]byte(providedToken), []byte(expectedToken)) == 1 {
+		return r, true
 	}
 
-	return true
+	token, ok := s.authenticateScopedToken(w, providedToken)
+	if !ok {
+		return r, false
+	}
+	return r.WithContext(withAccessToken(r.Context(), token)), true
 }
 
 func (s *Server) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
@@ -583,6 +771,13 @@ func (s *Server) hasActiveSession(id string, now time.Time) (bool, string) {
 
 	inactivity, maxLife := s.resolveSessionTimeouts()
 
+	if s.sessionStore != nil {
+		if active, reason, ok := s.touchStoredSession(id, now, inactivity, maxLife); ok {
+			return active, reason
+		}
+		// the store is unavailable; fall back to this process's sessions.
+	}
+
 	s.sessionMu.Lock()
 	defer s.sessionMu.Unlock()
 
@@ -608,10 +803,26 @@ func (s *Server) hasActiveSession(id string, now time.Time) (bool, string) {
 }
 
 func (s *Server) storeSession(id string) {
+	s.createSession(id, initializeParams{})
+}
+
+// createSession records a new session in memory and, when configured, in
+// the session store.
+func (s *Server) createSession(id string, params initializeParams) {
+	si := newSessionInfo(time.Now(), s.cfg.ProtocolVersion, params.ClientInfo.Name, params.ClientInfo.Version)
 	s.sessionMu.Lock()
-	defer s.sessionMu.Unlock()
-	now := time.Now()
-	s.sessions[id] = sessionInfo{created: now, lastSeen: now}
+	s.sessions[id] = si
+	s.sessionMu.Unlock()
+	s.persistSession(id, si)
+}
+
+// endSession forgets id in memory and, when configured, in the session
+// store, so no server accepts it any longer.
+func (s *Server) endSession(id string) {
+	s.sessionMu.Lock()
+	delete(s.sessions, id)
+	s.sessionMu.Unlock()
+	s.forgetStoredSession(id)
 }
 
 func (s *Server) runSessionCleanup(ctx context.Context) {
@@ -665,13 +876,18 @@ func (s *Server) runRateLimitCleanup(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.rateLimiter.cleanup(rateLimitBucketMaxAge)
+			s.tokenLimiter.cleanup(rateLimitBucketMaxAge)
 		}
 	}
 }
 
 func (s *Server) cleanupExpiredSessions(now time.Time) {
-	// mirror the logic from hasActiveSession but without logging or updating
+	// mirror the logic from hasActiveSession but without logging or updating.
+	// deleting a session also drops its conversation histories.
 	inactivity, maxLife := s.resolveSessionTimeouts()
+	if s.sessionStore != nil {
+		s.sweepStoredSessions(now, inactivity, maxLife)
+	}
 
 	s.sessionMu.Lock()
 	defer s.sessionMu.Unlock()
@@ -829,6 +1045,11 @@ func isOriginAllowed(origin string, allowlist []string) bool {
 // persisted. It acquires the paymentLogMu mutex, clears
 // s.paymentLogWriter and s.paymentLogFile under that lock, and returns a
 // combined error using errors.Join if flushing or closing fails.
+// closeEventStreams ends every open GET stream.
+func (s *Server) closeEventStreams() {
+	s.closeStreamOnce.Do(func() { close(s.streamsDone) })
+}
+
 func (s *Server) Close() error {
 	s.paymentLogMu.Lock()
 	defer s.paymentLogMu.Unlock()
@@ -1,10 +1,12 @@
 package mcp
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"dir2mcp/internal/config"
+	"dir2mcp/internal/model"
 )
 
 // The six legacy tests below described various combinations of the
@@ -92,3 +94,42 @@ func TestSessionSweepInterval(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanupExpiredSessionsDropsConversations(t *testing.T) {
+	cfg := config.Default()
+	cfg.SessionInactivityTimeout = time.Minute
+	s := NewServer(cfg, nil)
+	s.storeSession("sess-1")
+	conversations := s.conversationsForSession("sess-1")
+	if conversations == nil {
+		t.Fatal("expected conversation store for new session")
+	}
+	conversations.append("conv", model.ConversationTurn{Question: "q", Answer: "a"})
+
+	s.cleanupExpiredSessions(time.Now().Add(2 * time.Minute))
+
+	if got := s.conversationsForSession("sess-1"); got != nil {
+		t.Fatalf("expected conversations dropped with the session, got %+v", got.history("conv"))
+	}
+}
+
+func TestSessionConversationsAreBounded(t *testing.T) {
+	c := newSessionConversations()
+	for i := 0; i < maxConversationTurns+3; i++ {
+		c.append("conv", model.ConversationTurn{Question: fmt.Sprintf("q%d", i)})
+	}
+	history := c.history("conv")
+	if len(history) != maxConversationTurns || history[0].Question != "q3" {
+		t.Fatalf("expected the %d most recent turns, got %+v", maxConversationTurns, history)
+	}
+
+	for i := 0; i < maxConversationsPerSession; i++ {
+		c.append(fmt.Sprintf("other-%d", i), model.ConversationTurn{Question: "q"})
+	}
+	if len(c.byID) != maxConversationsPerSession {
+		t.Fatalf("expected at most %d conversations, got %d", maxConversationsPerSession, len(c.byID))
+	}
+	if got := c.history("conv"); got != nil {
+		t.Fatalf("expected least recently used conversation evicted, got %+v", got)
+	}
+}
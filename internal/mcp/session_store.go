@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"dir2mcp/internal/sessions"
+)
+
+// sessionStoreTimeout bounds one session store operation on the request
+// path.
+const sessionStoreTimeout = 5 * time.Second
+
+// maxClientInfoLength bounds the client name and version kept per session.
+const maxClientInfoLength = 256
+
+// WithSessionStore keeps sessions in st as well as in memory, so they
+// survive restarts and are shared with other servers using the same store.
+// Conversation histories, subscriptions and in-flight requests stay per
+// process.
+// The caller owns st and closes it after the server stops.
+func WithSessionStore(st *sessions.Store) ServerOption {
+	return func(s *Server) {
+		s.sessionStore = st
+	}
+}
+
+// initializeParams is the part of the initialize request the server keeps.
+// The negotiated protocol version is the server's own.
+type initializeParams struct {
+	ClientInfo struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"clientInfo"`
+}
+
+// parseInitializeParams reads the client info of an initialize request.
+// Malformed params are ignored; they only describe the client.
+func parseInitializeParams(raw json.RawMessage) initializeParams {
+	var params initializeParams
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &params)
+	}
+	params.ClientInfo.Name = truncateString(params.ClientInfo.Name, maxClientInfoLength)
+	params.ClientInfo.Version = truncateString(params.ClientInfo.Version, maxClientInfoLength)
+	return params
+}
+
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// persistSession writes a new session to the store. A failure leaves the
+// session usable in this process only.
+func (s *Server) persistSession(id string, si sessionInfo) {
+	if s.sessionStore == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	err := s.sessionStore.Create(ctx, sessions.Session{
+		ID:              id,
+		Created:         si.created,
+		LastSeen:        si.lastSeen,
+		ProtocolVersion: si.protocolVersion,
+		ClientName:      si.clientName,
+		ClientVersion:   si.clientVersion,
+	})
+	if err != nil {
+		s.warnSessionStore("create", err)
+	}
+}
+
+// touchStoredSession checks id against the store. ok is false when the
+// store could not be read, and the caller falls back to memory.
+func (s *Server) touchStoredSession(id string, now time.Time, inactivity, maxLife time.Duration) (active bool, reason string, ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	stored, reason, err := s.sessionStore.Touch(ctx, id, now, inactivity, maxLife)
+	if errors.Is(err, sessions.ErrNotFound) {
+		s.sessionMu.Lock()
+		delete(s.sessions, id)
+		s.sessionMu.Unlock()
+		if reason != "" {
+			log.Printf("session %s expired due to %s", maskSessionID(id), reason)
+		}
+		return false, reason, true
+	}
+	if err != nil {
+		s.warnSessionStore("touch", err)
+		return false, "", false
+	}
+
+	// the session may have been created before a restart or by another
+	// process; give it fresh per-process state.
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	si, exists := s.sessions[id]
+	if !exists {
+		si = newSessionInfo(stored.Created, stored.ProtocolVersion, stored.ClientName, stored.ClientVersion)
+	}
+	si.lastSeen = now
+	s.sessions[id] = si
+	return true, "", true
+}
+
+// forgetStoredSession deletes id from the store. A failure leaves it to
+// expire there.
+func (s *Server) forgetStoredSession(id string) {
+	if s.sessionStore == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	if err := s.sessionStore.Delete(ctx, id); err != nil {
+		s.warnSessionStore("delete", err)
+	}
+}
+
+// sweepStoredSessions deletes expired sessions from the store.
+func (s *Server) sweepStoredSessions(now time.Time, inactivity, maxLife time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	if _, err := s.sessionStore.DeleteExpired(ctx, now, inactivity, maxLife); err != nil {
+		s.warnSessionStore("sweep", err)
+	}
+}
+
+func (s *Server) warnSessionStore(operation string, err error) {
+	if s.eventEmitter != nil {
+		s.eventEmitter("warn", "session_store_failed", map[string]interface{}{
+			"operation": operation,
+			"error":     err.Error(),
+		})
+		return
+	}
+	log.Printf("session store %s failed: %v", operation, err)
+}
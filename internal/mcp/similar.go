@@ -0,0 +1,232 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+// retrieverFindSimilar is implemented by retrievers that can search with the
+// stored vectors of an indexed chunk or file.
+type retrieverFindSimilar interface {
+	FindSimilar(ctx context.Context, query model.SimilarQuery) (model.SimilarResult, error)
+}
+
+func (s *Server) handleFindSimilarTool(ctx context.Context, args map[string]interface{}) (toolCallResult, *toolExecutionError) {
+	if err := assertNoUnknownArguments(args, map[string]struct{}{
+		"chunk_id":        {},
+		"rel_path":        {},
+		"start_line":      {},
+		"end_line":        {},
+		"page":            {},
+		"start_ms":        {},
+		"end_ms":          {},
+		"k":               {},
+		"index":           {},
+		"path_prefix":     {},
+		"file_glob":       {},
+		"doc_types":       {},
+		"modified_after":  {},
+		"modified_before": {},
+		"min_size_bytes":  {},
+		"max_size_bytes":  {},
+		"statuses":        {},
+		"source_types":    {},
+		"rep_types":       {},
+		"languages":       {},
+	}); err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+
+	chunkID, hasChunkID, err := parseOptionalIntegerWithPresence(args, "chunk_id")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if hasChunkID && chunkID <= 0 {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "chunk_id must be > 0", Retryable: false}
+	}
+	relPath, err := parseOptionalString(args, "rel_path")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	relPath = strings.TrimSpace(relPath)
+	switch {
+	case hasChunkID && relPath != "":
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "provide only one of chunk_id or rel_path", Retryable: false}
+	case !hasChunkID && relPath == "":
+		return toolCallResult{}, &toolExecutionError{Code: "MISSING_FIELD", Message: "chunk_id or rel_path is required", Retryable: false}
+	}
+	span, toolErr := parseSpanArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	if hasChunkID && span.Kind != "" {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "span arguments require rel_path", Retryable: false}
+	}
+
+	k := DefaultSearchK
+	if rawK, exists := args["k"]; exists {
+		parsedK, parseErr := parseInteger(rawK, "k")
+		if parseErr != nil {
+			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: parseErr.Error(), Retryable: false}
+		}
+		k = parsedK
+	}
+	if k <= 0 {
+		k = DefaultSearchK
+	}
+	if k > 50 {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_RANGE", Message: "k must be between 1 and 50", Retryable: false}
+	}
+
+	indexName, err := parseOptionalString(args, "index")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	indexName = strings.ToLower(strings.TrimSpace(indexName))
+	if indexName == "" {
+		indexName = "auto"
+	}
+	switch indexName {
+	case "auto", "text", "code", "both":
+	default:
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "index must be one of auto,text,code,both", Retryable: false}
+	}
+
+	pathPrefix, err := parseOptionalString(args, "path_prefix")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	fileGlob, err := parseOptionalString(args, "file_glob")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	docTypes, err := parseOptionalStringSlice(args, "doc_types")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	metadataFilter, toolErr := parseMetadataFilterArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+
+	if s.retriever == nil {
+		return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever not configured", Retryable: false}
+	}
+	finder, ok := s.retriever.(retrieverFindSimilar)
+	if !ok {
+		return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever does not support find_similar", Retryable: false}
+	}
+	result, findErr := finder.FindSimilar(ctx, model.SimilarQuery{
+		ChunkID: uint64(chunkID),
+		RelPath: relPath,
+		Span:    span,
+		SearchQuery: model.SearchQuery{
+			K:              k,
+			Index:          indexName,
+			PathPrefix:     pathPrefix,
+			FileGlob:       fileGlob,
+			DocTypes:       docTypes,
+			MetadataFilter: metadataFilter,
+		},
+	})
+	if findErr != nil {
+		switch {
+		case errors.Is(findErr, model.ErrNotFound):
+			return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeFileNotFound, Message: "seed chunk or file has no indexed vectors", Retryable: false}
+		case errors.Is(findErr, model.ErrIndexNotReady), errors.Is(findErr, model.ErrIndexNotConfigured):
+			return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "index not ready", Retryable: true}
+		default:
+			return toolCallResult{}, &toolExecutionError{Code: "INTERNAL_ERROR", Message: "internal server error", Retryable: true}
+		}
+	}
+
+	indexingComplete := true
+	if ic, err := s.retriever.IndexingComplete(ctx); err == nil {
+		indexingComplete = ic
+	}
+	hitMaps := make([]map[string]interface{}, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		hitMaps = append(hitMaps, serializeHit(hit))
+	}
+	seed := map[string]interface{}{"chunk_ids": result.SeedChunkIDs}
+	if hasChunkID {
+		seed["chunk_id"] = chunkID
+	} else {
+		seed["rel_path"] = relPath
+		if span.Kind != "" {
+			seed["span"] = buildOpenFileSpan(span)
+		}
+	}
+	structured := map[string]interface{}{
+		"seed":              seed,
+		"k":                 k,
+		"index_used":        result.IndexUsed,
+		"hits":              hitMaps,
+		"indexing_complete": indexingComplete,
+	}
+
+	return toolCallResult{
+		Content: []toolContentItem{
+			{Type: "text", Text: fmt.Sprintf("found %d similar result(s)", len(result.Hits))},
+		},
+		StructuredContent: structured,
+	}, nil
+}
+
+func findSimilarInputSchema() map[string]interface{} {
+	properties := map[string]interface{}{
+		"chunk_id":    map[string]interface{}{"type": "integer", "minimum": 1},
+		"rel_path":    map[string]interface{}{"type": "string", "minLength": 1},
+		"start_line":  map[string]interface{}{"type": "integer", "minimum": 1},
+		"end_line":    map[string]interface{}{"type": "integer", "minimum": 1},
+		"page":        map[string]interface{}{"type": "integer", "minimum": 1},
+		"start_ms":    map[string]interface{}{"type": "integer", "minimum": 0},
+		"end_ms":      map[string]interface{}{"type": "integer", "minimum": 0},
+		"k":           map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50, "default": 10},
+		"index":       map[string]interface{}{"type": "string", "enum": []string{"auto", "text", "code", "both"}, "default": "auto"},
+		"path_prefix": map[string]interface{}{"type": "string"},
+		"file_glob":   map[string]interface{}{"type": "string"},
+		"doc_types":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	}
+	addMetadataFilterProperties(properties)
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+		"oneOf": []interface{}{
+			map[string]interface{}{"required": []string{"chunk_id"}},
+			map[string]interface{}{"required": []string{"rel_path"}},
+		},
+	}
+}
+
+func findSimilarOutputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"seed": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"chunk_id":  map[string]interface{}{"type": "integer"},
+					"rel_path":  map[string]interface{}{"type": "string"},
+					"span":      map[string]interface{}{"$ref": "#/definitions/Span"},
+					"chunk_ids": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+				},
+				"required": []string{"chunk_ids"},
+			},
+			"k":                 map[string]interface{}{"type": "integer"},
+			"index_used":        map[string]interface{}{"type": "string", "enum": []string{"text", "code", "both"}},
+			"hits":              map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/Hit"}},
+			"indexing_complete": map[string]interface{}{"type": "boolean"},
+		},
+		"required":    []string{"seed", "hits", "indexing_complete"},
+		"definitions": sharedDefinitions(),
+	}
+}
@@ -0,0 +1,264 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"dir2mcp/internal/protocol"
+)
+
+// ServeStdio serves MCP as newline-delimited JSON-RPC: one message per line
+// on in, and responses and notifications one per line on out. The process
+// that started the server is trusted, so rate limits, auth and origin checks
+// do not apply. ServeStdio returns once in reaches EOF and the requests in
+// flight have been answered, or when ctx ends.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	if s.x402Enabled {
+		return errors.New("x402 payment gating requires the HTTP transport")
+	}
+	defer func() {
+		if err := s.Close(); err != nil {
+			log.Printf("error closing payment log: %v", err)
+		}
+	}()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.runSessionCleanup(runCtx)
+
+	conn := &stdioConn{server: s, out: &stdioOutput{w: out}}
+	// the session lives as long as the connection.
+	defer conn.endSession()
+	lines := make(chan []byte)
+	readErrCh := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-runCtx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				readErrCh <- err
+				return
+			}
+		}
+	}()
+
+	var inFlight sync.WaitGroup
+	for {
+		select {
+		case <-runCtx.Done():
+			cancel()
+			inFlight.Wait()
+			return nil
+		case err := <-readErrCh:
+			inFlight.Wait()
+			return err
+		case line := <-lines:
+			conn.dispatch(runCtx, line, &inFlight)
+		}
+	}
+}
+
+// stdioConn is the single client of a stdio server. It remembers the
+// session created by initialize and attaches it to later messages.
+type stdioConn struct {
+	server *Server
+	out    *stdioOutput
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// dispatch handles one incoming line. Requests run concurrently;
+// initialize and notifications run inline so the session exists, and
+// notifications keep their order, before the next line is read.
+func (c *stdioConn) dispatch(ctx context.Context, line []byte, inFlight *sync.WaitGroup) {
+	var peek struct {
+		Method string          `json:"method"`
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	_ = json.Unmarshal(line, &peek)
+	if peek.Method == "" && (peek.Result != nil || peek.Error != nil) {
+		// a response to a server request; the server sends none.
+		return
+	}
+	if peek.Method == protocol.RPCMethodInitialize || len(peek.ID) == 0 {
+		c.handle(ctx, line)
+		return
+	}
+	inFlight.Add(1)
+	go func() {
+		defer inFlight.Done()
+		c.handle(ctx, line)
+	}()
+}
+
+func (c *stdioConn) handle(ctx context.Context, line []byte) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.server.cfg.MCPPath, bytes.NewReader(line))
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json, text/event-stream")
+	c.mu.Lock()
+	if c.sessionID != "" {
+		r.Header.Set(protocol.MCPSessionHeader, c.sessionID)
+	}
+	c.mu.Unlock()
+
+	w := newStdioResponseWriter(c.out)
+	aw, r, finishRecord := c.server.beginRequestRecord(w, r, "stdio")
+	c.server.handleRPC(aw, r)
+	_ = w.finish()
+	finishRecord()
+	if sessionID := w.Header().Get(protocol.MCPSessionHeader); sessionID != "" {
+		c.startSession(ctx, sessionID)
+	}
+}
+
+// startSession makes sessionID the connection's session and forwards its
+// resource notifications to out, as the GET stream does over HTTP.
+func (c *stdioConn) startSession(ctx context.Context, sessionID string) {
+	c.mu.Lock()
+	c.sessionID = sessionID
+	c.mu.Unlock()
+
+	resources := c.server.resourcesForSession(sessionID)
+	if resources == nil {
+		return
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream := &sessionStream{cancel: cancel}
+	resources.attachStream(stream)
+	lastID := c.server.resourceEvents.lastID()
+	go func() {
+		defer cancel()
+		defer resources.detachStream(stream)
+		c.server.pumpResourceEvents(streamCtx, newEventStream(newStdioResponseWriter(c.out)), resources, sessionID, lastID)
+	}()
+}
+
+// endSession ends the connection's session, if initialize created one.
+func (c *stdioConn) endSession() {
+	c.mu.Lock()
+	sessionID := c.sessionID
+	c.mu.Unlock()
+	if sessionID != "" {
+		c.server.endSession(sessionID)
+	}
+}
+
+// stdioOutput serializes lines written by concurrent requests.
+type stdioOutput struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *stdioOutput) writeLine(data []byte) error {
+	line := make([]byte, 0, len(data)+1)
+	line = append(append(line, data...), '\n')
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err := o.w.Write(line)
+	return err
+}
+
+// stdioResponseWriter lets the HTTP handlers answer a stdio message. A JSON
+// response becomes one line; a text/event-stream response becomes one line
+// per event, forwarded on every flush so progress arrives as it is sent.
+type stdioResponseWriter struct {
+	header http.Header
+	out    *stdioOutput
+	buf    bytes.Buffer
+}
+
+func newStdioResponseWriter(out *stdioOutput) *stdioResponseWriter {
+	return &stdioResponseWriter{header: make(http.Header), out: out}
+}
+
+func (w *stdioResponseWriter) Header() http.Header { return w.header }
+
+func (w *stdioResponseWriter) WriteHeader(int) {}
+
+func (w *stdioResponseWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+// FlushError is used by http.ResponseController; returning the write error
+// ends streams whose reader has gone away.
+func (w *stdioResponseWriter) FlushError() error {
+	if !w.streaming() {
+		return nil
+	}
+	return w.forwardEvents(false)
+}
+
+// finish writes whatever the handler left unflushed.
+func (w *stdioResponseWriter) finish() error {
+	if w.streaming() {
+		return w.forwardEvents(true)
+	}
+	data := bytes.TrimSpace(w.buf.Bytes())
+	w.buf.Reset()
+	if len(data) == 0 {
+		return nil
+	}
+	return w.out.writeLine(data)
+}
+
+func (w *stdioResponseWriter) streaming() bool {
+	return strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+}
+
+// forwardEvents writes the data of each complete SSE event in the buffer,
+// and of a trailing partial one when final is set. Comments such as
+// keepalives carry no data and are dropped.
+func (w *stdioResponseWriter) forwardEvents(final bool) error {
+	for {
+		raw := w.buf.Bytes()
+		end := bytes.Index(raw, []byte("\n\n"))
+		if end < 0 {
+			if !final {
+				return nil
+			}
+			event := append([]byte(nil), raw...)
+			w.buf.Reset()
+			return w.forwardEvent(event)
+		}
+		event := append([]byte(nil), raw[:end]...)
+		w.buf.Next(end + 2)
+		if err := w.forwardEvent(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *stdioResponseWriter) forwardEvent(event []byte) error {
+	var data [][]byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if rest, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimSpace(rest))
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return w.out.writeLine(bytes.Join(data, []byte("\n")))
+}
@@ -0,0 +1,242 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+// retrieverAskStream is implemented by retrievers that can deliver the
+// generated answer incrementally.
+type retrieverAskStream interface {
+	AskStream(ctx context.Context, question string, query model.SearchQuery, onDelta func(string)) (model.AskResult, error)
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// eventStream writes JSON-RPC messages as a text/event-stream response. The
+// stream opens with the first message, so a call that never sends progress
+// can still be answered with a plain JSON response.
+type eventStream struct {
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	isOpen bool
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	return &eventStream{w: w, rc: http.NewResponseController(w)}
+}
+
+func (e *eventStream) opened() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.isOpen
+}
+
+func (e *eventStream) send(message interface{}) error {
+	return e.sendEvent("", message)
+}
+
+// sendEvent writes message as one SSE event, tagged with id when id is not
+// empty so the client can resume after it with Last-Event-ID.
+func (e *eventStream) sendEvent(id string, message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.openLocked()
+	if id != "" {
+		if _, err := fmt.Fprintf(e.w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(e.w, "event: message\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	return e.rc.Flush()
+}
+
+// open starts the stream without sending a message.
+func (e *eventStream) open() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.openLocked()
+	return e.rc.Flush()
+}
+
+// keepAlive writes an SSE comment so idle connections are not dropped by
+// proxies and dead clients are noticed.
+func (e *eventStream) keepAlive() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.openLocked()
+	if _, err := fmt.Fprint(e.w, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	return e.rc.Flush()
+}
+
+func (e *eventStream) openLocked() {
+	if e.isOpen {
+		return
+	}
+	e.w.Header().Set("Content-Type", "text/event-stream")
+	e.w.Header().Set("Cache-Control", "no-cache")
+	// a stream may outlive the server's write timeout; the request context
+	// still bounds it.
+	_ = e.rc.SetWriteDeadline(time.Time{})
+	e.w.WriteHeader(http.StatusOK)
+	e.isOpen = true
+}
+
+// progressReporter sends notifications/progress for one tools/call request
+// that carried a progress token.
+type progressReporter struct {
+	mu       sync.Mutex
+	stream   *eventStream
+	token    interface{}
+	progress float64
+}
+
+type progressReporterContextKey struct{}
+
+func withProgressReporter(ctx context.Context, reporter *progressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterContextKey{}, reporter)
+}
+
+func progressReporterFromContext(ctx context.Context) *progressReporter {
+	reporter, _ := ctx.Value(progressReporterContextKey{}).(*progressReporter)
+	return reporter
+}
+
+// report advances progress by step and sends a notification carrying
+// message. Send failures are ignored: the client has gone away and the
+// request context will end the call.
+func (p *progressReporter) report(step float64, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress += step
+	params := map[string]interface{}{
+		"progressToken": p.token,
+		"progress":      p.progress,
+	}
+	if message != "" {
+		params["message"] = message
+	}
+	_ = p.stream.send(rpcNotification{JSONRPC: "2.0", Method: protocol.RPCMethodProgress, Params: params})
+}
+
+// progressHeartbeat is how often a step that waits on a provider repeats
+// its progress notification.
+const progressHeartbeat = 10 * time.Second
+
+// runWithProgress reports message as one step of a multi-step tool call and
+// runs fn. While fn runs the step is reported again every
+// progressHeartbeat with the elapsed time, so a slow provider call still
+// shows signs of life. Without a progress reporter it just runs fn.
+func runWithProgress(ctx context.Context, message string, fn func()) {
+	reporter := progressReporterFromContext(ctx)
+	if reporter == nil {
+		fn()
+		return
+	}
+	reporter.report(1, message)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		started := time.Now()
+		ticker := time.NewTicker(progressHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				reporter.report(1, fmt.Sprintf("%s (%s elapsed)", message, now.Sub(started).Round(time.Second)))
+			}
+		}
+	}()
+	fn()
+	close(done)
+	// no heartbeat may follow the response.
+	<-stopped
+}
+
+// askWithProgress runs Ask, streaming the answer text as progress
+// notifications when the client asked for progress and the retriever can
+// stream. Progress counts the characters generated so far.
+func (s *Server) askWithProgress(ctx context.Context, question string, query model.SearchQuery) (model.AskResult, error) {
+	reporter := progressReporterFromContext(ctx)
+	streamer, ok := s.retriever.(retrieverAskStream)
+	if reporter == nil || !ok {
+		return s.retriever.Ask(ctx, question, query)
+	}
+	return streamer.AskStream(ctx, question, query, func(delta string) {
+		reporter.report(float64(utf8.RuneCountInString(delta)), delta)
+	})
+}
+
+// parseProgressToken returns params._meta.progressToken when it is a string
+// or an integer, the token types MCP allows.
+func parseProgressToken(rawParams json.RawMessage) (interface{}, bool) {
+	var params struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil || len(params.Meta.ProgressToken) == 0 {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params.Meta.ProgressToken))
+	decoder.UseNumber()
+	var token interface{}
+	if err := decoder.Decode(&token); err != nil {
+		return nil, false
+	}
+	switch typed := token.(type) {
+	case string:
+		return typed, typed != ""
+	case json.Number:
+		if _, err := typed.Int64(); err != nil {
+			return nil, false
+		}
+		return typed, true
+	default:
+		return nil, false
+	}
+}
+
+// acceptsEventStream reports whether the request's Accept header lists
+// text/event-stream.
+func acceptsEventStream(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err == nil && mediaType == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
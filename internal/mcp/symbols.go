@@ -0,0 +1,474 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+// retrieverSymbols is implemented by retrievers backed by a code symbol
+// table.
+type retrieverSymbols interface {
+	Symbols(ctx context.Context, query model.SymbolQuery) ([]model.Symbol, error)
+	Definitions(ctx context.Context, query model.SymbolQuery) ([]model.Symbol, error)
+	References(ctx context.Context, query model.ReferenceQuery, emit func(model.GrepMatch)) (model.ReferenceResult, error)
+}
+
+var symbolKinds = []string{
+	model.SymbolKindFunction,
+	model.SymbolKindMethod,
+	model.SymbolKindType,
+	model.SymbolKindStruct,
+	model.SymbolKindInterface,
+	model.SymbolKindClass,
+	model.SymbolKindEnum,
+	model.SymbolKindModule,
+	model.SymbolKindConst,
+	model.SymbolKindVar,
+	model.SymbolKindField,
+	model.SymbolKindTable,
+}
+
+func (s *Server) handleSymbolsTool(ctx context.Context, args map[string]interface{}) (toolCallResult, *toolExecutionError) {
+	if err := assertNoUnknownArguments(args, map[string]struct{}{
+		"query":       {},
+		"kinds":       {},
+		"container":   {},
+		"language":    {},
+		"path_prefix": {},
+		"file_glob":   {},
+		"limit":       {},
+	}); err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	name, err := parseOptionalString(args, "query")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if len(name) > MaxSymbolNameLength {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("query must be at most %d bytes", MaxSymbolNameLength), Retryable: false}
+	}
+	query, toolErr := parseSymbolQueryArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	query.Name = strings.TrimSpace(name)
+	container, err := parseOptionalString(args, "container")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	query.Container = strings.TrimSpace(container)
+
+	symbolsRetriever, toolErr := s.symbolsRetriever()
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	symbols, err := symbolsRetriever.Symbols(ctx, query)
+	if err != nil {
+		return toolCallResult{}, symbolToolError(err)
+	}
+
+	return toolCallResult{
+		Content: []toolContentItem{
+			{Type: "text", Text: symbolListText(fmt.Sprintf("found %d symbol(s)", len(symbols)), symbols)},
+		},
+		StructuredContent: map[string]interface{}{
+			"query":     query.Name,
+			"symbols":   serializeSymbols(symbols),
+			"truncated": len(symbols) >= query.Limit,
+		},
+	}, nil
+}
+
+func (s *Server) handleDefinitionTool(ctx context.Context, args map[string]interface{}) (toolCallResult, *toolExecutionError) {
+	if err := assertNoUnknownArguments(args, map[string]struct{}{
+		"name":        {},
+		"kinds":       {},
+		"language":    {},
+		"path_prefix": {},
+		"file_glob":   {},
+		"limit":       {},
+	}); err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	name, toolErr := parseSymbolName(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	query, toolErr := parseSymbolQueryArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	query.Name = name
+
+	symbolsRetriever, toolErr := s.symbolsRetriever()
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	definitions, err := symbolsRetriever.Definitions(ctx, query)
+	if err != nil {
+		return toolCallResult{}, symbolToolError(err)
+	}
+
+	return toolCallResult{
+		Content: []toolContentItem{
+			{Type: "text", Text: symbolListText(fmt.Sprintf("found %d definition(s) of %s", len(definitions), name), definitions)},
+		},
+		StructuredContent: map[string]interface{}{
+			"name":        name,
+			"definitions": serializeSymbols(definitions),
+		},
+	}, nil
+}
+
+func (s *Server) handleReferencesTool(ctx context.Context, args map[string]interface{}) (toolCallResult, *toolExecutionError) {
+	if err := assertNoUnknownArguments(args, map[string]struct{}{
+		"name":                {},
+		"language":            {},
+		"path_prefix":         {},
+		"file_glob":           {},
+		"include_definitions": {},
+		"context_lines":       {},
+		"max_matches":         {},
+	}); err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	name, toolErr := parseSymbolName(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	language, err := parseOptionalString(args, "language")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	pathPrefix, err := parseOptionalString(args, "path_prefix")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	fileGlob, err := parseOptionalString(args, "file_glob")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	includeDefinitions, err := parseOptionalBool(args, "include_definitions", false)
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	contextLines, _, err := parseOptionalIntegerWithPresence(args, "context_lines")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if contextLines < 0 || contextLines > MaxGrepContextLines {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("context_lines must be between 0 and %d", MaxGrepContextLines), Retryable: false}
+	}
+	maxMatches, hasMaxMatches, err := parseOptionalIntegerWithPresence(args, "max_matches")
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if !hasMaxMatches {
+		maxMatches = DefaultGrepMaxMatches
+	}
+	if maxMatches < 1 || maxMatches > MaxGrepMatches {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("max_matches must be between 1 and %d", MaxGrepMatches), Retryable: false}
+	}
+
+	symbolsRetriever, toolErr := s.symbolsRetriever()
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	// references scan file contents like grep, so they share its deadline.
+	refCtx, cancel := context.WithTimeout(ctx, GrepTimeout)
+	defer cancel()
+	result, err := symbolsRetriever.References(refCtx, model.ReferenceQuery{
+		Name:               name,
+		Language:           strings.TrimSpace(language),
+		PathPrefix:         pathPrefix,
+		FileGlob:           fileGlob,
+		IncludeDefinitions: includeDefinitions,
+		ContextLines:       contextLines,
+		MaxMatches:         maxMatches,
+	}, nil)
+	if err != nil {
+		return toolCallResult{}, symbolToolError(err)
+	}
+
+	references := make([]map[string]interface{}, 0, len(result.Matches))
+	var text strings.Builder
+	fmt.Fprintf(&text, "found %d reference(s) to %s in %d file(s)", len(result.Matches), name, result.FilesScanned)
+	for _, match := range result.Matches {
+		references = append(references, map[string]interface{}{
+			"rel_path": match.RelPath,
+			"span":     buildOpenFileSpan(match.Span),
+			"line":     match.Line,
+			"before":   append([]string{}, match.Before...),
+			"after":    append([]string{}, match.After...),
+		})
+		fmt.Fprintf(&text, "\n%s:%d: %s", match.RelPath, match.Span.StartLine, match.Line)
+	}
+	if result.Truncated {
+		text.WriteString("\n(stopped at max_matches)")
+	}
+	if result.TimedOut {
+		text.WriteString("\n(timed out; results are partial)")
+	}
+
+	return toolCallResult{
+		Content: []toolContentItem{
+			{Type: "text", Text: text.String()},
+		},
+		StructuredContent: map[string]interface{}{
+			"name":          name,
+			"definitions":   serializeSymbols(result.Definitions),
+			"references":    references,
+			"files_scanned": result.FilesScanned,
+			"files_skipped": result.FilesSkipped,
+			"truncated":     result.Truncated,
+			"timed_out":     result.TimedOut,
+		},
+	}, nil
+}
+
+func (s *Server) symbolsRetriever() (retrieverSymbols, *toolExecutionError) {
+	if s.retriever == nil {
+		return nil, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever not configured", Retryable: false}
+	}
+	symbolsRetriever, ok := s.retriever.(retrieverSymbols)
+	if !ok {
+		return nil, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever does not support symbols", Retryable: false}
+	}
+	return symbolsRetriever, nil
+}
+
+func symbolToolError(err error) *toolExecutionError {
+	if errors.Is(err, model.ErrNotImplemented) {
+		return &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "symbol index not supported by store", Retryable: false}
+	}
+	return &toolExecutionError{Code: "INTERNAL_ERROR", Message: "internal server error", Retryable: true}
+}
+
+func parseSymbolName(args map[string]interface{}) (string, *toolExecutionError) {
+	name, ok, err := parseRequiredString(args, "name")
+	if err != nil {
+		return "", &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if !ok {
+		return "", &toolExecutionError{Code: "MISSING_FIELD", Message: "name is required", Retryable: false}
+	}
+	if len(name) > MaxSymbolNameLength {
+		return "", &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("name must be at most %d bytes", MaxSymbolNameLength), Retryable: false}
+	}
+	return name, nil
+}
+
+// parseSymbolQueryArguments reads the filters shared by dir2mcp.symbols and
+// dir2mcp.definition.
+func parseSymbolQueryArguments(args map[string]interface{}) (model.SymbolQuery, *toolExecutionError) {
+	kinds, err := parseOptionalStringSlice(args, "kinds")
+	if err != nil {
+		return model.SymbolQuery{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	for i, kind := range kinds {
+		kinds[i] = strings.ToLower(strings.TrimSpace(kind))
+		known := false
+		for _, candidate := range symbolKinds {
+			if kinds[i] == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return model.SymbolQuery{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "kinds must be among " + strings.Join(symbolKinds, ","), Retryable: false}
+		}
+	}
+	language, err := parseOptionalString(args, "language")
+	if err != nil {
+		return model.SymbolQuery{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	pathPrefix, err := parseOptionalString(args, "path_prefix")
+	if err != nil {
+		return model.SymbolQuery{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	fileGlob, err := parseOptionalString(args, "file_glob")
+	if err != nil {
+		return model.SymbolQuery{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	limit, hasLimit, err := parseOptionalIntegerWithPresence(args, "limit")
+	if err != nil {
+		return model.SymbolQuery{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if !hasLimit {
+		limit = DefaultSymbolLimit
+	}
+	if limit < 1 || limit > MaxSymbolLimit {
+		return model.SymbolQuery{}, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("limit must be between 1 and %d", MaxSymbolLimit), Retryable: false}
+	}
+	return model.SymbolQuery{
+		Kinds:      kinds,
+		Language:   strings.TrimSpace(language),
+		PathPrefix: pathPrefix,
+		FileGlob:   fileGlob,
+		Limit:      limit,
+	}, nil
+}
+
+func serializeSymbols(symbols []model.Symbol) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(symbols))
+	for _, sym := range symbols {
+		entry := map[string]interface{}{
+			"name":      sym.Name,
+			"kind":      sym.Kind,
+			"rel_path":  sym.RelPath,
+			"language":  sym.Language,
+			"span":      buildOpenFileSpan(sym.Span),
+			"signature": sym.Signature,
+			"exact":     sym.Exact,
+		}
+		if sym.Container != "" {
+			entry["container"] = sym.Container
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+func symbolListText(header string, symbols []model.Symbol) string {
+	var text strings.Builder
+	text.WriteString(header)
+	for _, sym := range symbols {
+		name := sym.Name
+		if sym.Container != "" {
+			name = sym.Container + "." + name
+		}
+		fmt.Fprintf(&text, "\n%s %s %s:%d-%d", sym.Kind, name, sym.RelPath, sym.Span.StartLine, sym.Span.EndLine)
+	}
+	return text.String()
+}
+
+func symbolSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name":      map[string]interface{}{"type": "string"},
+			"kind":      map[string]interface{}{"type": "string", "enum": symbolKinds},
+			"container": map[string]interface{}{"type": "string"},
+			"rel_path":  map[string]interface{}{"type": "string"},
+			"language":  map[string]interface{}{"type": "string"},
+			"span":      map[string]interface{}{"$ref": "#/definitions/Span"},
+			"signature": map[string]interface{}{"type": "string"},
+			"exact":     map[string]interface{}{"type": "boolean"},
+		},
+		"required": []string{"name", "kind", "rel_path", "span"},
+	}
+}
+
+func symbolFilterProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"kinds":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": symbolKinds}},
+		"language":    map[string]interface{}{"type": "string"},
+		"path_prefix": map[string]interface{}{"type": "string"},
+		"file_glob":   map[string]interface{}{"type": "string"},
+		"limit":       map[string]interface{}{"type": "integer", "minimum": 1, "maximum": MaxSymbolLimit, "default": DefaultSymbolLimit},
+	}
+}
+
+func symbolsInputSchema() map[string]interface{} {
+	properties := symbolFilterProperties()
+	properties["query"] = map[string]interface{}{"type": "string", "maxLength": MaxSymbolNameLength}
+	properties["container"] = map[string]interface{}{"type": "string"}
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+func symbolsOutputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"query":     map[string]interface{}{"type": "string"},
+			"symbols":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/Symbol"}},
+			"truncated": map[string]interface{}{"type": "boolean"},
+		},
+		"required":    []string{"symbols", "truncated"},
+		"definitions": map[string]interface{}{"Span": spanDefinitionSchema(), "Symbol": symbolSchema()},
+	}
+}
+
+func definitionInputSchema() map[string]interface{} {
+	properties := symbolFilterProperties()
+	properties["name"] = map[string]interface{}{"type": "string", "minLength": 1, "maxLength": MaxSymbolNameLength}
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+		"required":             []string{"name"},
+	}
+}
+
+func definitionOutputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name":        map[string]interface{}{"type": "string"},
+			"definitions": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/Symbol"}},
+		},
+		"required":    []string{"name", "definitions"},
+		"definitions": map[string]interface{}{"Span": spanDefinitionSchema(), "Symbol": symbolSchema()},
+	}
+}
+
+func referencesInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name":                map[string]interface{}{"type": "string", "minLength": 1, "maxLength": MaxSymbolNameLength},
+			"language":            map[string]interface{}{"type": "string"},
+			"path_prefix":         map[string]interface{}{"type": "string"},
+			"file_glob":           map[string]interface{}{"type": "string"},
+			"include_definitions": map[string]interface{}{"type": "boolean", "default": false},
+			"context_lines":       map[string]interface{}{"type": "integer", "minimum": 0, "maximum": MaxGrepContextLines, "default": 0},
+			"max_matches":         map[string]interface{}{"type": "integer", "minimum": 1, "maximum": MaxGrepMatches, "default": DefaultGrepMaxMatches},
+		},
+		"required": []string{"name"},
+	}
+}
+
+func referencesOutputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name":        map[string]interface{}{"type": "string"},
+			"definitions": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/Symbol"}},
+			"references": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"rel_path": map[string]interface{}{"type": "string"},
+						"span":     map[string]interface{}{"$ref": "#/definitions/Span"},
+						"line":     map[string]interface{}{"type": "string"},
+						"before":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"after":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+					},
+					"required": []string{"rel_path", "span", "line"},
+				},
+			},
+			"files_scanned": map[string]interface{}{"type": "integer"},
+			"files_skipped": map[string]interface{}{"type": "integer"},
+			"truncated":     map[string]interface{}{"type": "boolean"},
+			"timed_out":     map[string]interface{}{"type": "boolean"},
+		},
+		"required":    []string{"name", "definitions", "references", "truncated", "timed_out"},
+		"definitions": map[string]interface{}{"Span": spanDefinitionSchema(), "Symbol": symbolSchema()},
+	}
+}
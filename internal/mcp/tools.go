@@ -44,11 +44,21 @@ var toolOrder = []string{
 	protocol.ToolNameTranscribe,
 	protocol.ToolNameAnnotate,
 	protocol.ToolNameTranscribeAndAsk,
+	protocol.ToolNameFindSimilar,
+	protocol.ToolNameGrep,
+	protocol.ToolNameSymbols,
+	protocol.ToolNameDefinition,
+	protocol.ToolNameReferences,
 	protocol.ToolNameOpenFile,
 	protocol.ToolNameListFiles,
 	protocol.ToolNameStats,
 }
 
+// ToolNames returns the names of the server's tools in listing order.
+func ToolNames() []string {
+	return append([]string(nil), toolOrder...)
+}
+
 type toolHandler func(context.Context, map[string]interface{}) (toolCallResult, *toolExecutionError)
 
 type toolDefinition struct {
@@ -87,6 +97,12 @@ type retrieverOpenFileWithMeta interface {
 	OpenFileWithMeta(ctx context.Context, relPath string, span model.Span, maxChars int) (string, bool, error)
 }
 
+// retrieverSearchWithMetadata is implemented by retrievers that report how a
+// search was produced, such as reranker latency.
+type retrieverSearchWithMetadata interface {
+	SearchWithMetadata(ctx context.Context, query model.SearchQuery) (model.SearchResult, error)
+}
+
 type voiceAwareTTSSynthesizer interface {
 	SynthesizeWithVoice(ctx context.Context, text, voiceID string) ([]byte, error)
 }
@@ -135,6 +151,41 @@ func (s *Server) buildToolRegistry() map[string]toolDefinition {
 			OutputSchema: transcribeAndAskOutputSchema(),
 			handler:      s.handleTranscribeAndAskTool,
 		},
+		protocol.ToolNameFindSimilar: {
+			Name:         protocol.ToolNameFindSimilar,
+			Description:  "Find chunks similar to an indexed chunk or file, reusing its stored vectors.",
+			InputSchema:  findSimilarInputSchema(),
+			OutputSchema: findSimilarOutputSchema(),
+			handler:      s.handleFindSimilarTool,
+		},
+		protocol.ToolNameGrep: {
+			Name:         protocol.ToolNameGrep,
+			Description:  "Regex or exact-match search over the raw text of indexed files, with line spans and context.",
+			InputSchema:  grepInputSchema(),
+			OutputSchema: grepOutputSchema(),
+			handler:      s.handleGrepTool,
+		},
+		protocol.ToolNameSymbols: {
+			Name:         protocol.ToolNameSymbols,
+			Description:  "Search the code symbol table by name, kind, language or container.",
+			InputSchema:  symbolsInputSchema(),
+			OutputSchema: symbolsOutputSchema(),
+			handler:      s.handleSymbolsTool,
+		},
+		protocol.ToolNameDefinition: {
+			Name:         protocol.ToolNameDefinition,
+			Description:  "Find where a function, type or other symbol is defined.",
+			InputSchema:  definitionInputSchema(),
+			OutputSchema: definitionOutputSchema(),
+			handler:      s.handleDefinitionTool,
+		},
+		protocol.ToolNameReferences: {
+			Name:         protocol.ToolNameReferences,
+			Description:  "Find the lines where an identifier is used, with line spans and context.",
+			InputSchema:  referencesInputSchema(),
+			OutputSchema: referencesOutputSchema(),
+			handler:      s.handleReferencesTool,
+		},
 		protocol.ToolNameOpenFile: {
 			Name:         protocol.ToolNameOpenFile,
 			Description:  "Open an exact source slice for verification.",
@@ -159,16 +210,18 @@ func (s *Server) buildToolRegistry() map[string]toolDefinition {
 	}
 }
 
-func (s *Server) handleToolsList(w http.ResponseWriter, id interface{}) {
+// handleToolsList lists the tools in toolOrder, leaving out those the
+// request's token may not call.
+func (s *Server) handleToolsList(ctx context.Context, w http.ResponseWriter, id interface{}) {
 	tools := make([]toolDefinition, 0, len(s.tools))
 
 	for _, name := range toolOrder {
-		if tool, ok := s.tools[name]; ok {
+		if tool, ok := s.tools[name]; ok && toolAllowed(ctx, name) {
 			tools = append(tools, tool)
 		}
 	}
 
-	if len(tools) == 0 {
+	if len(tools) == 0 && accessTokenFromContext(ctx) == nil {
 		names := make([]string, 0, len(s.tools))
 		for name := range s.tools {
 			names = append(names, name)
@@ -184,20 +237,37 @@ func (s *Server) handleToolsList(w http.ResponseWriter, id interface{}) {
 	})
 }
 
-func (s *Server) handleToolsCall(ctx context.Context, w http.ResponseWriter, rawParams json.RawMessage, id interface{}) {
+func (s *Server) handleToolsCall(ctx context.Context, w http.ResponseWriter, r *http.Request, rawParams json.RawMessage, id interface{}) {
+	// a client that accepts an event stream and sends a progress token gets
+	// notifications/progress ahead of the result.
+	var stream *eventStream
+	if token, ok := parseProgressToken(rawParams); ok && acceptsEventStream(r) {
+		stream = newEventStream(w)
+		ctx = withProgressReporter(ctx, &progressReporter{stream: stream, token: token})
+	}
+
 	result, statusCode, rpcErr := s.processToolsCall(ctx, rawParams)
-	if rpcErr != nil {
-		writeResponse(w, statusCode, rpcResponse{
-			JSONRPC: "2.0",
-			ID:      id,
-			Error:   rpcErr,
-		})
+	response := rpcResponse{JSONRPC: "2.0", ID: id}
+	if requestCancelled(ctx) {
+		// whatever the tool returned was cut short.
+		response.Error = requestCancelledError()
+		statusCode = http.StatusOK
+	} else if rpcErr != nil {
+		response.Error = rpcErr
+	} else {
+		response.Result = result
+	}
+	if stream != nil && stream.opened() {
+		_ = stream.send(response)
 		return
 	}
-	writeResult(w, statusCode, id, result)
+	writeResponse(w, statusCode, response)
 }
 
-func (s *Server) processToolsCall(ctx context.Context, rawParams json.RawMessage) (toolCallResult, int, *rpcError) {
+func (s *Server) processToolsCall(ctx context.Context, rawParams json.RawMessage) (result toolCallResult, statusCode int, rpcErr *rpcError) {
+	var params toolsCallParams
+	defer func() { recordAuditToolCall(ctx, params, result, rpcErr) }()
+
 	params, err := parseToolsCallParams(rawParams)
 	if err != nil {
 		canonicalCode := "INVALID_FIELD"
@@ -224,6 +294,10 @@ func (s *Server) processToolsCall(ctx context.Context, rawParams json.RawMessage
 		}), http.StatusOK, nil
 	}
 
+	if !toolAllowed(ctx, params.Name) {
+		return newToolErrorResult(*toolForbiddenError(params.Name)), http.StatusOK, nil
+	}
+
 	result, toolErr := tool.handler(ctx, params.Arguments)
 	if toolErr != nil {
 		return newToolErrorResult(*toolErr), http.StatusOK, nil
@@ -432,7 +506,16 @@ func (s *Server) handleListFilesTool(ctx context.Context, args map[string]interf
 		docs = []model.Document{}
 		total = 0
 	} else {
-		listedDocs, listedTotal, listErr := s.store.ListFiles(ctx, pathPrefix, glob, limit, offset)
+		var (
+			listedDocs  []model.Document
+			listedTotal int64
+			listErr     error
+		)
+		if token := accessTokenFromContext(ctx); token != nil && token.PathScoped() {
+			listedDocs, listedTotal, listErr = s.listFilesInScope(ctx, token, pathPrefix, glob, limit, offset)
+		} else {
+			listedDocs, listedTotal, listErr = s.store.ListFiles(ctx, pathPrefix, glob, limit, offset)
+		}
 		if listErr != nil && !errors.Is(listErr, model.ErrNotImplemented) {
 			return toolCallResult{}, &toolExecutionError{
 				Code:      "STORE_CORRUPT",
@@ -477,12 +560,23 @@ func (s *Server) handleListFilesTool(ctx context.Context, args map[string]interf
 
 func (s *Server) handleSearchTool(ctx context.Context, args map[string]interface{}) (toolCallResult, *toolExecutionError) {
 	if err := assertNoUnknownArguments(args, map[string]struct{}{
-		"query":       {},
-		"k":           {},
-		"index":       {},
-		"path_prefix": {},
-		"file_glob":   {},
-		"doc_types":   {},
+		"query":             {},
+		"k":                 {},
+		"index":             {},
+		"path_prefix":       {},
+		"file_glob":         {},
+		"doc_types":         {},
+		"rerank":            {},
+		"mmr_lambda":        {},
+		"max_hits_per_file": {},
+		"modified_after":    {},
+		"modified_before":   {},
+		"min_size_bytes":    {},
+		"max_size_bytes":    {},
+		"statuses":          {},
+		"source_types":      {},
+		"rep_types":         {},
+		"languages":         {},
 	}); err != nil {
 		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
@@ -518,9 +612,9 @@ func (s *Server) handleSearchTool(ctx context.Context, args map[string]interface
 		indexName = "auto"
 	}
 	switch indexName {
-	case "auto", "text", "code", "both":
+	case "auto", "text", "code", "both", "hybrid":
 	default:
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "index must be one of auto,text,code,both", Retryable: false}
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "index must be one of auto,text,code,both,hybrid", Retryable: false}
 	}
 
 	pathPrefix, err := parseOptionalString(args, "path_prefix")
@@ -535,17 +629,33 @@ func (s *Server) handleSearchTool(ctx context.Context, args map[string]interface
 	if err != nil {
 		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
+	rerank, toolErr := parseRerankArgument(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	mmrLambda, maxHitsPerFile, toolErr := parseDiversityArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	metadataFilter, toolErr := parseMetadataFilterArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
 
 	if s.retriever == nil {
 		return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever not configured", Retryable: false}
 	}
-	hits, searchErr := s.retriever.Search(ctx, model.SearchQuery{
-		Query:      query,
-		K:          k,
-		Index:      indexName,
-		PathPrefix: pathPrefix,
-		FileGlob:   fileGlob,
-		DocTypes:   docTypes,
+	searchResult, searchErr := s.searchWithMetadata(ctx, model.SearchQuery{
+		Query:          query,
+		K:              scopedSearchK(ctx, k),
+		Index:          indexName,
+		PathPrefix:     pathPrefix,
+		FileGlob:       fileGlob,
+		DocTypes:       docTypes,
+		Rerank:         rerank,
+		MMRLambda:      mmrLambda,
+		MaxHitsPerFile: maxHitsPerFile,
+		MetadataFilter: metadataFilter,
 	})
 	if searchErr != nil {
 		code := "INTERNAL_ERROR"
@@ -554,9 +664,14 @@ func (s *Server) handleSearchTool(ctx context.Context, args map[string]interface
 		if errors.Is(searchErr, model.ErrIndexNotReady) || errors.Is(searchErr, model.ErrIndexNotConfigured) {
 			code = protocol.ErrorCodeIndexNotReady
 			message = "index not ready"
+		} else if errors.Is(searchErr, model.ErrUnknownReranker) {
+			code = "INVALID_FIELD"
+			message = searchErr.Error()
+			retryable = false
 		}
 		return toolCallResult{}, &toolExecutionError{Code: code, Message: message, Retryable: retryable}
 	}
+	hits := filterHitsByScope(ctx, searchResult.Hits, k)
 
 	indexUsed := "text"
 	switch indexName {
@@ -564,6 +679,8 @@ func (s *Server) handleSearchTool(ctx context.Context, args map[string]interface
 		indexUsed = "code"
 	case "both":
 		indexUsed = "both"
+	case "hybrid":
+		indexUsed = "hybrid"
 	}
 
 	// attempt to reflect real indexing status; when unknown we preserve the
@@ -572,13 +689,20 @@ func (s *Server) handleSearchTool(ctx context.Context, args map[string]interface
 	if ic, err := s.retriever.IndexingComplete(ctx); err == nil {
 		indexingComplete = ic
 	}
+	hitMaps := make([]map[string]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		hitMaps = append(hitMaps, serializeHit(hit))
+	}
 	structured := map[string]interface{}{
 		"query":             query,
 		"k":                 k,
 		"index_used":        indexUsed,
-		"hits":              hits,
+		"hits":              hitMaps,
 		"indexing_complete": indexingComplete,
 	}
+	if searchResult.Rerank != nil {
+		structured["rerank"] = serializeRerankInfo(*searchResult.Rerank)
+	}
 
 	return toolCallResult{
 		Content: []toolContentItem{
@@ -590,13 +714,27 @@ func (s *Server) handleSearchTool(ctx context.Context, args map[string]interface
 
 func (s *Server) handleAskTool(ctx context.Context, args map[string]interface{}) (toolCallResult, *toolExecutionError) {
 	if err := assertNoUnknownArguments(args, map[string]struct{}{
-		"question":    {},
-		"k":           {},
-		"mode":        {},
-		"index":       {},
-		"path_prefix": {},
-		"file_glob":   {},
-		"doc_types":   {},
+		"question":          {},
+		"k":                 {},
+		"mode":              {},
+		"index":             {},
+		"path_prefix":       {},
+		"file_glob":         {},
+		"doc_types":         {},
+		"debug":             {},
+		"rerank":            {},
+		"mmr_lambda":        {},
+		"max_hits_per_file": {},
+		"conversation_id":   {},
+		"strict":            {},
+		"modified_after":    {},
+		"modified_before":   {},
+		"min_size_bytes":    {},
+		"max_size_bytes":    {},
+		"statuses":          {},
+		"source_types":      {},
+		"rep_types":         {},
+		"languages":         {},
 	}); err != nil {
 		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
@@ -656,9 +794,9 @@ func (s *Server) handleAskTool(ctx context.Context, args map[string]interface{})
 		indexName = "auto"
 	}
 	switch indexName {
-	case "auto", "text", "code", "both":
+	case "auto", "text", "code", "both", "hybrid":
 	default:
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "index must be one of auto,text,code,both", Retryable: false}
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "index must be one of auto,text,code,both,hybrid", Retryable: false}
 	}
 
 	pathPrefix, err := parseOptionalString(args, "path_prefix")
@@ -673,18 +811,49 @@ func (s *Server) handleAskTool(ctx context.Context, args map[string]interface{})
 	if err != nil {
 		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
+	debug, err := parseOptionalBool(args, "debug", false)
+	if err != nil {
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	rerank, toolErr := parseRerankArgument(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	mmrLambda, maxHitsPerFile, toolErr := parseDiversityArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	conversationID, toolErr := parseConversationID(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	strict, toolErr := parseStrictArgument(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	metadataFilter, toolErr := parseMetadataFilterArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+	// follow-ups in a conversation are rewritten into a standalone query
+	// before retrieval so references like "that" resolve against history.
+	conversations, standalone := s.resolveFollowUp(ctx, conversationID, question)
 
 	// branch early on search_only so we avoid asking the generator and can
 	// take advantage of Search-specific behaviour (and avoid throwing away the
 	// generated answer).
 	if mode == "search_only" {
-		hits, searchErr := s.retriever.Search(ctx, model.SearchQuery{
-			Query:      question,
-			K:          k,
-			Index:      indexName,
-			PathPrefix: pathPrefix,
-			FileGlob:   fileGlob,
-			DocTypes:   docTypes,
+		searchResult, searchErr := s.searchWithMetadata(ctx, model.SearchQuery{
+			Query:          standalone,
+			K:              k,
+			Index:          indexName,
+			PathPrefix:     pathPrefix,
+			FileGlob:       fileGlob,
+			DocTypes:       docTypes,
+			Rerank:         rerank,
+			MMRLambda:      mmrLambda,
+			MaxHitsPerFile: maxHitsPerFile,
+			MetadataFilter: metadataFilter,
 		})
 		if searchErr != nil {
 			code := "INTERNAL_ERROR"
@@ -693,9 +862,14 @@ func (s *Server) handleAskTool(ctx context.Context, args map[string]interface{})
 			if errors.Is(searchErr, model.ErrIndexNotReady) || errors.Is(searchErr, model.ErrIndexNotConfigured) {
 				code = protocol.ErrorCodeIndexNotReady
 				message = "index not ready"
+			} else if errors.Is(searchErr, model.ErrUnknownReranker) {
+				code = "INVALID_FIELD"
+				message = searchErr.Error()
+				retryable = false
 			}
 			return toolCallResult{}, &toolExecutionError{Code: code, Message: message, Retryable: retryable}
 		}
+		hits := searchResult.Hits
 
 		hitMaps := make([]map[string]interface{}, 0, len(hits))
 		for _, h := range hits {
@@ -716,6 +890,14 @@ func (s *Server) handleAskTool(ctx context.Context, args map[string]interface{})
 			"hits":              hitMaps,
 			"indexing_complete": indexingComplete,
 		}
+		if searchResult.Rerank != nil {
+			structured["rerank"] = serializeRerankInfo(*searchResult.Rerank)
+		}
+		if conversations != nil {
+			conversations.append(conversationID, model.ConversationTurn{Question: question, Standalone: standalone})
+			structured["conversation_id"] = conversationID
+			structured["standalone_question"] = standalone
+		}
 		return toolCallResult{
 			Content:           []toolContentItem{{Type: "text", Text: fmt.Sprintf("found %d supporting result(s)", len(hits))}},
 			StructuredContent: structured,
@@ -723,13 +905,17 @@ func (s *Server) handleAskTool(ctx context.Context, args map[string]interface{})
 	}
 
 	// non‑search mode falls through to the original Ask logic
-	askResult, askErr := s.retriever.Ask(ctx, question, model.SearchQuery{
-		Query:      question,
-		K:          k,
-		Index:      indexName,
-		PathPrefix: pathPrefix,
-		FileGlob:   fileGlob,
-		DocTypes:   docTypes,
+	askResult, askErr := s.askWithProgress(ctx, standalone, model.SearchQuery{
+		Query:          standalone,
+		K:              k,
+		Index:          indexName,
+		PathPrefix:     pathPrefix,
+		FileGlob:       fileGlob,
+		DocTypes:       docTypes,
+		Rerank:         rerank,
+		MMRLambda:      mmrLambda,
+		MaxHitsPerFile: maxHitsPerFile,
+		MetadataFilter: metadataFilter,
 	})
 	if askErr != nil {
 		code := "INTERNAL_ERROR"
@@ -738,10 +924,31 @@ func (s *Server) handleAskTool(ctx context.Context, args map[string]interface{})
 		if errors.Is(askErr, model.ErrIndexNotReady) || errors.Is(askErr, model.ErrIndexNotConfigured) {
 			code = protocol.ErrorCodeIndexNotReady
 			message = "index not ready"
+		} else if errors.Is(askErr, model.ErrUnknownReranker) {
+			code = "INVALID_FIELD"
+			message = askErr.Error()
+			retryable = false
 		}
 		return toolCallResult{}, &toolExecutionError{Code: code, Message: message, Retryable: retryable}
 	}
+	if askResult.Verification != nil {
+		var verification model.AnswerVerification
+		askResult.Answer, verification = askResult.Verification.Apply(askResult.Answer, strict)
+		askResult.Verification = &verification
+	}
 	structured := buildAskStructuredContent(askResult)
+	if askResult.Rerank != nil {
+		structured["rerank"] = serializeRerankInfo(*askResult.Rerank)
+	}
+	if conversations != nil {
+		conversations.append(conversationID, model.ConversationTurn{Question: question, Standalone: standalone, Answer: askResult.Answer})
+		structured["question"] = question
+		structured["conversation_id"] = conversationID
+		structured["standalone_question"] = standalone
+	}
+	if debug && askResult.Context != nil {
+		structured["context"] = serializeAskContext(*askResult.Context)
+	}
 	contentText := askResult.Answer
 
 	return toolCallResult{
@@ -810,9 +1017,9 @@ func (s *Server) handleAskAudioTool(ctx context.Context, args map[string]interfa
 		indexName = "auto"
 	}
 	switch indexName {
-	case "auto", "text", "code", "both":
+	case "auto", "text", "code", "both", "hybrid":
 	default:
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "index must be one of auto,text,code,both", Retryable: false}
+		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "index must be one of auto,text,code,both,hybrid", Retryable: false}
 	}
 
 	pathPrefix, err := parseOptionalString(args, "path_prefix")
@@ -1144,7 +1351,11 @@ func (s *Server) handleAnnotateTool(ctx context.Context, args map[string]interfa
 		}
 	}
 
-	generated, genErr := client.Generate(ctx, prompt)
+	var generated string
+	var genErr error
+	runWithProgress(ctx, "generating annotation for "+relPath, func() {
+		generated, genErr = client.Generate(ctx, prompt)
+	})
 	if genErr != nil {
 		return toolCallResult{}, s.mapToolErrorFromProvider("ANNOTATE_FAILED", genErr)
 	}
@@ -1156,7 +1367,11 @@ func (s *Server) handleAnnotateTool(ctx context.Context, args map[string]interfa
 	// create a request-scoped ingest service to avoid cross-request mutation of
 	// OCR/transcriber settings under concurrency.
 	ing := ingest.NewService(s.cfg, s.store)
-	preview, persistErr := ing.StoreAnnotationRepresentations(ctx, doc, annotationObj, indexFlattenedText)
+	var preview string
+	var persistErr error
+	runWithProgress(ctx, "storing annotation for "+relPath, func() {
+		preview, persistErr = ing.StoreAnnotationRepresentations(ctx, doc, annotationObj, indexFlattenedText)
+	})
 	if persistErr != nil {
 		return toolCallResult{}, &toolExecutionError{Code: "STORE_CORRUPT", Message: persistErr.Error(), Retryable: false}
 	}
@@ -1231,7 +1446,10 @@ func (s *Server) handleTranscribeAndAskTool(ctx context.Context, args map[string
 		return toolCallResult{}, toolErr
 	}
 
-	askResult, askErr := s.retriever.Ask(ctx, question, model.SearchQuery{
+	if reporter := progressReporterFromContext(ctx); reporter != nil {
+		reporter.report(1, "answering from the transcript of "+relPath)
+	}
+	askResult, askErr := s.askWithProgress(ctx, question, model.SearchQuery{
 		Query:    question,
 		K:        k,
 		Index:    "text",
@@ -1280,6 +1498,10 @@ func (s *Server) handleOpenFileTool(ctx context.Context, args map[string]interfa
 		return toolCallResult{}, &toolExecutionError{Code: "MISSING_FIELD", Message: "rel_path is required", Retryable: false}
 	}
 
+	if !pathAllowed(ctx, relPath) {
+		return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodePermissionDenied, Message: "token scope does not allow " + relPath, Retryable: false}
+	}
+
 	if s.retriever == nil {
 		return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeIndexNotReady, Message: "retriever not configured", Retryable: false}
 	}
@@ -1296,6 +1518,59 @@ func (s *Server) handleOpenFileTool(ctx context.Context, args map[string]interfa
 		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "max_chars must be between 200 and 50000", Retryable: false}
 	}
 
+	span, toolErr := parseSpanArguments(args)
+	if toolErr != nil {
+		return toolCallResult{}, toolErr
+	}
+
+	var (
+		content   string
+		truncated bool
+		openErr   error
+	)
+	if withMeta, ok := s.retriever.(retrieverOpenFileWithMeta); ok {
+		content, truncated, openErr = withMeta.OpenFileWithMeta(ctx, relPath, span, maxChars)
+	} else {
+		content, openErr = s.retriever.OpenFile(ctx, relPath, span, maxChars)
+		truncated = len([]rune(content)) > maxChars
+	}
+	if openErr != nil {
+		switch {
+		case errors.Is(openErr, model.ErrForbidden):
+			return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodePermissionDenied, Message: "forbidden", Retryable: false}
+		case errors.Is(openErr, model.ErrPathOutsideRoot):
+			return toolCallResult{}, &toolExecutionError{Code: "PATH_OUTSIDE_ROOT", Message: "path outside root", Retryable: false}
+		case errors.Is(openErr, model.ErrDocTypeUnsupported):
+			return toolCallResult{}, &toolExecutionError{Code: "DOC_TYPE_UNSUPPORTED", Message: "doc type unsupported", Retryable: false}
+		case errors.Is(openErr, os.ErrNotExist):
+			return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeFileNotFound, Message: "file not found", Retryable: false}
+		default:
+			return toolCallResult{}, &toolExecutionError{Code: "INTERNAL_ERROR", Message: "internal server error", Retryable: true}
+		}
+	}
+
+	structured := map[string]interface{}{
+		"rel_path":  relPath,
+		"doc_type":  inferDocType(relPath),
+		"content":   content,
+		"truncated": truncated,
+	}
+	if strings.TrimSpace(span.Kind) != "" {
+		structured["span"] = buildOpenFileSpan(span)
+	}
+
+	return toolCallResult{
+		Content: []toolContentItem{
+			{Type: "text", Text: content},
+		},
+		StructuredContent: structured,
+	}, nil
+}
+
+// parseSpanArguments reads the optional page, start_ms/end_ms and
+// start_line/end_line arguments into a span. At most one group may be given;
+// with none the zero span is returned.
+func parseSpanArguments(args map[string]interface{}) (model.Span, *toolExecutionError) {
 	// parse all span-related parameters so we can detect conflicts between groups
 	span := model.Span{}
 	// group A: page
@@ -1306,31 +1581,31 @@ func (s *Server) handleOpenFileTool(ctx context.Context, args map[string]interfa
 		var parseErr error
 		page, parseErr = parseInteger(raw, "page")
 		if parseErr != nil {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: parseErr.Error(), Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: parseErr.Error(), Retryable: false}
 		}
 		if page <= 0 {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "page must be > 0", Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "page must be > 0", Retryable: false}
 		}
 	}
 
 	// group B: start_ms/end_ms
 	startMS, hasStartMS, err := parseOptionalIntegerWithPresence(args, "start_ms")
 	if err != nil {
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+		return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
 	endMS, hasEndMS, err := parseOptionalIntegerWithPresence(args, "end_ms")
 	if err != nil {
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+		return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
 
 	// group C: start_line/end_line
 	startLine, hasStartLine, err := parseOptionalIntegerWithPresence(args, "start_line")
 	if err != nil {
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+		return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
 	endLine, hasEndLine, err := parseOptionalIntegerWithPresence(args, "end_line")
 	if err != nil {
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+		return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
 	}
 
 	// detect mutually-exclusive groups: page vs time vs lines
@@ -1345,7 +1620,7 @@ func (s *Server) handleOpenFileTool(ctx context.Context, args map[string]interfa
 		groups++
 	}
 	if groups > 1 {
-		return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "conflicting span parameters: provide only one of page, start_ms/end_ms, or start_line/end_line", Retryable: false}
+		return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "conflicting span parameters: provide only one of page, start_ms/end_ms, or start_line/end_line", Retryable: false}
 	}
 
 	// now build the span based on the single group present (if any)
@@ -1354,72 +1629,30 @@ func (s *Server) handleOpenFileTool(ctx context.Context, args map[string]interfa
 	} else if hasStartMS || hasEndMS {
 		// require both parameters when specifying a time span
 		if hasStartMS != hasEndMS {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "both start_ms and end_ms must be provided", Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "both start_ms and end_ms must be provided", Retryable: false}
 		}
 		if (hasStartMS && startMS < 0) || (hasEndMS && endMS < 0) {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_ms/end_ms must be >= 0", Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_ms/end_ms must be >= 0", Retryable: false}
 		}
 		if hasStartMS && hasEndMS && startMS > endMS {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_ms must be <= end_ms", Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_ms must be <= end_ms", Retryable: false}
 		}
 		span = model.Span{Kind: "time", StartMS: startMS, EndMS: endMS}
 	} else if hasStartLine || hasEndLine {
 		// runtime validation mirrors openFileInputSchema which requires
 		// positive line numbers; do not allow zero or negative values.
 		if hasStartLine != hasEndLine {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "both start_line and end_line must be provided", Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "both start_line and end_line must be provided", Retryable: false}
 		}
 		if (hasStartLine && startLine <= 0) || (hasEndLine && endLine <= 0) {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_line/end_line must be > 0", Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_line/end_line must be > 0", Retryable: false}
 		}
 		if hasStartLine && hasEndLine && startLine > endLine {
-			return toolCallResult{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_line must be <= end_line", Retryable: false}
+			return model.Span{}, &toolExecutionError{Code: "INVALID_FIELD", Message: "start_line must be <= end_line", Retryable: false}
 		}
 		span = model.Span{Kind: "lines", StartLine: startLine, EndLine: endLine}
 	}
-
-	var (
-		content   string
-		truncated bool
-		openErr   error
-	)
-	if withMeta, ok := s.retriever.(retrieverOpenFileWithMeta); ok {
-		content, truncated, openErr = withMeta.OpenFileWithMeta(ctx, relPath, span, maxChars)
-	} else {
-		content, openErr = s.retriever.OpenFile(ctx, relPath, span, maxChars)
-		truncated = len([]rune(content)) > maxChars
-	}
-	if openErr != nil {
-		switch {
-		case errors.Is(openErr, model.ErrForbidden):
-			return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodePermissionDenied, Message: "forbidden", Retryable: false}
-		case errors.Is(openErr, model.ErrPathOutsideRoot):
-			return toolCallResult{}, &toolExecutionError{Code: "PATH_OUTSIDE_ROOT", Message: "path outside root", Retryable: false}
-		case errors.Is(openErr, model.ErrDocTypeUnsupported):
-			return toolCallResult{}, &toolExecutionError{Code: "DOC_TYPE_UNSUPPORTED", Message: "doc type unsupported", Retryable: false}
-		case errors.Is(openErr, os.ErrNotExist):
-			return toolCallResult{}, &toolExecutionError{Code: protocol.ErrorCodeFileNotFound, Message: "file not found", Retryable: false}
-		default:
-			return toolCallResult{}, &toolExecutionError{Code: "INTERNAL_ERROR", Message: "internal server error", Retryable: true}
-		}
-	}
-
-	structured := map[string]interface{}{
-		"rel_path":  relPath,
-		"doc_type":  inferDocType(relPath),
-		"content":   content,
-		"truncated": truncated,
-	}
-	if strings.TrimSpace(span.Kind) != "" {
-		structured["span"] = buildOpenFileSpan(span)
-	}
-
-	return toolCallResult{
-		Content: []toolContentItem{
-			{Type: "text", Text: content},
-		},
-		StructuredContent: structured,
-	}, nil
+	return span, nil
 }
 
 func assertNoUnknownArguments(args map[string]interface{}, allowed map[string]struct{}) error {
@@ -1542,7 +1775,15 @@ func (s *Server) ensureTranscriptForAudioDoc(ctx context.Context, doc model.Docu
 
 	// generate transcript text first so we can accurately determine whether
 	// there is anything worth indexing.
-	transcript, readErr := ing.ReadOrComputeTranscript(ctx, doc, content)
+	var transcript string
+	var readErr error
+	step := "transcribing " + doc.RelPath
+	if cacheValid {
+		step = "reading cached transcript of " + doc.RelPath
+	}
+	runWithProgress(ctx, step, func() {
+		transcript, readErr = ing.ReadOrComputeTranscript(ctx, doc, content)
+	})
 	if readErr != nil {
 		return "", false, false, s.mapToolErrorFromProvider("TRANSCRIBE_FAILED", readErr)
 	}
@@ -1550,7 +1791,11 @@ func (s *Server) ensureTranscriptForAudioDoc(ctx context.Context, doc model.Docu
 	indexed := false
 	if strings.TrimSpace(transcript) != "" {
 		// only attempt to persist a representation when we actually have text
-		if genErr := ing.GenerateTranscriptRepresentation(ctx, doc, content); genErr != nil {
+		var genErr error
+		runWithProgress(ctx, "indexing transcript of "+doc.RelPath, func() {
+			genErr = ing.GenerateTranscriptRepresentation(ctx, doc, content)
+		})
+		if genErr != nil {
 			return "", false, false, s.mapToolErrorFromProvider("TRANSCRIBE_FAILED", genErr)
 		}
 		indexed = true
@@ -1584,7 +1829,11 @@ func (s *Server) sourceTextForAnnotation(ctx context.Context, doc model.Document
 		}
 		ing := ingest.NewService(s.cfg, s.store)
 		ing.SetOCR(client)
-		text, ocrErr := ing.ReadOrComputeOCR(ctx, doc, content)
+		var text string
+		var ocrErr error
+		runWithProgress(ctx, "extracting text from "+doc.RelPath, func() {
+			text, ocrErr = ing.ReadOrComputeOCR(ctx, doc, content)
+		})
 		if ocrErr != nil {
 			return "", "", s.mapToolErrorFromProvider("ANNOTATE_FAILED", ocrErr)
 		}
@@ -1895,7 +2144,7 @@ func normalizeFileStatus(status string) string {
 }
 
 func serializeHit(h model.SearchHit) map[string]interface{} {
-	return map[string]interface{}{
+	out := map[string]interface{}{
 		"chunk_id": h.ChunkID,
 		"rel_path": h.RelPath,
 		"doc_type": h.DocType,
@@ -1904,6 +2153,160 @@ func serializeHit(h model.SearchHit) map[string]interface{} {
 		"snippet":  h.Snippet,
 		"span":     buildOpenFileSpan(h.Span),
 	}
+	// score components are only meaningful for fused (hybrid) results.
+	if h.FusedRank > 0 {
+		out["vector_score"] = h.VectorScore
+		out["lexical_score"] = h.LexicalScore
+		out["fused_rank"] = h.FusedRank
+	}
+	if h.Reranked {
+		out["rerank_score"] = h.RerankScore
+	}
+	return out
+}
+
+// serializeRerankInfo renders second-stage rerank metadata for search and
+// ask responses.
+func serializeRerankInfo(info model.RerankInfo) map[string]interface{} {
+	out := map[string]interface{}{
+		"reranker":   info.Reranker,
+		"candidates": info.Candidates,
+		"latency_ms": info.LatencyMS,
+	}
+	if info.Error != "" {
+		out["error"] = info.Error
+	}
+	return out
+}
+
+// parseDiversityArguments reads the optional result diversification
+// controls shared by dir2mcp.search and dir2mcp.ask. Absent arguments leave
+// diversification off.
+func parseDiversityArguments(args map[string]interface{}) (float64, int, *toolExecutionError) {
+	lambda := 0.0
+	if raw, exists := args["mmr_lambda"]; exists {
+		v, ok := raw.(float64)
+		if !ok {
+			return 0, 0, &toolExecutionError{Code: "INVALID_FIELD", Message: "mmr_lambda must be a number", Retryable: false}
+		}
+		if v <= 0 || v > 1 {
+			return 0, 0, &toolExecutionError{Code: "INVALID_RANGE", Message: "mmr_lambda must be greater than 0 and at most 1", Retryable: false}
+		}
+		lambda = v
+	}
+	maxHitsPerFile, present, err := parseOptionalIntegerWithPresence(args, "max_hits_per_file")
+	if err != nil {
+		return 0, 0, &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	if present && (maxHitsPerFile < 1 || maxHitsPerFile > MaxSearchK) {
+		return 0, 0, &toolExecutionError{Code: "INVALID_RANGE", Message: fmt.Sprintf("max_hits_per_file must be between 1 and %d", MaxSearchK), Retryable: false}
+	}
+	return lambda, maxHitsPerFile, nil
+}
+
+// searchWithMetadata prefers the retriever's metadata-aware search so rerank
+// details can be reported; other retrievers still receive the rerank request
+// through the query but return no metadata.
+func (s *Server) searchWithMetadata(ctx context.Context, query model.SearchQuery) (model.SearchResult, error) {
+	if withMeta, ok := s.retriever.(retrieverSearchWithMetadata); ok {
+		return withMeta.SearchWithMetadata(ctx, query)
+	}
+	hits, err := s.retriever.Search(ctx, query)
+	return model.SearchResult{Hits: hits}, err
+}
+
+// parseRerankArgument reads the optional rerank selector shared by
+// dir2mcp.search and dir2mcp.ask. "none" is normalized to the empty string.
+func parseRerankArgument(args map[string]interface{}) (string, *toolExecutionError) {
+	rerank, err := parseOptionalString(args, "rerank")
+	if err != nil {
+		return "", &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	rerank = strings.ToLower(strings.TrimSpace(rerank))
+	switch rerank {
+	case "", "none":
+		return "", nil
+	case "lexical", "llm":
+		return rerank, nil
+	default:
+		return "", &toolExecutionError{Code: "INVALID_FIELD", Message: "rerank must be one of none,lexical,llm", Retryable: false}
+	}
+}
+
+// parseStrictArgument reads the strict argument of dir2mcp.ask, which
+// controls what happens to answer sentences verification finds unsupported.
+func parseStrictArgument(args map[string]interface{}) (string, *toolExecutionError) {
+	strict, err := parseOptionalString(args, "strict")
+	if err != nil {
+		return "", &toolExecutionError{Code: "INVALID_FIELD", Message: err.Error(), Retryable: false}
+	}
+	strict = strings.ToLower(strings.TrimSpace(strict))
+	switch strict {
+	case "":
+		return model.StrictOff, nil
+	case model.StrictOff, model.StrictFlag, model.StrictStrip:
+		return strict, nil
+	default:
+		return "", &toolExecutionError{Code: "INVALID_FIELD", Message: "strict must be one of off,flag,strip", Retryable: false}
+	}
+}
+
+// serializeVerification renders per-sentence support and the grounding
+// score of a generated answer.
+func serializeVerification(v model.AnswerVerification) map[string]interface{} {
+	sentences := make([]map[string]interface{}, 0, len(v.Sentences))
+	for _, sentence := range v.Sentences {
+		sources := append([]string{}, sentence.Sources...)
+		entry := map[string]interface{}{
+			"text":    sentence.Text,
+			"start":   sentence.Start,
+			"end":     sentence.End,
+			"sources": sources,
+			"status":  sentence.Status,
+			"score":   sentence.Score,
+			"method":  sentence.Method,
+		}
+		if sentence.Removed {
+			entry["removed"] = true
+		}
+		sentences = append(sentences, entry)
+	}
+	strict := v.Strict
+	if strict == "" {
+		strict = model.StrictOff
+	}
+	return map[string]interface{}{
+		"grounding_score": v.GroundingScore,
+		"unsupported":     v.Unsupported(),
+		"strict":          strict,
+		"sentences":       sentences,
+	}
+}
+
+// serializeAskContext renders the assembled generation prompt for ask debug
+// output.
+func serializeAskContext(c model.AskContext) map[string]interface{} {
+	blocks := make([]map[string]interface{}, 0, len(c.Blocks))
+	for _, block := range c.Blocks {
+		chunkIDs := append([]uint64{}, block.ChunkIDs...)
+		blocks = append(blocks, map[string]interface{}{
+			"rel_path":  block.RelPath,
+			"chunk_ids": chunkIDs,
+			"span":      buildOpenFileSpan(block.Span),
+			"tokens":    block.Tokens,
+			"text":      block.Text,
+		})
+	}
+	return map[string]interface{}{
+		"chat_model":    c.ChatModel,
+		"token_budget":  c.TokenBudget,
+		"tokens_used":   c.TokensUsed,
+		"system_prompt": c.SystemPrompt,
+		"user_prompt":   c.UserPrompt,
+		"blocks":        blocks,
+		"dropped_hits":  c.Dropped,
+		"truncated":     c.Truncated,
+	}
 }
 
 func buildAskStructuredContent(result model.AskResult) map[string]interface{} {
@@ -1921,13 +2324,17 @@ func buildAskStructuredContent(result model.AskResult) map[string]interface{} {
 		hits = append(hits, serializeHit(hit))
 	}
 
-	return map[string]interface{}{
+	structured := map[string]interface{}{
 		"question":          result.Question,
 		"answer":            result.Answer,
 		"citations":         citations,
 		"hits":              hits,
 		"indexing_complete": result.IndexingComplete,
 	}
+	if result.Verification != nil {
+		structured["verification"] = serializeVerification(*result.Verification)
+	}
+	return structured
 }
 
 func spanDefinitionSchema() map[string]interface{} {
@@ -1979,6 +2386,11 @@ func hitDefinitionSchema() map[string]interface{} {
 			"score":    map[string]interface{}{"type": "number"},
 			"snippet":  map[string]interface{}{"type": "string"},
 			"span":     map[string]interface{}{"$ref": "#/definitions/Span"},
+
+			"vector_score":  map[string]interface{}{"type": "number"},
+			"lexical_score": map[string]interface{}{"type": "number"},
+			"fused_rank":    map[string]interface{}{"type": "integer", "minimum": 1},
+			"rerank_score":  map[string]interface{}{"type": "number"},
 		},
 		"required": []string{"chunk_id", "rel_path", "score", "snippet", "span"},
 	}
@@ -1992,18 +2404,23 @@ func sharedDefinitions() map[string]interface{} {
 }
 
 func searchInputSchema() map[string]interface{} {
+	properties := map[string]interface{}{
+		"query":             map[string]interface{}{"type": "string", "minLength": 1},
+		"k":                 map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50, "default": 10},
+		"index":             map[string]interface{}{"type": "string", "enum": []string{"auto", "text", "code", "both", "hybrid"}, "default": "auto"},
+		"path_prefix":       map[string]interface{}{"type": "string"},
+		"file_glob":         map[string]interface{}{"type": "string"},
+		"doc_types":         map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"rerank":            rerankInputSchema(),
+		"mmr_lambda":        map[string]interface{}{"type": "number", "exclusiveMinimum": 0, "maximum": 1},
+		"max_hits_per_file": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50},
+	}
+	addMetadataFilterProperties(properties)
 	return map[string]interface{}{
 		"type":                 "object",
 		"additionalProperties": false,
-		"properties": map[string]interface{}{
-			"query":       map[string]interface{}{"type": "string", "minLength": 1},
-			"k":           map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50, "default": 10},
-			"index":       map[string]interface{}{"type": "string", "enum": []string{"auto", "text", "code", "both"}, "default": "auto"},
-			"path_prefix": map[string]interface{}{"type": "string"},
-			"file_glob":   map[string]interface{}{"type": "string"},
-			"doc_types":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
-		},
-		"required": []string{"query"},
+		"properties":           properties,
+		"required":             []string{"query"},
 	}
 }
 
@@ -2014,29 +2431,58 @@ func searchOutputSchema() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"query":             map[string]interface{}{"type": "string"},
 			"k":                 map[string]interface{}{"type": "integer"},
-			"index_used":        map[string]interface{}{"type": "string", "enum": []string{"text", "code", "both"}},
+			"index_used":        map[string]interface{}{"type": "string", "enum": []string{"text", "code", "both", "hybrid"}},
 			"hits":              map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/Hit"}},
 			"indexing_complete": map[string]interface{}{"type": "boolean"},
+			"rerank":            rerankInfoSchema(),
 		},
 		"required":    []string{"query", "hits", "indexing_complete"},
 		"definitions": sharedDefinitions(),
 	}
 }
 
-func askInputSchema() map[string]interface{} {
+func rerankInputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "enum": []string{"none", "lexical", "llm"}, "default": "none"}
+}
+
+// rerankInfoSchema describes the metadata returned when a search was
+// reranked.
+func rerankInfoSchema() map[string]interface{} {
 	return map[string]interface{}{
 		"type":                 "object",
 		"additionalProperties": false,
 		"properties": map[string]interface{}{
-			"question":    map[string]interface{}{"type": "string", "minLength": 1},
-			"k":           map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50, "default": 10},
-			"mode":        map[string]interface{}{"type": "string", "enum": []string{"answer", "search_only"}, "default": "answer"},
-			"index":       map[string]interface{}{"type": "string", "enum": []string{"auto", "text", "code", "both"}, "default": "auto"},
-			"path_prefix": map[string]interface{}{"type": "string"},
-			"file_glob":   map[string]interface{}{"type": "string"},
-			"doc_types":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"reranker":   map[string]interface{}{"type": "string"},
+			"candidates": map[string]interface{}{"type": "integer", "minimum": 0},
+			"latency_ms": map[string]interface{}{"type": "integer", "minimum": 0},
+			"error":      map[string]interface{}{"type": "string"},
 		},
-		"required": []string{"question"},
+		"required": []string{"reranker", "candidates", "latency_ms"},
+	}
+}
+
+func askInputSchema() map[string]interface{} {
+	properties := map[string]interface{}{
+		"question":          map[string]interface{}{"type": "string", "minLength": 1},
+		"k":                 map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50, "default": 10},
+		"mode":              map[string]interface{}{"type": "string", "enum": []string{"answer", "search_only"}, "default": "answer"},
+		"index":             map[string]interface{}{"type": "string", "enum": []string{"auto", "text", "code", "both", "hybrid"}, "default": "auto"},
+		"path_prefix":       map[string]interface{}{"type": "string"},
+		"file_glob":         map[string]interface{}{"type": "string"},
+		"doc_types":         map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"debug":             map[string]interface{}{"type": "boolean", "default": false},
+		"conversation_id":   map[string]interface{}{"type": "string", "maxLength": 128},
+		"rerank":            rerankInputSchema(),
+		"mmr_lambda":        map[string]interface{}{"type": "number", "exclusiveMinimum": 0, "maximum": 1},
+		"max_hits_per_file": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50},
+		"strict":            map[string]interface{}{"type": "string", "enum": []string{"off", "flag", "strip"}, "default": "off"},
+	}
+	addMetadataFilterProperties(properties)
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+		"required":             []string{"question"},
 	}
 }
 
@@ -2060,20 +2506,104 @@ func askOutputSchema() map[string]interface{} {
 					"required": []string{"chunk_id", "rel_path", "span"},
 				},
 			},
-			"hits":              map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/Hit"}},
-			"indexing_complete": map[string]interface{}{"type": "boolean"},
+			"hits":                map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/Hit"}},
+			"indexing_complete":   map[string]interface{}{"type": "boolean"},
+			"context":             askContextSchema(),
+			"rerank":              rerankInfoSchema(),
+			"conversation_id":     map[string]interface{}{"type": "string"},
+			"standalone_question": map[string]interface{}{"type": "string"},
+			"verification":        verificationSchema(),
 		},
 		"required":    []string{"question", "citations", "hits", "indexing_complete"},
 		"definitions": sharedDefinitions(),
 	}
 }
 
+// verificationSchema describes the citation verification report attached
+// to generated answers.
+func verificationSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"grounding_score": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+			"unsupported":     map[string]interface{}{"type": "integer", "minimum": 0},
+			"strict":          map[string]interface{}{"type": "string", "enum": []string{"off", "flag", "strip"}},
+			"sentences": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"text":    map[string]interface{}{"type": "string"},
+						"start":   map[string]interface{}{"type": "integer", "minimum": 0},
+						"end":     map[string]interface{}{"type": "integer", "minimum": 0},
+						"sources": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"status":  map[string]interface{}{"type": "string", "enum": []string{"supported", "partial", "unsupported"}},
+						"score":   map[string]interface{}{"type": "number"},
+						"method":  map[string]interface{}{"type": "string", "enum": []string{"lexical", "generator"}},
+						"removed": map[string]interface{}{"type": "boolean"},
+					},
+					"required": []string{"text", "start", "end", "sources", "status", "score", "method"},
+				},
+			},
+		},
+		"required": []string{"grounding_score", "unsupported", "strict", "sentences"},
+	}
+}
+
+// askContextSchema describes the debug view of the assembled generation
+// prompt returned by dir2mcp.ask when debug is set.
+func askContextSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"chat_model":    map[string]interface{}{"type": "string"},
+			"token_budget":  map[string]interface{}{"type": "integer"},
+			"tokens_used":   map[string]interface{}{"type": "integer"},
+			"system_prompt": map[string]interface{}{"type": "string"},
+			"user_prompt":   map[string]interface{}{"type": "string"},
+			"blocks": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"rel_path":  map[string]interface{}{"type": "string"},
+						"chunk_ids": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+						"span":      map[string]interface{}{"$ref": "#/definitions/Span"},
+						"tokens":    map[string]interface{}{"type": "integer"},
+						"text":      map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"rel_path", "chunk_ids", "span", "tokens", "text"},
+				},
+			},
+			"dropped_hits": map[string]interface{}{"type": "integer"},
+			"truncated":    map[string]interface{}{"type": "boolean"},
+		},
+		"required": []string{"chat_model", "token_budget", "tokens_used", "system_prompt", "user_prompt", "blocks"},
+	}
+}
+
 func askAudioInputSchema() map[string]interface{} {
 	schema := askInputSchema()
 	properties, ok := schema["properties"].(map[string]interface{})
 	if !ok {
 		return askInputSchema()
 	}
+	// context debug output, reranking, diversification, conversations,
+	// strict verification and metadata filters are only offered by
+	// dir2mcp.ask.
+	delete(properties, "debug")
+	delete(properties, "rerank")
+	delete(properties, "mmr_lambda")
+	delete(properties, "max_hits_per_file")
+	delete(properties, "conversation_id")
+	delete(properties, "strict")
+	for _, key := range []string{"modified_after", "modified_before", "min_size_bytes", "max_size_bytes", "statuses", "source_types", "rep_types", "languages"} {
+		delete(properties, key)
+	}
 	properties["voice_id"] = map[string]interface{}{"type": "string", "minLength": 1}
 	return schema
 }
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"dir2mcp/internal/model"
+)
+
+// Metrics is the set of metrics recorded by the MCP server and the provider
+// clients. Values kept elsewhere, such as ingestion counters and index
+// sizes, are added to Registry as gauge and counter funcs by the caller.
+type Metrics struct {
+	Registry *Registry
+
+	requests         *CounterVec
+	requestDuration  *HistogramVec
+	errors           *CounterVec
+	rateLimited      *CounterVec
+	x402             *CounterVec
+	providerCalls    *CounterVec
+	providerDuration *HistogramVec
+	providerRetries  *CounterVec
+}
+
+// New returns a Metrics with its own registry.
+func New() *Metrics {
+	reg := NewRegistry()
+	return &Metrics{
+		Registry: reg,
+		requests: reg.NewCounterVec("dir2mcp_mcp_requests_total",
+			"JSON-RPC requests handled, by method, tool and status.", "method", "tool", "status"),
+		requestDuration: reg.NewHistogramVec("dir2mcp_mcp_request_duration_seconds",
+			"Time to answer a JSON-RPC request, by method and tool.", DefaultBuckets, "method", "tool"),
+		errors: reg.NewCounterVec("dir2mcp_mcp_errors_total",
+			"JSON-RPC requests that failed, by method and canonical error code.", "method", "code"),
+		rateLimited: reg.NewCounterVec("dir2mcp_rate_limit_rejections_total",
+			"Requests rejected with 429, by limiter (ip or token).", "limiter"),
+		x402: reg.NewCounterVec("dir2mcp_x402_operations_total",
+			"x402 facilitator calls, by stage (verify or settle) and outcome.", "stage", "outcome"),
+		providerCalls: reg.NewCounterVec("dir2mcp_provider_requests_total",
+			"Provider API attempts, by provider, operation and outcome.", "provider", "operation", "outcome"),
+		providerDuration: reg.NewHistogramVec("dir2mcp_provider_request_duration_seconds",
+			"Provider API attempt latency, by provider and operation.", DefaultBuckets, "provider", "operation"),
+		providerRetries: reg.NewCounterVec("dir2mcp_provider_retries_total",
+			"Provider API attempts that were retried after a retryable error.", "provider", "operation"),
+	}
+}
+
+// ObserveRequest records one JSON-RPC request. tool is empty unless method
+// is tools/call, and code is empty when the request succeeded.
+func (m *Metrics) ObserveRequest(method, tool, code string, duration time.Duration) {
+	status := "ok"
+	if code != "" {
+		status = "error"
+		m.errors.Inc(method, code)
+	}
+	m.requests.Inc(method, tool, status)
+	m.requestDuration.Observe(duration.Seconds(), method, tool)
+}
+
+// ObserveRateLimited records a request rejected by limiter.
+func (m *Metrics) ObserveRateLimited(limiter string) {
+	m.rateLimited.Inc(limiter)
+}
+
+// ObserveX402 records the outcome of a verify or settle call.
+func (m *Metrics) ObserveX402(stage, outcome string) {
+	m.x402.Inc(stage, outcome)
+}
+
+// ObserveProviderCall implements model.ProviderObserver.
+func (m *Metrics) ObserveProviderCall(provider, operation string, duration time.Duration, err error) {
+	m.providerCalls.Inc(provider, operation, providerOutcome(err))
+	m.providerDuration.Observe(duration.Seconds(), provider, operation)
+}
+
+// ObserveProviderRetry implements model.ProviderObserver.
+func (m *Metrics) ObserveProviderRetry(provider, operation string) {
+	m.providerRetries.Inc(provider, operation)
+}
+
+// providerOutcome keeps the outcome label bounded: "ok", the provider error
+// code, or "error".
+func providerOutcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var providerErr *model.ProviderError
+	if errors.As(err, &providerErr) && providerErr.Code != "" {
+		return providerErr.Code
+	}
+	return "error"
+}
@@ -0,0 +1,310 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// writes them in the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the histogram bounds, in seconds, used for latencies.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds named metrics. Metrics are written sorted by name.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[name]; exists {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.collectors[name] = c
+}
+
+// WriteText writes every metric to w.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry. When token is not empty, requests must carry
+// it as a bearer token.
+func (r *Registry) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" {
+			provided, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="dir2mcp metrics"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", ContentType)
+		if req.Method == http.MethodHead {
+			return
+		}
+		_ = r.WriteText(w)
+	})
+}
+
+// vec stores one value per label combination.
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// histogram state
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]*series)}
+}
+
+// seriesFor returns the series for labelValues. Callers hold v.mu.
+func (v *vec) seriesFor(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	return s
+}
+
+func (v *vec) sortedSeries() []*series {
+	out := make([]*series, 0, len(v.values))
+	for _, s := range v.values {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].labelValues, "\xff") < strings.Join(out[j].labelValues, "\xff")
+	})
+	return out
+}
+
+func (v *vec) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.writeHeader(w)
+	for _, s := range v.sortedSeries() {
+		writeSample(w, v.name, v.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct{ v *vec }
+
+// NewCounterVec registers a counter. Its name should end in _total.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{v: newVec(name, help, "counter", labels)}
+	r.register(name, c.v)
+	return c
+}
+
+// Inc adds one to the series for labelValues.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the series for labelValues.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.v.mu.Lock()
+	c.v.seriesFor(labelValues).value += delta
+	c.v.mu.Unlock()
+}
+
+// Value returns the current value of the series for labelValues.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.v.mu.Lock()
+	defer c.v.mu.Unlock()
+	return c.v.seriesFor(labelValues).value
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	v       *vec
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{v: newVec(name, help, "histogram", labels), buckets: append([]float64(nil), buckets...)}
+	sort.Float64s(h.buckets)
+	r.register(name, h)
+	return h
+}
+
+// Observe records value in the series for labelValues.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.v.mu.Lock()
+	defer h.v.mu.Unlock()
+	s := h.v.seriesFor(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Count returns how many values the series for labelValues has observed.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.v.mu.Lock()
+	defer h.v.mu.Unlock()
+	return h.v.seriesFor(labelValues).count
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.v.mu.Lock()
+	defer h.v.mu.Unlock()
+	h.v.writeHeader(w)
+	for _, s := range h.v.sortedSeries() {
+		for i, bound := range h.buckets {
+			writeSample(w, h.v.name+"_bucket", h.v.labels, s.labelValues, "le", formatFloat(bound), float64(s.buckets[i]))
+		}
+		writeSample(w, h.v.name+"_bucket", h.v.labels, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.v.name+"_sum", h.v.labels, s.labelValues, "", "", s.sum)
+		writeSample(w, h.v.name+"_count", h.v.labels, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// funcCollector reads its values when the registry is written.
+type funcCollector struct {
+	v  *vec
+	fn func(emit func(value float64, labelValues ...string))
+}
+
+func (f *funcCollector) write(w *bufio.Writer) {
+	f.v.writeHeader(w)
+	f.fn(func(value float64, labelValues ...string) {
+		if len(labelValues) != len(f.v.labels) {
+			return
+		}
+		writeSample(w, f.v.name, f.v.labels, labelValues, "", "", value)
+	})
+}
+
+// GaugeFunc registers a gauge whose values fn reports at each scrape, one
+// emit call per label combination.
+func (r *Registry) GaugeFunc(name, help string, labels []string, fn func(emit func(value float64, labelValues ...string))) {
+	r.register(name, &funcCollector{v: newVec(name, help, "gauge", labels), fn: fn})
+}
+
+// CounterFunc is GaugeFunc for values that only grow, such as counters kept
+// by another package.
+func (r *Registry) CounterFunc(name, help string, labels []string, fn func(emit func(value float64, labelValues ...string))) {
+	r.register(name, &funcCollector{v: newVec(name, help, "counter", labels), fn: fn})
+}
+
+func writeSample(w *bufio.Writer, name string, labels, labelValues []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabelValue(labelValues[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string        { return helpEscaper.Replace(help) }
+func escapeLabelValue(value string) string { return labelEscaper.Replace(value) }
@@ -1,6 +1,7 @@
 package mistral
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -94,6 +95,9 @@ type Client struct {
 	// specify an explicit model.  It is initialized to DefaultOCRModel by
 	// NewClient and can be mutated by callers to customize behaviour.
 	DefaultOCRModel string
+
+	// Observer, when set, is told about every attempt and retry.
+	Observer model.ProviderObserver
 }
 
 // NewClient constructs a client with safe default retry/timeout settings.
@@ -210,6 +214,7 @@ type transcribeResponse struct {
 type generateRequest struct {
 	Model    string            `json:"model"`
 	Messages []generateMessage `json:"messages"`
+	Stream   bool              `json:"stream,omitempty"`
 }
 
 type generateMessage struct {
@@ -225,6 +230,15 @@ type generateResponse struct {
 	} `json:"choices"`
 }
 
+// generateStreamChunk is one server-sent event of a streamed completion.
+type generateStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content interface{} `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
 func (c *Client) embedBatchWithRetry(ctx context.Context, modelName string, inputs []string) ([][]float32, error) {
 	maxRetries := c.MaxRetries
 	if maxRetries < 0 {
@@ -233,7 +247,9 @@ func (c *Client) embedBatchWithRetry(ctx context.Context, modelName string, inpu
 
 	var lastErr error
 	for attempt := 0; attempt <= maxRetries; attempt++ {
+		start := time.Now()
 		vectors, err := c.embedBatch(ctx, modelName, inputs)
+		c.observeCall("embed", start, err)
 		if err == nil {
 			return vectors, nil
 		}
@@ -244,6 +260,7 @@ func (c *Client) embedBatchWithRetry(ctx context.Context, modelName string, inpu
 			return nil, err
 		}
 
+		c.observeRetry("embed")
 		backoff := c.backoffForAttempt(attempt)
 		if waitErr := c.wait(ctx, backoff); waitErr != nil {
 			return nil, waitErr
@@ -292,7 +309,7 @@ func (c *Client) embedBatch(ctx context.Context, modelName string, inputs []stri
 		return nil, &model.ProviderError{
 			Code:      "MISTRAL_FAILED",
 			Message:   "embedding request failed",
-			Retryable: true,
+			Retryable: ctx.Err() == nil,
 			Cause:     err,
 		}
 	}
@@ -400,6 +417,18 @@ func (c *Client) embedBatch(ctx context.Context, modelName string, inputs []stri
 	return vectors, nil
 }
 
+func (c *Client) observeCall(operation string, start time.Time, err error) {
+	if c.Observer != nil {
+		c.Observer.ObserveProviderCall("mistral", operation, time.Since(start), err)
+	}
+}
+
+func (c *Client) observeRetry(operation string) {
+	if c.Observer != nil {
+		c.Observer.ObserveProviderRetry("mistral", operation)
+	}
+}
+
 func (c *Client) backoffForAttempt(attempt int) time.Duration {
 	initial := c.InitialBackoff
 	if initial <= 0 {
@@ -456,7 +485,9 @@ func (c *Client) extractWithRetry(ctx context.Context, relPath string, data []by
 
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
+		start := time.Now()
 		out, err := c.extractOnce(ctx, relPath, data)
+		c.observeCall("ocr", start, err)
 		if err == nil {
 			return out, nil
 		}
@@ -467,6 +498,7 @@ func (c *Client) extractWithRetry(ctx context.Context, relPath string, data []by
 			return "", err
 		}
 
+		c.observeRetry("ocr")
 		backoff := c.backoffForAttempt(attempt)
 		if waitErr := c.wait(ctx, backoff); waitErr != nil {
 			return "", waitErr
@@ -572,7 +604,7 @@ func (c *Client) extractOnce(ctx context.Context, relPath string, data []byte) (
 		return "", &model.ProviderError{
 			Code:      "MISTRAL_FAILED",
 			Message:   "ocr request failed",
-			Retryable: true,
+			Retryable: ctx.Err() == nil,
 			Cause:     err,
 		}
 	}
@@ -644,7 +676,14 @@ func (c *Client) Transcribe(ctx context.Context, relPath string, data []byte) (s
 }
 
 func (c *Client) Generate(ctx context.Context, prompt string) (string, error) {
-	return c.generateWithRetry(ctx, prompt)
+	return c.generateWithRetry(ctx, "", prompt)
+}
+
+// GenerateWithSystem is like Generate but sends system as a separate system
+// message ahead of the user prompt. An empty system string behaves exactly
+// like Generate.
+func (c *Client) GenerateWithSystem(ctx context.Context, system, prompt string) (string, error) {
+	return c.generateWithRetry(ctx, system, prompt)
 }
 
 func (c *Client) transcribeWithRetry(ctx context.Context, relPath string, data []byte) (string, error) {
@@ -656,7 +695,9 @@ func (c *Client) transcribeWithRetry(ctx context.Context, relPath string, data [
 
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
+		start := time.Now()
 		out, err := c.transcribeOnce(ctx, relPath, data)
+		c.observeCall("transcribe", start, err)
 		if err == nil {
 			return out, nil
 		}
@@ -667,6 +708,7 @@ func (c *Client) transcribeWithRetry(ctx context.Context, relPath string, data [
 			return "", err
 		}
 
+		c.observeRetry("transcribe")
 		backoff := c.backoffForAttempt(attempt)
 		if waitErr := c.wait(ctx, backoff); waitErr != nil {
 			return "", waitErr
@@ -785,7 +827,7 @@ func (c *Client) transcribeOnce(ctx context.Context, relPath string, data []byte
 		return "", &model.ProviderError{
 			Code:      "MISTRAL_FAILED",
 			Message:   "transcription request failed",
-			Retryable: true,
+			Retryable: ctx.Err() == nil,
 			Cause:     err,
 		}
 	}
@@ -854,7 +896,7 @@ func pad2(n int) string {
 	return strconv.Itoa(n)
 }
 
-func (c *Client) generateWithRetry(ctx context.Context, prompt string) (string, error) {
+func (c *Client) generateWithRetry(ctx context.Context, system, prompt string) (string, error) {
 	maxAttempts := c.MaxRetries + 1
 	if maxAttempts <= 0 {
 		maxAttempts = 1
@@ -862,7 +904,9 @@ func (c *Client) generateWithRetry(ctx context.Context, prompt string) (string,
 
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
-		out, err := c.generateOnce(ctx, prompt)
+		start := time.Now()
+		out, err := c.generateOnce(ctx, system, prompt)
+		c.observeCall("generate", start, err)
 		if err == nil {
 			return out, nil
 		}
@@ -873,6 +917,7 @@ func (c *Client) generateWithRetry(ctx context.Context, prompt string) (string,
 			return "", err
 		}
 
+		c.observeRetry("generate")
 		backoff := c.backoffForAttempt(attempt)
 		if waitErr := c.wait(ctx, backoff); waitErr != nil {
 			return "", waitErr
@@ -882,9 +927,170 @@ func (c *Client) generateWithRetry(ctx context.Context, prompt string) (string,
 	return "", lastErr
 }
 
-func (c *Client) generateOnce(ctx context.Context, prompt string) (string, error) {
-	if strings.TrimSpace(c.APIKey) == "" {
+func (c *Client) generateOnce(ctx context.Context, system, prompt string) (string, error) {
+	req, client, err := c.newGenerateRequest(ctx, system, prompt, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
 		return "", &model.ProviderError{
+			Code:      "MISTRAL_FAILED",
+			Message:   "generation request failed",
+			Retryable: ctx.Err() == nil,
+			Cause:     err,
+		}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", generateStatusError(resp)
+	}
+
+	var parsed generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", &model.ProviderError{
+			Code:      "MISTRAL_FAILED",
+			Message:   "failed to decode generation response",
+			Retryable: false,
+			Cause:     err,
+		}
+	}
+	if len(parsed.Choices) == 0 {
+		return "", &model.ProviderError{
+			Code:      "MISTRAL_FAILED",
+			Message:   "generation response had no choices",
+			Retryable: false,
+		}
+	}
+	text := strings.TrimSpace(contentToText(parsed.Choices[0].Message.Content))
+	if text == "" {
+		return "", &model.ProviderError{
+			Code:      "MISTRAL_FAILED",
+			Message:   "generation response had empty content",
+			Retryable: false,
+		}
+	}
+	return text, nil
+}
+
+// GenerateStream is like GenerateWithSystem but requests a streamed
+// completion and passes each content delta to onDelta as it arrives. A failed
+// attempt is only retried while no delta has been delivered, so callers never
+// receive the same text twice.
+func (c *Client) GenerateStream(ctx context.Context, system, prompt string, onDelta func(string)) (string, error) {
+	maxAttempts := c.MaxRetries + 1
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		start := time.Now()
+		out, delivered, err := c.generateStreamOnce(ctx, system, prompt, onDelta)
+		c.observeCall("generate_stream", start, err)
+		if err == nil {
+			return out, nil
+		}
+		lastErr = err
+
+		var providerErr *model.ProviderError
+		if delivered || !errors.As(err, &providerErr) || !providerErr.Retryable || attempt == maxAttempts-1 {
+			return "", err
+		}
+
+		c.observeRetry("generate_stream")
+		backoff := c.backoffForAttempt(attempt)
+		if waitErr := c.wait(ctx, backoff); waitErr != nil {
+			return "", waitErr
+		}
+	}
+
+	return "", lastErr
+}
+
+// generateStreamOnce performs one streamed chat completion. delivered reports
+// whether any delta reached onDelta before an error.
+func (c *Client) generateStreamOnce(ctx context.Context, system, prompt string, onDelta func(string)) (text string, delivered bool, err error) {
+	req, client, err := c.newGenerateRequest(ctx, system, prompt, true)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, &model.ProviderError{
+			Code:      "MISTRAL_FAILED",
+			Message:   "generation request failed",
+			Retryable: ctx.Err() == nil,
+			Cause:     err,
+		}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", false, generateStatusError(resp)
+	}
+
+	var out strings.Builder
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if data, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "data:"); ok {
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+			var chunk generateStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return "", delivered, &model.ProviderError{
+					Code:      "MISTRAL_FAILED",
+					Message:   "failed to decode generation stream event",
+					Retryable: false,
+					Cause:     err,
+				}
+			}
+			if len(chunk.Choices) > 0 {
+				if delta := contentToText(chunk.Choices[0].Delta.Content); delta != "" {
+					out.WriteString(delta)
+					delivered = true
+					if onDelta != nil {
+						onDelta(delta)
+					}
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", delivered, &model.ProviderError{
+				Code:      "MISTRAL_FAILED",
+				Message:   "generation stream interrupted",
+				Retryable: true,
+				Cause:     readErr,
+			}
+		}
+	}
+
+	text = strings.TrimSpace(out.String())
+	if text == "" {
+		return "", delivered, &model.ProviderError{
+			Code:      "MISTRAL_FAILED",
+			Message:   "generation response had empty content",
+			Retryable: false,
+		}
+	}
+	return text, delivered, nil
+}
+
+// newGenerateRequest builds a chat completions request and the HTTP client
+// to send it with, validating the key and prompt first.
+func (c *Client) newGenerateRequest(ctx context.Context, system, prompt string, stream bool) (*http.Request, *http.Client, error) {
+	if strings.TrimSpace(c.APIKey) == "" {
+		return nil, nil, &model.ProviderError{
 			Code:      "MISTRAL_AUTH",
 			Message:   "missing Mistral API key",
 			Retryable: false,
@@ -892,7 +1098,7 @@ func (c *Client) generateOnce(ctx context.Context, prompt string) (string, error
 	}
 	prompt = strings.TrimSpace(prompt)
 	if prompt == "" {
-		return "", &model.ProviderError{
+		return nil, nil, &model.ProviderError{
 			Code:      "MISTRAL_FAILED",
 			Message:   "prompt is required",
 			Retryable: false,
@@ -904,15 +1110,19 @@ func (c *Client) generateOnce(ctx context.Context, prompt string) (string, error
 	if strings.TrimSpace(c.DefaultChatModel) != "" {
 		chatModel = c.DefaultChatModel
 	}
+	messages := make([]generateMessage, 0, 2)
+	if system = strings.TrimSpace(system); system != "" {
+		messages = append(messages, generateMessage{Role: "system", Content: system})
+	}
+	messages = append(messages, generateMessage{Role: "user", Content: prompt})
 	reqPayload := generateRequest{
-		Model: chatModel,
-		Messages: []generateMessage{
-			{Role: "user", Content: prompt},
-		},
+		Model:    chatModel,
+		Messages: messages,
+		Stream:   stream,
 	}
 	body, err := json.Marshal(reqPayload)
 	if err != nil {
-		return "", &model.ProviderError{
+		return nil, nil, &model.ProviderError{
 			Code:      "MISTRAL_FAILED",
 			Message:   "failed to marshal generation request",
 			Retryable: false,
@@ -922,7 +1132,7 @@ func (c *Client) generateOnce(ctx context.Context, prompt string) (string, error
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/v1/chat/completions", bytes.NewReader(body))
 	if err != nil {
-		return "", &model.ProviderError{
+		return nil, nil, &model.ProviderError{
 			Code:      "MISTRAL_FAILED",
 			Message:   "failed to build generation request",
 			Retryable: false,
@@ -944,61 +1154,27 @@ func (c *Client) generateOnce(ctx context.Context, prompt string) (string, error
 	} else {
 		client = cloneHTTPClientWithTimeout(client, timeout)
 	}
+	return req, client, nil
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", &model.ProviderError{
-			Code:      "MISTRAL_FAILED",
-			Message:   "generation request failed",
-			Retryable: true,
-			Cause:     err,
-		}
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		errMsg := strings.TrimSpace(string(bodyBytes))
-		if errMsg == "" {
-			errMsg = "upstream returned non-200 response"
-		}
-		switch {
-		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
-			return "", &model.ProviderError{Code: "MISTRAL_AUTH", Message: errMsg, Retryable: false, StatusCode: resp.StatusCode}
-		case resp.StatusCode == http.StatusTooManyRequests:
-			return "", &model.ProviderError{Code: "MISTRAL_RATE_LIMIT", Message: errMsg, Retryable: true, StatusCode: resp.StatusCode}
-		case resp.StatusCode >= http.StatusInternalServerError:
-			return "", &model.ProviderError{Code: "MISTRAL_FAILED", Message: errMsg, Retryable: true, StatusCode: resp.StatusCode}
-		default:
-			return "", &model.ProviderError{Code: "MISTRAL_FAILED", Message: errMsg, Retryable: false, StatusCode: resp.StatusCode}
-		}
-	}
-
-	var parsed generateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return "", &model.ProviderError{
-			Code:      "MISTRAL_FAILED",
-			Message:   "failed to decode generation response",
-			Retryable: false,
-			Cause:     err,
-		}
-	}
-	if len(parsed.Choices) == 0 {
-		return "", &model.ProviderError{
-			Code:      "MISTRAL_FAILED",
-			Message:   "generation response had no choices",
-			Retryable: false,
-		}
-	}
-	text := strings.TrimSpace(contentToText(parsed.Choices[0].Message.Content))
-	if text == "" {
-		return "", &model.ProviderError{
-			Code:      "MISTRAL_FAILED",
-			Message:   "generation response had empty content",
-			Retryable: false,
-		}
+// generateStatusError maps a non-200 chat completions response to a
+// provider error.
+func generateStatusError(resp *http.Response) error {
+	bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	errMsg := strings.TrimSpace(string(bodyBytes))
+	if errMsg == "" {
+		errMsg = "upstream returned non-200 response"
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &model.ProviderError{Code: "MISTRAL_AUTH", Message: errMsg, Retryable: false, StatusCode: resp.StatusCode}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &model.ProviderError{Code: "MISTRAL_RATE_LIMIT", Message: errMsg, Retryable: true, StatusCode: resp.StatusCode}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return &model.ProviderError{Code: "MISTRAL_FAILED", Message: errMsg, Retryable: true, StatusCode: resp.StatusCode}
+	default:
+		return &model.ProviderError{Code: "MISTRAL_FAILED", Message: errMsg, Retryable: false, StatusCode: resp.StatusCode}
 	}
-	return text, nil
 }
 
 func cloneHTTPClientWithTimeout(base *http.Client, timeout time.Duration) *http.Client {
@@ -27,6 +27,10 @@ var (
 
 	// ErrDocTypeUnsupported indicates the requested span/doc mode isn't supported.
 	ErrDocTypeUnsupported = errors.New("doc type unsupported")
+
+	// ErrUnknownReranker is returned when a search requests a reranker that
+	// is not registered with the retriever.
+	ErrUnknownReranker = errors.New("unknown reranker")
 )
 
 type ProviderError struct {
@@ -0,0 +1,56 @@
+package model
+
+import "regexp"
+
+// GrepQuery describes a line-oriented pattern search over the raw text of
+// indexed files.
+type GrepQuery struct {
+	// Pattern is an RE2 expression, or an exact string when Literal is set.
+	Pattern    string
+	Literal    bool
+	IgnoreCase bool
+	PathPrefix string
+	FileGlob   string
+	// ContextLines is how many lines to report before and after each match.
+	ContextLines int
+	// MaxMatches stops the search after this many matching lines; zero uses
+	// the retriever's default.
+	MaxMatches int
+}
+
+// Regexp compiles the query pattern, quoting it when Literal is set.
+func (q GrepQuery) Regexp() (*regexp.Regexp, error) {
+	pattern := q.Pattern
+	if q.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if q.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// GrepMatch is one matching line with its surrounding context.
+type GrepMatch struct {
+	RelPath string
+	// Span is a lines span covering the matching line only.
+	Span   Span
+	Line   string
+	Before []string
+	After  []string
+}
+
+// GrepResult summarises a grep run. Matches holds every match that was
+// reported, in file and line order.
+type GrepResult struct {
+	Matches      []GrepMatch
+	FilesScanned int
+	// FilesSkipped counts files that were excluded, unreadable, or withheld
+	// because they contain secrets.
+	FilesSkipped int
+	// Truncated reports that the search stopped at MaxMatches.
+	Truncated bool
+	// TimedOut reports that the context deadline expired before every file
+	// was scanned; Matches holds what was found until then.
+	TimedOut bool
+}
@@ -1,6 +1,9 @@
 package model
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Store interface {
 	Init(ctx context.Context) error
@@ -32,6 +35,14 @@ type Retriever interface {
 	IndexingComplete(ctx context.Context) (bool, error)
 }
 
+// ProviderObserver is told about each call a provider client makes, for
+// metrics. err is nil for a successful call. Implementations must be safe for
+// concurrent use.
+type ProviderObserver interface {
+	ObserveProviderCall(provider, operation string, duration time.Duration, err error)
+	ObserveProviderRetry(provider, operation string)
+}
+
 type Ingestor interface {
 	Run(ctx context.Context) error
 	Reindex(ctx context.Context) error
@@ -53,6 +64,28 @@ type Generator interface {
 	Generate(ctx context.Context, prompt string) (string, error)
 }
 
+// Reranker scores first-stage search candidates against a query. It returns
+// one relevance score per candidate, in candidate order; higher is more
+// relevant and scores are only compared within a single call.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, candidates []SearchHit) ([]float64, error)
+}
+
+// SystemPromptGenerator is implemented by generators that accept system
+// instructions as a message separate from the user content, so retrieved
+// text cannot masquerade as instructions.
+type SystemPromptGenerator interface {
+	GenerateWithSystem(ctx context.Context, system, prompt string) (string, error)
+}
+
+// StreamingGenerator is implemented by generators that can deliver a
+// completion incrementally. onDelta receives each piece of text as it
+// arrives, in order; the returned string is the complete, trimmed
+// completion. An empty system string sends no system message.
+type StreamingGenerator interface {
+	GenerateStream(ctx context.Context, system, prompt string, onDelta func(string)) (string, error)
+}
+
 // RepresentationStore defines the subset of store operations used by the
 // ingest package for handling representations and their chunks.  It is
 // defined here in the model package to avoid cyclic dependencies between the
@@ -0,0 +1,84 @@
+package model
+
+import "context"
+
+// Symbol kinds recorded in the symbol table. Heuristic extractors map each
+// language's declarations onto this shared set.
+const (
+	SymbolKindFunction  = "function"
+	SymbolKindMethod    = "method"
+	SymbolKindType      = "type"
+	SymbolKindStruct    = "struct"
+	SymbolKindInterface = "interface"
+	SymbolKindClass     = "class"
+	SymbolKindEnum      = "enum"
+	SymbolKindModule    = "module"
+	SymbolKindConst     = "const"
+	SymbolKindVar       = "var"
+	SymbolKindField     = "field"
+	SymbolKindTable     = "table"
+)
+
+// Symbol is one declaration found in an indexed code file.
+type Symbol struct {
+	SymbolID int64
+	DocID    int64
+	RelPath  string
+	Name     string
+	Kind     string
+	// Container is the enclosing type or class of methods and fields, empty
+	// for top-level declarations.
+	Container string
+	Language  string
+	// Span is a lines span covering the whole declaration.
+	Span Span
+	// Signature is the declaration's first source line, trimmed.
+	Signature string
+	// Exact reports that the symbol came from a real parser rather than
+	// heuristic tag extraction.
+	Exact bool
+}
+
+// SymbolQuery selects symbols from the symbol table.
+type SymbolQuery struct {
+	// Name matches symbol names case-insensitively: exactly when Exact is
+	// set, as a substring otherwise. An empty Name matches every symbol.
+	Name       string
+	Exact      bool
+	Kinds      []string
+	Container  string
+	Language   string
+	PathPrefix string
+	FileGlob   string
+	// Limit caps the number of symbols returned; zero uses the store's
+	// default.
+	Limit int
+}
+
+// ReferenceQuery selects the places an identifier is used.
+type ReferenceQuery struct {
+	Name       string
+	Language   string
+	PathPrefix string
+	FileGlob   string
+	// IncludeDefinitions keeps the lines that declare Name.
+	IncludeDefinitions bool
+	ContextLines       int
+	MaxMatches         int
+}
+
+// ReferenceResult lists the places an identifier is used. Definitions holds
+// the symbols declaring the name; their declaration lines are left out of
+// Matches unless the query includes definitions.
+type ReferenceResult struct {
+	Definitions []Symbol
+	GrepResult
+}
+
+// SymbolStore is implemented by stores that keep a symbol table. It is
+// optional; callers detect it with a type assertion.
+type SymbolStore interface {
+	// ReplaceSymbols swaps every symbol of docID for symbols.
+	ReplaceSymbols(ctx context.Context, docID int64, symbols []Symbol) error
+	SearchSymbols(ctx context.Context, query SymbolQuery) ([]Symbol, error)
+}
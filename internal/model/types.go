@@ -17,6 +17,20 @@ type Document struct {
 	Deleted     bool
 }
 
+// Document change kinds reported by ingestion.
+const (
+	DocumentAdded   = "added"
+	DocumentUpdated = "updated"
+	DocumentDeleted = "deleted"
+)
+
+// DocumentChange reports that ingestion added, re-processed or deleted the
+// document at RelPath.
+type DocumentChange struct {
+	RelPath string
+	Kind    string
+}
+
 type Representation struct {
 	RepID       int64
 	DocID       int64
@@ -54,6 +68,45 @@ type SearchQuery struct {
 	PathPrefix string
 	FileGlob   string
 	DocTypes   []string
+	// Rerank names the second-stage reranker to apply ("lexical", "llm");
+	// empty or "none" keeps first-stage order.
+	Rerank string
+	// MMRLambda enables maximal marginal relevance diversification when in
+	// (0,1): lower values trade relevance for diversity. Zero disables it.
+	MMRLambda float64
+	// MaxHitsPerFile caps how many hits a single file may contribute; zero
+	// means unlimited.
+	MaxHitsPerFile int
+	// MetadataFilter restricts hits by document and representation
+	// attributes. Stores that support it resolve the matching chunks before
+	// vector scoring.
+	MetadataFilter
+}
+
+// MetadataFilter selects chunks by attributes of their document and
+// representation. Zero values disable the corresponding filter; list filters
+// match any of their entries.
+type MetadataFilter struct {
+	// ModifiedAfter and ModifiedBefore bound the document mtime in unix
+	// seconds; the lower bound is inclusive and the upper bound exclusive.
+	ModifiedAfter  int64
+	ModifiedBefore int64
+	// MinSizeBytes and MaxSizeBytes bound the document size, inclusive.
+	MinSizeBytes int64
+	MaxSizeBytes int64
+	Statuses     []string
+	SourceTypes  []string
+	RepTypes     []string
+	// Languages lists code languages as named by ingest.CodeLanguage.
+	Languages []string
+}
+
+// IsZero reports whether the filter selects every chunk.
+func (f MetadataFilter) IsZero() bool {
+	return f.ModifiedAfter == 0 && f.ModifiedBefore == 0 &&
+		f.MinSizeBytes == 0 && f.MaxSizeBytes == 0 &&
+		len(f.Statuses) == 0 && len(f.SourceTypes) == 0 &&
+		len(f.RepTypes) == 0 && len(f.Languages) == 0
 }
 
 type SearchHit struct {
@@ -64,6 +117,58 @@ type SearchHit struct {
 	Score   float64
 	Snippet string
 	Span    Span
+
+	// Score components reported by hybrid retrieval. VectorScore and
+	// LexicalScore are zero when the corresponding signal did not match the
+	// chunk; FusedRank is the 1-based position after fusion and zero for
+	// non-hybrid searches.
+	VectorScore  float64
+	LexicalScore float64
+	FusedRank    int
+
+	// RerankScore is the relevance assigned by a second-stage reranker and
+	// is only meaningful when Reranked is set. Score keeps the first-stage
+	// value.
+	RerankScore float64
+	Reranked    bool
+}
+
+// SearchResult is a search response together with metadata about how it was
+// produced.
+type SearchResult struct {
+	Hits   []SearchHit
+	Rerank *RerankInfo
+}
+
+// SimilarQuery seeds a "more like this" search with an indexed chunk or file
+// instead of query text. Exactly one of ChunkID and RelPath is set. K, Index
+// and the filters of the embedded SearchQuery apply as for a search; Query,
+// Rerank and the diversity settings are ignored.
+type SimilarQuery struct {
+	ChunkID uint64
+	RelPath string
+	// Span, when its Kind is set, restricts a RelPath seed to the chunks
+	// overlapping it.
+	Span Span
+	SearchQuery
+}
+
+// SimilarResult holds the hits of a find-similar search.
+type SimilarResult struct {
+	// SeedChunkIDs lists the chunks whose stored vectors formed the query;
+	// they are excluded from Hits.
+	SeedChunkIDs []uint64
+	IndexUsed    string
+	Hits         []SearchHit
+}
+
+// RerankInfo describes a second-stage rerank pass.
+type RerankInfo struct {
+	Reranker   string
+	Candidates int
+	LatencyMS  int64
+	// Error is set when the reranker failed and first-stage order was kept.
+	Error string
 }
 
 type ChunkMetadata struct {
@@ -112,6 +217,9 @@ type ChunkTask struct {
 	Text      string
 	IndexKind string
 	Metadata  ChunkMetadata
+	// LexicalScore is the relevance of a lexical search result, higher is
+	// better; zero on chunks returned for any other purpose.
+	LexicalScore float64
 }
 
 // NewChunkTask returns a task with the supplied components. If the provided
@@ -149,12 +257,67 @@ type Citation struct {
 	Span    Span
 }
 
+// ConversationTurn is one exchange of a multi-turn ask conversation.
+// Standalone is the self-contained form of Question used for retrieval; it
+// equals Question for the first turn.
+type ConversationTurn struct {
+	Question   string
+	Standalone string
+	Answer     string
+}
+
 type AskResult struct {
 	Question         string
 	Answer           string
 	Citations        []Citation
 	Hits             []SearchHit
 	IndexingComplete bool
+
+	// Context records the prompt assembled for the generator. It is nil when
+	// no generation was attempted (no hits or no generator configured).
+	Context *AskContext
+	// Rerank is set when the supporting search was reranked.
+	Rerank *RerankInfo
+	// Verification reports how well the cited excerpts support each sentence
+	// of a generated answer. It is nil when no answer was generated.
+	Verification *AnswerVerification
+}
+
+// ContextChunk is the full text of a chunk together with its position in the
+// owning representation. Retrieval uses it to expand hits with neighboring
+// chunks when assembling generation context.
+type ContextChunk struct {
+	ChunkID uint64
+	RepID   int64
+	Ordinal int
+	RelPath string
+	Span    Span
+	Text    string
+}
+
+// ContextBlock is one contiguous excerpt included in an ask prompt. Adjacent
+// hits from the same representation are merged into a single block.
+type ContextBlock struct {
+	RelPath  string
+	ChunkIDs []uint64
+	Span     Span
+	Text     string
+	Tokens   int
+}
+
+// AskContext describes exactly what the generator saw for an ask request so
+// operators can audit answers.
+type AskContext struct {
+	ChatModel    string
+	TokenBudget  int
+	TokensUsed   int
+	SystemPrompt string
+	UserPrompt   string
+	Blocks       []ContextBlock
+	// Dropped counts hits that did not fit within TokenBudget; Truncated
+	// reports that the first block was cut to fit.
+	Dropped   int
+	Truncated bool
 }
 
 type CorpusStats struct {
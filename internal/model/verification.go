@@ -0,0 +1,107 @@
+package model
+
+import "strings"
+
+// Support statuses assigned to answer sentences by verification.
+const (
+	SupportSupported   = "supported"
+	SupportPartial     = "partial"
+	SupportUnsupported = "unsupported"
+)
+
+// Strict modes accepted by AnswerVerification.Apply.
+const (
+	StrictOff   = "off"
+	StrictFlag  = "flag"
+	StrictStrip = "strip"
+)
+
+// UnsupportedMarker is appended to sentences flagged in StrictFlag mode.
+const UnsupportedMarker = "[unsupported]"
+
+// SentenceSupport is the verification outcome for one answer sentence.
+// Start and End are byte offsets of Text within the answer.
+type SentenceSupport struct {
+	Text  string
+	Start int
+	End   int
+	// Sources lists the rel_paths the sentence cites inline. Sentences
+	// without citations are checked against all retrieved excerpts.
+	Sources []string
+	Status  string
+	// Score is the fraction of the sentence's content terms found in its
+	// evidence.
+	Score float64
+	// Method names the check that decided Status: "lexical" or "generator".
+	Method string
+	// Removed reports that strict mode stripped the sentence from the
+	// answer; Start and End then mark where it used to be.
+	Removed bool
+}
+
+// AnswerVerification summarizes how well an answer is grounded in the
+// excerpts it was generated from.
+type AnswerVerification struct {
+	Sentences []SentenceSupport
+	// GroundingScore is in [0,1]: supported sentences count fully, partially
+	// supported ones count half.
+	GroundingScore float64
+	// Strict is the strict mode applied to the answer, if any.
+	Strict string
+}
+
+// Unsupported returns how many sentences were judged unsupported.
+func (v AnswerVerification) Unsupported() int {
+	n := 0
+	for _, sentence := range v.Sentences {
+		if sentence.Status == SupportUnsupported {
+			n++
+		}
+	}
+	return n
+}
+
+// Apply rewrites answer according to mode: StrictFlag appends
+// UnsupportedMarker to unsupported sentences and StrictStrip removes them.
+// It returns the new answer and a copy of v whose offsets refer to it. Any
+// other mode returns the inputs unchanged.
+func (v AnswerVerification) Apply(answer, mode string) (string, AnswerVerification) {
+	if mode != StrictFlag && mode != StrictStrip {
+		return answer, v
+	}
+	out := v
+	out.Strict = mode
+	out.Sentences = make([]SentenceSupport, len(v.Sentences))
+
+	var b strings.Builder
+	last := 0
+	for i, sentence := range v.Sentences {
+		if sentence.Start < last || sentence.End > len(answer) || sentence.Start > sentence.End {
+			// offsets do not describe this answer; leave it untouched.
+			return answer, v
+		}
+		b.WriteString(answer[last:sentence.Start])
+		moved := sentence
+		moved.Start = b.Len()
+		last = sentence.End
+		if sentence.Status == SupportUnsupported && mode == StrictStrip {
+			moved.Removed = true
+			moved.End = moved.Start
+			// drop the spacing that separated the sentence from the next one.
+			for last < len(answer) && (answer[last] == ' ' || answer[last] == '\t') {
+				last++
+			}
+			out.Sentences[i] = moved
+			continue
+		}
+		b.WriteString(answer[sentence.Start:sentence.End])
+		moved.End = b.Len()
+		if sentence.Status == SupportUnsupported {
+			b.WriteString(" ")
+			b.WriteString(UnsupportedMarker)
+		}
+		out.Sentences[i] = moved
+	}
+	b.WriteString(answer[last:])
+	return strings.TrimRight(b.String(), " \t\n"), out
+}
@@ -0,0 +1,484 @@
+// Package oauth lets the MCP server act as an OAuth 2.1 protected
+// resource. It validates JWT access tokens against a JWKS read from a file
+// or URL and maps their scopes onto the tool and path scopes of a
+// tokens.Token, so the server enforces them like a scoped API token.
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/tokens"
+)
+
+const (
+	// jwksCacheTTL bounds how long keys fetched from a URL are used before
+	// they are fetched again.
+	jwksCacheTTL = 10 * time.Minute
+	// jwksMinRefresh bounds how often an unknown kid may trigger a fetch.
+	jwksMinRefresh = 30 * time.Second
+	maxJWKSBytes   = 1 << 20
+	maxTokenBytes  = 16 << 10
+)
+
+var (
+	// ErrInvalidToken covers every reason a token is rejected: bad format or
+	// signature, wrong issuer or audience, expiry.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrInsufficientScope means the token is valid but grants no tools.
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// Validator validates JWT access tokens for one resource server.
+type Validator struct {
+	cfg    config.OAuthConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        []verificationKey
+	loadedAt    time.Time
+	fileModTime time.Time
+	lastFetch   time.Time
+	// fetching is closed when the JWKS fetch in flight finishes; fetchErr
+	// is the outcome of the last fetch.
+	fetching chan struct{}
+	fetchErr error
+}
+
+// NewValidator returns a Validator for cfg, which must have passed
+// config.ValidateOAuth. Keys are loaded on first use.
+func NewValidator(cfg config.OAuthConfig, client *http.Client) *Validator {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Validator{cfg: cfg, client: client}
+}
+
+// ScopePrefix returns the prefix of the scope values the validator maps.
+func (v *Validator) ScopePrefix() string {
+	return v.cfg.ScopePrefix
+}
+
+// Authenticate validates raw and returns the access it grants.
+func (v *Validator) Authenticate(ctx context.Context, raw string, now time.Time) (tokens.Token, error) {
+	claims, err := v.verify(ctx, raw, now)
+	if err != nil {
+		return tokens.Token{}, err
+	}
+	token, err := v.scopesToToken(claims)
+	if err != nil {
+		return tokens.Token{}, err
+	}
+	return token, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+	ClientID  string          `json:"client_id"`
+}
+
+func (v *Validator) verify(ctx context.Context, raw string, now time.Time) (jwtClaims, error) {
+	if v.cfg.Issuer == "" || v.cfg.Audience == "" {
+		// fail closed when the settings were never validated.
+		return jwtClaims{}, errors.New("oauth issuer and audience are not configured")
+	}
+	if len(raw) > maxTokenBytes {
+		return jwtClaims{}, fmt.Errorf("%w: token too large", ErrInvalidToken)
+	}
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, fmt.Errorf("%w: malformed jwt", ErrInvalidToken)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return jwtClaims{}, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+	key, err := v.keyFor(ctx, header, now)
+	if err != nil {
+		return jwtClaims{}, err
+	}
+	if err := verifySignature(header.Alg, key.key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return jwtClaims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return jwtClaims{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if claims.Issuer != v.cfg.Issuer {
+		return jwtClaims{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if !audienceContains(claims.Audience, v.cfg.Audience) {
+		return jwtClaims{}, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	exp, ok := numericDate(claims.ExpiresAt)
+	if !ok {
+		return jwtClaims{}, fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	}
+	if !now.Before(exp.Add(v.cfg.ClockSkew)) {
+		return jwtClaims{}, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if nbf, ok := numericDate(claims.NotBefore); ok && now.Add(v.cfg.ClockSkew).Before(nbf) {
+		return jwtClaims{}, fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// scopesToToken maps the token's scopes onto a tokens.Token. With the
+// default prefix, "dir2mcp:tools:search" grants a tool ("dir2mcp:tools:*"
+// grants all of them), "dir2mcp:paths:docs/" a path prefix and
+// "dir2mcp:globs:**/*.md" a path glob.
+func (v *Validator) scopesToToken(claims jwtClaims) (tokens.Token, error) {
+	subject := claims.Subject
+	if subject == "" {
+		subject = claims.ClientID
+	}
+	exp, _ := numericDate(claims.ExpiresAt)
+	token := tokens.Token{
+		ID:        "oauth:" + subject,
+		Name:      subject,
+		ExpiresAt: exp,
+	}
+	allTools := false
+	for _, scope := range scopeValues(claims) {
+		rest, ok := strings.CutPrefix(scope, v.cfg.ScopePrefix)
+		if !ok {
+			continue
+		}
+		kind, value, _ := strings.Cut(rest, ":")
+		switch {
+		case kind == "tools" && (value == "" || value == "*"):
+			allTools = true
+		case kind == "tools":
+			if !strings.Contains(value, ".") {
+				value = "dir2mcp." + value
+			}
+			token.Tools = append(token.Tools, value)
+		case kind == "paths" && value != "":
+			token.PathPrefixes = append(token.PathPrefixes, strings.TrimPrefix(value, "/"))
+		case kind == "globs" && value != "":
+			token.PathGlobs = append(token.PathGlobs, value)
+		}
+	}
+	if allTools {
+		token.Tools = nil
+	} else if len(token.Tools) == 0 {
+		return tokens.Token{}, ErrInsufficientScope
+	}
+	return token, nil
+}
+
+func scopeValues(claims jwtClaims) []string {
+	values := strings.Fields(claims.Scope)
+	if len(claims.Scp) == 0 {
+		return values
+	}
+	var list []string
+	if err := json.Unmarshal(claims.Scp, &list); err == nil {
+		return append(values, list...)
+	}
+	var single string
+	if err := json.Unmarshal(claims.Scp, &single); err == nil {
+		return append(values, strings.Fields(single)...)
+	}
+	return values
+}
+
+func audienceContains(raw json.RawMessage, audience string) bool {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single == audience
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for _, value := range list {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func numericDate(value *json.Number) (time.Time, bool) {
+	if value == nil {
+		return time.Time{}, false
+	}
+	seconds, err := value.Float64()
+	if err != nil || seconds < 0 || seconds > 1<<40 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("invalid base64url")
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
+
+// keyFor returns the key that signed a token with header, fetching the
+// JWKS again when the kid is unknown.
+func (v *Validator) keyFor(ctx context.Context, header jwtHeader, now time.Time) (verificationKey, error) {
+	if _, ok := signatureHashes[header.Alg]; !ok {
+		return verificationKey{}, fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+	if err := v.loadKeys(ctx, now, false); err != nil {
+		return verificationKey{}, err
+	}
+	v.mu.Lock()
+	key, ok := matchKey(v.keys, header)
+	refresh := !ok && v.cfg.JWKSURL != "" && now.Sub(v.lastFetch) >= jwksMinRefresh
+	v.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if refresh {
+		if err := v.loadKeys(ctx, now, true); err != nil {
+			return verificationKey{}, err
+		}
+		v.mu.Lock()
+		key, ok = matchKey(v.keys, header)
+		v.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return verificationKey{}, fmt.Errorf("%w: no key for kid %q", ErrInvalidToken, header.Kid)
+}
+
+func matchKey(keys []verificationKey, header jwtHeader) (verificationKey, bool) {
+	for _, key := range keys {
+		if header.Kid != "" && key.kid != header.Kid {
+			continue
+		}
+		if key.alg != "" && key.alg != header.Alg {
+			continue
+		}
+		if !keyFitsAlg(key.key, header.Alg) {
+			continue
+		}
+		return key, true
+	}
+	return verificationKey{}, false
+}
+
+// loadKeys reads the JWKS if it has not been read yet, has changed on
+// disk, has outlived jwksCacheTTL, or force is set.
+func (v *Validator) loadKeys(ctx context.Context, now time.Time, force bool) error {
+	if v.cfg.JWKSFile != "" {
+		return v.loadKeysFromFile(now, force)
+	}
+	return v.loadKeysFromURL(ctx, now, force)
+}
+
+func (v *Validator) loadKeysFromFile(now time.Time, force bool) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	info, err := os.Stat(v.cfg.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("read jwks: %w", err)
+	}
+	if !force && v.keys != nil && info.ModTime().Equal(v.fileModTime) {
+		return nil
+	}
+	raw, err := os.ReadFile(v.cfg.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("read jwks: %w", err)
+	}
+	v.fileModTime = info.ModTime()
+	return v.setKeysLocked(raw, now)
+}
+
+// loadKeysFromURL fetches the JWKS without holding v.mu, so a slow issuer
+// does not hold up tokens signed with keys already known. Concurrent
+// callers share one fetch.
+func (v *Validator) loadKeysFromURL(ctx context.Context, now time.Time, force bool) error {
+	v.mu.Lock()
+	if !force && v.keys != nil && now.Sub(v.loadedAt) < jwksCacheTTL {
+		v.mu.Unlock()
+		return nil
+	}
+	if wait := v.fetching; wait != nil {
+		v.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if v.keys == nil {
+			return v.fetchErr
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	v.fetching = done
+	v.lastFetch = now
+	v.mu.Unlock()
+
+	// the fetch is shared, so one caller going away must not cancel it;
+	// the HTTP client's timeout still bounds it.
+	raw, err := v.fetchJWKS(context.WithoutCancel(ctx))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fetching = nil
+	defer close(done)
+	if err == nil {
+		err = v.setKeysLocked(raw, now)
+	}
+	v.fetchErr = err
+	// keep serving the keys we have rather than failing every request.
+	if err != nil && v.keys != nil {
+		return nil
+	}
+	return err
+}
+
+// setKeysLocked replaces the keys with the parsed JWKS. A JWKS that does
+// not parse keeps the current keys if there are any. Callers hold v.mu.
+func (v *Validator) setKeysLocked(raw []byte, now time.Time) error {
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		if v.keys != nil {
+			return nil
+		}
+		return err
+	}
+	v.keys = keys
+	v.loadedAt = now
+	return nil
+}
+
+func (v *Validator) fetchJWKS(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	return raw, nil
+}
+
+// signatureHashes lists the supported JWS algorithms. EdDSA signs the
+// message itself, so it has no hash.
+var signatureHashes = map[string]func() hash.Hash{
+	"RS256": sha256.New,
+	"RS384": sha512.New384,
+	"RS512": sha512.New,
+	"PS256": sha256.New,
+	"PS384": sha512.New384,
+	"PS512": sha512.New,
+	"ES256": sha256.New,
+	"ES384": sha512.New384,
+	"ES512": sha512.New,
+	"EdDSA": nil,
+}
+
+var cryptoHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+func keyFitsAlg(key crypto.PublicKey, alg string) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return (alg == "ES256" && key.Curve.Params().BitSize == 256) ||
+			(alg == "ES384" && key.Curve.Params().BitSize == 384) ||
+			(alg == "ES512" && key.Curve.Params().BitSize == 521)
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	if !keyFitsAlg(key, alg) {
+		return fmt.Errorf("key does not match alg %s", alg)
+	}
+	newHash := signatureHashes[alg]
+	if newHash == nil {
+		if !ed25519.Verify(key.(ed25519.PublicKey), signed, signature) {
+			return errors.New("bad signature")
+		}
+		return nil
+	}
+	h := newHash()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		hashID := cryptoHashes[alg[2:]]
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(key, hashID, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.VerifyPKCS1v15(key, hashID, digest, signature)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("bad signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("bad signature")
+		}
+		return nil
+	}
+	return errors.New("unsupported key")
+}
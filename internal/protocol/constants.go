@@ -10,6 +10,11 @@ const (
 	ToolNameTranscribe       = "dir2mcp.transcribe"
 	ToolNameAnnotate         = "dir2mcp.annotate"
 	ToolNameTranscribeAndAsk = "dir2mcp.transcribe_and_ask"
+	ToolNameFindSimilar      = "dir2mcp.find_similar"
+	ToolNameGrep             = "dir2mcp.grep"
+	ToolNameSymbols          = "dir2mcp.symbols"
+	ToolNameDefinition       = "dir2mcp.definition"
+	ToolNameReferences       = "dir2mcp.references"
 )
 
 const (
@@ -19,6 +24,7 @@ const (
 	ErrorCodeFileNotFound      = "FILE_NOT_FOUND"
 	ErrorCodePermissionDenied  = "PERMISSION_DENIED"
 	ErrorCodeRateLimitExceeded = "RATE_LIMIT_EXCEEDED"
+	ErrorCodeRequestCancelled  = "REQUEST_CANCELLED"
 	// ErrorCodeRateLimited is kept as a compatibility alias.
 	ErrorCodeRateLimited = ErrorCodeRateLimitExceeded
 )
@@ -39,4 +45,19 @@ const (
 	RPCMethodNotificationsInitialized = "notifications/initialized"
 	RPCMethodToolsList                = "tools/list"
 	RPCMethodToolsCall                = "tools/call"
+	RPCMethodProgress                 = "notifications/progress"
+	RPCMethodCancelled                = "notifications/cancelled"
+	RPCMethodResourcesList            = "resources/list"
+	RPCMethodResourcesTemplatesList   = "resources/templates/list"
+	RPCMethodResourcesRead            = "resources/read"
+	RPCMethodResourcesSubscribe       = "resources/subscribe"
+	RPCMethodResourcesUnsubscribe     = "resources/unsubscribe"
+	RPCMethodResourcesUpdated         = "notifications/resources/updated"
+	RPCMethodResourcesListChanged     = "notifications/resources/list_changed"
+	RPCMethodPromptsList              = "prompts/list"
+	RPCMethodPromptsGet               = "prompts/get"
+
+	// ResourceURIPrefix prefixes the URI of every file resource; the rest is
+	// the percent-encoded rel_path and an optional span fragment.
+	ResourceURIPrefix = "dir2mcp://file/"
 )
@@ -0,0 +1,80 @@
+package retrieval
+
+import (
+	"context"
+	"strings"
+
+	"dir2mcp/internal/model"
+)
+
+// maxRewriteAnswerRunes trims earlier answers shown to the query rewriter;
+// the rewriter only needs enough of each answer to resolve references.
+const maxRewriteAnswerRunes = 800
+
+const followUpRewriteSystemPrompt = `You rewrite follow-up questions for a document search engine.
+Given the conversation so far and a follow-up question, write one standalone question that can be understood without the conversation.
+Resolve pronouns and references such as "that" or "it" using the conversation. If the follow-up is already standalone, return it unchanged.
+Respond with only the rewritten question.`
+
+// RewriteQuestion turns a follow-up question into a standalone query using
+// the earlier turns of its conversation. Without history or a generator the
+// question is returned unchanged. A generator failure returns the error
+// together with the original question so callers can continue with it.
+func (s *Service) RewriteQuestion(ctx context.Context, question string, history []model.ConversationTurn) (string, error) {
+	question = strings.TrimSpace(question)
+	if len(history) == 0 || s.gen == nil || question == "" {
+		return question, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Conversation:\n")
+	for _, turn := range history {
+		asked := strings.TrimSpace(turn.Standalone)
+		if asked == "" {
+			asked = strings.TrimSpace(turn.Question)
+		}
+		b.WriteString("Q: ")
+		b.WriteString(asked)
+		b.WriteString("\n")
+		if answer := truncateSnippet(turn.Answer, maxRewriteAnswerRunes); answer != "" {
+			b.WriteString("A: ")
+			b.WriteString(answer)
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\nFollow-up question:\n")
+	b.WriteString(question)
+
+	var (
+		raw string
+		err error
+	)
+	if sysGen, ok := s.gen.(model.SystemPromptGenerator); ok {
+		raw, err = sysGen.GenerateWithSystem(ctx, followUpRewriteSystemPrompt, b.String())
+	} else {
+		raw, err = s.gen.Generate(ctx, followUpRewriteSystemPrompt+"\n\n"+b.String())
+	}
+	if err != nil {
+		return question, err
+	}
+	if rewritten := cleanRewrittenQuestion(raw); rewritten != "" {
+		return rewritten, nil
+	}
+	return question, nil
+}
+
+// cleanRewrittenQuestion keeps the first non-empty line of a rewriter reply
+// and strips labels and quotes models tend to add.
+func cleanRewrittenQuestion(raw string) string {
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if idx := strings.Index(line, ":"); idx >= 0 && strings.EqualFold(strings.TrimSpace(line[:idx]), "standalone question") {
+			line = strings.TrimSpace(line[idx+1:])
+		}
+		return strings.TrimSpace(strings.Trim(line, "\"'`"))
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package retrieval
+
+import (
+	"math"
+
+	"dir2mcp/internal/model"
+)
+
+// vectorLookupIndex is implemented by indices that can return the vector
+// stored for a label, so diversification can compare candidates by their
+// embeddings instead of their text.
+type vectorLookupIndex interface {
+	Vector(label uint64) ([]float32, bool)
+}
+
+// diversificationEnabled reports whether query asks for MMR or a per-file
+// cap, both of which need a candidate pool larger than k.
+func diversificationEnabled(query model.SearchQuery) bool {
+	return mmrActive(query.MMRLambda) || query.MaxHitsPerFile > 0
+}
+
+func mmrActive(lambda float64) bool {
+	return lambda > 0 && lambda < 1
+}
+
+// diversifyHits picks up to k hits from candidates, which must be in
+// relevance order. With MMR active each pick maximises
+// lambda*relevance - (1-lambda)*max similarity to the hits already picked;
+// otherwise candidates are taken in order. Files that already contributed
+// maxPerFile hits are skipped when maxPerFile > 0.
+func (s *Service) diversifyHits(candidates []model.SearchHit, k int, lambda float64, maxPerFile int) []model.SearchHit {
+	if k <= 0 || len(candidates) == 0 {
+		return []model.SearchHit{}
+	}
+	perFile := make(map[string]int)
+	fileFull := func(hit model.SearchHit) bool {
+		return maxPerFile > 0 && perFile[hit.RelPath] >= maxPerFile
+	}
+	out := make([]model.SearchHit, 0, k)
+
+	if !mmrActive(lambda) {
+		for _, hit := range candidates {
+			if fileFull(hit) {
+				continue
+			}
+			perFile[hit.RelPath]++
+			out = append(out, hit)
+			if len(out) >= k {
+				break
+			}
+		}
+		return out
+	}
+
+	relevance := normalizedRelevance(candidates)
+	features := s.diversityFeatures(candidates)
+	maxSim := make([]float64, len(candidates))
+	picked := make([]bool, len(candidates))
+	for len(out) < k {
+		best := -1
+		bestScore := math.Inf(-1)
+		for i, hit := range candidates {
+			if picked[i] || fileFull(hit) {
+				continue
+			}
+			score := lambda*relevance[i] - (1-lambda)*maxSim[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		picked[best] = true
+		perFile[candidates[best].RelPath]++
+		out = append(out, candidates[best])
+		for i := range candidates {
+			if picked[i] {
+				continue
+			}
+			if sim := features[i].similarity(features[best]); sim > maxSim[i] {
+				maxSim[i] = sim
+			}
+		}
+	}
+	return out
+}
+
+// normalizedRelevance maps candidate scores onto [0,1] so they are on the
+// same scale as similarities. Rerank scores take precedence over first-stage
+// scores when present.
+func normalizedRelevance(hits []model.SearchHit) []float64 {
+	out := make([]float64, len(hits))
+	minScore, maxScore := math.Inf(1), math.Inf(-1)
+	for i, hit := range hits {
+		score := hit.Score
+		if hit.Reranked {
+			score = hit.RerankScore
+		}
+		out[i] = score
+		minScore = math.Min(minScore, score)
+		maxScore = math.Max(maxScore, score)
+	}
+	for i := range out {
+		if maxScore == minScore {
+			out[i] = 1
+			continue
+		}
+		out[i] = (out[i] - minScore) / (maxScore - minScore)
+	}
+	return out
+}
+
+// diversityFeature holds what is needed to compare two candidates: the
+// stored embedding when one is available, otherwise the snippet's terms.
+type diversityFeature struct {
+	index  model.Index
+	vector []float32
+	terms  map[string]struct{}
+}
+
+func (s *Service) diversityFeatures(hits []model.SearchHit) []diversityFeature {
+	s.metaMu.RLock()
+	indices := []model.Index{s.textIndex}
+	if s.codeIndex != s.textIndex {
+		indices = append(indices, s.codeIndex)
+	}
+	s.metaMu.RUnlock()
+
+	out := make([]diversityFeature, len(hits))
+	for i, hit := range hits {
+		for _, idx := range indices {
+			lookup, ok := idx.(vectorLookupIndex)
+			if !ok {
+				continue
+			}
+			if vec, found := lookup.Vector(hit.ChunkID); found {
+				out[i].index = idx
+				out[i].vector = vec
+				break
+			}
+		}
+		terms := make(map[string]struct{})
+		for _, tok := range tokenizeLexical(hit.Snippet) {
+			terms[tok] = struct{}{}
+		}
+		out[i].terms = terms
+	}
+	return out
+}
+
+// similarity is the cosine of the stored embeddings when both candidates
+// come from the same index, and the Jaccard overlap of their terms otherwise.
+func (f diversityFeature) similarity(other diversityFeature) float64 {
+	if f.index != nil && f.index == other.index && len(f.vector) == len(other.vector) {
+		return cosine(f.vector, other.vector)
+	}
+	if len(f.terms) == 0 || len(other.terms) == 0 {
+		return 0
+	}
+	shared := 0
+	for term := range f.terms {
+		if _, ok := other.terms[term]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(f.terms)+len(other.terms)-shared)
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, magA, magB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		magA += float64(a[i]) * float64(a[i])
+		magB += float64(b[i]) * float64(b[i])
+	}
+	if magA == 0 || magB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(magA*magB)
+}
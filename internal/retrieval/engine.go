@@ -96,6 +96,11 @@ func NewEngine(ctx context.Context, stateDir, rootDir string, cfg *config.Config
 	svc.SetRootDir(effective.RootDir)
 	svc.SetStateDir(effective.StateDir)
 	svc.SetProtocolVersion(effective.ProtocolVersion)
+	svc.SetChatModel(client.DefaultChatModel)
+	svc.SetContextTokenBudgets(effective.RAGContextTokens)
+	svc.SetNeighborChunks(effective.RAGNeighborChunks)
+	svc.SetGeneratorVerification(effective.RAGVerifyWithGenerator)
+	svc.SetReranker(RerankerLLM, NewLLMReranker(client))
 
 	if source, ok := interface{}(metadataStore).(embeddedChunkMetadataSource); ok {
 		preloadCtx, cancel := context.WithTimeout(ctx, defaultEnginePreloadTimeout)
@@ -195,6 +200,15 @@ func mergeEngineConfig(base config.Config, override *config.Config) config.Confi
 	if v := strings.TrimSpace(override.ChatModel); v != "" {
 		merged.ChatModel = v
 	}
+	if len(override.RAGContextTokens) > 0 {
+		merged.RAGContextTokens = override.RAGContextTokens
+	}
+	if override.RAGNeighborChunks > 0 {
+		merged.RAGNeighborChunks = override.RAGNeighborChunks
+	}
+	if override.RAGVerifyWithGenerator {
+		merged.RAGVerifyWithGenerator = true
+	}
 
 	return merged
 }
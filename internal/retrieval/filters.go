@@ -0,0 +1,168 @@
+package retrieval
+
+import (
+	"context"
+	"strings"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+// chunkFilterStore is implemented by stores that can resolve a metadata
+// filter to the set of matching chunk IDs.
+type chunkFilterStore interface {
+	ChunkIDsMatching(ctx context.Context, filter model.MetadataFilter) ([]uint64, error)
+}
+
+// filteredLexicalSearchStore is implemented by stores that apply the path,
+// doc type and metadata filters of a query while selecting lexical
+// candidates.
+type filteredLexicalSearchStore interface {
+	SearchChunksLexicalFiltered(ctx context.Context, terms []string, limit int, query model.SearchQuery) ([]model.ChunkTask, error)
+}
+
+// filteredSearchIndex is implemented by indices that can skip labels before
+// scoring them.
+type filteredSearchIndex interface {
+	SearchFiltered(vector []float32, k int, allow func(label uint64) bool) ([]uint64, []float32, error)
+}
+
+// searchFilters carries the filters of one search. Metadata filters are
+// resolved once per search: when the store supports it, labels holds the
+// chunks that satisfy them and indices only score those chunks. Otherwise
+// each hit's document is looked up and checked individually.
+type searchFilters struct {
+	model.SearchQuery
+
+	labels map[uint64]struct{}
+	// docs caches document lookups for stores that cannot pre-filter; a nil
+	// entry records a failed lookup.
+	docs map[string]*model.Document
+}
+
+// newSearchFilters prepares the filters of query, resolving metadata filters
+// against the store when possible.
+func (s *Service) newSearchFilters(ctx context.Context, query model.SearchQuery) (*searchFilters, error) {
+	f := &searchFilters{SearchQuery: query}
+	if query.MetadataFilter.IsZero() {
+		return f, nil
+	}
+	if source, ok := s.store.(chunkFilterStore); ok {
+		ids, err := source.ChunkIDsMatching(ctx, query.MetadataFilter)
+		if err != nil {
+			return nil, err
+		}
+		f.labels = make(map[uint64]struct{}, len(ids))
+		for _, id := range ids {
+			f.labels[id] = struct{}{}
+		}
+		return f, nil
+	}
+	f.docs = make(map[string]*model.Document)
+	return f, nil
+}
+
+// prefiltered reports whether the metadata filters were resolved to a label
+// set before scoring.
+func (f *searchFilters) prefiltered() bool {
+	return f.labels != nil
+}
+
+// allow reports whether label satisfies the pre-resolved metadata filters.
+func (f *searchFilters) allow(label uint64) bool {
+	if f.labels == nil {
+		return true
+	}
+	_, ok := f.labels[label]
+	return ok
+}
+
+// matchSearchFilters reports whether hit satisfies every filter of f.
+func (s *Service) matchSearchFilters(ctx context.Context, hit model.SearchHit, f *searchFilters) bool {
+	if !matchFilters(hit, f.SearchQuery) {
+		return false
+	}
+	if f.MetadataFilter.IsZero() {
+		return true
+	}
+	if f.labels != nil {
+		return f.allow(hit.ChunkID)
+	}
+	return s.matchMetadataFallback(ctx, hit, f)
+}
+
+// matchMetadataFallback checks metadata filters hit by hit for stores that
+// cannot resolve them up front. Hits whose document cannot be found do not
+// match document-level filters.
+func (s *Service) matchMetadataFallback(ctx context.Context, hit model.SearchHit, f *searchFilters) bool {
+	filter := f.MetadataFilter
+	if len(filter.RepTypes) > 0 && !containsFold(filter.RepTypes, hit.RepType) {
+		return false
+	}
+	if len(filter.Languages) > 0 {
+		language := ingest.CodeLanguage(hit.RelPath)
+		matched := false
+		for _, want := range filter.Languages {
+			if language != "" && ingest.NormalizeCodeLanguage(want) == language {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if filter.ModifiedAfter == 0 && filter.ModifiedBefore == 0 && filter.MinSizeBytes == 0 &&
+		filter.MaxSizeBytes == 0 && len(filter.Statuses) == 0 && len(filter.SourceTypes) == 0 {
+		return true
+	}
+
+	doc, cached := f.docs[hit.RelPath]
+	if !cached {
+		if s.store != nil {
+			if found, err := s.store.GetDocumentByPath(ctx, hit.RelPath); err == nil {
+				doc = &found
+			}
+		}
+		f.docs[hit.RelPath] = doc
+	}
+	if doc == nil {
+		return false
+	}
+	if filter.ModifiedAfter > 0 && doc.MTimeUnix < filter.ModifiedAfter {
+		return false
+	}
+	if filter.ModifiedBefore > 0 && doc.MTimeUnix >= filter.ModifiedBefore {
+		return false
+	}
+	if filter.MinSizeBytes > 0 && doc.SizeBytes < filter.MinSizeBytes {
+		return false
+	}
+	if filter.MaxSizeBytes > 0 && doc.SizeBytes > filter.MaxSizeBytes {
+		return false
+	}
+	if len(filter.Statuses) > 0 && !containsFold(filter.Statuses, defaultIfBlank(doc.Status, "ok")) {
+		return false
+	}
+	if len(filter.SourceTypes) > 0 && !containsFold(filter.SourceTypes, defaultIfBlank(doc.SourceType, "filesystem")) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, target string) bool {
+	target = strings.TrimSpace(target)
+	for _, value := range values {
+		if strings.EqualFold(strings.TrimSpace(value), target) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultIfBlank(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
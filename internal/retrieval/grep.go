@@ -0,0 +1,182 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+const (
+	// DefaultGrepMaxMatches applies when a grep query leaves MaxMatches
+	// unset.
+	DefaultGrepMaxMatches = 100
+	// grepMaxFileBytes bounds how much of each file grep reads.
+	grepMaxFileBytes = 4 << 20
+	// grepMaxLineRunes truncates reported lines so minified files do not
+	// flood the output.
+	grepMaxLineRunes = 500
+	// grepCancelCheckLines is how often, in lines, a scan checks its
+	// context.
+	grepCancelCheckLines = 4096
+)
+
+// representationDocumentStore is implemented by stores that can list the
+// documents holding a given representation type.
+type representationDocumentStore interface {
+	ListDocumentsWithRepresentation(ctx context.Context, repType, prefix, glob string) ([]model.Document, error)
+}
+
+// Grep runs query over the raw text of indexed files and reports every
+// matching line with query.ContextLines lines of context. Files are read from
+// disk under the same root, exclude and secret checks as OpenFile; files that
+// contain secrets are skipped entirely, and archive members, which have no
+// file of their own, are not searched. emit, when non-nil, receives each
+// match as soon as it is found.
+//
+// When ctx's deadline expires the matches found so far are returned with
+// TimedOut set; other context errors abort the search.
+func (s *Service) Grep(ctx context.Context, query model.GrepQuery, emit func(model.GrepMatch)) (model.GrepResult, error) {
+	re, err := query.Regexp()
+	if err != nil {
+		return model.GrepResult{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return s.grep(ctx, re, query, nil, nil, emit)
+}
+
+// grep scans the raw text of the documents selected by query with re.
+// acceptPath, when non-nil, drops documents before they are read; keep,
+// when non-nil, drops matches before they count towards MaxMatches.
+func (s *Service) grep(ctx context.Context, re *regexp.Regexp, query model.GrepQuery, acceptPath func(relPath string) bool, keep func(model.GrepMatch) bool, emit func(model.GrepMatch)) (model.GrepResult, error) {
+	maxMatches := query.MaxMatches
+	if maxMatches <= 0 {
+		maxMatches = DefaultGrepMaxMatches
+	}
+	contextLines := query.ContextLines
+	if contextLines < 0 {
+		contextLines = 0
+	}
+
+	s.metaMu.RLock()
+	st := s.store
+	rootDir := s.rootDir
+	pathExcludes := append([]string(nil), s.pathExcludes...)
+	secretPatterns := append([]*regexp.Regexp(nil), s.secretPatterns...)
+	s.metaMu.RUnlock()
+
+	lister, ok := st.(representationDocumentStore)
+	if !ok {
+		return model.GrepResult{}, model.ErrNotImplemented
+	}
+	docs, err := lister.ListDocumentsWithRepresentation(ctx, ingest.RepTypeRawText, query.PathPrefix, query.FileGlob)
+	if err != nil {
+		return model.GrepResult{}, err
+	}
+
+	result := model.GrepResult{Matches: []model.GrepMatch{}}
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return grepContextResult(result, err)
+		}
+		if doc.SourceType == "archive_member" {
+			continue
+		}
+		if acceptPath != nil && !acceptPath(doc.RelPath) {
+			continue
+		}
+
+		content, ok := s.readGrepFile(rootDir, doc.RelPath, pathExcludes, secretPatterns)
+		if !ok {
+			result.FilesSkipped++
+			continue
+		}
+		result.FilesScanned++
+
+		lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		for i, line := range lines {
+			if i > 0 && i%grepCancelCheckLines == 0 {
+				if err := ctx.Err(); err != nil {
+					return grepContextResult(result, err)
+				}
+			}
+			if !re.MatchString(line) {
+				continue
+			}
+			match := model.GrepMatch{
+				RelPath: doc.RelPath,
+				Span:    model.Span{Kind: "lines", StartLine: i + 1, EndLine: i + 1},
+				Line:    grepLine(line),
+				Before:  grepContext(lines, i-contextLines, i),
+				After:   grepContext(lines, i+1, i+1+contextLines),
+			}
+			if keep != nil && !keep(match) {
+				continue
+			}
+			result.Matches = append(result.Matches, match)
+			if emit != nil {
+				emit(match)
+			}
+			if len(result.Matches) >= maxMatches {
+				result.Truncated = true
+				return result, nil
+			}
+		}
+	}
+	return result, nil
+}
+
+// readGrepFile returns the content of relPath when it may be searched.
+func (s *Service) readGrepFile(rootDir, relPath string, pathExcludes []string, secretPatterns []*regexp.Regexp) (string, bool) {
+	target, err := s.resolveRootPath(rootDir, relPath, pathExcludes)
+	if err != nil {
+		return "", false
+	}
+	resolvedAbs, err := s.resolveRegularFile(target, pathExcludes)
+	if err != nil {
+		return "", false
+	}
+	raw, _, err := readFileBounded(resolvedAbs, grepMaxFileBytes)
+	if err != nil {
+		return "", false
+	}
+	content := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	for _, re := range secretPatterns {
+		if re != nil && re.MatchString(content) {
+			return "", false
+		}
+	}
+	return content, true
+}
+
+// grepContextResult ends a search interrupted by ctx: an expired deadline
+// keeps the partial result, any other cancellation is an error.
+func grepContextResult(result model.GrepResult, err error) (model.GrepResult, error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		result.TimedOut = true
+		return result, nil
+	}
+	return model.GrepResult{}, err
+}
+
+func grepContext(lines []string, from, to int) []string {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	out := make([]string, 0, max(to-from, 0))
+	for i := from; i < to; i++ {
+		out = append(out, grepLine(lines[i]))
+	}
+	return out
+}
+
+func grepLine(line string) string {
+	out, _ := truncateRunesWithFlag(line, grepMaxLineRunes)
+	return out
+}
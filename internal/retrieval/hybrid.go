@@ -0,0 +1,226 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"dir2mcp/internal/model"
+)
+
+const (
+	// rrfK is the rank offset used by reciprocal rank fusion. 60 is the value
+	// from the original RRF paper and damps the influence of top ranks so a
+	// single strong signal cannot dominate the fused list.
+	rrfK = 60
+
+	// maxLexicalTerms caps the number of query terms pushed down to the store.
+	maxLexicalTerms = 16
+)
+
+// lexicalSearchStore is implemented by stores that can return candidate
+// chunks containing any of a set of query terms, best matches first, each
+// carrying its relevance in LexicalScore.
+type lexicalSearchStore interface {
+	SearchChunksLexical(ctx context.Context, terms []string, limit int) ([]model.ChunkTask, error)
+}
+
+// searchHybrid runs vector retrieval over the text and code indices and
+// lexical retrieval over the store concurrently, then fuses the ranked lists
+// with reciprocal rank fusion. Rank fusion sidesteps the need to normalize
+// cosine and BM25 scores onto a common scale; each returned hit still carries
+// its raw vector score, lexical score and fused rank so callers can explain
+// why it matched.
+func (s *Service) searchHybrid(ctx context.Context, query string, k int, textModel, codeModel string, textIndex, codeIndex model.Index, filters *searchFilters) ([]model.SearchHit, error) {
+	s.metaMu.RLock()
+	overfetchMultiplier := s.overfetchMultiplier
+	s.metaMu.RUnlock()
+	candidates := k
+	if k <= math.MaxInt/overfetchMultiplier {
+		candidates = k * overfetchMultiplier
+	}
+
+	var (
+		wg          sync.WaitGroup
+		textHits    []model.SearchHit
+		codeHits    []model.SearchHit
+		vectorErr   error
+		lexicalHits []model.SearchHit
+		lexicalErr  error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		textHits, vectorErr = s.searchSingleIndex(ctx, query, candidates, textModel, textIndex, "text", filters)
+		if vectorErr != nil {
+			return
+		}
+		codeHits, vectorErr = s.searchSingleIndex(ctx, query, candidates, codeModel, codeIndex, "code", filters)
+	}()
+	go func() {
+		defer wg.Done()
+		lexicalHits, lexicalErr = s.searchLexical(ctx, query, candidates, filters)
+	}()
+	wg.Wait()
+
+	if vectorErr != nil {
+		return nil, vectorErr
+	}
+	if lexicalErr != nil {
+		if !errors.Is(lexicalErr, model.ErrNotImplemented) {
+			return nil, lexicalErr
+		}
+		s.logf("hybrid search: lexical retrieval unavailable, using vector results only")
+		lexicalHits = nil
+	}
+
+	return fuseReciprocalRank([][]model.SearchHit{textHits, codeHits}, lexicalHits, k), nil
+}
+
+// searchLexical returns the store's best lexical matches for the query
+// terms. The store ranks by BM25 over the whole corpus and filters before
+// truncating to k, so its order and scores are used as they are.
+func (s *Service) searchLexical(ctx context.Context, query string, k int, filters *searchFilters) ([]model.SearchHit, error) {
+	source, ok := s.store.(lexicalSearchStore)
+	if !ok {
+		return nil, model.ErrNotImplemented
+	}
+	terms := lexicalTerms(query)
+	if len(terms) == 0 {
+		return []model.SearchHit{}, nil
+	}
+
+	var (
+		chunks []model.ChunkTask
+		err    error
+	)
+	if filtered, ok := s.store.(filteredLexicalSearchStore); ok {
+		chunks, err = filtered.SearchChunksLexicalFiltered(ctx, terms, k, filters.SearchQuery)
+	} else {
+		chunks, err = source.SearchChunksLexical(ctx, terms, k)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]model.SearchHit, 0, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.LexicalScore <= 0 {
+			continue
+		}
+		hit := model.SearchHit{
+			ChunkID: chunk.Metadata.ChunkID,
+			RelPath: chunk.Metadata.RelPath,
+			DocType: chunk.Metadata.DocType,
+			RepType: chunk.Metadata.RepType,
+			Score:   chunk.LexicalScore,
+			Snippet: chunk.Metadata.Snippet,
+			Span:    chunk.Metadata.Span,
+		}
+		if !s.matchSearchFilters(ctx, hit, filters) {
+			continue
+		}
+		hits = append(hits, hit)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, nil
+}
+
+// fuseReciprocalRank merges ranked hit lists using reciprocal rank fusion.
+// The vector lists are treated as a single signal: a chunk contributes
+// 1/(rrfK+rank) for its best vector rank, plus the same term for its rank in
+// the lexical list. Vector metadata wins when a chunk appears in both signals
+// because it reflects the index the chunk was embedded into.
+func fuseReciprocalRank(vectorLists [][]model.SearchHit, lexicalHits []model.SearchHit, k int) []model.SearchHit {
+	fused := make(map[uint64]*model.SearchHit)
+	order := make([]uint64, 0)
+
+	for _, list := range vectorLists {
+		for rank, hit := range list {
+			contribution := 1 / float64(rrfK+rank+1)
+			if existing, ok := fused[hit.ChunkID]; ok {
+				if contribution > existing.Score {
+					existing.Score = contribution
+				}
+				if hit.Score > existing.VectorScore {
+					existing.VectorScore = hit.Score
+				}
+				continue
+			}
+			h := hit
+			h.VectorScore = hit.Score
+			h.Score = contribution
+			fused[hit.ChunkID] = &h
+			order = append(order, hit.ChunkID)
+		}
+	}
+	for rank, hit := range lexicalHits {
+		contribution := 1 / float64(rrfK+rank+1)
+		if existing, ok := fused[hit.ChunkID]; ok {
+			existing.LexicalScore = hit.Score
+			existing.Score += contribution
+			continue
+		}
+		h := hit
+		h.LexicalScore = hit.Score
+		h.Score = contribution
+		fused[hit.ChunkID] = &h
+		order = append(order, hit.ChunkID)
+	}
+
+	out := make([]model.SearchHit, 0, len(order))
+	for _, id := range order {
+		out = append(out, *fused[id])
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score == out[j].Score {
+			return out[i].ChunkID < out[j].ChunkID
+		}
+		return out[i].Score > out[j].Score
+	})
+	if len(out) > k {
+		out = out[:k]
+	}
+	for i := range out {
+		out[i].FusedRank = i + 1
+	}
+	return out
+}
+
+// lexicalTerms extracts the distinct lowercase query terms used for keyword
+// matching. Single-character tokens are dropped as they carry almost no
+// signal and would match nearly every chunk.
+func lexicalTerms(query string) []string {
+	seen := make(map[string]struct{})
+	out := make([]string, 0, maxLexicalTerms)
+	for _, tok := range tokenizeLexical(query) {
+		if len([]rune(tok)) < 2 {
+			continue
+		}
+		if _, ok := seen[tok]; ok {
+			continue
+		}
+		seen[tok] = struct{}{}
+		out = append(out, tok)
+		if len(out) >= maxLexicalTerms {
+			break
+		}
+	}
+	return out
+}
+
+func tokenizeLexical(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
@@ -47,6 +47,12 @@ var defaultSecretPatternLiterals = []string{
 	`sk_[a-z0-9]{32}|api_[A-Za-z0-9]{32}`,
 }
 
+// embeddingModelReporter is implemented by indices that know which embedding
+// model produced their vectors.
+type embeddingModelReporter interface {
+	EmbeddingModel() string
+}
+
 // Service implements retrieval operations over embedded data.
 // It holds necessary components like store, index, embedder and
 // supports configurable overfetching during searches. OverfetchMultiplier
@@ -78,7 +84,12 @@ type Service struct {
 	indexingStateFn     func() bool
 	textModel           string
 	codeModel           string
+	chatModel           string
+	contextBudgets      map[string]int
+	neighborChunks      int
 	overfetchMultiplier int
+	rerankers           map[string]model.Reranker
+	verifyWithGenerator bool
 	metaMu              sync.RWMutex
 	chunkByLabel        map[uint64]model.SearchHit
 	chunkByIndex        map[string]map[uint64]model.SearchHit
@@ -116,7 +127,9 @@ func NewService(store model.Store, index model.Index, embedder model.Embedder, g
 		logger:              log.Default(),
 		textModel:           "mistral-embed",
 		codeModel:           "codestral-embed",
+		neighborChunks:      defaultNeighborChunks,
 		overfetchMultiplier: 5,
+		rerankers:           map[string]model.Reranker{RerankerLexical: LexicalReranker{}},
 		chunkByLabel:        make(map[uint64]model.SearchHit),
 		chunkByIndex: map[string]map[uint64]model.SearchHit{
 			"text": make(map[uint64]model.SearchHit),
@@ -329,7 +342,19 @@ func (s *Service) SetOverfetchMultiplier(m int) {
 	s.metaMu.Unlock()
 }
 
+// Search returns the top query.K hits, applying the reranker named in
+// query.Rerank when one is set. Use SearchWithMetadata to also learn how the
+// reranker performed.
 func (s *Service) Search(ctx context.Context, query model.SearchQuery) ([]model.SearchHit, error) {
+	result, err := s.SearchWithMetadata(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Hits, nil
+}
+
+// search runs first-stage retrieval for query without reranking.
+func (s *Service) search(ctx context.Context, query model.SearchQuery) ([]model.SearchHit, error) {
 	s.metaMu.RLock()
 	textModel := s.textModel
 	codeModel := s.codeModel
@@ -342,28 +367,50 @@ func (s *Service) Search(ctx context.Context, query model.SearchQuery) ([]model.
 		k = 10
 	}
 
+	filters, err := s.newSearchFilters(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if filters.prefiltered() && len(filters.labels) == 0 {
+		return []model.SearchHit{}, nil
+	}
+
 	mode := strings.ToLower(strings.TrimSpace(query.Index))
 	if mode == "" {
 		mode = "auto"
 	}
 	switch mode {
 	case "text":
-		return s.searchSingleIndex(ctx, query.Query, k, textModel, textIndex, "text", query)
+		return s.searchSingleIndex(ctx, query.Query, k, textModel, textIndex, "text", filters)
 	case "code":
-		return s.searchSingleIndex(ctx, query.Query, k, codeModel, codeIndex, "code", query)
+		return s.searchSingleIndex(ctx, query.Query, k, codeModel, codeIndex, "code", filters)
 	case "both":
-		return s.searchBothIndices(ctx, query.Query, k, textModel, codeModel, textIndex, codeIndex, query)
+		return s.searchBothIndices(ctx, query.Query, k, textModel, codeModel, textIndex, codeIndex, filters)
+	case "hybrid":
+		return s.searchHybrid(ctx, query.Query, k, textModel, codeModel, textIndex, codeIndex, filters)
 	case "auto":
 		if looksLikeCodeQuery(query.Query) {
-			return s.searchSingleIndex(ctx, query.Query, k, codeModel, codeIndex, "code", query)
+			return s.searchSingleIndex(ctx, query.Query, k, codeModel, codeIndex, "code", filters)
 		}
-		return s.searchSingleIndex(ctx, query.Query, k, textModel, textIndex, "text", query)
+		return s.searchSingleIndex(ctx, query.Query, k, textModel, textIndex, "text", filters)
 	default:
-		return s.searchSingleIndex(ctx, query.Query, k, textModel, textIndex, "text", query)
+		return s.searchSingleIndex(ctx, query.Query, k, textModel, textIndex, "text", filters)
 	}
 }
 
 func (s *Service) Ask(ctx context.Context, question string, query model.SearchQuery) (model.AskResult, error) {
+	return s.ask(ctx, question, query, nil)
+}
+
+// AskStream is like Ask but passes the generated answer to onDelta as it is
+// produced when the generator supports streaming. The deltas form a draft:
+// the returned answer may add attributions, or fall back to the listed hits
+// when generation fails part-way.
+func (s *Service) AskStream(ctx context.Context, question string, query model.SearchQuery, onDelta func(string)) (model.AskResult, error) {
+	return s.ask(ctx, question, query, onDelta)
+}
+
+func (s *Service) ask(ctx context.Context, question string, query model.SearchQuery, onDelta func(string)) (model.AskResult, error) {
 	question = strings.TrimSpace(question)
 	if question == "" {
 		return model.AskResult{}, errors.New("question is required")
@@ -376,10 +423,11 @@ func (s *Service) Ask(ctx context.Context, question string, query model.SearchQu
 		query.K = 10
 	}
 
-	hits, err := s.Search(ctx, query)
+	searchResult, err := s.SearchWithMetadata(ctx, query)
 	if err != nil {
 		return model.AskResult{}, err
 	}
+	hits := searchResult.Hits
 
 	citations := make([]model.Citation, 0, len(hits))
 	for _, hit := range hits {
@@ -391,9 +439,24 @@ func (s *Service) Ask(ctx context.Context, question string, query model.SearchQu
 	}
 
 	answer := buildFallbackAnswer(question, hits)
+	generatedAnswer := false
+	var askContext *model.AskContext
 	if s.gen != nil && len(hits) > 0 {
-		prompt := buildRAGPrompt(question, hits)
-		generated, genErr := s.gen.Generate(ctx, prompt)
+		askContext = s.assembleAskContext(ctx, question, hits)
+		var (
+			generated string
+			genErr    error
+		)
+		streamGen, streaming := s.gen.(model.StreamingGenerator)
+		sysGen, withSystem := s.gen.(model.SystemPromptGenerator)
+		switch {
+		case streaming && onDelta != nil:
+			generated, genErr = streamGen.GenerateStream(ctx, askContext.SystemPrompt, askContext.UserPrompt, onDelta)
+		case withSystem:
+			generated, genErr = sysGen.GenerateWithSystem(ctx, askContext.SystemPrompt, askContext.UserPrompt)
+		default:
+			generated, genErr = s.gen.Generate(ctx, askContext.SystemPrompt+"\n\n"+askContext.UserPrompt)
+		}
 		if genErr != nil {
 			// log the error so callers have visibility; fall back to the
 			// precomputed answer when generation fails.  avoid recording the
@@ -403,11 +466,19 @@ func (s *Service) Ask(ctx context.Context, question string, query model.SearchQu
 		} else {
 			if trimmed := strings.TrimSpace(generated); trimmed != "" {
 				answer = trimmed
+				generatedAnswer = true
 			}
 		}
 	}
 	answer = ensureAnswerAttributions(answer, citations)
 
+	// only generated answers make claims worth checking; the fallback answer
+	// merely lists the hits.
+	var verification *model.AnswerVerification
+	if generatedAnswer {
+		verification = s.verifyAnswer(ctx, answer, hits, askContext)
+	}
+
 	// use the shared accessor to determine whether indexing is complete;
 	// this centralizes locking and nil-handling logic and avoids duplicating
 	// the callback lookup that was previously done here.
@@ -419,6 +490,9 @@ func (s *Service) Ask(ctx context.Context, question string, query model.SearchQu
 		Citations:        citations,
 		Hits:             hits,
 		IndexingComplete: indexingComplete,
+		Context:          askContext,
+		Rerank:           searchResult.Rerank,
+		Verification:     verification,
 	}, nil
 }
 
@@ -458,6 +532,45 @@ func (s *Service) OpenFileWithMeta(ctx context.Context, relPath string, span mod
 	return s.openFile(ctx, relPath, span, maxChars)
 }
 
+// OpenFileBytes returns up to maxBytes of the raw file at relPath, applying
+// the same root, exclusion and secret checks as OpenFile. The boolean reports
+// whether the file was cut short. A non-positive maxBytes reads the whole
+// file.
+func (s *Service) OpenFileBytes(ctx context.Context, relPath string, maxBytes int) ([]byte, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	relPath = strings.TrimSpace(relPath)
+	if relPath == "" {
+		return nil, false, model.ErrForbidden
+	}
+
+	s.metaMu.RLock()
+	rootDir := s.rootDir
+	pathExcludes := append([]string(nil), s.pathExcludes...)
+	secretPatterns := append([]*regexp.Regexp(nil), s.secretPatterns...)
+	s.metaMu.RUnlock()
+
+	target, err := s.resolveRootPath(rootDir, relPath, pathExcludes)
+	if err != nil {
+		return nil, false, err
+	}
+	resolvedAbs, err := s.resolveRegularFile(target, pathExcludes)
+	if err != nil {
+		return nil, false, err
+	}
+	raw, truncated, err := readFileBounded(resolvedAbs, maxBytes)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, re := range secretPatterns {
+		if re != nil && re.Match(raw) {
+			return nil, false, model.ErrForbidden
+		}
+	}
+	return raw, truncated, nil
+}
+
 func (s *Service) openFile(ctx context.Context, relPath string, span model.Span, maxChars int) (string, bool, error) {
 	if err := ctx.Err(); err != nil {
 		return "", false, err
@@ -479,34 +592,12 @@ func (s *Service) openFile(ctx context.Context, relPath string, span model.Span,
 	pathExcludes := append([]string(nil), s.pathExcludes...)
 	secretPatterns := append([]*regexp.Regexp(nil), s.secretPatterns...)
 	s.metaMu.RUnlock()
-	if strings.TrimSpace(rootDir) == "" {
-		rootDir = "."
-	}
-
-	normalizedRel := filepath.ToSlash(filepath.Clean(relPath))
-	if normalizedRel == "." || strings.HasPrefix(normalizedRel, "../") || normalizedRel == ".." || filepath.IsAbs(relPath) {
-		return "", false, model.ErrPathOutsideRoot
-	}
-	for _, pattern := range pathExcludes {
-		if s.matchExcludePattern(pattern, normalizedRel) {
-			return "", false, model.ErrForbidden
-		}
-	}
 
-	rootAbs, err := filepath.Abs(rootDir)
+	target, err := s.resolveRootPath(rootDir, relPath, pathExcludes)
 	if err != nil {
 		return "", false, err
 	}
-	realRoot := rootAbs
-	if resolvedRoot, rootErr := filepath.EvalSymlinks(rootAbs); rootErr == nil {
-		realRoot = resolvedRoot
-	}
-
-	targetAbs := filepath.Join(realRoot, filepath.FromSlash(normalizedRel))
-	relFromRoot, err := filepath.Rel(realRoot, targetAbs)
-	if err != nil || relFromRoot == ".." || strings.HasPrefix(relFromRoot, ".."+string(os.PathSeparator)) {
-		return "", false, model.ErrPathOutsideRoot
-	}
+	normalizedRel := target.rel
 
 	kind := strings.ToLower(strings.TrimSpace(span.Kind))
 	if kind == "page" || kind == "time" {
@@ -521,32 +612,10 @@ func (s *Service) openFile(ctx context.Context, relPath string, span model.Span,
 		}
 	}
 
-	resolvedAbs, err := filepath.EvalSymlinks(targetAbs)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return "", false, err
-		}
-		// if eval fails for other reasons, continue with direct target path check
-		resolvedAbs = targetAbs
-	}
-	resolvedRel, err := filepath.Rel(realRoot, resolvedAbs)
-	if err != nil || resolvedRel == ".." || strings.HasPrefix(resolvedRel, ".."+string(os.PathSeparator)) {
-		return "", false, model.ErrPathOutsideRoot
-	}
-	resolvedRel = filepath.ToSlash(filepath.Clean(resolvedRel))
-	for _, pattern := range pathExcludes {
-		if s.matchExcludePattern(pattern, resolvedRel) {
-			return "", false, model.ErrForbidden
-		}
-	}
-
-	info, err := os.Stat(resolvedAbs)
+	resolvedAbs, err := s.resolveRegularFile(target, pathExcludes)
 	if err != nil {
 		return "", false, err
 	}
-	if info.IsDir() {
-		return "", false, model.ErrDocTypeUnsupported
-	}
 
 	raw, readTruncated, err := readFileBounded(resolvedAbs, 0)
 	if err != nil {
@@ -603,6 +672,81 @@ func (s *Service) openFile(ctx context.Context, relPath string, span model.Span,
 	return out, readTruncated || outTruncated, nil
 }
 
+// rootPath is a relative path validated against the root directory and the
+// path excludes.
+type rootPath struct {
+	// rel is the cleaned, slash-separated relative path.
+	rel      string
+	realRoot string
+	abs      string
+}
+
+// resolveRootPath checks that relPath stays inside rootDir and is not
+// excluded, without touching the file itself.
+func (s *Service) resolveRootPath(rootDir, relPath string, pathExcludes []string) (rootPath, error) {
+	if strings.TrimSpace(rootDir) == "" {
+		rootDir = "."
+	}
+
+	normalizedRel := filepath.ToSlash(filepath.Clean(relPath))
+	if normalizedRel == "." || strings.HasPrefix(normalizedRel, "../") || normalizedRel == ".." || filepath.IsAbs(relPath) {
+		return rootPath{}, model.ErrPathOutsideRoot
+	}
+	for _, pattern := range pathExcludes {
+		if s.matchExcludePattern(pattern, normalizedRel) {
+			return rootPath{}, model.ErrForbidden
+		}
+	}
+
+	rootAbs, err := filepath.Abs(rootDir)
+	if err != nil {
+		return rootPath{}, err
+	}
+	realRoot := rootAbs
+	if resolvedRoot, rootErr := filepath.EvalSymlinks(rootAbs); rootErr == nil {
+		realRoot = resolvedRoot
+	}
+
+	targetAbs := filepath.Join(realRoot, filepath.FromSlash(normalizedRel))
+	relFromRoot, err := filepath.Rel(realRoot, targetAbs)
+	if err != nil || relFromRoot == ".." || strings.HasPrefix(relFromRoot, ".."+string(os.PathSeparator)) {
+		return rootPath{}, model.ErrPathOutsideRoot
+	}
+	return rootPath{rel: normalizedRel, realRoot: realRoot, abs: targetAbs}, nil
+}
+
+// resolveRegularFile follows symlinks of target, re-checking that the real
+// file is inside the root and not excluded, and returns its absolute path.
+func (s *Service) resolveRegularFile(target rootPath, pathExcludes []string) (string, error) {
+	resolvedAbs, err := filepath.EvalSymlinks(target.abs)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		// if eval fails for other reasons, continue with direct target path check
+		resolvedAbs = target.abs
+	}
+	resolvedRel, err := filepath.Rel(target.realRoot, resolvedAbs)
+	if err != nil || resolvedRel == ".." || strings.HasPrefix(resolvedRel, ".."+string(os.PathSeparator)) {
+		return "", model.ErrPathOutsideRoot
+	}
+	resolvedRel = filepath.ToSlash(filepath.Clean(resolvedRel))
+	for _, pattern := range pathExcludes {
+		if s.matchExcludePattern(pattern, resolvedRel) {
+			return "", model.ErrForbidden
+		}
+	}
+
+	info, err := os.Stat(resolvedAbs)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", model.ErrDocTypeUnsupported
+	}
+	return resolvedAbs, nil
+}
+
 func (s *Service) Stats(ctx context.Context) (model.Stats, error) {
 	if err := ctx.Err(); err != nil {
 		return model.Stats{}, err
@@ -768,13 +912,21 @@ func (s *Service) searchHitForLabel(indexName string, label uint64) model.Search
 // failure reason.
 var ErrMissingEmbedder = errors.New("embedder not configured")
 
-func (s *Service) searchSingleIndex(ctx context.Context, query string, k int, modelName string, idx model.Index, indexName string, filters model.SearchQuery) ([]model.SearchHit, error) {
+func (s *Service) searchSingleIndex(ctx context.Context, query string, k int, modelName string, idx model.Index, indexName string, filters *searchFilters) ([]model.SearchHit, error) {
 	if s.embedder == nil {
 		// caller should have provided an embedder via NewService or
 		// SetEmbedder (not currently available).  Return an explicit
 		// error rather than letting the nil dereference panic later.
 		return nil, ErrMissingEmbedder
 	}
+	// an index that records the model its vectors were built with must be
+	// queried with that same model; this keeps results consistent while a
+	// rebuild for a newly configured model is still in progress.
+	if reporter, ok := idx.(embeddingModelReporter); ok {
+		if name := strings.TrimSpace(reporter.EmbeddingModel()); name != "" {
+			modelName = name
+		}
+	}
 	vectors, err := s.embedder.Embed(ctx, modelName, []string{query})
 	if err != nil {
 		return nil, err
@@ -810,7 +962,17 @@ func (s *Service) searchSingleIndex(ctx context.Context, query string, k int, mo
 	} else {
 		n = k * overfetchMultiplier
 	}
-	labels, scores, err := idx.Search(vectors[0], n)
+	// pre-resolved metadata filters restrict scoring to matching chunks so
+	// selective filters still fill k; other indices filter after scoring.
+	var (
+		labels []uint64
+		scores []float32
+	)
+	if filtered, ok := idx.(filteredSearchIndex); ok && filters.prefiltered() {
+		labels, scores, err = filtered.SearchFiltered(vectors[0], n, filters.allow)
+	} else {
+		labels, scores, err = idx.Search(vectors[0], n)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -824,7 +986,7 @@ func (s *Service) searchSingleIndex(ctx context.Context, query string, k int, mo
 	for i, label := range labels {
 		hit := s.searchHitForLabel(indexName, label)
 		hit.Score = float64(scores[i])
-		if !matchFilters(hit, filters) {
+		if !s.matchSearchFilters(ctx, hit, filters) {
 			continue
 		}
 		filtered = append(filtered, hit)
@@ -835,7 +997,7 @@ func (s *Service) searchSingleIndex(ctx context.Context, query string, k int, mo
 	return filtered, nil
 }
 
-func (s *Service) searchBothIndices(ctx context.Context, query string, k int, textModel, codeModel string, textIndex, codeIndex model.Index, filters model.SearchQuery) ([]model.SearchHit, error) {
+func (s *Service) searchBothIndices(ctx context.Context, query string, k int, textModel, codeModel string, textIndex, codeIndex model.Index, filters *searchFilters) ([]model.SearchHit, error) {
 	// each single-index call will apply the overfetch multiplier internally
 	textHits, err := s.searchSingleIndex(ctx, query, k, textModel, textIndex, "text", filters)
 	if err != nil {
@@ -846,6 +1008,13 @@ func (s *Service) searchBothIndices(ctx context.Context, query string, k int, te
 		return nil, err
 	}
 
+	return mergeIndexHits(textHits, codeHits, k), nil
+}
+
+// mergeIndexHits combines hits from the text and code indices into one
+// list of at most k hits. Scores are normalised per index first so neither
+// index dominates; a chunk found in both keeps its higher score.
+func mergeIndexHits(textHits, codeHits []model.SearchHit, k int) []model.SearchHit {
 	normalizeScores(textHits)
 	normalizeScores(codeHits)
 
@@ -876,7 +1045,7 @@ func (s *Service) searchBothIndices(ctx context.Context, query string, k int, te
 	if len(out) > k {
 		out = out[:k]
 	}
-	return out, nil
+	return out
 }
 
 func normalizeScores(hits []model.SearchHit) {
@@ -973,34 +1142,6 @@ func buildFallbackAnswer(question string, hits []model.SearchHit) string {
 	return strings.Join(lines, "\n")
 }
 
-func buildRAGPrompt(question string, hits []model.SearchHit) string {
-	var b strings.Builder
-	b.WriteString("Answer the question using only the provided context.\n")
-	b.WriteString("Include concise source attributions in the form [rel_path].\n\n")
-	b.WriteString("Question:\n")
-	b.WriteString(question)
-	b.WriteString("\n\nContext:\n")
-
-	limit := len(hits)
-	if limit > 8 {
-		limit = 8
-	}
-	for i := 0; i < limit; i++ {
-		h := hits[i]
-		b.WriteString("- [")
-		b.WriteString(h.RelPath)
-		b.WriteString("] ")
-		snippet := truncateSnippet(strings.TrimSpace(h.Snippet), 300)
-		if snippet == "" {
-			b.WriteString("(no snippet)\n")
-			continue
-		}
-		b.WriteString(snippet)
-		b.WriteString("\n")
-	}
-	return b.String()
-}
-
 func truncateSnippet(s string, maxRunes int) string {
 	if maxRunes <= 0 {
 		return ""
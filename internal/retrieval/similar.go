@@ -0,0 +1,293 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+// similarSeed is the query of a find-similar search in one index: the seed
+// chunks that have a stored vector there and the mean of those vectors.
+type similarSeed struct {
+	labels []uint64
+	vector []float32
+}
+
+// FindSimilar returns the chunks closest to an indexed chunk or file. The
+// query vector is the seed chunk's stored embedding, or the mean of a file's
+// chunk embeddings, so nothing is re-embedded. Seed chunks never appear in
+// the hits. It returns model.ErrNotFound when no seed chunk has a stored
+// vector in the selected index.
+func (s *Service) FindSimilar(ctx context.Context, query model.SimilarQuery) (model.SimilarResult, error) {
+	if err := ctx.Err(); err != nil {
+		return model.SimilarResult{}, err
+	}
+	query.RelPath = strings.TrimSpace(query.RelPath)
+	if query.ChunkID == 0 && query.RelPath == "" {
+		return model.SimilarResult{}, errors.New("chunk_id or rel_path is required")
+	}
+	k := query.K
+	if k <= 0 {
+		k = 10
+	}
+
+	s.metaMu.RLock()
+	indices := map[string]model.Index{"text": s.textIndex, "code": s.codeIndex}
+	s.metaMu.RUnlock()
+
+	mode := strings.ToLower(strings.TrimSpace(query.Index))
+	if mode == "" {
+		mode = "auto"
+	}
+	var kinds []string
+	switch mode {
+	case "text", "code":
+		kinds = []string{mode}
+	case "both":
+		kinds = []string{"text", "code"}
+	case "auto":
+		// prefer the index matching the seed's content, falling back to the
+		// other one when the seed was only embedded there.
+		kinds = []string{"text", "code"}
+		if s.similarSeedLooksLikeCode(query) {
+			kinds = []string{"code", "text"}
+		}
+	default:
+		return model.SimilarResult{}, fmt.Errorf("unsupported index %q", query.Index)
+	}
+
+	seeds := make(map[string]similarSeed, len(kinds))
+	for _, kind := range kinds {
+		if seed, ok := s.similarSeedFor(kind, indices[kind], query); ok {
+			seeds[kind] = seed
+			if mode == "auto" {
+				kinds = []string{kind}
+				break
+			}
+		}
+	}
+	if len(seeds) == 0 {
+		return model.SimilarResult{}, fmt.Errorf("find similar seed: %w", model.ErrNotFound)
+	}
+
+	filters, err := s.newSearchFilters(ctx, query.SearchQuery)
+	if err != nil {
+		return model.SimilarResult{}, err
+	}
+
+	result := model.SimilarResult{IndexUsed: mode, Hits: []model.SearchHit{}}
+	var seedLabels []uint64
+	hitsByKind := make(map[string][]model.SearchHit, len(seeds))
+	for _, kind := range kinds {
+		seed, ok := seeds[kind]
+		if !ok {
+			continue
+		}
+		seedLabels = appendUniqueLabels(seedLabels, seed.labels)
+		if mode == "auto" {
+			result.IndexUsed = kind
+		}
+		if filters.prefiltered() && len(filters.labels) == 0 {
+			continue
+		}
+		hits, err := s.searchSimilar(ctx, kind, indices[kind], seed, k, filters)
+		if err != nil {
+			return model.SimilarResult{}, err
+		}
+		hitsByKind[kind] = hits
+	}
+	sort.Slice(seedLabels, func(i, j int) bool { return seedLabels[i] < seedLabels[j] })
+	result.SeedChunkIDs = seedLabels
+
+	if mode == "both" {
+		result.Hits = mergeIndexHits(hitsByKind["text"], hitsByKind["code"], k)
+	} else if hits := hitsByKind[result.IndexUsed]; hits != nil {
+		result.Hits = hits
+	}
+	return result, nil
+}
+
+// similarSeedLooksLikeCode reports whether the seed of query is source code,
+// judged by its document type or file extension.
+func (s *Service) similarSeedLooksLikeCode(query model.SimilarQuery) bool {
+	relPath := query.RelPath
+	if query.ChunkID != 0 {
+		s.metaMu.RLock()
+		meta, ok := s.chunkByIndex["code"][query.ChunkID]
+		if !ok {
+			meta, ok = s.chunkByLabel[query.ChunkID]
+		}
+		s.metaMu.RUnlock()
+		if !ok {
+			return false
+		}
+		if strings.EqualFold(meta.DocType, "code") {
+			return true
+		}
+		relPath = meta.RelPath
+	}
+	return ingest.CodeLanguage(relPath) != ""
+}
+
+// similarSeedFor resolves the seed chunks of query that have a vector in idx
+// and averages their vectors.
+func (s *Service) similarSeedFor(kind string, idx model.Index, query model.SimilarQuery) (similarSeed, bool) {
+	lookup, ok := idx.(vectorLookupIndex)
+	if !ok {
+		return similarSeed{}, false
+	}
+
+	var candidates []uint64
+	if query.ChunkID != 0 {
+		candidates = []uint64{query.ChunkID}
+	} else {
+		s.metaMu.RLock()
+		for label, meta := range s.chunkByIndex[kind] {
+			if meta.RelPath == query.RelPath && spanOverlaps(meta.Span, query.Span) {
+				candidates = append(candidates, label)
+			}
+		}
+		s.metaMu.RUnlock()
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+	}
+
+	seed := similarSeed{}
+	var sum []float64
+	for _, label := range candidates {
+		vec, found := lookup.Vector(label)
+		if !found || len(vec) == 0 {
+			continue
+		}
+		if sum == nil {
+			sum = make([]float64, len(vec))
+		}
+		if len(vec) != len(sum) {
+			continue
+		}
+		// normalise before averaging so each chunk weighs the same
+		// regardless of its vector length.
+		var norm float64
+		for _, v := range vec {
+			norm += float64(v) * float64(v)
+		}
+		if norm == 0 {
+			continue
+		}
+		norm = math.Sqrt(norm)
+		for i, v := range vec {
+			sum[i] += float64(v) / norm
+		}
+		seed.labels = append(seed.labels, label)
+	}
+	if len(seed.labels) == 0 {
+		return similarSeed{}, false
+	}
+	seed.vector = make([]float32, len(sum))
+	for i, v := range sum {
+		seed.vector[i] = float32(v / float64(len(seed.labels)))
+	}
+	return seed, true
+}
+
+// searchSimilar queries idx with the seed vector and returns up to k hits
+// that satisfy filters, skipping the seed chunks.
+func (s *Service) searchSimilar(ctx context.Context, kind string, idx model.Index, seed similarSeed, k int, filters *searchFilters) ([]model.SearchHit, error) {
+	exclude := make(map[uint64]struct{}, len(seed.labels))
+	for _, label := range seed.labels {
+		exclude[label] = struct{}{}
+	}
+
+	s.metaMu.RLock()
+	overfetchMultiplier := s.overfetchMultiplier
+	s.metaMu.RUnlock()
+	// the seed chunks are usually the nearest neighbours of their own
+	// vector, so ask for enough extra candidates to replace them.
+	wanted := k
+	if wanted <= math.MaxInt-len(seed.labels) {
+		wanted += len(seed.labels)
+	}
+	n := math.MaxInt
+	if wanted <= math.MaxInt/overfetchMultiplier {
+		n = wanted * overfetchMultiplier
+	}
+
+	var (
+		labels []uint64
+		scores []float32
+		err    error
+	)
+	if filtered, ok := idx.(filteredSearchIndex); ok {
+		labels, scores, err = filtered.SearchFiltered(seed.vector, n, func(label uint64) bool {
+			if _, isSeed := exclude[label]; isSeed {
+				return false
+			}
+			return filters.allow(label)
+		})
+	} else {
+		labels, scores, err = idx.Search(seed.vector, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]model.SearchHit, 0, k)
+	for i, label := range labels {
+		if _, isSeed := exclude[label]; isSeed {
+			continue
+		}
+		hit := s.searchHitForLabel(kind, label)
+		hit.Score = float64(scores[i])
+		if !s.matchSearchFilters(ctx, hit, filters) {
+			continue
+		}
+		out = append(out, hit)
+		if len(out) >= k {
+			break
+		}
+	}
+	return out, nil
+}
+
+// spanOverlaps reports whether a chunk span overlaps the requested span. A
+// requested span without a kind matches every chunk.
+func spanOverlaps(chunk, requested model.Span) bool {
+	kind := strings.ToLower(strings.TrimSpace(requested.Kind))
+	if kind == "" {
+		return true
+	}
+	if !strings.EqualFold(chunk.Kind, kind) {
+		return false
+	}
+	switch kind {
+	case "lines":
+		return overlapsTime(chunk.StartLine, chunk.EndLine, requested.StartLine, requested.EndLine)
+	case "page":
+		return chunk.Page == requested.Page
+	case "time":
+		return overlapsTime(chunk.StartMS, chunk.EndMS, requested.StartMS, requested.EndMS)
+	default:
+		return false
+	}
+}
+
+func appendUniqueLabels(dst, labels []uint64) []uint64 {
+	for _, label := range labels {
+		seen := false
+		for _, existing := range dst {
+			if existing == label {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			dst = append(dst, label)
+		}
+	}
+	return dst
+}
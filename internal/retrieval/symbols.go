@@ -0,0 +1,178 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+// maxReferenceDefinitions bounds the definitions looked up to exclude their
+// declaration lines from a references search.
+const maxReferenceDefinitions = 1000
+
+// Symbols returns the symbols matching query from the store's symbol table,
+// leaving out files hidden by the path excludes. It returns
+// model.ErrNotImplemented when the store keeps no symbol table.
+func (s *Service) Symbols(ctx context.Context, query model.SymbolQuery) ([]model.Symbol, error) {
+	s.metaMu.RLock()
+	st := s.store
+	pathExcludes := append([]string(nil), s.pathExcludes...)
+	s.metaMu.RUnlock()
+
+	symbolStore, ok := st.(model.SymbolStore)
+	if !ok {
+		return nil, model.ErrNotImplemented
+	}
+	symbols, err := symbolStore.SearchSymbols(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	out := symbols[:0]
+	for _, sym := range symbols {
+		if s.pathExcluded(sym.RelPath, pathExcludes) {
+			continue
+		}
+		out = append(out, sym)
+	}
+	return out, nil
+}
+
+// Definitions returns the symbols named exactly query.Name. A qualified name
+// such as "Service.Grep" or "Engine::run" selects the member of that
+// container, falling back to the whole name for languages whose symbol
+// names contain dots.
+func (s *Service) Definitions(ctx context.Context, query model.SymbolQuery) ([]model.Symbol, error) {
+	query.Name = strings.TrimSpace(query.Name)
+	if query.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	query.Exact = true
+	if query.Container == "" {
+		if container, name, ok := splitQualifiedName(query.Name); ok {
+			qualified := query
+			qualified.Container, qualified.Name = container, name
+			symbols, err := s.Symbols(ctx, qualified)
+			if err != nil || len(symbols) > 0 {
+				return symbols, err
+			}
+		}
+	}
+	return s.Symbols(ctx, query)
+}
+
+// References returns the lines of indexed raw text where query.Name occurs as
+// a whole identifier, scanned with the same limits, excludes and secret
+// handling as Grep. Occurrences are textual, so mentions in comments and
+// strings are included. The lines declaring the name are reported as
+// Definitions and left out of the matches unless query.IncludeDefinitions is
+// set.
+func (s *Service) References(ctx context.Context, query model.ReferenceQuery, emit func(model.GrepMatch)) (model.ReferenceResult, error) {
+	name := strings.TrimSpace(query.Name)
+	if name == "" {
+		return model.ReferenceResult{}, errors.New("name is required")
+	}
+	// references are searched by the bare member name.
+	if _, member, ok := splitQualifiedName(name); ok {
+		name = member
+	}
+	re, err := regexp.Compile(identifierPattern(name))
+	if err != nil {
+		return model.ReferenceResult{}, fmt.Errorf("invalid name: %w", err)
+	}
+
+	definitions, err := s.Definitions(ctx, model.SymbolQuery{
+		Name:       query.Name,
+		Language:   query.Language,
+		PathPrefix: query.PathPrefix,
+		FileGlob:   query.FileGlob,
+		Limit:      maxReferenceDefinitions,
+	})
+	if err != nil && !errors.Is(err, model.ErrNotImplemented) {
+		return model.ReferenceResult{}, err
+	}
+	definitionLines := make(map[string]map[int]struct{}, len(definitions))
+	for _, sym := range definitions {
+		if definitionLines[sym.RelPath] == nil {
+			definitionLines[sym.RelPath] = make(map[int]struct{})
+		}
+		definitionLines[sym.RelPath][sym.Span.StartLine] = struct{}{}
+	}
+
+	var acceptPath func(string) bool
+	if language := strings.TrimSpace(query.Language); language != "" {
+		if canonical := ingest.NormalizeCodeLanguage(language); canonical != "" {
+			language = canonical
+		}
+		acceptPath = func(relPath string) bool {
+			return ingest.CodeLanguage(relPath) == language
+		}
+	}
+	var keep func(model.GrepMatch) bool
+	if !query.IncludeDefinitions {
+		keep = func(match model.GrepMatch) bool {
+			_, isDefinition := definitionLines[match.RelPath][match.Span.StartLine]
+			return !isDefinition
+		}
+	}
+
+	grepResult, err := s.grep(ctx, re, model.GrepQuery{
+		Pattern:      re.String(),
+		PathPrefix:   query.PathPrefix,
+		FileGlob:     query.FileGlob,
+		ContextLines: query.ContextLines,
+		MaxMatches:   query.MaxMatches,
+	}, acceptPath, keep, emit)
+	if err != nil {
+		return model.ReferenceResult{}, err
+	}
+	if definitions == nil {
+		definitions = []model.Symbol{}
+	}
+	return model.ReferenceResult{Definitions: definitions, GrepResult: grepResult}, nil
+}
+
+// pathExcluded reports whether relPath matches one of pathExcludes.
+func (s *Service) pathExcluded(relPath string, pathExcludes []string) bool {
+	for _, pattern := range pathExcludes {
+		if s.matchExcludePattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitQualifiedName splits "Container.name" or "Container::name" at the last
+// separator.
+func splitQualifiedName(name string) (string, string, bool) {
+	for _, sep := range []string{"::", "."} {
+		if idx := strings.LastIndex(name, sep); idx > 0 && idx+len(sep) < len(name) {
+			return name[:idx], name[idx+len(sep):], true
+		}
+	}
+	return "", "", false
+}
+
+// identifierPattern matches name as a whole identifier. Word boundaries are
+// only asserted next to word characters, so names such as Ruby's "valid?"
+// still match.
+func identifierPattern(name string) string {
+	pattern := regexp.QuoteMeta(name)
+	runes := []rune(name)
+	if isIdentifierRune(runes[0]) {
+		pattern = `\b` + pattern
+	}
+	if isIdentifierRune(runes[len(runes)-1]) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
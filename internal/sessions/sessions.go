@@ -0,0 +1,198 @@
+// Package sessions persists MCP sessions in SQLite so they survive server
+// restarts and are shared by every server process using the same state
+// directory. Session IDs are stored as SHA-256 hashes.
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// FileName is the session database inside the state directory.
+const FileName = "sessions.sqlite"
+
+// Expiry reasons reported by Touch.
+const (
+	ReasonInactivity  = "inactivity"
+	ReasonMaxLifetime = "max-lifetime"
+)
+
+// touchInterval bounds how often Touch rewrites last_seen for a busy
+// session, so a stream of requests does not turn into a stream of writes.
+const touchInterval = time.Second
+
+// ErrNotFound is returned for unknown and expired sessions.
+var ErrNotFound = errors.New("session not found")
+
+// Session is one stored session.
+type Session struct {
+	ID              string
+	Created         time.Time
+	LastSeen        time.Time
+	ProtocolVersion string
+	ClientName      string
+	ClientVersion   string
+}
+
+// Store is a SQLite-backed session table. It is safe for concurrent use, and
+// several processes may open the same file.
+type Store struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS sessions (
+  id_hash          TEXT PRIMARY KEY,
+  created_ms       INTEGER NOT NULL,
+  last_seen_ms     INTEGER NOT NULL,
+  protocol_version TEXT NOT NULL DEFAULT '',
+  client_name      TEXT NOT NULL DEFAULT '',
+  client_version   TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS sessions_last_seen ON sessions(last_seen_ms);
+CREATE INDEX IF NOT EXISTS sessions_created ON sessions(created_ms);`
+
+// Open opens or creates the session database at path.
+func Open(path string) (*Store, error) {
+	// create the file up front so it gets restrictive permissions.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+
+	// WAL lets readers in other processes proceed while one writes, and the
+	// busy timeout makes concurrent writers wait instead of failing.
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialize session store: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create stores a new session.
+func (s *Store) Create(ctx context.Context, sess Session) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions(id_hash, created_ms, last_seen_ms, protocol_version, client_name, client_version)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		hashID(sess.ID), sess.Created.UnixMilli(), sess.LastSeen.UnixMilli(),
+		sess.ProtocolVersion, sess.ClientName, sess.ClientVersion)
+	return err
+}
+
+// Touch looks up session id at now. An active session has its last-seen
+// time moved to now and is returned. An expired session is deleted, and
+// Touch returns ErrNotFound with the reason; an unknown session returns
+// ErrNotFound with an empty reason.
+func (s *Store) Touch(ctx context.Context, id string, now time.Time, inactivity, maxLifetime time.Duration) (Session, string, error) {
+	idHash := hashID(id)
+	var createdMS, lastSeenMS int64
+	sess := Session{ID: id}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT created_ms, last_seen_ms, protocol_version, client_name, client_version
+		FROM sessions WHERE id_hash = ?`, idHash,
+	).Scan(&createdMS, &lastSeenMS, &sess.ProtocolVersion, &sess.ClientName, &sess.ClientVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, "", ErrNotFound
+	}
+	if err != nil {
+		return Session{}, "", err
+	}
+	sess.Created = time.UnixMilli(createdMS)
+	sess.LastSeen = time.UnixMilli(lastSeenMS)
+
+	if reason := ExpiryReason(sess.Created, sess.LastSeen, now, inactivity, maxLifetime); reason != "" {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id_hash = ?`, idHash); err != nil {
+			return Session{}, "", err
+		}
+		return Session{}, reason, ErrNotFound
+	}
+	if now.Sub(sess.LastSeen) >= touchInterval {
+		// MAX keeps a later touch from another process.
+		if _, err := s.db.ExecContext(ctx, `
+			UPDATE sessions SET last_seen_ms = MAX(last_seen_ms, ?) WHERE id_hash = ?`,
+			now.UnixMilli(), idHash); err != nil {
+			return Session{}, "", err
+		}
+		sess.LastSeen = now
+	}
+	return sess, "", nil
+}
+
+// Delete removes session id. Deleting an unknown session is not an error.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id_hash = ?`, hashID(id))
+	return err
+}
+
+// DeleteExpired removes every session expired at now and returns how many
+// were removed.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time, inactivity, maxLifetime time.Duration) (int64, error) {
+	inactiveBefore, createdBefore := cutoffs(now, inactivity, maxLifetime)
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM sessions WHERE last_seen_ms < ? OR created_ms < ?`,
+		inactiveBefore, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountActive returns how many sessions are active at now.
+func (s *Store) CountActive(ctx context.Context, now time.Time, inactivity, maxLifetime time.Duration) (int, error) {
+	inactiveBefore, createdBefore := cutoffs(now, inactivity, maxLifetime)
+	var n int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM sessions WHERE last_seen_ms >= ? AND created_ms >= ?`,
+		inactiveBefore, createdBefore,
+	).Scan(&n)
+	return n, err
+}
+
+// ExpiryReason returns why a session created and last seen at the given
+// times has expired at now, or "" while it is active. A zero maxLifetime
+// means no absolute limit.
+func ExpiryReason(created, lastSeen, now time.Time, inactivity, maxLifetime time.Duration) string {
+	if now.Sub(lastSeen) > inactivity {
+		return ReasonInactivity
+	}
+	if maxLifetime > 0 && now.Sub(created) > maxLifetime {
+		return ReasonMaxLifetime
+	}
+	return ""
+}
+
+// cutoffs turns the expiry rules into millisecond bounds: sessions last seen
+// before the first or created before the second have expired.
+func cutoffs(now time.Time, inactivity, maxLifetime time.Duration) (inactiveBefore, createdBefore int64) {
+	inactiveBefore = now.Add(-inactivity).UnixMilli()
+	createdBefore = int64(-1 << 63)
+	if maxLifetime > 0 {
+		createdBefore = now.Add(-maxLifetime).UnixMilli()
+	}
+	return inactiveBefore, createdBefore
+}
+
+func hashID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
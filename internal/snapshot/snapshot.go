@@ -0,0 +1,562 @@
+// Package snapshot packages a dir2mcp state directory into a single archive
+// that can be shipped to another machine and served with `up --read-only`
+// without re-ingesting the corpus.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/store"
+)
+
+// FormatVersion is the manifest format written by Create.
+const FormatVersion = 1
+
+// ManifestName is the archive member holding the manifest. It is always the
+// first entry so readers can inspect a snapshot without unpacking it.
+const ManifestName = "manifest.json"
+
+const metaFileName = "meta.sqlite"
+
+var (
+	// ErrInvalidArchive reports a malformed archive or manifest.
+	ErrInvalidArchive = errors.New("invalid snapshot archive")
+	// ErrChecksumMismatch reports that an archived file does not match the
+	// size or sha256 recorded in the manifest.
+	ErrChecksumMismatch = errors.New("snapshot checksum mismatch")
+	// ErrStateExists is returned by Restore when the target state directory
+	// already holds a metadata database and overwriting was not requested.
+	ErrStateExists = errors.New("state directory already contains an index")
+)
+
+// indexFiles and cacheDirs list the state entries, relative to the state
+// directory, that make up a snapshot besides the metadata database.
+var (
+	indexFiles = []string{"vectors_text.hnsw", "vectors_code.hnsw", "corpus.json"}
+	cacheDirs  = []string{"cache/ocr", "cache/transcribe", "cache/annotations"}
+)
+
+// File describes one archived file.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// IndexModel records the embedding model and dimension of one vector index.
+type IndexModel struct {
+	Model string `json:"model"`
+	Dim   int    `json:"dim,omitempty"`
+}
+
+// Manifest describes the contents of a snapshot archive.
+type Manifest struct {
+	FormatVersion   int                   `json:"format_version"`
+	CreatedAt       time.Time             `json:"created_at"`
+	SchemaVersion   int                   `json:"schema_version"`
+	RootFingerprint string                `json:"root_fingerprint"`
+	Documents       int64                 `json:"documents"`
+	Models          map[string]IndexModel `json:"models"`
+	ChatModel       string                `json:"chat_model,omitempty"`
+	// PendingChunks counts chunks without a vector in the archived indexes;
+	// vector search cannot find them until they are embedded.
+	PendingChunks int64  `json:"pending_chunks,omitempty"`
+	Files         []File `json:"files"`
+}
+
+func (m Manifest) lists(name string) bool {
+	for _, f := range m.Files {
+		if f.Path == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Create writes a snapshot of stateDir to outPath. The metadata database is
+// copied with the SQLite online backup API so the archive is consistent even
+// while a server is writing to the state directory. The indexes are saved
+// to disk only now and then, so chunks the copy records as embedded but the
+// copied indexes lack are marked pending again in the archive.
+func Create(ctx context.Context, stateDir, outPath string) (Manifest, error) {
+	metaPath := filepath.Join(stateDir, metaFileName)
+	if _, err := os.Stat(metaPath); err != nil {
+		return Manifest{}, fmt.Errorf("metadata database: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(filepath.Dir(outPath), ".dir2mcp-snapshot-*")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("create staging dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	backupPath := filepath.Join(staging, metaFileName)
+	st := store.NewSQLiteStore(metaPath)
+	backupErr := st.BackupTo(ctx, backupPath)
+	if closeErr := st.Close(); backupErr == nil && closeErr != nil {
+		backupErr = closeErr
+	}
+	if backupErr != nil {
+		return Manifest{}, fmt.Errorf("back up metadata database: %w", backupErr)
+	}
+
+	// sources maps archive paths to the file on disk holding their content.
+	// Index files are copied so the archive holds exactly the vectors the
+	// database copy is reconciled against.
+	sources := map[string]string{metaFileName: backupPath}
+	for _, name := range indexFiles {
+		p := filepath.Join(stateDir, name)
+		if !fileExists(p) {
+			continue
+		}
+		dst := filepath.Join(staging, name)
+		if err := copyFile(p, dst); err != nil {
+			return Manifest{}, fmt.Errorf("copy %s: %w", name, err)
+		}
+		sources[name] = dst
+	}
+	if err := reconcileEmbeddings(ctx, staging); err != nil {
+		return Manifest{}, err
+	}
+
+	info, err := store.DescribeDatabase(ctx, backupPath)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("describe metadata database: %w", err)
+	}
+	for _, dir := range cacheDirs {
+		if err := collectDir(stateDir, dir, sources); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	manifest := Manifest{
+		FormatVersion:   FormatVersion,
+		CreatedAt:       time.Now().UTC().Truncate(time.Second),
+		SchemaVersion:   info.SchemaVersion,
+		RootFingerprint: info.Fingerprint,
+		Documents:       info.Documents,
+		Models:          map[string]IndexModel{},
+		ChatModel:       info.Settings["chat_model"],
+		PendingChunks:   info.PendingChunks,
+	}
+	for _, kind := range []string{"text", "code"} {
+		manifest.Models[kind] = indexModel(staging, kind, info.Settings)
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return Manifest{}, err
+		}
+		size, sum, err := hashFile(sources[name])
+		if err != nil {
+			return Manifest{}, err
+		}
+		manifest.Files = append(manifest.Files, File{Path: name, Size: size, SHA256: sum})
+	}
+
+	if err := writeArchive(ctx, outPath, manifest, sources); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// Restore verifies the archive at archivePath and installs its contents into
+// stateDir. Every file is checked against the manifest and the metadata
+// database must match the recorded corpus fingerprint before anything in
+// stateDir is touched. An existing index is only replaced when force is set.
+func Restore(ctx context.Context, archivePath, stateDir string, force bool) (Manifest, error) {
+	if !force && fileExists(filepath.Join(stateDir, metaFileName)) {
+		return Manifest{}, fmt.Errorf("%w: %s (use --force to overwrite)", ErrStateExists, stateDir)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return Manifest{}, fmt.Errorf("create state dir: %w", err)
+	}
+	staging, err := os.MkdirTemp(stateDir, ".snapshot-restore-*")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("create staging dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	manifest, err := extractArchive(ctx, archivePath, staging)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := verifyStaged(ctx, staging, manifest); err != nil {
+		return Manifest{}, err
+	}
+	// archives written before Create reconciled its copies may still list
+	// chunks as embedded that their indexes lack.
+	if err := reconcileEmbeddings(ctx, staging); err != nil {
+		return Manifest{}, err
+	}
+
+	// stale WAL files would be replayed on top of the restored database, and
+	// an index missing from the snapshot must not survive from the old state.
+	stale := []string{metaFileName + "-wal", metaFileName + "-shm"}
+	for _, name := range indexFiles {
+		if !manifest.lists(name) {
+			stale = append(stale, name)
+		}
+	}
+	for _, name := range stale {
+		if err := os.Remove(filepath.Join(stateDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Manifest{}, fmt.Errorf("remove stale %s: %w", name, err)
+		}
+	}
+	for _, f := range manifest.Files {
+		rel := filepath.FromSlash(f.Path)
+		dst := filepath.Join(stateDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return Manifest{}, err
+		}
+		if err := os.Rename(filepath.Join(staging, rel), dst); err != nil {
+			return Manifest{}, fmt.Errorf("install %s: %w", f.Path, err)
+		}
+	}
+	return manifest, nil
+}
+
+func writeArchive(ctx context.Context, outPath string, manifest Manifest, sources map[string]string) (err error) {
+	tmpPath := outPath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = out.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(&tar.Header{
+		Name:    ManifestName,
+		Mode:    0o644,
+		Size:    int64(len(encoded)),
+		ModTime: manifest.CreatedAt,
+	}); err != nil {
+		return err
+	}
+	if _, err = tw.Write(encoded); err != nil {
+		return err
+	}
+
+	for _, f := range manifest.Files {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = appendFile(tw, f, sources[f.Path], manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, outPath)
+}
+
+func appendFile(tw *tar.Writer, f File, source string, modTime time.Time) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    f.Path,
+		Mode:    0o644,
+		Size:    f.Size,
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	// copy exactly the hashed size; a file that changed since hashing is
+	// reported rather than silently archived with a stale checksum.
+	n, err := io.Copy(tw, io.LimitReader(in, f.Size))
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", f.Path, err)
+	}
+	if n != f.Size {
+		return fmt.Errorf("archive %s: file changed while creating snapshot", f.Path)
+	}
+	return nil
+}
+
+func extractArchive(ctx context.Context, archivePath, staging string) (Manifest, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+	var manifest Manifest
+	seenManifest := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return Manifest{}, err
+		}
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return Manifest{}, fmt.Errorf("%w: unsupported entry %q", ErrInvalidArchive, hdr.Name)
+		}
+		if !seenManifest {
+			if hdr.Name != ManifestName {
+				return Manifest{}, fmt.Errorf("%w: first entry is %q, want %q", ErrInvalidArchive, hdr.Name, ManifestName)
+			}
+			manifest, err = decodeManifest(tr)
+			if err != nil {
+				return Manifest{}, err
+			}
+			seenManifest = true
+			continue
+		}
+		rel, err := cleanArchivePath(hdr.Name)
+		if err != nil {
+			return Manifest{}, err
+		}
+		dst := filepath.Join(staging, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return Manifest{}, err
+		}
+		out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		_, copyErr := io.Copy(out, tr)
+		closeErr := out.Close()
+		if copyErr != nil {
+			return Manifest{}, fmt.Errorf("extract %s: %w", hdr.Name, copyErr)
+		}
+		if closeErr != nil {
+			return Manifest{}, closeErr
+		}
+	}
+	if !seenManifest {
+		return Manifest{}, fmt.Errorf("%w: missing %s", ErrInvalidArchive, ManifestName)
+	}
+	return manifest, nil
+}
+
+func verifyStaged(ctx context.Context, staging string, manifest Manifest) error {
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return fmt.Errorf("%w: unsupported format version %d", ErrInvalidArchive, manifest.FormatVersion)
+	}
+	if manifest.SchemaVersion > store.CurrentSchemaVersion {
+		return fmt.Errorf("%w: database version %d, supported version %d", store.ErrSchemaTooNew, manifest.SchemaVersion, store.CurrentSchemaVersion)
+	}
+
+	listed := make(map[string]struct{}, len(manifest.Files))
+	hasMeta := false
+	for _, f := range manifest.Files {
+		rel, err := cleanArchivePath(f.Path)
+		if err != nil {
+			return err
+		}
+		listed[filepath.ToSlash(rel)] = struct{}{}
+		if f.Path == metaFileName {
+			hasMeta = true
+		}
+		size, sum, err := hashFile(filepath.Join(staging, rel))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s listed in manifest but missing", ErrInvalidArchive, f.Path)
+			}
+			return err
+		}
+		if size != f.Size || sum != f.SHA256 {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, f.Path)
+		}
+	}
+	if !hasMeta {
+		return fmt.Errorf("%w: manifest does not list %s", ErrInvalidArchive, metaFileName)
+	}
+	err := filepath.WalkDir(staging, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, relErr := filepath.Rel(staging, p)
+		if relErr != nil {
+			return relErr
+		}
+		if _, ok := listed[filepath.ToSlash(rel)]; !ok {
+			return fmt.Errorf("%w: %s is not listed in the manifest", ErrInvalidArchive, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := store.DescribeDatabase(ctx, filepath.Join(staging, metaFileName))
+	if err != nil {
+		return fmt.Errorf("inspect restored database: %w", err)
+	}
+	if info.Fingerprint != manifest.RootFingerprint {
+		return fmt.Errorf("%w: root fingerprint %s does not match manifest %s", ErrChecksumMismatch, info.Fingerprint, manifest.RootFingerprint)
+	}
+	return nil
+}
+
+func decodeManifest(r io.Reader) (Manifest, error) {
+	var manifest Manifest
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&manifest); err != nil {
+		return Manifest{}, fmt.Errorf("%w: decode manifest: %v", ErrInvalidArchive, err)
+	}
+	return manifest, nil
+}
+
+// cleanArchivePath converts an archive member name into a relative path that
+// cannot escape the extraction directory.
+func cleanArchivePath(name string) (string, error) {
+	cleaned := path.Clean(name)
+	if name == "" || path.IsAbs(name) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") || strings.Contains(name, `\`) {
+		return "", fmt.Errorf("%w: unsafe path %q", ErrInvalidArchive, name)
+	}
+	if cleaned == ManifestName {
+		return "", fmt.Errorf("%w: duplicate %s", ErrInvalidArchive, ManifestName)
+	}
+	return filepath.FromSlash(cleaned), nil
+}
+
+func indexModel(stateDir, kind string, settings map[string]string) IndexModel {
+	var out IndexModel
+	// without the marker the model setting is only the seeded default.
+	if settings[index.ModelRecordedSettingKey(kind)] != "" {
+		out.Model = settings[index.ModelSettingKey(kind)]
+	}
+	ix := index.NewHNSWIndex(filepath.Join(stateDir, "vectors_"+kind+".hnsw"))
+	if err := ix.Load(""); err == nil {
+		header := ix.Header()
+		if header.Model != "" {
+			out.Model = header.Model
+		}
+		out.Dim = header.Dim
+	}
+	return out
+}
+
+// reconcileEmbeddings marks chunks of the database in dir that are recorded
+// as embedded but missing from the index files next to it as pending, so a
+// later `up` embeds them again rather than never finding them.
+func reconcileEmbeddings(ctx context.Context, dir string) error {
+	for _, kind := range []string{"text", "code"} {
+		ix := index.NewHNSWIndex(filepath.Join(dir, "vectors_"+kind+".hnsw"))
+		if err := ix.Load(""); err != nil {
+			return fmt.Errorf("load %s index: %w", kind, err)
+		}
+		if _, err := store.ResetUnindexedChunks(ctx, filepath.Join(dir, metaFileName), kind, ix.Contains); err != nil {
+			return fmt.Errorf("reconcile %s embeddings: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func collectDir(stateDir, dir string, sources map[string]string) error {
+	root := filepath.Join(stateDir, filepath.FromSlash(dir))
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && p == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(stateDir, p)
+		if err != nil {
+			return err
+		}
+		sources[filepath.ToSlash(rel)] = p
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("collect %s: %w", dir, err)
+	}
+	return nil
+}
+
+func hashFile(p string) (int64, string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
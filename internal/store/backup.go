@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+// DatabaseInfo summarizes a metadata database for snapshot manifests.
+type DatabaseInfo struct {
+	SchemaVersion int
+	Documents     int64
+	// Fingerprint identifies the indexed corpus independent of where it is
+	// mounted: a sha256 over the sorted (rel_path, content_hash) pairs of all
+	// live documents.
+	Fingerprint string
+	Settings    map[string]string
+	// PendingChunks counts live chunks still waiting for an embedding.
+	PendingChunks int64
+}
+
+// backupConn is the subset of the modernc sqlite driver connection exposing
+// the online backup API.
+type backupConn interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+}
+
+// BackupTo writes a transactionally consistent copy of the database to
+// dstPath using the SQLite online backup API. Unlike copying meta.sqlite on
+// disk it is safe while other connections are writing, because pages that
+// change mid-copy are re-copied until a consistent image is produced.
+func (s *SQLiteStore) BackupTo(ctx context.Context, dstPath string) error {
+	if strings.TrimSpace(dstPath) == "" {
+		return errors.New("backup destination is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
+		return fmt.Errorf("create backup dir: %w", err)
+	}
+	if err := os.Remove(dstPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale backup: %w", err)
+	}
+
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.ReleaseDB()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	return conn.Raw(func(driverConn any) error {
+		source, ok := driverConn.(backupConn)
+		if !ok {
+			return fmt.Errorf("sqlite driver connection %T does not support backups", driverConn)
+		}
+		backup, err := source.NewBackup(dstPath)
+		if err != nil {
+			return fmt.Errorf("start backup: %w", err)
+		}
+		for {
+			if err := ctx.Err(); err != nil {
+				_ = backup.Finish()
+				return err
+			}
+			more, stepErr := backup.Step(256)
+			if stepErr != nil {
+				_ = backup.Finish()
+				return fmt.Errorf("backup step: %w", stepErr)
+			}
+			if !more {
+				break
+			}
+		}
+		return backup.Finish()
+	})
+}
+
+// DescribeDatabase reads schema version, settings and the corpus
+// fingerprint from the database at path without modifying it.
+func DescribeDatabase(ctx context.Context, path string) (DatabaseInfo, error) {
+	info := DatabaseInfo{Settings: map[string]string{}}
+	status, err := InspectSchema(ctx, path)
+	if err != nil {
+		return info, err
+	}
+	info.SchemaVersion = status.Version
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return info, err
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.QueryContext(ctx, `SELECT key, value FROM settings`)
+	if err != nil {
+		return info, err
+	}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			_ = rows.Close()
+			return info, err
+		}
+		info.Settings[key] = value
+	}
+	if err := rows.Close(); err != nil {
+		return info, err
+	}
+	if err := rows.Err(); err != nil {
+		return info, err
+	}
+
+	docs, err := db.QueryContext(ctx, `SELECT rel_path, content_hash FROM documents WHERE deleted = 0 ORDER BY rel_path`)
+	if err != nil {
+		return info, err
+	}
+	defer func() { _ = docs.Close() }()
+	hash := sha256.New()
+	for docs.Next() {
+		var relPath, contentHash string
+		if err := docs.Scan(&relPath, &contentHash); err != nil {
+			return info, err
+		}
+		_, _ = fmt.Fprintf(hash, "%s\t%s\n", relPath, contentHash)
+		info.Documents++
+	}
+	if err := docs.Err(); err != nil {
+		return info, err
+	}
+	info.Fingerprint = hex.EncodeToString(hash.Sum(nil))
+
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM chunks WHERE deleted = 0 AND embedding_status = 'pending'`).Scan(&info.PendingChunks); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// ResetUnindexedChunks marks the live chunks of indexKind that the database
+// at path records as embedded, but that indexed reports missing from the
+// vector index, as pending again. It returns the number of chunks reset.
+func ResetUnindexedChunks(ctx context.Context, path, indexKind string, indexed func(label uint64) bool) (int64, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.QueryContext(ctx, `SELECT chunk_id FROM chunks
+		WHERE deleted = 0 AND embedding_status = 'ok' AND index_kind = ? AND chunk_id > 0`, indexKind)
+	if err != nil {
+		return 0, err
+	}
+	var missing []int64
+	for rows.Next() {
+		var chunkID int64
+		if err := rows.Scan(&chunkID); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		if !indexed(uint64(chunkID)) {
+			missing = append(missing, chunkID)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.PrepareContext(ctx, `UPDATE chunks SET embedding_status = 'pending', embedding_error = '' WHERE chunk_id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, chunkID := range missing {
+		if _, err := stmt.ExecContext(ctx, chunkID); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(missing)), nil
+}
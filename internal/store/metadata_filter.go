@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+// metadataFilterClause renders filter as a SQL condition over chunks aliased
+// c and documents aliased d. It returns an empty condition for a zero filter.
+func metadataFilterClause(filter model.MetadataFilter) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	if filter.ModifiedAfter > 0 {
+		where = append(where, "d.mtime_unix >= ?")
+		args = append(args, filter.ModifiedAfter)
+	}
+	if filter.ModifiedBefore > 0 {
+		where = append(where, "d.mtime_unix < ?")
+		args = append(args, filter.ModifiedBefore)
+	}
+	if filter.MinSizeBytes > 0 {
+		where = append(where, "d.size_bytes >= ?")
+		args = append(args, filter.MinSizeBytes)
+	}
+	if filter.MaxSizeBytes > 0 {
+		where = append(where, "d.size_bytes <= ?")
+		args = append(args, filter.MaxSizeBytes)
+	}
+	addIn := func(column string, values []string, normalize func(string) string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, 0, len(values))
+		for _, value := range values {
+			placeholders = append(placeholders, "?")
+			args = append(args, normalize(value))
+		}
+		where = append(where, column+" IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	addIn("d.status", filter.Statuses, normalizeStatus)
+	addIn("d.source_type", filter.SourceTypes, normalizeSourceType)
+	addIn("c.rep_type", filter.RepTypes, func(v string) string { return strings.ToLower(strings.TrimSpace(v)) })
+	if len(filter.Languages) > 0 {
+		var exts []string
+		for _, language := range filter.Languages {
+			exts = append(exts, ingest.CodeLanguageExtensions(language)...)
+		}
+		if len(exts) == 0 {
+			// no recognized language can match any chunk.
+			where = append(where, "0")
+		} else {
+			alternatives := make([]string, 0, len(exts))
+			for _, ext := range exts {
+				alternatives = append(alternatives, `lower(c.rel_path) LIKE ? ESCAPE '\'`)
+				args = append(args, "%"+escapeLike(ext))
+			}
+			where = append(where, "("+strings.Join(alternatives, " OR ")+")")
+		}
+	}
+	return strings.Join(where, " AND "), args
+}
+
+// ChunkIDsMatching returns the IDs of live chunks whose document and
+// representation satisfy filter. Retrieval uses it to restrict vector
+// scoring to matching chunks before ranking.
+func (s *SQLiteStore) ChunkIDsMatching(ctx context.Context, filter model.MetadataFilter) ([]uint64, error) {
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.ReleaseDB()
+
+	query := `SELECT c.chunk_id
+	          FROM chunks c
+	          JOIN documents d ON d.rel_path = c.rel_path AND d.deleted = 0
+	          WHERE c.deleted = 0 AND c.chunk_id > 0`
+	clause, args := metadataFilterClause(filter)
+	if clause != "" {
+		query += " AND " + clause
+	}
+	query += " ORDER BY c.chunk_id"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]uint64, 0)
+	for rows.Next() {
+		var chunkID int64
+		if err := rows.Scan(&chunkID); err != nil {
+			return nil, err
+		}
+		out = append(out, uint64(chunkID))
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,423 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CurrentSchemaVersion is the newest schema version this build knows how to
+// read and write. It always equals the version of the last entry in
+// migrations.
+const CurrentSchemaVersion = 5
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer build
+// than the one trying to open it. Opening such a database could silently drop
+// or misinterpret data, so the store refuses instead.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build supports")
+
+// MigrationInfo describes a single schema migration.
+type MigrationInfo struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+// SchemaStatus reports the schema version recorded in a database and the
+// migrations this build would apply to it.
+type SchemaStatus struct {
+	Version   int             `json:"version"`
+	Supported int             `json:"supported"`
+	Pending   []MigrationInfo `json:"pending"`
+}
+
+type migration struct {
+	version int
+	name    string
+	apply   func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Entries are append-only: never edit or reorder a migration that has
+// shipped, add a new one instead.
+var migrations = []migration{
+	{version: 1, name: "initial_schema", apply: migrateInitialSchema},
+	{version: 2, name: "documents_source_type", apply: migrateDocumentsSourceType},
+	{version: 3, name: "symbols", apply: migrateSymbols},
+	{version: 4, name: "chunks_fts", apply: migrateChunksFTS},
+	{version: 5, name: "symbols_backfill", apply: migrateSymbolsBackfill},
+}
+
+const schemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  applied_unix INTEGER NOT NULL
+);
+`
+
+func migrateInitialSchema(ctx context.Context, tx *sql.Tx) error {
+	const schema = `
+CREATE TABLE IF NOT EXISTS documents (
+  doc_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  rel_path TEXT NOT NULL UNIQUE,
+  doc_type TEXT NOT NULL,
+  size_bytes INTEGER NOT NULL DEFAULT 0,
+  mtime_unix INTEGER NOT NULL DEFAULT 0,
+  content_hash TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'ok',
+  deleted INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS representations (
+  rep_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  doc_id INTEGER NOT NULL,
+  rep_type TEXT NOT NULL,
+  rep_hash TEXT NOT NULL,
+  created_unix INTEGER NOT NULL,
+  deleted INTEGER NOT NULL DEFAULT 0,
+  UNIQUE(doc_id, rep_type),
+  FOREIGN KEY (doc_id) REFERENCES documents(doc_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS chunks (
+  chunk_id INTEGER PRIMARY KEY,
+  rep_id INTEGER,
+  ordinal INTEGER NOT NULL DEFAULT 0,
+  rel_path TEXT NOT NULL,
+  doc_type TEXT NOT NULL,
+  rep_type TEXT NOT NULL DEFAULT 'raw_text',
+  text TEXT NOT NULL,
+  text_hash TEXT NOT NULL DEFAULT '',
+  tokens_est INTEGER NOT NULL DEFAULT 0,
+  index_kind TEXT NOT NULL DEFAULT 'text',
+  embedding_status TEXT NOT NULL DEFAULT 'pending',
+  embedding_error TEXT NOT NULL DEFAULT '',
+  deleted INTEGER NOT NULL DEFAULT 0,
+  UNIQUE(rep_id, ordinal),
+  FOREIGN KEY (rep_id) REFERENCES representations(rep_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS spans (
+  span_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  chunk_id INTEGER NOT NULL,
+  span_kind TEXT NOT NULL,
+  start INTEGER NOT NULL,
+  end INTEGER NOT NULL,
+  extra_json TEXT,
+  FOREIGN KEY (chunk_id) REFERENCES chunks(chunk_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+  key TEXT PRIMARY KEY,
+  value TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_documents_rel_path ON documents(rel_path);
+CREATE INDEX IF NOT EXISTS idx_documents_deleted ON documents(deleted);
+CREATE INDEX IF NOT EXISTS idx_representations_doc_id ON representations(doc_id);
+CREATE INDEX IF NOT EXISTS idx_chunks_rep_id ON chunks(rep_id);
+CREATE INDEX IF NOT EXISTS idx_chunks_embedding_status ON chunks(embedding_status);
+CREATE INDEX IF NOT EXISTS idx_chunks_index_kind ON chunks(index_kind);
+CREATE INDEX IF NOT EXISTS idx_chunks_rel_path_deleted ON chunks(rel_path, deleted);
+CREATE INDEX IF NOT EXISTS idx_spans_chunk_id_span_id ON spans(chunk_id, span_id);
+`
+	_, err := tx.ExecContext(ctx, schema)
+	return err
+}
+
+func migrateDocumentsSourceType(ctx context.Context, tx *sql.Tx) error {
+	// Builds that predate the migration framework added this column ad hoc,
+	// so databases reporting version 1 may already have it.
+	exists, err := columnExists(ctx, tx, "documents", "source_type")
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `ALTER TABLE documents ADD COLUMN source_type TEXT NOT NULL DEFAULT 'filesystem'`)
+	return err
+}
+
+func migrateSymbols(ctx context.Context, tx *sql.Tx) error {
+	const schema = `
+CREATE TABLE IF NOT EXISTS symbols (
+  symbol_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  doc_id INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  container TEXT NOT NULL DEFAULT '',
+  language TEXT NOT NULL DEFAULT '',
+  start_line INTEGER NOT NULL,
+  end_line INTEGER NOT NULL,
+  signature TEXT NOT NULL DEFAULT '',
+  exact INTEGER NOT NULL DEFAULT 0,
+  FOREIGN KEY (doc_id) REFERENCES documents(doc_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_symbols_doc_id ON symbols(doc_id);
+CREATE INDEX IF NOT EXISTS idx_symbols_name ON symbols(name COLLATE NOCASE);
+`
+	_, err := tx.ExecContext(ctx, schema)
+	return err
+}
+
+// migrateChunksFTS adds a full-text index over chunk text for lexical
+// search. It is an external-content table kept in sync by triggers, so chunk
+// text is stored once. The tokenizer matches retrieval's lexical tokenizer:
+// letters, digits and underscores, case-folded, diacritics kept.
+func migrateChunksFTS(ctx context.Context, tx *sql.Tx) error {
+	// partial legacy layouts without chunks have nothing to index.
+	exists, err := tableExists(ctx, tx, "chunks")
+	if err != nil || !exists {
+		return err
+	}
+	const schema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
+  text,
+  content='chunks',
+  content_rowid='chunk_id',
+  tokenize="unicode61 remove_diacritics 0 tokenchars '_'"
+);
+
+CREATE TRIGGER IF NOT EXISTS chunks_fts_insert AFTER INSERT ON chunks BEGIN
+  INSERT INTO chunks_fts(rowid, text) VALUES (new.chunk_id, new.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS chunks_fts_delete AFTER DELETE ON chunks BEGIN
+  INSERT INTO chunks_fts(chunks_fts, rowid, text) VALUES ('delete', old.chunk_id, old.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS chunks_fts_update AFTER UPDATE OF chunk_id, text ON chunks BEGIN
+  INSERT INTO chunks_fts(chunks_fts, rowid, text) VALUES ('delete', old.chunk_id, old.text);
+  INSERT INTO chunks_fts(rowid, text) VALUES (new.chunk_id, new.text);
+END;
+
+INSERT INTO chunks_fts(chunks_fts) VALUES ('rebuild');
+`
+	_, err = tx.ExecContext(ctx, schema)
+	return err
+}
+
+// migrateSymbolsBackfill makes the next scan re-process code files indexed
+// before symbols existed. Symbols are only extracted when a file's raw text
+// is regenerated, which an unchanged content hash skips, so their hashes are
+// cleared; so are the hashes of archives holding such files, whose members
+// are only re-read with the archive.
+func migrateSymbolsBackfill(ctx context.Context, tx *sql.Tx) error {
+	exists, err := tableExists(ctx, tx, "documents")
+	if err != nil || !exists {
+		return err
+	}
+	const stale = `
+  SELECT m.rel_path FROM documents m
+  WHERE m.deleted = 0 AND m.doc_type = 'code' AND m.content_hash != ''
+    AND NOT EXISTS (SELECT 1 FROM symbols s WHERE s.doc_id = m.doc_id)`
+	_, err = tx.ExecContext(ctx, `
+UPDATE documents SET content_hash = ''
+WHERE deleted = 0 AND doc_type = 'archive' AND EXISTS (
+  SELECT 1 FROM (`+stale+`) c
+  WHERE substr(c.rel_path, 1, length(documents.rel_path) + 1) = documents.rel_path || '/'
+);
+UPDATE documents SET content_hash = ''
+WHERE rel_path IN (`+stale+`);
+`)
+	return err
+}
+
+// migrateLocked brings db up to CurrentSchemaVersion. Each pending migration
+// runs in its own transaction together with its bookkeeping rows, so a
+// failure leaves the database at the last fully applied version.
+func migrateLocked(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, schemaMigrationsTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrationVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+	if version := maxVersion(applied); version > CurrentSchemaVersion {
+		return fmt.Errorf("%w: database version %d, supported version %d", ErrSchemaTooNew, version, CurrentSchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("apply migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// another process may have applied the migration while we were waiting
+	// for the write lock.
+	var count int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.version).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return tx.Commit()
+	}
+
+	if err = m.apply(ctx, tx); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO schema_migrations(version, name, applied_unix) VALUES(?, ?, ?)`,
+		m.version,
+		m.name,
+		time.Now().Unix(),
+	); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO settings(key, value) VALUES('schema_version', ?)
+		 ON CONFLICT(key) DO UPDATE SET value=excluded.value`,
+		strconv.Itoa(m.version),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appliedMigrationVersions returns the set of migration versions recorded in
+// the database. Databases created before schema_migrations existed only carry
+// settings.schema_version, so every version up to that value is treated as
+// applied as well.
+func appliedMigrationVersions(ctx context.Context, exec dbExecutor) (map[int]bool, error) {
+	applied := make(map[int]bool)
+
+	hasTable, err := tableExists(ctx, exec, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if hasTable {
+		rows, err := exec.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			var version int
+			if err := rows.Scan(&version); err != nil {
+				return nil, err
+			}
+			applied[version] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	hasSettings, err := tableExists(ctx, exec, "settings")
+	if err != nil || !hasSettings {
+		return applied, err
+	}
+	var raw string
+	err = exec.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = 'schema_version'`).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return applied, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	legacy, convErr := strconv.Atoi(strings.TrimSpace(raw))
+	if convErr != nil {
+		return nil, fmt.Errorf("invalid schema_version setting %q: %w", raw, convErr)
+	}
+	for v := 1; v <= legacy; v++ {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// InspectSchema reports the schema version of the database at path and the
+// migrations that opening it with this build would apply. Unlike Init it never
+// modifies the database, which makes it suitable for dry runs.
+func InspectSchema(ctx context.Context, path string) (SchemaStatus, error) {
+	status := SchemaStatus{Supported: CurrentSchemaVersion, Pending: []MigrationInfo{}}
+	if _, err := os.Stat(path); err != nil {
+		return status, err
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return status, err
+	}
+	defer func() { _ = db.Close() }()
+
+	applied, err := appliedMigrationVersions(ctx, db)
+	if err != nil {
+		return status, err
+	}
+	status.Version = maxVersion(applied)
+	if status.Version > CurrentSchemaVersion {
+		return status, fmt.Errorf("%w: database version %d, supported version %d", ErrSchemaTooNew, status.Version, CurrentSchemaVersion)
+	}
+	for _, m := range migrations {
+		if !applied[m.version] {
+			status.Pending = append(status.Pending, MigrationInfo{Version: m.version, Name: m.name})
+		}
+	}
+	return status, nil
+}
+
+// InspectSchema is a convenience wrapper around the package-level
+// InspectSchema for the store's database path.
+func (s *SQLiteStore) InspectSchema(ctx context.Context) (SchemaStatus, error) {
+	return InspectSchema(ctx, s.path)
+}
+
+func maxVersion(applied map[int]bool) int {
+	highest := 0
+	for v := range applied {
+		if v > highest {
+			highest = v
+		}
+	}
+	return highest
+}
+
+func tableExists(ctx context.Context, exec dbExecutor, table string) (bool, error) {
+	var count int
+	if err := exec.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func columnExists(ctx context.Context, exec dbExecutor, table, column string) (bool, error) {
+	rows, err := exec.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if strings.EqualFold(name, column) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
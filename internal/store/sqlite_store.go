@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	_ "modernc.org/sqlite"
 
@@ -230,77 +231,7 @@ func (s *SQLiteStore) initLocked(ctx context.Context) error {
 		return err
 	}
 
-	schema := `
-CREATE TABLE IF NOT EXISTS documents (
-  doc_id INTEGER PRIMARY KEY AUTOINCREMENT,
-  rel_path TEXT NOT NULL UNIQUE,
-  doc_type TEXT NOT NULL,
-  source_type TEXT NOT NULL DEFAULT 'filesystem',
-  size_bytes INTEGER NOT NULL DEFAULT 0,
-  mtime_unix INTEGER NOT NULL DEFAULT 0,
-  content_hash TEXT NOT NULL DEFAULT '',
-  status TEXT NOT NULL DEFAULT 'ok',
-  deleted INTEGER NOT NULL DEFAULT 0
-);
-
-CREATE TABLE IF NOT EXISTS representations (
-  rep_id INTEGER PRIMARY KEY AUTOINCREMENT,
-  doc_id INTEGER NOT NULL,
-  rep_type TEXT NOT NULL,
-  rep_hash TEXT NOT NULL,
-  created_unix INTEGER NOT NULL,
-  deleted INTEGER NOT NULL DEFAULT 0,
-  UNIQUE(doc_id, rep_type),
-  FOREIGN KEY (doc_id) REFERENCES documents(doc_id) ON DELETE CASCADE
-);
-
-CREATE TABLE IF NOT EXISTS chunks (
-  chunk_id INTEGER PRIMARY KEY,
-  rep_id INTEGER,
-  ordinal INTEGER NOT NULL DEFAULT 0,
-  rel_path TEXT NOT NULL,
-  doc_type TEXT NOT NULL,
-  rep_type TEXT NOT NULL DEFAULT 'raw_text',
-  text TEXT NOT NULL,
-  text_hash TEXT NOT NULL DEFAULT '',
-  tokens_est INTEGER NOT NULL DEFAULT 0,
-  index_kind TEXT NOT NULL DEFAULT 'text',
-  embedding_status TEXT NOT NULL DEFAULT 'pending',
-  embedding_error TEXT NOT NULL DEFAULT '',
-  deleted INTEGER NOT NULL DEFAULT 0,
-  UNIQUE(rep_id, ordinal),
-  FOREIGN KEY (rep_id) REFERENCES representations(rep_id) ON DELETE CASCADE
-);
-
-CREATE TABLE IF NOT EXISTS spans (
-  span_id INTEGER PRIMARY KEY AUTOINCREMENT,
-  chunk_id INTEGER NOT NULL,
-  span_kind TEXT NOT NULL,
-  start INTEGER NOT NULL,
-  end INTEGER NOT NULL,
-  extra_json TEXT,
-  FOREIGN KEY (chunk_id) REFERENCES chunks(chunk_id) ON DELETE CASCADE
-);
-
-CREATE TABLE IF NOT EXISTS settings (
-  key TEXT PRIMARY KEY,
-  value TEXT NOT NULL
-);
-
-CREATE INDEX IF NOT EXISTS idx_documents_rel_path ON documents(rel_path);
-CREATE INDEX IF NOT EXISTS idx_documents_deleted ON documents(deleted);
-CREATE INDEX IF NOT EXISTS idx_representations_doc_id ON representations(doc_id);
-CREATE INDEX IF NOT EXISTS idx_chunks_rep_id ON chunks(rep_id);
-CREATE INDEX IF NOT EXISTS idx_chunks_embedding_status ON chunks(embedding_status);
-CREATE INDEX IF NOT EXISTS idx_chunks_index_kind ON chunks(index_kind);
-CREATE INDEX IF NOT EXISTS idx_chunks_rel_path_deleted ON chunks(rel_path, deleted);
-CREATE INDEX IF NOT EXISTS idx_spans_chunk_id_span_id ON spans(chunk_id, span_id);
-`
-	if _, err := db.ExecContext(ctx, schema); err != nil {
-		_ = db.Close()
-		return err
-	}
-	if _, err := db.ExecContext(ctx, `ALTER TABLE documents ADD COLUMN source_type TEXT NOT NULL DEFAULT 'filesystem'`); err != nil && !isDuplicateColumnError(err) {
+	if err := migrateLocked(ctx, db); err != nil {
 		_ = db.Close()
 		return err
 	}
@@ -483,6 +414,31 @@ func (s *SQLiteStore) ClearDocumentContentHashes(ctx context.Context) error {
 	return err
 }
 
+// ResetEmbeddingStatus marks every live chunk of indexKind as pending again so
+// the embedding workers re-embed it, e.g. after the configured embedding model
+// changed. An empty indexKind resets all kinds. It returns the number of
+// chunks reset.
+func (s *SQLiteStore) ResetEmbeddingStatus(ctx context.Context, indexKind string) (int64, error) {
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer s.ReleaseDB()
+
+	query := `UPDATE chunks SET embedding_status = 'pending', embedding_error = ''
+	          WHERE deleted = 0 AND embedding_status <> 'pending'`
+	args := []any{}
+	if indexKind != "" {
+		query += ` AND index_kind = ?`
+		args = append(args, indexKind)
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // WithTx begins a new database transaction and passes a transaction-bound
 // representation store to the supplied callback. If the callback returns an
 // error the transaction is rolled back; otherwise it is committed.  The
@@ -810,6 +766,57 @@ func (s *SQLiteStore) ListFiles(ctx context.Context, prefix, glob string, limit,
 	return docs, total, nil
 }
 
+// ListDocumentsWithRepresentation returns the live documents under prefix
+// and matching glob that have a live representation of repType, ordered by
+// rel_path.
+func (s *SQLiteStore) ListDocumentsWithRepresentation(ctx context.Context, repType, prefix, glob string) ([]model.Document, error) {
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.ReleaseDB()
+
+	query := `SELECT d.doc_id, d.rel_path, d.doc_type, d.source_type, d.size_bytes, d.mtime_unix, d.content_hash, d.status
+	          FROM documents d
+	          JOIN representations r ON r.doc_id = d.doc_id AND r.deleted = 0 AND r.rep_type = ?
+	          WHERE d.deleted = 0`
+	args := []any{strings.ToLower(strings.TrimSpace(repType))}
+	if normalizedPrefix := normalizePrefix(prefix); normalizedPrefix != "" {
+		query += ` AND d.rel_path LIKE ? ESCAPE '\'`
+		args = append(args, escapeLike(normalizedPrefix)+"%")
+	}
+	if strings.TrimSpace(glob) != "" {
+		query += " AND d.rel_path GLOB ?"
+		args = append(args, glob)
+	}
+	query += " ORDER BY d.rel_path"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	docs := make([]model.Document, 0)
+	for rows.Next() {
+		var doc model.Document
+		if err := rows.Scan(
+			&doc.DocID,
+			&doc.RelPath,
+			&doc.DocType,
+			&doc.SourceType,
+			&doc.SizeBytes,
+			&doc.MTimeUnix,
+			&doc.ContentHash,
+			&doc.Status,
+		); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
 // ActiveDocCounts returns active-document counts grouped by doc_type along
 // with the total active document count using aggregate SQL queries.  The
 // implementation simply obtains a database handle and delegates to
@@ -876,6 +883,37 @@ func (s *SQLiteStore) CorpusStats(ctx context.Context) (model.CorpusStats, error
 	return stats, nil
 }
 
+// PendingChunkCounts returns how many live chunks still wait for an
+// embedding, keyed by index kind.
+func (s *SQLiteStore) PendingChunkCounts(ctx context.Context) (map[string]int64, error) {
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.ReleaseDB()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT index_kind, COUNT(*)
+		FROM chunks
+		WHERE deleted = 0 AND embedding_status = 'pending'
+		GROUP BY index_kind`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var kind string
+		var n int64
+		if err := rows.Scan(&kind, &n); err != nil {
+			return nil, err
+		}
+		counts[kind] = n
+	}
+	return counts, rows.Err()
+}
+
 func (s *SQLiteStore) NextPending(ctx context.Context, limit int, indexKind string) ([]model.ChunkTask, error) {
 	db, err := s.ensureDB(ctx)
 	if err != nil {
@@ -1028,6 +1066,189 @@ func (s *SQLiteStore) ListEmbeddedChunkMetadata(ctx context.Context, indexKind s
 	return out, rows.Err()
 }
 
+// SearchChunksLexical returns up to limit active chunks containing at least
+// one of the supplied terms as a whole token, best BM25 match first, with
+// their corpus-wide BM25 score in LexicalScore. Terms are matched
+// case-insensitively.
+func (s *SQLiteStore) SearchChunksLexical(ctx context.Context, terms []string, limit int) ([]model.ChunkTask, error) {
+	return s.SearchChunksLexicalFiltered(ctx, terms, limit, model.SearchQuery{})
+}
+
+// SearchChunksLexicalFiltered is SearchChunksLexical restricted to chunks
+// matching the path prefix, file glob, doc types and metadata filter of
+// query. Filters and ranking are applied before the limit, so the best
+// matching chunks fill the candidate pool however many weaker ones precede
+// them.
+func (s *SQLiteStore) SearchChunksLexicalFiltered(ctx context.Context, terms []string, limit int, query model.SearchQuery) ([]model.ChunkTask, error) {
+	match := lexicalMatchExpression(terms)
+	if match == "" {
+		return []model.ChunkTask{}, nil
+	}
+
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.ReleaseDB()
+	if limit <= 0 {
+		limit = 500
+	}
+	join := ""
+	where := "chunks_fts MATCH ? AND c.deleted = 0 AND c.chunk_id > 0"
+	args := []any{match}
+	if query.PathPrefix != "" {
+		where += " AND instr(c.rel_path, ?) = 1"
+		args = append(args, query.PathPrefix)
+	}
+	if strings.TrimSpace(query.FileGlob) != "" {
+		// GLOB lets * and ? cross '/', so it can only widen path.Match; the
+		// retrieval layer checks the exact glob on the ranked hits.
+		where += " AND c.rel_path GLOB ?"
+		args = append(args, query.FileGlob)
+	}
+	if len(query.DocTypes) > 0 {
+		placeholders := make([]string, 0, len(query.DocTypes))
+		for _, docType := range query.DocTypes {
+			placeholders = append(placeholders, "?")
+			args = append(args, strings.ToLower(strings.TrimSpace(docType)))
+		}
+		where += " AND lower(trim(c.doc_type)) IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if !query.MetadataFilter.IsZero() {
+		join = " JOIN documents d ON d.rel_path = c.rel_path AND d.deleted = 0"
+		clause, filterArgs := metadataFilterClause(query.MetadataFilter)
+		where += " AND " + clause
+		args = append(args, filterArgs...)
+	}
+	args = append(args, limit)
+
+	sqlQuery := `WITH filtered_chunks AS (
+	            SELECT c.chunk_id, c.rel_path, c.doc_type, c.rep_type, c.text, c.index_kind,
+	                   bm25(chunks_fts) AS rank
+	            FROM chunks_fts
+	            JOIN chunks c ON c.chunk_id = chunks_fts.rowid` + join + `
+	            WHERE ` + where + `
+	            ORDER BY rank, c.chunk_id
+	            LIMIT ?
+	          ),
+	          ranked_spans AS (
+	            SELECT s.chunk_id, s.span_kind, s.start, s."end",
+	                   ROW_NUMBER() OVER (PARTITION BY s.chunk_id ORDER BY s.span_id) AS rn
+	            FROM spans s
+	            JOIN filtered_chunks fc ON fc.chunk_id = s.chunk_id
+	          )
+	          SELECT fc.chunk_id, fc.rel_path, fc.doc_type, fc.rep_type, fc.text, fc.index_kind, fc.rank,
+	                 COALESCE(sp.span_kind, ''), COALESCE(sp.start, 0), COALESCE(sp.end, 0)
+	          FROM filtered_chunks fc
+	          LEFT JOIN ranked_spans sp ON sp.chunk_id = fc.chunk_id AND sp.rn = 1
+	          ORDER BY fc.rank, fc.chunk_id`
+
+	rows, err := db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]model.ChunkTask, 0)
+	for rows.Next() {
+		var (
+			chunkID int64
+			relPath string
+			docType string
+			repType string
+			text    string
+			kind    string
+			rank    float64
+			spanK   string
+			spanS   int
+			spanE   int
+		)
+		if err := rows.Scan(&chunkID, &relPath, &docType, &repType, &text, &kind, &rank, &spanK, &spanS, &spanE); err != nil {
+			return nil, err
+		}
+		uid := uint64(chunkID)
+		out = append(out, model.ChunkTask{
+			Label:     uid,
+			Text:      text,
+			IndexKind: kind,
+			Metadata: model.ChunkMetadata{
+				ChunkID: uid,
+				RelPath: relPath,
+				DocType: docType,
+				RepType: repType,
+				Snippet: snippet(text, 240),
+				Span:    spanFromRow(spanK, spanS, spanE),
+			},
+			// bm25() is lower for better matches.
+			LexicalScore: -rank,
+		})
+	}
+	return out, rows.Err()
+}
+
+// ChunkNeighborhood returns the live chunk identified by chunkID together
+// with up to radius live chunks on either side of it in the same
+// representation, ordered by ordinal. Chunks carry their full text. An
+// unknown or deleted chunk yields an empty slice.
+func (s *SQLiteStore) ChunkNeighborhood(ctx context.Context, chunkID uint64, radius int) ([]model.ContextChunk, error) {
+	if radius < 0 {
+		radius = 0
+	}
+
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.ReleaseDB()
+
+	rows, err := db.QueryContext(
+		ctx,
+		`WITH anchor AS (
+		   SELECT rep_id, ordinal FROM chunks WHERE chunk_id = ? AND deleted = 0
+		 ),
+		 neighborhood AS (
+		   SELECT c.chunk_id, c.rep_id, c.ordinal, c.rel_path, c.text
+		   FROM chunks c
+		   JOIN anchor a ON a.rep_id = c.rep_id
+		   WHERE c.deleted = 0 AND c.ordinal BETWEEN a.ordinal - ? AND a.ordinal + ?
+		 ),
+		 ranked_spans AS (
+		   SELECT s.chunk_id, s.span_kind, s.start, s."end",
+		          ROW_NUMBER() OVER (PARTITION BY s.chunk_id ORDER BY s.span_id) AS rn
+		   FROM spans s
+		   JOIN neighborhood n ON n.chunk_id = s.chunk_id
+		 )
+		 SELECT n.chunk_id, n.rep_id, n.ordinal, n.rel_path, n.text,
+		        COALESCE(sp.span_kind, ''), COALESCE(sp.start, 0), COALESCE(sp."end", 0)
+		 FROM neighborhood n
+		 LEFT JOIN ranked_spans sp ON sp.chunk_id = n.chunk_id AND sp.rn = 1
+		 ORDER BY n.ordinal`,
+		int64(chunkID), radius, radius,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]model.ContextChunk, 0, 2*radius+1)
+	for rows.Next() {
+		var (
+			chunk model.ContextChunk
+			id    int64
+			spanK string
+			spanS int
+			spanE int
+		)
+		if err := rows.Scan(&id, &chunk.RepID, &chunk.Ordinal, &chunk.RelPath, &chunk.Text, &spanK, &spanS, &spanE); err != nil {
+			return nil, err
+		}
+		chunk.ChunkID = uint64(id)
+		chunk.Span = spanFromRow(spanK, spanS, spanE)
+		out = append(out, chunk)
+	}
+	return out, rows.Err()
+}
+
 func spanFromRow(kind string, start, end int) model.Span {
 	switch strings.ToLower(strings.TrimSpace(kind)) {
 	case "page":
@@ -1173,7 +1394,6 @@ func (s *SQLiteStore) ReleaseDB() {
 
 func bootstrapSettingsLocked(ctx context.Context, db *sql.DB) error {
 	defaults := map[string]string{
-		"schema_version":       "1",
 		"protocol_version":     "2025-11-25",
 		"index_format_version": "1",
 		"embed_text_model":     "mistral-embed",
@@ -1258,13 +1478,6 @@ func normalizeSourceType(sourceType string) string {
 	}
 }
 
-func isDuplicateColumnError(err error) bool {
-	if err == nil {
-		return false
-	}
-	return strings.Contains(strings.ToLower(err.Error()), "duplicate column name")
-}
-
 func normalizeIndexKind(indexKind string) string {
 	switch strings.ToLower(strings.TrimSpace(indexKind)) {
 	case "code":
@@ -1321,6 +1534,25 @@ func defaultIfEmpty(v, fallback string) string {
 	return v
 }
 
+// lexicalMatchExpression renders terms as an FTS5 query matching any of
+// them. Each term is quoted so FTS5 operators in it are taken literally;
+// terms without a token character cannot match and are dropped.
+func lexicalMatchExpression(terms []string) string {
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if strings.IndexFunc(term, isLexicalTokenRune) < 0 {
+			continue
+		}
+		phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	return strings.Join(phrases, " OR ")
+}
+
+func isLexicalTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
 func escapeLike(v string) string {
 	v = strings.ReplaceAll(v, `\\`, `\\\\`)
 	v = strings.ReplaceAll(v, `%`, `\\%`)
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+// defaultSymbolLimit applies when a symbol query leaves Limit unset.
+const defaultSymbolLimit = 100
+
+// ReplaceSymbols swaps every symbol recorded for docID for symbols.
+func (s *SQLiteStore) ReplaceSymbols(ctx context.Context, docID int64, symbols []model.Symbol) error {
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.ReleaseDB()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := replaceSymbolsWith(ctx, tx, docID, symbols); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceSymbols on a transaction-bound store writes through the open
+// transaction so symbols change together with the document's chunks.
+func (t *txSQLiteStore) ReplaceSymbols(ctx context.Context, docID int64, symbols []model.Symbol) error {
+	return replaceSymbolsWith(ctx, t.tx, docID, symbols)
+}
+
+// SearchSymbols on a transaction-bound store delegates to the parent store;
+// it is only implemented so the transaction satisfies model.SymbolStore.
+func (t *txSQLiteStore) SearchSymbols(ctx context.Context, query model.SymbolQuery) ([]model.Symbol, error) {
+	return t.parent.SearchSymbols(ctx, query)
+}
+
+func replaceSymbolsWith(ctx context.Context, exec dbExecutor, docID int64, symbols []model.Symbol) error {
+	if docID <= 0 {
+		return errors.New("doc_id must be > 0")
+	}
+	if _, err := exec.ExecContext(ctx, `DELETE FROM symbols WHERE doc_id = ?`, docID); err != nil {
+		return err
+	}
+	for _, sym := range symbols {
+		if strings.TrimSpace(sym.Name) == "" {
+			continue
+		}
+		exact := 0
+		if sym.Exact {
+			exact = 1
+		}
+		if _, err := exec.ExecContext(
+			ctx,
+			`INSERT INTO symbols(doc_id, name, kind, container, language, start_line, end_line, signature, exact)
+			 VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			docID,
+			sym.Name,
+			sym.Kind,
+			sym.Container,
+			sym.Language,
+			sym.Span.StartLine,
+			sym.Span.EndLine,
+			sym.Signature,
+			exact,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchSymbols returns the symbols of live documents matching query. Exact
+// name matches sort first, then shorter names, then path and line order.
+func (s *SQLiteStore) SearchSymbols(ctx context.Context, query model.SymbolQuery) ([]model.Symbol, error) {
+	db, err := s.ensureDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.ReleaseDB()
+
+	name := strings.TrimSpace(query.Name)
+	sqlQuery := `SELECT s.symbol_id, s.doc_id, d.rel_path, s.name, s.kind, s.container, s.language,
+	                    s.start_line, s.end_line, s.signature, s.exact
+	             FROM symbols s
+	             JOIN documents d ON d.doc_id = s.doc_id
+	             WHERE d.deleted = 0`
+	var args []any
+	if name != "" {
+		if query.Exact {
+			sqlQuery += ` AND s.name = ? COLLATE NOCASE`
+			args = append(args, name)
+		} else {
+			sqlQuery += ` AND s.name LIKE ? ESCAPE '\'`
+			args = append(args, "%"+escapeLike(name)+"%")
+		}
+	}
+	if len(query.Kinds) > 0 {
+		placeholders := make([]string, 0, len(query.Kinds))
+		for _, kind := range query.Kinds {
+			placeholders = append(placeholders, "?")
+			args = append(args, strings.ToLower(strings.TrimSpace(kind)))
+		}
+		sqlQuery += ` AND s.kind IN (` + strings.Join(placeholders, ",") + `)`
+	}
+	if container := strings.TrimSpace(query.Container); container != "" {
+		sqlQuery += ` AND s.container = ? COLLATE NOCASE`
+		args = append(args, container)
+	}
+	if language := strings.TrimSpace(query.Language); language != "" {
+		if canonical := ingest.NormalizeCodeLanguage(language); canonical != "" {
+			language = canonical
+		}
+		sqlQuery += ` AND s.language = ?`
+		args = append(args, strings.ToLower(language))
+	}
+	if normalizedPrefix := normalizePrefix(query.PathPrefix); normalizedPrefix != "" {
+		sqlQuery += ` AND d.rel_path LIKE ? ESCAPE '\'`
+		args = append(args, escapeLike(normalizedPrefix)+"%")
+	}
+	if strings.TrimSpace(query.FileGlob) != "" {
+		sqlQuery += ` AND d.rel_path GLOB ?`
+		args = append(args, query.FileGlob)
+	}
+	sqlQuery += ` ORDER BY (s.name = ? COLLATE NOCASE) DESC, length(s.name), d.rel_path, s.start_line, s.symbol_id LIMIT ?`
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultSymbolLimit
+	}
+	args = append(args, name, limit)
+
+	rows, err := db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	symbols := make([]model.Symbol, 0)
+	for rows.Next() {
+		var (
+			sym   model.Symbol
+			exact int
+		)
+		if err := rows.Scan(
+			&sym.SymbolID,
+			&sym.DocID,
+			&sym.RelPath,
+			&sym.Name,
+			&sym.Kind,
+			&sym.Container,
+			&sym.Language,
+			&sym.Span.StartLine,
+			&sym.Span.EndLine,
+			&sym.Signature,
+			&exact,
+		); err != nil {
+			return nil, err
+		}
+		sym.Span.Kind = "lines"
+		sym.Exact = exact != 0
+		symbols = append(symbols, sym)
+	}
+	return symbols, rows.Err()
+}
@@ -0,0 +1,362 @@
+// Package tokens manages scoped API tokens for the MCP server. Each token
+// has a name and scopes: the tools it may call, the paths it may see, an
+// expiry and a rate limit. Only SHA-256 hashes of token secrets are written
+// to disk; a secret is shown once, when the token is created.
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dir2mcp/internal/ingest"
+)
+
+// FileName is the token file inside the state directory.
+const FileName = "tokens.json"
+
+const (
+	fileVersion  = 1
+	secretPrefix = "d2m_"
+	idPrefix     = "tok_"
+	hashPrefix   = "sha256:"
+)
+
+var (
+	ErrUnknownToken = errors.New("unknown token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrTokenRevoked = errors.New("token revoked")
+	ErrNotFound     = errors.New("no such token")
+)
+
+// Token is one named token and its scopes. Empty scope lists allow
+// everything; a zero ExpiresAt never expires and a zero RateLimitRPS is
+// unlimited.
+type Token struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Hash         string   `json:"hash"`
+	Tools        []string `json:"tools,omitempty"`
+	PathPrefixes []string `json:"path_prefixes,omitempty"`
+	PathGlobs    []string `json:"path_globs,omitempty"`
+	// RateLimitRPS and RateLimitBurst bound requests made with the token,
+	// across all of its sessions and client addresses.
+	RateLimitRPS   float64   `json:"rate_limit_rps,omitempty"`
+	RateLimitBurst int       `json:"rate_limit_burst,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at,omitempty"`
+	RevokedAt      time.Time `json:"revoked_at,omitempty"`
+}
+
+// Expired reports whether the token has expired at now.
+func (t Token) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// Revoked reports whether the token has been revoked.
+func (t Token) Revoked() bool {
+	return !t.RevokedAt.IsZero()
+}
+
+// AllowsTool reports whether the token may call tool.
+func (t Token) AllowsTool(tool string) bool {
+	if len(t.Tools) == 0 {
+		return true
+	}
+	for _, allowed := range t.Tools {
+		if allowed == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// PathScoped reports whether the token is limited to some paths.
+func (t Token) PathScoped() bool {
+	return len(t.PathPrefixes) > 0 || len(t.PathGlobs) > 0
+}
+
+// AllowsPath reports whether relPath is inside one of the token's path
+// prefixes or matches one of its globs. relPath is cleaned first and a path
+// that leaves the root is never allowed. Prefixes match whole segments:
+// "docs" allows "docs/a.md" but not "docs-private/a.md".
+func (t Token) AllowsPath(relPath string) bool {
+	if !t.PathScoped() {
+		return true
+	}
+	relPath, ok := cleanRelPath(relPath)
+	if !ok {
+		return false
+	}
+	for _, prefix := range t.PathPrefixes {
+		if withinPrefix(relPath, prefix) {
+			return true
+		}
+	}
+	for _, glob := range t.PathGlobs {
+		if ingest.MatchesGlobPattern(relPath, glob) {
+			return true
+		}
+	}
+	return false
+}
+
+// File is the on-disk token file.
+type File struct {
+	Version int     `json:"version"`
+	Tokens  []Token `json:"tokens"`
+}
+
+// Load reads the token file at path. A missing file holds no tokens.
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return File{Version: fileVersion}, nil
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("read token file: %w", err)
+	}
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return File{}, fmt.Errorf("parse token file %s: %w", path, err)
+	}
+	if file.Version != fileVersion {
+		return File{}, fmt.Errorf("token file %s has unsupported version %d", path, file.Version)
+	}
+	return file, nil
+}
+
+// Save writes file to path, readable only by its owner.
+func Save(path string, file File) error {
+	file.Version = fileVersion
+	if file.Tokens == nil {
+		file.Tokens = []Token{}
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode token file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create token dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tokens-*.json")
+	if err != nil {
+		return fmt.Errorf("write token file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write token file: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write token file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write token file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write token file: %w", err)
+	}
+	return nil
+}
+
+// Create adds a token built from spec, which carries the name and scopes,
+// and returns it with its secret. Names must be unique among tokens that
+// have not been revoked.
+func (f *File) Create(spec Token, now time.Time) (Token, string, error) {
+	name := strings.TrimSpace(spec.Name)
+	if name == "" {
+		return Token{}, "", errors.New("token name is required")
+	}
+	for _, existing := range f.Tokens {
+		if existing.Name == name && !existing.Revoked() {
+			return Token{}, "", fmt.Errorf("a token named %q already exists", name)
+		}
+	}
+	if spec.RateLimitRPS < 0 || spec.RateLimitBurst < 0 {
+		return Token{}, "", errors.New("rate limits must not be negative")
+	}
+	if !spec.ExpiresAt.IsZero() && !spec.ExpiresAt.After(now) {
+		return Token{}, "", errors.New("expiry must be in the future")
+	}
+
+	id, err := randomHex(6)
+	if err != nil {
+		return Token{}, "", err
+	}
+	secretBody, err := randomHex(32)
+	if err != nil {
+		return Token{}, "", err
+	}
+	secret := secretPrefix + secretBody
+
+	token := spec
+	token.ID = idPrefix + id
+	token.Name = name
+	token.Hash = HashSecret(secret)
+	token.Tools = normalizeList(spec.Tools, nil)
+	token.PathPrefixes = normalizeList(spec.PathPrefixes, normalizePathPrefix)
+	token.PathGlobs = normalizeList(spec.PathGlobs, nil)
+	if token.RateLimitRPS > 0 && token.RateLimitBurst == 0 {
+		token.RateLimitBurst = int(token.RateLimitRPS) + 1
+	}
+	token.CreatedAt = now.UTC()
+	token.RevokedAt = time.Time{}
+	if !token.ExpiresAt.IsZero() {
+		token.ExpiresAt = token.ExpiresAt.UTC()
+	}
+	f.Tokens = append(f.Tokens, token)
+	return token, secret, nil
+}
+
+// Revoke marks the token whose ID or name is ref as revoked.
+func (f *File) Revoke(ref string, now time.Time) (Token, error) {
+	ref = strings.TrimSpace(ref)
+	for i, token := range f.Tokens {
+		if token.Revoked() || (token.ID != ref && token.Name != ref) {
+			continue
+		}
+		f.Tokens[i].RevokedAt = now.UTC()
+		return f.Tokens[i], nil
+	}
+	return Token{}, fmt.Errorf("%w: %s", ErrNotFound, ref)
+}
+
+// Lookup returns the token whose secret is secret, revoked or not.
+func (f File) Lookup(secret string) (Token, bool) {
+	hash := []byte(HashSecret(secret))
+	for _, token := range f.Tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+// HashSecret returns the form in which a secret is stored.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(secret)))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// Store serves authentication from a token file. It re-reads the file when
+// it changes, so tokens created or revoked while the server runs take
+// effect without a restart.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	file    File
+	err     error
+}
+
+// NewStore returns a Store for the token file at path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Authenticate returns the active token whose secret is secret.
+func (s *Store) Authenticate(secret string, now time.Time) (Token, error) {
+	file, err := s.current()
+	if err != nil {
+		return Token{}, err
+	}
+	token, ok := file.Lookup(secret)
+	switch {
+	case !ok:
+		return Token{}, ErrUnknownToken
+	case token.Revoked():
+		return Token{}, ErrTokenRevoked
+	case token.Expired(now):
+		return Token{}, ErrTokenExpired
+	}
+	return token, nil
+}
+
+func (s *Store) current() (File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.file, s.err, s.modTime, s.size = File{Version: fileVersion}, nil, time.Time{}, 0
+		return s.file, nil
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("stat token file: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size && (s.file.Version != 0 || s.err != nil) {
+		return s.file, s.err
+	}
+	s.file, s.err = Load(s.path)
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return s.file, s.err
+}
+
+func normalizeList(values []string, normalize func(string) string) []string {
+	var out []string
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if normalize != nil {
+			value = normalize(value)
+		}
+		if value == "" {
+			continue
+		}
+		if _, dup := seen[value]; dup {
+			continue
+		}
+		seen[value] = struct{}{}
+		out = append(out, value)
+	}
+	return out
+}
+
+// cleanRelPath returns relPath cleaned and in slash form. It reports false
+// for absolute paths and paths that climb out of the root with "..".
+func cleanRelPath(relPath string) (string, bool) {
+	relPath = filepath.ToSlash(strings.TrimSpace(relPath))
+	if strings.HasPrefix(relPath, "/") || filepath.IsAbs(relPath) || filepath.VolumeName(relPath) != "" {
+		return "", false
+	}
+	relPath = path.Clean(relPath)
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", false
+	}
+	return relPath, true
+}
+
+// withinPrefix reports whether the clean relPath is prefix or lies below
+// it. An empty or "." prefix covers the whole root.
+func withinPrefix(relPath, prefix string) bool {
+	prefix = path.Clean(normalizePathPrefix(prefix))
+	if prefix == "." {
+		return true
+	}
+	return relPath == prefix || strings.HasPrefix(relPath, prefix+"/")
+}
+
+func normalizePathPrefix(prefix string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(prefix), "./"), "/")
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"dir2mcp/internal/audit"
+)
+
+func appendEntries(t *testing.T, logger *audit.Logger, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		err := logger.Append(audit.Entry{
+			Session:  "sess",
+			Method:   "tools/call",
+			Tool:     "dir2mcp.open_file",
+			Refs:     []audit.Ref{{RelPath: "docs/a.md", Span: &audit.Span{Kind: "lines", StartLine: 1, EndLine: 3}}},
+			Status:   "ok",
+			ClientIP: "127.0.0.1",
+		})
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+}
+
+func TestAuditLogChainsAcrossRotationAndReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), audit.DirName)
+	logger, err := audit.Open(dir, 600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	appendEntries(t, logger, 5)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// reopening resumes the sequence and the chain.
+	logger, err = audit.Open(dir, 600)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	appendEntries(t, logger, 3)
+	_ = logger.Close()
+
+	report, err := audit.Verify(dir)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.Entries != 8 || report.FirstSeq != 1 || report.LastSeq != 8 || report.Truncated {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.Files) < 2 || report.Files[len(report.Files)-1] != audit.ActiveFileName {
+		t.Fatalf("expected rotated files before the active file, got %v", report.Files)
+	}
+}
+
+func TestAuditVerifyDetectsTampering(t *testing.T) {
+	newLog := func(t *testing.T) (string, [][]byte) {
+		dir := filepath.Join(t.TempDir(), audit.DirName)
+		logger, err := audit.Open(dir, 0)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		appendEntries(t, logger, 4)
+		_ = logger.Close()
+		raw, err := os.ReadFile(filepath.Join(dir, audit.ActiveFileName))
+		if err != nil {
+			t.Fatalf("read log: %v", err)
+		}
+		return dir, bytes.Split(bytes.TrimRight(raw, "\n"), []byte("\n"))
+	}
+	write := func(t *testing.T, dir string, lines [][]byte) {
+		raw := append(bytes.Join(lines, []byte("\n")), '\n')
+		if err := os.WriteFile(filepath.Join(dir, audit.ActiveFileName), raw, 0o600); err != nil {
+			t.Fatalf("write log: %v", err)
+		}
+	}
+
+	cases := map[string]func([][]byte) [][]byte{
+		"edited entry": func(lines [][]byte) [][]byte {
+			lines[1] = bytes.Replace(lines[1], []byte("docs/a.md"), []byte("docs/b.md"), 1)
+			return lines
+		},
+		"removed entry": func(lines [][]byte) [][]byte {
+			return append(lines[:1:1], lines[2:]...)
+		},
+		"reordered entries": func(lines [][]byte) [][]byte {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		},
+	}
+	for name, tamper := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, lines := newLog(t)
+			write(t, dir, tamper(lines))
+			_, err := audit.Verify(dir)
+			var chainErr *audit.ChainError
+			if !errors.As(err, &chainErr) || chainErr.Line != 2 {
+				t.Fatalf("err=%v want a chain error at line 2", err)
+			}
+		})
+	}
+}
+
+func TestAuditLogWritersSharingADirectoryKeepOneChain(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), audit.DirName)
+	// two loggers stand in for two server processes on one state dir.
+	var loggers []*audit.Logger
+	for i := 0; i < 2; i++ {
+		logger, err := audit.Open(dir, 1000)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		defer func() { _ = logger.Close() }()
+		loggers = append(loggers, logger)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(loggers))
+	for _, logger := range loggers {
+		wg.Add(1)
+		go func(logger *audit.Logger) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := logger.Append(audit.Entry{Method: "tools/list", Status: "ok"}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(logger)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("append: %v", err)
+	}
+
+	report, err := audit.Verify(dir)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.Entries != 40 || report.FirstSeq != 1 || report.LastSeq != 40 || len(report.Files) < 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"dir2mcp/internal/audit"
 	"dir2mcp/internal/cli"
 	"dir2mcp/internal/config"
 	"dir2mcp/internal/mcp"
@@ -269,6 +271,77 @@ func TestStatusFallsBackToComputedSnapshot(t *testing.T) {
 	}
 }
 
+func TestStatusDryRunListsPendingMigrations(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, ".dir2mcp")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state dir: %v", err)
+	}
+	metaPath := filepath.Join(stateDir, "meta.sqlite")
+	db, err := sql.Open("sqlite", metaPath)
+	if err != nil {
+		t.Fatalf("open raw db: %v", err)
+	}
+	// pre-migration layout: settings only records schema_version=1.
+	if _, err := db.Exec(`CREATE TABLE settings (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+INSERT INTO settings(key, value) VALUES('schema_version', '1');`); err != nil {
+		t.Fatalf("seed legacy db: %v", err)
+	}
+	_ = db.Close()
+
+	var stdout, stderr bytes.Buffer
+	app := cli.NewAppWithIO(&stdout, &stderr)
+	withWorkingDir(t, tmp, func() {
+		code := app.RunWithContext(context.Background(), []string{"status", "--dry-run"})
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d stderr=%s", code, stderr.String())
+		}
+	})
+
+	out := stdout.String()
+	if !strings.Contains(out, "Schema: version=1") || !strings.Contains(out, "documents_source_type") {
+		t.Fatalf("expected pending migration listing, got: %s", out)
+	}
+
+	// dry-run must not apply anything.
+	status, err := store.InspectSchema(context.Background(), metaPath)
+	if err != nil {
+		t.Fatalf("InspectSchema failed: %v", err)
+	}
+	if status.Version != 1 || len(status.Pending) == 0 {
+		t.Fatalf("dry-run mutated schema: %+v", status)
+	}
+}
+
+func TestStatusPrintsSchemaVersion(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, ".dir2mcp")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state dir: %v", err)
+	}
+	st := store.NewSQLiteStore(filepath.Join(stateDir, "meta.sqlite"))
+	if err := st.Init(context.Background()); err != nil {
+		t.Fatalf("init sqlite store: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("close sqlite store: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	app := cli.NewAppWithIO(&stdout, &stderr)
+	withWorkingDir(t, tmp, func() {
+		code := app.RunWithContext(context.Background(), []string{"status"})
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d stderr=%s", code, stderr.String())
+		}
+	})
+
+	want := fmt.Sprintf("Schema: version=%d supported=%d pending=0", store.CurrentSchemaVersion, store.CurrentSchemaVersion)
+	if !strings.Contains(stdout.String(), want) {
+		t.Fatalf("expected %q in stdout, got: %s", want, stdout.String())
+	}
+}
+
 func TestStatusNoStateReturnsExitCode1(t *testing.T) {
 	tmp := t.TempDir()
 	var stdout, stderr bytes.Buffer
@@ -672,3 +745,306 @@ func TestAskMissingQuestionFails(t *testing.T) {
 		t.Fatalf("expected missing-question message, got: %s", stderr.String())
 	}
 }
+
+type commandTestGrepRetrieverStub struct {
+	commandTestRetrieverStub
+	matches   []model.GrepMatch
+	result    model.GrepResult
+	lastQuery model.GrepQuery
+}
+
+func (s *commandTestGrepRetrieverStub) Grep(_ context.Context, q model.GrepQuery, emit func(model.GrepMatch)) (model.GrepResult, error) {
+	s.lastQuery = q
+	for _, match := range s.matches {
+		emit(match)
+	}
+	result := s.result
+	result.Matches = s.matches
+	return result, nil
+}
+
+func newGrepTestApp(stub *commandTestGrepRetrieverStub, stdout, stderr *bytes.Buffer) *cli.App {
+	return cli.NewAppWithIOAndHooks(stdout, stderr, cli.RuntimeHooks{
+		NewStore: func(config.Config) model.Store { return &commandTestNoopStore{} },
+		NewRetriever: func(config.Config, model.Store) model.Retriever {
+			return stub
+		},
+	})
+}
+
+func TestGrepStreamsMatchesWithContext(t *testing.T) {
+	tmp := t.TempDir()
+	stub := &commandTestGrepRetrieverStub{
+		matches: []model.GrepMatch{
+			{RelPath: "src/a.go", Span: model.Span{Kind: "lines", StartLine: 4, EndLine: 4}, Line: "// TODO one", Before: []string{"func a() {"}, After: []string{"}"}},
+			{RelPath: "src/b.go", Span: model.Span{Kind: "lines", StartLine: 1, EndLine: 1}, Line: "// TODO two", After: []string{"package b"}},
+		},
+		result: model.GrepResult{Truncated: true},
+	}
+	var stdout, stderr bytes.Buffer
+	app := newGrepTestApp(stub, &stdout, &stderr)
+
+	withWorkingDir(t, tmp, func() {
+		code := app.RunWithContext(context.Background(), []string{"grep", "-i", "--path-prefix", "src/", "--context", "1", "--max-matches", "2", "todo"})
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d stderr=%s", code, stderr.String())
+		}
+	})
+
+	if q := stub.lastQuery; q.Pattern != "todo" || !q.IgnoreCase || q.PathPrefix != "src/" || q.ContextLines != 1 || q.MaxMatches != 2 {
+		t.Fatalf("unexpected grep query: %+v", q)
+	}
+	want := "src/a.go-3-func a() {\nsrc/a.go:4:// TODO one\nsrc/a.go-5-}\n--\nsrc/b.go:1:// TODO two\nsrc/b.go-2-package b\n"
+	if stdout.String() != want {
+		t.Fatalf("unexpected stdout:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "stopped after 2 match(es)") {
+		t.Fatalf("expected truncation note, got: %s", stderr.String())
+	}
+}
+
+func TestGrepJSONEmitsOneEventPerMatch(t *testing.T) {
+	tmp := t.TempDir()
+	stub := &commandTestGrepRetrieverStub{
+		matches: []model.GrepMatch{
+			{RelPath: "docs/a.md", Span: model.Span{Kind: "lines", StartLine: 2, EndLine: 2}, Line: "needle"},
+		},
+		result: model.GrepResult{FilesScanned: 4, FilesSkipped: 1},
+	}
+	var stdout, stderr bytes.Buffer
+	app := newGrepTestApp(stub, &stdout, &stderr)
+
+	withWorkingDir(t, tmp, func() {
+		code := app.RunWithContext(context.Background(), []string{"--json", "grep", "--literal", "needle"})
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d stderr=%s", code, stderr.String())
+		}
+	})
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected match and summary events, got: %s", stdout.String())
+	}
+	var match, summary struct {
+		Event string                 `json:"event"`
+		Data  map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &match); err != nil {
+		t.Fatalf("unmarshal match event: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+		t.Fatalf("unmarshal summary event: %v", err)
+	}
+	if match.Event != "grep_match" || match.Data["rel_path"] != "docs/a.md" || match.Data["line"] != "needle" {
+		t.Fatalf("unexpected match event: %+v", match)
+	}
+	if summary.Event != "grep_summary" || summary.Data["matches"] != float64(1) || summary.Data["files_scanned"] != float64(4) {
+		t.Fatalf("unexpected summary event: %+v", summary)
+	}
+	if !stub.lastQuery.Literal {
+		t.Fatalf("expected literal query, got %+v", stub.lastQuery)
+	}
+}
+
+func TestGrepRejectsInvalidFlags(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"grep"}, "exactly one pattern argument"},
+		{[]string{"grep", "("}, "invalid pattern"},
+		{[]string{"grep", "--context", "11", "x"}, fmt.Sprintf("context must be between 0 and %d", mcp.MaxGrepContextLines)},
+		{[]string{"grep", "--max-matches", "1001", "x"}, fmt.Sprintf("max-matches must be between 1 and %d", mcp.MaxGrepMatches)},
+	}
+	for _, tc := range cases {
+		tmp := t.TempDir()
+		var stdout, stderr bytes.Buffer
+		app := cli.NewAppWithIO(&stdout, &stderr)
+		withWorkingDir(t, tmp, func() {
+			code := app.RunWithContext(context.Background(), tc.args)
+			if code != 1 {
+				t.Fatalf("%v: unexpected exit code: got=%d want=1 stderr=%s", tc.args, code, stderr.String())
+			}
+		})
+		if !strings.Contains(stderr.String(), "invalid grep flags") || !strings.Contains(stderr.String(), tc.want) {
+			t.Fatalf("%v: expected %q, got: %s", tc.args, tc.want, stderr.String())
+		}
+	}
+}
+
+func TestStdioCommandKeepsStdoutForJSONRPC(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("MISTRAL_API_KEY", "test-key")
+
+	var stdout, stderr bytes.Buffer
+	app := cli.NewAppWithIOAndHooks(&stdout, &stderr, cli.RuntimeHooks{
+		NewStore: func(config.Config) model.Store { return &commandTestNoopStore{} },
+		Stdin: strings.NewReader("" +
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}` + "\n" +
+			`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+			`{"jsonrpc":"2.0","id":2,"method":"tools/list"}` + "\n"),
+	})
+
+	withWorkingDir(t, tmp, func() {
+		code := app.RunWithContext(context.Background(), []string{"stdio", "--read-only"})
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d stderr=%s", code, stderr.String())
+		}
+	})
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two responses on stdout, got %q", stdout.String())
+	}
+	for i, line := range lines {
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("stdout line %d is not JSON: %q", i, line)
+		}
+		if message["id"] != float64(i+1) || message["result"] == nil {
+			t.Fatalf("unexpected response %d: %#v", i, message)
+		}
+	}
+	if !strings.Contains(stderr.String(), "serving MCP over stdio") {
+		t.Fatalf("expected the startup log on stderr, got %q", stderr.String())
+	}
+}
+
+func TestUpRejectsUnknownTransport(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	app := cli.NewAppWithIO(&stdout, &stderr)
+	code := app.RunWithContext(context.Background(), []string{"up", "--transport", "grpc"})
+	if code != 2 {
+		t.Fatalf("unexpected exit code: got=%d want=2 stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), `invalid --transport "grpc"`) {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func TestTokenCreateListRevoke(t *testing.T) {
+	tmp := t.TempDir()
+	withWorkingDir(t, tmp, func() {
+		var stdout, stderr bytes.Buffer
+		app := cli.NewAppWithIO(&stdout, &stderr)
+		code := app.RunWithContext(context.Background(), []string{"--json", "token", "create", "--name", "ci", "--tools", "search,open_file", "--path-prefix", "docs/", "--expires", "24h"})
+		if code != 0 {
+			t.Fatalf("create exit code=%d stderr=%s", code, stderr.String())
+		}
+		var created map[string]interface{}
+		if err := json.Unmarshal(stdout.Bytes(), &created); err != nil {
+			t.Fatalf("decode create output: %v", err)
+		}
+		secret, _ := created["secret"].(string)
+		if !strings.HasPrefix(secret, "d2m_") || created["expires_at"] == nil {
+			t.Fatalf("unexpected create output: %#v", created)
+		}
+		raw, err := os.ReadFile(filepath.Join(".dir2mcp", "tokens.json"))
+		if err != nil {
+			t.Fatalf("read token file: %v", err)
+		}
+		if strings.Contains(string(raw), secret) {
+			t.Fatal("token file contains the secret")
+		}
+
+		stdout.Reset()
+		if code := app.RunWithContext(context.Background(), []string{"token", "create", "--name", "bad", "--tools", "nope"}); code == 0 {
+			t.Fatal("expected an unknown tool to be rejected")
+		}
+		if code := app.RunWithContext(context.Background(), []string{"token", "revoke", "ci"}); code != 0 {
+			t.Fatalf("revoke exit code=%d stderr=%s", code, stderr.String())
+		}
+		stdout.Reset()
+		if code := app.RunWithContext(context.Background(), []string{"token", "list"}); code != 0 {
+			t.Fatalf("list exit code=%d stderr=%s", code, stderr.String())
+		}
+		line := strings.TrimSpace(stdout.String())
+		if !strings.Contains(line, "\tci\trevoked\t") || !strings.Contains(line, "tools=dir2mcp.search,dir2mcp.open_file") {
+			t.Fatalf("unexpected list output: %q", line)
+		}
+		if strings.Contains(line, "sha256:") {
+			t.Fatalf("list output leaks the token hash: %q", line)
+		}
+	})
+}
+
+func TestAuditVerifyReportsChainBreaks(t *testing.T) {
+	tmp := t.TempDir()
+	withWorkingDir(t, tmp, func() {
+		dir := filepath.Join(".dir2mcp", audit.DirName)
+		logger, err := audit.Open(dir, 0)
+		if err != nil {
+			t.Fatalf("open audit log: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := logger.Append(audit.Entry{Method: "tools/list", Status: "ok"}); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		_ = logger.Close()
+
+		var stdout, stderr bytes.Buffer
+		app := cli.NewAppWithIO(&stdout, &stderr)
+		if code := app.RunWithContext(context.Background(), []string{"audit", "verify"}); code != 0 {
+			t.Fatalf("verify exit code=%d stderr=%s", code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "audit log OK: 3 entries") {
+			t.Fatalf("unexpected verify output: %q", stdout.String())
+		}
+
+		path := filepath.Join(dir, audit.ActiveFileName)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read audit log: %v", err)
+		}
+		tampered := strings.Replace(string(raw), `"method":"tools/list"`, `"method":"tools/call"`, 1)
+		if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+			t.Fatalf("write audit log: %v", err)
+		}
+		stdout.Reset()
+		if code := app.RunWithContext(context.Background(), []string{"--json", "audit", "verify"}); code == 0 {
+			t.Fatal("expected a tampered log to fail verification")
+		}
+		var report map[string]interface{}
+		if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+			t.Fatalf("decode verify output: %v", err)
+		}
+		if report["ok"] != false || report["error"] == nil {
+			t.Fatalf("unexpected verify output: %#v", report)
+		}
+	})
+}
+
+func TestAuditVerifyFailsWhenEarlierFilesWereRemoved(t *testing.T) {
+	tmp := t.TempDir()
+	withWorkingDir(t, tmp, func() {
+		dir := filepath.Join(".dir2mcp", audit.DirName)
+		logger, err := audit.Open(dir, 300)
+		if err != nil {
+			t.Fatalf("open audit log: %v", err)
+		}
+		for i := 0; i < 6; i++ {
+			if err := logger.Append(audit.Entry{Method: "tools/list", Status: "ok"}); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		_ = logger.Close()
+
+		rotated, err := filepath.Glob(filepath.Join(dir, "audit-*.ndjson"))
+		if err != nil || len(rotated) == 0 {
+			t.Fatalf("expected rotated files, got %v (err=%v)", rotated, err)
+		}
+		if err := os.Remove(rotated[0]); err != nil {
+			t.Fatalf("remove rotated file: %v", err)
+		}
+
+		var stdout, stderr bytes.Buffer
+		app := cli.NewAppWithIO(&stdout, &stderr)
+		if code := app.RunWithContext(context.Background(), []string{"audit", "verify"}); code == 0 {
+			t.Fatalf("expected a truncated log to fail verification; stdout=%s", stdout.String())
+		}
+		if !strings.Contains(stderr.String(), "earlier files were removed") {
+			t.Fatalf("expected a truncation warning, got %q", stderr.String())
+		}
+	})
+}
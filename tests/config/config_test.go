@@ -670,6 +670,93 @@ func TestLoad_EnvOverridesEmbedModels(t *testing.T) {
 	})
 }
 
+func TestLoad_EmbedCacheMaxBytes(t *testing.T) {
+	if got := config.Default().EmbedCacheMaxBytes; got != 512<<20 {
+		t.Fatalf("unexpected default embed cache limit: %d", got)
+	}
+
+	tmp := t.TempDir()
+	testutil.WithWorkingDir(t, tmp, func() {
+		if err := os.WriteFile(".dir2mcp.yaml", []byte("embed_cache_max_bytes: 4096\n"), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.EmbedCacheMaxBytes != 4096 {
+			t.Fatalf("EmbedCacheMaxBytes=%d want=%d", cfg.EmbedCacheMaxBytes, 4096)
+		}
+
+		t.Setenv("DIR2MCP_EMBED_CACHE_MAX_BYTES", "0")
+		cfg, err = config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.EmbedCacheMaxBytes != 0 {
+			t.Fatalf("EmbedCacheMaxBytes=%d want env override 0", cfg.EmbedCacheMaxBytes)
+		}
+	})
+}
+
+func TestLoad_RAGContextSettings(t *testing.T) {
+	def := config.Default()
+	if def.RAGContextTokens["default"] != 8000 || def.RAGNeighborChunks != 1 {
+		t.Fatalf("unexpected RAG defaults: tokens=%v neighbors=%d", def.RAGContextTokens, def.RAGNeighborChunks)
+	}
+
+	tmp := t.TempDir()
+	testutil.WithWorkingDir(t, tmp, func() {
+		raw := "rag_context_tokens:\n  - default=4000\n  - mistral-large-latest=24000\nrag_neighbor_chunks: 2\nrag_verify_with_generator: true\n"
+		if err := os.WriteFile(".dir2mcp.yaml", []byte(raw), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err := config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.RAGContextTokens["default"] != 4000 || cfg.RAGContextTokens["mistral-large-latest"] != 24000 {
+			t.Fatalf("unexpected budgets: %v", cfg.RAGContextTokens)
+		}
+		if cfg.RAGNeighborChunks != 2 {
+			t.Fatalf("RAGNeighborChunks=%d want=2", cfg.RAGNeighborChunks)
+		}
+		if !cfg.RAGVerifyWithGenerator {
+			t.Fatal("expected rag_verify_with_generator loaded from file")
+		}
+
+		t.Setenv("DIR2MCP_RAG_CONTEXT_TOKENS", "mistral-small-latest=12000")
+		cfg, err = config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.RAGContextTokens["mistral-small-latest"] != 12000 || cfg.RAGContextTokens["default"] != 4000 {
+			t.Fatalf("expected env budgets merged over file budgets, got %v", cfg.RAGContextTokens)
+		}
+
+		if err := config.SaveFile("saved.yaml", cfg); err != nil {
+			t.Fatalf("SaveFile failed: %v", err)
+		}
+		saved, err := config.LoadFile("saved.yaml")
+		if err != nil {
+			t.Fatalf("LoadFile failed: %v", err)
+		}
+		if len(saved.RAGContextTokens) != 3 || saved.RAGContextTokens["mistral-small-latest"] != 12000 {
+			t.Fatalf("budgets did not round-trip: %v", saved.RAGContextTokens)
+		}
+		if !saved.RAGVerifyWithGenerator {
+			t.Fatal("rag_verify_with_generator did not round-trip")
+		}
+
+		if err := os.WriteFile("bad.yaml", []byte("rag_context_tokens: [large=lots]\n"), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := config.Load("bad.yaml"); err == nil {
+			t.Fatal("expected invalid budget entry to be rejected")
+		}
+	})
+}
+
 func TestDefault_ChatModel(t *testing.T) {
 	cfg := config.Default()
 	if cfg.ChatModel != "mistral-small-2506" {
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/tests/testutil"
+)
+
+func TestLoad_MetricsKeysAndEnvOverride(t *testing.T) {
+	tmp := t.TempDir()
+
+	testutil.WithWorkingDir(t, tmp, func() {
+		content := strings.Join([]string{
+			"metrics_listen_addr: 127.0.0.1:9464",
+			"metrics_auth: file:/run/secrets/metrics",
+		}, "\n") + "\n"
+		if err := os.WriteFile(".dir2mcp.yaml", []byte(content), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		cfg, err := config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.MetricsListenAddr != "127.0.0.1:9464" || cfg.MetricsAuth != "file:/run/secrets/metrics" {
+			t.Fatalf("unexpected metrics config: listen=%q auth=%q", cfg.MetricsListenAddr, cfg.MetricsAuth)
+		}
+
+		t.Setenv("DIR2MCP_METRICS_LISTEN_ADDR", "0.0.0.0:9100")
+		t.Setenv("DIR2MCP_METRICS_AUTH", "none")
+		cfg, err = config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.MetricsListenAddr != "0.0.0.0:9100" || cfg.MetricsAuth != "none" {
+			t.Fatalf("env did not override: listen=%q auth=%q", cfg.MetricsListenAddr, cfg.MetricsAuth)
+		}
+	})
+}
+
+func TestValidate_MetricsAuth(t *testing.T) {
+	for _, auth := range []string{"auto", "none", "file:/tmp/token", ""} {
+		cfg := config.Default()
+		cfg.MetricsAuth = auth
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("metrics_auth %q: unexpected error %v", auth, err)
+		}
+	}
+	for _, auth := range []string{"file:", "bearer", "oauth"} {
+		cfg := config.Default()
+		cfg.MetricsAuth = auth
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("metrics_auth %q: expected an error", auth)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/tests/testutil"
+)
+
+func TestLoad_OAuthFileKeysAndValidation(t *testing.T) {
+	tmp := t.TempDir()
+
+	testutil.WithWorkingDir(t, tmp, func() {
+		content := strings.Join([]string{
+			"auth_mode: oauth",
+			"oauth_issuer: https://issuer.example.com",
+			"oauth_jwks_file: jwks.json",
+			"oauth_clock_skew: 30s",
+			"oauth_resource_url: https://mcp.example.com/mcp",
+		}, "\n") + "\n"
+		if err := os.WriteFile(".dir2mcp.yaml", []byte(content), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		cfg, err := config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.OAuth.Issuer != "https://issuer.example.com" || cfg.OAuth.JWKSFile != "jwks.json" || cfg.OAuth.ClockSkew != 30*time.Second {
+			t.Fatalf("unexpected oauth config: %+v", cfg.OAuth)
+		}
+		if err := cfg.ValidateOAuth(); err != nil {
+			t.Fatalf("ValidateOAuth: %v", err)
+		}
+		if cfg.OAuth.Audience != "https://mcp.example.com/mcp" {
+			t.Fatalf("Audience=%q want it to default to the resource URL", cfg.OAuth.Audience)
+		}
+		if cfg.OAuth.ScopePrefix != "dir2mcp:" {
+			t.Fatalf("ScopePrefix=%q want default", cfg.OAuth.ScopePrefix)
+		}
+	})
+}
+
+func TestValidateOAuth_RequiresIssuerKeysAndAudience(t *testing.T) {
+	base := config.Default()
+	base.OAuth.Issuer = "https://issuer.example.com"
+	base.OAuth.Audience = "dir2mcp"
+	base.OAuth.JWKSFile = "jwks.json"
+
+	cases := map[string]func(*config.Config){
+		"missing issuer":   func(c *config.Config) { c.OAuth.Issuer = "" },
+		"missing jwks":     func(c *config.Config) { c.OAuth.JWKSFile = "" },
+		"both jwks":        func(c *config.Config) { c.OAuth.JWKSURL = "https://issuer.example.com/jwks" },
+		"missing audience": func(c *config.Config) { c.OAuth.Audience = "" },
+		"negative skew":    func(c *config.Config) { c.OAuth.ClockSkew = -time.Second },
+		"relative url": func(c *config.Config) {
+			c.OAuth.JWKSFile = ""
+			c.OAuth.JWKSURL = "/jwks"
+		},
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := base
+			mutate(&cfg)
+			if err := cfg.ValidateOAuth(); err == nil {
+				t.Fatal("expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/config"
+)
+
+func TestLoadFile_ReadsPromptTemplates(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, ".dir2mcp.yaml")
+	writeFile(t, path, ""+
+		"auth_mode: none\n"+
+		"prompts:\n"+
+		"  - name: review_file\n"+
+		"    description: \"Review a file: bugs first\"\n"+
+		"    arguments:\n"+
+		"      - name: rel_path\n"+
+		"        required: true\n"+
+		"      - name: max_findings\n"+
+		"        type: integer\n"+
+		"        description: Upper bound on findings\n"+
+		"    template: |\n"+
+		"      Review {{rel_path}}.\n"+
+		"\n"+
+		"      # not a comment inside the block\n"+
+		"      {{#max_findings}}Report at most {{max_findings}} findings.{{/max_findings}}\n"+
+		"  - name: standup\n"+
+		"    template: Summarize yesterday's changes.\n"+
+		"public: true\n")
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if !cfg.Public || cfg.AuthMode != "none" {
+		t.Fatalf("keys around the prompts block were not read: public=%v auth=%q", cfg.Public, cfg.AuthMode)
+	}
+	want := []config.PromptTemplate{
+		{
+			Name:        "review_file",
+			Description: "Review a file: bugs first",
+			Arguments: []config.PromptArgument{
+				{Name: "rel_path", Type: config.PromptArgumentPath, Required: true},
+				{Name: "max_findings", Type: config.PromptArgumentInteger, Description: "Upper bound on findings"},
+			},
+			Template: "Review {{rel_path}}.\n\n# not a comment inside the block\n{{#max_findings}}Report at most {{max_findings}} findings.{{/max_findings}}\n",
+		},
+		{Name: "standup", Template: "Summarize yesterday's changes."},
+	}
+	if !reflect.DeepEqual(cfg.Prompts, want) {
+		t.Fatalf("Prompts=%#v\nwant=%#v", cfg.Prompts, want)
+	}
+
+	if got := cfg.Prompts[0].Render(map[string]string{"rel_path": "a.go"}); got != "Review a.go.\n\n# not a comment inside the block" {
+		t.Fatalf("Render without optional section = %q", got)
+	}
+	if got := cfg.Prompts[0].Render(map[string]string{"rel_path": "a.go", "max_findings": "3"}); !strings.HasSuffix(got, "Report at most 3 findings.") {
+		t.Fatalf("Render with optional section = %q", got)
+	}
+}
+
+func TestLoadFile_RejectsInvalidPromptTemplates(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "scalar value", body: "prompts: nope\n", want: "prompts must be a list"},
+		{name: "unknown field", body: "prompts:\n  - name: a\n    template: x\n    color: red\n", want: `unknown prompt field "color"`},
+		{name: "unknown type", body: "prompts:\n  - name: a\n    arguments:\n      - name: n\n        type: float\n    template: x\n", want: `unknown type "float"`},
+		{name: "undeclared placeholder", body: "prompts:\n  - name: a\n    template: Hello {{who}}\n", want: `undeclared argument "who"`},
+		{name: "unclosed section", body: "prompts:\n  - name: a\n    arguments:\n      - name: who\n    template: \"{{#who}}hi\"\n", want: `section "who" is not closed`},
+		{name: "duplicate prompt", body: "prompts:\n  - name: a\n    template: x\n  - name: a\n    template: y\n", want: `duplicate prompt "a"`},
+		{name: "missing template", body: "prompts:\n  - name: a\n", want: "template is required"},
+		{name: "bad indentation", body: "prompts:\n  - name: a\n      template: x\n", want: "unexpected indentation"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".dir2mcp.yaml")
+			writeFile(t, path, tc.body)
+			_, err := config.LoadFile(path)
+			if err == nil {
+				t.Fatal("expected LoadFile to fail")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error %q does not mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSaveFile_RoundTripsPromptTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".dir2mcp.yaml")
+	cfg := config.Default()
+	cfg.Prompts = []config.PromptTemplate{{
+		Name:        "explain",
+		Description: `Explain "it"`,
+		Arguments: []config.PromptArgument{
+			{Name: "name", Type: config.PromptArgumentSymbol, Required: true, Description: "Symbol: exact name"},
+			{Name: "verbose", Type: config.PromptArgumentBoolean},
+		},
+		Template: "Explain {{name}}.\n\n{{#verbose}}Go into detail.{{/verbose}}",
+	}}
+	if err := config.SaveFile(path, cfg); err != nil {
+		t.Fatalf("SaveFile failed: %v", err)
+	}
+	loaded, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Prompts, cfg.Prompts) {
+		t.Fatalf("Prompts=%#v\nwant=%#v", loaded.Prompts, cfg.Prompts)
+	}
+}
+
+func TestLoadPromptDir_ReadsOnePromptPerFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "b_changelog.yml"), ""+
+		"description: Draft a changelog entry\n"+
+		"arguments:\n"+
+		"  - name: since\n"+
+		"    required: true\n"+
+		"template: Draft a changelog entry for changes since {{since}}.\n")
+	writeFile(t, filepath.Join(dir, "a.yaml"), ""+
+		"name: triage\n"+
+		"template: Triage the open issues.\n")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "ignored")
+
+	prompts, err := config.LoadPromptDir(dir)
+	if err != nil {
+		t.Fatalf("LoadPromptDir failed: %v", err)
+	}
+	if len(prompts) != 2 || prompts[0].Name != "triage" || prompts[1].Name != "b_changelog" {
+		t.Fatalf("unexpected prompts: %#v", prompts)
+	}
+	if arg := prompts[1].Arguments[0]; arg.Type != config.PromptArgumentString || !arg.Required {
+		t.Fatalf("unexpected argument: %#v", arg)
+	}
+
+	missing, err := config.LoadPromptDir(filepath.Join(dir, "absent"))
+	if err != nil || missing != nil {
+		t.Fatalf("expected no prompts for a missing directory, got %#v, %v", missing, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "c.yaml"), []byte("name: triage\ntemplate: again\n"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := config.LoadPromptDir(dir); err == nil || !strings.Contains(err.Error(), `duplicate prompt "triage"`) {
+		t.Fatalf("expected a duplicate prompt error, got %v", err)
+	}
+}
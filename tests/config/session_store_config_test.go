@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/tests/testutil"
+)
+
+func TestLoad_SessionStoreKeyEnvOverrideAndValidation(t *testing.T) {
+	tmp := t.TempDir()
+
+	testutil.WithWorkingDir(t, tmp, func() {
+		cfg, err := config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.SessionStore != config.SessionStoreMemory {
+			t.Fatalf("default session_store=%q want=%q", cfg.SessionStore, config.SessionStoreMemory)
+		}
+
+		if err := os.WriteFile(".dir2mcp.yaml", []byte("session_store: sqlite\n"), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cfg, err = config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.SessionStore != config.SessionStoreSQLite {
+			t.Fatalf("session_store=%q want=%q", cfg.SessionStore, config.SessionStoreSQLite)
+		}
+
+		t.Setenv("DIR2MCP_SESSION_STORE", "memory")
+		cfg, err = config.Load(".dir2mcp.yaml")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.SessionStore != config.SessionStoreMemory {
+			t.Fatalf("env did not override: session_store=%q", cfg.SessionStore)
+		}
+
+		t.Setenv("DIR2MCP_SESSION_STORE", "redis")
+		if _, err := config.Load(".dir2mcp.yaml"); err == nil {
+			t.Fatal("expected an error for an unknown session store")
+		}
+	})
+}
@@ -40,6 +40,27 @@ func TestPlannerModelProfileAffectsStrategyAndSynthesis(t *testing.T) {
 	}
 }
 
+func TestWithConversationTagsOnlyAskSteps(t *testing.T) {
+	plan, err := breeze.PlanTurn("explain indexing", "mistral-large-latest")
+	if err != nil {
+		t.Fatalf("plan error: %v", err)
+	}
+
+	tagged := breeze.WithConversation(plan, "conv-1")
+	if _, ok := tagged.Steps[0].Args["conversation_id"]; ok {
+		t.Fatalf("expected search step untouched, got %#v", tagged.Steps[0].Args)
+	}
+	if got := tagged.Steps[1].Args["conversation_id"]; got != "conv-1" {
+		t.Fatalf("expected ask step tagged with conversation, got %#v", tagged.Steps[1].Args)
+	}
+	if _, ok := plan.Steps[1].Args["conversation_id"]; ok {
+		t.Fatal("expected original plan args not to be mutated")
+	}
+	if untagged := breeze.WithConversation(plan, ""); !reflect.DeepEqual(untagged, plan) {
+		t.Fatalf("expected empty conversation to leave plan unchanged, got %#v", untagged)
+	}
+}
+
 func TestPlannerSupportsClearCommand(t *testing.T) {
 	plan, err := breeze.PlanTurn("/clear", "mistral-small-latest")
 	if err != nil {
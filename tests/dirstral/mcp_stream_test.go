@@ -0,0 +1,114 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/dirstral/mcp"
+	"dir2mcp/internal/protocol"
+)
+
+func TestCallToolStreamDeliversProgressBeforeResult(t *testing.T) {
+	t.Parallel()
+
+	handlerErrCh := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			reportHandlerErr(handlerErrCh, "decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch req["method"] {
+		case "initialize":
+			w.Header().Set(protocol.MCPSessionHeader, "session")
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req["id"], "result": map[string]any{}})
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/call":
+			if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+				reportHandlerErr(handlerErrCh, "expected Accept to include text/event-stream, got %q", r.Header.Get("Accept"))
+			}
+			params, _ := req["params"].(map[string]any)
+			meta, _ := params["_meta"].(map[string]any)
+			token, _ := meta["progressToken"].(string)
+			if token == "" {
+				reportHandlerErr(handlerErrCh, "expected a progress token, got %#v", params)
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			events := []map[string]any{
+				{"jsonrpc": "2.0", "method": protocol.RPCMethodProgress, "params": map[string]any{"progressToken": token, "progress": 5, "message": "alpha"}},
+				{"jsonrpc": "2.0", "method": protocol.RPCMethodProgress, "params": map[string]any{"progressToken": "other", "progress": 1, "message": "ignored"}},
+				{"jsonrpc": "2.0", "method": protocol.RPCMethodProgress, "params": map[string]any{"progressToken": token, "progress": 10, "message": " beta"}},
+				{"jsonrpc": "2.0", "id": req["id"], "result": map[string]any{"content": []map[string]any{{"type": "text", "text": "alpha beta"}}}},
+			}
+			for _, event := range events {
+				data, _ := json.Marshal(event)
+				_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			}
+		default:
+			reportHandlerErr(handlerErrCh, "unexpected method: %v", req["method"])
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := mcp.NewWithTransport(server.URL, "streamable-http", false)
+	ctx := context.Background()
+	if err := client.Initialize(ctx); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+
+	var deltas []string
+	res, err := client.CallToolStream(ctx, protocol.ToolNameAsk, map[string]any{"question": "what?"}, func(message string) {
+		deltas = append(deltas, message)
+	})
+	if err != nil {
+		t.Fatalf("call tool failed: %v", err)
+	}
+	if want := []string{"alpha", " beta"}; !reflect.DeepEqual(deltas, want) {
+		t.Fatalf("progress messages = %q, want %q", deltas, want)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "alpha beta" {
+		t.Fatalf("unexpected tool result: %#v", res.Content)
+	}
+	assertNoHandlerErr(t, handlerErrCh)
+}
+
+func TestCallToolStreamFailsWhenStreamEndsWithoutResult(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["method"] {
+		case "initialize":
+			w.Header().Set(protocol.MCPSessionHeader, "session")
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req["id"], "result": map[string]any{}})
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+		}
+	}))
+	defer server.Close()
+
+	client := mcp.NewWithTransport(server.URL, "streamable-http", false)
+	ctx := context.Background()
+	if err := client.Initialize(ctx); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if _, err := client.CallToolStream(ctx, protocol.ToolNameAsk, map[string]any{"question": "what?"}, func(string) {}); err == nil {
+		t.Fatal("expected an error for a stream without a response")
+	}
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/model"
+)
+
+// countingEmbedder returns one deterministic vector per input and records
+// every input it was asked to embed.
+type countingEmbedder struct {
+	inputs []string
+}
+
+func (e *countingEmbedder) Embed(_ context.Context, _ string, texts []string) ([][]float32, error) {
+	e.inputs = append(e.inputs, texts...)
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = []float32{float32(len(text)), 1}
+	}
+	return out, nil
+}
+
+func TestFileEmbeddingCache_RoundTripIsKeyedByModel(t *testing.T) {
+	cache := index.NewFileEmbeddingCache(filepath.Join(t.TempDir(), "embeddings"), 0)
+
+	if _, ok := cache.Get("mistral-embed", "alpha"); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+	if err := cache.Put("mistral-embed", "alpha", []float32{0.25, -1.5}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	got, ok := cache.Get("mistral-embed", "alpha")
+	if !ok {
+		t.Fatal("expected hit after Put")
+	}
+	if !reflect.DeepEqual(got, []float32{0.25, -1.5}) {
+		t.Fatalf("unexpected cached vector: %v", got)
+	}
+	if _, ok := cache.Get("codestral-embed", "alpha"); ok {
+		t.Fatal("expected miss for a different model")
+	}
+}
+
+func TestFileEmbeddingCache_PruneEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "embeddings")
+	// each two-dimensional entry is 8 bytes, so the limit holds two entries.
+	cache := index.NewFileEmbeddingCache(dir, 16)
+	cache.SetPruneEvery(1000)
+
+	for _, text := range []string{"first", "second", "third"} {
+		if err := cache.Put("m", text, []float32{1, 2}); err != nil {
+			t.Fatalf("Put(%s) failed: %v", text, err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	for i, e := range entries {
+		stamp := old.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, e.Name()), stamp, stamp); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+	// reading an entry marks it as recently used.
+	if _, ok := cache.Get("m", "first"); !ok {
+		t.Fatal("expected hit for first")
+	}
+
+	if err := cache.Prune(); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if _, ok := cache.Get("m", "first"); !ok {
+		t.Fatal("recently used entry should survive pruning")
+	}
+	remaining, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(remaining) != 2 {
+		t.Fatalf("expected 2 entries after pruning, got %d", len(remaining))
+	}
+}
+
+func TestEmbeddingWorker_RunOnce_UsesCache(t *testing.T) {
+	cache := index.NewFileEmbeddingCache(filepath.Join(t.TempDir(), "embeddings"), 0)
+	if err := cache.Put("mistral-embed", "alpha", []float32{9, 9}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	source := &fakeChunkSource{
+		tasks: []model.ChunkTask{
+			model.NewChunkTask(11, "alpha", "text", model.ChunkMetadata{ChunkID: 11, RelPath: "a.txt"}),
+			model.NewChunkTask(22, "beta", "text", model.ChunkMetadata{ChunkID: 22, RelPath: "b.txt"}),
+		},
+	}
+	embedder := &countingEmbedder{}
+	idx := index.NewHNSWIndex("")
+	worker := &index.EmbeddingWorker{
+		Source:       source,
+		Index:        idx,
+		Embedder:     embedder,
+		Cache:        cache,
+		ModelForText: "mistral-embed",
+	}
+
+	n, err := worker.RunOnce(context.Background(), "text")
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if n != 2 || len(source.embedded) != 2 {
+		t.Fatalf("expected both chunks indexed, n=%d embedded=%v", n, source.embedded)
+	}
+	if !reflect.DeepEqual(embedder.inputs, []string{"beta"}) {
+		t.Fatalf("expected only the uncached chunk to be embedded, got %v", embedder.inputs)
+	}
+	if got, ok := cache.Get("mistral-embed", "beta"); !ok || !reflect.DeepEqual(got, []float32{4, 1}) {
+		t.Fatalf("expected new vector to be cached, got %v ok=%v", got, ok)
+	}
+
+	// a second pass over the same chunks, as after a full reindex, must not
+	// call the provider at all.
+	source.tasks = []model.ChunkTask{
+		model.NewChunkTask(11, "alpha", "text", model.ChunkMetadata{ChunkID: 11, RelPath: "a.txt"}),
+		model.NewChunkTask(22, "beta", "text", model.ChunkMetadata{ChunkID: 22, RelPath: "b.txt"}),
+	}
+	embedder.inputs = nil
+	if _, err := worker.RunOnce(context.Background(), "text"); err != nil {
+		t.Fatalf("second RunOnce failed: %v", err)
+	}
+	if len(embedder.inputs) != 0 {
+		t.Fatalf("expected no provider calls on a fully cached batch, got %v", embedder.inputs)
+	}
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"encoding/gob"
 	"log"
 	"os"
 	"path/filepath"
@@ -106,6 +107,61 @@ func TestHNSWIndex_SaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestHNSWIndex_SaveAndLoadPreservesHeader(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "idx.bin")
+
+	idx := index.NewHNSWIndex(file)
+	idx.SetModel("mistral-embed")
+	if err := idx.Add(1, []float32{0.1, 0.2, 0.3}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := idx.Save(""); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded := index.NewHNSWIndex(file)
+	if err := loaded.Load(""); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	header := loaded.Header()
+	if header.Model != "mistral-embed" || header.Dim != 3 {
+		t.Fatalf("unexpected header after load: %+v", header)
+	}
+	if loaded.EmbeddingModel() != "mistral-embed" {
+		t.Fatalf("unexpected embedding model: %q", loaded.EmbeddingModel())
+	}
+}
+
+func TestHNSWIndex_LoadLegacyFormat(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "idx.bin")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("create legacy file: %v", err)
+	}
+	// builds without headers persisted a bare vector map.
+	if err := gob.NewEncoder(f).Encode(map[uint64][]float32{9: {1, 0}}); err != nil {
+		t.Fatalf("encode legacy index: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close legacy file: %v", err)
+	}
+
+	loaded := index.NewHNSWIndex(file)
+	if err := loaded.Load(""); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if header := loaded.Header(); header.Model != "" || header.Dim != 2 {
+		t.Fatalf("unexpected header for legacy index: %+v", header)
+	}
+	labels, _, err := loaded.Search([]float32{1, 0}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(labels) != 1 || labels[0] != 9 {
+		t.Fatalf("unexpected legacy search result: %#v", labels)
+	}
+}
+
 func TestHNSWIndex_SearchEmptyIndex(t *testing.T) {
 	idx := index.NewHNSWIndex("")
 	// should not panic and should return empty slices
@@ -171,3 +227,20 @@ func TestHNSWIndex_LoadNonExistentFile(t *testing.T) {
 		t.Fatalf("expected nil for nonexistent file, got %v", err)
 	}
 }
+
+func TestHNSWIndex_SearchFilteredSkipsDisallowedLabels(t *testing.T) {
+	idx := index.NewHNSWIndex("")
+	for label, vector := range map[uint64][]float32{1: {1, 0}, 2: {0.9, 0.1}, 3: {0, 1}} {
+		if err := idx.Add(label, vector); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	labels, _, err := idx.SearchFiltered([]float32{1, 0}, 1, func(label uint64) bool { return label == 3 })
+	if err != nil {
+		t.Fatalf("SearchFiltered failed: %v", err)
+	}
+	if len(labels) != 1 || labels[0] != 3 {
+		t.Fatalf("expected only the allowed label 3, got %v", labels)
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/model"
+)
+
+type fakeModelSettings struct {
+	settings   map[string]string
+	resetKinds []string
+}
+
+func newFakeModelSettings() *fakeModelSettings {
+	return &fakeModelSettings{settings: map[string]string{}}
+}
+
+func (f *fakeModelSettings) GetSetting(_ context.Context, key string) (string, error) {
+	value, ok := f.settings[key]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return value, nil
+}
+
+func (f *fakeModelSettings) SetSetting(_ context.Context, key, value string) error {
+	f.settings[key] = value
+	return nil
+}
+
+func (f *fakeModelSettings) ResetEmbeddingStatus(_ context.Context, indexKind string) (int64, error) {
+	f.resetKinds = append(f.resetKinds, indexKind)
+	return 1, nil
+}
+
+func TestReconcileEmbeddingModel_MatchingModelRecordsSettings(t *testing.T) {
+	ctx := context.Background()
+	live := index.NewHNSWIndex("")
+	live.SetModel("mistral-embed")
+	if err := live.Add(1, []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	st := newFakeModelSettings()
+	target := index.NewShadowIndex(live)
+
+	rebuilding, err := index.ReconcileEmbeddingModel(ctx, st, "text", target, "mistral-embed")
+	if err != nil {
+		t.Fatalf("ReconcileEmbeddingModel failed: %v", err)
+	}
+	if rebuilding || target.Rebuilding() {
+		t.Fatal("expected no rebuild when models match")
+	}
+	if len(st.resetKinds) != 0 {
+		t.Fatalf("expected no chunks reset, got %v", st.resetKinds)
+	}
+	if st.settings["embed_text_model"] != "mistral-embed" || st.settings["embed_text_dim"] != "3" {
+		t.Fatalf("unexpected recorded settings: %#v", st.settings)
+	}
+}
+
+func TestReconcileEmbeddingModel_LegacyIndexUsesSettings(t *testing.T) {
+	ctx := context.Background()
+	live := index.NewHNSWIndex("")
+	if err := live.Add(1, []float32{1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	st := newFakeModelSettings()
+	st.settings["embed_code_model"] = "codestral-embed"
+	st.settings["embed_code_model_recorded"] = "true"
+	target := index.NewShadowIndex(live)
+
+	rebuilding, err := index.ReconcileEmbeddingModel(ctx, st, "code", target, "other-embed")
+	if err != nil {
+		t.Fatalf("ReconcileEmbeddingModel failed: %v", err)
+	}
+	if !rebuilding {
+		t.Fatal("expected rebuild when the recorded model differs")
+	}
+	if got := live.EmbeddingModel(); got != "codestral-embed" {
+		t.Fatalf("live index should keep serving with the recorded model, got %q", got)
+	}
+}
+
+func TestReconcileEmbeddingModel_LegacyIndexIgnoresSeededDefault(t *testing.T) {
+	ctx := context.Background()
+	live := index.NewHNSWIndex("")
+	if err := live.Add(1, []float32{1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	// the store seeds the model setting with a default on every open; it
+	// does not say which model built this index.
+	st := newFakeModelSettings()
+	st.settings["embed_text_model"] = "mistral-embed"
+	target := index.NewShadowIndex(live)
+
+	rebuilding, err := index.ReconcileEmbeddingModel(ctx, st, "text", target, "other-embed")
+	if err != nil {
+		t.Fatalf("ReconcileEmbeddingModel failed: %v", err)
+	}
+	if rebuilding || len(st.resetKinds) != 0 {
+		t.Fatalf("expected no rebuild for an unknown legacy model, reset=%v", st.resetKinds)
+	}
+	if got := live.EmbeddingModel(); got != "other-embed" {
+		t.Fatalf("expected the configured model to be adopted, got %q", got)
+	}
+	if st.settings["embed_text_model"] != "other-embed" || st.settings["embed_text_model_recorded"] == "" {
+		t.Fatalf("expected the adopted model to be recorded, got %#v", st.settings)
+	}
+}
+
+func TestShadowIndex_RebuildServesOldIndexUntilSwap(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors_text.hnsw")
+	live := index.NewHNSWIndex(path)
+	live.SetModel("old-embed")
+	if err := live.Add(1, []float32{1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	st := newFakeModelSettings()
+	target := index.NewShadowIndex(live)
+
+	rebuilding, err := index.ReconcileEmbeddingModel(ctx, st, "text", target, "new-embed")
+	if err != nil {
+		t.Fatalf("ReconcileEmbeddingModel failed: %v", err)
+	}
+	if !rebuilding || !target.Rebuilding() {
+		t.Fatal("expected rebuild to start on model mismatch")
+	}
+	if len(st.resetKinds) != 1 || st.resetKinds[0] != "text" {
+		t.Fatalf("expected text chunks reset, got %v", st.resetKinds)
+	}
+
+	// vectors from the new model have a different dimension and must not
+	// leak into the index that is still serving queries.
+	if err := target.Add(1, []float32{0, 0, 1}); err != nil {
+		t.Fatalf("Add during rebuild failed: %v", err)
+	}
+	if got := target.EmbeddingModel(); got != "old-embed" {
+		t.Fatalf("queries should use the old model until swap, got %q", got)
+	}
+	labels, _, err := target.Search([]float32{1, 0}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(labels) != 1 || labels[0] != 1 {
+		t.Fatalf("expected old index to keep serving, got %#v", labels)
+	}
+
+	source := &fakeChunkSource{}
+	if err := index.CompleteRebuildWhenDrained(ctx, st, source, "text", target, time.Millisecond); err != nil {
+		t.Fatalf("CompleteRebuildWhenDrained failed: %v", err)
+	}
+	if target.Rebuilding() {
+		t.Fatal("expected rebuild to finish once no chunks are pending")
+	}
+	if header := live.Header(); header.Model != "new-embed" || header.Dim != 3 {
+		t.Fatalf("unexpected header after swap: %+v", header)
+	}
+	labels, _, err = target.Search([]float32{0, 0, 1}, 1)
+	if err != nil {
+		t.Fatalf("Search after swap failed: %v", err)
+	}
+	if len(labels) != 1 || labels[0] != 1 {
+		t.Fatalf("expected rebuilt vectors after swap, got %#v", labels)
+	}
+	if st.settings["embed_text_model"] != "new-embed" || st.settings["embed_text_dim"] != "3" {
+		t.Fatalf("unexpected recorded settings after swap: %#v", st.settings)
+	}
+
+	reloaded := index.NewHNSWIndex(path)
+	if err := reloaded.Load(""); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if header := reloaded.Header(); header.Model != "new-embed" {
+		t.Fatalf("expected swapped index to be persisted, got %+v", header)
+	}
+}
+
+func TestShadowIndex_WaitsWhileChunksPending(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	live := index.NewHNSWIndex("")
+	target := index.NewShadowIndex(live)
+	target.BeginRebuild("new-embed")
+
+	source := &pendingChunkSource{task: model.NewChunkTask(1, "x", "text", model.ChunkMetadata{ChunkID: 1, RelPath: "a.md"})}
+	err := index.CompleteRebuildWhenDrained(ctx, newFakeModelSettings(), source, "text", target, 5*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected context error while chunks remain pending")
+	}
+	if !target.Rebuilding() {
+		t.Fatal("expected rebuild to remain in progress")
+	}
+}
+
+// pendingChunkSource always reports the same pending task.
+type pendingChunkSource struct {
+	task model.ChunkTask
+}
+
+func (s *pendingChunkSource) NextPending(_ context.Context, _ int, _ string) ([]model.ChunkTask, error) {
+	return []model.ChunkTask{s.task}, nil
+}
+
+func (s *pendingChunkSource) MarkEmbedded(_ context.Context, _ []uint64) error { return nil }
+
+func (s *pendingChunkSource) MarkFailed(_ context.Context, _ []uint64, _ string) error { return nil }
@@ -392,3 +392,47 @@ func TestMemoryStoreListFilesPaging(t *testing.T) {
 		t.Fatalf("page2 unexpected: %#v", page2)
 	}
 }
+
+func TestServiceRun_ReportsDocumentChanges(t *testing.T) {
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "a.txt"), []byte("first"))
+	mustWriteFile(t, filepath.Join(root, "b.txt"), []byte("second"))
+
+	cfg := config.Default()
+	cfg.RootDir = root
+	svc := ingest.NewService(cfg, newMemoryStore())
+	var changes []string
+	svc.SetDocumentChangeHandler(func(change model.DocumentChange) {
+		changes = append(changes, change.Kind+":"+change.RelPath)
+	})
+
+	run := func() []string {
+		t.Helper()
+		changes = nil
+		if err := svc.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		sort.Strings(changes)
+		return changes
+	}
+
+	if got, want := run(), []string{"added:a.txt", "added:b.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("first scan changes=%v want=%v", got, want)
+	}
+	if got := run(); len(got) != 0 {
+		t.Fatalf("unchanged scan reported changes: %v", got)
+	}
+
+	mustWriteFile(t, filepath.Join(root, "a.txt"), []byte("first, edited"))
+	if err := os.Remove(filepath.Join(root, "b.txt")); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if got, want := run(), []string{"deleted:b.txt", "updated:a.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("second scan changes=%v want=%v", got, want)
+	}
+
+	mustWriteFile(t, filepath.Join(root, "b.txt"), []byte("second"))
+	if got, want := run(), []string{"added:b.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("restore scan changes=%v want=%v", got, want)
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/store"
+)
+
+// TestIngest_UpgradeBackfillsSymbolsForUnchangedCode indexes a corpus, rolls
+// the database back to the layout before symbols existed and checks that
+// the next scan after the upgrade finds symbols in files that did not change.
+func TestIngest_UpgradeBackfillsSymbolsForUnchangedCode(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg", "run.go"), []byte("package pkg\n\nfunc Run() {}\n"), 0o600); err != nil {
+		t.Fatalf("write code file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bundle.zip"), buildZip(t, map[string]string{"lib/util.go": "package lib\n\nfunc Runner() {}\n"}), 0o600); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	cfg := config.Default()
+	cfg.RootDir = root
+	dbPath := filepath.Join(t.TempDir(), "meta.sqlite")
+
+	st := store.NewSQLiteStore(dbPath)
+	if err := ingest.NewService(cfg, st).Run(ctx); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open raw db: %v", err)
+	}
+	if _, err := db.Exec(`
+DROP TABLE symbols;
+DROP TRIGGER chunks_fts_insert;
+DROP TRIGGER chunks_fts_delete;
+DROP TRIGGER chunks_fts_update;
+DROP TABLE chunks_fts;
+DELETE FROM schema_migrations WHERE version >= 3;
+UPDATE settings SET value = '2' WHERE key = 'schema_version';`); err != nil {
+		t.Fatalf("roll back to version 2: %v", err)
+	}
+	_ = db.Close()
+
+	upgraded := store.NewSQLiteStore(dbPath)
+	t.Cleanup(func() { _ = upgraded.Close() })
+	if err := ingest.NewService(cfg, upgraded).Run(ctx); err != nil {
+		t.Fatalf("Run after upgrade: %v", err)
+	}
+
+	symbols, err := upgraded.SearchSymbols(ctx, model.SymbolQuery{Name: "run"})
+	if err != nil {
+		t.Fatalf("SearchSymbols: %v", err)
+	}
+	found := map[string]bool{}
+	for _, sym := range symbols {
+		found[sym.RelPath+":"+sym.Name] = true
+	}
+	if !found["pkg/run.go:Run"] || !found["bundle.zip/lib/util.go:Runner"] {
+		t.Fatalf("expected symbols for unchanged files after the upgrade, got %+v", symbols)
+	}
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"testing"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+)
+
+func findSymbol(symbols []model.Symbol, container, name string) (model.Symbol, bool) {
+	for _, sym := range symbols {
+		if sym.Name == name && sym.Container == container {
+			return sym, true
+		}
+	}
+	return model.Symbol{}, false
+}
+
+func TestExtractSymbols_GoIsExact(t *testing.T) {
+	src := `package sample
+
+const Limit = 10
+
+type Store struct {
+	path string
+}
+
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+`
+	symbols := ingest.ExtractSymbols("pkg/sample.go", []byte(src))
+
+	tests := []struct {
+		container, name, kind string
+		start, end            int
+	}{
+		{"", "Limit", model.SymbolKindConst, 3, 3},
+		{"", "Store", model.SymbolKindStruct, 5, 7},
+		{"Store", "path", model.SymbolKindField, 6, 6},
+		{"", "Reader", model.SymbolKindInterface, 9, 11},
+		{"Reader", "Read", model.SymbolKindMethod, 10, 10},
+		{"", "New", model.SymbolKindFunction, 13, 15},
+		{"Store", "Path", model.SymbolKindMethod, 17, 19},
+	}
+	for _, tc := range tests {
+		sym, ok := findSymbol(symbols, tc.container, tc.name)
+		if !ok {
+			t.Fatalf("missing symbol %s.%s in %+v", tc.container, tc.name, symbols)
+		}
+		if sym.Kind != tc.kind || sym.Span.StartLine != tc.start || sym.Span.EndLine != tc.end {
+			t.Fatalf("symbol %s.%s = %+v, want kind=%s lines %d-%d", tc.container, tc.name, sym, tc.kind, tc.start, tc.end)
+		}
+		if !sym.Exact || sym.Language != "go" || sym.Span.Kind != "lines" {
+			t.Fatalf("symbol %s.%s should be an exact go lines span: %+v", tc.container, tc.name, sym)
+		}
+	}
+}
+
+func TestExtractSymbols_GoKeepsDeclarationsBeforeSyntaxError(t *testing.T) {
+	symbols := ingest.ExtractSymbols("broken.go", []byte("package x\n\nfunc Done() {}\n\nfunc (\n"))
+	if len(symbols) != 1 || symbols[0].Name != "Done" {
+		t.Fatalf("expected only Done from half-edited file, got %+v", symbols)
+	}
+}
+
+func TestExtractSymbols_HeuristicLanguages(t *testing.T) {
+	tests := []struct {
+		path                  string
+		src                   string
+		container, name, kind string
+		start, end            int
+	}{
+		{
+			path:      "app/models.py",
+			src:       "import os\n\nclass User:\n    def save(self):\n        return True\n\n\ndef helper():\n    pass\n",
+			container: "User", name: "save", kind: model.SymbolKindMethod, start: 4, end: 5,
+		},
+		{
+			path:      "app/models.py",
+			src:       "class User:\n    pass\n\ndef helper():\n    pass\n",
+			container: "", name: "helper", kind: model.SymbolKindFunction, start: 4, end: 5,
+		},
+		{
+			path:      "web/client.ts",
+			src:       "export class Client {\n  fetch(url: string): Promise<void> {\n    return get(url);\n  }\n}\n\nexport function connect() {\n  return new Client();\n}\n",
+			container: "", name: "Client", kind: model.SymbolKindClass, start: 1, end: 5,
+		},
+		{
+			path:      "web/client.ts",
+			src:       "export function connect() {\n  const s = \"}\";\n  return s;\n}\n",
+			container: "", name: "connect", kind: model.SymbolKindFunction, start: 1, end: 4,
+		},
+		{
+			path:      "src/lib.rs",
+			src:       "pub struct Engine<'a> {\n    name: &'a str,\n}\n\nimpl<'a> Engine<'a> {\n    pub fn run(&self) -> bool {\n        true\n    }\n}\n",
+			container: "Engine", name: "run", kind: model.SymbolKindMethod, start: 6, end: 8,
+		},
+		{
+			path:      "lib/math.c",
+			src:       "#include <stdio.h>\n\nstatic int\nadd(int a, int b)\n{\n    return a + b;\n}\n",
+			container: "", name: "add", kind: model.SymbolKindFunction, start: 4, end: 7,
+		},
+	}
+	for _, tc := range tests {
+		symbols := ingest.ExtractSymbols(tc.path, []byte(tc.src))
+		sym, ok := findSymbol(symbols, tc.container, tc.name)
+		if !ok {
+			t.Fatalf("%s: missing symbol %s.%s in %+v", tc.path, tc.container, tc.name, symbols)
+		}
+		if sym.Kind != tc.kind || sym.Span.StartLine != tc.start || sym.Span.EndLine != tc.end {
+			t.Fatalf("%s: symbol %s.%s = %+v, want kind=%s lines %d-%d", tc.path, tc.container, tc.name, sym, tc.kind, tc.start, tc.end)
+		}
+		if sym.Exact {
+			t.Fatalf("%s: heuristic symbol must not be exact: %+v", tc.path, sym)
+		}
+	}
+}
+
+func TestExtractSymbols_IgnoresNonCode(t *testing.T) {
+	if symbols := ingest.ExtractSymbols("README.md", []byte("# def not_a_function():\n")); len(symbols) != 0 {
+		t.Fatalf("expected no symbols for markdown, got %+v", symbols)
+	}
+}
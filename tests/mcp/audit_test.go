@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/audit"
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+	"dir2mcp/internal/retrieval"
+	"dir2mcp/internal/tokens"
+)
+
+func readAuditEntries(t *testing.T, dir string) []audit.Entry {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, audit.ActiveFileName))
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	var entries []audit.Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry audit.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decode audit entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditLog_RecordsRequestsAndToolCalls(t *testing.T) {
+	cfg, st, _ := setupMCPToolStore(t, "docs/a.md", "md", []byte("alpha\nbeta\n"))
+	cfg = scopedTokenConfig(cfg)
+	secret := createScopedToken(t, cfg.StateDir, tokens.Token{Name: "reader"})
+
+	dir := filepath.Join(cfg.StateDir, audit.DirName)
+	logger, err := audit.Open(dir, 0)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+
+	retriever := &askAudioRetrieverStub{
+		OnSearch: func(query model.SearchQuery) ([]model.SearchHit, error) {
+			return []model.SearchHit{
+				{ChunkID: 1, RelPath: "docs/a.md", Snippet: "alpha", Span: model.Span{Kind: "lines", StartLine: 1, EndLine: 2}},
+			}, nil
+		},
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever, mcp.WithStore(st), mcp.WithAuditLog(logger)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+
+	sessionID := bearerInitialize(t, url, secret)
+	bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"alpha"}}}`)
+	bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"alpha","bogus":true}}}`)
+	resp := bearerRPC(t, url, sessionID, "wrong-token", `{"jsonrpc":"2.0","id":4,"method":"tools/list"}`)
+	_ = resp.Body.Close()
+
+	entries := readAuditEntries(t, dir)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 audit entries, got %d: %+v", len(entries), entries)
+	}
+	initialize, search, invalid, rejected := entries[0], entries[1], entries[2], entries[3]
+
+	tokenID := initialize.Token
+	if initialize.Method != "initialize" || initialize.Session != sessionID || len(tokenID) < 4 || tokenID[:4] != "tok_" || initialize.ClientIP != "127.0.0.1" {
+		t.Fatalf("unexpected initialize entry: %+v", initialize)
+	}
+	if search.Tool != protocol.ToolNameSearch || search.Status != "ok" || search.Token != tokenID || search.Session != sessionID || search.ArgsDigest == "" {
+		t.Fatalf("unexpected search entry: %+v", search)
+	}
+	if len(search.Refs) != 1 || search.Refs[0].RelPath != "docs/a.md" || search.Refs[0].Span == nil {
+		t.Fatalf("search refs=%+v want docs/a.md with a span", search.Refs)
+	}
+	if invalid.Status != "error" || invalid.ErrorCode == "" || invalid.ArgsDigest == search.ArgsDigest {
+		t.Fatalf("unexpected invalid search entry: %+v", invalid)
+	}
+	if rejected.Status != "error" || rejected.HTTPStatus != http.StatusUnauthorized || rejected.Token != "" || rejected.ErrorCode != protocol.ErrorCodeUnauthorized {
+		t.Fatalf("unexpected rejected entry: %+v", rejected)
+	}
+
+	report, err := audit.Verify(dir)
+	if err != nil || report.Entries != 4 {
+		t.Fatalf("verify: report=%+v err=%v", report, err)
+	}
+}
+
+func TestAuditLog_RecordsFilesReadThroughResourcesAndPrompts(t *testing.T) {
+	root := t.TempDir()
+	for relPath, content := range map[string]string{"docs/a.md": "alpha\nbeta\n", "docs/b.md": "gamma\n"} {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	svc := retrieval.NewService(nil, nil, nil, nil)
+	svc.SetRootDir(root)
+
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	cfg.RootDir = root
+	dir := filepath.Join(t.TempDir(), audit.DirName)
+	logger, err := audit.Open(dir, 0)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+
+	server := httptest.NewServer(mcp.NewServer(cfg, svc, mcp.WithAuditLog(logger)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+	sessionID := initializeSession(t, url)
+
+	if envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"dir2mcp://file/docs/a.md#L2-2"}}`); envelope.Error != nil {
+		t.Fatalf("resources/read: %#v", envelope.Error)
+	}
+	if envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":{"name":"summarize_file","arguments":{"rel_path":"docs/b.md"}}}`); envelope.Error != nil {
+		t.Fatalf("prompts/get: %#v", envelope.Error)
+	}
+
+	entries := readAuditEntries(t, dir)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %d: %+v", len(entries), entries)
+	}
+	read, prompt := entries[1], entries[2]
+	if read.Method != "resources/read" || len(read.Refs) != 1 || read.Refs[0].RelPath != "docs/a.md" ||
+		read.Refs[0].Span == nil || read.Refs[0].Span.StartLine != 2 || read.Refs[0].Span.EndLine != 2 {
+		t.Fatalf("unexpected resources/read entry: %+v", read)
+	}
+	if prompt.Method != "prompts/get" || len(prompt.Refs) != 1 || prompt.Refs[0].RelPath != "docs/b.md" || prompt.Refs[0].Span != nil {
+		t.Fatalf("unexpected prompts/get entry: %+v", prompt)
+	}
+}
+
+func TestAuditLog_RecordsEventStreams(t *testing.T) {
+	cfg := scopedTokenConfig(config.Default())
+	cfg.StateDir = t.TempDir()
+	dir := filepath.Join(cfg.StateDir, audit.DirName)
+	logger, err := audit.Open(dir, 0)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer func() { _ = logger.Close() }()
+
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithAuditLog(logger)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+	sessionID := bearerInitialize(t, url, scopedTestServerToken)
+
+	openStream := func(ctx context.Context, secret string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("create request: %v", err)
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Authorization", "Bearer "+secret)
+		req.Header.Set(protocol.MCPSessionHeader, sessionID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("do request: %v", err)
+		}
+		return resp
+	}
+
+	resp := openStream(context.Background(), "wrong-token")
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	// an open stream is recorded when it closes.
+	ctx, cancel := context.WithCancel(context.Background())
+	resp = openStream(ctx, scopedTestServerToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusOK)
+	}
+	cancel()
+	_ = resp.Body.Close()
+
+	var entries []audit.Entry
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if entries = readAuditEntries(t, dir); len(entries) == 3 {
+			break
+		}
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %d: %+v", len(entries), entries)
+	}
+	rejected, stream := entries[1], entries[2]
+	if rejected.Method != "GET" || rejected.Status != "error" || rejected.HTTPStatus != http.StatusUnauthorized || rejected.Token != "" || rejected.ErrorCode != protocol.ErrorCodeUnauthorized {
+		t.Fatalf("unexpected rejected stream entry: %+v", rejected)
+	}
+	if stream.Method != "GET" || stream.Status != "ok" || stream.HTTPStatus != http.StatusOK || stream.Token != "server" || stream.Session != sessionID {
+		t.Fatalf("unexpected stream entry: %+v", stream)
+	}
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/protocol"
+)
+
+// blockingTranscriptionUpstream stands in for the Mistral API: each
+// transcription request is counted, announced on started and held until
+// the caller gives up.
+func blockingTranscriptionUpstream(t *testing.T, attempts *atomic.Int64, started chan<- struct{}) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			http.NotFound(w, r)
+			return
+		}
+		// the body must be drained for a client disconnect to end r.Context.
+		_, _ = io.Copy(io.Discard, r.Body)
+		attempts.Add(1)
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		http.Error(w, `{"error":"too slow"}`, http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+// cancelAfterStart sends notifications/cancelled for requestID once the
+// upstream has received the call, and returns the call's response.
+func cancelAfterStart(t *testing.T, url, sessionID string, started <-chan struct{}, requestID int, call func() *http.Response) rpcEnvelope {
+	t.Helper()
+	responses := make(chan *http.Response, 1)
+	go func() { responses <- call() }()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream never received the call")
+	}
+	cancelResp := postRPC(t, url, sessionID, fmt.Sprintf(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":%d,"reason":"user aborted"}}`, requestID))
+	_ = cancelResp.Body.Close()
+	if cancelResp.StatusCode != http.StatusAccepted {
+		t.Fatalf("cancel status=%d want=%d", cancelResp.StatusCode, http.StatusAccepted)
+	}
+
+	var resp *http.Response
+	select {
+	case resp = <-responses:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled call did not return")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var envelope rpcEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return envelope
+}
+
+func TestMCPToolsCallTranscribe_StreamsProgressSteps(t *testing.T) {
+	cfg, st, _ := setupMCPToolStore(t, "voice.wav", "audio", []byte("RIFF0000WAVEfmt data"))
+	cfg.MistralAPIKey = "test-key"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"segments":[{"start":1,"end":2,"text":"alpha"},{"start":3,"end":4,"text":"beta"}]}`)
+	}))
+	defer upstream.Close()
+	cfg.MistralBaseURL = upstream.URL
+
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithStore(st)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+	sessionID := initializeSession(t, url)
+
+	resp := postRPCWithHeaders(t, url, sessionID,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"dir2mcp.transcribe","arguments":{"rel_path":"voice.wav"},"_meta":{"progressToken":7}}}`,
+		map[string]string{"Accept": "application/json, text/event-stream"})
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected event stream, got Content-Type %q", ct)
+	}
+
+	messages := readSSEMessages(t, resp.Body)
+	if len(messages) != 3 {
+		t.Fatalf("expected two progress steps and a result, got %#v", messages)
+	}
+	for i, want := range []string{"transcribing voice.wav", "indexing transcript of voice.wav"} {
+		params, _ := messages[i]["params"].(map[string]interface{})
+		if messages[i]["method"] != protocol.RPCMethodProgress || params["progressToken"] != float64(7) || params["message"] != want || params["progress"] != float64(i+1) {
+			t.Fatalf("message %d: unexpected progress %#v", i, messages[i])
+		}
+	}
+	result, _ := messages[2]["result"].(map[string]interface{})
+	if messages[2]["id"] != float64(5) || result["isError"] == true {
+		t.Fatalf("unexpected final response: %#v", messages[2])
+	}
+}
+
+func TestMCPNotificationsCancelled_EndsInFlightToolCall(t *testing.T) {
+	cfg, st, _ := setupMCPToolStore(t, "voice.wav", "audio", []byte("RIFF0000WAVEfmt data"))
+	cfg.MistralAPIKey = "test-key"
+	var attempts atomic.Int64
+	started := make(chan struct{}, 4)
+	cfg.MistralBaseURL = blockingTranscriptionUpstream(t, &attempts, started).URL
+
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithStore(st)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+	sessionID := initializeSession(t, url)
+
+	envelope := cancelAfterStart(t, url, sessionID, started, 41, func() *http.Response {
+		return postRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":41,"method":"tools/call","params":{"name":"dir2mcp.transcribe","arguments":{"rel_path":"voice.wav"}}}`)
+	})
+	if envelope.Error == nil || envelope.Error.Code != -32800 || envelope.Error.Data.Code != protocol.ErrorCodeRequestCancelled {
+		t.Fatalf("expected a request cancelled error, got %#v", envelope)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Fatalf("transcription attempts=%d want=1; a cancelled call must not be retried", got)
+	}
+
+	// cancelling a finished or unknown request is a no-op.
+	resp := postRPC(t, url, sessionID, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":41}}`)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusAccepted)
+	}
+}
+
+func TestX402ToolsCall_CancelledCallIsNotSettled(t *testing.T) {
+	fac := newFacilitatorStub(t)
+	facServer := httptest.NewServer(fac)
+	defer facServer.Close()
+
+	toolCfg, st, _ := setupMCPToolStore(t, "voice.wav", "audio", []byte("RIFF0000WAVEfmt data"))
+	cfg := x402EnabledTestConfig("https://resource.example.com")
+	cfg.AuthMode = "none"
+	cfg.X402.FacilitatorURL = facServer.URL
+	cfg.RootDir = toolCfg.RootDir
+	cfg.StateDir = toolCfg.StateDir
+	cfg.MistralAPIKey = "test-key"
+	var attempts atomic.Int64
+	started := make(chan struct{}, 4)
+	cfg.MistralBaseURL = blockingTranscriptionUpstream(t, &attempts, started).URL
+
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithStore(st)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+	sessionID := initializeSession(t, url)
+
+	envelope := cancelAfterStart(t, url, sessionID, started, 42, func() *http.Response {
+		return postRPCWithHeaders(t, url, sessionID, `{"jsonrpc":"2.0","id":42,"method":"tools/call","params":{"name":"dir2mcp.transcribe","arguments":{"rel_path":"voice.wav"}}}`, map[string]string{
+			"PAYMENT-SIGNATURE": "signed-payment-payload",
+		})
+	})
+	if envelope.Error == nil || envelope.Error.Data.Code != protocol.ErrorCodeRequestCancelled {
+		t.Fatalf("expected a request cancelled error, got %#v", envelope)
+	}
+	if fac.verifyCalls.Load() != 1 {
+		t.Fatalf("verify calls=%d want=1", fac.verifyCalls.Load())
+	}
+	if fac.settleCalls.Load() != 0 {
+		t.Fatalf("settle calls=%d want=0 for a cancelled call", fac.settleCalls.Load())
+	}
+}
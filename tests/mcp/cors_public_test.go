@@ -94,8 +94,9 @@ func TestCORS_OptionsWithoutPreflightHeadersFallsThrough(t *testing.T) {
 		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusMethodNotAllowed)
 	}
 
-	if allow := resp.Header.Get("Allow"); allow != http.MethodPost {
-		t.Fatalf("Allow=%q want=%q", allow, http.MethodPost)
+	// GET serves the resource notification stream.
+	if allow, want := resp.Header.Get("Allow"), http.MethodGet+", "+http.MethodPost; allow != want {
+		t.Fatalf("Allow=%q want=%q", allow, want)
 	}
 }
 
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/metrics"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/tokens"
+)
+
+func scrapeMetrics(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := m.Registry.WriteText(&buf); err != nil {
+		t.Fatalf("write metrics: %v", err)
+	}
+	return buf.String()
+}
+
+func assertMetricLines(t *testing.T, text string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(text, line+"\n") {
+			t.Fatalf("missing %q in metrics:\n%s", line, text)
+		}
+	}
+}
+
+func TestMetrics_CountsRequestsToolsAndErrors(t *testing.T) {
+	cfg, st, _ := setupMCPToolStore(t, "docs/a.md", "md", []byte("alpha\n"))
+	cfg = scopedTokenConfig(cfg)
+	secret := createScopedToken(t, cfg.StateDir, tokens.Token{Name: "reader"})
+
+	m := metrics.New()
+	retriever := &askAudioRetrieverStub{
+		OnSearch: func(query model.SearchQuery) ([]model.SearchHit, error) {
+			return []model.SearchHit{{ChunkID: 1, RelPath: "docs/a.md", Snippet: "alpha"}}, nil
+		},
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever, mcp.WithStore(st), mcp.WithMetrics(m)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+
+	sessionID := bearerInitialize(t, url, secret)
+	bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"alpha"}}}`)
+	bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"dir2mcp.nope","arguments":{}}}`)
+	_ = bearerRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":4,"method":"made/up"}`).Body.Close()
+	_ = bearerRPC(t, url, sessionID, "wrong-token", `{"jsonrpc":"2.0","id":5,"method":"tools/list"}`).Body.Close()
+
+	text := scrapeMetrics(t, m)
+	assertMetricLines(t, text,
+		`dir2mcp_mcp_requests_total{method="initialize",tool="",status="ok"} 1`,
+		`dir2mcp_mcp_requests_total{method="tools/call",tool="dir2mcp.search",status="ok"} 1`,
+		`dir2mcp_mcp_requests_total{method="tools/call",tool="unknown",status="error"} 1`,
+		`dir2mcp_mcp_requests_total{method="other",tool="",status="error"} 1`,
+		`dir2mcp_mcp_requests_total{method="none",tool="",status="error"} 1`,
+		`dir2mcp_mcp_errors_total{method="none",code="UNAUTHORIZED"} 1`,
+		`dir2mcp_mcp_request_duration_seconds_count{method="tools/call",tool="dir2mcp.search"} 1`,
+		`dir2mcp_mcp_active_sessions 1`,
+	)
+}
+
+func TestMetrics_CountsRateLimitRejections(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	cfg.Public = true
+	cfg.RateLimitRPS = 1
+	cfg.RateLimitBurst = 1
+
+	m := metrics.New()
+	handler := mcp.NewServer(cfg, nil, mcp.WithMetrics(m)).Handler()
+	for i := 0; i < 3; i++ {
+		_ = initializeRequestFromIP(t, handler, cfg.MCPPath, "198.51.100.60", "127.0.0.1:5060")
+	}
+	if rr := initializeRequestFromIP(t, handler, cfg.MCPPath, "198.51.100.60", "127.0.0.1:5060"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status=%d want 429", rr.Code)
+	}
+
+	assertMetricLines(t, scrapeMetrics(t, m),
+		`dir2mcp_rate_limit_rejections_total{limiter="ip"} 3`,
+		`dir2mcp_mcp_requests_total{method="initialize",tool="",status="ok"} 1`,
+	)
+}
+
+func TestMetrics_CountsX402Outcomes(t *testing.T) {
+	fac := newFacilitatorStub(t)
+	fac.verifyStatus = http.StatusOK
+	fac.verifyBody = `{"ok":true,"kind":"verify"}`
+	fac.settleStatus = http.StatusServiceUnavailable
+	fac.settleBody = `{"message":"temporary outage"}`
+	facServer := httptest.NewServer(fac)
+	defer facServer.Close()
+
+	cfg := x402EnabledTestConfig("https://resource.example.com")
+	cfg.AuthMode = "none"
+	cfg.X402.FacilitatorURL = facServer.URL
+
+	m := metrics.New()
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithMetrics(m)).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPCWithHeaders(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"dir2mcp.stats","arguments":{}}}`, map[string]string{
+		"PAYMENT-SIGNATURE": "signed-payment-payload",
+	})
+	_ = resp.Body.Close()
+
+	assertMetricLines(t, scrapeMetrics(t, m),
+		`dir2mcp_x402_operations_total{stage="verify",outcome="ok"} 1`,
+		`dir2mcp_x402_operations_total{stage="settle",outcome="PAYMENT_SETTLEMENT_UNAVAILABLE"} 1`,
+	)
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+type sseEvent struct {
+	id      string
+	hasID   bool
+	message map[string]interface{}
+}
+
+// openEventStream issues the GET stream request and returns a channel of
+// parsed events; the channel closes when the stream ends.
+func openEventStream(t *testing.T, ctx context.Context, url, sessionID, lastEventID string) (*http.Response, <-chan sseEvent) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(protocol.MCPSessionHeader, sessionID)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusOK)
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var current sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id:"):
+				current.id, current.hasID = strings.TrimSpace(strings.TrimPrefix(line, "id:")), true
+			case strings.HasPrefix(line, "data:"):
+				_ = json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &current.message)
+			case line == "" && current.message != nil:
+				events <- current
+				current = sseEvent{}
+			}
+		}
+	}()
+	return resp, events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event stream ended")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return sseEvent{}
+}
+
+func assertEvent(t *testing.T, ev sseEvent, wantID, wantMethod, wantURI string) {
+	t.Helper()
+	if ev.message["method"] != wantMethod {
+		t.Fatalf("method = %v, want %s (event %#v)", ev.message["method"], wantMethod, ev)
+	}
+	if (wantID != "") != ev.hasID || ev.id != wantID {
+		t.Fatalf("id = %q (present=%v), want %q", ev.id, ev.hasID, wantID)
+	}
+	if wantURI != "" {
+		params, _ := ev.message["params"].(map[string]interface{})
+		if params["uri"] != wantURI {
+			t.Fatalf("uri = %v, want %s", params["uri"], wantURI)
+		}
+	}
+}
+
+func newNotifyingServer(t *testing.T) (*mcp.Server, string) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	srv := mcp.NewServer(cfg, nil)
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+	return srv, server.URL + cfg.MCPPath
+}
+
+func TestMCPEventStream_DeliversSubscribedUpdatesAndListChanges(t *testing.T) {
+	srv, url := newNotifyingServer(t)
+	sessionID := initializeSession(t, url)
+	for i, uri := range []string{"dir2mcp://file/docs/a.md", "dir2mcp://file/docs/a.md#L1-5", "dir2mcp://file/docs/gone.md"} {
+		envelope := callRPC(t, url, sessionID, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"resources/subscribe","params":{"uri":%q}}`, 2+i, uri))
+		if envelope.Error != nil {
+			t.Fatalf("subscribe %s failed: %#v", uri, envelope.Error)
+		}
+	}
+	if envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":9,"method":"resources/unsubscribe","params":{"uri":"dir2mcp://file/docs/gone.md"}}`); envelope.Error != nil {
+		t.Fatalf("unsubscribe failed: %#v", envelope.Error)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, events := openEventStream(t, ctx, url, sessionID, "")
+
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "docs/gone.md", Kind: model.DocumentUpdated})
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "docs/a.md", Kind: model.DocumentUpdated})
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "docs/new.md", Kind: model.DocumentAdded})
+
+	assertEvent(t, nextEvent(t, events), "2", protocol.RPCMethodResourcesUpdated, "dir2mcp://file/docs/a.md")
+	assertEvent(t, nextEvent(t, events), "2", protocol.RPCMethodResourcesUpdated, "dir2mcp://file/docs/a.md#L1-5")
+	assertEvent(t, nextEvent(t, events), "3", protocol.RPCMethodResourcesListChanged, "")
+}
+
+func TestMCPEventStream_ResumesAfterLastEventID(t *testing.T) {
+	srv, url := newNotifyingServer(t)
+	sessionID := initializeSession(t, url)
+
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "a.md", Kind: model.DocumentAdded})
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "a.md", Kind: model.DocumentUpdated})
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "b.md", Kind: model.DocumentDeleted})
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "c.md", Kind: model.DocumentDeleted})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// event 2 (an update nobody subscribed to) is skipped, and the two
+	// deletions collapse into one list_changed.
+	_, events := openEventStream(t, ctx, url, sessionID, "1")
+	assertEvent(t, nextEvent(t, events), "4", protocol.RPCMethodResourcesListChanged, "")
+
+	// an id from an earlier server run means events were missed.
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	_, missed := openEventStream(t, ctx2, url, sessionID, "999")
+	assertEvent(t, nextEvent(t, missed), "", protocol.RPCMethodResourcesListChanged, "")
+	assertEvent(t, nextEvent(t, missed), "1", protocol.RPCMethodResourcesListChanged, "")
+}
+
+func TestMCPEventStream_NewStreamReplacesPrevious(t *testing.T) {
+	_, url := newNotifyingServer(t)
+	sessionID := initializeSession(t, url)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, first := openEventStream(t, ctx, url, sessionID, "")
+	_, _ = openEventStream(t, ctx, url, sessionID, "")
+
+	select {
+	case _, ok := <-first:
+		if ok {
+			t.Fatal("expected the first stream to end without events")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("first stream was not closed")
+	}
+}
+
+func TestMCPEventStream_RequiresSessionAndAccept(t *testing.T) {
+	_, url := newNotifyingServer(t)
+	sessionID := initializeSession(t, url)
+
+	cases := []struct {
+		name    string
+		session string
+		accept  string
+		want    int
+	}{
+		{name: "missing accept", session: sessionID, accept: "application/json", want: http.StatusNotAcceptable},
+		{name: "missing session", accept: "text/event-stream", want: http.StatusNotFound},
+		{name: "unknown session", session: "nope", accept: "text/event-stream", want: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			if err != nil {
+				t.Fatalf("create request: %v", err)
+			}
+			req.Header.Set("Accept", tc.accept)
+			if tc.session != "" {
+				req.Header.Set(protocol.MCPSessionHeader, tc.session)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("do request: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Fatalf("status=%d want=%d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/protocol"
+	"dir2mcp/tests/testutil"
+)
+
+const oauthTestIssuer = "https://issuer.example.com"
+
+func oauthServer(t *testing.T, cfg config.Config, opts ...mcp.ServerOption) (*httptest.Server, *testutil.JWTIssuer) {
+	t.Helper()
+	issuer := testutil.NewJWTIssuer(t)
+	cfg.AuthMode = "oauth"
+	cfg.OAuth.Issuer = oauthTestIssuer
+	cfg.OAuth.JWKSFile = issuer.JWKSPath
+	cfg.OAuth.ResourceURL = "https://mcp.example.com" + cfg.MCPPath
+	if err := cfg.ValidateOAuth(); err != nil {
+		t.Fatalf("validate oauth config: %v", err)
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, opts...).Handler())
+	t.Cleanup(server.Close)
+	return server, issuer
+}
+
+func oauthAccessToken(t *testing.T, issuer *testutil.JWTIssuer, cfg config.Config, scope string) string {
+	t.Helper()
+	return issuer.Sign(t, map[string]interface{}{
+		"iss":   oauthTestIssuer,
+		"aud":   "https://mcp.example.com" + cfg.MCPPath,
+		"sub":   "alice",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": scope,
+	})
+}
+
+func TestOAuth_ProtectedResourceMetadata(t *testing.T) {
+	cfg := config.Default()
+	cfg.StateDir = t.TempDir()
+	server, _ := oauthServer(t, cfg)
+
+	for _, path := range []string{"/.well-known/oauth-protected-resource", "/.well-known/oauth-protected-resource" + cfg.MCPPath} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		var metadata struct {
+			Resource             string   `json:"resource"`
+			AuthorizationServers []string `json:"authorization_servers"`
+			BearerMethods        []string `json:"bearer_methods_supported"`
+			ScopesSupported      []string `json:"scopes_supported"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&metadata)
+		_ = resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status=%d err=%v", path, resp.StatusCode, err)
+		}
+		if metadata.Resource != "https://mcp.example.com"+cfg.MCPPath ||
+			len(metadata.AuthorizationServers) != 1 || metadata.AuthorizationServers[0] != oauthTestIssuer ||
+			len(metadata.BearerMethods) != 1 || metadata.BearerMethods[0] != "header" {
+			t.Fatalf("%s: unexpected metadata %+v", path, metadata)
+		}
+		if len(metadata.ScopesSupported) == 0 || metadata.ScopesSupported[0] != "dir2mcp:tools:*" {
+			t.Fatalf("%s: scopes_supported=%v", path, metadata.ScopesSupported)
+		}
+	}
+}
+
+func TestOAuth_UnauthorizedResponsesCarryChallenge(t *testing.T) {
+	cfg := config.Default()
+	cfg.StateDir = t.TempDir()
+	server, issuer := oauthServer(t, cfg)
+	url := server.URL + cfg.MCPPath
+	metadataURL := `resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource` + cfg.MCPPath + `"`
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`
+
+	resp := postRPCWithHeaders(t, url, "", initialize, nil)
+	_ = resp.Body.Close()
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(challenge, "Bearer ") || !strings.Contains(challenge, metadataURL) {
+		t.Fatalf("missing token: status=%d WWW-Authenticate=%q", resp.StatusCode, challenge)
+	}
+	if strings.Contains(challenge, "error=") {
+		t.Fatalf("a request without credentials must not carry an error code: %q", challenge)
+	}
+
+	expired := issuer.Sign(t, map[string]interface{}{
+		"iss":   oauthTestIssuer,
+		"aud":   "https://mcp.example.com" + cfg.MCPPath,
+		"exp":   time.Now().Add(-time.Hour).Unix(),
+		"scope": "dir2mcp:tools:*",
+	})
+	resp = bearerRPC(t, url, "", expired, initialize)
+	_ = resp.Body.Close()
+	challenge = resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(challenge, `error="invalid_token"`) || !strings.Contains(challenge, metadataURL) {
+		t.Fatalf("expired token: status=%d WWW-Authenticate=%q", resp.StatusCode, challenge)
+	}
+
+	resp = bearerRPC(t, url, "", oauthAccessToken(t, issuer, cfg, "openid"), initialize)
+	_ = resp.Body.Close()
+	challenge = resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(challenge, `error="insufficient_scope"`) || !strings.Contains(challenge, `scope="dir2mcp:tools:*"`) {
+		t.Fatalf("token without tool scopes: status=%d WWW-Authenticate=%q", resp.StatusCode, challenge)
+	}
+}
+
+func TestOAuth_ScopesLimitTools(t *testing.T) {
+	cfg, st, _ := setupMCPToolStore(t, "docs/a.md", "md", []byte("alpha"))
+	server, issuer := oauthServer(t, cfg, mcp.WithStore(st))
+	url := server.URL + cfg.MCPPath
+
+	secret := oauthAccessToken(t, issuer, cfg, "dir2mcp:tools:search")
+	sessionID := bearerInitialize(t, url, secret)
+	names := listedToolNames(t, bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if len(names) != 1 || names[0] != protocol.ToolNameSearch {
+		t.Fatalf("scoped tools/list=%v want only %s", names, protocol.ToolNameSearch)
+	}
+	envelope := bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"dir2mcp.list_files","arguments":{}}}`)
+	if code := toolErrorCode(envelope); code != protocol.ErrorCodePermissionDenied {
+		t.Fatalf("list_files with a search-only token: code=%q want %s", code, protocol.ErrorCodePermissionDenied)
+	}
+
+	full := oauthAccessToken(t, issuer, cfg, "dir2mcp:tools:*")
+	fullSession := bearerInitialize(t, url, full)
+	if names := listedToolNames(t, bearerCallRPC(t, url, fullSession, full, `{"jsonrpc":"2.0","id":4,"method":"tools/list"}`)); len(names) != len(mcp.ToolNames()) {
+		t.Fatalf("tools:* tools/list=%v want all tools", names)
+	}
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// symbolPromptRetriever adds a fixed symbol table to a real retrieval
+// service.
+type symbolPromptRetriever struct {
+	*retrieval.Service
+	definitions []model.Symbol
+}
+
+func (r *symbolPromptRetriever) Symbols(context.Context, model.SymbolQuery) ([]model.Symbol, error) {
+	return r.definitions, nil
+}
+
+func (r *symbolPromptRetriever) Definitions(_ context.Context, query model.SymbolQuery) ([]model.Symbol, error) {
+	var out []model.Symbol
+	for _, def := range r.definitions {
+		if def.Name == query.Name {
+			out = append(out, def)
+		}
+	}
+	return out, nil
+}
+
+func (r *symbolPromptRetriever) References(context.Context, model.ReferenceQuery, func(model.GrepMatch)) (model.ReferenceResult, error) {
+	return model.ReferenceResult{}, nil
+}
+
+func newPromptServer(t *testing.T, files map[string]string, prompts []config.PromptTemplate, st model.Store, definitions []model.Symbol) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	for relPath, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	svc := retrieval.NewService(nil, nil, nil, nil)
+	svc.SetRootDir(root)
+	svc.SetPathExcludes([]string{"private/**"})
+
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	cfg.RootDir = root
+	cfg.Prompts = prompts
+	if err := config.ValidatePrompts(cfg.Prompts); err != nil {
+		t.Fatalf("invalid prompts: %v", err)
+	}
+	opts := []mcp.ServerOption{}
+	if st != nil {
+		opts = append(opts, mcp.WithStore(st))
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, &symbolPromptRetriever{Service: svc, definitions: definitions}, opts...).Handler())
+	t.Cleanup(server.Close)
+	url := server.URL + cfg.MCPPath
+	return url, initializeSession(t, url)
+}
+
+// promptMessages returns the content entries of a prompts/get result.
+func promptMessages(t *testing.T, envelope rpcEnvelope) []map[string]interface{} {
+	t.Helper()
+	if envelope.Error != nil {
+		t.Fatalf("unexpected error: %#v", envelope.Error)
+	}
+	raw, _ := envelope.Result["messages"].([]interface{})
+	out := make([]map[string]interface{}, 0, len(raw))
+	for _, item := range raw {
+		message, _ := item.(map[string]interface{})
+		if message["role"] != "user" {
+			t.Fatalf("unexpected role in %#v", message)
+		}
+		content, _ := message["content"].(map[string]interface{})
+		out = append(out, content)
+	}
+	return out
+}
+
+func TestMCPPromptsList_IncludesBuiltinAndConfiguredPrompts(t *testing.T) {
+	url, sessionID := newPromptServer(t, nil, []config.PromptTemplate{
+		{Name: "standup", Description: "Daily summary", Template: "Summarize."},
+		{Name: "summarize_file", Description: "House style summary", Arguments: []config.PromptArgument{{Name: "rel_path", Required: true}}, Template: "TL;DR of {{rel_path}}."},
+	}, nil, nil)
+
+	envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"prompts/list"}`)
+	if envelope.Error != nil {
+		t.Fatalf("unexpected error: %#v", envelope.Error)
+	}
+	prompts, _ := envelope.Result["prompts"].([]interface{})
+	var names []string
+	descriptions := map[string]interface{}{}
+	for _, raw := range prompts {
+		prompt, _ := raw.(map[string]interface{})
+		name := fmt.Sprint(prompt["name"])
+		names = append(names, name)
+		descriptions[name] = prompt["description"]
+	}
+	if got, want := strings.Join(names, ","), "summarize_file,explain_symbol,compare_files,onboarding_tour,standup"; got != want {
+		t.Fatalf("prompt names = %s, want %s", got, want)
+	}
+	if descriptions["summarize_file"] != "House style summary" {
+		t.Fatalf("expected the configured summarize_file to replace the built-in, got %#v", descriptions["summarize_file"])
+	}
+}
+
+func TestMCPPromptsGet_EmbedsFilesForPathArguments(t *testing.T) {
+	url, sessionID := newPromptServer(t, map[string]string{
+		"docs/a.md": "alpha\n",
+		"docs/b.md": "beta\n",
+	}, nil, nil, nil)
+
+	envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"compare_files","arguments":{"rel_path_a":"docs/a.md","rel_path_b":"docs/b.md","focus":"tone"}}}`)
+	messages := promptMessages(t, envelope)
+	if len(messages) != 3 {
+		t.Fatalf("expected two embedded files and the instruction, got %#v", messages)
+	}
+	for i, want := range []struct{ uri, text string }{
+		{uri: "dir2mcp://file/docs/a.md", text: "alpha\n"},
+		{uri: "dir2mcp://file/docs/b.md", text: "beta\n"},
+	} {
+		resource, _ := messages[i]["resource"].(map[string]interface{})
+		if messages[i]["type"] != "resource" || resource["uri"] != want.uri || resource["text"] != want.text || resource["mimeType"] != "text/markdown" {
+			t.Fatalf("message %d = %#v", i, messages[i])
+		}
+	}
+	text, _ := messages[2]["text"].(string)
+	if !strings.Contains(text, "Compare docs/a.md with docs/b.md") || !strings.Contains(text, "Focus on: tone.") {
+		t.Fatalf("unexpected instruction: %q", text)
+	}
+}
+
+func TestMCPPromptsGet_EmbedsSymbolDefinitions(t *testing.T) {
+	url, sessionID := newPromptServer(t, map[string]string{
+		"pkg/run.go": "package pkg\n\nfunc Run() error {\n\treturn nil\n}\n",
+	}, nil, nil, []model.Symbol{
+		{RelPath: "pkg/run.go", Name: "Run", Kind: model.SymbolKindFunction, Span: model.Span{Kind: "lines", StartLine: 3, EndLine: 5}},
+	})
+
+	messages := promptMessages(t, callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"explain_symbol","arguments":{"name":"Run"}}}`))
+	if len(messages) != 2 {
+		t.Fatalf("expected the definition and the instruction, got %#v", messages)
+	}
+	resource, _ := messages[0]["resource"].(map[string]interface{})
+	if resource["uri"] != "dir2mcp://file/pkg/run.go#L3-5" || resource["text"] != "func Run() error {\n\treturn nil\n}" {
+		t.Fatalf("unexpected definition: %#v", resource)
+	}
+
+	missing := promptMessages(t, callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":{"name":"explain_symbol","arguments":{"name":"Stop"}}}`))
+	if text, _ := missing[len(missing)-1]["text"].(string); len(missing) != 1 || !strings.Contains(text, "No indexed definition of Stop was found.") {
+		t.Fatalf("unexpected messages for an unknown symbol: %#v", missing)
+	}
+}
+
+func TestMCPPromptsGet_OnboardingTourListsIndexedFiles(t *testing.T) {
+	st := &pagedDocumentStore{docs: []model.Document{
+		{RelPath: "README.md"},
+		{RelPath: "old.md", Deleted: true},
+		{RelPath: "cmd/main.go"},
+	}}
+	url, sessionID := newPromptServer(t, nil, nil, st, nil)
+
+	messages := promptMessages(t, callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"onboarding_tour"}}`))
+	if len(messages) != 2 {
+		t.Fatalf("expected the overview and the instruction, got %#v", messages)
+	}
+	overview, _ := messages[0]["text"].(string)
+	if !strings.Contains(overview, "\n- README.md\n- cmd/main.go") || strings.Contains(overview, "old.md") {
+		t.Fatalf("unexpected overview: %q", overview)
+	}
+}
+
+func TestMCPPromptsGet_ValidatesTypedArguments(t *testing.T) {
+	url, sessionID := newPromptServer(t, map[string]string{
+		"notes.txt":       "hello",
+		"private/key.txt": "secret",
+	}, []config.PromptTemplate{{
+		Name: "triage",
+		Arguments: []config.PromptArgument{
+			{Name: "limit", Type: config.PromptArgumentInteger, Required: true},
+			{Name: "urgent", Type: config.PromptArgumentBoolean},
+		},
+		Template: "List {{limit}} issues.{{#urgent}} Urgent: {{urgent}}.{{/urgent}}",
+	}}, nil, nil)
+
+	ok := promptMessages(t, callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"triage","arguments":{"limit":" 07 ","urgent":"TRUE"}}}`))
+	if text, _ := ok[0]["text"].(string); text != "List 7 issues. Urgent: true." {
+		t.Fatalf("unexpected rendering: %q", text)
+	}
+
+	cases := []struct {
+		name          string
+		params        string
+		rpcCode       int
+		canonicalCode string
+	}{
+		{name: "missing name", params: `{}`, rpcCode: -32602, canonicalCode: "MISSING_FIELD"},
+		{name: "unknown prompt", params: `{"name":"nope"}`, rpcCode: -32602, canonicalCode: "INVALID_FIELD"},
+		{name: "missing required", params: `{"name":"triage","arguments":{"urgent":"true"}}`, rpcCode: -32602, canonicalCode: "MISSING_FIELD"},
+		{name: "bad integer", params: `{"name":"triage","arguments":{"limit":"many"}}`, rpcCode: -32602, canonicalCode: "INVALID_FIELD"},
+		{name: "bad boolean", params: `{"name":"triage","arguments":{"limit":"1","urgent":"maybe"}}`, rpcCode: -32602, canonicalCode: "INVALID_FIELD"},
+		{name: "unknown argument", params: `{"name":"triage","arguments":{"limit":"1","owner":"me"}}`, rpcCode: -32602, canonicalCode: "INVALID_FIELD"},
+		{name: "excluded path", params: `{"name":"summarize_file","arguments":{"rel_path":"private/key.txt"}}`, rpcCode: -32602, canonicalCode: "PERMISSION_DENIED"},
+		{name: "missing file", params: `{"name":"summarize_file","arguments":{"rel_path":"absent.txt"}}`, rpcCode: -32002, canonicalCode: "FILE_NOT_FOUND"},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			envelope := callRPC(t, url, sessionID, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"prompts/get","params":%s}`, 10+i, tc.params))
+			if envelope.Error == nil {
+				t.Fatalf("expected an error, got %#v", envelope.Result)
+			}
+			if envelope.Error.Code != tc.rpcCode || envelope.Error.Data.Code != tc.canonicalCode {
+				t.Fatalf("error = %d/%s, want %d/%s", envelope.Error.Code, envelope.Error.Data.Code, tc.rpcCode, tc.canonicalCode)
+			}
+		})
+	}
+}
+
+func TestMCPPrompts_DisabledWhenX402Enabled(t *testing.T) {
+	cfg := x402EnabledTestConfig("https://resource.example.com")
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, nil).Handler())
+	defer server.Close()
+
+	url := server.URL + cfg.MCPPath
+	sessionID := initializeSession(t, url)
+	envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"prompts/list"}`)
+	if envelope.Error == nil || envelope.Error.Code != -32601 {
+		t.Fatalf("expected method not found while x402 gating is on, got %#v", envelope)
+	}
+}
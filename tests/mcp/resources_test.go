@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// pagedDocumentStore serves ListFiles from a fixed document list.
+type pagedDocumentStore struct {
+	failingListFilesStore
+	docs []model.Document
+}
+
+func (s *pagedDocumentStore) ListFiles(_ context.Context, _, _ string, limit, offset int) ([]model.Document, int64, error) {
+	total := int64(len(s.docs))
+	if offset >= len(s.docs) {
+		return nil, total, nil
+	}
+	end := offset + limit
+	if end > len(s.docs) {
+		end = len(s.docs)
+	}
+	return s.docs[offset:end], total, nil
+}
+
+type rpcEnvelope struct {
+	Result map[string]interface{} `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			Code string `json:"code"`
+		} `json:"data"`
+	} `json:"error"`
+}
+
+func callRPC(t *testing.T, url, sessionID, body string) rpcEnvelope {
+	t.Helper()
+	resp := postRPC(t, url, sessionID, body)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusOK)
+	}
+	var envelope rpcEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return envelope
+}
+
+// newResourceServer serves files from a temp root through a real retrieval
+// service, listing docs from the given store.
+func newResourceServer(t *testing.T, files map[string][]byte, st model.Store) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	for relPath, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	svc := retrieval.NewService(nil, nil, nil, nil)
+	svc.SetRootDir(root)
+	svc.SetPathExcludes([]string{"private/**"})
+
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	cfg.RootDir = root
+	opts := []mcp.ServerOption{}
+	if st != nil {
+		opts = append(opts, mcp.WithStore(st))
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, svc, opts...).Handler())
+	t.Cleanup(server.Close)
+	url := server.URL + cfg.MCPPath
+	return url, initializeSession(t, url)
+}
+
+func TestMCPInitialize_AdvertisesResources(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, nil).Handler())
+	defer server.Close()
+
+	resp := postRPC(t, server.URL+cfg.MCPPath, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var envelope rpcEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	capabilities, _ := envelope.Result["capabilities"].(map[string]interface{})
+	if _, ok := capabilities["resources"].(map[string]interface{}); !ok {
+		t.Fatalf("expected resources capability, got %#v", capabilities)
+	}
+}
+
+func TestMCPResourcesList_PaginatesIndexedDocuments(t *testing.T) {
+	st := &pagedDocumentStore{}
+	st.docs = append(st.docs, model.Document{RelPath: "docs/read me.md", SizeBytes: 12, MTimeUnix: 1700000000})
+	st.docs = append(st.docs, model.Document{RelPath: "gone.txt", Deleted: true})
+	for i := 0; i < 203; i++ {
+		st.docs = append(st.docs, model.Document{RelPath: fmt.Sprintf("src/f%03d.go", i)})
+	}
+	url, sessionID := newResourceServer(t, nil, st)
+
+	first := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)
+	resources, _ := first.Result["resources"].([]interface{})
+	if len(resources) != 199 {
+		t.Fatalf("expected 199 resources on the first page (200 minus a deleted doc), got %d", len(resources))
+	}
+	if first.Result["nextCursor"] != "200" {
+		t.Fatalf("expected nextCursor 200, got %#v", first.Result["nextCursor"])
+	}
+	doc, _ := resources[0].(map[string]interface{})
+	if doc["uri"] != "dir2mcp://file/docs/read%20me.md" || doc["name"] != "docs/read me.md" || doc["mimeType"] != "text/markdown" {
+		t.Fatalf("unexpected resource entry: %#v", doc)
+	}
+	annotations, _ := doc["annotations"].(map[string]interface{})
+	if annotations["lastModified"] != "2023-11-14T22:13:20Z" {
+		t.Fatalf("unexpected lastModified: %#v", annotations)
+	}
+
+	second := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":3,"method":"resources/list","params":{"cursor":"200"}}`)
+	if rest, _ := second.Result["resources"].([]interface{}); len(rest) != 5 {
+		t.Fatalf("expected 5 resources on the last page, got %d", len(rest))
+	}
+	if _, ok := second.Result["nextCursor"]; ok {
+		t.Fatalf("expected no nextCursor on the last page, got %#v", second.Result["nextCursor"])
+	}
+
+	bad := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":4,"method":"resources/list","params":{"cursor":"nope"}}`)
+	if bad.Error == nil || bad.Error.Code != -32602 {
+		t.Fatalf("expected invalid params for a bad cursor, got %#v", bad)
+	}
+}
+
+func TestMCPResourceTemplatesList_CoversSpanKinds(t *testing.T) {
+	url, sessionID := newResourceServer(t, nil, nil)
+
+	envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"resources/templates/list"}`)
+	templates, _ := envelope.Result["resourceTemplates"].([]interface{})
+	got := map[string]bool{}
+	for _, raw := range templates {
+		tmpl, _ := raw.(map[string]interface{})
+		got[fmt.Sprint(tmpl["uriTemplate"])] = true
+	}
+	for _, want := range []string{
+		"dir2mcp://file/{+rel_path}",
+		"dir2mcp://file/{+rel_path}#L{start_line}-{end_line}",
+		"dir2mcp://file/{+rel_path}#page={page}",
+		"dir2mcp://file/{+rel_path}#t={start_ms}-{end_ms}",
+	} {
+		if !got[want] {
+			t.Fatalf("missing template %s in %#v", want, templates)
+		}
+	}
+}
+
+func TestMCPResourcesRead_ReturnsTextBlobsAndSpans(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0xff}
+	url, sessionID := newResourceServer(t, map[string][]byte{
+		"docs/read me.md": []byte("one\ntwo\nthree\nfour\n"),
+		"img/logo.png":    png,
+	}, nil)
+
+	cases := []struct {
+		name     string
+		uri      string
+		mimeType string
+		text     string
+		blob     []byte
+	}{
+		{name: "whole text file", uri: "dir2mcp://file/docs/read%20me.md", mimeType: "text/markdown", text: "one\ntwo\nthree\nfour\n"},
+		{name: "line span", uri: "dir2mcp://file/docs/read%20me.md#L2-3", mimeType: "text/markdown", text: "two\nthree"},
+		{name: "binary blob", uri: "dir2mcp://file/img/logo.png", mimeType: "image/png", blob: png},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			envelope := callRPC(t, url, sessionID, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"resources/read","params":{"uri":%q}}`, 10+i, tc.uri))
+			if envelope.Error != nil {
+				t.Fatalf("unexpected error: %#v", envelope.Error)
+			}
+			contents, _ := envelope.Result["contents"].([]interface{})
+			if len(contents) != 1 {
+				t.Fatalf("expected one contents entry, got %#v", envelope.Result)
+			}
+			item, _ := contents[0].(map[string]interface{})
+			if item["uri"] != tc.uri || item["mimeType"] != tc.mimeType {
+				t.Fatalf("unexpected contents metadata: %#v", item)
+			}
+			if tc.blob != nil {
+				if item["blob"] != base64.StdEncoding.EncodeToString(tc.blob) {
+					t.Fatalf("unexpected blob: %#v", item["blob"])
+				}
+				return
+			}
+			if text, _ := item["text"].(string); text != tc.text {
+				t.Fatalf("text = %q, want %q", text, tc.text)
+			}
+		})
+	}
+}
+
+func TestMCPResourcesRead_RejectsBadURIsAndBlockedPaths(t *testing.T) {
+	url, sessionID := newResourceServer(t, map[string][]byte{
+		"private/key.txt": []byte("secret"),
+		"notes.txt":       []byte("hello"),
+	}, nil)
+
+	cases := []struct {
+		name          string
+		params        string
+		rpcCode       int
+		canonicalCode string
+	}{
+		{name: "missing uri", params: `{}`, rpcCode: -32602, canonicalCode: "MISSING_FIELD"},
+		{name: "foreign scheme", params: `{"uri":"file:///etc/passwd"}`, rpcCode: -32602, canonicalCode: "INVALID_FIELD"},
+		{name: "bad fragment", params: `{"uri":"dir2mcp://file/notes.txt#L5-2"}`, rpcCode: -32602, canonicalCode: "INVALID_FIELD"},
+		{name: "excluded path", params: `{"uri":"dir2mcp://file/private/key.txt"}`, rpcCode: -32602, canonicalCode: "PERMISSION_DENIED"},
+		{name: "outside root", params: `{"uri":"dir2mcp://file/..%2Foutside.txt"}`, rpcCode: -32602, canonicalCode: "PATH_OUTSIDE_ROOT"},
+		{name: "missing file", params: `{"uri":"dir2mcp://file/absent.txt"}`, rpcCode: -32002, canonicalCode: "FILE_NOT_FOUND"},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			envelope := callRPC(t, url, sessionID, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"resources/read","params":%s}`, 20+i, tc.params))
+			if envelope.Error == nil {
+				t.Fatalf("expected an error, got %#v", envelope.Result)
+			}
+			if envelope.Error.Code != tc.rpcCode || envelope.Error.Data.Code != tc.canonicalCode {
+				t.Fatalf("error = %d/%s, want %d/%s", envelope.Error.Code, envelope.Error.Data.Code, tc.rpcCode, tc.canonicalCode)
+			}
+		})
+	}
+}
+
+func TestMCPResources_DisabledWhenX402Enabled(t *testing.T) {
+	cfg := x402EnabledTestConfig("https://resource.example.com")
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, nil).Handler())
+	defer server.Close()
+
+	url := server.URL + cfg.MCPPath
+	sessionID := initializeSession(t, url)
+	envelope := callRPC(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"dir2mcp://file/a.txt"}}`)
+	if envelope.Error == nil || envelope.Error.Code != -32601 {
+		t.Fatalf("expected method not found while x402 gating is on, got %#v", envelope)
+	}
+}
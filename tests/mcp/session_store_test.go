@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/protocol"
+	"dir2mcp/internal/sessions"
+)
+
+func TestSessionStore_SessionSurvivesRestartAndIsSharedAcrossServers(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	path := filepath.Join(t.TempDir(), sessions.FileName)
+
+	first, err := sessions.Open(path)
+	if err != nil {
+		t.Fatalf("open session store: %v", err)
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithSessionStore(first)).Handler())
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	server.Close()
+	if err := first.Close(); err != nil {
+		t.Fatalf("close session store: %v", err)
+	}
+
+	// a restarted server and a second process on the same state directory
+	// both accept the session without a new initialize.
+	for _, name := range []string{"restarted", "second"} {
+		st, err := sessions.Open(path)
+		if err != nil {
+			t.Fatalf("%s: open session store: %v", name, err)
+		}
+		server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithSessionStore(st)).Handler())
+		resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{}}`)
+		payload, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		server.Close()
+		_ = st.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status=%d want=%d body=%s", name, resp.StatusCode, http.StatusOK, string(payload))
+		}
+	}
+
+	// without the store the session is unknown, as before.
+	server = httptest.NewServer(mcp.NewServer(cfg, nil).Handler())
+	defer server.Close()
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":3,"method":"tools/list","params":{}}`)
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("memory-only server: status=%d want=%d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestSessionStore_ExpiredSessionIsRejectedByOtherServer(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	cfg.SessionInactivityTimeout = 50 * time.Millisecond
+	cfg.SessionMaxLifetime = 0
+	path := filepath.Join(t.TempDir(), sessions.FileName)
+
+	var urls []string
+	for i := 0; i < 2; i++ {
+		st, err := sessions.Open(path)
+		if err != nil {
+			t.Fatalf("open session store: %v", err)
+		}
+		t.Cleanup(func() { _ = st.Close() })
+		server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithSessionStore(st)).Handler())
+		t.Cleanup(server.Close)
+		urls = append(urls, server.URL+cfg.MCPPath)
+	}
+
+	sessionID := initializeSession(t, urls[0])
+	time.Sleep(500 * time.Millisecond)
+
+	resp := postRPC(t, urls[1], sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{}}`)
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusNotFound)
+	}
+	if got := resp.Header.Get(protocol.MCPSessionExpiredHeader); got != sessions.ReasonInactivity {
+		t.Fatalf("expired header=%q want=%q", got, sessions.ReasonInactivity)
+	}
+}
+
+func TestSessionStore_ReinitializeEndsPreviousSessionEverywhere(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	path := filepath.Join(t.TempDir(), sessions.FileName)
+
+	var urls []string
+	for i := 0; i < 2; i++ {
+		st, err := sessions.Open(path)
+		if err != nil {
+			t.Fatalf("open session store: %v", err)
+		}
+		t.Cleanup(func() { _ = st.Close() })
+		server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithSessionStore(st)).Handler())
+		t.Cleanup(server.Close)
+		urls = append(urls, server.URL+cfg.MCPPath)
+	}
+
+	previous := initializeSession(t, urls[0])
+	resp := postRPC(t, urls[0], previous, `{"jsonrpc":"2.0","id":2,"method":"initialize","params":{}}`)
+	_ = resp.Body.Close()
+	current := resp.Header.Get(protocol.MCPSessionHeader)
+	if resp.StatusCode != http.StatusOK || current == "" || current == previous {
+		t.Fatalf("re-initialize: status=%d session=%q", resp.StatusCode, current)
+	}
+
+	for _, tc := range []struct {
+		sessionID string
+		want      int
+	}{
+		{previous, http.StatusNotFound},
+		{current, http.StatusOK},
+	} {
+		for _, url := range urls {
+			resp := postRPC(t, url, tc.sessionID, `{"jsonrpc":"2.0","id":3,"method":"tools/list","params":{}}`)
+			_ = resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Fatalf("session %s on %s: status=%d want=%d", tc.sessionID, url, resp.StatusCode, tc.want)
+			}
+		}
+	}
+}
+
+func TestSessionStore_StdioSessionEndsWithTheConnection(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	st, err := sessions.Open(filepath.Join(t.TempDir(), sessions.FileName))
+	if err != nil {
+		t.Fatalf("open session store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	var out bytes.Buffer
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}` + "\n")
+	if err := mcp.NewServer(cfg, nil, mcp.WithSessionStore(st)).ServeStdio(ctx, in, &out); err != nil {
+		t.Fatalf("ServeStdio failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `"protocolVersion"`) {
+		t.Fatalf("expected an initialize result, got %q", out.String())
+	}
+	n, err := st.CountActive(ctx, time.Now(), time.Hour, 0)
+	if err != nil || n != 0 {
+		t.Fatalf("CountActive=%d err=%v want 0 after the connection closed", n, err)
+	}
+}
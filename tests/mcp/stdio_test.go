@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+// stdioSession drives ServeStdio over pipes, one JSON-RPC message per line.
+type stdioSession struct {
+	in    *io.PipeWriter
+	lines chan map[string]interface{}
+	done  chan error
+}
+
+func startStdioSession(t *testing.T, srv *mcp.Server) *stdioSession {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	session := &stdioSession{
+		in:    inWriter,
+		lines: make(chan map[string]interface{}, 16),
+		done:  make(chan error, 1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		_ = inWriter.Close()
+		_ = outReader.Close()
+	})
+	go func() {
+		err := srv.ServeStdio(ctx, inReader, outWriter)
+		_ = outWriter.Close()
+		session.done <- err
+	}()
+	go func() {
+		defer close(session.lines)
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			var message map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+				t.Errorf("stdout line is not JSON: %q", scanner.Text())
+				return
+			}
+			session.lines <- message
+		}
+	}()
+	return session
+}
+
+func (s *stdioSession) send(t *testing.T, line string) {
+	t.Helper()
+	if _, err := io.WriteString(s.in, line+"\n"); err != nil {
+		t.Fatalf("write stdin: %v", err)
+	}
+}
+
+func (s *stdioSession) next(t *testing.T) map[string]interface{} {
+	t.Helper()
+	select {
+	case message, ok := <-s.lines:
+		if !ok {
+			t.Fatal("stdout closed")
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a stdout line")
+	}
+	return nil
+}
+
+func newStdioTestServer(retriever model.Retriever) *mcp.Server {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	return mcp.NewServer(cfg, retriever)
+}
+
+func TestServeStdio_AnswersOneLinePerRequest(t *testing.T) {
+	session := startStdioSession(t, newStdioTestServer(nil))
+
+	session.send(t, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if message := session.next(t); message["id"] != float64(1) || message["error"] == nil {
+		t.Fatalf("expected a session error before initialize, got %#v", message)
+	}
+
+	session.send(t, `{"jsonrpc":"2.0","id":2,"method":"initialize","params":{}}`)
+	initialized := session.next(t)
+	result, _ := initialized["result"].(map[string]interface{})
+	if initialized["id"] != float64(2) || result["protocolVersion"] == nil {
+		t.Fatalf("unexpected initialize response: %#v", initialized)
+	}
+
+	// notifications get no reply, so the next line answers tools/list.
+	session.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	session.send(t, ``)
+	session.send(t, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)
+	listed := session.next(t)
+	result, _ = listed["result"].(map[string]interface{})
+	if tools, _ := result["tools"].([]interface{}); listed["id"] != float64(3) || len(tools) == 0 {
+		t.Fatalf("unexpected tools/list response: %#v", listed)
+	}
+
+	session.send(t, `{"jsonrpc":"2.0","id":4,"method":`)
+	if message := session.next(t); message["error"] == nil {
+		t.Fatalf("expected a parse error, got %#v", message)
+	}
+
+	_ = session.in.Close()
+	select {
+	case err := <-session.done:
+		if err != nil {
+			t.Fatalf("ServeStdio returned %v at EOF", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeStdio did not return at EOF")
+	}
+}
+
+func TestServeStdio_WritesProgressBeforeResult(t *testing.T) {
+	session := startStdioSession(t, newStdioTestServer(&streamingAskRetrieverStub{
+		askAudioRetrieverStub: askAudioRetrieverStub{
+			askResult: model.AskResult{Answer: "alpha beta", IndexingComplete: true},
+		},
+		deltas: []string{"alpha", " beta"},
+	}))
+	session.send(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	session.next(t)
+
+	session.send(t, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"what?"},"_meta":{"progressToken":"t1"}}}`)
+	for i, want := range []string{"alpha", " beta"} {
+		message := session.next(t)
+		params, _ := message["params"].(map[string]interface{})
+		if message["method"] != protocol.RPCMethodProgress || params["message"] != want {
+			t.Fatalf("line %d: expected progress %q, got %#v", i, want, message)
+		}
+	}
+	final := session.next(t)
+	result, _ := final["result"].(map[string]interface{})
+	structured, _ := result["structuredContent"].(map[string]interface{})
+	if final["id"] != float64(7) || structured["answer"] != "alpha beta" {
+		t.Fatalf("unexpected final response: %#v", final)
+	}
+}
+
+func TestServeStdio_DeliversResourceNotifications(t *testing.T) {
+	srv := newStdioTestServer(nil)
+	session := startStdioSession(t, srv)
+	session.send(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	session.next(t)
+	session.send(t, `{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"dir2mcp://file/docs/a.md"}}`)
+	if message := session.next(t); message["error"] != nil {
+		t.Fatalf("subscribe failed: %#v", message)
+	}
+
+	srv.NotifyDocumentChange(model.DocumentChange{RelPath: "docs/a.md", Kind: model.DocumentUpdated})
+	message := session.next(t)
+	params, _ := message["params"].(map[string]interface{})
+	if message["method"] != protocol.RPCMethodResourcesUpdated || params["uri"] != "dir2mcp://file/docs/a.md" {
+		t.Fatalf("unexpected notification: %#v", message)
+	}
+}
+
+func TestServeStdio_RejectsX402(t *testing.T) {
+	cfg := x402EnabledTestConfig("https://resource.example.com")
+	err := mcp.NewServer(cfg, nil).ServeStdio(context.Background(), strings.NewReader(""), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "HTTP transport") {
+		t.Fatalf("expected an HTTP transport error, got %v", err)
+	}
+}
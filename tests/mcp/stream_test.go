@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+)
+
+// streamingAskRetrieverStub streams a fixed set of deltas before returning
+// the stub's ask result.
+type streamingAskRetrieverStub struct {
+	askAudioRetrieverStub
+	deltas []string
+}
+
+func (s *streamingAskRetrieverStub) AskStream(ctx context.Context, question string, query model.SearchQuery, onDelta func(string)) (model.AskResult, error) {
+	for _, delta := range s.deltas {
+		onDelta(delta)
+	}
+	return s.Ask(ctx, question, query)
+}
+
+// readSSEMessages returns the JSON payload of every data event in body.
+func readSSEMessages(t *testing.T, body io.Reader) []map[string]interface{} {
+	t.Helper()
+	var messages []map[string]interface{}
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &message); err != nil {
+			t.Fatalf("decode event %q: %v", line, err)
+		}
+		messages = append(messages, message)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read event stream: %v", err)
+	}
+	return messages
+}
+
+func newStreamingAskServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &streamingAskRetrieverStub{
+		askAudioRetrieverStub: askAudioRetrieverStub{
+			askResult: model.AskResult{Answer: "alpha beta", IndexingComplete: true},
+		},
+		deltas: []string{"alpha", " beta"},
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	t.Cleanup(server.Close)
+	return server, server.URL + cfg.MCPPath
+}
+
+func TestMCPToolsCallAsk_StreamsAnswerAsProgressNotifications(t *testing.T) {
+	_, url := newStreamingAskServer(t)
+	sessionID := initializeSession(t, url)
+
+	resp := postRPCWithHeaders(t, url, sessionID,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"what?"},"_meta":{"progressToken":"t1"}}}`,
+		map[string]string{"Accept": "application/json, text/event-stream"})
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		payload, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status=%d want=%d body=%s", resp.StatusCode, http.StatusOK, string(payload))
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected event stream, got Content-Type %q", ct)
+	}
+
+	messages := readSSEMessages(t, resp.Body)
+	if len(messages) != 3 {
+		t.Fatalf("expected two progress notifications and a result, got %#v", messages)
+	}
+	wantDeltas := []string{"alpha", " beta"}
+	wantProgress := []float64{5, 10}
+	for i, want := range wantDeltas {
+		if messages[i]["method"] != protocol.RPCMethodProgress {
+			t.Fatalf("message %d: expected progress notification, got %#v", i, messages[i])
+		}
+		params, _ := messages[i]["params"].(map[string]interface{})
+		if params["progressToken"] != "t1" || params["message"] != want || params["progress"] != wantProgress[i] {
+			t.Fatalf("message %d: unexpected progress params %#v", i, params)
+		}
+	}
+	final := messages[2]
+	if final["id"] != float64(7) {
+		t.Fatalf("expected final response for id 7, got %#v", final)
+	}
+	result, _ := final["result"].(map[string]interface{})
+	structured, _ := result["structuredContent"].(map[string]interface{})
+	if structured["answer"] != "alpha beta" {
+		t.Fatalf("unexpected final answer: %#v", result)
+	}
+}
+
+func TestMCPToolsCallAsk_WithoutProgressTokenReturnsJSON(t *testing.T) {
+	_, url := newStreamingAskServer(t)
+	sessionID := initializeSession(t, url)
+
+	resp := postRPCWithHeaders(t, url, sessionID,
+		`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"what?"}}}`,
+		map[string]string{"Accept": "application/json, text/event-stream"})
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("expected JSON response, got Content-Type %q", ct)
+	}
+	var envelope struct {
+		Result struct {
+			StructuredContent map[string]interface{} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if envelope.Result.StructuredContent["answer"] != "alpha beta" {
+		t.Fatalf("unexpected answer: %#v", envelope.Result.StructuredContent)
+	}
+}
+
+func TestMCPToolsCallGrep_StreamsMatchesAsProgressNotifications(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &grepRetrieverStub{result: model.GrepResult{
+		Matches: []model.GrepMatch{
+			{RelPath: "src/a.go", Span: model.Span{Kind: "lines", StartLine: 3, EndLine: 3}, Line: "// TODO one"},
+			{RelPath: "src/b.go", Span: model.Span{Kind: "lines", StartLine: 9, EndLine: 9}, Line: "// TODO two"},
+		},
+		FilesScanned: 2,
+	}}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	t.Cleanup(server.Close)
+	url := server.URL + cfg.MCPPath
+	sessionID := initializeSession(t, url)
+
+	resp := postRPCWithHeaders(t, url, sessionID,
+		`{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"dir2mcp.grep","arguments":{"pattern":"TODO"},"_meta":{"progressToken":3}}}`,
+		map[string]string{"Accept": "application/json, text/event-stream"})
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected event stream, got Content-Type %q", ct)
+	}
+
+	messages := readSSEMessages(t, resp.Body)
+	if len(messages) != 3 {
+		t.Fatalf("expected two progress notifications and a result, got %#v", messages)
+	}
+	wantMessages := []string{"src/a.go:3: // TODO one", "src/b.go:9: // TODO two"}
+	for i, want := range wantMessages {
+		params, _ := messages[i]["params"].(map[string]interface{})
+		if messages[i]["method"] != protocol.RPCMethodProgress || params["progressToken"] != float64(3) || params["message"] != want || params["progress"] != float64(i+1) {
+			t.Fatalf("message %d: unexpected progress notification %#v", i, messages[i])
+		}
+	}
+	result, _ := messages[2]["result"].(map[string]interface{})
+	structured, _ := result["structuredContent"].(map[string]interface{})
+	if matches, _ := structured["matches"].([]interface{}); len(matches) != 2 {
+		t.Fatalf("unexpected final result: %#v", messages[2])
+	}
+}
@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/mcp"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/protocol"
+	"dir2mcp/internal/store"
+	"dir2mcp/internal/tokens"
+)
+
+const scopedTestServerToken = "server-token"
+
+// createScopedToken adds a token built from spec to the state dir's token
+// file and returns its secret.
+func createScopedToken(t *testing.T, stateDir string, spec tokens.Token) string {
+	t.Helper()
+	path := filepath.Join(stateDir, tokens.FileName)
+	file, err := tokens.Load(path)
+	if err != nil {
+		t.Fatalf("load tokens: %v", err)
+	}
+	_, secret, err := file.Create(spec, time.Now())
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if err := tokens.Save(path, file); err != nil {
+		t.Fatalf("save tokens: %v", err)
+	}
+	return secret
+}
+
+func scopedTokenConfig(cfg config.Config) config.Config {
+	cfg.AuthMode = "auto"
+	cfg.ResolvedAuthToken = scopedTestServerToken
+	return cfg
+}
+
+func bearerRPC(t *testing.T, url, sessionID, secret, body string) *http.Response {
+	t.Helper()
+	return postRPCWithHeaders(t, url, sessionID, body, map[string]string{"Authorization": "Bearer " + secret})
+}
+
+func bearerCallRPC(t *testing.T, url, sessionID, secret, body string) rpcEnvelope {
+	t.Helper()
+	resp := bearerRPC(t, url, sessionID, secret, body)
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusOK)
+	}
+	var envelope rpcEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return envelope
+}
+
+func bearerInitialize(t *testing.T, url, secret string) string {
+	t.Helper()
+	resp := bearerRPC(t, url, "", secret, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status=%d want=%d", resp.StatusCode, http.StatusOK)
+	}
+	return resp.Header.Get(protocol.MCPSessionHeader)
+}
+
+func listedToolNames(t *testing.T, envelope rpcEnvelope) []string {
+	t.Helper()
+	result := envelope.Result
+	listed, _ := result["tools"].([]interface{})
+	names := make([]string, 0, len(listed))
+	for _, tool := range listed {
+		entry, _ := tool.(map[string]interface{})
+		name, _ := entry["name"].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+func toolErrorCode(envelope rpcEnvelope) string {
+	result := envelope.Result
+	structured, _ := result["structuredContent"].(map[string]interface{})
+	toolErr, _ := structured["error"].(map[string]interface{})
+	code, _ := toolErr["code"].(string)
+	return code
+}
+
+func TestScopedToken_ToolScopeFiltersListAndCall(t *testing.T) {
+	cfg, st, _ := setupMCPToolStore(t, "docs/a.md", "md", []byte("alpha"))
+	cfg = scopedTokenConfig(cfg)
+	secret := createScopedToken(t, cfg.StateDir, tokens.Token{Name: "search-only", Tools: []string{protocol.ToolNameSearch}})
+
+	server := httptest.NewServer(mcp.NewServer(cfg, nil, mcp.WithStore(st)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+
+	sessionID := bearerInitialize(t, url, secret)
+	names := listedToolNames(t, bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if len(names) != 1 || names[0] != protocol.ToolNameSearch {
+		t.Fatalf("scoped tools/list=%v want only %s", names, protocol.ToolNameSearch)
+	}
+	envelope := bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"dir2mcp.list_files","arguments":{}}}`)
+	if code := toolErrorCode(envelope); code != protocol.ErrorCodePermissionDenied {
+		t.Fatalf("list_files with a search-only token: code=%q want %s (%#v)", code, protocol.ErrorCodePermissionDenied, envelope)
+	}
+
+	// the server token keeps full access.
+	serverSession := bearerInitialize(t, url, scopedTestServerToken)
+	if names := listedToolNames(t, bearerCallRPC(t, url, serverSession, scopedTestServerToken, `{"jsonrpc":"2.0","id":4,"method":"tools/list"}`)); len(names) != len(mcp.ToolNames()) {
+		t.Fatalf("server token tools/list=%v want all tools", names)
+	}
+
+	// revoking the token takes effect without a restart.
+	path := filepath.Join(cfg.StateDir, tokens.FileName)
+	file, err := tokens.Load(path)
+	if err != nil {
+		t.Fatalf("load tokens: %v", err)
+	}
+	if _, err := file.Revoke("search-only", time.Now()); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := tokens.Save(path, file); err != nil {
+		t.Fatalf("save tokens: %v", err)
+	}
+	resp := bearerRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":5,"method":"tools/list"}`)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("revoked token status=%d want=%d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestScopedToken_ExpiredTokenIsRejected(t *testing.T) {
+	cfg := scopedTokenConfig(config.Default())
+	cfg.StateDir = t.TempDir()
+	secret := createScopedToken(t, cfg.StateDir, tokens.Token{Name: "expired", ExpiresAt: time.Now().Add(time.Hour)})
+	path := filepath.Join(cfg.StateDir, tokens.FileName)
+	file, err := tokens.Load(path)
+	if err != nil {
+		t.Fatalf("load tokens: %v", err)
+	}
+	file.Tokens[0].ExpiresAt = time.Now().Add(-time.Second)
+	if err := tokens.Save(path, file); err != nil {
+		t.Fatalf("save tokens: %v", err)
+	}
+
+	server := httptest.NewServer(mcp.NewServer(cfg, nil).Handler())
+	defer server.Close()
+	resp := bearerRPC(t, server.URL+cfg.MCPPath, "", secret, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expired token status=%d want=%d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestScopedToken_PathScopeLimitsSearchListAndOpen(t *testing.T) {
+	cfg, st, _ := setupMCPToolStore(t, "docs/a.md", "md", []byte("alpha"))
+	cfg = scopedTokenConfig(cfg)
+	for _, relPath := range []string{"docs/b.md", "private/x.md"} {
+		upsertScopedTestDocument(t, st, relPath)
+	}
+	secret := createScopedToken(t, cfg.StateDir, tokens.Token{Name: "docs", PathPrefixes: []string{"docs/"}})
+
+	var searchedK int
+	retriever := &askAudioRetrieverStub{
+		OnSearch: func(query model.SearchQuery) ([]model.SearchHit, error) {
+			searchedK = query.K
+			return []model.SearchHit{
+				{ChunkID: 1, RelPath: "private/x.md", Snippet: "x"},
+				{ChunkID: 2, RelPath: "docs/a.md", Snippet: "a"},
+				{ChunkID: 3, RelPath: "docs/b.md", Snippet: "b"},
+			}, nil
+		},
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever, mcp.WithStore(st)).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+	sessionID := bearerInitialize(t, url, secret)
+
+	envelope := bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"q","k":2}}}`)
+	result := envelope.Result
+	structured, _ := result["structuredContent"].(map[string]interface{})
+	hits, _ := structured["hits"].([]interface{})
+	if len(hits) != 2 || searchedK <= 2 {
+		t.Fatalf("expected 2 in-scope hits from an oversampled search, got %d hits with K=%d", len(hits), searchedK)
+	}
+	for _, hit := range hits {
+		if relPath := hit.(map[string]interface{})["rel_path"]; !strings.HasPrefix(relPath.(string), "docs/") {
+			t.Fatalf("search returned out-of-scope hit %v", relPath)
+		}
+	}
+
+	envelope = bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"dir2mcp.list_files","arguments":{"limit":1,"offset":1}}}`)
+	result = envelope.Result
+	structured, _ = result["structuredContent"].(map[string]interface{})
+	files, _ := structured["files"].([]interface{})
+	if structured["total"] != float64(2) || len(files) != 1 || files[0].(map[string]interface{})["rel_path"] != "docs/b.md" {
+		t.Fatalf("unexpected scoped list_files result: %#v", structured)
+	}
+
+	envelope = bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"dir2mcp.open_file","arguments":{"rel_path":"private/x.md"}}}`)
+	if code := toolErrorCode(envelope); code != protocol.ErrorCodePermissionDenied {
+		t.Fatalf("open_file outside scope: code=%q want %s", code, protocol.ErrorCodePermissionDenied)
+	}
+
+	// the prefix check runs on the cleaned path, so ".." cannot climb out.
+	envelope = bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"dir2mcp.open_file","arguments":{"rel_path":"docs/../private/x.md"}}}`)
+	if code := toolErrorCode(envelope); code != protocol.ErrorCodePermissionDenied {
+		t.Fatalf("open_file through docs/..: code=%q want %s", code, protocol.ErrorCodePermissionDenied)
+	}
+
+	// tools that read across the corpus are out of reach for a path-scoped token.
+	envelope = bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"q"}}}`)
+	if code := toolErrorCode(envelope); code != protocol.ErrorCodePermissionDenied {
+		t.Fatalf("ask with a path-scoped token: code=%q want %s", code, protocol.ErrorCodePermissionDenied)
+	}
+	// stats reports the root and corpus-wide counts.
+	envelope = bearerCallRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"dir2mcp.stats","arguments":{}}}`)
+	if code := toolErrorCode(envelope); code != protocol.ErrorCodePermissionDenied {
+		t.Fatalf("stats with a path-scoped token: code=%q want %s", code, protocol.ErrorCodePermissionDenied)
+	}
+
+	// resources read files directly, so they are refused with the same code.
+	resp := bearerRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":8,"method":"resources/list"}`)
+	defer func() { _ = resp.Body.Close() }()
+	var refused rpcEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&refused); err != nil {
+		t.Fatalf("decode resources/list response: %v", err)
+	}
+	if resp.StatusCode != http.StatusForbidden || refused.Error == nil || refused.Error.Data.Code != protocol.ErrorCodePermissionDenied {
+		t.Fatalf("resources/list with a path-scoped token: status=%d error=%#v", resp.StatusCode, refused.Error)
+	}
+}
+
+func TestScopedToken_RateLimit(t *testing.T) {
+	cfg := scopedTokenConfig(config.Default())
+	cfg.StateDir = t.TempDir()
+	secret := createScopedToken(t, cfg.StateDir, tokens.Token{Name: "slow", RateLimitRPS: 0.01, RateLimitBurst: 1})
+
+	server := httptest.NewServer(mcp.NewServer(cfg, nil).Handler())
+	defer server.Close()
+	url := server.URL + cfg.MCPPath
+
+	sessionID := bearerInitialize(t, url, secret)
+	resp := bearerRPC(t, url, sessionID, secret, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+}
+
+func upsertScopedTestDocument(t *testing.T, st *store.SQLiteStore, relPath string) {
+	t.Helper()
+	if err := st.UpsertDocument(context.Background(), model.Document{
+		RelPath:     relPath,
+		DocType:     "md",
+		SourceType:  "filesystem",
+		SizeBytes:   1,
+		MTimeUnix:   1,
+		ContentHash: "h-" + relPath,
+		Status:      "ok",
+	}); err != nil {
+		t.Fatalf("upsert document: %v", err)
+	}
+}
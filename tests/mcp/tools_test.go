@@ -66,6 +66,11 @@ func TestMCPToolsList_RegistersDayOneToolsWithSchemas(t *testing.T) {
 		protocol.ToolNameOpenFile:         false,
 		protocol.ToolNameListFiles:        false,
 		protocol.ToolNameStats:            false,
+		protocol.ToolNameFindSimilar:      false,
+		protocol.ToolNameGrep:             false,
+		protocol.ToolNameSymbols:          false,
+		protocol.ToolNameDefinition:       false,
+		protocol.ToolNameReferences:       false,
 	}
 
 	for _, tool := range envelope.Result.Tools {
@@ -1118,6 +1123,683 @@ func TestMCPToolsCallAsk_SearchOnly(t *testing.T) {
 	}
 }
 
+func TestMCPToolsCallSearch_HybridReportsScoreComponents(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+
+	var gotIndex string
+	retriever := &askAudioRetrieverStub{
+		OnSearch: func(q model.SearchQuery) ([]model.SearchHit, error) {
+			gotIndex = q.Index
+			return []model.SearchHit{
+				{ChunkID: 7, RelPath: "src/retry.go", Score: 0.03, Snippet: "retry", Span: model.Span{Kind: "lines", StartLine: 1, EndLine: 3}, VectorScore: 0.82, LexicalScore: 2.4, FusedRank: 1},
+			}, nil
+		},
+		indexingComplete: true,
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":15,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"retry","index":"hybrid"}}}`)
+	defer func() { _ = resp.Body.Close() }()
+
+	var envelope struct {
+		Result struct {
+			IsError           bool                   `json:"isError"`
+			StructuredContent map[string]interface{} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if envelope.Result.IsError {
+		t.Fatalf("expected search success, got %#v", envelope.Result.StructuredContent)
+	}
+	if gotIndex != "hybrid" {
+		t.Fatalf("expected hybrid index passed to retriever, got %q", gotIndex)
+	}
+	if envelope.Result.StructuredContent["index_used"] != "hybrid" {
+		t.Fatalf("unexpected index_used: %#v", envelope.Result.StructuredContent["index_used"])
+	}
+	hitsList, ok := envelope.Result.StructuredContent["hits"].([]interface{})
+	if !ok || len(hitsList) != 1 {
+		t.Fatalf("expected one hit, got %#v", envelope.Result.StructuredContent["hits"])
+	}
+	hit, ok := hitsList[0].(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected hit payload: %#v", hitsList[0])
+	}
+	if hit["rel_path"] != "src/retry.go" || hit["vector_score"] != 0.82 || hit["lexical_score"] != 2.4 || hit["fused_rank"] != float64(1) {
+		t.Fatalf("unexpected hit fields: %#v", hit)
+	}
+}
+
+func TestMCPToolsCallSearch_RejectsUnknownIndex(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, &askAudioRetrieverStub{searchHits: []model.SearchHit{}}).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":16,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"retry","index":"fuzzy"}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+}
+
+func TestMCPToolsCallSearch_RerankReportsMetadata(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+
+	retriever := &rerankRetrieverStub{
+		result: model.SearchResult{
+			Hits: []model.SearchHit{
+				{ChunkID: 3, RelPath: "docs/keys.md", Score: 0.41, Snippet: "rotate keys", Span: model.Span{Kind: "lines", StartLine: 1, EndLine: 2}, RerankScore: 0.75, Reranked: true},
+			},
+			Rerank: &model.RerankInfo{Reranker: "lexical", Candidates: 25, LatencyMS: 4},
+		},
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":17,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"rotate keys","k":5,"rerank":"lexical"}}}`)
+	defer func() { _ = resp.Body.Close() }()
+
+	var envelope struct {
+		Result struct {
+			IsError           bool                   `json:"isError"`
+			StructuredContent map[string]interface{} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if envelope.Result.IsError {
+		t.Fatalf("expected search success, got %#v", envelope.Result.StructuredContent)
+	}
+	if retriever.got.Rerank != "lexical" || retriever.got.K != 5 {
+		t.Fatalf("expected rerank request passed to retriever, got %+v", retriever.got)
+	}
+	rerank, ok := envelope.Result.StructuredContent["rerank"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected rerank metadata, got %#v", envelope.Result.StructuredContent)
+	}
+	if rerank["reranker"] != "lexical" || rerank["candidates"] != float64(25) || rerank["latency_ms"] != float64(4) {
+		t.Fatalf("unexpected rerank metadata: %#v", rerank)
+	}
+	hitsList, ok := envelope.Result.StructuredContent["hits"].([]interface{})
+	if !ok || len(hitsList) != 1 {
+		t.Fatalf("expected one hit, got %#v", envelope.Result.StructuredContent["hits"])
+	}
+	hit, _ := hitsList[0].(map[string]interface{})
+	if hit["rerank_score"] != 0.75 {
+		t.Fatalf("expected rerank_score on hit, got %#v", hit)
+	}
+}
+
+func TestMCPToolsCallSearch_RejectsUnknownReranker(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, &askAudioRetrieverStub{searchHits: []model.SearchHit{}}).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":18,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"rotate keys","rerank":"cross-encoder"}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+}
+
+func TestMCPToolsCallSearch_PassesDiversityArguments(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &rerankRetrieverStub{}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":19,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"retry","mmr_lambda":0.6,"max_hits_per_file":2}}}`)
+	_ = resp.Body.Close()
+	if retriever.got.MMRLambda != 0.6 || retriever.got.MaxHitsPerFile != 2 {
+		t.Fatalf("expected diversity arguments passed to retriever, got %+v", retriever.got)
+	}
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":20,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"retry","mmr_lambda":1.5}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_RANGE")
+}
+
+func TestMCPToolsCallSearch_PassesMetadataFilters(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &rerankRetrieverStub{}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":19,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"retry","modified_after":"2024-01-01","modified_before":"2024-06-01T00:00:00Z","max_size_bytes":4096,"source_types":["archive_member"],"rep_types":["ocr_markdown"],"languages":["TS"]}}}`)
+	_ = resp.Body.Close()
+	got := retriever.got.MetadataFilter
+	if got.ModifiedAfter != 1704067200 || got.ModifiedBefore != 1717200000 || got.MaxSizeBytes != 4096 {
+		t.Fatalf("unexpected range filters: %+v", got)
+	}
+	if len(got.SourceTypes) != 1 || got.SourceTypes[0] != "archive_member" || len(got.RepTypes) != 1 || got.RepTypes[0] != "ocr_markdown" {
+		t.Fatalf("unexpected enum filters: %+v", got)
+	}
+	if len(got.Languages) != 1 || got.Languages[0] != "typescript" {
+		t.Fatalf("expected language alias normalized, got %+v", got.Languages)
+	}
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":20,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"retry","modified_after":"2024-06-01","modified_before":"2024-01-01"}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_RANGE")
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":21,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"retry","languages":["cobol"]}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":22,"method":"tools/call","params":{"name":"dir2mcp.search","arguments":{"query":"retry","statuses":["pending"]}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+}
+
+func TestMCPToolsCallFindSimilar_PassesSeedAndFilters(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &findSimilarRetrieverStub{result: model.SimilarResult{
+		SeedChunkIDs: []uint64{7, 8},
+		IndexUsed:    "text",
+		Hits:         []model.SearchHit{{ChunkID: 9, RelPath: "docs/b.md", Score: 0.9, Span: model.Span{Kind: "lines", StartLine: 1, EndLine: 2}}},
+	}}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":23,"method":"tools/call","params":{"name":"dir2mcp.find_similar","arguments":{"rel_path":"docs/a.md","start_line":3,"end_line":9,"k":4,"path_prefix":"docs/","languages":["go"]}}}`)
+	defer func() { _ = resp.Body.Close() }()
+	var envelope struct {
+		Result struct {
+			IsError           bool                   `json:"isError"`
+			StructuredContent map[string]interface{} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if envelope.Result.IsError {
+		t.Fatalf("expected find_similar success, got %#v", envelope.Result.StructuredContent)
+	}
+	got := retriever.got
+	if got.RelPath != "docs/a.md" || got.Span.Kind != "lines" || got.Span.StartLine != 3 || got.Span.EndLine != 9 {
+		t.Fatalf("unexpected seed passed to retriever: %+v", got)
+	}
+	if got.K != 4 || got.PathPrefix != "docs/" || len(got.Languages) != 1 || got.Languages[0] != "go" {
+		t.Fatalf("unexpected query passed to retriever: %+v", got.SearchQuery)
+	}
+	seed, _ := envelope.Result.StructuredContent["seed"].(map[string]interface{})
+	if seed["rel_path"] != "docs/a.md" || len(seed["chunk_ids"].([]interface{})) != 2 {
+		t.Fatalf("unexpected seed payload: %#v", seed)
+	}
+	hits, _ := envelope.Result.StructuredContent["hits"].([]interface{})
+	if len(hits) != 1 || envelope.Result.StructuredContent["index_used"] != "text" {
+		t.Fatalf("unexpected hits payload: %#v", envelope.Result.StructuredContent)
+	}
+}
+
+func TestMCPToolsCallFindSimilar_ValidatesSeed(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &findSimilarRetrieverStub{err: model.ErrNotFound}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":24,"method":"tools/call","params":{"name":"dir2mcp.find_similar","arguments":{}}}`)
+	assertToolCallErrorCode(t, resp, "MISSING_FIELD")
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":25,"method":"tools/call","params":{"name":"dir2mcp.find_similar","arguments":{"chunk_id":3,"rel_path":"docs/a.md"}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":26,"method":"tools/call","params":{"name":"dir2mcp.find_similar","arguments":{"chunk_id":3,"page":2}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":27,"method":"tools/call","params":{"name":"dir2mcp.find_similar","arguments":{"chunk_id":3,"index":"hybrid"}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":28,"method":"tools/call","params":{"name":"dir2mcp.find_similar","arguments":{"chunk_id":3}}}`)
+	assertToolCallErrorCode(t, resp, "FILE_NOT_FOUND")
+}
+
+func TestMCPToolsCallGrep_PassesQueryAndReportsMatches(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &grepRetrieverStub{result: model.GrepResult{
+		Matches: []model.GrepMatch{{
+			RelPath: "src/main.go",
+			Span:    model.Span{Kind: "lines", StartLine: 12, EndLine: 12},
+			Line:    "// TODO wire flags",
+			Before:  []string{"func main() {"},
+			After:   []string{"}"},
+		}},
+		FilesScanned: 3,
+		FilesSkipped: 1,
+		Truncated:    true,
+	}}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":29,"method":"tools/call","params":{"name":"dir2mcp.grep","arguments":{"pattern":"TODO","ignore_case":true,"path_prefix":"src/","file_glob":"*.go","context_lines":1,"max_matches":1}}}`)
+	defer func() { _ = resp.Body.Close() }()
+	var envelope struct {
+		Result struct {
+			IsError           bool                   `json:"isError"`
+			StructuredContent map[string]interface{} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if envelope.Result.IsError {
+		t.Fatalf("expected grep success, got %#v", envelope.Result.StructuredContent)
+	}
+	got := retriever.got
+	if got.Pattern != "TODO" || !got.IgnoreCase || got.Literal || got.PathPrefix != "src/" || got.FileGlob != "*.go" || got.ContextLines != 1 || got.MaxMatches != 1 {
+		t.Fatalf("unexpected query passed to retriever: %+v", got)
+	}
+	if !retriever.hadDeadline {
+		t.Fatal("expected grep to run under a deadline")
+	}
+	structured := envelope.Result.StructuredContent
+	matches, _ := structured["matches"].([]interface{})
+	if len(matches) != 1 {
+		t.Fatalf("unexpected matches payload: %#v", structured)
+	}
+	match, _ := matches[0].(map[string]interface{})
+	span, _ := match["span"].(map[string]interface{})
+	if match["rel_path"] != "src/main.go" || span["kind"] != "lines" || span["start_line"] != float64(12) {
+		t.Fatalf("unexpected match payload: %#v", match)
+	}
+	if structured["truncated"] != true || structured["timed_out"] != false || structured["files_scanned"] != float64(3) {
+		t.Fatalf("unexpected summary payload: %#v", structured)
+	}
+}
+
+func TestMCPToolsCallGrep_ValidatesArguments(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, &grepRetrieverStub{}).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	cases := []struct {
+		args string
+		code string
+	}{
+		{`{}`, "MISSING_FIELD"},
+		{`{"pattern":"("}`, "INVALID_FIELD"},
+		{`{"pattern":"x","context_lines":11}`, "INVALID_RANGE"},
+		{`{"pattern":"x","max_matches":1001}`, "INVALID_RANGE"},
+		{`{"pattern":"x","max_matches":0}`, "INVALID_RANGE"},
+		{fmt.Sprintf(`{"pattern":%q}`, strings.Repeat("a", mcp.MaxGrepPatternLength+1)), "INVALID_RANGE"},
+		{`{"pattern":"x","regex":true}`, "INVALID_FIELD"},
+	}
+	for i, tc := range cases {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"dir2mcp.grep","arguments":%s}}`, 30+i, tc.args)
+		resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, body)
+		assertToolCallErrorCode(t, resp, tc.code)
+	}
+}
+
+func TestMCPToolsCallSymbols_PassesFiltersAndSerializesSymbols(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &symbolRetrieverStub{symbols: []model.Symbol{{
+		Name:      "Grep",
+		Kind:      model.SymbolKindMethod,
+		Container: "Service",
+		RelPath:   "internal/retrieval/grep.go",
+		Language:  "go",
+		Span:      model.Span{Kind: "lines", StartLine: 20, EndLine: 48},
+		Signature: "func (s *Service) Grep(ctx context.Context) error {",
+		Exact:     true,
+	}}}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":40,"method":"tools/call","params":{"name":"dir2mcp.symbols","arguments":{"query":"grep","kinds":["Method"],"container":"Service","language":"go","path_prefix":"internal/","file_glob":"*.go","limit":5}}}`)
+	defer func() { _ = resp.Body.Close() }()
+	var envelope struct {
+		Result struct {
+			IsError           bool                   `json:"isError"`
+			StructuredContent map[string]interface{} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if envelope.Result.IsError {
+		t.Fatalf("expected symbols success, got %#v", envelope.Result.StructuredContent)
+	}
+	got := retriever.gotSymbols
+	if got.Name != "grep" || got.Exact || len(got.Kinds) != 1 || got.Kinds[0] != "method" || got.Container != "Service" || got.Language != "go" || got.PathPrefix != "internal/" || got.FileGlob != "*.go" || got.Limit != 5 {
+		t.Fatalf("unexpected query passed to retriever: %+v", got)
+	}
+	symbols, _ := envelope.Result.StructuredContent["symbols"].([]interface{})
+	if len(symbols) != 1 {
+		t.Fatalf("unexpected symbols payload: %#v", envelope.Result.StructuredContent)
+	}
+	sym, _ := symbols[0].(map[string]interface{})
+	span, _ := sym["span"].(map[string]interface{})
+	if sym["name"] != "Grep" || sym["container"] != "Service" || sym["exact"] != true || span["kind"] != "lines" || span["start_line"] != float64(20) || span["end_line"] != float64(48) {
+		t.Fatalf("unexpected symbol payload: %#v", sym)
+	}
+}
+
+func TestMCPToolsCallDefinitionAndReferences(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	definition := model.Symbol{Name: "Run", Kind: model.SymbolKindFunction, RelPath: "main.go", Language: "go", Span: model.Span{Kind: "lines", StartLine: 3, EndLine: 5}, Exact: true}
+	retriever := &symbolRetrieverStub{
+		symbols: []model.Symbol{definition},
+		references: model.ReferenceResult{
+			Definitions: []model.Symbol{definition},
+			GrepResult: model.GrepResult{
+				Matches:      []model.GrepMatch{{RelPath: "cmd.go", Span: model.Span{Kind: "lines", StartLine: 9, EndLine: 9}, Line: "\tRun()"}},
+				FilesScanned: 2,
+			},
+		},
+	}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+
+	call := func(body string) map[string]interface{} {
+		t.Helper()
+		resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, body)
+		defer func() { _ = resp.Body.Close() }()
+		var envelope struct {
+			Result struct {
+				IsError           bool                   `json:"isError"`
+				StructuredContent map[string]interface{} `json:"structuredContent"`
+			} `json:"result"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if envelope.Result.IsError {
+			t.Fatalf("expected success, got %#v", envelope.Result.StructuredContent)
+		}
+		return envelope.Result.StructuredContent
+	}
+
+	defs := call(`{"jsonrpc":"2.0","id":41,"method":"tools/call","params":{"name":"dir2mcp.definition","arguments":{"name":"Run","language":"go"}}}`)
+	if retriever.gotDefinition.Name != "Run" || retriever.gotDefinition.Language != "go" || retriever.gotDefinition.Limit != mcp.DefaultSymbolLimit {
+		t.Fatalf("unexpected definition query: %+v", retriever.gotDefinition)
+	}
+	if list, _ := defs["definitions"].([]interface{}); defs["name"] != "Run" || len(list) != 1 {
+		t.Fatalf("unexpected definition payload: %#v", defs)
+	}
+
+	refs := call(`{"jsonrpc":"2.0","id":42,"method":"tools/call","params":{"name":"dir2mcp.references","arguments":{"name":"Run","include_definitions":true,"context_lines":2,"max_matches":10}}}`)
+	got := retriever.gotReferences
+	if got.Name != "Run" || !got.IncludeDefinitions || got.ContextLines != 2 || got.MaxMatches != 10 {
+		t.Fatalf("unexpected references query: %+v", got)
+	}
+	if !retriever.hadDeadline {
+		t.Fatal("expected references to run under a deadline")
+	}
+	references, _ := refs["references"].([]interface{})
+	if len(references) != 1 || refs["files_scanned"] != float64(2) || refs["truncated"] != false {
+		t.Fatalf("unexpected references payload: %#v", refs)
+	}
+	ref, _ := references[0].(map[string]interface{})
+	if span, _ := ref["span"].(map[string]interface{}); ref["rel_path"] != "cmd.go" || span["start_line"] != float64(9) {
+		t.Fatalf("unexpected reference payload: %#v", ref)
+	}
+}
+
+func TestMCPToolsCallSymbolTools_ValidateArguments(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, &symbolRetrieverStub{}).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	cases := []struct {
+		tool string
+		args string
+		code string
+	}{
+		{"dir2mcp.symbols", `{"kinds":["widget"]}`, "INVALID_FIELD"},
+		{"dir2mcp.symbols", `{"limit":201}`, "INVALID_RANGE"},
+		{"dir2mcp.symbols", fmt.Sprintf(`{"query":%q}`, strings.Repeat("a", mcp.MaxSymbolNameLength+1)), "INVALID_RANGE"},
+		{"dir2mcp.definition", `{}`, "MISSING_FIELD"},
+		{"dir2mcp.definition", `{"name":"x","container":"y"}`, "INVALID_FIELD"},
+		{"dir2mcp.references", `{"name":" "}`, "INVALID_FIELD"},
+		{"dir2mcp.references", `{"name":"x","context_lines":11}`, "INVALID_RANGE"},
+		{"dir2mcp.references", `{"name":"x","max_matches":0}`, "INVALID_RANGE"},
+	}
+	for i, tc := range cases {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q,"arguments":%s}}`, 50+i, tc.tool, tc.args)
+		resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, body)
+		assertToolCallErrorCode(t, resp, tc.code)
+	}
+}
+
+func TestMCPToolsCallSymbols_StoreWithoutSymbolTableIsNotReady(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	server := httptest.NewServer(mcp.NewServer(cfg, &symbolRetrieverStub{err: model.ErrNotImplemented}).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":60,"method":"tools/call","params":{"name":"dir2mcp.symbols","arguments":{"query":"x"}}}`)
+	assertToolCallErrorCode(t, resp, protocol.ErrorCodeIndexNotReady)
+}
+
+func TestMCPToolsCallAsk_ConversationRewritesFollowUps(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	retriever := &conversationRetrieverStub{rewriteTo: "how is the deploy key rotation configured?"}
+	retriever.askResult = model.AskResult{Answer: "answer"}
+	retriever.EchoQuestion = true
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	callAsk := func(sessionID, question string) map[string]interface{} {
+		t.Helper()
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":21,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":%q,"conversation_id":"c1"}}}`, question)
+		resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, body)
+		defer func() { _ = resp.Body.Close() }()
+		var envelope struct {
+			Result struct {
+				IsError           bool                   `json:"isError"`
+				StructuredContent map[string]interface{} `json:"structuredContent"`
+			} `json:"result"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if envelope.Result.IsError {
+			t.Fatalf("expected ask success, got %#v", envelope.Result.StructuredContent)
+		}
+		return envelope.Result.StructuredContent
+	}
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	first := callAsk(sessionID, "how often does the deploy key rotate?")
+	if retriever.rewriteCalls != 0 {
+		t.Fatal("expected first turn to skip rewriting")
+	}
+	if first["standalone_question"] != "how often does the deploy key rotate?" || first["conversation_id"] != "c1" {
+		t.Fatalf("unexpected first turn payload: %#v", first)
+	}
+
+	second := callAsk(sessionID, "and how is that configured?")
+	if retriever.rewriteCalls != 1 || len(retriever.history) != 1 || retriever.history[0].Answer != "answer" {
+		t.Fatalf("expected rewrite with one prior turn, got calls=%d history=%+v", retriever.rewriteCalls, retriever.history)
+	}
+	if retriever.lastQuery != "how is the deploy key rotation configured?" {
+		t.Fatalf("expected retrieval with the standalone query, got %q", retriever.lastQuery)
+	}
+	if second["question"] != "and how is that configured?" || second["standalone_question"] != retriever.rewriteTo {
+		t.Fatalf("unexpected follow-up payload: %#v", second)
+	}
+
+	// histories are per session: the same conversation_id in a new session
+	// starts fresh.
+	otherSession := initializeSession(t, server.URL+cfg.MCPPath)
+	callAsk(otherSession, "and how is that configured?")
+	if retriever.rewriteCalls != 1 {
+		t.Fatalf("expected no rewrite for a fresh session, got %d calls", retriever.rewriteCalls)
+	}
+}
+
+func TestMCPToolsCallAsk_StrictStripsUnsupportedSentences(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthMode = "none"
+	answer := "Keys rotate monthly [docs/keys.md]. Keys never expire."
+	retriever := &askAudioRetrieverStub{askResult: model.AskResult{
+		Question: "rotation?",
+		Answer:   answer,
+		Verification: &model.AnswerVerification{
+			GroundingScore: 0.5,
+			Sentences: []model.SentenceSupport{
+				{Text: answer[:34], Start: 0, End: 34, Sources: []string{"docs/keys.md"}, Status: model.SupportSupported, Score: 1, Method: "lexical"},
+				{Text: answer[35:], Start: 35, End: len(answer), Status: model.SupportUnsupported, Method: "lexical"},
+			},
+		},
+	}}
+	server := httptest.NewServer(mcp.NewServer(cfg, retriever).Handler())
+	defer server.Close()
+
+	sessionID := initializeSession(t, server.URL+cfg.MCPPath)
+	resp := postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":22,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"rotation?","strict":"strip"}}}`)
+	defer func() { _ = resp.Body.Close() }()
+
+	var envelope struct {
+		Result struct {
+			IsError           bool                   `json:"isError"`
+			StructuredContent map[string]interface{} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if envelope.Result.IsError {
+		t.Fatalf("expected ask success, got %#v", envelope.Result.StructuredContent)
+	}
+	structured := envelope.Result.StructuredContent
+	if structured["answer"] != "Keys rotate monthly [docs/keys.md]." {
+		t.Fatalf("expected unsupported sentence stripped, got %#v", structured["answer"])
+	}
+	verification, ok := structured["verification"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected verification, got %#v", structured)
+	}
+	if verification["grounding_score"] != 0.5 || verification["unsupported"] != float64(1) || verification["strict"] != "strip" {
+		t.Fatalf("unexpected verification summary: %#v", verification)
+	}
+	sentences, _ := verification["sentences"].([]interface{})
+	if len(sentences) != 2 {
+		t.Fatalf("expected two sentences, got %#v", verification["sentences"])
+	}
+	removed, _ := sentences[1].(map[string]interface{})
+	if removed["removed"] != true || removed["status"] != "unsupported" {
+		t.Fatalf("expected stripped sentence reported as removed, got %#v", removed)
+	}
+
+	resp = postRPC(t, server.URL+cfg.MCPPath, sessionID, `{"jsonrpc":"2.0","id":23,"method":"tools/call","params":{"name":"dir2mcp.ask","arguments":{"question":"rotation?","strict":"redact"}}}`)
+	assertToolCallErrorCode(t, resp, "INVALID_FIELD")
+}
+
+// conversationRetrieverStub records follow-up rewrites and the query used
+// for retrieval.
+type conversationRetrieverStub struct {
+	askAudioRetrieverStub
+	rewriteTo    string
+	rewriteCalls int
+	history      []model.ConversationTurn
+	lastQuery    string
+}
+
+func (s *conversationRetrieverStub) RewriteQuestion(_ context.Context, _ string, history []model.ConversationTurn) (string, error) {
+	s.rewriteCalls++
+	s.history = history
+	return s.rewriteTo, nil
+}
+
+func (s *conversationRetrieverStub) Ask(ctx context.Context, question string, q model.SearchQuery) (model.AskResult, error) {
+	s.lastQuery = q.Query
+	return s.askAudioRetrieverStub.Ask(ctx, question, q)
+}
+
+// rerankRetrieverStub reports search metadata through SearchWithMetadata.
+type rerankRetrieverStub struct {
+	askAudioRetrieverStub
+	result model.SearchResult
+	got    model.SearchQuery
+}
+
+func (s *rerankRetrieverStub) SearchWithMetadata(_ context.Context, q model.SearchQuery) (model.SearchResult, error) {
+	s.got = q
+	return s.result, nil
+}
+
+// findSimilarRetrieverStub records the find-similar query it receives.
+type findSimilarRetrieverStub struct {
+	askAudioRetrieverStub
+	result model.SimilarResult
+	err    error
+	got    model.SimilarQuery
+}
+
+func (s *findSimilarRetrieverStub) FindSimilar(_ context.Context, q model.SimilarQuery) (model.SimilarResult, error) {
+	s.got = q
+	return s.result, s.err
+}
+
+type grepRetrieverStub struct {
+	askAudioRetrieverStub
+	result      model.GrepResult
+	got         model.GrepQuery
+	hadDeadline bool
+}
+
+func (s *grepRetrieverStub) Grep(ctx context.Context, q model.GrepQuery, emit func(model.GrepMatch)) (model.GrepResult, error) {
+	s.got = q
+	_, s.hadDeadline = ctx.Deadline()
+	if emit != nil {
+		for _, match := range s.result.Matches {
+			emit(match)
+		}
+	}
+	return s.result, nil
+}
+
+type symbolRetrieverStub struct {
+	askAudioRetrieverStub
+	symbols       []model.Symbol
+	references    model.ReferenceResult
+	err           error
+	gotSymbols    model.SymbolQuery
+	gotDefinition model.SymbolQuery
+	gotReferences model.ReferenceQuery
+	hadDeadline   bool
+}
+
+func (s *symbolRetrieverStub) Symbols(_ context.Context, q model.SymbolQuery) ([]model.Symbol, error) {
+	s.gotSymbols = q
+	return s.symbols, s.err
+}
+
+func (s *symbolRetrieverStub) Definitions(_ context.Context, q model.SymbolQuery) ([]model.Symbol, error) {
+	s.gotDefinition = q
+	return s.symbols, s.err
+}
+
+func (s *symbolRetrieverStub) References(ctx context.Context, q model.ReferenceQuery, _ func(model.GrepMatch)) (model.ReferenceResult, error) {
+	s.gotReferences = q
+	_, s.hadDeadline = ctx.Deadline()
+	return s.references, s.err
+}
+
 // failingListFilesStore is a minimal store stub that forces ListFiles to
 // return a configured error for error-path testing.
 type failingListFilesStore struct {
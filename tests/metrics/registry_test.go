@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/metrics"
+)
+
+func TestRegistryWritesTextFormat(t *testing.T) {
+	reg := metrics.NewRegistry()
+	requests := reg.NewCounterVec("test_requests_total", "Requests.\nSecond line.", "method", "status")
+	latency := reg.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.5, 0.1}, "method")
+	reg.GaugeFunc("test_queue", "Queue depth.", []string{"index"}, func(emit func(float64, ...string)) {
+		emit(3, "text")
+		emit(1, "a", "too many labels")
+	})
+
+	requests.Inc("search", "ok")
+	requests.Add(2, `say "hi"\`, "error")
+	requests.Add(-1, "search", "ok")
+	latency.Observe(0.05, "search")
+	latency.Observe(0.3, "search")
+	latency.Observe(4, "search")
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{method="search",le="0.1"} 1
+test_latency_seconds_bucket{method="search",le="0.5"} 2
+test_latency_seconds_bucket{method="search",le="+Inf"} 3
+test_latency_seconds_sum{method="search"} 4.35
+test_latency_seconds_count{method="search"} 3
+# HELP test_queue Queue depth.
+# TYPE test_queue gauge
+test_queue{index="text"} 3
+# HELP test_requests_total Requests.\nSecond line.
+# TYPE test_requests_total counter
+test_requests_total{method="say \"hi\"\\",status="error"} 2
+test_requests_total{method="search",status="ok"} 1
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistryHandlerRequiresToken(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.NewCounterVec("test_total", "Test.").Inc()
+	handler := reg.Handler("s3cret")
+
+	get := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	for _, authorization := range []string{"", "Bearer wrong", "s3cret"} {
+		if rr := get(authorization); rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("authorization %q: status=%d want 401 with a challenge", authorization, rr.Code)
+		}
+	}
+	rr := get("Bearer s3cret")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("status=%d content-type=%q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Body.String(), "test_total 1\n") {
+		t.Fatalf("unexpected body: %s", rr.Body.String())
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -116,6 +118,61 @@ func TestEmbed_RetriesOnRateLimitThenSucceeds(t *testing.T) {
 	}
 }
 
+type recordingObserver struct {
+	mu      sync.Mutex
+	calls   []string
+	retries []string
+}
+
+func (o *recordingObserver) ObserveProviderCall(provider, operation string, _ time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	outcome := "ok"
+	var providerErr *model.ProviderError
+	if errors.As(err, &providerErr) {
+		outcome = providerErr.Code
+	}
+	o.calls = append(o.calls, provider+"/"+operation+"/"+outcome)
+}
+
+func (o *recordingObserver) ObserveProviderRetry(provider, operation string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.retries = append(o.retries, provider+"/"+operation)
+}
+
+func TestEmbed_ReportsAttemptsAndRetriesToObserver(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"index": 0, "embedding": []float64{1.0}}},
+		})
+	}))
+	defer server.Close()
+
+	observer := &recordingObserver{}
+	client := mistral.NewClient(server.URL, "test-key")
+	client.MaxRetries = 2
+	client.InitialBackoff = time.Millisecond
+	client.MaxBackoff = time.Millisecond
+	client.Observer = observer
+
+	if _, err := client.Embed(context.Background(), "mistral-embed", []string{"a"}); err != nil {
+		t.Fatalf("embed failed: %v", err)
+	}
+	wantCalls := []string{"mistral/embed/MISTRAL_RATE_LIMIT", "mistral/embed/ok"}
+	if strings.Join(observer.calls, ",") != strings.Join(wantCalls, ",") {
+		t.Fatalf("calls=%v want %v", observer.calls, wantCalls)
+	}
+	if len(observer.retries) != 1 || observer.retries[0] != "mistral/embed" {
+		t.Fatalf("retries=%v want [mistral/embed]", observer.retries)
+	}
+}
+
 func TestEmbed_RetriesOnServerErrorThenSucceeds(t *testing.T) {
 	var calls int32
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
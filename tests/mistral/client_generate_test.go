@@ -240,3 +240,106 @@ func TestGenerate_UsesConfiguredChatModelInRequest(t *testing.T) {
 		t.Fatalf("unexpected output: %q", out)
 	}
 }
+
+func TestGenerateWithSystem_SendsSeparateSystemMessage(t *testing.T) {
+	var messages []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		messages = req.Messages
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"content": "ok"}}},
+		})
+	}))
+	defer server.Close()
+
+	client := mistral.NewClient(server.URL, "test-key")
+	if _, err := client.GenerateWithSystem(context.Background(), "be terse", "what is up?"); err != nil {
+		t.Fatalf("GenerateWithSystem failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected system and user messages, got %#v", messages)
+	}
+	if messages[0]["role"] != "system" || messages[0]["content"] != "be terse" {
+		t.Fatalf("unexpected system message: %#v", messages[0])
+	}
+	if messages[1]["role"] != "user" || messages[1]["content"] != "what is up?" {
+		t.Fatalf("unexpected user message: %#v", messages[1])
+	}
+}
+
+func TestGenerateStream_DeliversDeltasInOrder(t *testing.T) {
+	var req struct {
+		Stream   bool             `json:"stream"`
+		Messages []map[string]any `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`data: {"choices":[{"delta":{"role":"assistant","content":""}}]}`,
+			`data: {"choices":[{"delta":{"content":"Hello"}}]}`,
+			`: keep-alive`,
+			`data: {"choices":[{"delta":{"content":[{"type":"text","text":", world"}]}}]}`,
+			`data: [DONE]`,
+		} {
+			_, _ = io.WriteString(w, event+"\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	client := mistral.NewClient(server.URL, "test-key")
+	var deltas []string
+	out, err := client.GenerateStream(context.Background(), "be terse", "greet", func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if !req.Stream || len(req.Messages) != 2 || req.Messages[0]["role"] != "system" {
+		t.Fatalf("expected a streamed request with a system message, got %+v", req)
+	}
+	if out != "Hello, world" || strings.Join(deltas, "|") != "Hello|, world" {
+		t.Fatalf("unexpected output %q or deltas %q", out, deltas)
+	}
+}
+
+func TestGenerateStream_RetriesOnlyBeforeFirstDelta(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\ndata: {not json\n\n")
+	}))
+	defer server.Close()
+
+	client := mistral.NewClient(server.URL, "test-key")
+	client.MaxRetries = 3
+	client.InitialBackoff = time.Millisecond
+	var deltas []string
+	_, err := client.GenerateStream(context.Background(), "", "go", func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err == nil {
+		t.Fatal("expected broken stream to fail")
+	}
+	// the 503 is retried; the broken stream is not, since text already went out.
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected 2 calls, got %d", got)
+	}
+	if len(deltas) != 1 || deltas[0] != "partial" {
+		t.Fatalf("expected the partial delta once, got %q", deltas)
+	}
+}
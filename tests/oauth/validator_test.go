@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/config"
+	"dir2mcp/internal/oauth"
+	"dir2mcp/tests/testutil"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "https://mcp.example.com/mcp"
+)
+
+func testOAuthConfig(t *testing.T, jwksFile string) config.OAuthConfig {
+	t.Helper()
+	cfg := config.Default()
+	cfg.OAuth.Issuer = testIssuer
+	cfg.OAuth.Audience = testAudience
+	cfg.OAuth.JWKSFile = jwksFile
+	if err := cfg.ValidateOAuth(); err != nil {
+		t.Fatalf("validate oauth config: %v", err)
+	}
+	return cfg.OAuth
+}
+
+func testClaims(now time.Time, overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "alice",
+		"exp":   now.Add(time.Hour).Unix(),
+		"scope": "dir2mcp:tools:*",
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(claims, key)
+			continue
+		}
+		claims[key] = value
+	}
+	return claims
+}
+
+func TestValidatorAcceptsSignedTokensAndMapsScopes(t *testing.T) {
+	issuer := testutil.NewJWTIssuer(t)
+	validator := oauth.NewValidator(testOAuthConfig(t, issuer.JWKSPath), nil)
+	now := time.Now()
+
+	token, err := validator.Authenticate(context.Background(), issuer.Sign(t, testClaims(now, nil)), now)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if token.ID != "oauth:alice" || len(token.Tools) != 0 || token.PathScoped() {
+		t.Fatalf("tools:* should grant every tool without path scope, got %+v", token)
+	}
+
+	scoped := testClaims(now, map[string]interface{}{
+		"aud":   []string{"other", testAudience},
+		"scope": "openid dir2mcp:tools:search dir2mcp:tools:open_file",
+		"scp":   []string{"dir2mcp:paths:/docs/", "dir2mcp:globs:**/*.md"},
+	})
+	token, err = validator.Authenticate(context.Background(), issuer.SignES256(t, scoped), now)
+	if err != nil {
+		t.Fatalf("authenticate es256: %v", err)
+	}
+	if want := []string{"dir2mcp.search", "dir2mcp.open_file"}; !reflect.DeepEqual(token.Tools, want) {
+		t.Fatalf("tools=%v want %v", token.Tools, want)
+	}
+	if !reflect.DeepEqual(token.PathPrefixes, []string{"docs/"}) || !reflect.DeepEqual(token.PathGlobs, []string{"**/*.md"}) {
+		t.Fatalf("unexpected path scope: prefixes=%v globs=%v", token.PathPrefixes, token.PathGlobs)
+	}
+
+	_, err = validator.Authenticate(context.Background(), issuer.Sign(t, testClaims(now, map[string]interface{}{"scope": "openid profile"})), now)
+	if !errors.Is(err, oauth.ErrInsufficientScope) {
+		t.Fatalf("token without dir2mcp scopes: err=%v want ErrInsufficientScope", err)
+	}
+}
+
+func TestValidatorRejectsInvalidTokens(t *testing.T) {
+	issuer := testutil.NewJWTIssuer(t)
+	other := testutil.NewJWTIssuer(t)
+	validator := oauth.NewValidator(testOAuthConfig(t, issuer.JWKSPath), nil)
+	now := time.Now()
+
+	tampered := issuer.Sign(t, testClaims(now, nil))
+	parts := strings.Split(tampered, ".")
+	parts[1] = strings.Split(issuer.Sign(t, testClaims(now, map[string]interface{}{"sub": "mallory"})), ".")[1]
+	tampered = strings.Join(parts, ".")
+
+	cases := map[string]string{
+		"wrong issuer":     issuer.Sign(t, testClaims(now, map[string]interface{}{"iss": "https://evil.example.com"})),
+		"wrong audience":   issuer.Sign(t, testClaims(now, map[string]interface{}{"aud": "https://other.example.com"})),
+		"expired":          issuer.Sign(t, testClaims(now, map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})),
+		"missing exp":      issuer.Sign(t, testClaims(now, map[string]interface{}{"exp": nil})),
+		"not yet valid":    issuer.Sign(t, testClaims(now, map[string]interface{}{"nbf": now.Add(5 * time.Minute).Unix()})),
+		"unknown key":      other.Sign(t, testClaims(now, nil)),
+		"tampered payload": tampered,
+		"alg none":         issuer.Unsigned(t, testClaims(now, nil)),
+		"not a jwt":        "d2m_not-a-jwt",
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := validator.Authenticate(context.Background(), raw, now); !errors.Is(err, oauth.ErrInvalidToken) {
+				t.Fatalf("err=%v want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestValidatorAllowsClockSkew(t *testing.T) {
+	issuer := testutil.NewJWTIssuer(t)
+	validator := oauth.NewValidator(testOAuthConfig(t, issuer.JWKSPath), nil)
+	now := time.Now()
+
+	// the default skew is one minute.
+	raw := issuer.Sign(t, testClaims(now, map[string]interface{}{
+		"exp": now.Add(-30 * time.Second).Unix(),
+		"nbf": now.Add(30 * time.Second).Unix(),
+	}))
+	if _, err := validator.Authenticate(context.Background(), raw, now); err != nil {
+		t.Fatalf("token within clock skew: %v", err)
+	}
+}
+
+func TestValidatorFetchesJWKSFromURL(t *testing.T) {
+	issuer := testutil.NewJWTIssuer(t)
+	jwks, err := os.ReadFile(issuer.JWKSPath)
+	if err != nil {
+		t.Fatalf("read jwks: %v", err)
+	}
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+
+	cfg := testOAuthConfig(t, issuer.JWKSPath)
+	cfg.JWKSFile = ""
+	cfg.JWKSURL = server.URL
+	validator := oauth.NewValidator(cfg, server.Client())
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := validator.Authenticate(context.Background(), issuer.Sign(t, testClaims(now, nil)), now); err != nil {
+			t.Fatalf("authenticate: %v", err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("jwks fetched %d times want 1", fetches)
+	}
+}
+
+func TestValidatorPathScopeCannotEscapeWithDotDot(t *testing.T) {
+	issuer := testutil.NewJWTIssuer(t)
+	validator := oauth.NewValidator(testOAuthConfig(t, issuer.JWKSPath), nil)
+	now := time.Now()
+
+	raw := issuer.Sign(t, testClaims(now, map[string]interface{}{
+		"scope": "dir2mcp:tools:open_file dir2mcp:paths:/docs",
+	}))
+	token, err := validator.Authenticate(context.Background(), raw, now)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	cases := map[string]bool{
+		"docs/a.md":               true,
+		"docs/../secrets/key.txt": false,
+		"docs-private/a.md":       false,
+		"/docs/a.md":              false,
+	}
+	for relPath, want := range cases {
+		if got := token.AllowsPath(relPath); got != want {
+			t.Errorf("AllowsPath(%q)=%t want=%t", relPath, got, want)
+		}
+	}
+}
+
+func TestValidatorSlowJWKSFetchDoesNotBlockKnownKeys(t *testing.T) {
+	issuer := testutil.NewJWTIssuer(t)
+	raw, err := os.ReadFile(issuer.JWKSPath)
+	if err != nil {
+		t.Fatalf("read jwks: %v", err)
+	}
+	// publish only the RSA key, so ES256 tokens carry an unknown kid.
+	var published struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &published); err != nil {
+		t.Fatalf("parse jwks: %v", err)
+	}
+	published.Keys = published.Keys[:1]
+	jwks, err := json.Marshal(published)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cfg := testOAuthConfig(t, issuer.JWKSPath)
+	cfg.JWKSFile = ""
+	cfg.JWKSURL = server.URL
+	validator := oauth.NewValidator(cfg, server.Client())
+	now := time.Now()
+	if _, err := validator.Authenticate(context.Background(), issuer.Sign(t, testClaims(now, nil)), now); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	// an unknown kid triggers a refetch that hangs until release.
+	later := now.Add(time.Minute)
+	go func() {
+		_, _ = validator.Authenticate(context.Background(), issuer.SignES256(t, testClaims(later, nil)), later)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for fetches.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if fetches.Load() < 2 {
+		t.Fatal("unknown kid did not trigger a refetch")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := validator.Authenticate(context.Background(), issuer.Sign(t, testClaims(later, nil)), later)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("authenticate with a known key: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a token with a known key waited for the JWKS fetch")
+	}
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// systemPromptGenerator records the system and user messages it receives.
+type systemPromptGenerator struct {
+	system string
+	prompt string
+}
+
+func (g *systemPromptGenerator) Generate(_ context.Context, prompt string) (string, error) {
+	g.prompt = prompt
+	return "answer [docs/guide.md]", nil
+}
+
+func (g *systemPromptGenerator) GenerateWithSystem(_ context.Context, system, prompt string) (string, error) {
+	g.system = system
+	g.prompt = prompt
+	return "answer [docs/guide.md]", nil
+}
+
+// fakeNeighborhoodStore serves chunks of one representation by ordinal.
+type fakeNeighborhoodStore struct {
+	fakeListOnlyStore
+	chunks []model.ContextChunk
+	radius []int
+}
+
+func (f *fakeNeighborhoodStore) ChunkNeighborhood(_ context.Context, chunkID uint64, radius int) ([]model.ContextChunk, error) {
+	f.radius = append(f.radius, radius)
+	var anchor *model.ContextChunk
+	for i := range f.chunks {
+		if f.chunks[i].ChunkID == chunkID {
+			anchor = &f.chunks[i]
+		}
+	}
+	if anchor == nil {
+		return nil, nil
+	}
+	out := make([]model.ContextChunk, 0)
+	for _, c := range f.chunks {
+		if c.RepID == anchor.RepID && c.Ordinal >= anchor.Ordinal-radius && c.Ordinal <= anchor.Ordinal+radius {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func newContextTestService(t *testing.T, st model.Store, gen model.Generator, labels ...uint64) *retrieval.Service {
+	t.Helper()
+	idx := index.NewHNSWIndex("")
+	for i, label := range labels {
+		if err := idx.Add(label, []float32{1, float32(i) * 0.01}); err != nil {
+			t.Fatalf("idx.Add failed: %v", err)
+		}
+	}
+	svc := retrieval.NewService(st, idx, &fakeRetrievalEmbedder{vectorsByModel: map[string][]float32{
+		"mistral-embed": {1, 0},
+	}}, gen)
+	return svc
+}
+
+func TestAsk_ContextUsesFullChunkTextAndMergesNeighbors(t *testing.T) {
+	longTail := strings.Repeat("filler ", 80) + "the deploy key rotates every 30 days"
+	st := &fakeNeighborhoodStore{chunks: []model.ContextChunk{
+		{ChunkID: 10, RepID: 1, Ordinal: 0, RelPath: "docs/guide.md", Text: "intro paragraph", Span: model.Span{Kind: "lines", StartLine: 1, EndLine: 4}},
+		{ChunkID: 11, RepID: 1, Ordinal: 1, RelPath: "docs/guide.md", Text: longTail, Span: model.Span{Kind: "lines", StartLine: 5, EndLine: 9}},
+		{ChunkID: 12, RepID: 1, Ordinal: 2, RelPath: "docs/guide.md", Text: "rotation steps", Span: model.Span{Kind: "lines", StartLine: 10, EndLine: 14}},
+		{ChunkID: 13, RepID: 1, Ordinal: 3, RelPath: "docs/guide.md", Text: "appendix", Span: model.Span{Kind: "lines", StartLine: 15, EndLine: 20}},
+	}}
+	gen := &systemPromptGenerator{}
+	svc := newContextTestService(t, st, gen, 11, 12)
+	svc.SetChatModel("mistral-small-latest")
+	svc.SetChunkMetadata(11, model.SearchHit{RelPath: "docs/guide.md", Snippet: "filler filler"})
+	svc.SetChunkMetadata(12, model.SearchHit{RelPath: "docs/guide.md", Snippet: "rotation steps"})
+
+	got, err := svc.Ask(context.Background(), "how often does the deploy key rotate?", model.SearchQuery{K: 2})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if got.Context == nil {
+		t.Fatal("expected assembled context in result")
+	}
+	if len(st.radius) == 0 || st.radius[0] != 1 {
+		t.Fatalf("expected default neighbor radius 1, got %v", st.radius)
+	}
+	if len(got.Context.Blocks) != 1 {
+		t.Fatalf("expected adjacent hits merged into one block, got %+v", got.Context.Blocks)
+	}
+	block := got.Context.Blocks[0]
+	if len(block.ChunkIDs) != 4 || block.Span.StartLine != 1 || block.Span.EndLine != 20 {
+		t.Fatalf("expected block covering chunks 10-13 and lines 1-20, got %+v", block)
+	}
+	if !strings.Contains(gen.prompt, "the deploy key rotates every 30 days") {
+		t.Fatal("expected full chunk text beyond the snippet in the prompt")
+	}
+	if gen.system == "" || strings.Contains(gen.prompt, gen.system) {
+		t.Fatal("expected system prompt to be sent separately from user content")
+	}
+	if gen.prompt != got.Context.UserPrompt || gen.system != got.Context.SystemPrompt {
+		t.Fatal("expected debug context to match what the generator received")
+	}
+	if got.Context.ChatModel != "mistral-small-latest" || got.Context.TokensUsed <= 0 {
+		t.Fatalf("unexpected context accounting: %+v", got.Context)
+	}
+}
+
+func TestAsk_ContextRespectsTokenBudgetPerChatModel(t *testing.T) {
+	st := &fakeNeighborhoodStore{chunks: []model.ContextChunk{
+		{ChunkID: 1, RepID: 1, Ordinal: 0, RelPath: "a.md", Text: strings.Repeat("a", 400)},
+		{ChunkID: 2, RepID: 2, Ordinal: 0, RelPath: "b.md", Text: strings.Repeat("b", 400)},
+		{ChunkID: 3, RepID: 3, Ordinal: 0, RelPath: "c.md", Text: strings.Repeat("c", 400)},
+	}}
+	gen := &systemPromptGenerator{}
+	svc := newContextTestService(t, st, gen, 1, 2, 3)
+	svc.SetChatModel("small-model")
+	svc.SetContextTokenBudgets(map[string]int{"default": 100000, "small-model": 250})
+	svc.SetNeighborChunks(0)
+	for _, label := range []uint64{1, 2, 3} {
+		svc.SetChunkMetadata(label, model.SearchHit{RelPath: st.chunks[label-1].RelPath})
+	}
+
+	got, err := svc.Ask(context.Background(), "q", model.SearchQuery{K: 3})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	c := got.Context
+	if c == nil || c.TokenBudget != 250 {
+		t.Fatalf("expected per-model budget of 250, got %+v", c)
+	}
+	if len(c.Blocks) != 2 || c.Dropped != 1 {
+		t.Fatalf("expected two blocks packed and one dropped, got blocks=%d dropped=%d", len(c.Blocks), c.Dropped)
+	}
+	if c.TokensUsed > c.TokenBudget {
+		t.Fatalf("context exceeds budget: used=%d budget=%d", c.TokensUsed, c.TokenBudget)
+	}
+	if st.radius[0] != 0 {
+		t.Fatalf("expected neighbor expansion disabled, got radius %d", st.radius[0])
+	}
+}
+
+func TestAsk_ContextFallsBackToSnippetsWithoutChunkStore(t *testing.T) {
+	gen := &fakeGenerator{out: "generated"}
+	svc := newContextTestService(t, nil, gen, 1)
+	svc.SetChunkMetadata(1, model.SearchHit{RelPath: "docs/a.md", Snippet: "alpha snippet"})
+
+	got, err := svc.Ask(context.Background(), "q", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if got.Context == nil || len(got.Context.Blocks) != 1 || got.Context.Blocks[0].Text != "alpha snippet" {
+		t.Fatalf("expected snippet-based context block, got %+v", got.Context)
+	}
+}
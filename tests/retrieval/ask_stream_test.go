@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// streamingGenerator streams a fixed answer in pieces and records which
+// generation method was used.
+type streamingGenerator struct {
+	systemPromptGenerator
+	pieces   []string
+	streamed bool
+}
+
+func (g *streamingGenerator) GenerateStream(_ context.Context, system, prompt string, onDelta func(string)) (string, error) {
+	g.streamed = true
+	g.system, g.prompt = system, prompt
+	for _, piece := range g.pieces {
+		onDelta(piece)
+	}
+	return strings.TrimSpace(strings.Join(g.pieces, "")), nil
+}
+
+func newStreamTestService(t *testing.T, gen model.Generator) *retrieval.Service {
+	t.Helper()
+	idx := index.NewHNSWIndex("")
+	if err := idx.Add(1, []float32{1, 0}); err != nil {
+		t.Fatalf("idx.Add failed: %v", err)
+	}
+	svc := retrieval.NewService(nil, idx, &fakeRetrievalEmbedder{vectorsByModel: map[string][]float32{
+		"mistral-embed": {1, 0},
+	}}, gen)
+	svc.SetChunkMetadata(1, model.SearchHit{
+		RelPath: "docs/a.md",
+		Snippet: "alpha snippet",
+		Span:    model.Span{Kind: "lines", StartLine: 1, EndLine: 2},
+	})
+	return svc
+}
+
+func TestAskStream_DeliversGeneratedDeltas(t *testing.T) {
+	gen := &streamingGenerator{pieces: []string{"Alpha ", "is first ", "[docs/a.md]"}}
+	svc := newStreamTestService(t, gen)
+
+	var deltas []string
+	got, err := svc.AskStream(context.Background(), "what is alpha?", model.SearchQuery{K: 1}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("AskStream failed: %v", err)
+	}
+	if !gen.streamed || gen.system == "" {
+		t.Fatalf("expected streamed generation with a system prompt, got %+v", gen)
+	}
+	if strings.Join(deltas, "") != "Alpha is first [docs/a.md]" {
+		t.Fatalf("unexpected deltas: %q", deltas)
+	}
+	if got.Answer != "Alpha is first [docs/a.md]" {
+		t.Fatalf("unexpected final answer: %q", got.Answer)
+	}
+}
+
+func TestAsk_DoesNotStreamWithoutCallback(t *testing.T) {
+	gen := &streamingGenerator{pieces: []string{"unused"}}
+	svc := newStreamTestService(t, gen)
+
+	got, err := svc.Ask(context.Background(), "what is alpha?", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if gen.streamed {
+		t.Fatal("Ask must not use the streaming path")
+	}
+	if !strings.HasPrefix(got.Answer, "answer [docs/guide.md]") {
+		t.Fatalf("expected the non-streamed answer, got %q", got.Answer)
+	}
+}
@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/model"
+)
+
+func TestRewriteQuestion_UsesHistoryAndCleansReply(t *testing.T) {
+	gen := &systemPromptGenerator{}
+	svc := newContextTestService(t, nil, &scriptedGenerator{inner: gen, out: "Standalone question: \"How is deploy key rotation configured?\"\n"})
+
+	got, err := svc.RewriteQuestion(context.Background(), "and how is that configured?", []model.ConversationTurn{
+		{Question: "how often does it rotate?", Standalone: "how often does the deploy key rotate?", Answer: "Every 30 days."},
+	})
+	if err != nil {
+		t.Fatalf("RewriteQuestion failed: %v", err)
+	}
+	if got != "How is deploy key rotation configured?" {
+		t.Fatalf("unexpected rewrite: %q", got)
+	}
+	if !strings.Contains(gen.prompt, "Q: how often does the deploy key rotate?") || !strings.Contains(gen.prompt, "A: Every 30 days.") {
+		t.Fatalf("expected standalone history in prompt, got %q", gen.prompt)
+	}
+	if gen.system == "" {
+		t.Fatal("expected rewrite instructions sent as a system message")
+	}
+}
+
+func TestRewriteQuestion_WithoutHistorySkipsGenerator(t *testing.T) {
+	gen := &systemPromptGenerator{}
+	svc := newContextTestService(t, nil, gen)
+
+	got, err := svc.RewriteQuestion(context.Background(), " first question ", nil)
+	if err != nil {
+		t.Fatalf("RewriteQuestion failed: %v", err)
+	}
+	if got != "first question" || gen.prompt != "" {
+		t.Fatalf("expected question returned unchanged without generator call, got %q (prompt %q)", got, gen.prompt)
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+func TestSearchWithMetadata_MaxHitsPerFileCapsEachFile(t *testing.T) {
+	svc := newContextTestService(t, nil, nil, 1, 2, 3, 4, 5)
+	for label, relPath := range map[uint64]string{1: "a.md", 2: "a.md", 3: "a.md", 4: "b.md", 5: "b.md"} {
+		svc.SetChunkMetadata(label, model.SearchHit{RelPath: relPath, Snippet: relPath})
+	}
+
+	result, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{Query: "q", K: 3, MaxHitsPerFile: 1})
+	if err != nil {
+		t.Fatalf("SearchWithMetadata failed: %v", err)
+	}
+	if len(result.Hits) != 2 || result.Hits[0].ChunkID != 1 || result.Hits[1].ChunkID != 4 {
+		t.Fatalf("expected one hit from each file in rank order, got %+v", result.Hits)
+	}
+	if result.Rerank != nil {
+		t.Fatalf("expected no rerank metadata without a reranker, got %+v", result.Rerank)
+	}
+}
+
+func TestSearchWithMetadata_MMRSkipsNearDuplicates(t *testing.T) {
+	idx := index.NewHNSWIndex("")
+	vectors := map[uint64][]float32{
+		1: {1, 0.01},   // best match
+		2: {1, 0.02},   // near-duplicate of 1
+		3: {0.8, 0.6},  // related but different
+		4: {0.05, 1.0}, // barely related
+	}
+	for label, vec := range vectors {
+		if err := idx.Add(label, vec); err != nil {
+			t.Fatalf("idx.Add failed: %v", err)
+		}
+	}
+	svc := retrieval.NewService(nil, idx, &fakeRetrievalEmbedder{vectorsByModel: map[string][]float32{
+		"mistral-embed": {1, 0},
+	}}, nil)
+	for label := range vectors {
+		svc.SetChunkMetadata(label, model.SearchHit{RelPath: fmt.Sprintf("docs/%d.md", label)})
+	}
+
+	plain, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{Query: "q", K: 2})
+	if err != nil {
+		t.Fatalf("SearchWithMetadata failed: %v", err)
+	}
+	if len(plain.Hits) != 2 || plain.Hits[1].ChunkID != 2 {
+		t.Fatalf("expected near-duplicate second without MMR, got %+v", plain.Hits)
+	}
+
+	diverse, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{Query: "q", K: 2, MMRLambda: 0.3})
+	if err != nil {
+		t.Fatalf("SearchWithMetadata failed: %v", err)
+	}
+	if len(diverse.Hits) != 2 || diverse.Hits[0].ChunkID != 1 {
+		t.Fatalf("expected best match first, got %+v", diverse.Hits)
+	}
+	if diverse.Hits[1].ChunkID == 2 {
+		t.Fatalf("expected MMR to skip the near-duplicate, got %+v", diverse.Hits)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"dir2mcp/internal/model"
+)
+
+// fakeFilterStore resolves every metadata filter to a fixed set of chunk IDs.
+type fakeFilterStore struct {
+	fakeListOnlyStore
+	matching []uint64
+	filters  []model.MetadataFilter
+}
+
+func (f *fakeFilterStore) ChunkIDsMatching(_ context.Context, filter model.MetadataFilter) ([]uint64, error) {
+	f.filters = append(f.filters, filter)
+	return f.matching, nil
+}
+
+// fakeDocumentStore serves documents by path but cannot pre-filter chunks.
+type fakeDocumentStore struct {
+	fakeListOnlyStore
+	byPath map[string]model.Document
+}
+
+func (f *fakeDocumentStore) GetDocumentByPath(_ context.Context, relPath string) (model.Document, error) {
+	doc, ok := f.byPath[relPath]
+	if !ok {
+		return model.Document{}, model.ErrNotImplemented
+	}
+	return doc, nil
+}
+
+func TestSearch_MetadataFilterReachesChunksBeyondOverfetchWindow(t *testing.T) {
+	labels := make([]uint64, 0, 30)
+	for label := uint64(1); label <= 30; label++ {
+		labels = append(labels, label)
+	}
+	// the only matching chunk is the least similar one, far outside the
+	// K*overfetch candidates an unfiltered search would consider.
+	st := &fakeFilterStore{matching: []uint64{30}}
+	svc := newContextTestService(t, st, nil, labels...)
+	for _, label := range labels {
+		svc.SetChunkMetadata(label, model.SearchHit{RelPath: fmt.Sprintf("docs/%02d.md", label), Snippet: "text"})
+	}
+
+	hits, err := svc.Search(context.Background(), model.SearchQuery{
+		Query:          "anything",
+		K:              1,
+		MetadataFilter: model.MetadataFilter{ModifiedAfter: 1_700_000_000},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ChunkID != 30 {
+		t.Fatalf("expected the filtered chunk 30, got %+v", hits)
+	}
+	if len(st.filters) != 1 || st.filters[0].ModifiedAfter != 1_700_000_000 {
+		t.Fatalf("expected filter resolved once against the store, got %+v", st.filters)
+	}
+}
+
+func TestSearch_MetadataFilterWithNoMatchesReturnsNothing(t *testing.T) {
+	st := &fakeFilterStore{matching: nil}
+	svc := newContextTestService(t, st, nil, 1, 2)
+	svc.SetChunkMetadata(1, model.SearchHit{RelPath: "docs/a.md"})
+	svc.SetChunkMetadata(2, model.SearchHit{RelPath: "docs/b.md"})
+
+	hits, err := svc.Search(context.Background(), model.SearchQuery{
+		Query:          "anything",
+		K:              2,
+		MetadataFilter: model.MetadataFilter{Statuses: []string{"error"}},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("expected no hits, got %+v", hits)
+	}
+}
+
+func TestSearch_MetadataFilterFallsBackToDocumentLookups(t *testing.T) {
+	st := &fakeDocumentStore{byPath: map[string]model.Document{
+		"src/small.go": {RelPath: "src/small.go", SizeBytes: 100},
+		"src/large.go": {RelPath: "src/large.go", SizeBytes: 10_000},
+		"docs/big.md":  {RelPath: "docs/big.md", SizeBytes: 10_000},
+	}}
+	svc := newContextTestService(t, st, nil, 1, 2, 3)
+	svc.SetChunkMetadata(1, model.SearchHit{RelPath: "src/small.go", RepType: "raw_text"})
+	svc.SetChunkMetadata(2, model.SearchHit{RelPath: "src/large.go", RepType: "raw_text"})
+	svc.SetChunkMetadata(3, model.SearchHit{RelPath: "docs/big.md", RepType: "raw_text"})
+
+	hits, err := svc.Search(context.Background(), model.SearchQuery{
+		Query: "anything",
+		K:     3,
+		MetadataFilter: model.MetadataFilter{
+			MinSizeBytes: 1_000,
+			Languages:    []string{"go"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].RelPath != "src/large.go" {
+		t.Fatalf("expected only the large Go file, got %+v", hits)
+	}
+}
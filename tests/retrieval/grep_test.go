@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+type fakeGrepStore struct {
+	fakeListOnlyStore
+	docs    []model.Document
+	repType string
+	prefix  string
+	glob    string
+}
+
+func (f *fakeGrepStore) ListDocumentsWithRepresentation(_ context.Context, repType, prefix, glob string) ([]model.Document, error) {
+	f.repType, f.prefix, f.glob = repType, prefix, glob
+	return f.docs, nil
+}
+
+// newGrepTestService writes files under a temp root and returns a service
+// whose store lists every written path as a raw-text document.
+func newGrepTestService(t *testing.T, files map[string]string) (*retrieval.Service, *fakeGrepStore) {
+	t.Helper()
+	root := t.TempDir()
+	st := &fakeGrepStore{}
+	for _, relPath := range []string{"docs/a.md", "docs/b.md", "src/main.go", "config/creds.txt", "private/notes.md"} {
+		content, ok := files[relPath]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		st.docs = append(st.docs, model.Document{RelPath: relPath, SourceType: "filesystem"})
+	}
+	svc := retrieval.NewService(st, nil, nil, nil)
+	svc.SetRootDir(root)
+	return svc, st
+}
+
+func TestGrep_ReportsLineSpansWithContext(t *testing.T) {
+	svc, st := newGrepTestService(t, map[string]string{
+		"docs/a.md":   "alpha\nbeta\nTODO: fix gamma\ndelta\n",
+		"src/main.go": "package main\n// TODO wire flags\n",
+	})
+
+	var streamed []model.GrepMatch
+	result, err := svc.Grep(context.Background(), model.GrepQuery{Pattern: `TODO\b`, ContextLines: 1, PathPrefix: "docs/", FileGlob: "*.md"}, func(m model.GrepMatch) {
+		streamed = append(streamed, m)
+	})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if st.repType != "raw_text" || st.prefix != "docs/" || st.glob != "*.md" {
+		t.Fatalf("expected raw_text lookup with filters, got %q %q %q", st.repType, st.prefix, st.glob)
+	}
+	if len(result.Matches) != 2 || len(streamed) != 2 {
+		t.Fatalf("expected 2 matches returned and streamed, got %d and %d", len(result.Matches), len(streamed))
+	}
+	first := result.Matches[0]
+	if first.RelPath != "docs/a.md" || first.Span != (model.Span{Kind: "lines", StartLine: 3, EndLine: 3}) {
+		t.Fatalf("unexpected first match: %+v", first)
+	}
+	if first.Line != "TODO: fix gamma" || len(first.Before) != 1 || first.Before[0] != "beta" || len(first.After) != 1 || first.After[0] != "delta" {
+		t.Fatalf("unexpected line or context: %+v", first)
+	}
+	second := result.Matches[1]
+	if second.RelPath != "src/main.go" || second.Span.StartLine != 2 || len(second.After) != 0 {
+		t.Fatalf("unexpected second match: %+v", second)
+	}
+	if result.FilesScanned != 2 || result.Truncated || result.TimedOut {
+		t.Fatalf("unexpected result summary: %+v", result)
+	}
+}
+
+func TestGrep_LiteralAndIgnoreCase(t *testing.T) {
+	svc, _ := newGrepTestService(t, map[string]string{
+		"docs/a.md": "cost is $5.00\nCOST is 5x00\n",
+	})
+
+	literal, err := svc.Grep(context.Background(), model.GrepQuery{Pattern: "$5.00", Literal: true}, nil)
+	if err != nil {
+		t.Fatalf("literal Grep failed: %v", err)
+	}
+	if len(literal.Matches) != 1 || literal.Matches[0].Span.StartLine != 1 {
+		t.Fatalf("expected one literal match on line 1, got %+v", literal.Matches)
+	}
+
+	folded, err := svc.Grep(context.Background(), model.GrepQuery{Pattern: "cost is 5.00", IgnoreCase: true}, nil)
+	if err != nil {
+		t.Fatalf("ignore-case Grep failed: %v", err)
+	}
+	if len(folded.Matches) != 1 || folded.Matches[0].Span.StartLine != 2 {
+		t.Fatalf("expected one case-folded regex match on line 2, got %+v", folded.Matches)
+	}
+
+	if _, err := svc.Grep(context.Background(), model.GrepQuery{Pattern: "("}, nil); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+}
+
+func TestGrep_StopsAtMaxMatches(t *testing.T) {
+	svc, _ := newGrepTestService(t, map[string]string{
+		"docs/a.md": "hit\nhit\nhit\n",
+		"docs/b.md": "hit\n",
+	})
+
+	result, err := svc.Grep(context.Background(), model.GrepQuery{Pattern: "hit", MaxMatches: 2}, nil)
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(result.Matches) != 2 || !result.Truncated {
+		t.Fatalf("expected 2 matches and truncated, got %+v", result)
+	}
+	if result.FilesScanned != 1 {
+		t.Fatalf("expected the scan to stop in the first file, got %d files scanned", result.FilesScanned)
+	}
+}
+
+func TestGrep_SkipsSecretsAndExcludedPaths(t *testing.T) {
+	svc, _ := newGrepTestService(t, map[string]string{
+		"docs/a.md":        "needle\n",
+		"config/creds.txt": "needle\nAKIAABCDEFGHIJKLMNOP\n",
+		"private/notes.md": "needle\n",
+	})
+	svc.SetPathExcludes([]string{"private/**"})
+
+	result, err := svc.Grep(context.Background(), model.GrepQuery{Pattern: "needle"}, nil)
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].RelPath != "docs/a.md" {
+		t.Fatalf("expected only docs/a.md to match, got %+v", result.Matches)
+	}
+	if result.FilesScanned != 1 || result.FilesSkipped != 2 {
+		t.Fatalf("expected 1 scanned and 2 skipped, got %+v", result)
+	}
+}
+
+func TestGrep_DeadlineReturnsPartialResult(t *testing.T) {
+	svc, _ := newGrepTestService(t, map[string]string{
+		"docs/a.md": "needle\n",
+	})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	result, err := svc.Grep(ctx, model.GrepQuery{Pattern: "needle"}, nil)
+	if err != nil {
+		t.Fatalf("expected partial result on deadline, got %v", err)
+	}
+	if !result.TimedOut || len(result.Matches) != 0 {
+		t.Fatalf("expected timed out empty result, got %+v", result)
+	}
+
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, err := svc.Grep(canceled, model.GrepQuery{Pattern: "needle"}, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestGrep_RequiresRepresentationLister(t *testing.T) {
+	svc := retrieval.NewService(&fakeListOnlyStore{}, nil, nil, nil)
+	if _, err := svc.Grep(context.Background(), model.GrepQuery{Pattern: "x"}, nil); !errors.Is(err, model.ErrNotImplemented) {
+		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	}
+}
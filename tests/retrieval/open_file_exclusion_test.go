@@ -95,3 +95,45 @@ func TestOpenFile_ContentPatternOverride(t *testing.T) {
 		t.Fatalf("expected forbidden on custom content pattern, got %v", err)
 	}
 }
+
+func TestOpenFileBytes_AppliesOpenFileChecks(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"docs/secret.txt":  "token = AAAAAAAAAAAAAAAAAAAAAAAAA",
+		"private/key.bin":  "\x00\x01",
+		"docs/payload.bin": "\x00\x01\x02\x03",
+	}
+	for relPath, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	svc := retrieval.NewService(nil, nil, nil, nil)
+	svc.SetRootDir(root)
+	svc.SetPathExcludes([]string{"private/**"})
+	ctx := context.Background()
+
+	if _, _, err := svc.OpenFileBytes(ctx, "docs/secret.txt", 0); !errors.Is(err, model.ErrForbidden) {
+		t.Fatalf("expected forbidden on secret content, got %v", err)
+	}
+	if _, _, err := svc.OpenFileBytes(ctx, "private/key.bin", 0); !errors.Is(err, model.ErrForbidden) {
+		t.Fatalf("expected forbidden on excluded path, got %v", err)
+	}
+	if _, _, err := svc.OpenFileBytes(ctx, "../outside.bin", 0); !errors.Is(err, model.ErrPathOutsideRoot) {
+		t.Fatalf("expected path outside root, got %v", err)
+	}
+
+	data, truncated, err := svc.OpenFileBytes(ctx, "docs/payload.bin", 0)
+	if err != nil || truncated || string(data) != "\x00\x01\x02\x03" {
+		t.Fatalf("unexpected whole read: %q truncated=%v err=%v", data, truncated, err)
+	}
+	data, truncated, err = svc.OpenFileBytes(ctx, "docs/payload.bin", 2)
+	if err != nil || !truncated || len(data) != 2 {
+		t.Fatalf("unexpected bounded read: %q truncated=%v err=%v", data, truncated, err)
+	}
+}
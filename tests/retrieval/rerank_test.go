@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// newRerankTestService indexes one chunk per snippet; first-stage order
+// follows the snippet order.
+func newRerankTestService(t *testing.T, gen model.Generator, snippets ...string) *retrieval.Service {
+	t.Helper()
+	labels := make([]uint64, 0, len(snippets))
+	for i := range snippets {
+		labels = append(labels, uint64(i+1))
+	}
+	svc := newContextTestService(t, nil, gen, labels...)
+	for i, snippet := range snippets {
+		svc.SetChunkMetadata(labels[i], model.SearchHit{
+			ChunkID: labels[i],
+			RelPath: fmt.Sprintf("docs/%d.md", labels[i]),
+			Snippet: snippet,
+		})
+	}
+	return svc
+}
+
+func TestSearchWithMetadata_LexicalRerankReordersCandidates(t *testing.T) {
+	svc := newRerankTestService(t, nil,
+		"unrelated introduction",
+		"nothing relevant here",
+		"how to rotate the deploy key",
+	)
+
+	result, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{
+		Query:  "rotate deploy key",
+		K:      1,
+		Rerank: retrieval.RerankerLexical,
+	})
+	if err != nil {
+		t.Fatalf("SearchWithMetadata failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].ChunkID != 3 {
+		t.Fatalf("expected reranked top hit chunk 3, got %+v", result.Hits)
+	}
+	if !result.Hits[0].Reranked || result.Hits[0].RerankScore <= 0 {
+		t.Fatalf("expected rerank score on hit, got %+v", result.Hits[0])
+	}
+	if result.Rerank == nil || result.Rerank.Reranker != "lexical" || result.Rerank.Candidates != 3 {
+		t.Fatalf("unexpected rerank metadata: %+v", result.Rerank)
+	}
+	if result.Rerank.LatencyMS < 0 || result.Rerank.Error != "" {
+		t.Fatalf("unexpected rerank metadata: %+v", result.Rerank)
+	}
+}
+
+func TestSearchWithMetadata_RerankRetrievesKTimesOverfetchCandidates(t *testing.T) {
+	snippets := make([]string, 20)
+	for i := range snippets {
+		snippets[i] = fmt.Sprintf("passage %d", i)
+	}
+	svc := newRerankTestService(t, nil, snippets...)
+	svc.SetOverfetchMultiplier(3)
+
+	result, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{
+		Query:  "passage",
+		K:      2,
+		Rerank: "lexical",
+	})
+	if err != nil {
+		t.Fatalf("SearchWithMetadata failed: %v", err)
+	}
+	if result.Rerank == nil || result.Rerank.Candidates != 6 {
+		t.Fatalf("expected 6 candidates (k=2 * overfetch=3), got %+v", result.Rerank)
+	}
+	if len(result.Hits) != 2 {
+		t.Fatalf("expected results truncated to k, got %d", len(result.Hits))
+	}
+}
+
+func TestSearchWithMetadata_LLMRerankUsesGeneratorScores(t *testing.T) {
+	gen := &systemPromptGenerator{}
+	svc := newRerankTestService(t, nil, "first passage", "second passage")
+	reranker := retrieval.NewLLMReranker(&scriptedGenerator{inner: gen, out: "Scores: [2, 9]"})
+	svc.SetReranker(retrieval.RerankerLLM, reranker)
+
+	result, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{
+		Query:  "which passage?",
+		K:      2,
+		Rerank: "llm",
+	})
+	if err != nil {
+		t.Fatalf("SearchWithMetadata failed: %v", err)
+	}
+	if len(result.Hits) != 2 || result.Hits[0].ChunkID != 2 || result.Hits[0].RerankScore != 9 {
+		t.Fatalf("expected chunk 2 promoted with score 9, got %+v", result.Hits)
+	}
+	if !strings.Contains(gen.prompt, "[2] docs/2.md") || !strings.Contains(gen.prompt, "which passage?") {
+		t.Fatalf("expected numbered passages and query in prompt, got %q", gen.prompt)
+	}
+	if gen.system == "" {
+		t.Fatal("expected grading instructions sent as a system message")
+	}
+}
+
+func TestSearchWithMetadata_RerankFailureKeepsFirstStageOrder(t *testing.T) {
+	svc := newRerankTestService(t, nil, "first passage", "second passage")
+	svc.SetReranker(retrieval.RerankerLLM, retrieval.NewLLMReranker(&fakeGenerator{err: errors.New("upstream down")}))
+
+	result, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{
+		Query:  "passage",
+		K:      2,
+		Rerank: "llm",
+	})
+	if err != nil {
+		t.Fatalf("expected rerank failure to be non-fatal, got %v", err)
+	}
+	if len(result.Hits) != 2 || result.Hits[0].ChunkID != 1 || result.Hits[0].Reranked {
+		t.Fatalf("expected first-stage order, got %+v", result.Hits)
+	}
+	if result.Rerank == nil || !strings.Contains(result.Rerank.Error, "upstream down") {
+		t.Fatalf("expected rerank error in metadata, got %+v", result.Rerank)
+	}
+}
+
+func TestSearchWithMetadata_UnknownReranker(t *testing.T) {
+	svc := newRerankTestService(t, nil, "passage")
+
+	_, err := svc.SearchWithMetadata(context.Background(), model.SearchQuery{Query: "passage", Rerank: "llm"})
+	if !errors.Is(err, model.ErrUnknownReranker) {
+		t.Fatalf("expected ErrUnknownReranker, got %v", err)
+	}
+}
+
+func TestAsk_ReportsRerankMetadata(t *testing.T) {
+	svc := newRerankTestService(t, &fakeGenerator{out: "answer"}, "alpha", "beta gamma")
+
+	got, err := svc.Ask(context.Background(), "gamma", model.SearchQuery{K: 2, Rerank: "lexical"})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if got.Rerank == nil || got.Rerank.Reranker != "lexical" {
+		t.Fatalf("expected rerank metadata on ask result, got %+v", got.Rerank)
+	}
+	if len(got.Hits) == 0 || got.Hits[0].ChunkID != 2 {
+		t.Fatalf("expected reranked hit first, got %+v", got.Hits)
+	}
+}
+
+// scriptedGenerator records prompts via inner but returns a fixed reply.
+type scriptedGenerator struct {
+	inner *systemPromptGenerator
+	out   string
+}
+
+func (g *scriptedGenerator) Generate(ctx context.Context, prompt string) (string, error) {
+	_, _ = g.inner.Generate(ctx, prompt)
+	return g.out, nil
+}
+
+func (g *scriptedGenerator) GenerateWithSystem(ctx context.Context, system, prompt string) (string, error) {
+	_, _ = g.inner.GenerateWithSystem(ctx, system, prompt)
+	return g.out, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"log"
 	"math"
 	"os"
@@ -238,6 +239,35 @@ func TestSearch_ReturnsRankedHitsWithFilters(t *testing.T) {
 	}
 }
 
+func TestSearch_UsesModelRecordedInIndex(t *testing.T) {
+	idx := index.NewHNSWIndex("")
+	idx.SetModel("old-embed")
+	if err := idx.Add(1, []float32{1, 0}); err != nil {
+		t.Fatalf("idx.Add failed: %v", err)
+	}
+	if err := idx.Add(2, []float32{0, 1}); err != nil {
+		t.Fatalf("idx.Add failed: %v", err)
+	}
+
+	// the configured query model points at chunk 2, but the index was built
+	// with old-embed, which must win so queries match the stored vectors.
+	svc := retrieval.NewService(nil, idx, &fakeRetrievalEmbedder{vectorsByModel: map[string][]float32{
+		"new-embed": {0, 1},
+		"old-embed": {1, 0},
+	}}, nil)
+	svc.SetQueryEmbeddingModel("new-embed")
+	svc.SetChunkMetadata(1, model.SearchHit{RelPath: "docs/a.md", DocType: "md"})
+	svc.SetChunkMetadata(2, model.SearchHit{RelPath: "docs/b.md", DocType: "md"})
+
+	hits, err := svc.Search(context.Background(), model.SearchQuery{Query: "alpha", K: 1, Index: "text"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ChunkID != 1 {
+		t.Fatalf("expected hit from the recorded model, got %#v", hits)
+	}
+}
+
 func TestSearch_FileGlobFilter(t *testing.T) {
 	idx := index.NewHNSWIndex("")
 	if err := idx.Add(10, []float32{1, 0}); err != nil {
@@ -369,6 +399,120 @@ func TestSearch_BothMode_DedupesAndNormalizes(t *testing.T) {
 	}
 }
 
+// fakeLexicalStore serves canned chunks to hybrid search.
+type fakeLexicalStore struct {
+	fakeListOnlyStore
+	chunks    []model.ChunkTask
+	lastTerms []string
+}
+
+func (f *fakeLexicalStore) SearchChunksLexical(_ context.Context, terms []string, _ int) ([]model.ChunkTask, error) {
+	f.lastTerms = append([]string(nil), terms...)
+	return f.chunks, nil
+}
+
+func TestSearch_HybridMode_FusesVectorAndLexical(t *testing.T) {
+	textIdx := index.NewHNSWIndex("")
+	if err := textIdx.Add(1, []float32{1, 0}); err != nil {
+		t.Fatalf("textIdx.Add failed: %v", err)
+	}
+	if err := textIdx.Add(2, []float32{0.6, 0.4}); err != nil {
+		t.Fatalf("textIdx.Add failed: %v", err)
+	}
+
+	st := &fakeLexicalStore{chunks: []model.ChunkTask{
+		{Label: 2, Text: "retry budget retry policy", Metadata: model.ChunkMetadata{ChunkID: 2, RelPath: "docs/retry.md", DocType: "md"}, LexicalScore: 2.5},
+		{Label: 3, Text: "the retry loop", Metadata: model.ChunkMetadata{ChunkID: 3, RelPath: "src/loop.go", DocType: "code"}, LexicalScore: 0.8},
+	}}
+	svc := retrieval.NewService(st, textIdx, &fakeRetrievalEmbedder{}, nil)
+	svc.SetChunkMetadata(1, model.SearchHit{RelPath: "docs/intro.md", DocType: "md"})
+	svc.SetChunkMetadata(2, model.SearchHit{RelPath: "docs/retry.md", DocType: "md"})
+
+	hits, err := svc.Search(context.Background(), model.SearchQuery{Query: "Retry policy", K: 10, Index: "hybrid"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(st.lastTerms) != 2 || st.lastTerms[0] != "retry" || st.lastTerms[1] != "policy" {
+		t.Fatalf("unexpected lexical terms: %v", st.lastTerms)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("expected 3 fused hits, got %d: %+v", len(hits), hits)
+	}
+	// chunk 2 matches both signals and must rank first.
+	if hits[0].ChunkID != 2 || hits[0].VectorScore <= 0 || hits[0].LexicalScore <= 0 {
+		t.Fatalf("expected chunk 2 first with both components, got %+v", hits[0])
+	}
+	for i, hit := range hits {
+		if hit.FusedRank != i+1 {
+			t.Fatalf("hit %d has fused_rank %d", i, hit.FusedRank)
+		}
+		if i > 0 && hit.Score > hits[i-1].Score {
+			t.Fatalf("hits not ordered by fused score: %+v", hits)
+		}
+	}
+	for _, hit := range hits {
+		switch hit.ChunkID {
+		case 1:
+			if hit.LexicalScore != 0 || hit.VectorScore <= 0 {
+				t.Fatalf("chunk 1 should be vector-only: %+v", hit)
+			}
+		case 3:
+			if hit.VectorScore != 0 || hit.LexicalScore <= 0 || hit.RelPath != "src/loop.go" {
+				t.Fatalf("chunk 3 should be lexical-only with store metadata: %+v", hit)
+			}
+		}
+	}
+}
+
+func TestSearch_HybridMode_KeepsStoreLexicalRanking(t *testing.T) {
+	textIdx := index.NewHNSWIndex("")
+	if err := textIdx.Add(9, []float32{1, 0}); err != nil {
+		t.Fatalf("textIdx.Add failed: %v", err)
+	}
+	// re-scoring this pool on its own would favour chunk 5, which repeats
+	// the query term; the store ranked chunk 4 first over the whole corpus.
+	st := &fakeLexicalStore{chunks: []model.ChunkTask{
+		{Label: 4, Text: "quorum", Metadata: model.ChunkMetadata{ChunkID: 4, RelPath: "docs/raft.md", DocType: "md"}, LexicalScore: 7.5},
+		{Label: 5, Text: "quorum quorum quorum", Metadata: model.ChunkMetadata{ChunkID: 5, RelPath: "docs/notes.md", DocType: "md"}, LexicalScore: 1.25},
+	}}
+	svc := retrieval.NewService(st, textIdx, &fakeRetrievalEmbedder{}, nil)
+	svc.SetChunkMetadata(9, model.SearchHit{RelPath: "docs/intro.md", DocType: "md"})
+
+	hits, err := svc.Search(context.Background(), model.SearchQuery{Query: "quorum", K: 10, Index: "hybrid"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	scores := map[uint64]float64{}
+	rank := map[uint64]int{}
+	for _, hit := range hits {
+		scores[hit.ChunkID] = hit.LexicalScore
+		rank[hit.ChunkID] = hit.FusedRank
+	}
+	if scores[4] != 7.5 || scores[5] != 1.25 {
+		t.Fatalf("expected the store's lexical scores, got %+v", hits)
+	}
+	if rank[4] >= rank[5] {
+		t.Fatalf("expected chunk 4 to outrank chunk 5, got %+v", hits)
+	}
+}
+
+func TestSearch_HybridMode_WithoutLexicalStoreFallsBackToVector(t *testing.T) {
+	textIdx := index.NewHNSWIndex("")
+	if err := textIdx.Add(1, []float32{1, 0}); err != nil {
+		t.Fatalf("textIdx.Add failed: %v", err)
+	}
+	svc := retrieval.NewService(nil, textIdx, &fakeRetrievalEmbedder{}, nil)
+	svc.SetLogger(log.New(io.Discard, "", 0))
+
+	hits, err := svc.Search(context.Background(), model.SearchQuery{Query: "anything", K: 5, Index: "hybrid"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].FusedRank != 1 || hits[0].LexicalScore != 0 {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+}
+
 func TestOpenFile_LineSpan(t *testing.T) {
 	root := t.TempDir()
 	filePath := filepath.Join(root, "docs", "a.md")
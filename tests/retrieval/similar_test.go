@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// newSimilarTestService indexes two chunks of docs/a.md pointing east, one
+// chunk of docs/b.md between them and north, and docs/c.md pointing north.
+func newSimilarTestService(t *testing.T, st model.Store) (*retrieval.Service, *countingEmbedder) {
+	t.Helper()
+	idx := index.NewHNSWIndex("")
+	chunks := []struct {
+		label   uint64
+		vector  []float32
+		relPath string
+		span    model.Span
+	}{
+		{1, []float32{1, 0}, "docs/a.md", model.Span{Kind: "lines", StartLine: 1, EndLine: 10}},
+		{2, []float32{0.9, 0.1}, "docs/a.md", model.Span{Kind: "lines", StartLine: 11, EndLine: 20}},
+		{3, []float32{0.7, 0.7}, "docs/b.md", model.Span{Kind: "lines", StartLine: 1, EndLine: 5}},
+		{4, []float32{0, 1}, "docs/c.md", model.Span{Kind: "lines", StartLine: 1, EndLine: 5}},
+	}
+	embedder := &countingEmbedder{}
+	svc := retrieval.NewService(st, idx, embedder, nil)
+	for _, c := range chunks {
+		if err := idx.Add(c.label, c.vector); err != nil {
+			t.Fatalf("idx.Add failed: %v", err)
+		}
+		svc.SetChunkMetadataForIndex("text", c.label, model.SearchHit{RelPath: c.relPath, DocType: "md", Span: c.span})
+	}
+	return svc, embedder
+}
+
+// countingEmbedder records how often it is asked to embed text.
+type countingEmbedder struct {
+	calls int
+}
+
+func (e *countingEmbedder) Embed(context.Context, string, []string) ([][]float32, error) {
+	e.calls++
+	return [][]float32{{1, 0}}, nil
+}
+
+func TestFindSimilar_ChunkSeedReusesStoredVectorAndExcludesSeed(t *testing.T) {
+	svc, embedder := newSimilarTestService(t, nil)
+
+	result, err := svc.FindSimilar(context.Background(), model.SimilarQuery{ChunkID: 4, SearchQuery: model.SearchQuery{K: 2}})
+	if err != nil {
+		t.Fatalf("FindSimilar failed: %v", err)
+	}
+	if embedder.calls != 0 {
+		t.Fatalf("expected stored vectors to be reused, embedder called %d times", embedder.calls)
+	}
+	if len(result.SeedChunkIDs) != 1 || result.SeedChunkIDs[0] != 4 || result.IndexUsed != "text" {
+		t.Fatalf("unexpected seed metadata: %+v", result)
+	}
+	if len(result.Hits) != 2 || result.Hits[0].ChunkID != 3 || result.Hits[0].RelPath != "docs/b.md" {
+		t.Fatalf("expected docs/b.md nearest to the seed, got %+v", result.Hits)
+	}
+	for _, hit := range result.Hits {
+		if hit.ChunkID == 4 {
+			t.Fatalf("seed chunk returned as a hit: %+v", result.Hits)
+		}
+	}
+}
+
+func TestFindSimilar_FileSeedAveragesChunksAndExcludesFile(t *testing.T) {
+	svc, _ := newSimilarTestService(t, nil)
+
+	result, err := svc.FindSimilar(context.Background(), model.SimilarQuery{RelPath: "docs/a.md", SearchQuery: model.SearchQuery{K: 5}})
+	if err != nil {
+		t.Fatalf("FindSimilar failed: %v", err)
+	}
+	if len(result.SeedChunkIDs) != 2 || result.SeedChunkIDs[0] != 1 || result.SeedChunkIDs[1] != 2 {
+		t.Fatalf("expected both chunks of the file as seeds, got %v", result.SeedChunkIDs)
+	}
+	if len(result.Hits) != 2 || result.Hits[0].ChunkID != 3 || result.Hits[1].ChunkID != 4 {
+		t.Fatalf("expected the other files in similarity order, got %+v", result.Hits)
+	}
+}
+
+func TestFindSimilar_SpanRestrictsFileSeed(t *testing.T) {
+	svc, _ := newSimilarTestService(t, nil)
+
+	result, err := svc.FindSimilar(context.Background(), model.SimilarQuery{
+		RelPath:     "docs/a.md",
+		Span:        model.Span{Kind: "lines", StartLine: 12, EndLine: 14},
+		SearchQuery: model.SearchQuery{K: 1},
+	})
+	if err != nil {
+		t.Fatalf("FindSimilar failed: %v", err)
+	}
+	if len(result.SeedChunkIDs) != 1 || result.SeedChunkIDs[0] != 2 {
+		t.Fatalf("expected only the overlapping chunk as seed, got %v", result.SeedChunkIDs)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].ChunkID != 1 {
+		t.Fatalf("expected the file's other chunk as nearest hit, got %+v", result.Hits)
+	}
+}
+
+func TestFindSimilar_AppliesFilters(t *testing.T) {
+	st := &fakeFilterStore{matching: []uint64{4}}
+	svc, _ := newSimilarTestService(t, st)
+
+	result, err := svc.FindSimilar(context.Background(), model.SimilarQuery{
+		ChunkID: 1,
+		SearchQuery: model.SearchQuery{
+			K:              3,
+			MetadataFilter: model.MetadataFilter{MaxSizeBytes: 10},
+		},
+	})
+	if err != nil {
+		t.Fatalf("FindSimilar failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].ChunkID != 4 {
+		t.Fatalf("expected only the filtered chunk, got %+v", result.Hits)
+	}
+
+	result, err = svc.FindSimilar(context.Background(), model.SimilarQuery{
+		ChunkID:     1,
+		SearchQuery: model.SearchQuery{K: 3, PathPrefix: "docs/c"},
+	})
+	if err != nil {
+		t.Fatalf("FindSimilar failed: %v", err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].RelPath != "docs/c.md" {
+		t.Fatalf("expected path prefix applied, got %+v", result.Hits)
+	}
+}
+
+func TestFindSimilar_UnknownSeedReturnsNotFound(t *testing.T) {
+	svc, _ := newSimilarTestService(t, nil)
+
+	_, err := svc.FindSimilar(context.Background(), model.SimilarQuery{RelPath: "docs/missing.md"})
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unindexed file, got %v", err)
+	}
+	_, err = svc.FindSimilar(context.Background(), model.SimilarQuery{ChunkID: 99})
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unindexed chunk, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// fakeSymbolStore answers symbol queries from a fixed table, honouring the
+// exact name and container filters, and lists its files for reference scans.
+type fakeSymbolStore struct {
+	fakeGrepStore
+	symbols []model.Symbol
+	queries []model.SymbolQuery
+}
+
+func (f *fakeSymbolStore) ReplaceSymbols(context.Context, int64, []model.Symbol) error {
+	return nil
+}
+
+func (f *fakeSymbolStore) SearchSymbols(_ context.Context, query model.SymbolQuery) ([]model.Symbol, error) {
+	f.queries = append(f.queries, query)
+	var out []model.Symbol
+	for _, sym := range f.symbols {
+		if query.Exact && sym.Name != query.Name {
+			continue
+		}
+		if query.Container != "" && sym.Container != query.Container {
+			continue
+		}
+		out = append(out, sym)
+	}
+	return out, nil
+}
+
+func newSymbolTestService(t *testing.T, files map[string]string, symbols []model.Symbol) (*retrieval.Service, *fakeSymbolStore) {
+	t.Helper()
+	root := t.TempDir()
+	st := &fakeSymbolStore{symbols: symbols}
+	for relPath, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		st.docs = append(st.docs, model.Document{RelPath: relPath, SourceType: "filesystem"})
+	}
+	svc := retrieval.NewService(st, nil, nil, nil)
+	svc.SetRootDir(root)
+	return svc, st
+}
+
+func lineSpan(start, end int) model.Span {
+	return model.Span{Kind: "lines", StartLine: start, EndLine: end}
+}
+
+func TestSymbols_RequiresSymbolStore(t *testing.T) {
+	svc := retrieval.NewService(&fakeListOnlyStore{}, nil, nil, nil)
+	if _, err := svc.Symbols(context.Background(), model.SymbolQuery{Name: "x"}); !errors.Is(err, model.ErrNotImplemented) {
+		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	}
+}
+
+func TestSymbols_DropsExcludedPaths(t *testing.T) {
+	svc, _ := newSymbolTestService(t, nil, []model.Symbol{
+		{Name: "Run", RelPath: "src/run.go"},
+		{Name: "Run", RelPath: "private/run.go"},
+	})
+	svc.SetPathExcludes([]string{"private/**"})
+
+	got, err := svc.Symbols(context.Background(), model.SymbolQuery{Name: "Run"})
+	if err != nil {
+		t.Fatalf("Symbols failed: %v", err)
+	}
+	if len(got) != 1 || got[0].RelPath != "src/run.go" {
+		t.Fatalf("expected only the non-excluded symbol, got %+v", got)
+	}
+}
+
+func TestDefinitions_ResolvesQualifiedNames(t *testing.T) {
+	svc, st := newSymbolTestService(t, nil, []model.Symbol{
+		{Name: "Grep", Container: "Service", RelPath: "a.go", Span: lineSpan(10, 20)},
+		{Name: "Grep", Container: "Other", RelPath: "b.go", Span: lineSpan(3, 4)},
+		{Name: "os.path", RelPath: "c.py", Span: lineSpan(1, 1)},
+	})
+
+	got, err := svc.Definitions(context.Background(), model.SymbolQuery{Name: "Service.Grep"})
+	if err != nil {
+		t.Fatalf("Definitions failed: %v", err)
+	}
+	if len(got) != 1 || got[0].RelPath != "a.go" {
+		t.Fatalf("expected Service.Grep only, got %+v", got)
+	}
+	if q := st.queries[0]; !q.Exact || q.Name != "Grep" || q.Container != "Service" {
+		t.Fatalf("expected exact container lookup, got %+v", q)
+	}
+
+	// no container called "os" exists, so the dotted name is tried whole.
+	got, err = svc.Definitions(context.Background(), model.SymbolQuery{Name: "os.path"})
+	if err != nil {
+		t.Fatalf("Definitions failed: %v", err)
+	}
+	if len(got) != 1 || got[0].RelPath != "c.py" {
+		t.Fatalf("expected fallback to whole name, got %+v", got)
+	}
+}
+
+func TestReferences_MatchesWholeIdentifierAndSkipsDefinitions(t *testing.T) {
+	svc, _ := newSymbolTestService(t, map[string]string{
+		"src/main.go": "package main\n\nfunc Run() {}\n\nfunc main() {\n\tRun()\n\tRunner()\n}\n",
+		"lib/util.py": "from main import Run\n",
+	}, []model.Symbol{
+		{Name: "Run", Kind: model.SymbolKindFunction, RelPath: "src/main.go", Span: lineSpan(3, 3)},
+	})
+
+	result, err := svc.References(context.Background(), model.ReferenceQuery{Name: "Run"}, nil)
+	if err != nil {
+		t.Fatalf("References failed: %v", err)
+	}
+	if len(result.Definitions) != 1 || result.Definitions[0].Span.StartLine != 3 {
+		t.Fatalf("expected the declaration as definition, got %+v", result.Definitions)
+	}
+	found := map[string]int{}
+	for _, match := range result.Matches {
+		found[match.RelPath] = match.Span.StartLine
+	}
+	if len(result.Matches) != 2 || found["src/main.go"] != 6 || found["lib/util.py"] != 1 {
+		t.Fatalf("expected Run() call and import, got %+v", result.Matches)
+	}
+
+	withDefs, err := svc.References(context.Background(), model.ReferenceQuery{Name: "Run", IncludeDefinitions: true, Language: "go"}, nil)
+	if err != nil {
+		t.Fatalf("References failed: %v", err)
+	}
+	if len(withDefs.Matches) != 2 || withDefs.Matches[0].Span.StartLine != 3 || withDefs.Matches[1].Span.StartLine != 6 {
+		t.Fatalf("expected go declaration and call, got %+v", withDefs.Matches)
+	}
+}
+
+func TestReferences_WorksWithoutSymbolStore(t *testing.T) {
+	svc, _ := newGrepTestService(t, map[string]string{"src/main.go": "x := Run()\n"})
+
+	result, err := svc.References(context.Background(), model.ReferenceQuery{Name: "pkg.Run"}, nil)
+	if err != nil {
+		t.Fatalf("References failed: %v", err)
+	}
+	if len(result.Definitions) != 0 || len(result.Matches) != 1 {
+		t.Fatalf("expected one textual match and no definitions, got %+v", result)
+	}
+}
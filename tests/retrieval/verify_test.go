@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/retrieval"
+)
+
+// sequenceGenerator returns its replies in order, repeating the last one.
+type sequenceGenerator struct {
+	replies []string
+	prompts []string
+}
+
+func (g *sequenceGenerator) Generate(_ context.Context, prompt string) (string, error) {
+	g.prompts = append(g.prompts, prompt)
+	n := len(g.prompts) - 1
+	if n >= len(g.replies) {
+		n = len(g.replies) - 1
+	}
+	return g.replies[n], nil
+}
+
+const verifyTestAnswer = "The deploy key rotates every 30 days [docs/guide.md]. The moon is made of green cheese [docs/guide.md]."
+
+func newVerifyTestService(t *testing.T, gen model.Generator) *retrieval.Service {
+	t.Helper()
+	svc := newContextTestService(t, nil, gen, 1)
+	svc.SetChunkMetadata(1, model.SearchHit{
+		RelPath: "docs/guide.md",
+		Snippet: "The deploy key rotates automatically every 30 days.",
+		Span:    model.Span{Kind: "lines", StartLine: 1, EndLine: 3},
+	})
+	return svc
+}
+
+func TestAsk_VerifiesSentencesAgainstCitedExcerpts(t *testing.T) {
+	svc := newVerifyTestService(t, &sequenceGenerator{replies: []string{verifyTestAnswer}})
+
+	result, err := svc.Ask(context.Background(), "how often does the deploy key rotate?", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	v := result.Verification
+	if v == nil {
+		t.Fatal("expected verification for a generated answer")
+	}
+	if len(v.Sentences) != 2 {
+		t.Fatalf("expected two sentences, got %+v", v.Sentences)
+	}
+	first, second := v.Sentences[0], v.Sentences[1]
+	if first.Status != model.SupportSupported || second.Status != model.SupportUnsupported {
+		t.Fatalf("unexpected statuses: %q, %q", first.Status, second.Status)
+	}
+	if len(first.Sources) != 1 || first.Sources[0] != "docs/guide.md" || first.Method != "lexical" {
+		t.Fatalf("unexpected first sentence: %+v", first)
+	}
+	if result.Answer[second.Start:second.End] != second.Text || !strings.HasPrefix(second.Text, "The moon") {
+		t.Fatalf("offsets do not match sentence text: %+v", second)
+	}
+	if v.GroundingScore != 0.5 || v.Unsupported() != 1 {
+		t.Fatalf("expected grounding 0.5 with one unsupported sentence, got %v / %d", v.GroundingScore, v.Unsupported())
+	}
+}
+
+func TestAsk_CitationOnlyFragmentAttachesToPreviousSentence(t *testing.T) {
+	svc := newVerifyTestService(t, &sequenceGenerator{replies: []string{"The deploy key rotates every 30 days. [docs/guide.md:L1-L3]\n\nSources: [docs/guide.md]"}})
+
+	result, err := svc.Ask(context.Background(), "rotation?", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	v := result.Verification
+	if v == nil || len(v.Sentences) != 1 {
+		t.Fatalf("expected one checked sentence, got %+v", v)
+	}
+	if got := v.Sentences[0].Sources; len(got) != 1 || got[0] != "docs/guide.md" {
+		t.Fatalf("expected span-suffixed citation resolved to the path, got %v", got)
+	}
+	if v.GroundingScore != 1 {
+		t.Fatalf("expected full grounding, got %v", v.GroundingScore)
+	}
+}
+
+func TestAsk_GeneratorVerificationOverridesLexicalVerdicts(t *testing.T) {
+	gen := &sequenceGenerator{replies: []string{verifyTestAnswer, "```json\n[\"partial\"]\n```"}}
+	svc := newVerifyTestService(t, gen)
+	svc.SetGeneratorVerification(true)
+
+	result, err := svc.Ask(context.Background(), "rotation?", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if len(gen.prompts) != 2 {
+		t.Fatalf("expected answer and verification generations, got %d", len(gen.prompts))
+	}
+	if !strings.Contains(gen.prompts[1], "[1] The moon is made of green cheese") || strings.Contains(gen.prompts[1], "[2]") {
+		t.Fatalf("expected only the unsupported sentence sent for checking, got %q", gen.prompts[1])
+	}
+	second := result.Verification.Sentences[1]
+	if second.Status != model.SupportPartial || second.Method != "generator" {
+		t.Fatalf("expected generator verdict, got %+v", second)
+	}
+	if result.Verification.GroundingScore != 0.75 {
+		t.Fatalf("expected grounding 0.75, got %v", result.Verification.GroundingScore)
+	}
+}
+
+func TestAsk_GeneratorVerificationFailureKeepsLexicalVerdicts(t *testing.T) {
+	svc := newVerifyTestService(t, &sequenceGenerator{replies: []string{verifyTestAnswer, "no idea"}})
+	svc.SetGeneratorVerification(true)
+
+	result, err := svc.Ask(context.Background(), "rotation?", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if second := result.Verification.Sentences[1]; second.Status != model.SupportUnsupported || second.Method != "lexical" {
+		t.Fatalf("expected lexical verdict kept, got %+v", second)
+	}
+}
+
+func TestAsk_FallbackAnswerIsNotVerified(t *testing.T) {
+	svc := newVerifyTestService(t, nil)
+
+	result, err := svc.Ask(context.Background(), "rotation?", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if result.Verification != nil {
+		t.Fatalf("expected no verification without a generator, got %+v", result.Verification)
+	}
+}
+
+func TestAnswerVerificationApply_FlagAndStrip(t *testing.T) {
+	svc := newVerifyTestService(t, &sequenceGenerator{replies: []string{verifyTestAnswer}})
+	result, err := svc.Ask(context.Background(), "rotation?", model.SearchQuery{K: 1})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	v := *result.Verification
+
+	flagged, flaggedV := v.Apply(result.Answer, model.StrictFlag)
+	if !strings.HasSuffix(flagged, "cheese [docs/guide.md]. "+model.UnsupportedMarker) {
+		t.Fatalf("expected unsupported sentence flagged, got %q", flagged)
+	}
+	for _, sentence := range flaggedV.Sentences {
+		if flagged[sentence.Start:sentence.End] != sentence.Text {
+			t.Fatalf("flagged offsets drifted for %+v in %q", sentence, flagged)
+		}
+	}
+
+	stripped, strippedV := v.Apply(result.Answer, model.StrictStrip)
+	if stripped != "The deploy key rotates every 30 days [docs/guide.md]." {
+		t.Fatalf("expected unsupported sentence stripped, got %q", stripped)
+	}
+	if !strippedV.Sentences[1].Removed || strippedV.Strict != model.StrictStrip {
+		t.Fatalf("expected removed sentence reported, got %+v", strippedV)
+	}
+	if strippedV.GroundingScore != v.GroundingScore {
+		t.Fatal("strict mode must not change the grounding score")
+	}
+
+	unchanged, _ := v.Apply(result.Answer, model.StrictOff)
+	if unchanged != result.Answer {
+		t.Fatalf("expected off to leave the answer unchanged, got %q", unchanged)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/sessions"
+)
+
+func openSessionStore(t *testing.T, path string) *sessions.Store {
+	t.Helper()
+	st, err := sessions.Open(path)
+	if err != nil {
+		t.Fatalf("open session store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+func TestStore_TouchAppliesInactivityAndMaxLifetime(t *testing.T) {
+	ctx := context.Background()
+	st := openSessionStore(t, filepath.Join(t.TempDir(), sessions.FileName))
+	start := time.UnixMilli(1_700_000_000_000)
+
+	for _, id := range []string{"idle", "old"} {
+		if err := st.Create(ctx, sessions.Session{ID: id, Created: start, LastSeen: start, ProtocolVersion: "2025-11-25", ClientName: "agent", ClientVersion: "1.0"}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+
+	got, reason, err := st.Touch(ctx, "old", start.Add(40*time.Minute), time.Hour, 2*time.Hour)
+	if err != nil || reason != "" {
+		t.Fatalf("touch active session: reason=%q err=%v", reason, err)
+	}
+	if got.ProtocolVersion != "2025-11-25" || got.ClientName != "agent" || got.ClientVersion != "1.0" || !got.Created.Equal(start) {
+		t.Fatalf("unexpected stored session: %+v", got)
+	}
+
+	_, reason, err = st.Touch(ctx, "idle", start.Add(90*time.Minute), time.Hour, 2*time.Hour)
+	if !errors.Is(err, sessions.ErrNotFound) || reason != sessions.ReasonInactivity {
+		t.Fatalf("idle session: reason=%q err=%v", reason, err)
+	}
+	// the 40m touch keeps "old" alive past the inactivity limit...
+	if _, reason, err = st.Touch(ctx, "old", start.Add(90*time.Minute), time.Hour, 2*time.Hour); err != nil {
+		t.Fatalf("touch refreshed session: reason=%q err=%v", reason, err)
+	}
+	// ...but not past its max lifetime.
+	_, reason, err = st.Touch(ctx, "old", start.Add(121*time.Minute), time.Hour, 2*time.Hour)
+	if !errors.Is(err, sessions.ErrNotFound) || reason != sessions.ReasonMaxLifetime {
+		t.Fatalf("old session: reason=%q err=%v", reason, err)
+	}
+
+	// expired sessions are deleted, so a second lookup has no reason.
+	_, reason, err = st.Touch(ctx, "old", start.Add(122*time.Minute), time.Hour, 2*time.Hour)
+	if !errors.Is(err, sessions.ErrNotFound) || reason != "" {
+		t.Fatalf("deleted session: reason=%q err=%v", reason, err)
+	}
+}
+
+func TestStore_SharedFileSweepsAndCounts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), sessions.FileName)
+	first := openSessionStore(t, path)
+	second := openSessionStore(t, path)
+	start := time.UnixMilli(1_700_000_000_000)
+
+	if err := first.Create(ctx, sessions.Session{ID: "a", Created: start, LastSeen: start}); err != nil {
+		t.Fatalf("create a: %v", err)
+	}
+	if _, _, err := second.Touch(ctx, "a", start.Add(30*time.Minute), time.Hour, 0); err != nil {
+		t.Fatalf("second store should see the first store's session: %v", err)
+	}
+	later := start.Add(50 * time.Minute)
+	if err := second.Create(ctx, sessions.Session{ID: "b", Created: later, LastSeen: later}); err != nil {
+		t.Fatalf("create b: %v", err)
+	}
+	n, err := first.CountActive(ctx, later, time.Hour, 0)
+	if err != nil || n != 2 {
+		t.Fatalf("CountActive=%d err=%v want 2", n, err)
+	}
+
+	// "a" was last touched at +30m, so at +95m only it is past the hour.
+	removed, err := first.DeleteExpired(ctx, start.Add(95*time.Minute), time.Hour, 0)
+	if err != nil || removed != 1 {
+		t.Fatalf("DeleteExpired=%d err=%v want 1", removed, err)
+	}
+	if _, _, err := second.Touch(ctx, "a", start.Add(95*time.Minute), time.Hour, 0); !errors.Is(err, sessions.ErrNotFound) {
+		t.Fatalf("swept session still found: %v", err)
+	}
+	if err := second.Delete(ctx, "b"); err != nil {
+		t.Fatalf("delete b: %v", err)
+	}
+	n, err = first.CountActive(ctx, start.Add(95*time.Minute), time.Hour, 0)
+	if err != nil || n != 0 {
+		t.Fatalf("CountActive=%d err=%v want 0", n, err)
+	}
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dir2mcp/internal/index"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/snapshot"
+	"dir2mcp/internal/store"
+)
+
+// seedStateDir builds a small but complete state directory: an initialized
+// metadata store with one document, a text index and an OCR cache entry.
+func seedStateDir(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+	stateDir := filepath.Join(t.TempDir(), ".dir2mcp")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state dir: %v", err)
+	}
+
+	st := store.NewSQLiteStore(filepath.Join(stateDir, "meta.sqlite"))
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := st.UpsertDocument(ctx, model.Document{RelPath: "docs/a.md", DocType: "md", ContentHash: "abc", Status: "ok"}); err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	ix := index.NewHNSWIndex(filepath.Join(stateDir, "vectors_text.hnsw"))
+	ix.SetModel("mistral-embed")
+	if err := ix.Add(1, []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := ix.Save(""); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	ocrDir := filepath.Join(stateDir, "cache", "ocr")
+	if err := os.MkdirAll(ocrDir, 0o755); err != nil {
+		t.Fatalf("mkdir ocr cache: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(ocrDir, "deadbeef.md"), []byte("# scanned"), 0o644); err != nil {
+		t.Fatalf("write ocr cache: %v", err)
+	}
+	return stateDir
+}
+
+func TestSnapshot_CreateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	stateDir := seedStateDir(t)
+	archive := filepath.Join(t.TempDir(), "snap.tar.gz")
+
+	manifest, err := snapshot.Create(ctx, stateDir, archive)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if manifest.Documents != 1 || manifest.RootFingerprint == "" {
+		t.Fatalf("unexpected manifest summary: %+v", manifest)
+	}
+	if manifest.SchemaVersion != store.CurrentSchemaVersion {
+		t.Fatalf("schema_version=%d want=%d", manifest.SchemaVersion, store.CurrentSchemaVersion)
+	}
+	if got := manifest.Models["text"]; got.Model != "mistral-embed" || got.Dim != 3 {
+		t.Fatalf("unexpected text model in manifest: %+v", got)
+	}
+	paths := map[string]bool{}
+	for _, f := range manifest.Files {
+		paths[f.Path] = true
+	}
+	for _, want := range []string{"meta.sqlite", "vectors_text.hnsw", "cache/ocr/deadbeef.md"} {
+		if !paths[want] {
+			t.Fatalf("expected %s in manifest, got %+v", want, manifest.Files)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), ".dir2mcp")
+	restored, err := snapshot.Restore(ctx, archive, target, false)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.RootFingerprint != manifest.RootFingerprint {
+		t.Fatalf("restored fingerprint mismatch: %s vs %s", restored.RootFingerprint, manifest.RootFingerprint)
+	}
+
+	st := store.NewSQLiteStore(filepath.Join(target, "meta.sqlite"))
+	t.Cleanup(func() { _ = st.Close() })
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init on restored store failed: %v", err)
+	}
+	if _, err := st.GetDocumentByPath(ctx, "docs/a.md"); err != nil {
+		t.Fatalf("restored document missing: %v", err)
+	}
+	ix := index.NewHNSWIndex(filepath.Join(target, "vectors_text.hnsw"))
+	if err := ix.Load(""); err != nil {
+		t.Fatalf("Load restored index failed: %v", err)
+	}
+	if ix.Len() != 1 || ix.EmbeddingModel() != "mistral-embed" {
+		t.Fatalf("unexpected restored index: len=%d model=%q", ix.Len(), ix.EmbeddingModel())
+	}
+	if _, err := os.Stat(filepath.Join(target, "cache", "ocr", "deadbeef.md")); err != nil {
+		t.Fatalf("restored ocr cache missing: %v", err)
+	}
+
+	if _, err := snapshot.Restore(ctx, archive, target, false); !errors.Is(err, snapshot.ErrStateExists) {
+		t.Fatalf("expected ErrStateExists without --force, got %v", err)
+	}
+	if _, err := snapshot.Restore(ctx, archive, target, true); err != nil {
+		t.Fatalf("Restore with force failed: %v", err)
+	}
+}
+
+func TestSnapshot_RestoreRejectsTamperedArchive(t *testing.T) {
+	ctx := context.Background()
+	stateDir := seedStateDir(t)
+	archive := filepath.Join(t.TempDir(), "snap.tar.gz")
+	if _, err := snapshot.Create(ctx, stateDir, archive); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	tampered := filepath.Join(t.TempDir(), "tampered.tar.gz")
+	rewriteArchive(t, archive, tampered, func(name string, body []byte) []byte {
+		if name == "cache/ocr/deadbeef.md" {
+			return []byte("# forged")
+		}
+		return body
+	})
+
+	target := filepath.Join(t.TempDir(), ".dir2mcp")
+	if _, err := snapshot.Restore(ctx, tampered, target, false); !errors.Is(err, snapshot.ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "meta.sqlite")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("failed restore must not install files, stat err=%v", err)
+	}
+}
+
+// rewriteArchive copies src to dst, passing every member through edit.
+func rewriteArchive(t *testing.T, src, dst string, edit func(name string, body []byte) []byte) {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer func() { _ = in.Close() }()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read archive: %v", err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read member: %v", err)
+		}
+		body = edit(hdr.Name, body)
+		hdr.Size = int64(len(body))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatalf("write member: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+}
+
+func TestSnapshot_MarksChunksMissingFromTheIndexPending(t *testing.T) {
+	ctx := context.Background()
+	stateDir := seedStateDir(t)
+
+	// chunk 2 was embedded after the text index was last saved.
+	st := store.NewSQLiteStore(filepath.Join(stateDir, "meta.sqlite"))
+	for _, label := range []uint64{1, 2} {
+		task := model.NewChunkTask(label, "alpha", "text", model.ChunkMetadata{RelPath: "docs/a.md", DocType: "md"})
+		if err := st.UpsertChunkTask(ctx, task); err != nil {
+			t.Fatalf("UpsertChunkTask failed: %v", err)
+		}
+	}
+	if err := st.MarkEmbedded(ctx, []uint64{1, 2}); err != nil {
+		t.Fatalf("MarkEmbedded failed: %v", err)
+	}
+	_ = st.Close()
+
+	archive := filepath.Join(t.TempDir(), "snap.tar.gz")
+	manifest, err := snapshot.Create(ctx, stateDir, archive)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if manifest.PendingChunks != 1 {
+		t.Fatalf("pending_chunks=%d want 1", manifest.PendingChunks)
+	}
+
+	target := filepath.Join(t.TempDir(), ".dir2mcp")
+	if _, err := snapshot.Restore(ctx, archive, target, false); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored := store.NewSQLiteStore(filepath.Join(target, "meta.sqlite"))
+	t.Cleanup(func() { _ = restored.Close() })
+	pending, err := restored.NextPending(ctx, 10, "text")
+	if err != nil {
+		t.Fatalf("NextPending failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Label != 2 {
+		t.Fatalf("expected chunk 2 to be pending again, got %+v", pending)
+	}
+
+	// the source state dir is left alone.
+	counts, err := st.PendingChunkCounts(ctx)
+	if err != nil {
+		t.Fatalf("PendingChunkCounts failed: %v", err)
+	}
+	if counts["text"] != 0 {
+		t.Fatalf("source store was modified: %v", counts)
+	}
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/store"
+)
+
+// seedFilterCorpus stores one chunk per document and returns the chunk ID of
+// each document keyed by rel_path.
+func seedFilterCorpus(t *testing.T, st *store.SQLiteStore) map[string]uint64 {
+	t.Helper()
+	ctx := context.Background()
+	docs := []struct {
+		doc     model.Document
+		repType string
+		text    string
+	}{
+		{model.Document{RelPath: "src/auth.go", DocType: "code", SizeBytes: 2_000, MTimeUnix: 1_700_000_000, Status: "ok"}, "raw_text", "token validation"},
+		{model.Document{RelPath: "web/app.ts", DocType: "code", SizeBytes: 50_000, MTimeUnix: 1_710_000_000, Status: "ok"}, "raw_text", "token refresh"},
+		{model.Document{RelPath: "scans/contract.pdf", DocType: "pdf", SizeBytes: 900_000, MTimeUnix: 1_720_000_000, Status: "ok"}, "ocr_markdown", "token clause"},
+		{model.Document{RelPath: "bundle.zip::notes.md", DocType: "md", SourceType: "archive_member", SizeBytes: 300, MTimeUnix: 1_720_000_000, Status: "ok"}, "raw_text", "token notes"},
+	}
+	ids := make(map[string]uint64, len(docs))
+	for i, entry := range docs {
+		entry.doc.ContentHash = fmt.Sprintf("h%d", i)
+		if err := st.UpsertDocument(ctx, entry.doc); err != nil {
+			t.Fatalf("UpsertDocument(%s) failed: %v", entry.doc.RelPath, err)
+		}
+		doc, err := st.GetDocumentByPath(ctx, entry.doc.RelPath)
+		if err != nil {
+			t.Fatalf("GetDocumentByPath failed: %v", err)
+		}
+		repID, err := st.UpsertRepresentation(ctx, model.Representation{DocID: doc.DocID, RepType: entry.repType, RepHash: fmt.Sprintf("r%d", i)})
+		if err != nil {
+			t.Fatalf("UpsertRepresentation failed: %v", err)
+		}
+		id, err := st.InsertChunkWithSpans(ctx, model.Chunk{RepID: repID, Text: entry.text, TextHash: fmt.Sprintf("c%d", i), IndexKind: "text"}, []model.Span{{Kind: "lines", StartLine: 1, EndLine: 1}})
+		if err != nil {
+			t.Fatalf("InsertChunkWithSpans failed: %v", err)
+		}
+		ids[entry.doc.RelPath] = uint64(id)
+	}
+	return ids
+}
+
+func TestSQLiteStore_ChunkIDsMatching(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	ids := seedFilterCorpus(t, st)
+
+	cases := []struct {
+		name   string
+		filter model.MetadataFilter
+		want   []string
+	}{
+		{"modified range", model.MetadataFilter{ModifiedAfter: 1_705_000_000, ModifiedBefore: 1_720_000_000}, []string{"web/app.ts"}},
+		{"size range", model.MetadataFilter{MinSizeBytes: 1_000, MaxSizeBytes: 100_000}, []string{"src/auth.go", "web/app.ts"}},
+		{"source type", model.MetadataFilter{SourceTypes: []string{"archive_member"}}, []string{"bundle.zip::notes.md"}},
+		{"rep type", model.MetadataFilter{RepTypes: []string{"ocr_markdown"}}, []string{"scans/contract.pdf"}},
+		{"language alias", model.MetadataFilter{Languages: []string{"ts"}}, []string{"web/app.ts"}},
+		{"status", model.MetadataFilter{Statuses: []string{"error"}}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := st.ChunkIDsMatching(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("ChunkIDsMatching failed: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got chunk IDs %v", tc.want, got)
+			}
+			for i, relPath := range tc.want {
+				if got[i] != ids[relPath] {
+					t.Fatalf("expected %v, got chunk IDs %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteStore_SearchChunksLexicalFiltered(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	seedFilterCorpus(t, st)
+
+	// the limit applies after filtering, so a selective filter still finds
+	// its only match even though other chunks sort first.
+	chunks, err := st.SearchChunksLexicalFiltered(ctx, []string{"token"}, 1, model.SearchQuery{MetadataFilter: model.MetadataFilter{RepTypes: []string{"ocr_markdown"}}})
+	if err != nil {
+		t.Fatalf("SearchChunksLexicalFiltered failed: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].Metadata.RelPath != "scans/contract.pdf" {
+		t.Fatalf("expected the OCR chunk, got %+v", chunks)
+	}
+}
+
+func TestSQLiteStore_ListDocumentsWithRepresentation(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	seedFilterCorpus(t, st)
+
+	cases := []struct {
+		name   string
+		prefix string
+		glob   string
+		want   []string
+	}{
+		{"all raw text", "", "", []string{"bundle.zip::notes.md", "src/auth.go", "web/app.ts"}},
+		{"prefix", "src/", "", []string{"src/auth.go"}},
+		{"glob", "", "*.ts", []string{"web/app.ts"}},
+		{"no match", "scans/", "", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			docs, err := st.ListDocumentsWithRepresentation(ctx, "raw_text", tc.prefix, tc.glob)
+			if err != nil {
+				t.Fatalf("ListDocumentsWithRepresentation failed: %v", err)
+			}
+			if len(docs) != len(tc.want) {
+				t.Fatalf("expected %v, got %+v", tc.want, docs)
+			}
+			for i, doc := range docs {
+				if doc.RelPath != tc.want[i] {
+					t.Fatalf("expected %v, got %+v", tc.want, docs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/store"
+)
+
+// legacySchema mirrors the layout written by builds that predate versioned
+// migrations: no schema_migrations table, settings.schema_version=1 and a
+// documents table without source_type.
+const legacySchema = `
+CREATE TABLE documents (
+  doc_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  rel_path TEXT NOT NULL UNIQUE,
+  doc_type TEXT NOT NULL,
+  size_bytes INTEGER NOT NULL DEFAULT 0,
+  mtime_unix INTEGER NOT NULL DEFAULT 0,
+  content_hash TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'ok',
+  deleted INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE settings (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+INSERT INTO settings(key, value) VALUES('schema_version', '1');
+INSERT INTO documents(rel_path, doc_type) VALUES('docs/legacy.md', 'md');
+`
+
+func writeRawDatabase(t *testing.T, path, ddl string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open raw db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec(ddl); err != nil {
+		t.Fatalf("exec raw ddl: %v", err)
+	}
+}
+
+func TestSQLiteStoreMigrations_FreshDatabaseIsCurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "meta.sqlite")
+	st := store.NewSQLiteStore(path)
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	status, err := st.InspectSchema(ctx)
+	if err != nil {
+		t.Fatalf("InspectSchema failed: %v", err)
+	}
+	if status.Version != store.CurrentSchemaVersion || status.Supported != store.CurrentSchemaVersion || len(status.Pending) != 0 {
+		t.Fatalf("unexpected schema status: %+v", status)
+	}
+	value, err := st.GetSetting(ctx, "schema_version")
+	if err != nil {
+		t.Fatalf("GetSetting(schema_version) failed: %v", err)
+	}
+	if value != strconv.Itoa(store.CurrentSchemaVersion) {
+		t.Fatalf("schema_version setting=%q want=%d", value, store.CurrentSchemaVersion)
+	}
+}
+
+func TestSQLiteStoreMigrations_UpgradesLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "meta.sqlite")
+	writeRawDatabase(t, path, legacySchema)
+
+	before, err := store.InspectSchema(ctx, path)
+	if err != nil {
+		t.Fatalf("InspectSchema failed: %v", err)
+	}
+	if before.Version != 1 || len(before.Pending) != store.CurrentSchemaVersion-1 || before.Pending[0].Name != "documents_source_type" {
+		t.Fatalf("unexpected pending migrations for legacy db: %+v", before)
+	}
+
+	st := store.NewSQLiteStore(path)
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	if err := st.UpsertDocument(ctx, model.Document{RelPath: "docs/new.md", DocType: "md", SourceType: "filesystem"}); err != nil {
+		t.Fatalf("UpsertDocument after migration failed: %v", err)
+	}
+	doc, err := st.GetDocumentByPath(ctx, "docs/legacy.md")
+	if err != nil {
+		t.Fatalf("GetDocumentByPath(legacy) failed: %v", err)
+	}
+	if doc.SourceType != "filesystem" {
+		t.Fatalf("expected backfilled source_type, got %q", doc.SourceType)
+	}
+
+	after, err := store.InspectSchema(ctx, path)
+	if err != nil {
+		t.Fatalf("InspectSchema after Init failed: %v", err)
+	}
+	if after.Version != store.CurrentSchemaVersion || len(after.Pending) != 0 {
+		t.Fatalf("unexpected schema status after Init: %+v", after)
+	}
+}
+
+func TestSQLiteStoreMigrations_RefusesNewerDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "meta.sqlite")
+	st := store.NewSQLiteStore(path)
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	writeRawDatabase(t, path, `INSERT INTO schema_migrations(version, name, applied_unix) VALUES(999, 'from_the_future', 0)`)
+
+	reopened := store.NewSQLiteStore(path)
+	t.Cleanup(func() { _ = reopened.Close() })
+	if err := reopened.Init(ctx); !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+	if _, err := store.InspectSchema(ctx, path); !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Fatalf("expected InspectSchema to report ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestSQLiteStoreMigrations_ChunksFTSIndexesExistingChunks(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "meta.sqlite")
+	st := store.NewSQLiteStore(path)
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	task := model.NewChunkTask(7, "rotate the signing key", "text", model.ChunkMetadata{RelPath: "docs/keys.md", DocType: "md"})
+	if err := st.UpsertChunkTask(ctx, task); err != nil {
+		t.Fatalf("UpsertChunkTask failed: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// roll the database back to version 3, before chunks had a full-text
+	// index.
+	writeRawDatabase(t, path, `
+DROP TRIGGER chunks_fts_insert;
+DROP TRIGGER chunks_fts_delete;
+DROP TRIGGER chunks_fts_update;
+DROP TABLE chunks_fts;
+DELETE FROM schema_migrations WHERE version = 4;
+UPDATE settings SET value = '3' WHERE key = 'schema_version';`)
+
+	reopened := store.NewSQLiteStore(path)
+	if err := reopened.Init(ctx); err != nil {
+		t.Fatalf("Init after rollback failed: %v", err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+	chunks, err := reopened.SearchChunksLexical(ctx, []string{"signing"}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksLexical failed: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].Label != 7 {
+		t.Fatalf("expected the existing chunk to be indexed, got %+v", chunks)
+	}
+
+	// later edits keep the index in sync.
+	task.Text = "rotate the encryption key"
+	if err := reopened.UpsertChunkTask(ctx, task); err != nil {
+		t.Fatalf("UpsertChunkTask(update) failed: %v", err)
+	}
+	for term, want := range map[string]int{"signing": 0, "encryption": 1} {
+		chunks, err := reopened.SearchChunksLexical(ctx, []string{term}, 10)
+		if err != nil {
+			t.Fatalf("SearchChunksLexical(%s) failed: %v", term, err)
+		}
+		if len(chunks) != want {
+			t.Fatalf("term %q: got %d chunks want %d", term, len(chunks), want)
+		}
+	}
+}
@@ -77,6 +77,119 @@ func TestSQLiteStore_PendingChunkLifecycle(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_ResetEmbeddingStatus(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	defer func() { _ = st.Close() }()
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	for _, task := range []model.ChunkTask{
+		model.NewChunkTask(1, "alpha", "text", model.ChunkMetadata{ChunkID: 1, RelPath: "docs/a.md", DocType: "md", RepType: "raw_text"}),
+		model.NewChunkTask(2, "beta", "text", model.ChunkMetadata{ChunkID: 2, RelPath: "docs/b.md", DocType: "md", RepType: "raw_text"}),
+		model.NewChunkTask(3, "func main() {}", "code", model.ChunkMetadata{ChunkID: 3, RelPath: "main.go", DocType: "code", RepType: "raw_text"}),
+	} {
+		if err := st.UpsertChunkTask(ctx, task); err != nil {
+			t.Fatalf("UpsertChunkTask failed: %v", err)
+		}
+	}
+	if err := st.MarkEmbedded(ctx, []uint64{1, 3}); err != nil {
+		t.Fatalf("MarkEmbedded failed: %v", err)
+	}
+	if err := st.MarkFailed(ctx, []uint64{2}, "boom"); err != nil {
+		t.Fatalf("MarkFailed failed: %v", err)
+	}
+
+	reset, err := st.ResetEmbeddingStatus(ctx, "text")
+	if err != nil {
+		t.Fatalf("ResetEmbeddingStatus failed: %v", err)
+	}
+	if reset != 2 {
+		t.Fatalf("expected 2 text chunks reset, got %d", reset)
+	}
+	textPending, err := st.NextPending(ctx, 10, "text")
+	if err != nil {
+		t.Fatalf("NextPending(text) failed: %v", err)
+	}
+	if len(textPending) != 2 {
+		t.Fatalf("expected both text chunks pending, got %#v", textPending)
+	}
+	codePending, err := st.NextPending(ctx, 10, "code")
+	if err != nil {
+		t.Fatalf("NextPending(code) failed: %v", err)
+	}
+	if len(codePending) != 0 {
+		t.Fatalf("expected code chunks untouched, got %#v", codePending)
+	}
+}
+
+func TestSQLiteStore_ChunkNeighborhood(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	defer func() { _ = st.Close() }()
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := st.UpsertDocument(ctx, model.Document{RelPath: "docs/guide.md", DocType: "md", SourceType: "file", ContentHash: "h", Status: "ok"}); err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	doc, err := st.GetDocumentByPath(ctx, "docs/guide.md")
+	if err != nil {
+		t.Fatalf("GetDocumentByPath failed: %v", err)
+	}
+	repID, err := st.UpsertRepresentation(ctx, model.Representation{DocID: doc.DocID, RepType: "raw_text", RepHash: "r"})
+	if err != nil {
+		t.Fatalf("UpsertRepresentation failed: %v", err)
+	}
+	ids := make([]uint64, 0, 5)
+	for ordinal := 0; ordinal < 5; ordinal++ {
+		id, err := st.InsertChunkWithSpans(ctx, model.Chunk{
+			RepID:           repID,
+			Ordinal:         ordinal,
+			Text:            fmt.Sprintf("chunk %d", ordinal),
+			TextHash:        fmt.Sprintf("hash-%d", ordinal),
+			IndexKind:       "text",
+			EmbeddingStatus: "ok",
+		}, []model.Span{{Kind: "lines", StartLine: ordinal*10 + 1, EndLine: ordinal*10 + 10}})
+		if err != nil {
+			t.Fatalf("InsertChunkWithSpans failed: %v", err)
+		}
+		ids = append(ids, uint64(id))
+	}
+
+	got, err := st.ChunkNeighborhood(ctx, ids[2], 1)
+	if err != nil {
+		t.Fatalf("ChunkNeighborhood failed: %v", err)
+	}
+	if len(got) != 3 || got[0].ChunkID != ids[1] || got[2].ChunkID != ids[3] {
+		t.Fatalf("expected chunks at ordinals 1-3, got %+v", got)
+	}
+	if got[1].Text != "chunk 2" || got[1].RelPath != "docs/guide.md" || got[1].RepID != repID {
+		t.Fatalf("unexpected anchor chunk: %+v", got[1])
+	}
+	if got[1].Span.Kind != "lines" || got[1].Span.StartLine != 21 || got[1].Span.EndLine != 30 {
+		t.Fatalf("unexpected anchor span: %+v", got[1].Span)
+	}
+
+	// neighbors past the end of the representation are simply absent.
+	edge, err := st.ChunkNeighborhood(ctx, ids[4], 2)
+	if err != nil {
+		t.Fatalf("ChunkNeighborhood failed: %v", err)
+	}
+	if len(edge) != 3 || edge[0].Ordinal != 2 {
+		t.Fatalf("expected ordinals 2-4 at the edge, got %+v", edge)
+	}
+
+	missing, err := st.ChunkNeighborhood(ctx, 9999, 1)
+	if err != nil {
+		t.Fatalf("ChunkNeighborhood failed: %v", err)
+	}
+	if len(missing) != 0 {
+		t.Fatalf("expected no chunks for unknown id, got %+v", missing)
+	}
+}
+
 func TestSQLiteStore_MarkEmbeddingStatus_LabelOverflow(t *testing.T) {
 	ctx := context.Background()
 	dbPath := filepath.Join(t.TempDir(), "meta.sqlite")
@@ -721,6 +834,124 @@ func TestSQLiteStore_CorpusStats_Empty(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_SearchChunksLexical(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	if err := st.UpsertDocument(ctx, model.Document{RelPath: "src/auth.go", DocType: "code", ContentHash: "h1", Status: "ok"}); err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	doc, err := st.GetDocumentByPath(ctx, "src/auth.go")
+	if err != nil {
+		t.Fatalf("GetDocumentByPath failed: %v", err)
+	}
+	repID, err := st.UpsertRepresentation(ctx, model.Representation{DocID: doc.DocID, RepType: "raw_text", RepHash: "rep"})
+	if err != nil {
+		t.Fatalf("UpsertRepresentation failed: %v", err)
+	}
+	texts := []string{"func Validate(token string) error", "unrelated content", "token refresh handling"}
+	for i, text := range texts {
+		if _, err := st.InsertChunkWithSpans(ctx, model.Chunk{
+			RepID:     repID,
+			Ordinal:   i,
+			Text:      text,
+			TextHash:  fmt.Sprintf("chunk-%d", i),
+			IndexKind: "code",
+		}, []model.Span{{Kind: "lines", StartLine: i + 1, EndLine: i + 1}}); err != nil {
+			t.Fatalf("InsertChunkWithSpans(%d) failed: %v", i, err)
+		}
+	}
+
+	chunks, err := st.SearchChunksLexical(ctx, []string{"TOKEN"}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksLexical failed: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 matching chunks, got %d: %+v", len(chunks), chunks)
+	}
+	for _, chunk := range chunks {
+		if chunk.Metadata.RelPath != "src/auth.go" || chunk.Metadata.Span.Kind != "lines" {
+			t.Fatalf("unexpected chunk metadata: %+v", chunk.Metadata)
+		}
+		if !strings.Contains(strings.ToLower(chunk.Text), "token") {
+			t.Fatalf("unexpected chunk text: %q", chunk.Text)
+		}
+	}
+
+	empty, err := st.SearchChunksLexical(ctx, []string{" "}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksLexical(blank) failed: %v", err)
+	}
+	if len(empty) != 0 {
+		t.Fatalf("expected no chunks for blank terms, got %d", len(empty))
+	}
+}
+
+func TestSQLiteStore_SearchChunksLexicalRanksAndFiltersBeforeLimit(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	if err := st.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	// 250 weak matches sort before the strong one by chunk_id.
+	for i := 1; i <= 250; i++ {
+		task := model.NewChunkTask(uint64(i), "a long note that mentions the token once among many other unrelated words", "text", model.ChunkMetadata{RelPath: fmt.Sprintf("notes/n%03d.md", i), DocType: "md"})
+		if err := st.UpsertChunkTask(ctx, task); err != nil {
+			t.Fatalf("UpsertChunkTask(%d) failed: %v", i, err)
+		}
+	}
+	strong := model.NewChunkTask(1000, "token rotation: token expiry and token refresh", "code", model.ChunkMetadata{RelPath: "src/auth/token.go", DocType: "code"})
+	if err := st.UpsertChunkTask(ctx, strong); err != nil {
+		t.Fatalf("UpsertChunkTask(strong) failed: %v", err)
+	}
+
+	chunks, err := st.SearchChunksLexical(ctx, []string{"token"}, 200)
+	if err != nil {
+		t.Fatalf("SearchChunksLexical failed: %v", err)
+	}
+	if len(chunks) != 200 || chunks[0].Label != 1000 {
+		t.Fatalf("expected the strongest match first among 200, got %d chunks starting with %d", len(chunks), chunks[0].Label)
+	}
+	for i, chunk := range chunks {
+		if chunk.LexicalScore <= 0 || (i > 0 && chunk.LexicalScore > chunks[i-1].LexicalScore) {
+			t.Fatalf("chunk %d: lexical scores must be positive and descending, got %f after %f", i, chunk.LexicalScore, chunks[max(i-1, 0)].LexicalScore)
+		}
+	}
+
+	cases := []struct {
+		name  string
+		query model.SearchQuery
+	}{
+		{"path prefix", model.SearchQuery{PathPrefix: "src/"}},
+		{"file glob", model.SearchQuery{FileGlob: "*.go"}},
+		{"doc type", model.SearchQuery{DocTypes: []string{" CODE "}}},
+	}
+	for _, tc := range cases {
+		chunks, err := st.SearchChunksLexicalFiltered(ctx, []string{"token"}, 1, tc.query)
+		if err != nil {
+			t.Fatalf("%s: SearchChunksLexicalFiltered failed: %v", tc.name, err)
+		}
+		if len(chunks) != 1 || chunks[0].Label != 1000 {
+			t.Fatalf("%s: expected only the src/auth/token.go chunk, got %+v", tc.name, chunks)
+		}
+	}
+
+	// matching is by whole token, so a prefix of a word does not match.
+	chunks, err = st.SearchChunksLexical(ctx, []string{"tok"}, 10)
+	if err != nil {
+		t.Fatalf("SearchChunksLexical(tok) failed: %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Fatalf("expected no chunks for a partial token, got %d", len(chunks))
+	}
+}
+
 func TestSQLiteStoreConcurrentReadWriteWithWAL(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"dir2mcp/internal/ingest"
+	"dir2mcp/internal/model"
+	"dir2mcp/internal/store"
+)
+
+func newSymbolTestStore(t *testing.T) *store.SQLiteStore {
+	t.Helper()
+	st := store.NewSQLiteStore(filepath.Join(t.TempDir(), "meta.sqlite"))
+	if err := st.Init(context.Background()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+func indexCodeDocument(t *testing.T, st *store.SQLiteStore, relPath, content string) model.Document {
+	t.Helper()
+	ctx := context.Background()
+	if err := st.UpsertDocument(ctx, model.Document{RelPath: relPath, DocType: "code", ContentHash: relPath, Status: "ok"}); err != nil {
+		t.Fatalf("UpsertDocument(%s) failed: %v", relPath, err)
+	}
+	doc, err := st.GetDocumentByPath(ctx, relPath)
+	if err != nil {
+		t.Fatalf("GetDocumentByPath(%s) failed: %v", relPath, err)
+	}
+	if err := ingest.NewRepresentationGenerator(st).GenerateRawTextFromContent(ctx, doc, []byte(content)); err != nil {
+		t.Fatalf("GenerateRawTextFromContent(%s) failed: %v", relPath, err)
+	}
+	return doc
+}
+
+func TestSQLiteStore_IngestRecordsSearchableSymbols(t *testing.T) {
+	ctx := context.Background()
+	st := newSymbolTestStore(t)
+	indexCodeDocument(t, st, "pkg/store.go", "package pkg\n\ntype Store struct{}\n\nfunc (s *Store) Search() {}\n\nfunc SearchAll() {}\n")
+	indexCodeDocument(t, st, "web/search.py", "def search():\n    pass\n")
+
+	cases := []struct {
+		name  string
+		query model.SymbolQuery
+		want  []string
+	}{
+		{"substring ranks exact name first", model.SymbolQuery{Name: "search"}, []string{"pkg/store.go:5:Search", "web/search.py:1:search", "pkg/store.go:7:SearchAll"}},
+		{"exact", model.SymbolQuery{Name: "SEARCH", Exact: true}, []string{"pkg/store.go:5:Search", "web/search.py:1:search"}},
+		{"kind", model.SymbolQuery{Kinds: []string{model.SymbolKindStruct}}, []string{"pkg/store.go:3:Store"}},
+		{"container", model.SymbolQuery{Name: "search", Container: "store"}, []string{"pkg/store.go:5:Search"}},
+		{"language alias", model.SymbolQuery{Name: "search", Language: "py"}, []string{"web/search.py:1:search"}},
+		{"prefix", model.SymbolQuery{Name: "search", PathPrefix: "web/"}, []string{"web/search.py:1:search"}},
+		{"glob", model.SymbolQuery{Name: "search", FileGlob: "*.go"}, []string{"pkg/store.go:5:Search", "pkg/store.go:7:SearchAll"}},
+		{"limit", model.SymbolQuery{Name: "search", Limit: 1}, []string{"pkg/store.go:5:Search"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := st.SearchSymbols(ctx, tc.query)
+			if err != nil {
+				t.Fatalf("SearchSymbols failed: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %+v", tc.want, got)
+			}
+			for i, sym := range got {
+				if key := sym.RelPath + ":" + strconv.Itoa(sym.Span.StartLine) + ":" + sym.Name; key != tc.want[i] {
+					t.Fatalf("expected %v, got %+v", tc.want, got)
+				}
+				if sym.Span.Kind != "lines" {
+					t.Fatalf("expected lines span, got %+v", sym.Span)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteStore_ReplaceSymbolsSwapsDocumentSymbols(t *testing.T) {
+	ctx := context.Background()
+	st := newSymbolTestStore(t)
+	doc := indexCodeDocument(t, st, "pkg/a.go", "package pkg\n\nfunc Old() {}\n")
+
+	if err := st.ReplaceSymbols(ctx, doc.DocID, []model.Symbol{
+		{Name: "New", Kind: model.SymbolKindFunction, Language: "go", Span: model.Span{Kind: "lines", StartLine: 3, EndLine: 3}, Exact: true},
+	}); err != nil {
+		t.Fatalf("ReplaceSymbols failed: %v", err)
+	}
+	old, err := st.SearchSymbols(ctx, model.SymbolQuery{Name: "Old", Exact: true})
+	if err != nil {
+		t.Fatalf("SearchSymbols failed: %v", err)
+	}
+	if len(old) != 0 {
+		t.Fatalf("expected replaced symbol to be gone, got %+v", old)
+	}
+	got, err := st.SearchSymbols(ctx, model.SymbolQuery{Name: "New", Exact: true})
+	if err != nil {
+		t.Fatalf("SearchSymbols failed: %v", err)
+	}
+	if len(got) != 1 || got[0].RelPath != "pkg/a.go" || got[0].DocID != doc.DocID || !got[0].Exact {
+		t.Fatalf("unexpected replacement symbols: %+v", got)
+	}
+}
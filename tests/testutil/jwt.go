@@ -0,0 +1,121 @@
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// JWTIssuer signs test access tokens with locally generated keys and
+// publishes their public halves as a JWKS file.
+type JWTIssuer struct {
+	RSA      *rsa.PrivateKey
+	EC       *ecdsa.PrivateKey
+	JWKSPath string
+}
+
+// NewJWTIssuer generates an RS256 and an ES256 key and writes the JWKS to a
+// temporary file.
+func NewJWTIssuer(t *testing.T) *JWTIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	issuer := &JWTIssuer{
+		RSA:      rsaKey,
+		EC:       ecKey,
+		JWKSPath: filepath.Join(t.TempDir(), "jwks.json"),
+	}
+	jwks := map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kty": "RSA",
+				"kid": "rsa-1",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   b64(rsaKey.N.Bytes()),
+				"e":   b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec-1",
+				"use": "sig",
+				"crv": "P-256",
+				"x":   b64(ecKey.X.FillBytes(make([]byte, 32))),
+				"y":   b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	}
+	raw, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	if err := os.WriteFile(issuer.JWKSPath, raw, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	return issuer
+}
+
+// Sign returns a JWT with claims signed by the RSA key (kid "rsa-1").
+func (i *JWTIssuer) Sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	signingInput := i.signingInput(t, map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": "rsa-1"}, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.RSA, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign jwt: %v", err)
+	}
+	return signingInput + "." + b64(signature)
+}
+
+// SignES256 returns a JWT with claims signed by the EC key (kid "ec-1").
+func (i *JWTIssuer) SignES256(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	signingInput := i.signingInput(t, map[string]interface{}{"alg": "ES256", "typ": "JWT", "kid": "ec-1"}, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, i.EC, digest[:])
+	if err != nil {
+		t.Fatalf("sign jwt: %v", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + b64(signature)
+}
+
+// Unsigned returns a JWT using alg "none".
+func (i *JWTIssuer) Unsigned(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	return i.signingInput(t, map[string]interface{}{"alg": "none", "typ": "JWT"}, claims) + "."
+}
+
+func (i *JWTIssuer) signingInput(t *testing.T, header, claims map[string]interface{}) string {
+	t.Helper()
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("marshal jwt header: %v", err)
+	}
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal jwt claims: %v", err)
+	}
+	return b64(rawHeader) + "." + b64(rawClaims)
+}
+
+func b64(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dir2mcp/internal/tokens"
+)
+
+func TestSaveStoresOnlyHashesWithOwnerOnlyPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".dir2mcp", tokens.FileName)
+	var file tokens.File
+	token, secret, err := file.Create(tokens.Token{Name: "ci", Tools: []string{"dir2mcp.search"}}, time.Now())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := tokens.Save(path, file); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read token file: %v", err)
+	}
+	if strings.Contains(string(raw), secret) {
+		t.Fatal("token file contains the secret")
+	}
+	if !strings.Contains(string(raw), tokens.HashSecret(secret)) {
+		t.Fatal("token file does not contain the secret's hash")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat token file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("token file mode=%o want=600", perm)
+	}
+
+	store := tokens.NewStore(path)
+	got, err := store.Authenticate(secret, time.Now())
+	if err != nil || got.ID != token.ID {
+		t.Fatalf("authenticate: token=%#v err=%v", got, err)
+	}
+	if _, err := store.Authenticate(secret+"x", time.Now()); !errors.Is(err, tokens.ErrUnknownToken) {
+		t.Fatalf("wrong secret: err=%v want ErrUnknownToken", err)
+	}
+}
+
+func TestCreateRevokeAndExpiry(t *testing.T) {
+	now := time.Now()
+	var file tokens.File
+	if _, _, err := file.Create(tokens.Token{Name: "ci", ExpiresAt: now.Add(time.Hour)}, now); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, _, err := file.Create(tokens.Token{Name: "ci"}, now); err == nil {
+		t.Fatal("expected a duplicate name to be rejected")
+	}
+	if _, _, err := file.Create(tokens.Token{Name: "old", ExpiresAt: now.Add(-time.Minute)}, now); err == nil {
+		t.Fatal("expected an expiry in the past to be rejected")
+	}
+	if !file.Tokens[0].Expired(now.Add(2 * time.Hour)) {
+		t.Fatal("expected the token to expire after its lifetime")
+	}
+
+	revoked, err := file.Revoke("ci", now)
+	if err != nil || !revoked.Revoked() {
+		t.Fatalf("revoke: token=%#v err=%v", revoked, err)
+	}
+	if _, err := file.Revoke("ci", now); !errors.Is(err, tokens.ErrNotFound) {
+		t.Fatalf("second revoke: err=%v want ErrNotFound", err)
+	}
+	// the name is free again once revoked.
+	if _, _, err := file.Create(tokens.Token{Name: "ci"}, now); err != nil {
+		t.Fatalf("recreate: %v", err)
+	}
+}
+
+func TestAllowsPathPrefixesAndGlobs(t *testing.T) {
+	token := tokens.Token{PathPrefixes: []string{"docs/"}, PathGlobs: []string{"**/*.go"}}
+	cases := map[string]bool{
+		"docs/a.md":          true,
+		"./docs/guide/b.md":  true,
+		"internal/mcp/x.go":  true,
+		"private/notes.md":   false,
+		"documents/other.md": false,
+	}
+	for relPath, want := range cases {
+		if got := token.AllowsPath(relPath); got != want {
+			t.Errorf("AllowsPath(%q)=%t want=%t", relPath, got, want)
+		}
+	}
+	if !(tokens.Token{}).AllowsPath("anything") {
+		t.Error("a token without path scopes should allow every path")
+	}
+}
+
+func TestAllowsPathRejectsTraversalAndPartialSegments(t *testing.T) {
+	for _, prefix := range []string{"docs", "docs/"} {
+		token := tokens.Token{PathPrefixes: []string{prefix}, PathGlobs: []string{"docs/**"}}
+		cases := map[string]bool{
+			"docs":                      true,
+			"docs/a.md":                 true,
+			"docs/guide/../b.md":        true,
+			"docs/../secrets/key.txt":   false,
+			"docs/../../etc/passwd":     false,
+			"./docs/../secrets/key.txt": false,
+			"../docs/a.md":              false,
+			"/docs/a.md":                false,
+			"docs-private/x.md":         false,
+			"docsx":                     false,
+		}
+		for relPath, want := range cases {
+			if got := token.AllowsPath(relPath); got != want {
+				t.Errorf("prefix %q: AllowsPath(%q)=%t want=%t", prefix, relPath, got, want)
+			}
+		}
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -732,3 +734,97 @@ func TestCapturingIngestorReindexErrorOnMissingConfig(t *testing.T) {
 		t.Fatal("expected error when store and capturedHash are nil")
 	}
 }
+
+func TestUpServesMetricsWithItsOwnToken(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("MISTRAL_API_KEY", "test-key")
+	t.Setenv("DIR2MCP_AUTH_TOKEN", "")
+
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	metricsAddr := probe.Addr().String()
+	_ = probe.Close()
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	app := cli.NewAppWithIO(&stdout, &stderr)
+
+	withWorkingDir(t, tmp, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan int, 1)
+		go func() {
+			done <- app.RunWithContext(ctx, []string{"up", "--listen", "127.0.0.1:0", "--metrics-listen", metricsAddr, "--read-only", "--json"})
+		}()
+
+		metricsURL := "http://" + metricsAddr + "/metrics"
+		var resp *http.Response
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			resp, err = http.Get(metricsURL)
+			if err == nil || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("metrics endpoint did not come up: %v stderr=%s", err, stderr.String())
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("unauthenticated scrape status=%d want 401", resp.StatusCode)
+		}
+
+		token, err := os.ReadFile(filepath.Join(tmp, ".dir2mcp", "metrics.token"))
+		if err != nil {
+			t.Fatalf("read metrics token: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, metricsURL, nil)
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("scrape status=%d body=%s", resp.StatusCode, body)
+		}
+		for _, want := range []string{
+			`dir2mcp_index_vectors{index="text"} 0`,
+			`dir2mcp_embedding_pending_chunks{index="code"} 0`,
+			"dir2mcp_ingest_scanned_total ",
+			"dir2mcp_mcp_active_sessions 0",
+		} {
+			if !strings.Contains(string(body), want) {
+				t.Fatalf("missing %q in metrics:\n%s", want, body)
+			}
+		}
+
+		cancel()
+		if code := <-done; code != 0 {
+			t.Fatalf("unexpected exit code: got=%d stderr=%s", code, stderr.String())
+		}
+	})
+}
+
+func TestUpMetricsWithoutAuthRequiresLoopback(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("MISTRAL_API_KEY", "test-key")
+	t.Setenv("DIR2MCP_AUTH_TOKEN", "")
+	t.Setenv("DIR2MCP_METRICS_AUTH", "none")
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	app := cli.NewAppWithIO(&stdout, &stderr)
+
+	var code int
+	withWorkingDir(t, tmp, func() {
+		code = app.RunWithContext(context.Background(), []string{"up", "--metrics-listen", "0.0.0.0:0"})
+	})
+	if code != 2 || !strings.Contains(stderr.String(), "metrics on a non-loopback address require auth") {
+		t.Fatalf("code=%d stderr=%s want the metrics auth guardrail", code, stderr.String())
+	}
+}